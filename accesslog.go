@@ -0,0 +1,61 @@
+package quickserve
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultAccessLogSkip keeps load balancer probes out of the access log
+var defaultAccessLogSkip = []string{"/health", "/health/weight", "/healthz", "/readyz"}
+
+// WithAccessLog logs every request at info with the "access" component.
+// Requests for the skip paths are not logged; a path ending in "/" skips
+// everything below it.
+func WithAccessLog(skip ...string) Option {
+	return func(s *Server) {
+		s.accessLog = true
+		s.accessLogSkip = skip
+	}
+}
+
+// skipAccessLog reports whether path is excluded from the access log
+func (s *Server) skipAccessLog(path string) bool {
+	for _, p := range s.accessLogSkip {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// logAccess writes one line per request with its outcome. Latency is
+// measured in real time regardless of the server clock.
+func (s *Server) logAccess(next http.Handler) http.Handler {
+	if !s.accessLog {
+		return next
+	}
+	logger := s.componentLogger("access")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.skipAccessLog(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		logger.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"bytes", sw.bytes,
+			"duration", time.Since(start),
+			"client_ip", s.clientIP(r),
+		)
+	})
+}
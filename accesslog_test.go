@@ -0,0 +1,49 @@
+package quickserve
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAccessLog(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var buf bytes.Buffer
+	logger, _ := NewLogger(&buf, "text", slog.LevelInfo)
+	handler := NewServer(WithLogger(logger), WithAccessLog("/health", "/admin/")).Routes()
+
+	for _, path := range []string{"/users/999", "/health", "/admin/routes"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(RequestIDHeader, "trace-7")
+		req.RemoteAddr = "203.0.113.9:4000"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected skip paths to be left out, got:\n%s", buf.String())
+	}
+	for _, want := range []string{
+		"component=access",
+		"method=GET",
+		"path=/users/999",
+		"status=404",
+		"bytes=",
+		"duration=",
+		"client_ip=203.0.113.9",
+		"request_id=trace-7",
+	} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected %q in %q", want, lines[0])
+		}
+	}
+	if strings.Contains(lines[0], "bytes=0 ") {
+		t.Errorf("expected the error body to be counted, got %q", lines[0])
+	}
+}
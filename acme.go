@@ -0,0 +1,38 @@
+package quickserve
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultACMECache is where ACME certificates and the account key are kept
+const defaultACMECache = "acme-cache"
+
+// newACMEManager obtains and renews certificates for domains from Let's
+// Encrypt, refusing to request them for any other host name
+func newACMEManager(domains []string, cacheDir, email string) *autocert.Manager {
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      email,
+	}
+}
+
+// acmeTLSConfig serves certificates from m on top of base, and answers
+// TLS-ALPN-01 challenges on the HTTPS listener itself
+func acmeTLSConfig(base *tls.Config, m *autocert.Manager) *tls.Config {
+	cfg := base.Clone()
+	cfg.GetCertificate = m.GetCertificate
+	cfg.NextProtos = append([]string{"h2", "http/1.1"}, acme.ALPNProto)
+	return cfg
+}
+
+// acmeChallengeHandler answers HTTP-01 challenges and redirects every
+// other request to HTTPS
+func acmeChallengeHandler(m *autocert.Manager, httpsAddr string) http.Handler {
+	return m.HTTPHandler(redirectToHTTPS(httpsAddr))
+}
@@ -0,0 +1,42 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"golang.org/x/crypto/acme"
+)
+
+func TestACMEManager(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	m := newACMEManager([]string{"example.com"}, t.TempDir(), "ops@example.com")
+	if err := m.HostPolicy(context.Background(), "example.com"); err != nil {
+		t.Errorf("expected configured domain to be allowed: %v", err)
+	}
+	if err := m.HostPolicy(context.Background(), "evil.example"); err == nil {
+		t.Error("expected other hosts to be refused")
+	}
+
+	cfg := acmeTLSConfig(defaultTLSConfig(), m)
+	if cfg.GetCertificate == nil || !slices.Contains(cfg.NextProtos, acme.ALPNProto) {
+		t.Error("expected certificates and TLS-ALPN-01 to be served by the manager")
+	}
+}
+
+func TestACMEChallengeHandlerRedirects(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	m := newACMEManager([]string{"example.com"}, t.TempDir(), "")
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	w := httptest.NewRecorder()
+	acmeChallengeHandler(m, ":443").ServeHTTP(w, req)
+
+	if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "https://example.com/users" {
+		t.Errorf("expected redirect to HTTPS, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -0,0 +1,186 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultActivityLimit = 20
+	maxActivityLimit     = 100
+)
+
+// Activity types
+const (
+	ActivityLogin          = "login"
+	ActivityAccountCreated = "account_created"
+	ActivityProfileUpdated = "profile_updated"
+	ActivityAccountDeleted = "account_deleted"
+	ActivityGroupJoined    = "group_joined"
+	ActivityGroupLeft      = "group_left"
+	ActivityGroupRole      = "group_role_changed"
+)
+
+// publicActivity lists the activity types anyone may see. Logins and
+// profile changes are only shown to the user themselves and to admins.
+var publicActivity = map[string]bool{
+	ActivityAccountCreated: true,
+	ActivityGroupJoined:    true,
+	ActivityGroupLeft:      true,
+	ActivityGroupRole:      true,
+}
+
+// ActivityItem is one entry in a user's activity feed
+type ActivityItem struct {
+	ID         ID             `json:"id"`
+	Type       string         `json:"type"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Details    map[string]any `json:"details,omitempty"`
+	// Actor and RequestID are only shown to the user and admins
+	Actor     string `json:"actor,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// activityPage is the body of GET /users/{id}/activity
+type activityPage struct {
+	Items      []ActivityItem `json:"items"`
+	NextCursor *ID            `json:"next_cursor,omitempty"`
+}
+
+// recordLogin adds a successful login by p to the event log and notifies
+// the user
+func (s *Server) recordLogin(r *http.Request, p Principal) {
+	s.recordAudit(r.WithContext(WithPrincipal(r.Context(), p)), AuditLogin, "session", "", nil, p)
+	s.notifyLogin(r.Context(), p)
+}
+
+// activityFor maps an event log entry to an activity item of the user it
+// concerns, if it concerns one
+func activityFor(e AuditEntry) (ActivityItem, ID, bool) {
+	item := ActivityItem{ID: e.ID, OccurredAt: e.OccurredAt, Actor: e.Actor, RequestID: e.RequestID}
+
+	switch e.Resource {
+	case "session":
+		if e.Action != AuditLogin || e.ActorUserID == 0 {
+			return ActivityItem{}, 0, false
+		}
+		var p Principal
+		json.Unmarshal(e.After, &p)
+		item.Type = ActivityLogin
+		item.Details = map[string]any{"method": p.Method}
+		return item, e.ActorUserID, true
+
+	case "user":
+		id, err := ParseID(e.ResourceID)
+		if err != nil {
+			return ActivityItem{}, 0, false
+		}
+		item.Type = map[string]string{
+			AuditCreate: ActivityAccountCreated,
+			AuditUpdate: ActivityProfileUpdated,
+			AuditDelete: ActivityAccountDeleted,
+		}[e.Action]
+		return item, id, item.Type != ""
+
+	case "membership":
+		var m Membership
+		snapshot := e.After
+		if snapshot == nil {
+			snapshot = e.Before
+		}
+		if json.Unmarshal(snapshot, &m) != nil {
+			return ActivityItem{}, 0, false
+		}
+		item.Type = map[string]string{
+			AuditCreate: ActivityGroupJoined,
+			AuditUpdate: ActivityGroupRole,
+			AuditDelete: ActivityGroupLeft,
+		}[e.Action]
+		item.Details = map[string]any{"org_id": m.OrgID, "role": m.Role}
+		if m.TeamID != 0 {
+			item.Details["team_id"] = m.TeamID
+		}
+		return item, m.UserID, item.Type != ""
+	}
+	return ActivityItem{}, 0, false
+}
+
+// selfOrAdmin reports whether the caller is user or an admin, and so may
+// see the user's private data. Without authentication everything is
+// visible, as for other routes.
+func (s *Server) selfOrAdmin(r *http.Request, user User) bool {
+	p, ok := PrincipalFromContext(r.Context())
+	if !ok || p.Role == RoleAdmin {
+		return true
+	}
+	return p.Email != "" && p.Email == user.Email
+}
+
+// HandleUserActivity handles GET /users/{id}/activity, newest first.
+// Pages are requested with ?limit= and continued with ?before=, set to
+// the previous page's next_cursor.
+func (s *Server) HandleUserActivity(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user, ok, err := s.users(r.Context()).Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := defaultActivityLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxActivityLimit {
+			httpError(w, r, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	var before ID
+	if v := q.Get("before"); v != "" {
+		if before, err = ParseID(v); err != nil {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	full := s.selfOrAdmin(r, user)
+	entries := s.audit.Query(AuditFilter{})
+	slices.Reverse(entries)
+
+	items := make([]ActivityItem, 0, limit)
+	var next *ID
+	for _, e := range entries {
+		if before != 0 && e.ID >= before {
+			continue
+		}
+		item, subject, ok := activityFor(e)
+		if !ok || subject != id {
+			continue
+		}
+		if !full {
+			if !publicActivity[item.Type] {
+				continue
+			}
+			item.Actor, item.RequestID = "", ""
+		}
+		if len(items) == limit {
+			cursor := items[len(items)-1].ID
+			next = &cursor
+			break
+		}
+		items = append(items, item)
+	}
+
+	s.writeJSON(w, r, http.StatusOK, activityPage{items, next})
+}
@@ -0,0 +1,114 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUserActivityFeed(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithAPIKeyAuth("admin-secret"))
+	_, userSecret, err := server.apiKeys.Create("viewer", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := server.Routes()
+
+	do := func(secret, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, secret)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+	feed := func(secret, query string) activityPage {
+		t.Helper()
+		w := do(secret, http.MethodGet, "/users/1/activity"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var page activityPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	do("admin-secret", http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	do("admin-secret", http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com"}`)
+	do("admin-secret", http.MethodPost, "/orgs", `{"name":"Acme"}`)
+	clock.Advance(time.Minute)
+	do("admin-secret", http.MethodPut, "/orgs/1/members/1", `{"role":"user"}`)
+	do("admin-secret", http.MethodPut, "/orgs/1/members/2", `{"role":"user"}`)
+	req := httptest.NewRequest(http.MethodPost, "/auth/callback", nil)
+	server.recordLogin(req, Principal{Subject: "alice", Method: "oidc", Email: "alice@test.com", Role: RoleUser})
+
+	page := feed("admin-secret", "")
+	var types []string
+	for _, item := range page.Items {
+		types = append(types, item.Type)
+	}
+	if got := strings.Join(types, ","); got != "login,group_joined,account_created" {
+		t.Fatalf("unexpected feed: %s", got)
+	}
+	if page.Items[0].Details["method"] != "oidc" || page.Items[1].Details["org_id"] != float64(1) {
+		t.Errorf("unexpected details: %+v", page.Items[:2])
+	}
+	if page.Items[1].Actor == "" {
+		t.Error("expected the actor to be shown to admins")
+	}
+
+	other := feed(userSecret, "")
+	if len(other.Items) != 2 || other.Items[0].Type != ActivityGroupJoined {
+		t.Fatalf("expected only public activity for other users, got %+v", other.Items)
+	}
+	if other.Items[0].Actor != "" || other.Items[0].RequestID != "" {
+		t.Errorf("expected actor and request id to be hidden, got %+v", other.Items[0])
+	}
+
+	first := feed("admin-secret", "?limit=2")
+	if len(first.Items) != 2 || first.NextCursor == nil {
+		t.Fatalf("expected a full page with a cursor, got %+v", first)
+	}
+	rest := feed("admin-secret", fmt.Sprintf("?limit=2&before=%d", *first.NextCursor))
+	if len(rest.Items) != 1 || rest.Items[0].Type != ActivityAccountCreated || rest.NextCursor != nil {
+		t.Errorf("expected the last item and no cursor, got %+v", rest)
+	}
+
+	if w := do("admin-secret", http.MethodGet, "/users/1/activity?limit=0", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for limit=0, got %d", w.Code)
+	}
+	if w := do("admin-secret", http.MethodGet, "/users/9/activity", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown user, got %d", w.Code)
+	}
+}
+
+func TestUsersSeeTheirOwnActivity(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	alice := User{ID: 1, Email: "alice@test.com"}
+	as := func(p Principal) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/users/1/activity", nil)
+		return req.WithContext(WithPrincipal(req.Context(), p))
+	}
+
+	if !server.selfOrAdmin(as(Principal{Email: "alice@test.com", Role: RoleUser}), alice) {
+		t.Error("expected users to see all of their own activity")
+	}
+	if server.selfOrAdmin(as(Principal{Email: "bob@test.com", Role: RoleUser}), alice) {
+		t.Error("expected other users to see only public activity")
+	}
+	if !server.selfOrAdmin(as(Principal{Role: RoleAdmin}), alice) {
+		t.Error("expected admins to see all activity")
+	}
+}
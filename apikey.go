@@ -0,0 +1,266 @@
+package quickserve
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// APIKeyHeader is the request header carrying an API key
+const APIKeyHeader = "X-API-Key"
+
+// APIKey describes an issued API key. The secret itself is never stored;
+// only its SHA-256 hash is kept so a dump of the store cannot be replayed.
+type APIKey struct {
+	ID        ID         `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Role      Role       `json:"role"`
+	Quota     *Quota     `json:"quota,omitempty"`
+	NotifyURL string     `json:"notify_url,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	hash [sha256.Size]byte
+}
+
+// Revoked reports whether the key has been revoked
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyStore is an in-memory API key store
+type APIKeyStore struct {
+	mu    sync.RWMutex
+	keys  map[ID]APIKey
+	next  ID
+	clock Clock
+}
+
+// NewAPIKeyStore creates a new API key store
+func NewAPIKeyStore() *APIKeyStore {
+	return &APIKeyStore{
+		keys:  make(map[ID]APIKey),
+		next:  1,
+		clock: SystemClock{},
+	}
+}
+
+// Create issues a new key and returns it together with its secret.
+// The secret is only available at creation time.
+func (s *APIKeyStore) Create(name string, role Role) (APIKey, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := "qs_" + hex.EncodeToString(buf)
+	return s.add(name, secret, role), secret, nil
+}
+
+// Import registers a caller-supplied secret, e.g. a bootstrap key from
+// the environment.
+func (s *APIKeyStore) Import(name, secret string, role Role) APIKey {
+	return s.add(name, secret, role)
+}
+
+func (s *APIKeyStore) add(name, secret string, role Role) APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	key := APIKey{
+		ID:        s.next,
+		Name:      name,
+		Prefix:    keyPrefix(secret),
+		Role:      role,
+		CreatedAt: now,
+		UpdatedAt: now,
+		hash:      sha256.Sum256([]byte(secret)),
+	}
+	s.keys[s.next] = key
+	s.next++
+	return key
+}
+
+// Get retrieves a key by ID
+func (s *APIKeyStore) Get(id ID) (APIKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	return key, ok
+}
+
+// SetQuota overrides the default quota for a key. A nil quota restores
+// the default.
+func (s *APIKeyStore) SetQuota(id ID, q *Quota) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return false
+	}
+	key.Quota = q
+	key.UpdatedAt = s.clock.Now()
+	s.keys[id] = key
+	return true
+}
+
+// SetNotifyURL sets where the key's owner is told about the key's calls
+// to deprecated routes. An empty URL turns notifications off.
+func (s *APIKeyStore) SetNotifyURL(id ID, u string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return false
+	}
+	key.NotifyURL = u
+	key.UpdatedAt = s.clock.Now()
+	s.keys[id] = key
+	return true
+}
+
+// List returns all keys, including revoked ones
+func (s *APIKeyStore) List() []APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return cmp.Compare(a.ID, b.ID) })
+	return keys
+}
+
+// Revoke marks a key as revoked. It returns false if the key does not exist.
+func (s *APIKeyStore) Revoke(id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return false
+	}
+	if key.RevokedAt == nil {
+		now := s.clock.Now()
+		key.RevokedAt = &now
+		key.UpdatedAt = now
+		s.keys[id] = key
+	}
+	return true
+}
+
+// Authenticate looks up the active key matching secret
+func (s *APIKeyStore) Authenticate(secret string) (APIKey, bool) {
+	if secret == "" {
+		return APIKey{}, false
+	}
+	hash := sha256.Sum256([]byte(secret))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare(k.hash[:], hash[:]) == 1 {
+			if k.Revoked() {
+				return APIKey{}, false
+			}
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+func keyPrefix(secret string) string {
+	if len(secret) > 8 {
+		return secret[:8]
+	}
+	return secret
+}
+
+// HandleListAPIKeys handles GET /admin/keys
+func (s *Server) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys := s.apiKeys.List()
+
+	s.writeJSON(w, r, http.StatusOK, keys)
+}
+
+// HandleCreateAPIKey handles POST /admin/keys
+func (s *Server) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string `json:"name"`
+		Role      Role   `json:"role"`
+		Quota     *Quota `json:"quota"`
+		NotifyURL string `json:"notify_url"`
+	}
+
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleUser
+	}
+	if !req.Role.Valid() {
+		httpError(w, r, "invalid role", http.StatusBadRequest)
+		return
+	}
+	if req.NotifyURL != "" {
+		if u, err := url.Parse(req.NotifyURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			httpError(w, r, "notify_url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, secret, err := s.apiKeys.Create(req.Name, req.Role)
+	if err != nil {
+		httpError(w, r, "could not generate key", http.StatusInternalServerError)
+		return
+	}
+	if req.Quota != nil {
+		s.apiKeys.SetQuota(key.ID, req.Quota)
+		key.Quota = req.Quota
+	}
+	if req.NotifyURL != "" {
+		s.apiKeys.SetNotifyURL(key.ID, req.NotifyURL)
+		key.NotifyURL = req.NotifyURL
+	}
+	s.recordAudit(r, AuditCreate, "api_key", key.ID.String(), nil, key)
+
+	s.writeJSON(w, r, http.StatusCreated, struct {
+		APIKey
+		Key string `json:"key"`
+	}{key, secret})
+}
+
+// HandleRevokeAPIKey handles DELETE /admin/keys/{id}
+func (s *Server) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := ParseID(idStr)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	before, ok := s.apiKeys.Get(id)
+	if !ok || !s.apiKeys.Revoke(id) {
+		httpError(w, r, "api key not found", http.StatusNotFound)
+		return
+	}
+	after, _ := s.apiKeys.Get(id)
+	s.recordAudit(r, AuditUpdate, "api_key", id.String(), before, after)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,90 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAPIKeyRequired(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("bootstrap-secret"))
+	routes := server.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(APIKeyHeader, "bootstrap-secret")
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("bootstrap-secret"))
+	routes := server.Routes()
+
+	body := bytes.NewBufferString(`{"name":"billing"}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/keys", body)
+	req.Header.Set(APIKeyHeader, "bootstrap-secret")
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	var created struct {
+		ID  ID     `json:"id"`
+		Key string `json:"key"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	if _, ok := server.apiKeys.Authenticate(created.Key); !ok {
+		t.Fatal("expected new key to authenticate")
+	}
+
+	if !server.apiKeys.Revoke(created.ID) {
+		t.Fatal("expected revoke to succeed")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(APIKeyHeader, created.Key)
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for revoked key, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyListHidesSecret(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewAPIKeyStore()
+	_, secret, err := store.Create("ci", RoleUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _ := json.Marshal(store.List())
+	if bytes.Contains(out, []byte(secret)) {
+		t.Error("expected listed keys not to contain the secret")
+	}
+}
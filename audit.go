@@ -0,0 +1,344 @@
+package quickserve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// AuditLogin records a successful sign-in rather than a mutation
+	AuditLogin = "login"
+	// AuditAlert records a limit being approached rather than a mutation
+	AuditAlert = "alert"
+)
+
+// AuditEntry records one mutation: who made it, when, to what, and the
+// resource before and after
+type AuditEntry struct {
+	ID          ID              `json:"id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Actor       string          `json:"actor,omitempty"`
+	ActorUserID ID              `json:"actor_user_id,omitempty"`
+	Action      string          `json:"action"`
+	Resource    string          `json:"resource"`
+	ResourceID  string          `json:"resource_id,omitempty"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	RequestID   string          `json:"request_id,omitempty"`
+	// Method and Path are those of the request that made the change, and
+	// empty for changes made without one
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// AuditLog is an append-only record of mutations, but for entries pruned
+// once past their retention. With a file, each entry is also appended to
+// it as a JSON line and the log survives restarts.
+type AuditLog struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+	// last is the ID of the last entry appended, which pruning keeps
+	last  ID
+	path  string
+	file  *os.File
+	drift []SchemaDrift
+}
+
+// NewAuditLog creates an in-memory audit log
+func NewAuditLog() *AuditLog {
+	return &AuditLog{}
+}
+
+// OpenAuditLog opens the audit log at path, loading existing entries.
+// Entries that do not match the expected schema are left out and
+// reported by Drift.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &AuditLog{path: path, file: f}
+	check := newSchemaCheck("audit log", AuditEntry{})
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		record := fmt.Sprintf("%s:%d", path, line)
+		if !check.record(record, sc.Bytes()) {
+			continue
+		}
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			check.add("", "undecodable", "", err.Error(), record)
+			continue
+		}
+		l.entries = append(l.entries, e)
+		l.last = max(l.last, e.ID)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l.drift = check.report()
+	return l, nil
+}
+
+// Drift reports entries in the backing file that could not be loaded
+func (l *AuditLog) Drift() []SchemaDrift {
+	return l.drift
+}
+
+// Close closes the backing file, if any
+func (l *AuditLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Append assigns e the next ID and records it
+func (l *AuditLog) Append(e AuditEntry) (AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.ID = l.last + 1
+	if l.file != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return AuditEntry{}, err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	l.entries = append(l.entries, e)
+	l.last = e.ID
+	return e, nil
+}
+
+// Prune removes the entries drop reports true for and returns how many it
+// removed. With a file, the file is replaced by one holding the rest.
+func (l *AuditLog) Prune(drop func(AuditEntry) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	kept := slices.DeleteFunc(slices.Clone(l.entries), drop)
+	n := len(l.entries) - len(kept)
+	if n == 0 {
+		return 0, nil
+	}
+	if l.file != nil {
+		if err := l.rewrite(kept); err != nil {
+			return 0, err
+		}
+	}
+	l.entries = kept
+	return n, nil
+}
+
+// rewrite replaces the file with one holding entries, through a
+// temporary file renamed into place so a crash never leaves a truncated
+// log, and appends to the new one from then on
+func (l *AuditLog) rewrite(entries []AuditEntry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".audit-*.jsonl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = f
+	return nil
+}
+
+// AuditFilter selects entries; zero fields match everything
+type AuditFilter struct {
+	// UserID matches entries made by the user or affecting them
+	UserID ID
+	Since  time.Time
+	Until  time.Time
+	// AfterID matches entries recorded after the one with this ID
+	AfterID ID
+}
+
+func (f AuditFilter) match(e AuditEntry) bool {
+	if f.UserID != 0 && e.ActorUserID != f.UserID && !(e.Resource == "user" && e.ResourceID == f.UserID.String()) {
+		return false
+	}
+	if e.ID <= f.AfterID {
+		return false
+	}
+	if !f.Since.IsZero() && e.OccurredAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.OccurredAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns the entries matching f, oldest first
+func (l *AuditLog) Query(f AuditFilter) []AuditEntry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]AuditEntry, 0)
+	for _, e := range l.entries {
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// WithAuditLog records mutations to l
+func WithAuditLog(l *AuditLog) Option {
+	return func(s *Server) {
+		s.audit = l
+	}
+}
+
+// recordAudit logs a mutation made by r. before and after are snapshots
+// of the resource; either may be nil. A failure to record is logged but
+// does not fail the request, which has already taken effect. Changes to
+// users are then published on the event bus, numbered by their entry.
+func (s *Server) recordAudit(r *http.Request, action, resource, resourceID string, before, after any) {
+	s.appendAudit(r.Context(), AuditEntry{
+		OccurredAt: s.clock.Now(),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		RequestID:  RequestIDFromContext(r.Context()),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+	}, before, after)
+}
+
+// recordChange is recordAudit for a mutation made without a request, such
+// as by seeding or through Embedded, so it reaches the event bus and what
+// consumes it, like the response cache, as well
+func (s *Server) recordChange(ctx context.Context, action, resource, resourceID string, before, after any) {
+	s.appendAudit(ctx, AuditEntry{
+		OccurredAt: s.clock.Now(),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		RequestID:  RequestIDFromContext(ctx),
+	}, before, after)
+}
+
+// appendAudit completes e with its actor and snapshots, appends it and
+// publishes the event it records
+func (s *Server) appendAudit(ctx context.Context, e AuditEntry, before, after any) {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		e.Actor = p.Subject
+		if u, ok, err := s.users(ctx).FindByEmail(ctx, p.Email); p.Email != "" && ok && err == nil {
+			e.ActorUserID = u.ID
+		}
+	}
+
+	var err error
+	if e.Before, err = auditSnapshot(before); err == nil {
+		e.After, err = auditSnapshot(after)
+	}
+	if err == nil {
+		if e, err = s.audit.Append(e); err == nil {
+			// Users are kept in memory, so there is no transaction an
+			// outbox could share with the change; a persistent backend
+			// should write events to one and publish them from a relay
+			if ev, ok := eventFor(e); ok {
+				s.events.Publish(ctx, ev)
+			}
+		}
+	}
+	if err != nil {
+		s.componentLogger("audit").ErrorContext(ctx, "could not record entry",
+			"action", e.Action, "resource", e.Resource, "resource_id", e.ResourceID, "err", err)
+	}
+}
+
+func auditSnapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// HandleListAudit handles GET /admin/audit with optional user_id, since
+// and until filters
+func (s *Server) HandleListAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f AuditFilter
+	if v := q.Get("user_id"); v != "" {
+		id, err := ParseID(v)
+		if err != nil {
+			httpError(w, r, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		f.UserID = id
+	}
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := q.Get(name); v != "" {
+			parsed, err := ParseTimestamp(v)
+			if err != nil {
+				httpError(w, r, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	entries := s.audit.Query(f)
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			httpError(w, r, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		// The most recent entries are the interesting ones
+		if n < len(entries) {
+			entries = entries[len(entries)-n:]
+		}
+	}
+	s.writeJSON(w, r, http.StatusOK, entries)
+}
@@ -0,0 +1,81 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAuditRecordsMutations(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithAPIKeyAuth("admin-secret"))
+	routes := server.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		req.Header.Set(RequestIDHeader, "req-"+method)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	clock.Advance(time.Hour)
+	do(http.MethodDelete, "/users/1", "")
+	do(http.MethodGet, "/users", "")
+
+	entries := server.audit.Query(AuditFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries for 2 mutations, got %d", len(entries))
+	}
+	created, deleted := entries[0], entries[1]
+	if created.Action != AuditCreate || created.Resource != "user" || created.ResourceID != "1" || created.After == nil {
+		t.Errorf("unexpected create entry: %+v", created)
+	}
+	if deleted.Action != AuditDelete || deleted.Before == nil || deleted.After != nil {
+		t.Errorf("unexpected delete entry: %+v", deleted)
+	}
+	if created.Actor != "apikey:1" || created.RequestID != "req-POST" {
+		t.Errorf("expected actor and request id, got %q %q", created.Actor, created.RequestID)
+	}
+
+	w := do(http.MethodGet, "/admin/audit?user_id=1&since=2024-01-01T00:30:00Z", "")
+	var got []AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Action != AuditDelete {
+		t.Errorf("expected only the delete after the cutoff, got %+v", got)
+	}
+}
+
+func TestAuditLogPersists(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Append(AuditEntry{Action: AuditCreate, Resource: "org", ResourceID: "1"})
+	l.Close()
+
+	l, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	e, _ := l.Append(AuditEntry{Action: AuditDelete, Resource: "org", ResourceID: "1"})
+	if e.ID != 2 || len(l.Query(AuditFilter{})) != 2 {
+		t.Errorf("expected entries to survive reopening, got id %d", e.ID)
+	}
+}
@@ -0,0 +1,112 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Principal identifies the authenticated caller of a request
+type Principal struct {
+	Subject string `json:"subject"`
+	Method  string `json:"method"`
+	Email   string `json:"email,omitempty"`
+	Role    Role   `json:"role"`
+	KeyID   ID     `json:"key_id,omitempty"`
+	// Tenant is the tenant the caller's credentials are bound to, if any
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, if any
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// authRequired reports whether any end-user authentication is enabled
+func (s *Server) authRequired() bool {
+	return s.apiKeyAuth || s.oidc != nil || s.clientCAs != nil
+}
+
+// authenticate tries every enabled authentication method in turn.
+// Sessions from POST /login, as a bearer token or cookie, are always
+// accepted. A client certificate comes last so explicit credentials take
+// precedence over the connection's identity.
+func (s *Server) authenticate(r *http.Request) (Principal, bool) {
+	if s.apiKeyAuth {
+		if key, ok := s.apiKeys.Authenticate(r.Header.Get(APIKeyHeader)); ok {
+			return Principal{
+				Subject: "apikey:" + key.ID.String(),
+				Method:  "apikey",
+				Role:    key.Role,
+				KeyID:   key.ID,
+			}, true
+		}
+	}
+	if token, ok := sessionToken(r); ok {
+		// The user is looked up on every request so role changes and
+		// deletions take effect immediately
+		if sess, ok := s.resumeSession(r.Context(), token); ok {
+			if u, ok, err := s.store.Get(r.Context(), sess.UserID); ok && err == nil {
+				return userPrincipal(u), true
+			}
+		}
+	}
+	if s.oidc != nil {
+		if token, ok := bearerToken(r); ok {
+			if claims, err := s.oidc.Verify(r.Context(), token); err == nil {
+				return Principal{
+					Subject: claims.Subject,
+					Method:  "oidc",
+					Email:   claims.Email,
+					Role:    s.oidcRole(r.Context(), claims),
+					Tenant:  claims.Tenant,
+				}, true
+			}
+		}
+	}
+	// Only verified certificates are returned, so this also covers
+	// listeners with client CAs of their own
+	if cert, ok := ClientCertificate(r); ok {
+		return s.certificatePrincipal(r.Context(), cert), true
+	}
+	return Principal{}, false
+}
+
+// requireAuth rejects requests that no enabled method can authenticate,
+// and stores the caller in the request context otherwise
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := s.authenticate(r)
+		if !ok {
+			if s.oidc != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="quickserve"`)
+			}
+			httpError(w, r, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		ctx, ok := s.bindTenant(r.WithContext(WithPrincipal(r.Context(), p)), p)
+		if !ok {
+			httpError(w, r, "forbidden in this tenant", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
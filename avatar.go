@@ -0,0 +1,259 @@
+package quickserve
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	defaultAvatarSize = 80
+	minAvatarSize     = 16
+	maxAvatarSize     = 512
+	// avatarGrid is the identicon's cell count per side; the left half is
+	// mirrored onto the right
+	avatarGrid = 5
+	// maxCachedAvatars bounds the rendered-avatar cache
+	maxCachedAvatars = 1024
+)
+
+// avatarSpec is everything an avatar's pixels depend on. Its hash names
+// the rendered image, so equal specs share one cache entry and one ETag.
+type avatarSpec struct {
+	Style  string // "identicon" or "initials"
+	Seed   string // normalized email
+	Label  string // initials
+	Size   int
+	Format string // "svg" or "png"
+}
+
+func (s avatarSpec) key() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{s.Style, s.Seed, s.Label, strconv.Itoa(s.Size), s.Format}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// avatarCache holds rendered avatars by content key
+type avatarCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (c *avatarCache) get(key string, render func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	b, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return b, nil
+	}
+
+	b, err := render()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	// Evicting an arbitrary entry keeps the cache bounded; everything in
+	// it can be regenerated
+	if len(c.entries) >= maxCachedAvatars {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = b
+	return b, nil
+}
+
+// identicon derives a mirrored grid pattern and a color from seed
+func identicon(seed string) (cells [avatarGrid][avatarGrid]bool, fg color.RGBA) {
+	sum := sha256.Sum256([]byte(seed))
+	fg = color.RGBA{R: sum[0]/2 + 64, G: sum[1]/2 + 64, B: sum[2]/2 + 64, A: 255}
+
+	half := (avatarGrid + 1) / 2
+	bit := 0
+	for y := 0; y < avatarGrid; y++ {
+		for x := 0; x < half; x++ {
+			on := sum[3+bit/8]>>(bit%8)&1 == 1
+			cells[y][x], cells[y][avatarGrid-1-x] = on, on
+			bit++
+		}
+	}
+	return cells, fg
+}
+
+// initials returns up to two uppercase initials of name
+func initials(name string) string {
+	var out []rune
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				out = append(out, unicode.ToUpper(r))
+				break
+			}
+		}
+		if len(out) == 2 {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return "?"
+	}
+	return string(out)
+}
+
+func colorHex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// renderAvatar draws spec
+func renderAvatar(spec avatarSpec) ([]byte, error) {
+	cells, fg := identicon(spec.Seed)
+	bg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+
+	if spec.Format == "png" {
+		return renderIdenticonPNG(cells, fg, bg, spec.Size)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		spec.Size, spec.Size, avatarGrid, avatarGrid)
+	if spec.Style == "initials" {
+		fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, avatarGrid, avatarGrid, colorHex(fg))
+		fmt.Fprintf(&b, `<text x="50%%" y="50%%" dy=".35em" text-anchor="middle" font-family="sans-serif" font-size="2.2" fill="#fff">%s</text>`,
+			html.EscapeString(spec.Label))
+	} else {
+		fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`, avatarGrid, avatarGrid, colorHex(bg))
+		for y, row := range cells {
+			for x, on := range row {
+				if on {
+					fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`, x, y, colorHex(fg))
+				}
+			}
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.Bytes(), nil
+}
+
+func renderIdenticonPNG(cells [avatarGrid][avatarGrid]bool, fg, bg color.RGBA, size int) ([]byte, error) {
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			if cells[py*avatarGrid/size][px*avatarGrid/size] {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// negotiateAvatarFormat picks svg or png from ?format= or the Accept
+// header, preferring SVG when both are equally acceptable
+func negotiateAvatarFormat(r *http.Request) (string, bool) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f, f == "svg" || f == "png"
+	}
+	if r.Header.Get("Accept") == "" {
+		return "svg", true
+	}
+
+	quality := acceptQuality(r)
+	svg, pngQ := quality("image/svg+xml"), quality("image/png")
+	switch {
+	case svg == 0 && pngQ == 0:
+		return "", false
+	case pngQ > svg:
+		return "png", true
+	default:
+		return "svg", true
+	}
+}
+
+// HandleGetAvatar handles GET /users/{id}/avatar. Users have no uploaded
+// pictures, so a deterministic avatar is generated from their email:
+// an identicon by default, or their initials with ?style=initials (SVG
+// only; PNG requests fall back to the identicon).
+func (s *Server) HandleGetAvatar(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user, ok, err := s.users(r.Context()).Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return
+	}
+
+	spec := avatarSpec{Style: "identicon", Seed: strings.ToLower(strings.TrimSpace(user.Email)), Size: defaultAvatarSize}
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minAvatarSize || n > maxAvatarSize {
+			httpError(w, r, fmt.Sprintf("size must be between %d and %d", minAvatarSize, maxAvatarSize), http.StatusBadRequest)
+			return
+		}
+		spec.Size = n
+	}
+	switch style := r.URL.Query().Get("style"); style {
+	case "", "identicon":
+	case "initials":
+		spec.Style = style
+		spec.Label = initials(user.Name)
+	default:
+		httpError(w, r, "style must be identicon or initials", http.StatusBadRequest)
+		return
+	}
+	var acceptable bool
+	if spec.Format, acceptable = negotiateAvatarFormat(r); !acceptable {
+		httpError(w, r, "avatars are available as image/svg+xml or image/png", http.StatusNotAcceptable)
+		return
+	}
+	if spec.Format == "png" {
+		spec.Style, spec.Label = "identicon", ""
+	}
+
+	key := spec.key()
+	etag := `"` + key + `"`
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept")
+	if match := r.Header.Get("If-None-Match"); match == etag || match == "*" {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body, err := s.avatars.get(key, func() ([]byte, error) { return renderAvatar(spec) })
+	if err != nil {
+		httpError(w, r, "could not render avatar", http.StatusInternalServerError)
+		return
+	}
+	if spec.Format == "png" {
+		h.Set("Content-Type", "image/png")
+	} else {
+		h.Set("Content-Type", "image/svg+xml")
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
@@ -0,0 +1,101 @@
+package quickserve
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAvatarNegotiation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	addUser(t, server, User{Name: "Alice Smith", Email: "alice@test.com"})
+	routes := server.Routes()
+
+	do := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/users/1/avatar", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("expected SVG by default, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	w = do("/users/1/avatar?size=32", "image/png,image/svg+xml;q=0.5")
+	if w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("expected PNG when preferred, got %q", w.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+		t.Errorf("expected 32x32, got %v", b)
+	}
+
+	if w := do("/users/1/avatar", "text/html"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected 406, got %d", w.Code)
+	}
+	if w := do("/users/1/avatar?size=4096", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for oversized avatar, got %d", w.Code)
+	}
+	if w := do("/users/1/avatar?style=initials", ""); !strings.Contains(w.Body.String(), ">AS</text>") {
+		t.Errorf("expected initials avatar, got %s", w.Body)
+	}
+}
+
+func TestAvatarDeterministicAndCached(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	addUser(t, server, User{Name: "Bob", Email: "bob@test.com"})
+	addUser(t, server, User{Name: "Carol", Email: "carol@test.com"})
+	routes := server.Routes()
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	first, second := get("/users/1/avatar", ""), get("/users/1/avatar", "")
+	if !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) || first.Header().Get("ETag") != second.Header().Get("ETag") {
+		t.Error("expected identical avatars and ETags for the same user")
+	}
+	if other := get("/users/2/avatar", ""); other.Header().Get("ETag") == first.Header().Get("ETag") {
+		t.Error("expected different users to get different avatars")
+	}
+	if w := get("/users/1/avatar", first.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", w.Code)
+	}
+	if len(server.avatars.entries) != 2 {
+		t.Errorf("expected one cache entry per distinct avatar, got %d", len(server.avatars.entries))
+	}
+}
+
+func TestInitials(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tests := map[string]string{"Alice Smith": "AS", "bob": "B", "Jean-Luc de Picard": "JD", "": "?", "élodie durand": "ÉD"}
+	for name, expected := range tests {
+		if got := initials(name); got != expected {
+			t.Errorf("initials(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
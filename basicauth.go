@@ -0,0 +1,40 @@
+package quickserve
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// WithAdminCredentials protects the /admin route group with HTTP Basic
+// auth using the given username and password
+func WithAdminCredentials(username, password string) Option {
+	return func(s *Server) {
+		s.adminUser = sha256.Sum256([]byte(username))
+		s.adminPass = sha256.Sum256([]byte(password))
+		s.adminAuth = true
+	}
+}
+
+// requireBasicAuth rejects requests without valid admin credentials.
+// Both fields are hashed first so the comparison is constant-time
+// regardless of input length, and both are always compared.
+func (s *Server) requireBasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if ok {
+			u := sha256.Sum256([]byte(username))
+			p := sha256.Sum256([]byte(password))
+			userOK := subtle.ConstantTimeCompare(u[:], s.adminUser[:])
+			passOK := subtle.ConstantTimeCompare(p[:], s.adminPass[:])
+			ok = userOK&passOK == 1
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="quickserve admin", charset="UTF-8"`)
+			httpError(w, r, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		p := Principal{Subject: "admin:" + username, Method: "basic", Role: RoleAdmin}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
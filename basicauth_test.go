@@ -0,0 +1,58 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAdminBasicAuth(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAdminCredentials("admin", "s3cret"))
+	routes := server.Routes()
+
+	tests := []struct {
+		name     string
+		user     string
+		pass     string
+		setAuth  bool
+		expected int
+	}{
+		{"no credentials", "", "", false, http.StatusUnauthorized},
+		{"wrong password", "admin", "nope", true, http.StatusUnauthorized},
+		{"wrong user", "root", "s3cret", true, http.StatusUnauthorized},
+		{"valid", "admin", "s3cret", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+			if tt.setAuth {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminBasicAuthLeavesHealthOpen(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAdminCredentials("admin", "s3cret"))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/whitespace"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+const (
+	// bleveAnalyzer splits the words searchTokens joined with spaces, so
+	// both indexes see the same words
+	bleveAnalyzer = "quickserve_words"
+	// bleveBatchSize is how many users a rebuild indexes per batch
+	bleveBatchSize = 1000
+)
+
+// Boosts of the ways a query word can match a word of a user: whole
+// words rank above prefixes, prefixes above misspellings, and names above
+// emails
+const (
+	bleveTermBoost   = 3
+	blevePrefixBoost = 2
+	bleveFuzzyBoost  = 1
+	bleveNameBoost   = 2
+)
+
+// BleveIndex is a full-text index of users in Bleve, for stores too large
+// for the memory store's index or searches that should forgive typos. It
+// follows the user events, and Server.Run rebuilds it from the store on
+// startup, so changes made to the store directly are only seen after a
+// restart or RebuildSearchIndex.
+type BleveIndex struct {
+	// mu orders writes, so a rebuild and the events published while it
+	// runs apply in the order they happened
+	mu     sync.Mutex
+	index  bleve.Index
+	logger *slog.Logger
+}
+
+// bleveUser is what is indexed of a user
+type bleveUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// NewBleveIndex creates an empty index in dir, removing any index left
+// there since it is rebuilt on startup anyway, or in memory if dir is
+// empty
+func NewBleveIndex(dir string) (*BleveIndex, error) {
+	m := bleve.NewIndexMapping()
+	if err := m.AddCustomAnalyzer(bleveAnalyzer, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     whitespace.Name,
+		"token_filters": []any{lowercase.Name},
+	}); err != nil {
+		return nil, err
+	}
+	doc := bleve.NewDocumentStaticMapping()
+	for _, field := range []string{"name", "email"} {
+		fm := bleve.NewTextFieldMapping()
+		fm.Analyzer = bleveAnalyzer
+		fm.Store = false
+		fm.IncludeInAll = false
+		doc.AddFieldMappingsAt(field, fm)
+	}
+	m.DefaultMapping = doc
+
+	var idx bleve.Index
+	var err error
+	if dir == "" {
+		idx, err = bleve.NewMemOnly(m)
+	} else {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("search index: %w", err)
+		}
+		idx, err = bleve.New(dir, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search index: %w", err)
+	}
+	return &BleveIndex{index: idx, logger: slog.Default()}, nil
+}
+
+// WithBleveIndex answers GET /users/search from x instead of the user
+// store, keeping it in sync through the user events
+func WithBleveIndex(x *BleveIndex) Option {
+	return func(s *Server) {
+		s.bleve = x
+	}
+}
+
+// Close closes the index
+func (x *BleveIndex) Close() error {
+	return x.index.Close()
+}
+
+// bleveDocID zero-pads id, so sorting document IDs sorts ties by ID
+func bleveDocID(id ID) string {
+	return fmt.Sprintf("%019d", int64(id))
+}
+
+func bleveDoc(u User) bleveUser {
+	return bleveUser{
+		Name:  strings.Join(searchTokens(u.Name), " "),
+		Email: strings.Join(searchTokens(u.Email), " "),
+	}
+}
+
+// handleEvent is the event bus sink applying user changes to the index.
+// Events relayed from other instances are skipped: their users are not
+// in this instance's store.
+func (x *BleveIndex) handleEvent(ctx context.Context, ev UserEvent) {
+	if ev.remote {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var err error
+	switch ev.Type {
+	case EventUserCreated, EventUserUpdated:
+		var u User
+		if err = json.Unmarshal(ev.User, &u); err == nil {
+			err = x.index.Index(bleveDocID(ev.UserID), bleveDoc(u))
+		}
+	case EventUserDeleted:
+		err = x.index.Delete(bleveDocID(ev.UserID))
+	}
+	if err != nil {
+		x.logger.ErrorContext(ctx, "could not update search index", "user_id", ev.UserID, "event", ev.Type, "err", err)
+	}
+}
+
+// rebuild indexes every user of store. Events published meanwhile wait
+// for it, so none is overwritten by an older copy of the user.
+func (x *BleveIndex) rebuild(ctx context.Context, store UserStore) (int, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	users, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(users); start += bleveBatchSize {
+		if err := ctx.Err(); err != nil {
+			return start, err
+		}
+		b := x.index.NewBatch()
+		for _, u := range users[start:min(start+bleveBatchSize, len(users))] {
+			if err := b.Index(bleveDocID(u.ID), bleveDoc(u)); err != nil {
+				return start, err
+			}
+		}
+		if err := x.index.Batch(b); err != nil {
+			return start, err
+		}
+	}
+	return len(users), nil
+}
+
+// bleveQuery matches users having, for every word of text, a word that is
+// the same, starts with it, or is within an edit or two of it
+func bleveQuery(text string) (query.Query, bool) {
+	words := searchTokens(text)
+	if len(words) == 0 {
+		return nil, false
+	}
+	all := bleve.NewConjunctionQuery()
+	for _, word := range words {
+		either := bleve.NewDisjunctionQuery()
+		for _, field := range []struct {
+			name  string
+			boost float64
+		}{{"name", bleveNameBoost}, {"email", 1}} {
+			term := bleve.NewTermQuery(word)
+			term.SetField(field.name)
+			term.SetBoost(bleveTermBoost * field.boost)
+			prefix := bleve.NewPrefixQuery(word)
+			prefix.SetField(field.name)
+			prefix.SetBoost(blevePrefixBoost * field.boost)
+			either.AddQuery(term, prefix)
+			// Short words are too easily one edit away from another
+			if fuzziness := bleveFuzziness(word); fuzziness > 0 {
+				fuzzy := bleve.NewFuzzyQuery(word)
+				fuzzy.SetField(field.name)
+				fuzzy.SetFuzziness(fuzziness)
+				fuzzy.SetBoost(bleveFuzzyBoost * field.boost)
+				either.AddQuery(fuzzy)
+			}
+		}
+		all.AddQuery(either)
+	}
+	return all, true
+}
+
+// bleveFuzziness is how many edits a query word may be away from a match
+func bleveFuzziness(word string) int {
+	switch n := len([]rune(word)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// search returns the best limit matches of text
+func (x *BleveIndex) search(ctx context.Context, text string, limit int) ([]searchMatch, error) {
+	q, ok := bleveQuery(text)
+	if !ok {
+		return nil, nil
+	}
+	req := bleve.NewSearchRequestOptions(q, limit, 0, false)
+	req.SortBy([]string{"-_score", "_id"})
+	res, err := x.index.SearchInContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]searchMatch, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		id, err := ParseID(hit.ID)
+		if err != nil {
+			continue
+		}
+		matches = append(matches, searchMatch{id, math.Round(hit.Score*1000) / 1000})
+	}
+	return matches, nil
+}
+
+// bleveSearcher searches with a BleveIndex and loads the users it finds
+// from the store
+type bleveSearcher struct {
+	index *BleveIndex
+	store UserStore
+}
+
+func (bs bleveSearcher) SearchUsers(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	matches, err := bs.index.search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(matches))
+	for _, m := range matches {
+		u, ok, err := bs.store.Get(ctx, m.id)
+		if err != nil {
+			return nil, err
+		}
+		// Skip users deleted since the search
+		if ok {
+			results = append(results, SearchResult{User: u, Score: m.score})
+		}
+	}
+	return results, nil
+}
+
+// RebuildSearchIndex indexes every user of the store in the Bleve index,
+// e.g. after writing to the store directly. Server.Run does it on
+// startup. It does nothing without WithBleveIndex.
+func (s *Server) RebuildSearchIndex(ctx context.Context) error {
+	if s.bleve == nil {
+		return nil
+	}
+	n, err := s.bleve.rebuild(ctx, s.store)
+	if err != nil {
+		return fmt.Errorf("rebuild search index: %w", err)
+	}
+	s.bleve.logger.InfoContext(ctx, "search index rebuilt", "users", n)
+	return nil
+}
@@ -0,0 +1,112 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestBleveIndex(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	index, err := NewBleveIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	s := NewServer(WithUserStore(store), WithBleveIndex(index))
+	routes := s.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	search := func(q string) []string {
+		w := do(http.MethodGet, "/users/search?q="+url.QueryEscape(q), "")
+		var page searchPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%q: expected results, got %d: %v", q, w.Code, err)
+		}
+		var names []string
+		for _, r := range page.Results {
+			names = append(names, r.User.Name)
+		}
+		return names
+	}
+
+	// Users written to the store directly are indexed by the rebuild
+	store.Insert(ctx, User{Name: "Carol Smith", Email: "carol@example.org"}, 0, 0)
+	if got := search("carol"); got != nil {
+		t.Errorf("expected nothing indexed before the rebuild, got %q", got)
+	}
+	if err := s.RebuildSearchIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("carol"); !slices.Equal(got, []string{"Carol Smith"}) {
+		t.Errorf("expected the rebuild to index the store, got %q", got)
+	}
+
+	// Users written through the API are indexed by their events
+	for _, body := range []string{
+		`{"name":"Alice Smith","email":"alice@example.com"}`,
+		`{"name":"Ali Khan","email":"ak@example.com"}`,
+		`{"name":"Bob Jones","email":"bob.alison@example.com"}`,
+	} {
+		if w := do(http.MethodPost, "/users", body); w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+		}
+	}
+	for _, tt := range []struct {
+		q    string
+		want []string
+	}{
+		// A whole word beats a prefix, and a name beats an email
+		{"ali", []string{"Ali Khan", "Alice Smith", "Bob Jones"}},
+		// Longer words forgive typos
+		{"alcie", []string{"Alice Smith"}},
+		{"smiht", []string{"Carol Smith", "Alice Smith"}},
+		{"jnoes", []string{"Bob Jones"}},
+		// but short ones don't
+		{"bbo", nil},
+		// Every word must match
+		{"smith car", []string{"Carol Smith"}},
+		{"smith bob", nil},
+	} {
+		if got := search(tt.q); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.q, tt.want, got)
+		}
+	}
+
+	updated, _ := json.Marshal(User{ID: 4, Name: "Robert Jones", Email: "bob.alison@example.com"})
+	index.handleEvent(ctx, UserEvent{Type: EventUserUpdated, UserID: 4, User: updated})
+	if got := search("robert"); !slices.Equal(got, []string{"Bob Jones"}) {
+		t.Errorf("expected the update indexed and the user loaded from the store, got %q", got)
+	}
+	index.handleEvent(ctx, UserEvent{Type: EventUserDeleted, UserID: 4, remote: true})
+	if got := search("jones"); !slices.Equal(got, []string{"Bob Jones"}) {
+		t.Errorf("expected events from other instances skipped, got %q", got)
+	}
+	do(http.MethodDelete, "/users/2", "")
+	if got := search("ali"); !slices.Equal(got, []string{"Ali Khan", "Bob Jones"}) {
+		t.Errorf("expected the deleted user gone, got %q", got)
+	}
+}
+
+func TestBleveFuzziness(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for word, want := range map[string]int{"al": 0, "bob": 0, "jone": 1, "smith": 1, "alexandr": 2, "zoëzoë": 1} {
+		if got := bleveFuzziness(word); got != want {
+			t.Errorf("%q: expected %d, got %d", word, want, got)
+		}
+	}
+}
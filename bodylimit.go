@@ -0,0 +1,90 @@
+package quickserve
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// defaultMaxBody is how large a request body may be unless configured
+const defaultMaxBody = 1 << 20
+
+// WithMaxBodySize limits request bodies to n bytes on the routes matching
+// patterns, e.g. "POST /users", or on every route if none are given.
+// Zero or less removes the limit. Per-route settings win over the
+// default and over limits a module declares with WithMaxBody.
+func WithMaxBodySize(n int64, patterns ...string) Option {
+	return func(s *Server) {
+		if len(patterns) == 0 {
+			s.maxBody = n
+			return
+		}
+		if s.routeMaxBody == nil {
+			s.routeMaxBody = make(map[string]int64)
+		}
+		for _, p := range patterns {
+			s.routeMaxBody[p] = n
+		}
+	}
+}
+
+// WithMaxBody sets the body limit a module wants for a route, e.g. for
+// uploads larger than the default allows
+func WithMaxBody(n int64) RouteOption {
+	return func(rt *Route) {
+		rt.MaxBody = n
+	}
+}
+
+// bodyLimit resolves the body limit for rt; zero or less means none
+func (s *Server) bodyLimit(rt *Route) int64 {
+	if n, ok := s.routeMaxBody[rt.Pattern()]; ok {
+		return n
+	}
+	if rt.MaxBody != 0 {
+		return rt.MaxBody
+	}
+	return s.maxBody
+}
+
+// limitBody answers 413 to requests whose body exceeds the route's limit.
+// The body is read up front so handlers never see a truncated one, and
+// http.MaxBytesReader stops reading, and closes the connection, as soon
+// as the limit is passed.
+func (s *Server) limitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, ok := RouteFromContext(r.Context())
+		if !ok || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := s.bodyLimit(rt)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, r, limit)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyTooLarge(w, r, limit)
+			return
+		}
+		if err != nil {
+			httpError(w, r, "could not read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, r *http.Request, limit int64) {
+	httpError(w, r, fmt.Sprintf("request body too large: the limit is %d bytes", limit), http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,64 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestBodyLimit(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithMaxBodySize(64), WithMaxBodySize(1024, "POST /invitations/accept"))
+	handler := server.Routes()
+
+	do := func(path string, body io.Reader) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, body))
+		return w
+	}
+	big := `{"name":"` + strings.Repeat("x", 100) + `","email":"a@example.com"}`
+
+	if w := do("/users", strings.NewReader(`{"name":"A","email":"a@example.com"}`)); w.Code != http.StatusCreated {
+		t.Errorf("expected 201 within the limit, got %d: %s", w.Code, w.Body)
+	}
+
+	w := do("/users", strings.NewReader(big))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", w.Code)
+	}
+	var body errorBody
+	json.NewDecoder(w.Body).Decode(&body)
+	if !strings.Contains(body.Error, "64 bytes") {
+		t.Errorf("expected the error to name the limit, got %q", body.Error)
+	}
+
+	// Without a Content-Length the limit is enforced while reading
+	if w := do("/users", io.MultiReader(strings.NewReader(big))); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized body of unknown length, got %d", w.Code)
+	}
+
+	// The per-route limit wins over the default
+	if w := do("/invitations/accept", strings.NewReader(`{"token":"`+strings.Repeat("x", 100)+`","name":"A"}`)); w.Code != http.StatusNotFound {
+		t.Errorf("expected the larger route limit to apply, got %d", w.Code)
+	}
+}
+
+func TestBodyLimitRouteOption(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithMaxBodySize(8))
+	rt := &Route{Method: http.MethodPost, Path: "/widgets", MaxBody: 1024}
+	if n := server.bodyLimit(rt); n != 1024 {
+		t.Errorf("expected the module's limit, got %d", n)
+	}
+	server = NewServer(WithMaxBodySize(8), WithMaxBodySize(0, "POST /widgets"))
+	if n := server.bodyLimit(rt); n != 0 {
+		t.Errorf("expected the server's per-route setting to win and disable the limit, got %d", n)
+	}
+}
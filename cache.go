@@ -0,0 +1,226 @@
+package quickserve
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// cacheHeader tells clients whether a response came from the cache
+const cacheHeader = "X-Cache"
+
+// usersCacheTag marks cached responses listing users, which every user
+// change invalidates
+const usersCacheTag = "users"
+
+// userCacheTag marks cached responses showing the user with id
+func userCacheTag(id ID) string {
+	return "user:" + id.String()
+}
+
+// WithResponseCache keeps up to size encoded responses of GET /users and
+// GET /users/{id} in memory, evicting the least recently used. Cached
+// responses are invalidated by the user events of the changes made
+// through the API; changes made to the store directly are only seen once
+// their entries are evicted.
+func WithResponseCache(size int) Option {
+	return func(s *Server) {
+		s.cache = newResponseCache(size)
+	}
+}
+
+// CacheStats reports how well the response cache is doing
+type CacheStats struct {
+	Enabled       bool   `json:"enabled"`
+	Capacity      int    `json:"capacity"`
+	Entries       int    `json:"entries"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// cacheEntry is an encoded response, when what it shows last changed,
+// and the tag invalidating it
+type cacheEntry struct {
+	key, tag string
+	data     []byte
+	modified time.Time
+}
+
+// responseCache is an LRU cache of encoded responses. Each entry has a
+// tag naming what it shows, so a change drops every variant of it, such
+// as the same user rendered with string IDs.
+type responseCache struct {
+	size int
+
+	mu      sync.Mutex
+	lru     *list.List // of *cacheEntry, most recently used first
+	entries map[string]*list.Element
+	tags    map[string]map[*list.Element]struct{}
+	// gen counts invalidations, so a response loaded before one is not
+	// cached after it
+	gen   uint64
+	stats CacheStats
+}
+
+func newResponseCache(size int) *responseCache {
+	return &responseCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[*list.Element]struct{}),
+		stats:   CacheStats{Enabled: true, Capacity: size},
+	}
+}
+
+// get returns the response cached under key
+func (c *responseCache) get(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return cacheEntry{}, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(el)
+	return *el.Value.(*cacheEntry), true
+}
+
+// generation returns the invalidation count to pass to put
+func (c *responseCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// put caches e, unless something was invalidated since gen was taken, in
+// which case it may already be stale
+func (c *responseCache) put(e cacheEntry, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	el := c.lru.PushFront(&e)
+	c.entries[e.key] = el
+	if c.tags[e.tag] == nil {
+		c.tags[e.tag] = make(map[*list.Element]struct{})
+	}
+	c.tags[e.tag][el] = struct{}{}
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops el from the cache
+func (c *responseCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.key)
+	delete(c.tags[e.tag], el)
+	if len(c.tags[e.tag]) == 0 {
+		delete(c.tags, e.tag)
+	}
+}
+
+// invalidate drops the responses tagged with any of tags
+func (c *responseCache) invalidate(tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for _, tag := range tags {
+		for el := range c.tags[tag] {
+			c.remove(el)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// purge drops every response, e.g. once the store was replaced
+func (c *responseCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.stats.Invalidations += uint64(c.lru.Len())
+	c.lru.Init()
+	clear(c.entries)
+	clear(c.tags)
+}
+
+// handleEvent is the event bus sink dropping what a user change makes
+// stale: the user and every list
+func (c *responseCache) handleEvent(_ context.Context, ev UserEvent) {
+	c.invalidate(userCacheTag(ev.UserID), usersCacheTag)
+}
+
+// snapshot returns the current stats
+func (c *responseCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// writeCachedJSON answers r with the response cached under tag, or else
+// with what load returns, caching it. load also returns when what it
+// loaded last changed, for conditional requests; it writes its own error
+// response and returns nil when there is nothing to send. Without a
+// cache it is writeJSON of load's result.
+func (s *Server) writeCachedJSON(w http.ResponseWriter, r *http.Request, tag string, load func() (any, time.Time)) {
+	if s.cache == nil {
+		v, modified := load()
+		if v != nil && !checkModified(w, r, modified) {
+			s.writeJSON(w, r, http.StatusOK, v)
+		}
+		return
+	}
+
+	opts := s.renderOptionsFor(r)
+	key := tag + "|" + codecFor(r.Context()).version() + "|" + string(opts.IDs)
+	// Tenants see different users under the same tag
+	if tenant, ok := tenantFromContext(r.Context()); ok {
+		key += "|" + tenant
+	}
+	// Each page and order is a response of its own
+	if r.URL.RawQuery != "" {
+		key += "?" + r.URL.RawQuery
+	}
+	e, ok := s.cache.get(key)
+	if ok {
+		w.Header().Set(cacheHeader, "hit")
+	} else {
+		gen := s.cache.generation()
+		v, modified := load()
+		if v == nil {
+			return
+		}
+		data, err := encodeJSON(v, opts)
+		if err != nil {
+			httpError(w, r, "could not encode response", http.StatusInternalServerError)
+			return
+		}
+		e = cacheEntry{key: key, tag: tag, data: data, modified: modified}
+		s.cache.put(e, gen)
+		w.Header().Set(cacheHeader, "miss")
+	}
+	if !checkModified(w, r, e.modified) {
+		s.writeEncoded(w, r, http.StatusOK, e.data)
+	}
+}
+
+// HandleGetCacheStats handles GET /admin/cache
+func (s *Server) HandleGetCacheStats(w http.ResponseWriter, r *http.Request) {
+	if s.cache == nil {
+		s.writeJSON(w, r, http.StatusOK, CacheStats{})
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, s.cache.snapshot())
+}
@@ -0,0 +1,141 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// countingStore counts the reads reaching the store
+type countingStore struct {
+	UserStore
+	gets, lists atomic.Int32
+}
+
+func (s *countingStore) Get(ctx context.Context, id ID) (User, bool, error) {
+	s.gets.Add(1)
+	return s.UserStore.Get(ctx, id)
+}
+
+func (s *countingStore) List(ctx context.Context) ([]User, error) {
+	s.lists.Add(1)
+	return s.UserStore.List(ctx)
+}
+
+func TestResponseCache(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := &countingStore{UserStore: NewMemoryUserStore()}
+	server := NewServer(WithUserStore(store), WithResponseCache(10))
+	routes := server.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	do(http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com"}`)
+
+	first := do(http.MethodGet, "/users/1", "")
+	second := do(http.MethodGet, "/users/1", "")
+	if first.Header().Get(cacheHeader) != "miss" || second.Header().Get(cacheHeader) != "hit" {
+		t.Errorf("expected a miss then a hit, got %q and %q", first.Header().Get(cacheHeader), second.Header().Get(cacheHeader))
+	}
+	if second.Body.String() != first.Body.String() || store.gets.Load() != 1 {
+		t.Errorf("expected the same body from one store read, got %d reads", store.gets.Load())
+	}
+
+	// Each ID format is cached on its own
+	if w := do(http.MethodGet, "/users/1?id_format=string", ""); w.Header().Get(cacheHeader) != "miss" || !strings.Contains(w.Body.String(), `"id":"1"`) {
+		t.Errorf("expected string IDs to miss, got %s: %s", w.Header().Get(cacheHeader), w.Body)
+	}
+
+	do(http.MethodGet, "/users", "")
+	do(http.MethodGet, "/users/2", "")
+
+	// Deleting Alice drops her and the list, but not Bob
+	do(http.MethodDelete, "/users/1", "")
+	if w := do(http.MethodGet, "/users/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the deleted user to be gone, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users", ""); w.Header().Get(cacheHeader) != "miss" || strings.Contains(w.Body.String(), "Alice") {
+		t.Errorf("expected the list to be reloaded without Alice, got %s: %s", w.Header().Get(cacheHeader), w.Body)
+	}
+	if w := do(http.MethodGet, "/users/2", ""); w.Header().Get(cacheHeader) != "hit" {
+		t.Errorf("expected Bob to stay cached, got %q", w.Header().Get(cacheHeader))
+	}
+
+	stats := server.cache.snapshot()
+	if stats.Hits != 2 || stats.Misses != 6 || stats.Invalidations != 3 || stats.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestResponseCacheEmbeddedChanges(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	e, err := NewEmbedded(WithResponseCache(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	list := func() string {
+		w := httptest.NewRecorder()
+		e.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+		return w.Body.String()
+	}
+
+	// Changes made without a request go through the event bus too, or
+	// the cached list would miss them
+	list()
+	e.CreateUser(ctx, User{Name: "Alice", Email: "alice@example.com"}, "")
+	if got := list(); !strings.Contains(got, "Alice") {
+		t.Errorf("expected Alice listed after CreateUser, got %s", got)
+	}
+	e.Seed(ctx, &Seed{Users: []SeedUser{{Name: "Bob", Email: "bob@example.com"}}})
+	if got := list(); !strings.Contains(got, "Bob") {
+		t.Errorf("expected Bob listed after Seed, got %s", got)
+	}
+	carol, _ := e.Users().Insert(ctx, User{Name: "Carol", Email: "carol@example.com"}, 0, 0)
+	if got := list(); !strings.Contains(got, "Carol") {
+		t.Errorf("expected Carol listed after an insert into the store, got %s", got)
+	}
+	e.Users().Delete(ctx, carol.ID)
+	if got := list(); strings.Contains(got, "Carol") {
+		t.Errorf("expected Carol gone after a delete from the store, got %s", got)
+	}
+}
+
+func TestResponseCacheEviction(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	c := newResponseCache(2)
+	c.put(cacheEntry{key: "a", tag: "a"}, 0)
+	c.put(cacheEntry{key: "b", tag: "b"}, 0)
+	c.get("a")
+	c.put(cacheEntry{key: "c", tag: "c"}, 0)
+	if _, ok := c.get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a recently used entry to stay")
+	}
+
+	// A response loaded before an invalidation is not cached after it
+	gen := c.generation()
+	c.invalidate("d")
+	c.put(cacheEntry{key: "d", tag: "d", data: []byte("stale")}, gen)
+	if _, ok := c.get("d"); ok {
+		t.Error("expected a response loaded before the invalidation to be dropped")
+	}
+	if stats := c.snapshot(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
@@ -0,0 +1,128 @@
+package quickserve
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultCacheControl is the policy of routes operators have not
+// configured
+var defaultCacheControl = map[string]string{
+	// Avatars are named by content, and revalidate with their ETag
+	"GET /users/{id}/avatar": "public, max-age=3600",
+}
+
+// WithCacheControl sends value as the Cache-Control of successful
+// responses on targets, each a route pattern such as "GET /users" or a
+// module such as "admin". A pattern wins over its module, and both over
+// the built-in policy. Handlers that set Cache-Control themselves, such
+// as event streams, keep theirs.
+func WithCacheControl(value string, targets ...string) Option {
+	return func(s *Server) {
+		if s.cacheControl == nil {
+			s.cacheControl = make(map[string]string)
+		}
+		for _, t := range targets {
+			s.cacheControl[t] = value
+		}
+	}
+}
+
+// cacheControlFor resolves the policy of rt; "" means none
+func (s *Server) cacheControlFor(rt *Route) string {
+	if v, ok := s.cacheControl[rt.Pattern()]; ok {
+		return v
+	}
+	if v, ok := s.cacheControl[rt.Module]; ok {
+		return v
+	}
+	return defaultCacheControl[unscopedPattern(rt.Pattern())]
+}
+
+// parseMaxAge returns the max-age directive of a Cache-Control value
+func parseMaxAge(value string) (time.Duration, bool, error) {
+	for _, d := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		secs, err := strconv.Atoi(arg)
+		if err != nil || secs < 0 {
+			return 0, false, fmt.Errorf("invalid max-age %q in cache control %q", arg, value)
+		}
+		return time.Duration(secs) * time.Second, true, nil
+	}
+	return 0, false, nil
+}
+
+// expiresFor returns the Expires header matching a Cache-Control value,
+// for HTTP/1.0 caches: max-age from now, or already expired if the
+// response must not be reused
+func expiresFor(value string, now time.Time) string {
+	lower := strings.ToLower(value)
+	if strings.Contains(lower, "no-store") || strings.Contains(lower, "no-cache") {
+		return "0"
+	}
+	if age, ok, err := parseMaxAge(value); ok && err == nil {
+		return now.Add(age).UTC().Format(http.TimeFormat)
+	}
+	return ""
+}
+
+// cacheControlWriter adds the route's policy to the response headers
+// once the status is known
+type cacheControlWriter struct {
+	http.ResponseWriter
+	apply func(status int)
+	done  bool
+}
+
+func (w *cacheControlWriter) WriteHeader(code int) {
+	if !w.done && code >= 200 {
+		w.done = true
+		w.apply(code)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.done {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// applyCacheControl sets the route's Cache-Control and Expires on
+// responses below 400, so errors are never cached by its policy
+func (s *Server) applyCacheControl(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, ok := RouteFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		policy := s.cacheControlFor(rt)
+		if policy == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, apply: func(status int) {
+			h := w.Header()
+			if status >= http.StatusBadRequest || h.Get("Cache-Control") != "" {
+				return
+			}
+			h.Set("Cache-Control", policy)
+			if expires := expiresFor(policy, s.clock.Now()); expires != "" {
+				h.Set("Expires", expires)
+			}
+		}}, r)
+	})
+}
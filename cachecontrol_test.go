@@ -0,0 +1,56 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestCacheControlPolicy(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock),
+		WithAdminCredentials("admin", "secret"),
+		WithCacheControl("public, max-age=30", "GET /users"),
+		WithCacheControl("no-store", "admin"),
+		WithCacheControl("private, max-age=60", "GET /admin/audit"))
+	routes := server.Routes()
+	routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`)))
+	get := func(path string) http.Header {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	h := get("/users")
+	if h.Get("Cache-Control") != "public, max-age=30" || h.Get("Expires") != "Mon, 01 Jan 2024 00:00:30 GMT" {
+		t.Errorf("expected the route's policy with a matching Expires, got %v", h)
+	}
+	if h := get("/admin/keys"); h.Get("Cache-Control") != "no-store" || h.Get("Expires") != "0" {
+		t.Errorf("expected the module's policy, got %v", h)
+	}
+	if h := get("/admin/audit"); h.Get("Cache-Control") != "private, max-age=60" {
+		t.Errorf("expected a route to win over its module, got %v", h)
+	}
+	if h := get("/users/1/avatar"); h.Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("expected the built-in avatar policy, got %v", h)
+	}
+	if h := get("/orgs"); h.Get("Cache-Control") != "" {
+		t.Errorf("expected no policy on unconfigured routes, got %v", h)
+	}
+
+	// Errors are never cached by the policy
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":`))
+	w := httptest.NewRecorder()
+	NewServer(WithCacheControl("public, max-age=30", "users")).Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("expected a 400 without Cache-Control, got %d: %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,144 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// capacityThresholds are the percentages of a limit at which a warning is
+// logged and a user.capacity event published, so operators hear about a
+// full collection before users do
+var capacityThresholds = []int{80, 90, 100}
+
+// QuotaError reports that a collection is at its limit
+type QuotaError struct {
+	Resource string `json:"resource"`
+	// Scope is "server" for the total cap or "tenant" for a tenant's own
+	Scope  string `json:"scope"`
+	Tenant string `json:"tenant,omitempty"`
+	Limit  int    `json:"limit"`
+	Used   int    `json:"used"`
+}
+
+func (e *QuotaError) Error() string {
+	if e.Scope == "tenant" {
+		return fmt.Sprintf("tenant %s has reached its limit of %d %s", e.Tenant, e.Limit, e.Resource)
+	}
+	return fmt.Sprintf("server has reached its limit of %d %s", e.Limit, e.Resource)
+}
+
+// WithMaxUsers caps the number of users on the server and, by default,
+// in each tenant. Zero means no limit. Tenants can be given their own cap
+// with the max_users setting.
+func WithMaxUsers(total, perTenant int) Option {
+	return func(s *Server) {
+		s.maxUsers = total
+		s.tenantMaxUsers = perTenant
+	}
+}
+
+// writeCreateError answers a failed createUser: 409 when the email is
+// taken, 507 when the server is full and 403 when only the tenant is,
+// describing which limit was hit
+func writeCreateError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrEmailTaken) {
+		httpError(w, r, "a user with this email already exists", http.StatusConflict)
+		return
+	}
+	var e *QuotaError
+	if !errors.As(err, &e) {
+		writeStoreError(w, r, err)
+		return
+	}
+	status := http.StatusInsufficientStorage
+	if e.Scope == "tenant" {
+		status = http.StatusForbidden
+	}
+	writeError(w, r, status, errorBody{Error: e.Resource + " limit reached", Quota: e})
+}
+
+// createUser inserts u within the server's and its tenant's user limits
+func (s *Server) createUser(ctx context.Context, u User) (User, error) {
+	var tenantLimit int
+	if u.Tenant != "" {
+		tenantLimit = s.settingsFor(u.Tenant).MaxUsers
+	}
+	user, err := s.store.Insert(ctx, u, s.maxUsers, tenantLimit)
+	if err != nil {
+		return User{}, err
+	}
+	s.checkUserCapacity(ctx, user.Tenant)
+	return user, nil
+}
+
+// checkUserCapacity warns when user counts cross a threshold. It runs
+// after deletions too, so a collection that shrinks and fills up again
+// warns again.
+func (s *Server) checkUserCapacity(ctx context.Context, tenant string) {
+	total, inTenant, err := s.store.Count(ctx, tenant)
+	if err != nil {
+		return
+	}
+	s.observeCapacity(ctx, CapacityAlert{Scope: "server", Used: total, Limit: s.maxUsers})
+	if tenant != "" {
+		s.observeCapacity(ctx, CapacityAlert{Scope: "tenant", Tenant: tenant, Used: inTenant, Limit: s.settingsFor(tenant).MaxUsers})
+	}
+}
+
+// CapacityAlert is the body of a user.capacity event: a user count
+// reaching Threshold percent of its limit
+type CapacityAlert struct {
+	// Scope is "server" for the total cap or "tenant" for a tenant's own
+	Scope     string `json:"scope"`
+	Tenant    string `json:"tenant,omitempty"`
+	Threshold int    `json:"threshold"`
+	Used      int    `json:"used"`
+	Limit     int    `json:"limit"`
+}
+
+// observeCapacity logs a warning and publishes a user.capacity event the
+// first time a count reaches each threshold of its limit. The event is
+// audited, so it is numbered like user events and streams replay it.
+func (s *Server) observeCapacity(ctx context.Context, a CapacityAlert) {
+	level, reached := s.capacity.observe(a.Scope, a.Tenant, a.Used, a.Limit)
+	if !reached {
+		return
+	}
+	a.Threshold = level
+	s.capacity.logger.WarnContext(ctx, "user capacity threshold reached",
+		"scope", a.Scope, "tenant", a.Tenant, "threshold", a.Threshold, "used", a.Used, "limit", a.Limit)
+	s.recordChange(ctx, AuditAlert, "capacity", a.Tenant, nil, a)
+}
+
+// capacityAlerts remembers the highest threshold each scope has reached
+type capacityAlerts struct {
+	mu     sync.Mutex
+	levels map[string]int
+	logger *slog.Logger
+}
+
+func newCapacityAlerts(logger *slog.Logger) *capacityAlerts {
+	return &capacityAlerts{levels: make(map[string]int), logger: logger}
+}
+
+// observe returns the highest threshold of limit used reaches, and
+// whether it is higher than the scope had reached before
+func (c *capacityAlerts) observe(scope, tenant string, used, limit int) (int, bool) {
+	level := 0
+	for _, t := range capacityThresholds {
+		if limit > 0 && used*100 >= t*limit {
+			level = t
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := scope + ":" + tenant
+	prev := c.levels[key]
+	c.levels[key] = level
+	return level, level > prev
+}
@@ -0,0 +1,125 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUserLimits(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var logs bytes.Buffer
+	logger, _ := NewLogger(&logs, "text", slog.LevelWarn)
+	server := NewServer(WithLogger(logger), WithMaxUsers(10, 0))
+	two := 2
+	server.tenantSettings.Set("acme", TenantOverrides{MaxUsers: &two})
+	handler := server.Routes()
+
+	create := func(tenant string, n int) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"name":"User %d","email":"user%d@test.com"}`, n, n)
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	quotaOf := func(w *httptest.ResponseRecorder) QuotaError {
+		var body errorBody
+		json.NewDecoder(w.Body).Decode(&body)
+		if body.Quota == nil {
+			t.Fatalf("expected a quota in the error, got %+v", body)
+		}
+		return *body.Quota
+	}
+
+	for i := 1; i <= 2; i++ {
+		if w := create("acme", i); w.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", w.Code)
+		}
+	}
+	w := create("acme", 3)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 over the tenant's cap, got %d", w.Code)
+	}
+	if q := quotaOf(w); q.Scope != "tenant" || q.Tenant != "acme" || q.Limit != 2 || q.Used != 2 {
+		t.Errorf("unexpected quota: %+v", q)
+	}
+
+	for i := 3; i <= 10; i++ {
+		if w := create("", i); w.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for user %d, got %d", i, w.Code)
+		}
+	}
+	w = create("", 11)
+	if w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507 over the server's cap, got %d", w.Code)
+	}
+	if q := quotaOf(w); q.Scope != "server" || q.Limit != 10 || q.Used != 10 {
+		t.Errorf("unexpected quota: %+v", q)
+	}
+
+	out := logs.String()
+	for _, want := range []string{
+		"scope=server tenant=\"\" threshold=80 used=8 limit=10",
+		"scope=server tenant=\"\" threshold=90 used=9 limit=10",
+		"scope=server tenant=\"\" threshold=100 used=10 limit=10",
+		"scope=tenant tenant=acme threshold=100 used=2 limit=2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected a warning with %q, got:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "threshold="); n != 4 {
+		t.Errorf("expected each threshold to warn once, got %d warnings", n)
+	}
+
+	// Dropping below a threshold re-arms its warning
+	req := httptest.NewRequest(http.MethodDelete, "/users/10", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if w := create("", 12); w.Code != http.StatusCreated {
+		t.Fatalf("expected room after a deletion, got %d", w.Code)
+	}
+	if n := strings.Count(logs.String(), "threshold=100 used=10"); n != 2 {
+		t.Errorf("expected the 100%% warning again after refilling, got %d", n)
+	}
+}
+
+func TestCapacityEvents(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	var got []UserEvent
+	server := NewServer(WithMaxUsers(10, 0), WithEventSink(func(_ context.Context, ev UserEvent) {
+		if ev.Type == EventUserCapacity {
+			got = append(got, ev)
+		}
+	}))
+	for i := 1; i <= 8; i++ {
+		if _, err := server.createUser(ctx, User{Name: "User", Email: fmt.Sprintf("user%d@test.com", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected one event for crossing 80%%, got %+v", got)
+	}
+	var alert CapacityAlert
+	json.Unmarshal(got[0].Capacity, &alert)
+	if alert != (CapacityAlert{Scope: "server", Threshold: 80, Used: 8, Limit: 10}) {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+	if e := server.audit.Query(AuditFilter{AfterID: got[0].ID - 1}); len(e) == 0 || e[0].Action != AuditAlert {
+		t.Errorf("expected the event numbered by its audit entry, got %+v", e)
+	}
+}
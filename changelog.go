@@ -0,0 +1,380 @@
+package quickserve
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIVersion is the version of the API this server implements. It must
+// match the newest release in changelog.
+const APIVersion = "1.40.1"
+
+// APIVersionHeader reports APIVersion on every response
+const APIVersionHeader = "X-API-Version"
+
+// ChangeKind classifies a change for compatibility checks
+type ChangeKind string
+
+const (
+	// ChangeAdded is a new route; existing clients are unaffected
+	ChangeAdded ChangeKind = "added"
+	// ChangeChanged is a backward-compatible change in behavior
+	ChangeChanged ChangeKind = "changed"
+	// ChangeDeprecated marks a route that will be removed
+	ChangeDeprecated ChangeKind = "deprecated"
+	// ChangeRemoved is a route that is no longer served
+	ChangeRemoved ChangeKind = "removed"
+)
+
+// Change is one entry in a release. Route is a pattern such as
+// "GET /users/{id}", optionally followed by "?param" for a change to one
+// query parameter; it is empty for changes that apply across the API.
+type Change struct {
+	Kind        ChangeKind `json:"kind"`
+	Route       string     `json:"route,omitempty"`
+	Description string     `json:"description"`
+}
+
+// Release lists the changes made in one API version
+type Release struct {
+	Version string   `json:"version"`
+	Changes []Change `json:"changes"`
+}
+
+// changelog records every API change, newest release first. Add an entry
+// whenever a route is added, changed, deprecated or removed.
+var changelog = []Release{
+	{Version: "1.40.1", Changes: []Change{
+		{ChangeChanged, "", "User counts reaching 80%, 90% and 100% of a limit are audited and published as user.capacity events to WebSockets, Server-Sent Events and webhooks"},
+		{ChangeChanged, "PUT /admin/tenants/{tenant}/settings", "A tenant's rate limit applies only to callers authenticated in the tenant, not to requests merely naming it"},
+		{ChangeChanged, "", "Groups with CSRF protection, and WebSocket origin checks, exempt only API keys and bearer tokens; Basic-authenticated requests need a CSRF token"},
+		{ChangeChanged, "", "OpenID Connect callers get the role of the user with their email only when the provider verified it (email_verified), and user otherwise"},
+		{ChangeChanged, "", "The /v1, /v2 and /tenants/{tenant} copies of a deprecated route answer with Deprecation and Sunset and count towards its usage"},
+		{ChangeChanged, "", "Signed requests sign the method and path along with the timestamp and body, so a signature can't be replayed against another route"},
+		{ChangeChanged, "", "Webhook payloads, event streams and Kafka and NATS messages follow the server's ID format and timestamp precision"},
+		{ChangeChanged, "POST /users", "Refuses an email already taken with 409, as signups and invitations do; emails are unique per tenant with tenancy and across the server without it, even for concurrent requests"},
+		{ChangeChanged, "PUT /admin/tenants/{tenant}/settings", "Enforces retention: audit entries of the tenant's resources, and the events and feeds read from them, are pruned once older"},
+		{ChangeChanged, "GET /admin/audit", "Users created by seeding or through an embedded instance's stores are audited, without method and path, and published as user events"},
+		{ChangeChanged, "", "Deleting a user through an embedded instance's user store removes their org memberships, notification preferences, posts and sessions"},
+	}},
+	{Version: "1.40.0", Changes: []Change{
+		{ChangeChanged, "GET /users", "Pages of users with ?limit= and ?cursor=, set to the previous page's next_cursor, under every prefix; ?sort= orders by id, name or created_at"},
+	}},
+	{Version: "1.39.0", Changes: []Change{
+		{ChangeChanged, "", "Routes modules deprecate in the route registry answer with Deprecation, and with Sunset once their removal date is set"},
+		{ChangeChanged, "GET /admin/deprecations", "Reports calls to routes deprecated in the route registry, with their sunset date"},
+		{ChangeChanged, "GET /admin/routes", "Routes deprecated in the route registry carry their deprecation"},
+	}},
+	{Version: "1.38.0", Changes: []Change{
+		{ChangeAdded, "GET /v1/users", "List users"},
+		{ChangeAdded, "POST /v1/users", "Create a user"},
+		{ChangeAdded, "GET /v1/users/{id}", "Get a user"},
+		{ChangeAdded, "DELETE /v1/users/{id}", "Delete a user"},
+		{ChangeAdded, "GET /v1/users/search", "Full-text search of user names and emails"},
+		{ChangeAdded, "GET /v1/users/changes", "Ordered feed of user changes"},
+		{ChangeAdded, "GET /v1/users/{id}/avatar", "Generated avatar"},
+		{ChangeAdded, "GET /v1/users/{id}/activity", "Per-user activity feed"},
+		{ChangeAdded, "GET /v1/users/{id}/notifications", "Read notification preferences"},
+		{ChangeAdded, "PUT /v1/users/{id}/notifications", "Update notification preferences"},
+		{ChangeAdded, "GET /v1/users/{id}/posts", "Posts written by a user"},
+		{ChangeAdded, "POST /v1/users/{id}/posts", "Write a post as the user"},
+		{ChangeAdded, "GET /v1/users/{id}/posts/{post}", "Get a post of the user"},
+		{ChangeAdded, "PUT /v1/users/{id}/posts/{post}", "Replace the title and body of a post"},
+		{ChangeAdded, "DELETE /v1/users/{id}/posts/{post}", "Delete a post"},
+		{ChangeAdded, "GET /v2/users", "List users as v2 represents them"},
+		{ChangeAdded, "POST /v2/users", "Create a user from display_name, email and the optional fields"},
+		{ChangeAdded, "GET /v2/users/{id}", "Get a user as v2 represents them"},
+		{ChangeAdded, "DELETE /v2/users/{id}", "Delete a user"},
+		{ChangeAdded, "GET /v2/users/search", "Full-text search of user names and emails, with v2 users"},
+		{ChangeAdded, "GET /v2/users/changes", "Ordered feed of user changes"},
+		{ChangeAdded, "GET /v2/users/{id}/avatar", "Generated avatar"},
+		{ChangeAdded, "GET /v2/users/{id}/activity", "Per-user activity feed"},
+		{ChangeAdded, "GET /v2/users/{id}/notifications", "Read notification preferences"},
+		{ChangeAdded, "PUT /v2/users/{id}/notifications", "Update notification preferences"},
+		{ChangeAdded, "GET /v2/users/{id}/posts", "Posts written by a user"},
+		{ChangeAdded, "POST /v2/users/{id}/posts", "Write a post as the user"},
+		{ChangeAdded, "GET /v2/users/{id}/posts/{post}", "Get a post of the user"},
+		{ChangeAdded, "PUT /v2/users/{id}/posts/{post}", "Replace the title and body of a post"},
+		{ChangeAdded, "DELETE /v2/users/{id}/posts/{post}", "Delete a post"},
+		{ChangeChanged, "", "The user routes are also served under /v1, unchanged, and /v2, where user IDs are strings and name is display_name"},
+	}},
+	{Version: "1.37.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/tenants", "Tenants created through the admin API"},
+		{ChangeAdded, "POST /admin/tenants", "Create a tenant"},
+		{ChangeAdded, "GET /admin/tenants/{tenant}", "Get a tenant"},
+		{ChangeAdded, "POST /admin/tenants/{tenant}/suspend", "Suspend a tenant; its requests get 403 and its data is kept"},
+		{ChangeAdded, "POST /admin/tenants/{tenant}/resume", "Resume a suspended tenant"},
+		{ChangeAdded, "DELETE /admin/tenants/{tenant}", "Delete a tenant, purging its users with their data and its settings, webhooks and invitations"},
+		{ChangeAdded, "GET /tenants/{tenant}/users", "Users of the tenant"},
+		{ChangeAdded, "POST /tenants/{tenant}/users", "Create a user in the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}", "Get a user of the tenant"},
+		{ChangeAdded, "DELETE /tenants/{tenant}/users/{id}", "Delete a user of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/search", "Search the users of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/changes", "Changes to the users of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}/avatar", "Avatar of a user of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}/activity", "Activity of a user of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}/notifications", "Notification preferences of a user of the tenant"},
+		{ChangeAdded, "PUT /tenants/{tenant}/users/{id}/notifications", "Update the notification preferences of a user of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}/posts", "Posts written by a user of the tenant"},
+		{ChangeAdded, "POST /tenants/{tenant}/users/{id}/posts", "Write a post as a user of the tenant"},
+		{ChangeAdded, "GET /tenants/{tenant}/users/{id}/posts/{post}", "Get a post of a user of the tenant"},
+		{ChangeAdded, "PUT /tenants/{tenant}/users/{id}/posts/{post}", "Replace a post of a user of the tenant"},
+		{ChangeAdded, "DELETE /tenants/{tenant}/users/{id}/posts/{post}", "Delete a post of a user of the tenant"},
+		{ChangeChanged, "", "Requests to a suspended tenant get 403"},
+	}},
+	{Version: "1.36.0", Changes: []Change{
+		{ChangeChanged, "", "With tenancy configured, requests only see the users of the tenant named by X-Tenant-ID, the subdomain or the caller's credentials, and callers bound to a tenant get 403 in any other"},
+	}},
+	{Version: "1.35.0", Changes: []Change{
+		{ChangeAdded, "GET /users/{id}/posts", "Posts written by a user"},
+		{ChangeAdded, "POST /users/{id}/posts", "Write a post as the user"},
+		{ChangeAdded, "GET /users/{id}/posts/{post}", "Get a post of the user"},
+		{ChangeAdded, "PUT /users/{id}/posts/{post}", "Replace the title and body of a post"},
+		{ChangeAdded, "DELETE /users/{id}/posts/{post}", "Delete a post"},
+		{ChangeChanged, "DELETE /users/{id}", "Deletes the user's posts too, or answers 409 while they have posts when configured to block"},
+	}},
+	{Version: "1.34.0", Changes: []Change{
+		{ChangeAdded, "GET /teams", "Teams of every org"},
+		{ChangeAdded, "POST /teams", "Create a team in the org named in the body"},
+		{ChangeAdded, "GET /teams/{team}", "Get a team by ID"},
+		{ChangeAdded, "DELETE /teams/{team}", "Delete a team with its sub-teams and memberships"},
+		{ChangeAdded, "GET /teams/{team}/members", "Members of a team, including inherited ones"},
+		{ChangeAdded, "POST /teams/{team}/members", "Add a user to a team or change their role there"},
+	}},
+	{Version: "1.33.0", Changes: []Change{
+		{ChangeAdded, "GET /users/search", "Full-text search of user names and emails, ranked by relevance"},
+	}},
+	{Version: "1.32.0", Changes: []Change{
+		{ChangeChanged, "GET /users", "Responses carry Last-Modified, and If-Modified-Since answers 304 while no user has changed"},
+		{ChangeChanged, "GET /users/{id}", "Responses carry Last-Modified, and If-Modified-Since answers 304 while the user is unchanged"},
+	}},
+	{Version: "1.31.0", Changes: []Change{
+		{ChangeChanged, "", "Successful responses carry the Cache-Control and Expires configured for their route or module"},
+	}},
+	{Version: "1.30.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/cache", "Hit, miss and eviction counts of the response cache"},
+		{ChangeChanged, "GET /users", "With the response cache enabled, responses may be cached and say so in X-Cache"},
+		{ChangeChanged, "GET /users/{id}", "With the response cache enabled, responses may be cached and say so in X-Cache"},
+	}},
+	{Version: "1.29.0", Changes: []Change{
+		{ChangeChanged, "", "With load shedding configured, requests answer 503 with Retry-After while the server is saturated, lowest route priority first"},
+		{ChangeChanged, "GET /admin/routes", "Routes report their priority"},
+	}},
+	{Version: "1.28.0", Changes: []Change{
+		{ChangeChanged, "", "With a concurrency limit configured, requests over it queue briefly and then answer 503 with Retry-After"},
+	}},
+	{Version: "1.27.0", Changes: []Change{
+		{ChangeChanged, "", "Rate-limited responses carry the draft RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and every 429 sets Retry-After"},
+	}},
+	{Version: "1.26.0", Changes: []Change{
+		{ChangeAdded, "GET /users/changes", "Ordered feed of user changes with sequence numbers, read incrementally with ?since="},
+	}},
+	{Version: "1.25.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/webhooks", "List webhooks registered for user events"},
+		{ChangeAdded, "POST /admin/webhooks", "Register a webhook receiving signed user created, updated and deleted events"},
+		{ChangeAdded, "DELETE /admin/webhooks/{id}", "Remove a webhook"},
+	}},
+	{Version: "1.24.0", Changes: []Change{
+		{ChangeAdded, "GET /users/events", "Server-Sent Events stream of user changes, resumable with Last-Event-ID"},
+	}},
+	{Version: "1.23.0", Changes: []Change{
+		{ChangeAdded, "GET /ws", "WebSocket pushing user created, updated and deleted events"},
+	}},
+	{Version: "1.22.0", Changes: []Change{
+		{ChangeAdded, "POST /rpc", "JSON-RPC 2.0 endpoint offering user.list, user.get, user.create and user.delete, with batches"},
+	}},
+	{Version: "1.21.0", Changes: []Change{
+		{ChangeChanged, "", "Request bodies and documented query parameters are validated against the OpenAPI document; mismatches answer 400 listing each problem in invalid"},
+	}},
+	{Version: "1.20.0", Changes: []Change{
+		{ChangeAdded, "GET /docs/", "Interactive API explorer"},
+	}},
+	{Version: "1.19.0", Changes: []Change{
+		{ChangeAdded, "GET /openapi.json", "OpenAPI 3 description of the served routes"},
+	}},
+	{Version: "1.18.0", Changes: []Change{
+		{ChangeAdded, "POST /admin/reload", "Reload the log level, rate limit and IP access lists without a restart"},
+	}},
+	{Version: "1.17.0", Changes: []Change{
+		{ChangeAdded, "GET /healthz", "Liveness check"},
+		{ChangeAdded, "GET /readyz", "Readiness check with the result of each dependency check"},
+		{ChangeDeprecated, "GET /health", "use GET /healthz for liveness or GET /readyz for readiness"},
+	}},
+	{Version: "1.16.0", Changes: []Change{
+		{ChangeChanged, "GET /users", "Users are listed in ID order"},
+		{ChangeChanged, "GET /admin/keys", "Keys are listed in ID order"},
+	}},
+	{Version: "1.15.0", Changes: []Change{
+		{ChangeChanged, "", "With tracing enabled, a W3C traceparent continues the caller's trace and is forwarded on outbound calls"},
+	}},
+	{Version: "1.14.0", Changes: []Change{
+		{ChangeChanged, "", "Request bodies over 1 MiB, or the limit configured for the route, answer 413"},
+		{ChangeChanged, "GET /admin/routes", "Routes report the body limit their module asked for as max_body"},
+	}},
+	{Version: "1.13.0", Changes: []Change{
+		{ChangeAdded, "POST /replication/snapshot", "Apply a signed snapshot from the primary on a standby"},
+		{ChangeAdded, "GET /admin/replication", "Snapshot shipping status"},
+		{ChangeAdded, "POST /admin/promote", "Promote a standby to primary"},
+		{ChangeChanged, "", "A standby answers 503 to writes until it is promoted"},
+	}},
+	{Version: "1.12.0", Changes: []Change{
+		{ChangeChanged, "", "Requests without X-Request-Deadline answer 504 once the configured handler timeout passes"},
+	}},
+	{Version: "1.11.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/schema", "Schema drift report for persisted stores"},
+		{ChangeChanged, "", "Writes answer 503 with a drift report while persisted stores do not match the expected schema"},
+	}},
+	{Version: "1.10.0", Changes: []Change{
+		{ChangeChanged, "", "Unexpected server errors answer 500 with an application/problem+json body"},
+	}},
+	{Version: "1.9.0", Changes: []Change{
+		{ChangeChanged, "POST /users", "Answers 507 or 403 with a quota error when the server or tenant user limit is reached"},
+		{ChangeChanged, "POST /signup", "Answers 507 or 403 with a quota error when the server or tenant user limit is reached"},
+		{ChangeChanged, "POST /invitations/accept", "Answers 507 or 403 with a quota error when the server or tenant user limit is reached"},
+		{ChangeChanged, "PUT /admin/tenants/{tenant}/settings", "Accepts max_users"},
+		{ChangeChanged, "", "Users and invitations record the tenant from X-Tenant-ID"},
+	}},
+	{Version: "1.8.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/deprecations", "Report of API keys still calling deprecated routes"},
+		{ChangeChanged, "POST /admin/keys", "Accepts a notify_url told when the key first calls a deprecated route"},
+		{ChangeChanged, "", "Deprecated routes answer with Deprecation and Link headers"},
+	}},
+	{Version: "1.7.0", Changes: []Change{
+		{ChangeChanged, "", "Error responses are JSON objects with error and request_id instead of plain text"},
+	}},
+	{Version: "1.6.0", Changes: []Change{
+		{ChangeAdded, "GET /changelog", "Machine-readable API changelog"},
+		{ChangeChanged, "", "Every response carries the API version in X-API-Version"},
+	}},
+	{Version: "1.5.0", Changes: []Change{
+		{ChangeAdded, "POST /login", "Password login issuing a session token and cookie"},
+		{ChangeAdded, "POST /logout", "End the current session"},
+		{ChangeAdded, "GET /users/{id}/activity", "Per-user activity feed"},
+		{ChangeAdded, "GET /users/{id}/notifications", "Read notification preferences"},
+		{ChangeAdded, "PUT /users/{id}/notifications", "Update notification preferences"},
+		{ChangeChanged, "POST /users", "Accepts optional password and locale fields"},
+		{ChangeChanged, "POST /invitations", "Accepts an optional locale for the invitation email"},
+		{ChangeChanged, "", "GET responses are rendered as plain text tables when Accept prefers text/plain"},
+	}},
+	{Version: "1.4.0", Changes: []Change{
+		{ChangeAdded, "POST /invitations", "Invite a user by email"},
+		{ChangeAdded, "POST /invitations/accept", "Accept an invitation and create the account"},
+		{ChangeAdded, "GET /admin/invitations", "List pending invitations"},
+		{ChangeAdded, "DELETE /admin/invitations/{id}", "Revoke an invitation"},
+		{ChangeAdded, "POST /signup", "Self-service signup"},
+		{ChangeAdded, "GET /admin/audit", "Query the audit log"},
+		{ChangeAdded, "GET /users/{id}/avatar", "Generated identicon or initials avatar"},
+	}},
+	{Version: "1.3.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/tenants/{tenant}/settings", "Read a tenant's settings"},
+		{ChangeAdded, "PUT /admin/tenants/{tenant}/settings", "Override a tenant's settings"},
+		{ChangeAdded, "DELETE /admin/tenants/{tenant}/settings", "Clear a tenant's overrides"},
+		{ChangeAdded, "GET /orgs", "List organizations"},
+		{ChangeAdded, "POST /orgs", "Create an organization"},
+		{ChangeAdded, "GET /orgs/{org}", "Get an organization"},
+		{ChangeAdded, "DELETE /orgs/{org}", "Delete an organization"},
+		{ChangeAdded, "GET /orgs/{org}/users", "List an organization's users with effective roles"},
+		{ChangeAdded, "GET /orgs/{org}/teams", "List teams"},
+		{ChangeAdded, "POST /orgs/{org}/teams", "Create a team"},
+		{ChangeAdded, "DELETE /orgs/{org}/teams/{team}", "Delete a team"},
+		{ChangeAdded, "GET /orgs/{org}/members", "List organization members"},
+		{ChangeAdded, "PUT /orgs/{org}/members/{user}", "Add or update an organization member"},
+		{ChangeAdded, "DELETE /orgs/{org}/members/{user}", "Remove an organization member"},
+		{ChangeAdded, "GET /orgs/{org}/teams/{team}/members", "List team members"},
+		{ChangeAdded, "PUT /orgs/{org}/teams/{team}/members/{user}", "Add or update a team member"},
+		{ChangeAdded, "DELETE /orgs/{org}/teams/{team}/members/{user}", "Remove a team member"},
+	}},
+	{Version: "1.2.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/clock", "Read the server clock"},
+		{ChangeAdded, "POST /admin/clock", "Move a simulated clock"},
+		{ChangeAdded, "GET /admin/routes", "List routes with their owning module and idempotency"},
+		{ChangeAdded, "GET /admin/usage", "API key quota usage"},
+		{ChangeAdded, "GET /health/weight", "Load-based balancer weight"},
+		{ChangeChanged, "", "X-Request-Deadline bounds request processing; overruns answer 504"},
+		{ChangeChanged, "", "Requests are rate limited per client and answer 429 with Retry-After"},
+	}},
+	{Version: "1.1.0", Changes: []Change{
+		{ChangeAdded, "GET /admin/keys", "List API keys"},
+		{ChangeAdded, "POST /admin/keys", "Create an API key"},
+		{ChangeAdded, "DELETE /admin/keys/{id}", "Revoke an API key"},
+		{ChangeAdded, "GET /auth/login", "Start an OpenID Connect login"},
+		{ChangeAdded, "GET /auth/callback", "Complete an OpenID Connect login"},
+		{ChangeChanged, "", "IDs can be rendered as strings with ?id_format=string"},
+		{ChangeChanged, "", "Timestamps are RFC 3339 in UTC"},
+	}},
+	{Version: "1.0.0", Changes: []Change{
+		{ChangeAdded, "GET /users", "List users"},
+		{ChangeAdded, "POST /users", "Create a user"},
+		{ChangeAdded, "GET /users/{id}", "Get a user"},
+		{ChangeAdded, "DELETE /users/{id}", "Delete a user"},
+		{ChangeAdded, "GET /health", "Liveness check"},
+	}},
+}
+
+// parseVersion parses a major.minor.patch version
+func parseVersion(v string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(parts) != 3 {
+		return parsed, fmt.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid version %q", v)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, equal to or
+// newer than b
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// withAPIVersion sets X-API-Version on every response
+func withAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(APIVersionHeader, APIVersion)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// changelogBody is the body of GET /changelog
+type changelogBody struct {
+	Version  string    `json:"version"`
+	Releases []Release `json:"releases"`
+}
+
+// HandleChangelog handles GET /changelog. ?since=1.2.0 limits the
+// releases to those newer than the version a client was built against.
+func (s *Server) HandleChangelog(w http.ResponseWriter, r *http.Request) {
+	releases := changelog
+	if since := r.URL.Query().Get("since"); since != "" {
+		v, err := parseVersion(since)
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		releases = []Release{}
+		for _, rel := range changelog {
+			if rv, _ := parseVersion(rel.Version); compareVersions(rv, v) > 0 {
+				releases = append(releases, rel)
+			}
+		}
+	}
+
+	s.writeJSON(w, r, http.StatusOK, changelogBody{Version: APIVersion, Releases: releases})
+}
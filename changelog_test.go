@@ -0,0 +1,134 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// TestChangelogCoversRoutes keeps the changelog in step with the router:
+// every built-in route must have been added in some release and not
+// removed since, and every route the changelog says is live must exist
+func TestChangelogCoversRoutes(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(
+		WithAPIKeyAuth("admin-secret"),
+		WithSignup(SignupConfig{}),
+		WithOIDC(&OIDCProvider{}),
+		WithStandby("secret"),
+		WithReloadSource(func() (LiveSettings, error) { return LiveSettings{}, nil }),
+		WithClock(NewSimulatedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))),
+		WithDocs(),
+	)
+	rr := NewRouteRegistry()
+	server.registerRoutes(rr)
+
+	served := make(map[string]bool)
+	for _, rt := range rr.Routes() {
+		served[rt.Pattern()] = true
+	}
+
+	live := make(map[string]bool)
+	for i := len(changelog) - 1; i >= 0; i-- {
+		for _, c := range changelog[i].Changes {
+			route, field, _ := strings.Cut(c.Route, "?")
+			switch c.Kind {
+			case ChangeAdded:
+				live[route] = true
+			case ChangeRemoved:
+				if field == "" {
+					delete(live, route)
+				}
+			case ChangeChanged, ChangeDeprecated:
+				if route != "" && !live[route] {
+					t.Errorf("%s: %s %s before it was added", changelog[i].Version, c.Kind, c.Route)
+				}
+			default:
+				t.Errorf("%s: unknown change kind %q", changelog[i].Version, c.Kind)
+			}
+		}
+	}
+
+	for pattern := range served {
+		if !live[pattern] {
+			t.Errorf("%s is served but missing from the changelog", pattern)
+		}
+	}
+	for pattern := range live {
+		if !served[pattern] {
+			t.Errorf("%s is in the changelog but not served", pattern)
+		}
+	}
+}
+
+func TestChangelogVersions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	if changelog[0].Version != APIVersion {
+		t.Errorf("newest release is %s, expected APIVersion %s", changelog[0].Version, APIVersion)
+	}
+	for i := 1; i < len(changelog); i++ {
+		newer, err := parseVersion(changelog[i-1].Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		older, err := parseVersion(changelog[i].Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compareVersions(newer, older) <= 0 {
+			t.Errorf("releases out of order: %s listed before %s", changelog[i-1].Version, changelog[i].Version)
+		}
+	}
+}
+
+func TestHandleChangelog(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	handler := NewServer().Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/changelog?since=1.4.0", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get(APIVersionHeader); got != APIVersion {
+		t.Errorf("expected %s %s, got %q", APIVersionHeader, APIVersion, got)
+	}
+
+	var body struct {
+		Version  string
+		Releases []Release
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if body.Version != APIVersion {
+		t.Errorf("expected version %s, got %s", APIVersion, body.Version)
+	}
+	for _, rel := range body.Releases {
+		if rel.Version == "1.4.0" || rel.Version == "1.0.0" {
+			t.Errorf("release %s is not newer than 1.4.0", rel.Version)
+		}
+	}
+	newer := 0
+	for changelog[newer].Version != "1.4.0" {
+		newer++
+	}
+	if len(body.Releases) != newer {
+		t.Errorf("expected the %d releases after 1.4.0, got %d", newer, len(body.Releases))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/changelog?since=latest", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid version, got %d", w.Code)
+	}
+}
@@ -0,0 +1,60 @@
+package quickserve
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// changesPage is the body of GET /users/changes
+type changesPage struct {
+	Changes []UserEvent `json:"changes"`
+	// NextSince is the sequence number to pass as ?since= next time: the
+	// last change returned, or since itself when there were none
+	NextSince ID `json:"next_since"`
+	// HasMore is set when changes were left out for the limit
+	HasMore bool `json:"has_more"`
+}
+
+// HandleUserChanges handles GET /users/changes, listing user changes
+// oldest first. Each is numbered by its audit entry, so sequence numbers
+// only grow and match the IDs of live events; a consumer syncs by
+// passing the last one it saw as ?since=.
+func (s *Server) HandleUserChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var since ID
+	var err error
+	if v := q.Get("since"); v != "" {
+		if since, err = ParseID(v); err != nil || since < 0 {
+			httpError(w, r, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := defaultChangesLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxChangesLimit {
+			httpError(w, r, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page := changesPage{Changes: make([]UserEvent, 0), NextSince: since}
+	for _, e := range s.audit.Query(AuditFilter{AfterID: since}) {
+		ev, ok := userEventFor(e)
+		if !ok || !eventVisible(r.Context(), ev) {
+			continue
+		}
+		if len(page.Changes) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Changes = append(page.Changes, ev)
+		page.NextSince = ev.ID
+	}
+
+	s.writeJSON(w, r, http.StatusOK, page)
+}
@@ -0,0 +1,75 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUserChanges(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	routes := NewServer().Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	changes := func(query string) changesPage {
+		t.Helper()
+		w := do(http.MethodGet, "/users/changes"+query, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var page changesPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	if page := changes(""); len(page.Changes) != 0 || page.NextSince != 0 || page.HasMore {
+		t.Errorf("expected no changes yet, got %+v", page)
+	}
+
+	do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	do(http.MethodPost, "/orgs", `{"name":"Acme"}`)
+	do(http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com"}`)
+	do(http.MethodDelete, "/users/1", "")
+
+	// Only user changes are listed, oldest first, with growing numbers
+	page := changes("?limit=2")
+	if len(page.Changes) != 2 || !page.HasMore {
+		t.Fatalf("expected a first page of 2 with more to come, got %+v", page)
+	}
+	first, second := page.Changes[0], page.Changes[1]
+	if first.Type != EventUserCreated || first.UserID != 1 || second.Type != EventUserCreated || second.UserID != 2 {
+		t.Errorf("expected both creations first, got %+v", page.Changes)
+	}
+	if first.ID >= second.ID || page.NextSince != second.ID {
+		t.Errorf("expected increasing sequence numbers ending at next_since, got %d, %d and %d", first.ID, second.ID, page.NextSince)
+	}
+
+	// Passing next_since continues where the last call stopped
+	page = changes("?since=" + page.NextSince.String())
+	if len(page.Changes) != 1 || page.Changes[0].Type != EventUserDeleted || page.HasMore {
+		t.Fatalf("expected only the deletion, got %+v", page)
+	}
+	if page.Changes[0].User == nil {
+		t.Error("expected the deleted user's last state")
+	}
+	last := page.NextSince
+	if page = changes("?since=" + last.String()); len(page.Changes) != 0 || page.NextSince != last {
+		t.Errorf("expected nothing new and next_since kept at %d, got %+v", last, page)
+	}
+
+	for _, query := range []string{"?since=abc", "?since=-1", "?limit=0", "?limit=1001"} {
+		if w := do(http.MethodGet, "/users/changes"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,690 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/text/language"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Main runs the quickserve binary: it reads its Config from a file,
+// QUICKSERVE_* environment variables and command-line flags, serves until
+// a listener fails or a shutdown signal arrives, and exits the process on
+// fatal errors. `quickserve soak` runs a soak test instead, and
+// `quickserve loadtest` a load test; see soakMain and loadTestMain.
+func Main() {
+	if len(os.Args) > 1 && os.Args[1] == "soak" {
+		soakMain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		loadTestMain(os.Args[2:])
+		return
+	}
+
+	cfg, err := LoadConfig(os.Args[1:], os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	// Validated by LoadConfig
+	live, _ := cfg.LiveSettings()
+	var level slog.LevelVar
+	level.Set(live.LogLevel)
+	logger, _ := NewLogger(os.Stderr, cfg.Log.Format, &level)
+	slog.SetDefault(logger)
+
+	// SIGHUP and POST /admin/reload read the file and environment again;
+	// only the live settings take effect without a restart
+	opts := []Option{WithLogger(logger), WithLogLevel(&level), WithReloadSource(func() (LiveSettings, error) {
+		cfg, err := LoadConfig(os.Args[1:], os.Environ())
+		if err != nil {
+			return LiveSettings{}, err
+		}
+		return cfg.LiveSettings()
+	})}
+	if live.RateLimit != nil {
+		opts = append(opts, WithRateLimit(live.RateLimit.Rate, live.RateLimit.Burst))
+	}
+	for group, access := range live.IPAccess {
+		opts = append(opts, WithIPAccess(group, access))
+	}
+	if cfg.Log.Access {
+		opts = append(opts, WithAccessLog(cfg.Log.AccessSkip...))
+	}
+	if cfg.Docs {
+		opts = append(opts, WithDocs())
+	}
+	if cfg.TLS.ClientCA != "" {
+		pool, err := LoadClientCAs(cfg.TLS.ClientCA)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, WithClientCAs(pool))
+	}
+	if key := os.Getenv("QUICKSERVE_API_KEY"); key != "" {
+		opts = append(opts, WithAPIKeyAuth(key))
+	}
+	if user := os.Getenv("QUICKSERVE_ADMIN_USER"); user != "" {
+		opts = append(opts, WithAdminCredentials(user, os.Getenv("QUICKSERVE_ADMIN_PASSWORD")))
+	}
+	if f := os.Getenv("QUICKSERVE_ID_FORMAT"); f != "" {
+		opts = append(opts, WithIDFormat(IDFormat(f)))
+	}
+	if issuer := os.Getenv("QUICKSERVE_OIDC_ISSUER"); issuer != "" {
+		provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("QUICKSERVE_OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("QUICKSERVE_OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("QUICKSERVE_OIDC_REDIRECT_URL"),
+		}, nil)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, WithOIDC(provider))
+	}
+	if start := os.Getenv("QUICKSERVE_SIMULATED_CLOCK"); start != "" {
+		t, err := ParseTimestamp(start)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("running on a simulated clock", "start", t.Format(time.RFC3339))
+		opts = append(opts, WithClock(NewSimulatedClock(t)))
+	}
+	if d := cfg.Timeouts.MaxRequestDeadline; d > 0 {
+		opts = append(opts, WithMaxRequestDeadline(d))
+	}
+	if v := os.Getenv("QUICKSERVE_MAX_BODY"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			fatalf("invalid QUICKSERVE_MAX_BODY %q", v)
+		}
+		opts = append(opts, WithMaxBodySize(n))
+	}
+	// e.g. "POST /users=4096,PUT /users/{id}/notifications=1024"
+	if v := os.Getenv("QUICKSERVE_MAX_BODY_ROUTES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			pattern, size, _ := strings.Cut(entry, "=")
+			n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+			if err != nil {
+				fatalf("invalid QUICKSERVE_MAX_BODY_ROUTES entry %q", entry)
+			}
+			opts = append(opts, WithMaxBodySize(n, strings.TrimSpace(pattern)))
+		}
+	}
+	for target, value := range cfg.CacheControl {
+		opts = append(opts, WithCacheControl(value, target))
+	}
+	if n := cfg.Store.CacheSize; n > 0 {
+		opts = append(opts, WithResponseCache(n))
+	}
+	opts = append(opts, WithPostsOnUserDelete(PostsOnUserDelete(cfg.Store.PostsOnUserDelete)))
+	if t := cfg.Tenancy; len(t.Sources) > 0 {
+		tenancy := Tenancy{Domain: t.Domain}
+		for _, src := range t.Sources {
+			tenancy.Sources = append(tenancy.Sources, TenantSource(src))
+		}
+		opts = append(opts, WithTenancy(tenancy))
+	}
+	if n := cfg.Concurrency.MaxInFlight; n > 0 {
+		opts = append(opts, WithConcurrencyLimit(n, cfg.Concurrency.QueueTimeout))
+	}
+	if ls := cfg.LoadShedding; ls.MaxLatency > 0 || ls.MaxGoroutines > 0 {
+		opts = append(opts, WithLoadShedding(ls.MaxLatency, ls.MaxGoroutines))
+	}
+	if lc := cfg.LeakCheck; lc.Interval > 0 {
+		opts = append(opts, WithLeakMonitor(lc.Interval, lc.MaxGoroutineGrowth, int64(lc.MaxHeapGrowthMB)<<20))
+	}
+	for pattern, p := range cfg.LoadShedding.Priorities {
+		opts = append(opts, WithRoutePriority(p, pattern))
+	}
+	if d := cfg.Timeouts.Handler; d > 0 {
+		opts = append(opts, WithHandlerTimeout(d))
+	}
+	if v := os.Getenv("QUICKSERVE_SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			fatalf("invalid QUICKSERVE_SESSION_TTL %q", v)
+		}
+		opts = append(opts, WithSessionTTL(d))
+	}
+	if v := os.Getenv("QUICKSERVE_SESSION_IDLE_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			fatalf("invalid QUICKSERVE_SESSION_IDLE_TIMEOUT %q", v)
+		}
+		opts = append(opts, WithSessionIdleTimeout(d))
+	}
+	if v := os.Getenv("QUICKSERVE_CSRF_GROUPS"); v != "" {
+		opts = append(opts, WithCSRFProtection(strings.Split(v, ",")...))
+	}
+	if v := os.Getenv("QUICKSERVE_SIGNING_SECRETS"); v != "" {
+		groups := strings.Split(os.Getenv("QUICKSERVE_SIGNED_GROUPS"), ",")
+		opts = append(opts, WithRequestSigning(strings.Split(v, ","), groups...))
+	}
+	if v := os.Getenv("QUICKSERVE_TRUSTED_PROXIES"); v != "" {
+		proxies, err := ParseCIDRs(v)
+		if err != nil {
+			fatalf("QUICKSERVE_TRUSTED_PROXIES: %v", err)
+		}
+		opts = append(opts, WithTrustedProxies(proxies...))
+	}
+	var quota Quota
+	for env, limit := range map[string]*int{
+		"QUICKSERVE_DAILY_QUOTA":   &quota.Daily,
+		"QUICKSERVE_MONTHLY_QUOTA": &quota.Monthly,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				fatalf("%s: %v", env, err)
+			}
+			*limit = n
+		}
+	}
+	opts = append(opts, WithQuota(quota))
+	var maxUsers, tenantMaxUsers int
+	for env, limit := range map[string]*int{
+		"QUICKSERVE_MAX_USERS":        &maxUsers,
+		"QUICKSERVE_TENANT_MAX_USERS": &tenantMaxUsers,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				fatalf("%s: must be a non-negative integer", env)
+			}
+			*limit = n
+		}
+	}
+	opts = append(opts, WithMaxUsers(maxUsers, tenantMaxUsers))
+	if p := os.Getenv("QUICKSERVE_TIMESTAMP_PRECISION"); p != "" {
+		precision, err := ParseTimestampPrecision(p)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, WithTimestampPrecision(precision))
+	}
+	if v := os.Getenv("QUICKSERVE_FEATURES"); v != "" {
+		flags := make(map[string]bool)
+		for _, name := range strings.Split(v, ",") {
+			flags[name] = true
+		}
+		opts = append(opts, WithFeatureFlags(flags))
+	}
+	if addr := os.Getenv("QUICKSERVE_SMTP_ADDR"); addr != "" {
+		opts = append(opts, WithMailer(SMTPMailer{
+			Addr:     addr,
+			From:     os.Getenv("QUICKSERVE_SMTP_FROM"),
+			Username: os.Getenv("QUICKSERVE_SMTP_USERNAME"),
+			Password: os.Getenv("QUICKSERVE_SMTP_PASSWORD"),
+		}))
+	}
+	if os.Getenv("QUICKSERVE_SIGNUP") == "true" {
+		cfg := SignupConfig{DefaultRole: Role(os.Getenv("QUICKSERVE_SIGNUP_ROLE"))}
+		if v := os.Getenv("QUICKSERVE_SIGNUP_DOMAINS"); v != "" {
+			cfg.AllowedDomains = strings.Split(v, ",")
+		}
+		if secret := os.Getenv("QUICKSERVE_HCAPTCHA_SECRET"); secret != "" {
+			cfg.Captcha = NewHCaptcha(secret)
+		}
+		if secret := os.Getenv("QUICKSERVE_TURNSTILE_SECRET"); secret != "" {
+			cfg.Captcha = NewTurnstile(secret)
+		}
+		if cfg.DefaultRole != "" && !cfg.DefaultRole.Valid() {
+			fatalf("invalid QUICKSERVE_SIGNUP_ROLE %q", cfg.DefaultRole)
+		}
+		opts = append(opts, WithSignup(cfg))
+	}
+	if url := os.Getenv("QUICKSERVE_INVITE_URL"); url != "" {
+		opts = append(opts, WithInviteURL(url))
+	}
+	if v, dir := os.Getenv("QUICKSERVE_LOCALE"), os.Getenv("QUICKSERVE_LOCALE_DIR"); v != "" || dir != "" {
+		fallback := language.English
+		if v != "" {
+			tag, err := language.Parse(v)
+			if err != nil {
+				fatalf("invalid QUICKSERVE_LOCALE %q", v)
+			}
+			fallback = tag
+		}
+		translator := NewTranslator(fallback)
+		if dir != "" {
+			if err := translator.LoadDir(dir); err != nil {
+				fatal(err)
+			}
+		}
+		opts = append(opts, WithTranslator(translator))
+	}
+	if path := cfg.Store.AuditFile; path != "" {
+		auditLog, err := OpenAuditLog(path)
+		if err != nil {
+			fatal(err)
+		}
+		defer auditLog.Close()
+		opts = append(opts, WithAuditLog(auditLog))
+	}
+	if cfg.Store.Search == "bleve" {
+		index, err := NewBleveIndex(cfg.Store.SearchDir)
+		if err != nil {
+			fatal(err)
+		}
+		defer index.Close()
+		opts = append(opts, WithBleveIndex(index))
+	}
+	if path := cfg.Store.TenantSettingsFile; path != "" {
+		store, err := LoadTenantSettings(path)
+		if err != nil {
+			fatal(err)
+		}
+		opts = append(opts, WithTenantSettings(store))
+	}
+	if os.Getenv("QUICKSERVE_SCHEMA_DRIFT_READS") == "off" {
+		opts = append(opts, WithSchemaDriftReads(false))
+	}
+	secret := os.Getenv("QUICKSERVE_REPLICATION_SECRET")
+	if url := os.Getenv("QUICKSERVE_STANDBY_URL"); url != "" {
+		if secret == "" {
+			fatalf("QUICKSERVE_STANDBY_URL requires QUICKSERVE_REPLICATION_SECRET")
+		}
+		var interval time.Duration
+		if v := os.Getenv("QUICKSERVE_SNAPSHOT_INTERVAL"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				fatal(err)
+			}
+			interval = d
+		}
+		opts = append(opts, WithSnapshotShipping(url, secret, interval))
+	}
+	if os.Getenv("QUICKSERVE_STANDBY") == "true" {
+		if secret == "" {
+			fatalf("QUICKSERVE_STANDBY requires QUICKSERVE_REPLICATION_SECRET")
+		}
+		opts = append(opts, WithStandby(strings.Split(secret, ",")...))
+	}
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		endpoint = strings.TrimSuffix(v, "/v1/traces")
+	}
+	if endpoint != "" {
+		cfg := TracingConfig{Endpoint: endpoint, ServiceName: os.Getenv("OTEL_SERVICE_NAME")}
+		if v := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); v != "" {
+			cfg.Headers = make(map[string]string)
+			for _, pair := range strings.Split(v, ",") {
+				k, val, ok := strings.Cut(pair, "=")
+				if !ok {
+					fatalf("invalid OTEL_EXPORTER_OTLP_HEADERS entry %q, want key=value", pair)
+				}
+				cfg.Headers[strings.TrimSpace(k)] = strings.TrimSpace(val)
+			}
+		}
+		opts = append(opts, WithTracing(NewTracer(cfg)))
+	}
+	if len(cfg.Kafka.Brokers) > 0 {
+		opts = append(opts, WithKafka(NewKafkaPublisher(cfg.Kafka)))
+	}
+	if cfg.NATS.URL != "" {
+		opts = append(opts, WithNATS(NewNATSTransport(cfg.NATS)))
+	}
+
+	server := NewServer(opts...)
+	if drift := server.schemaDrift(); len(drift) > 0 {
+		for _, d := range drift {
+			slog.Error("schema drift", "store", d.Store, "field", d.Field, "problem", d.Problem,
+				"expected", d.Expected, "found", d.Found, "records", d.Records, "example", d.Example)
+		}
+		slog.Warn("refusing writes until migrations are applied", "reads", server.driftReads)
+	}
+
+	restHandler, err := server.Handler()
+	if err != nil {
+		fatalf("invalid routes:\n%v", err)
+	}
+	handler := restHandler
+	if cfg.GRPC {
+		handler = MultiplexGRPC(NewGRPCServer(restHandler), restHandler)
+	}
+
+	if server.isStandby() {
+		slog.Warn("running as standby; writes are refused until POST /admin/promote")
+	} else if cfg.Store.Seed != "" {
+		// Validated by LoadConfig
+		seed, _ := LoadSeed(cfg.Store.Seed)
+		n, err := server.seedUsers(context.Background(), seed)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("seeded users", "file", cfg.Store.Seed, "created", n, "existing", len(seed.Users)-n)
+	}
+
+	// Background jobs and listeners run in two groups. A failure in either
+	// stops everything; a shutdown signal drains the listeners first, so
+	// in-flight requests can still start background work, and then stops
+	// the jobs. Main returns only once all have exited.
+	background := newTaskGroup(context.Background())
+	background.Go("server", server.Run)
+	if interval := sdWatchdogInterval(); interval > 0 {
+		background.Go("watchdog", func(ctx context.Context) error { return sdWatchdog(ctx, interval) })
+	}
+	listeners := newTaskGroup(context.Background())
+	context.AfterFunc(background.ctx, listeners.stop)
+	wait := func() {
+		// Every listener is open by now
+		if err := sdNotify("READY=1"); err != nil {
+			slog.Warn("notifying systemd failed", "err", err)
+		}
+		err := listeners.wait()
+		background.stop()
+		if err := errors.Join(err, background.wait()); err != nil {
+			fatal(err)
+		}
+		slog.Info("shut down")
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	listeners.Go("reload", func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-hangups:
+				if _, err := server.Reload(); err != nil {
+					slog.Error("reload failed; keeping the current settings", "err", err)
+				}
+			}
+		}
+	})
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	listeners.Go("signals", func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-signals.Done():
+		}
+		// A second signal kills the process
+		stopSignals()
+		slog.Info("draining", "delay", cfg.Timeouts.DrainDelay, "timeout", cfg.Timeouts.Drain)
+		server.Drain()
+		if err := sdNotify("STOPPING=1"); err != nil {
+			slog.Warn("notifying systemd failed", "err", err)
+		}
+		t := time.NewTimer(cfg.Timeouts.DrainDelay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		listeners.stop()
+		return nil
+	})
+
+	// Validate has checked the mode
+	socketMode, _ := cfg.socketMode()
+	if addr := cfg.AgentCheckAddr; addr != "" {
+		ln, err := listen(addr, socketMode)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("serving HAProxy agent-check", "addr", addr)
+		listeners.Go("agent-check", func(ctx context.Context) error {
+			stop := context.AfterFunc(ctx, func() { ln.Close() })
+			defer stop()
+			err := server.ServeAgentCheck(ln)
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		})
+	}
+	if cfg.PprofAddr != "" {
+		ln, err := listen(cfg.PprofAddr, socketMode)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("serving pprof", "addr", cfg.PprofAddr)
+		mux := http.NewServeMux()
+		mux.Handle(pprofPrefix, DebugHandler())
+		mux.Handle(leakPath, server.LeakHandler())
+		mux.Handle(leakPath+"/", server.LeakHandler())
+		mux.Handle(deprecationMetricsPath, server.DeprecationMetricsHandler())
+		debug := &http.Server{Handler: mux}
+		listeners.Go("pprof", func(ctx context.Context) error {
+			return serveAndDrain(ctx, debug, cfg.Timeouts.Drain, func() error { return debug.Serve(ln) })
+		})
+	}
+
+	httpServer := func(addr string, tlsConfig *tls.Config) *http.Server {
+		return &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			TLSConfig:         tlsConfig,
+			ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+			ReadTimeout:       cfg.Timeouts.Read,
+			WriteTimeout:      cfg.Timeouts.Write,
+			IdleTimeout:       cfg.Timeouts.Idle,
+			ErrorLog:          slog.NewLogLogger(logger.With("component", "http", "addr", addr).Handler(), slog.LevelError),
+		}
+	}
+	srv := httpServer(cfg.Addr, server.TLSConfig())
+
+	// Additional listeners serve the same handler; all of them share the
+	// listeners group, so one failing or a signal stops and drains them all
+	ln, err := listen(cfg.Addr, socketMode)
+	if err != nil {
+		fatal(err)
+	}
+	for _, l := range cfg.Listeners {
+		var pool *x509.CertPool
+		if l.ClientCA != "" {
+			if pool, err = LoadClientCAs(l.ClientCA); err != nil {
+				fatal(err)
+			}
+		}
+		extra := httpServer(l.Addr, mutualTLSConfig(pool))
+		if cfg.H2C && l.Cert == "" {
+			if err := EnableH2C(extra); err != nil {
+				fatal(err)
+			}
+		}
+		extraLn, err := listen(l.Addr, socketMode)
+		if err != nil {
+			fatal(err)
+		}
+		serve := func() error { return extra.Serve(extraLn) }
+		if l.Cert != "" {
+			serve = func() error { return extra.ServeTLS(extraLn, l.Cert, l.Key) }
+		}
+		slog.Info("starting additional listener", "addr", l.Addr, "tls", l.Cert != "", "mtls", pool != nil)
+		listeners.Go("listener "+l.Addr, func(ctx context.Context) error {
+			return serveAndDrain(ctx, extra, cfg.Timeouts.Drain, serve)
+		})
+	}
+	// gRPC gets a listener of its own, with the main listener's TLS
+	// settings, and is served by the same handler
+	serveGRPC := func(tlsConfig *tls.Config) {
+		if cfg.GRPCAddr == "" {
+			return
+		}
+		var grpcOpts []grpc.ServerOption
+		if tlsConfig != nil {
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		if cfg.Timeouts.Idle > 0 {
+			grpcOpts = append(grpcOpts, grpc.KeepaliveParams(keepalive.ServerParameters{MaxConnectionIdle: cfg.Timeouts.Idle}))
+		}
+		gs := NewGRPCServer(restHandler, grpcOpts...)
+		grpcLn, err := listen(cfg.GRPCAddr, socketMode)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("starting gRPC server", "addr", cfg.GRPCAddr, "tls", tlsConfig != nil)
+		listeners.Go("grpc", func(ctx context.Context) error {
+			return serveAndDrain(ctx, grpcDrainer{gs}, cfg.Timeouts.Drain, func() error { return gs.Serve(grpcLn) })
+		})
+	}
+
+	if !cfg.UseTLS() {
+		serveGRPC(nil)
+		if cfg.H2C {
+			if err := EnableH2C(srv); err != nil {
+				fatal(err)
+			}
+		}
+		slog.Info("starting server", "addr", cfg.Addr, "h2c", cfg.H2C)
+		listeners.Go("http", func(ctx context.Context) error {
+			return serveAndDrain(ctx, srv, cfg.Timeouts.Drain, func() error { return srv.Serve(ln) })
+		})
+		wait()
+		return
+	}
+
+	// HTTP-01 challenges always arrive on port 80, so ACME mode needs a
+	// plain HTTP listener even when no redirect was asked for
+	redirect, redirectAddr := redirectToHTTPS(cfg.Addr), cfg.TLS.RedirectAddr
+	if len(cfg.TLS.ACMEDomains) > 0 {
+		m := newACMEManager(cfg.TLS.ACMEDomains, cfg.TLS.ACMECache, cfg.TLS.ACMEEmail)
+		srv.TLSConfig = acmeTLSConfig(srv.TLSConfig, m)
+		redirect = acmeChallengeHandler(m, cfg.Addr)
+		if redirectAddr == "" {
+			redirectAddr = ":80"
+		}
+	}
+
+	if redirectAddr != "" {
+		slog.Info("redirecting HTTP to HTTPS", "addr", redirectAddr)
+		plain := &http.Server{Addr: redirectAddr, Handler: redirect, ReadHeaderTimeout: cfg.Timeouts.ReadHeader}
+		listeners.Go("redirect", func(ctx context.Context) error {
+			return serveAndDrain(ctx, plain, cfg.Timeouts.Drain, plain.ListenAndServe)
+		})
+	}
+
+	if cfg.GRPCAddr != "" {
+		tlsConfig, err := withCertificates(srv.TLSConfig, cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			fatal(err)
+		}
+		serveGRPC(tlsConfig)
+	}
+
+	if cfg.TLS.HTTP3 {
+		h3, err := newHTTP3Server(srv, cfg.TLS.Cert, cfg.TLS.Key)
+		if err != nil {
+			fatal(err)
+		}
+		conn, err := net.ListenPacket("udp", cfg.Addr)
+		if err != nil {
+			fatal(err)
+		}
+		srv.Handler = advertiseHTTP3(h3, srv.Handler)
+		slog.Info("starting experimental HTTP/3 server", "addr", cfg.Addr)
+		listeners.Go("http3", func(ctx context.Context) error {
+			defer conn.Close()
+			return serveAndDrain(ctx, h3, cfg.Timeouts.Drain, func() error { return h3.Serve(conn) })
+		})
+	}
+
+	slog.Info("starting HTTPS server", "addr", cfg.Addr)
+	listeners.Go("https", func(ctx context.Context) error {
+		return serveAndDrain(ctx, srv, cfg.Timeouts.Drain, func() error { return srv.ServeTLS(ln, cfg.TLS.Cert, cfg.TLS.Key) })
+	})
+	wait()
+}
+
+// soakMain runs `quickserve soak`: traffic against an embedded instance
+// followed by a leak report on stdout. It exits 1 if the run failed.
+func soakMain(args []string) {
+	fs := flag.NewFlagSet("soak", flag.ExitOnError)
+	duration := fs.Duration("duration", 10*time.Minute, "how long to run traffic")
+	rps := fs.Int("rps", 100, "requests per second")
+	interval := fs.Duration("sample-interval", 0, "how often to sample goroutines and heap; default 60 samples per run")
+	maxGoroutines := fs.Int("max-goroutine-growth", 0, "goroutines allowed to outlive the instance")
+	maxHeapMB := fs.Int64("max-heap-growth-mb", defaultSoakMaxHeapGrowth>>20, "live heap growth allowed after warm-up, in MiB")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	fs.Parse(args)
+
+	// Request logs would drown the report
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := Soak(ctx, SoakConfig{
+		Duration:           *duration,
+		RPS:                *rps,
+		SampleInterval:     *interval,
+		MaxGoroutineGrowth: *maxGoroutines,
+		MaxHeapGrowth:      *maxHeapMB << 20,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if !report.Passed() {
+		os.Exit(1)
+	}
+}
+
+// loadTestMain runs `quickserve loadtest`: traffic against a running
+// server followed by a latency and error report on stdout
+func loadTestMain(args []string) {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	target := fs.String("target", cmp.Or(os.Getenv("QUICKSERVE_URL"), "http://localhost:8080"), "base URL of the server under test (env QUICKSERVE_URL)")
+	duration := fs.Duration("duration", 30*time.Second, "how long to run traffic")
+	rps := fs.Int("rps", 50, "requests per second")
+	workers := fs.Int("workers", defaultLoadTestWorkers, "requests allowed in flight")
+	apiKey := fs.String("api-key", os.Getenv("QUICKSERVE_API_KEY"), "API key to authenticate with (env QUICKSERVE_API_KEY)")
+	token := fs.String("token", os.Getenv("QUICKSERVE_TOKEN"), "bearer token to authenticate with (env QUICKSERVE_TOKEN)")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	report, err := LoadTest(ctx, LoadTestConfig{
+		Target:   *target,
+		Duration: *duration,
+		RPS:      *rps,
+		Workers:  *workers,
+		APIKey:   *apiKey,
+		Token:    *token,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	if *asJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
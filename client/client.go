@@ -0,0 +1,366 @@
+// Package client is a typed Go client for the quickserve user API, so
+// consumers don't hand-roll HTTP calls. It shares its types with the
+// server, e.g.
+//
+//	c, err := client.New("https://users.example.com", client.WithAPIKey(key))
+//	user, err := c.CreateUser(ctx, client.CreateUserRequest{Name: "Alice", Email: "alice@example.com"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harshakonda/quickserve"
+)
+
+const (
+	// defaultRetries is how many times an idempotent request is retried
+	defaultRetries = 2
+	// defaultBackoff is the wait before the first retry; it doubles for
+	// each one after
+	defaultBackoff = 100 * time.Millisecond
+	// maxRetryAfter caps how long a Retry-After header can make a retry
+	// wait
+	maxRetryAfter = 30 * time.Second
+)
+
+// Client calls a quickserve server. It is safe for concurrent use.
+type Client struct {
+	base    *url.URL
+	http    *http.Client
+	auth    []func(*http.Request) error
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient,
+// e.g. for timeouts, proxies or client certificates
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithAuth adds a hook that authenticates each request, e.g. by signing
+// it or fetching a fresh token. Hooks run in order before every attempt.
+func WithAuth(hook func(*http.Request) error) Option {
+	return func(c *Client) {
+		c.auth = append(c.auth, hook)
+	}
+}
+
+// WithAPIKey authenticates with an API key
+func WithAPIKey(key string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set(quickserve.APIKeyHeader, key)
+		return nil
+	})
+}
+
+// WithBearerToken authenticates with a session or OpenID Connect token
+func WithBearerToken(token string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// WithBasicAuth authenticates with the admin username and password
+func WithBasicAuth(username, password string) Option {
+	return WithAuth(func(r *http.Request) error {
+		r.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// WithRetries sets how many times a request that may be repeated safely
+// is retried after a connection error, 429 or 503, and the wait before
+// the first retry, which doubles for each one after. A Retry-After
+// header from the server wins. Zero retries turns retrying off.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries, c.backoff = n, backoff
+	}
+}
+
+// New returns a client for the server at baseURL, such as
+// https://users.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("base URL %q must be http or https", baseURL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	c := &Client{base: base, http: http.DefaultClient, retries: defaultRetries, backoff: defaultBackoff}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Error is a failed call: the server's answer when there was one
+type Error struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"error"`
+	RequestID  string `json:"request_id,omitempty"`
+	// Invalid lists the problems with a request that did not match the
+	// API schema
+	Invalid []quickserve.FieldError `json:"invalid,omitempty"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+	for _, f := range e.Invalid {
+		if f.Field != "" {
+			msg += fmt.Sprintf("; %s %s", f.Field, f.Reason)
+		} else {
+			msg += fmt.Sprintf("; %s %s", f.In, f.Reason)
+		}
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// IsNotFound reports whether err is a 404 from the server
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// CreateUserRequest is the body of CreateUser. Role defaults to user;
+// users without a Password can only sign in with an API key or OpenID
+// Connect.
+type CreateUserRequest struct {
+	Name     string          `json:"name"`
+	Email    string          `json:"email"`
+	Role     quickserve.Role `json:"role,omitempty"`
+	Locale   string          `json:"locale,omitempty"`
+	Password string          `json:"password,omitempty"`
+}
+
+// NotificationsUpdate changes a user's notification preferences; nil
+// fields are left unchanged
+type NotificationsUpdate struct {
+	EmailOnLogin  *bool     `json:"email_on_login,omitempty"`
+	WeeklyDigest  *bool     `json:"weekly_digest,omitempty"`
+	WebhookEvents *[]string `json:"webhook_events,omitempty"`
+}
+
+// ActivityOptions pages through a user's activity. Zero values use the
+// server's defaults: the newest entries, 20 at a time.
+type ActivityOptions struct {
+	Limit  int
+	Before quickserve.ID
+}
+
+// ActivityPage is one page of a user's activity, newest first
+type ActivityPage struct {
+	Items []quickserve.ActivityItem `json:"items"`
+	// NextCursor is passed as Before to fetch the next page; nil on the
+	// last one
+	NextCursor *quickserve.ID `json:"next_cursor,omitempty"`
+}
+
+// ListUsers lists every user in ID order
+func (c *Client) ListUsers(ctx context.Context) ([]quickserve.User, error) {
+	var users []quickserve.User
+	err := c.do(ctx, http.MethodGet, "/users", nil, nil, &users)
+	return users, err
+}
+
+// GetUser fetches one user; IsNotFound reports a missing one
+func (c *Client) GetUser(ctx context.Context, id quickserve.ID) (quickserve.User, error) {
+	var user quickserve.User
+	err := c.do(ctx, http.MethodGet, userPath(id), nil, nil, &user)
+	return user, err
+}
+
+// CreateUser creates a user. It is not retried, since a retry after a
+// lost response could create the user twice.
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (quickserve.User, error) {
+	var user quickserve.User
+	err := c.do(ctx, http.MethodPost, "/users", nil, req, &user)
+	return user, err
+}
+
+// DeleteUser deletes a user, ending their sessions
+func (c *Client) DeleteUser(ctx context.Context, id quickserve.ID) error {
+	return c.do(ctx, http.MethodDelete, userPath(id), nil, nil, nil)
+}
+
+// GetNotifications fetches a user's notification preferences
+func (c *Client) GetNotifications(ctx context.Context, id quickserve.ID) (quickserve.NotificationPrefs, error) {
+	var prefs quickserve.NotificationPrefs
+	err := c.do(ctx, http.MethodGet, userPath(id)+"/notifications", nil, nil, &prefs)
+	return prefs, err
+}
+
+// UpdateNotifications changes a user's notification preferences and
+// returns them as they now are
+func (c *Client) UpdateNotifications(ctx context.Context, id quickserve.ID, update NotificationsUpdate) (quickserve.NotificationPrefs, error) {
+	var prefs quickserve.NotificationPrefs
+	err := c.do(ctx, http.MethodPut, userPath(id)+"/notifications", nil, update, &prefs)
+	return prefs, err
+}
+
+// ListActivity fetches a page of a user's activity
+func (c *Client) ListActivity(ctx context.Context, id quickserve.ID, opts ActivityOptions) (ActivityPage, error) {
+	query := make(url.Values)
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Before != 0 {
+		query.Set("before", opts.Before.String())
+	}
+	var page ActivityPage
+	err := c.do(ctx, http.MethodGet, userPath(id)+"/activity", query, nil, &page)
+	return page, err
+}
+
+// Avatar fetches a user's avatar as SVG, or PNG if png is set
+func (c *Client) Avatar(ctx context.Context, id quickserve.ID, png bool) ([]byte, error) {
+	query := url.Values{"format": {"svg"}}
+	if png {
+		query.Set("format", "png")
+	}
+	var img []byte
+	err := c.do(ctx, http.MethodGet, userPath(id)+"/avatar", query, nil, &img)
+	return img, err
+}
+
+func userPath(id quickserve.ID) string {
+	return "/users/" + id.String()
+}
+
+// do sends a request, retrying it if the method allows, and decodes a
+// successful JSON answer into out. A *[]byte out receives the raw body.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	retries := 0
+	if method != http.MethodPost && method != http.MethodPatch {
+		retries = c.retries
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := c.newRequest(ctx, method, u.String(), body)
+		if err != nil {
+			return err
+		}
+		resp, err := c.http.Do(req)
+		if err == nil && (attempt == retries || !retryStatus(resp.StatusCode)) {
+			defer resp.Body.Close()
+			return decodeResponse(resp, out)
+		}
+		if err != nil && (attempt == retries || ctx.Err() != nil) {
+			return err
+		}
+
+		wait := c.backoff << attempt
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = after
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		// Jitter keeps clients that failed together from retrying together
+		wait += rand.N(wait/4 + 1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// newRequest builds one attempt at a request and runs the auth hooks
+func (c *Client) newRequest(ctx context.Context, method, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, hook := range c.auth {
+		if err := hook(req); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// retryStatus reports whether an answer means the server could not take
+// the request right now
+func retryStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(v string) (time.Duration, bool) {
+	secs, err := strconv.Atoi(v)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return min(time.Duration(secs)*time.Second, maxRetryAfter), true
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		b, _ := io.ReadAll(resp.Body)
+		var p struct {
+			Title     string `json:"title"`
+			RequestID string `json:"request_id"`
+		}
+		switch {
+		case json.Unmarshal(b, e) == nil && e.Message != "":
+		case json.Unmarshal(b, &p) == nil && p.Title != "":
+			// An unexpected failure, answered with problem details
+			e.Message, e.RequestID = p.Title, p.RequestID
+		default:
+			e.Message = http.StatusText(resp.StatusCode)
+		}
+		return e
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		b, err := io.ReadAll(resp.Body)
+		*out = b
+		return err
+	default:
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
@@ -0,0 +1,160 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/client"
+)
+
+func newServer(t *testing.T, opts ...quickserve.Option) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(quickserve.NewServer(opts...).Routes())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClientUsers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := newServer(t, quickserve.WithAPIKeyAuth("admin-secret"))
+	c, err := client.New(ts.URL+"/", client.WithAPIKey("admin-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	alice, err := c.CreateUser(ctx, client.CreateUserRequest{Name: "Alice", Email: "alice@test.com", Role: quickserve.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.ID == 0 || alice.Name != "Alice" || alice.Role != quickserve.RoleAdmin {
+		t.Fatalf("unexpected user: %+v", alice)
+	}
+	if got, err := c.GetUser(ctx, alice.ID); err != nil || got.Email != "alice@test.com" {
+		t.Errorf("expected to get Alice back, got %+v, %v", got, err)
+	}
+	if users, err := c.ListUsers(ctx); err != nil || len(users) != 1 {
+		t.Errorf("expected one user, got %+v, %v", users, err)
+	}
+
+	on := true
+	prefs, err := c.UpdateNotifications(ctx, alice.ID, client.NotificationsUpdate{WeeklyDigest: &on})
+	if err != nil || !prefs.WeeklyDigest {
+		t.Errorf("expected the digest to be turned on, got %+v, %v", prefs, err)
+	}
+	if prefs, err := c.GetNotifications(ctx, alice.ID); err != nil || !prefs.WeeklyDigest {
+		t.Errorf("expected the update to stick, got %+v, %v", prefs, err)
+	}
+	if page, err := c.ListActivity(ctx, alice.ID, client.ActivityOptions{Limit: 5}); err != nil || len(page.Items) == 0 {
+		t.Errorf("expected the creation in the activity, got %+v, %v", page, err)
+	}
+	if img, err := c.Avatar(ctx, alice.ID, false); err != nil || !strings.HasPrefix(string(img), "<svg") {
+		t.Errorf("expected an SVG avatar, got %.20q, %v", img, err)
+	}
+
+	if err := c.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, alice.ID); !client.IsNotFound(err) {
+		t.Errorf("expected a deleted user to be not found, got %v", err)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := newServer(t, quickserve.WithAPIKeyAuth("admin-secret"))
+	ctx := context.Background()
+
+	anonymous, _ := client.New(ts.URL)
+	var e *client.Error
+	if _, err := anonymous.ListUsers(ctx); !errors.As(err, &e) || e.StatusCode != http.StatusUnauthorized || e.RequestID == "" {
+		t.Errorf("expected a 401 with a request ID, got %v", err)
+	}
+
+	c, _ := client.New(ts.URL, client.WithAPIKey("admin-secret"))
+	_, err := c.CreateUser(ctx, client.CreateUserRequest{Name: "Bob", Email: "bob@test.com", Role: "owner"})
+	if !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest || len(e.Invalid) != 1 {
+		t.Fatalf("expected 400 listing the bad role, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "role must be one of admin, user") {
+		t.Errorf("expected the message to name the problems, got %q", err)
+	}
+
+	if _, err := client.New("users.example.com"); err == nil {
+		t.Error("expected a base URL without a scheme to be refused")
+	}
+}
+
+func TestClientAuthHook(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := newServer(t, quickserve.WithAPIKeyAuth("admin-secret"))
+	var calls atomic.Int32
+	c, _ := client.New(ts.URL, client.WithAuth(func(r *http.Request) error {
+		calls.Add(1)
+		r.Header.Set(quickserve.APIKeyHeader, "admin-secret")
+		return nil
+	}))
+	if _, err := c.ListUsers(context.Background()); err != nil || calls.Load() != 1 {
+		t.Errorf("expected the hook to authenticate the request, got %v after %d calls", err, calls.Load())
+	}
+
+	failing, _ := client.New(ts.URL, client.WithAuth(func(*http.Request) error { return errors.New("no token") }))
+	if _, err := failing.ListUsers(context.Background()); err == nil || err.Error() != "no token" {
+		t.Errorf("expected the hook's error, got %v", err)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+
+	c, _ := client.New(ts.URL, client.WithRetries(2, time.Millisecond))
+	if _, err := c.ListUsers(ctx); err != nil || calls.Load() != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d", err, calls.Load())
+	}
+
+	calls.Store(0)
+	var e *client.Error
+	if _, err := c.CreateUser(ctx, client.CreateUserRequest{}); !errors.As(err, &e) || e.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("expected a POST not to be retried, got %v after %d", err, calls.Load())
+	}
+
+	calls.Store(0)
+	c, _ = client.New(ts.URL, client.WithRetries(0, 0))
+	if _, err := c.ListUsers(ctx); err == nil || calls.Load() != 1 {
+		t.Errorf("expected no retries, got %v after %d", err, calls.Load())
+	}
+
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	c, _ = client.New(unavailable.URL, client.WithRetries(5, time.Hour))
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListUsers(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait to end with the context, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package quickserve
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for everything time-dependent in the
+// server: timestamps, TTLs, rate limiting, scheduled jobs and token
+// expiry. Code should never call time.Now directly.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock is the real wall clock
+type SystemClock struct{}
+
+// Now returns the current time in UTC
+func (SystemClock) Now() time.Time { return time.Now().UTC() }
+
+// After waits for d to elapse
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SimulatedClock is a clock that only moves when told to. Tests use it
+// for deterministic behavior, and operators can run the server against
+// one to reproduce time-dependent bugs.
+type SimulatedClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []simWaiter
+}
+
+type simWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// NewSimulatedClock creates a simulated clock starting at start
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{now: start.UTC()}
+}
+
+// Now returns the simulated time
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives once the clock has been advanced
+// by at least d
+func (c *SimulatedClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	at := c.now.Add(d)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, simWaiter{at: at, ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, firing any due waiters
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to t. Moving backwards is allowed, which is useful
+// for reproducing clock-skew bugs; waiters only fire when time passes them.
+func (c *SimulatedClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t.UTC())
+}
+
+func (c *SimulatedClock) setLocked(t time.Time) {
+	c.now = t
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(t) {
+			w.ch <- t
+			continue
+		}
+		pending = append(pending, w)
+	}
+	c.waiters = pending
+}
+
+// WithClock sets the clock used by the server and its stores
+func WithClock(c Clock) Option {
+	return func(s *Server) {
+		s.clock = c
+	}
+}
+
+// HandleGetClock handles GET /admin/clock
+func (s *Server) HandleGetClock(w http.ResponseWriter, r *http.Request) {
+	_, simulated := s.clock.(*SimulatedClock)
+
+	s.writeJSON(w, r, http.StatusOK, struct {
+		CurrentAt time.Time `json:"current_at"`
+		Simulated bool      `json:"simulated"`
+	}{s.clock.Now(), simulated})
+}
+
+// HandleSetClock handles POST /admin/clock. It is only mounted when the
+// server runs on a SimulatedClock. The body either advances the clock by
+// a duration or sets it to an absolute time.
+func (s *Server) HandleSetClock(w http.ResponseWriter, r *http.Request) {
+	sim, ok := s.clock.(*SimulatedClock)
+	if !ok {
+		httpError(w, r, "clock is not simulated", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Advance string        `json:"advance"`
+		Set     *FlexibleTime `json:"set"`
+	}
+
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	before := sim.Now()
+	switch {
+	case req.Set != nil:
+		sim.Set(req.Set.Time)
+	case req.Advance != "":
+		d, err := time.ParseDuration(req.Advance)
+		if err != nil {
+			httpError(w, r, "invalid duration", http.StatusBadRequest)
+			return
+		}
+		sim.Advance(d)
+	default:
+		httpError(w, r, "advance or set is required", http.StatusBadRequest)
+		return
+	}
+	s.recordAudit(r, AuditUpdate, "clock", "", before, sim.Now())
+
+	s.HandleGetClock(w, r)
+}
@@ -0,0 +1,94 @@
+package quickserve
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSimulatedClockAfter(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	ch := clock.After(time.Minute)
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("expected waiter not to fire before deadline")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case got := <-ch:
+		if !got.Equal(start.Add(time.Minute)) {
+			t.Errorf("expected %v, got %v", start.Add(time.Minute), got)
+		}
+	default:
+		t.Fatal("expected waiter to fire at deadline")
+	}
+}
+
+func TestStoreUsesClock(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	server := NewServer(WithClock(NewSimulatedClock(start)))
+
+	user := addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+	if !user.CreatedAt.Equal(start) {
+		t.Errorf("expected created_at %v, got %v", start, user.CreatedAt)
+	}
+}
+
+func TestAdminAdvanceClock(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewSimulatedClock(start)
+	server := NewServer(WithClock(clock), WithAdminCredentials("admin", "pw"))
+
+	body := bytes.NewBufferString(`{"advance":"2h"}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/clock", body)
+	req.SetBasicAuth("admin", "pw")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if want := start.Add(2 * time.Hour); !clock.Now().Equal(want) {
+		t.Errorf("expected %v, got %v", want, clock.Now())
+	}
+}
+
+// awaitWaiter returns once something waits on c for no more than d, so
+// a test advances time only when the code under test is waiting for it
+func (c *SimulatedClock) awaitWaiter(t *testing.T, d time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.waitingWithin(d) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a wait of up to %s on the clock", d)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitingWithin reports whether a waiter is due within d
+func (c *SimulatedClock) waitingWithin(d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, w := range c.waiters {
+		if !w.at.After(c.now.Add(d)) {
+			return true
+		}
+	}
+	return false
+}
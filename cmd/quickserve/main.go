@@ -0,0 +1,40 @@
+// Command quickserve runs the quickserve REST API. `quickserve users`
+// manages the users of a running server instead, and `quickserve seed`
+// loads users into one from a seed file; see usersMain and seedMain.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/harshakonda/quickserve"
+)
+
+// subcommands run against a running server through the client package
+var subcommands = map[string]func(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) error{
+	"users": usersMain,
+	"seed":  seedMain,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := run(ctx, os.Args[2:], os.Stdout, os.Stderr, os.Getenv)
+			stop()
+			switch {
+			case errors.Is(err, errUsage):
+				os.Exit(2)
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "quickserve %s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	quickserve.Main()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/client"
+)
+
+// seedMain runs `quickserve seed`: it creates the users of a seed file on
+// a running server, skipping those whose email is already taken, as a
+// server started with -seed does
+func seedMain(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	serverURL := fs.String("server", envOr(getenv, "QUICKSERVE_URL", defaultServerURL), "base URL of the server (env QUICKSERVE_URL)")
+	apiKey := fs.String("api-key", getenv("QUICKSERVE_API_KEY"), "API key to authenticate with (env QUICKSERVE_API_KEY)")
+	token := fs.String("token", getenv("QUICKSERVE_TOKEN"), "bearer token to authenticate with (env QUICKSERVE_TOKEN)")
+	timeout := fs.Duration("timeout", 5*time.Minute, "how long the whole seed may take")
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "usage: quickserve seed [flags] <file>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	seed, err := quickserve.LoadSeed(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	opts := []client.Option{}
+	if *apiKey != "" {
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	}
+	if *token != "" {
+		opts = append(opts, client.WithBearerToken(*token))
+	}
+	c, err := client.New(*serverURL, opts...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	existing, err := c.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]bool, len(existing))
+	for _, u := range existing {
+		taken[u.Email] = true
+	}
+
+	created := 0
+	for _, su := range seed.Users {
+		if taken[su.Email] {
+			continue
+		}
+		// Users join the tenant of the request creating them
+		tc := c
+		if su.Tenant != "" {
+			tenant := su.Tenant
+			tc, _ = client.New(*serverURL, append(opts, client.WithAuth(func(r *http.Request) error {
+				r.Header.Set(quickserve.TenantHeader, tenant)
+				return nil
+			}))...)
+		}
+		_, err := tc.CreateUser(ctx, client.CreateUserRequest{
+			Name: su.Name, Email: su.Email, Role: su.Role, Locale: su.Locale, Password: su.Password,
+		})
+		if err != nil {
+			return fmt.Errorf("user %s: %w (created %d before it)", su.Email, err, created)
+		}
+		created++
+	}
+	fmt.Fprintf(stdout, "created %d users, %d already existed\n", created, len(seed.Users)-created)
+	return nil
+}
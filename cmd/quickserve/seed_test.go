@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/quicktest"
+)
+
+func TestSeedCommand(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t, quickserve.WithAPIKeyAuth("admin-secret")).WithAPIKey("admin-secret")
+	env := map[string]string{"QUICKSERVE_URL": srv.URL(), "QUICKSERVE_API_KEY": "admin-secret"}
+	run := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		err := seedMain(context.Background(), args, &stdout, &bytes.Buffer{}, func(k string) string { return env[k] })
+		return stdout.String(), err
+	}
+
+	if out, err := run("../../fixtures/testdata/users.json"); err != nil || out != "created 7 users, 0 already existed\n" {
+		t.Fatalf("expected every user created, got %q, %v", out, err)
+	}
+	if out, err := run("../../fixtures/testdata/users.json"); err != nil || out != "created 0 users, 7 already existed\n" {
+		t.Errorf("expected seeding again to create nothing, got %q, %v", out, err)
+	}
+
+	var stdout bytes.Buffer
+	usersMain(context.Background(), []string{"list", "-o", "json"}, &stdout, &bytes.Buffer{}, func(k string) string { return env[k] })
+	if !bytes.Contains(stdout.Bytes(), []byte(`"tenant": "globex"`)) {
+		t.Errorf("expected tenant users to join their tenant, got %s", stdout.String())
+	}
+}
+
+func TestSeedCommandUsage(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, args := range [][]string{{}, {"a.json", "b.json"}, {"-bogus"}} {
+		err := seedMain(context.Background(), args, &bytes.Buffer{}, &bytes.Buffer{}, func(string) string { return "" })
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%q: expected usage, got %v", args, err)
+		}
+	}
+	if err := seedMain(context.Background(), []string{"missing.json"}, &bytes.Buffer{}, &bytes.Buffer{}, func(string) string { return "" }); err == nil {
+		t.Error("expected a missing file to fail")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/client"
+)
+
+// defaultServerURL is the server users commands call unless told
+// otherwise; it matches the default listen address
+const defaultServerURL = "http://localhost:8080"
+
+const usersUsage = `usage: quickserve users <command> [flags] [id]
+
+Commands:
+  list            list every user
+  get <id>        show one user
+  create          create a user from -name, -email, -role and -locale
+  delete <id>     delete a user
+
+Run quickserve users <command> -h for the flags of a command.
+`
+
+// errUsage reports a command line that could not be run; usage has
+// already been printed
+var errUsage = errors.New("invalid usage")
+
+// usersMain runs `quickserve users`: it manages users on a running server
+// through the client package, printing them as a table or as JSON
+func usersMain(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		fmt.Fprint(stderr, usersUsage)
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("users "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	serverURL := fs.String("server", envOr(getenv, "QUICKSERVE_URL", defaultServerURL), "base URL of the server (env QUICKSERVE_URL)")
+	apiKey := fs.String("api-key", getenv("QUICKSERVE_API_KEY"), "API key to authenticate with (env QUICKSERVE_API_KEY)")
+	token := fs.String("token", getenv("QUICKSERVE_TOKEN"), "bearer token to authenticate with (env QUICKSERVE_TOKEN)")
+	output := fs.String("o", "table", "output format: table or json")
+	timeout := fs.Duration("timeout", 30*time.Second, "how long to wait for the server, retries included")
+
+	var create client.CreateUserRequest
+	positional := "<id>"
+	switch cmd {
+	case "list":
+		positional = ""
+	case "get", "delete":
+	case "create":
+		positional = ""
+		fs.StringVar(&create.Name, "name", "", "display name (required)")
+		fs.StringVar(&create.Email, "email", "", "email address (required)")
+		fs.Var((*roleFlag)(&create.Role), "role", "role: admin or user (default user)")
+		fs.StringVar(&create.Locale, "locale", "", "preferred locale, e.g. de-CH")
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", cmd, usersUsage)
+		return errUsage
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: quickserve users %s [flags] %s\n\nFlags:\n", cmd, positional)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("-o must be table or json, not %q", *output)
+	}
+	wantArgs := 0
+	if positional != "" {
+		wantArgs = 1
+	}
+	if fs.NArg() != wantArgs {
+		fs.Usage()
+		return errUsage
+	}
+
+	opts := []client.Option{}
+	if *apiKey != "" {
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	}
+	if *token != "" {
+		opts = append(opts, client.WithBearerToken(*token))
+	}
+	c, err := client.New(*serverURL, opts...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	var id quickserve.ID
+	if positional != "" {
+		if id, err = quickserve.ParseID(fs.Arg(0)); err != nil {
+			return fmt.Errorf("invalid id %q", fs.Arg(0))
+		}
+	}
+	var users []quickserve.User
+	switch cmd {
+	case "list":
+		users, err = c.ListUsers(ctx)
+	case "get":
+		var user quickserve.User
+		user, err = c.GetUser(ctx, id)
+		users = []quickserve.User{user}
+	case "create":
+		var user quickserve.User
+		user, err = c.CreateUser(ctx, create)
+		users = []quickserve.User{user}
+	case "delete":
+		if err = c.DeleteUser(ctx, id); err == nil {
+			fmt.Fprintf(stdout, "deleted user %s\n", id)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if cmd == "list" {
+			return enc.Encode(users)
+		}
+		return enc.Encode(users[0])
+	}
+	return writeUserTable(stdout, users)
+}
+
+// writeUserTable prints users one per line under a header
+func writeUserTable(w io.Writer, users []quickserve.User) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tROLE\tTENANT\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", u.ID, u.Name, u.Email, u.Role, dash(u.Tenant), u.CreatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+// roleFlag sets a quickserve.Role from a flag; the server checks it
+type roleFlag quickserve.Role
+
+func (r *roleFlag) String() string     { return string(*r) }
+func (r *roleFlag) Set(v string) error { *r = roleFlag(v); return nil }
+
+func dash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
+
+func envOr(getenv func(string) string, key, fallback string) string {
+	if v := getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
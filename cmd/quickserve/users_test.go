@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/quicktest"
+)
+
+func TestUsersCommand(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t, quickserve.WithAPIKeyAuth("admin-secret"))
+	env := map[string]string{"QUICKSERVE_URL": srv.URL(), "QUICKSERVE_API_KEY": "admin-secret"}
+	run := func(args ...string) (string, string, error) {
+		var stdout, stderr bytes.Buffer
+		err := usersMain(context.Background(), args, &stdout, &stderr, func(k string) string { return env[k] })
+		return stdout.String(), stderr.String(), err
+	}
+
+	out, _, err := run("create", "-name", "Alice", "-email", "alice@test.com", "-role", "admin", "-o", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var alice quickserve.User
+	if err := json.Unmarshal([]byte(out), &alice); err != nil || alice.Name != "Alice" || alice.Role != quickserve.RoleAdmin {
+		t.Fatalf("expected Alice as JSON, got %q, %v", out, err)
+	}
+
+	out, _, err = run("list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "ID") || !strings.Contains(lines[1], "alice@test.com") {
+		t.Errorf("expected a header and one row, got %q", out)
+	}
+	if out, _, err = run("get", alice.ID.String()); err != nil || !strings.Contains(out, "Alice") {
+		t.Errorf("expected Alice's row, got %q, %v", out, err)
+	}
+
+	if out, _, err = run("delete", alice.ID.String()); err != nil || out != "deleted user "+alice.ID.String()+"\n" {
+		t.Errorf("expected the deletion to be confirmed, got %q, %v", out, err)
+	}
+	if _, _, err = run("get", alice.ID.String()); err == nil || !strings.Contains(err.Error(), "user not found") {
+		t.Errorf("expected the server's 404, got %v", err)
+	}
+
+	if _, _, err = run("list", "-api-key", "wrong"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected the flag to win over the environment, got %v", err)
+	}
+}
+
+func TestUsersCommandUsage(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, args := range [][]string{{}, {"rename"}, {"get"}, {"list", "extra"}, {"list", "-bogus"}} {
+		var stderr bytes.Buffer
+		err := usersMain(context.Background(), args, &bytes.Buffer{}, &stderr, func(string) string { return "" })
+		if !errors.Is(err, errUsage) || !strings.Contains(stderr.String(), "usage:") {
+			t.Errorf("%q: expected usage, got %v and %q", args, err, stderr.String())
+		}
+	}
+	if err := usersMain(context.Background(), []string{"list", "-o", "yaml"}, &bytes.Buffer{}, &bytes.Buffer{}, func(string) string { return "" }); err == nil {
+		t.Error("expected an unknown output format to be refused")
+	}
+}
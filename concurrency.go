@@ -0,0 +1,67 @@
+package quickserve
+
+import (
+	"net/http"
+	"time"
+)
+
+// defaultQueueTimeout is how long a request waits for a slot when the
+// in-flight limit is reached, unless configured
+const defaultQueueTimeout = 100 * time.Millisecond
+
+// ConcurrencyConfig caps how many requests are handled at once
+type ConcurrencyConfig struct {
+	// MaxInFlight is the most requests handled at once; zero means no
+	// limit
+	MaxInFlight int `yaml:"max_in_flight"`
+	// QueueTimeout is how long a request over the limit waits for a slot
+	// before it is refused
+	QueueTimeout time.Duration `yaml:"queue_timeout"`
+}
+
+// WithConcurrencyLimit handles at most max requests at once. Requests
+// over the limit wait up to queueTimeout, or defaultQueueTimeout if zero,
+// for another to finish, and then answer 503.
+func WithConcurrencyLimit(max int, queueTimeout time.Duration) Option {
+	return func(s *Server) {
+		if queueTimeout <= 0 {
+			queueTimeout = defaultQueueTimeout
+		}
+		s.inFlight = make(chan struct{}, max)
+		s.queueTimeout = queueTimeout
+	}
+}
+
+// limitConcurrency holds each request to a slot of s.inFlight, so a burst
+// queues briefly and is then shed instead of piling up handlers, and the
+// memory their responses take. Health checks and event streams, which
+// stay open, take no slot.
+func (s *Server) limitConcurrency(next http.Handler) http.Handler {
+	if s.inFlight == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHealthPath(r.URL.Path) || isWebSocketUpgrade(r) || isEventStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case s.inFlight <- struct{}{}:
+		default:
+			t := time.NewTimer(s.queueTimeout)
+			defer t.Stop()
+			select {
+			case s.inFlight <- struct{}{}:
+			case <-t.C:
+				setRetryAfter(w.Header(), time.Second)
+				httpError(w, r, "server busy", http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-s.inFlight }()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithConcurrencyLimit(1, 100*time.Millisecond))
+	started, release := make(chan struct{}), make(chan struct{})
+	h := server.limitConcurrency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+	}))
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		do("/slow")
+	}()
+	<-started
+
+	// With the only slot taken, a request waits out the queue and is shed
+	w := do("/users")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 503 with Retry-After, got %d: %v", w.Code, w.Header())
+	}
+	if w := do("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("expected health checks to take no slot, got %d", w.Code)
+	}
+
+	// A request queued when the slot frees up gets it
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		close(release)
+	}()
+	if w := do("/users"); w.Code != http.StatusOK {
+		t.Errorf("expected a queued request to be served once the slot frees, got %d", w.Code)
+	}
+	wg.Wait()
+	if n := len(server.inFlight); n != 0 {
+		t.Errorf("expected every slot to be released, %d still taken", n)
+	}
+}
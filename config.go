@@ -0,0 +1,623 @@
+package quickserve
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the quickserve binary. It is built from
+// defaults, then a YAML config file, then QUICKSERVE_* environment
+// variables, then command-line flags, each overriding the one before.
+// Features not listed here are configured with environment variables only.
+type Config struct {
+	// Addr is the address the API listens on: host:port, unix:// and a
+	// socket path, or systemd: and the name of a socket systemd passed in
+	Addr string `yaml:"addr"`
+	// SocketMode is the octal file mode of unix sockets
+	SocketMode string `yaml:"socket_mode"`
+	// AgentCheckAddr serves HAProxy agent-checks when set
+	AgentCheckAddr string `yaml:"agent_check_addr"`
+	// PprofAddr serves /debug/pprof when set; keep it private
+	PprofAddr string `yaml:"pprof_addr"`
+	// GRPCAddr serves the gRPC UserService when set, with the same TLS
+	// settings as Addr
+	GRPCAddr string `yaml:"grpc_addr"`
+	// GRPC also serves the gRPC UserService on the API listeners, next to
+	// REST; plain HTTP ones need H2C
+	GRPC bool `yaml:"grpc"`
+	// Listeners serve the API on more addresses, each with its own TLS
+	// settings; they are set in the config file only
+	Listeners []ListenerConfig `yaml:"listeners"`
+	// H2C serves HTTP/2 without TLS on the listeners serving plain HTTP
+	H2C bool `yaml:"h2c"`
+	// Docs serves the API explorer at /docs/
+	Docs bool `yaml:"docs"`
+
+	// RateLimit limits requests per client; a zero rate means no limit,
+	// and a zero burst allows one second's worth
+	RateLimit RateLimit `yaml:"rate_limit"`
+	// Concurrency caps requests handled at once, across clients
+	Concurrency ConcurrencyConfig `yaml:"concurrency"`
+	// LoadShedding rejects requests by route priority when saturated
+	LoadShedding LoadSheddingConfig `yaml:"load_shedding"`
+	// LeakCheck watches the process for goroutine and heap leaks
+	LeakCheck LeakCheckConfig `yaml:"leak_check"`
+	// CacheControl maps route patterns, such as "GET /users", and modules,
+	// such as admin, to the Cache-Control of their successful responses;
+	// it is set in the config file only
+	CacheControl map[string]string `yaml:"cache_control"`
+	// IPAccess restricts route groups, such as admin, by client address
+	IPAccess map[string]IPAccessConfig `yaml:"ip_access"`
+
+	Timeouts TimeoutConfig `yaml:"timeouts"`
+	Store    StoreConfig   `yaml:"store"`
+	TLS      TLSConfig     `yaml:"tls"`
+	Log      LogConfig     `yaml:"log"`
+	// Kafka produces user events to a topic when brokers are set
+	Kafka KafkaConfig `yaml:"kafka"`
+	// NATS exchanges user events with other instances when a URL is set
+	NATS NATSConfig `yaml:"nats"`
+	// Tenancy isolates tenants when sources are set
+	Tenancy TenancyConfig `yaml:"tenancy"`
+}
+
+// IPAccessConfig lists the address ranges, in CIDR notation or as bare
+// addresses, allowed and denied on a route group
+type IPAccessConfig struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+// ListenerConfig is an additional address serving the API. Without a
+// certificate it serves plain HTTP.
+type ListenerConfig struct {
+	Addr string `yaml:"addr"`
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// ClientCA requires client certificates signed by the CA bundle at
+	// this path
+	ClientCA string `yaml:"client_ca"`
+}
+
+// TimeoutConfig bounds how long connections, requests and shutdown take.
+// Zero means no limit, except for Drain.
+type TimeoutConfig struct {
+	ReadHeader time.Duration `yaml:"read_header"`
+	Read       time.Duration `yaml:"read"`
+	Write      time.Duration `yaml:"write"`
+	Idle       time.Duration `yaml:"idle"`
+	// Handler is the time a handler gets before the request fails with 503
+	Handler time.Duration `yaml:"handler"`
+	// MaxRequestDeadline caps X-Request-Deadline
+	MaxRequestDeadline time.Duration `yaml:"max_request_deadline"`
+	// DrainDelay is how long requests are still accepted after SIGTERM
+	// while readiness fails
+	DrainDelay time.Duration `yaml:"drain_delay"`
+	// Drain is how long in-flight requests get to finish on shutdown
+	Drain time.Duration `yaml:"drain"`
+}
+
+// StoreConfig selects where data is kept
+type StoreConfig struct {
+	// Backend holds users; only "memory" is available
+	Backend string `yaml:"backend"`
+	// AuditFile persists the audit log when set
+	AuditFile string `yaml:"audit_file"`
+	// TenantSettingsFile persists tenant settings when set
+	TenantSettingsFile string `yaml:"tenant_settings_file"`
+	// CacheSize is how many responses of user reads are cached in
+	// memory; zero disables the cache
+	CacheSize int `yaml:"cache_size"`
+	// Seed is a seed file of users created at startup unless their email
+	// is taken
+	Seed string `yaml:"seed"`
+	// Search answers GET /users/search from the store's index ("store")
+	// or from a Bleve index matching typos too ("bleve")
+	Search string `yaml:"search"`
+	// SearchDir keeps the Bleve index on disk instead of in memory
+	SearchDir string `yaml:"search_dir"`
+	// PostsOnUserDelete is "cascade" to delete a user's posts with them
+	// or "block" to refuse deleting users who have posts
+	PostsOnUserDelete string `yaml:"posts_on_user_delete"`
+}
+
+// TenancyConfig names where a request's tenant comes from
+type TenancyConfig struct {
+	// Sources are "header", "subdomain" and "claim", tried in order
+	Sources []string `yaml:"sources"`
+	// Domain is the base domain whose subdomains name tenants
+	Domain string `yaml:"domain"`
+}
+
+// TLSConfig enables HTTPS with a certificate pair or ACME
+type TLSConfig struct {
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+	// ClientCA enables mutual TLS with the CA bundle at this path
+	ClientCA string `yaml:"client_ca"`
+	// RedirectAddr serves a plain HTTP listener redirecting to HTTPS
+	RedirectAddr string `yaml:"redirect_addr"`
+	// ACMEDomains obtains certificates from Let's Encrypt; exclusive with
+	// Cert and Key
+	ACMEDomains []string `yaml:"acme_domains"`
+	ACMECache   string   `yaml:"acme_cache"`
+	ACMEEmail   string   `yaml:"acme_email"`
+	// HTTP3 also serves HTTP/3 over QUIC, on the UDP port of the same
+	// number; experimental
+	HTTP3 bool `yaml:"http3"`
+}
+
+// LogConfig configures the logger and the access log
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is text or json
+	Format string `yaml:"format"`
+	Access bool   `yaml:"access"`
+	// AccessSkip lists paths left out of the access log
+	AccessSkip []string `yaml:"access_skip"`
+}
+
+// DefaultConfig returns the configuration used when nothing is set
+func DefaultConfig() Config {
+	return Config{
+		Addr:       ":8080",
+		SocketMode: defaultSocketMode,
+		Docs:       true,
+		Timeouts:   TimeoutConfig{Drain: defaultDrainTimeout},
+		Store:      StoreConfig{Backend: "memory", Search: "store", PostsOnUserDelete: string(PostsCascade)},
+		TLS:        TLSConfig{ACMECache: defaultACMECache},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     "text",
+			Access:     true,
+			AccessSkip: slices.Clone(defaultAccessLogSkip),
+		},
+	}
+}
+
+// configField is a setting that can be given as a flag and an environment
+// variable. field returns a *string, *bool, *int, *float64,
+// *time.Duration or *[]string into the Config.
+type configField struct {
+	flag, env, usage string
+	field            func(c *Config) any
+}
+
+var configFields = []configField{
+	{"addr", "QUICKSERVE_ADDR", "address to listen on: host:port, unix:///path/to.sock or systemd:NAME", func(c *Config) any { return &c.Addr }},
+	{"listen", "", "alias of -addr", func(c *Config) any { return &c.Addr }},
+	{"socket-mode", "QUICKSERVE_SOCKET_MODE", "octal file mode of unix sockets", func(c *Config) any { return &c.SocketMode }},
+	{"docs", "QUICKSERVE_DOCS", "serve the interactive API explorer at /docs/", func(c *Config) any { return &c.Docs }},
+	{"h2c", "QUICKSERVE_H2C", "serve HTTP/2 without TLS (h2c) on plain HTTP listeners", func(c *Config) any { return &c.H2C }},
+	{"agent-check-addr", "QUICKSERVE_AGENT_CHECK_ADDR", "address serving HAProxy agent-checks", func(c *Config) any { return &c.AgentCheckAddr }},
+	{"pprof-addr", "QUICKSERVE_PPROF_ADDR", "address of a separate listener serving /debug/pprof profiles; keep it private", func(c *Config) any { return &c.PprofAddr }},
+	{"grpc-addr", "QUICKSERVE_GRPC_ADDR", "address of a separate listener serving the gRPC UserService", func(c *Config) any { return &c.GRPCAddr }},
+	{"grpc", "QUICKSERVE_GRPC", "also serve the gRPC UserService on the API listeners; needs TLS or -h2c", func(c *Config) any { return &c.GRPC }},
+
+	{"rate-limit", "QUICKSERVE_RATE_LIMIT", "requests per second allowed per client; 0 for no limit", func(c *Config) any { return &c.RateLimit.Rate }},
+	{"rate-burst", "QUICKSERVE_RATE_BURST", "requests a client may burst above the rate; default one second's worth", func(c *Config) any { return &c.RateLimit.Burst }},
+	{"max-in-flight", "QUICKSERVE_MAX_IN_FLIGHT", "requests handled at once before others queue and then get 503; 0 for no limit", func(c *Config) any { return &c.Concurrency.MaxInFlight }},
+	{"queue-timeout", "QUICKSERVE_QUEUE_TIMEOUT", "how long a request over -max-in-flight waits for a slot", func(c *Config) any { return &c.Concurrency.QueueTimeout }},
+	{"shed-latency", "QUICKSERVE_SHED_LATENCY", "p99 handler latency over which low-priority requests are shed; 0 to ignore latency", func(c *Config) any { return &c.LoadShedding.MaxLatency }},
+	{"shed-goroutines", "QUICKSERVE_SHED_GOROUTINES", "goroutine count over which low-priority requests are shed; 0 to ignore it", func(c *Config) any { return &c.LoadShedding.MaxGoroutines }},
+	{"leak-check-interval", "QUICKSERVE_LEAK_CHECK_INTERVAL", "how often to check for goroutine and heap leaks, reported at /debug/leaks on -pprof-addr; 0 disables the checks", func(c *Config) any { return &c.LeakCheck.Interval }},
+	{"leak-max-goroutine-growth", "QUICKSERVE_LEAK_MAX_GOROUTINE_GROWTH", "goroutines added since the first leak check over which a leak is reported; 0 to ignore them", func(c *Config) any { return &c.LeakCheck.MaxGoroutineGrowth }},
+	{"leak-max-heap-growth-mb", "QUICKSERVE_LEAK_MAX_HEAP_GROWTH_MB", "live heap MiB added since the first leak check over which a leak is reported; 0 to ignore it", func(c *Config) any { return &c.LeakCheck.MaxHeapGrowthMB }},
+
+	{"read-header-timeout", "QUICKSERVE_READ_HEADER_TIMEOUT", "how long a client gets to send request headers", func(c *Config) any { return &c.Timeouts.ReadHeader }},
+	{"read-timeout", "QUICKSERVE_READ_TIMEOUT", "how long a client gets to send a whole request", func(c *Config) any { return &c.Timeouts.Read }},
+	{"write-timeout", "QUICKSERVE_WRITE_TIMEOUT", "how long writing a response may take", func(c *Config) any { return &c.Timeouts.Write }},
+	{"idle-timeout", "QUICKSERVE_IDLE_TIMEOUT", "how long idle keep-alive connections are kept", func(c *Config) any { return &c.Timeouts.Idle }},
+	{"handler-timeout", "QUICKSERVE_HANDLER_TIMEOUT", "how long a handler gets before the request fails with 503", func(c *Config) any { return &c.Timeouts.Handler }},
+	{"max-request-deadline", "QUICKSERVE_MAX_REQUEST_DEADLINE", "cap on X-Request-Deadline", func(c *Config) any { return &c.Timeouts.MaxRequestDeadline }},
+	{"drain-delay", "QUICKSERVE_DRAIN_DELAY", "how long to keep accepting requests after SIGTERM while readiness fails, so load balancers notice", func(c *Config) any { return &c.Timeouts.DrainDelay }},
+	{"drain-timeout", "QUICKSERVE_DRAIN_TIMEOUT", "how long in-flight requests get to finish on shutdown", func(c *Config) any { return &c.Timeouts.Drain }},
+
+	{"store", "QUICKSERVE_STORE", "user store backend: memory", func(c *Config) any { return &c.Store.Backend }},
+	{"audit-file", "QUICKSERVE_AUDIT_FILE", "file persisting the audit log", func(c *Config) any { return &c.Store.AuditFile }},
+	{"tenant-settings-file", "QUICKSERVE_TENANT_SETTINGS_FILE", "file persisting tenant settings", func(c *Config) any { return &c.Store.TenantSettingsFile }},
+	{"cache-size", "QUICKSERVE_CACHE_SIZE", "responses of GET /users and GET /users/{id} cached in memory; 0 disables the cache", func(c *Config) any { return &c.Store.CacheSize }},
+	{"seed", "QUICKSERVE_SEED", "JSON file of users to create at startup; users whose email exists are skipped", func(c *Config) any { return &c.Store.Seed }},
+	{"search", "QUICKSERVE_SEARCH", "user search backend: store, or bleve for fuzzy matching", func(c *Config) any { return &c.Store.Search }},
+	{"search-dir", "QUICKSERVE_SEARCH_DIR", "directory of the Bleve search index, rebuilt at startup; in memory when empty", func(c *Config) any { return &c.Store.SearchDir }},
+	{"posts-on-user-delete", "QUICKSERVE_POSTS_ON_USER_DELETE", "what deleting a user who has posts does: cascade deletes them, block refuses with 409", func(c *Config) any { return &c.Store.PostsOnUserDelete }},
+
+	{"tenant-sources", "QUICKSERVE_TENANT_SOURCES", "comma-separated sources of a request's tenant, tried in order: header, subdomain or claim; isolates tenants when set", func(c *Config) any { return &c.Tenancy.Sources }},
+	{"tenant-domain", "QUICKSERVE_TENANT_DOMAIN", "base domain whose subdomains name tenants, for the subdomain source", func(c *Config) any { return &c.Tenancy.Domain }},
+
+	{"tls-cert", "QUICKSERVE_TLS_CERT", "TLS certificate file (PEM); enables HTTPS", func(c *Config) any { return &c.TLS.Cert }},
+	{"tls-key", "QUICKSERVE_TLS_KEY", "TLS private key file (PEM)", func(c *Config) any { return &c.TLS.Key }},
+	{"tls-client-ca", "QUICKSERVE_TLS_CLIENT_CA", "CA bundle (PEM) for verifying client certificates; enables mutual TLS", func(c *Config) any { return &c.TLS.ClientCA }},
+	{"http-redirect-addr", "QUICKSERVE_HTTP_REDIRECT_ADDR", "address of a plain HTTP listener that redirects to HTTPS", func(c *Config) any { return &c.TLS.RedirectAddr }},
+	{"acme-domain", "QUICKSERVE_ACME_DOMAIN", "comma-separated domains to obtain certificates for from Let's Encrypt; enables HTTPS", func(c *Config) any { return &c.TLS.ACMEDomains }},
+	{"acme-cache", "QUICKSERVE_ACME_CACHE", "directory for ACME certificates and account key", func(c *Config) any { return &c.TLS.ACMECache }},
+	{"acme-email", "QUICKSERVE_ACME_EMAIL", "contact email for the ACME account", func(c *Config) any { return &c.TLS.ACMEEmail }},
+	{"http3", "QUICKSERVE_HTTP3", "experimental: also serve HTTP/3 over QUIC on the UDP port of the HTTPS address", func(c *Config) any { return &c.TLS.HTTP3 }},
+
+	{"kafka-brokers", "QUICKSERVE_KAFKA_BROKERS", "comma-separated Kafka brokers (host:port) to produce user events to", func(c *Config) any { return &c.Kafka.Brokers }},
+	{"kafka-topic", "QUICKSERVE_KAFKA_TOPIC", "Kafka topic receiving user events", func(c *Config) any { return &c.Kafka.Topic }},
+	{"nats-url", "QUICKSERVE_NATS_URL", "comma-separated NATS server URLs to exchange user events with other instances over", func(c *Config) any { return &c.NATS.URL }},
+	{"nats-subject-prefix", "QUICKSERVE_NATS_SUBJECT_PREFIX", "first token of the NATS subjects user events are published to", func(c *Config) any { return &c.NATS.SubjectPrefix }},
+
+	{"log-level", "QUICKSERVE_LOG_LEVEL", "minimum log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"log-format", "QUICKSERVE_LOG_FORMAT", "log format: text or json", func(c *Config) any { return &c.Log.Format }},
+	{"access-log", "QUICKSERVE_ACCESS_LOG", "write every request to the access log", func(c *Config) any { return &c.Log.Access }},
+	{"access-log-skip", "QUICKSERVE_ACCESS_LOG_SKIP", "comma-separated paths left out of the access log; a trailing / skips everything below", func(c *Config) any { return &c.Log.AccessSkip }},
+}
+
+// LoadConfig builds the configuration from args, the command-line flags
+// without the program name, and environ, the environment as os.Environ
+// returns it. The config file is named by -config or QUICKSERVE_CONFIG.
+// Empty variables are ignored, except that an empty list clears it. -h
+// reports flag.ErrHelp after printing usage to stderr.
+func LoadConfig(args []string, environ []string) (Config, error) {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+
+	// The file is read before flags are applied, so find its path first
+	var path string
+	var scratch Config
+	pre := newConfigFlagSet(&scratch, &path)
+	pre.SetOutput(io.Discard)
+	pre.Usage = func() {}
+	if err := pre.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return Config{}, err
+	}
+	if path == "" {
+		path = env["QUICKSERVE_CONFIG"]
+	}
+
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, f := range configFields {
+		if f.env == "" {
+			continue
+		}
+		v, ok := env[f.env]
+		if _, list := f.field(&cfg).(*[]string); !ok || v == "" && !list {
+			continue
+		}
+		if err := setConfigField(f.field(&cfg), v); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", f.env, err)
+		}
+	}
+	cfg.ipAccessFromEnv(env)
+	fs := newConfigFlagSet(&cfg, &path)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments %q", fs.Args())
+	}
+	return cfg, cfg.Validate()
+}
+
+// ipAccessFromEnv applies QUICKSERVE_IP_ALLOW_<GROUP> and
+// QUICKSERVE_IP_DENY_<GROUP>, e.g. QUICKSERVE_IP_ALLOW_ADMIN=10.8.0.0/16
+// for the admin group. Each replaces the list the file gave the group.
+func (c *Config) ipAccessFromEnv(env map[string]string) {
+	for name, value := range env {
+		for prefix, deny := range map[string]bool{"QUICKSERVE_IP_ALLOW_": false, "QUICKSERVE_IP_DENY_": true} {
+			group, ok := strings.CutPrefix(name, prefix)
+			if !ok || group == "" {
+				continue
+			}
+			if c.IPAccess == nil {
+				c.IPAccess = make(map[string]IPAccessConfig)
+			}
+			group = strings.ToLower(group)
+			rule := c.IPAccess[group]
+			var list []string
+			setConfigField(&list, value)
+			if deny {
+				rule.Deny = list
+			} else {
+				rule.Allow = list
+			}
+			c.IPAccess[group] = rule
+		}
+	}
+}
+
+// newConfigFlagSet binds a flag to every field of cfg, with the current
+// values as defaults, and -config to path
+func newConfigFlagSet(cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("quickserve", flag.ContinueOnError)
+	fs.StringVar(path, "config", *path, "YAML config file; QUICKSERVE_* variables and flags override it")
+	for _, f := range configFields {
+		usage := f.usage
+		if f.env != "" {
+			usage += " (" + f.env + ")"
+		}
+		fs.Var(configValue{f.field(cfg)}, f.flag, usage)
+	}
+	return fs
+}
+
+// loadFile applies the YAML file at path over c. Unknown keys are errors,
+// so typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports settings that are invalid or do not go together
+func (c Config) Validate() error {
+	var errs []error
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must be set"))
+	}
+	if _, err := c.socketMode(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := NewLogger(io.Discard, c.Log.Format, slog.LevelInfo); err != nil {
+		errs = append(errs, err)
+	}
+	if c.RateLimit.Rate < 0 || c.RateLimit.Burst < 0 {
+		errs = append(errs, errors.New("rate limit and burst must not be negative"))
+	}
+	if c.Concurrency.MaxInFlight < 0 || c.Concurrency.QueueTimeout < 0 {
+		errs = append(errs, errors.New("max in flight and queue timeout must not be negative"))
+	}
+	if c.LoadShedding.MaxLatency < 0 || c.LoadShedding.MaxGoroutines < 0 {
+		errs = append(errs, errors.New("shed latency and goroutines must not be negative"))
+	}
+	if lc := c.LeakCheck; lc.Interval < 0 || lc.MaxGoroutineGrowth < 0 || lc.MaxHeapGrowthMB < 0 {
+		errs = append(errs, errors.New("leak check interval and limits must not be negative"))
+	}
+	for pattern, p := range c.LoadShedding.Priorities {
+		if !p.valid() {
+			errs = append(errs, fmt.Errorf("priority %q of %s must be low, normal or critical", p, pattern))
+		}
+	}
+	if _, err := c.LiveSettings(); err != nil {
+		errs = append(errs, err)
+	}
+	for target, value := range c.CacheControl {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("cache control of %s must not be empty", target))
+		} else if _, _, err := parseMaxAge(value); err != nil {
+			errs = append(errs, fmt.Errorf("cache control of %s: %w", target, err))
+		}
+	}
+	if c.Store.CacheSize < 0 {
+		errs = append(errs, errors.New("cache size must not be negative"))
+	}
+	if c.Store.Seed != "" {
+		if _, err := LoadSeed(c.Store.Seed); err != nil {
+			errs = append(errs, fmt.Errorf("seed: %w", err))
+		}
+	}
+	if c.Store.Backend != "memory" {
+		errs = append(errs, fmt.Errorf("unknown store backend %q, want memory", c.Store.Backend))
+	}
+	switch c.Store.Search {
+	case "store":
+		if c.Store.SearchDir != "" {
+			errs = append(errs, errors.New("search dir requires the bleve search backend"))
+		}
+	case "bleve":
+	default:
+		errs = append(errs, fmt.Errorf("unknown search backend %q, want store or bleve", c.Store.Search))
+	}
+	if !PostsOnUserDelete(c.Store.PostsOnUserDelete).Valid() {
+		errs = append(errs, fmt.Errorf("unknown posts on user delete %q, want cascade or block", c.Store.PostsOnUserDelete))
+	}
+	for _, src := range c.Tenancy.Sources {
+		if !TenantSource(src).Valid() {
+			errs = append(errs, fmt.Errorf("unknown tenant source %q, want header, subdomain or claim", src))
+		}
+	}
+	if slices.Contains(c.Tenancy.Sources, string(TenantFromSubdomain)) && c.Tenancy.Domain == "" {
+		errs = append(errs, errors.New("the subdomain tenant source requires a tenant domain"))
+	}
+	if c.Timeouts.Drain <= 0 {
+		errs = append(errs, errors.New("drain timeout must be positive"))
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, errors.New("tls cert and key must be given together"))
+	}
+	if len(c.TLS.ACMEDomains) > 0 && c.TLS.Cert != "" {
+		errs = append(errs, errors.New("acme domains and a tls cert are mutually exclusive"))
+	}
+	if c.TLS.ClientCA != "" && !c.UseTLS() {
+		errs = append(errs, errors.New("tls client ca requires a tls cert or acme domains"))
+	}
+	if c.TLS.HTTP3 {
+		if _, _, err := net.SplitHostPort(c.Addr); err != nil || !c.UseTLS() {
+			errs = append(errs, errors.New("http3 requires https on a host:port address"))
+		}
+	}
+	addrs := map[string]bool{c.Addr: true}
+	for i, l := range c.Listeners {
+		switch {
+		case l.Addr == "":
+			errs = append(errs, fmt.Errorf("listeners[%d]: addr must be set", i))
+		case addrs[l.Addr]:
+			errs = append(errs, fmt.Errorf("listeners[%d]: %s is listened on twice", i, l.Addr))
+		}
+		addrs[l.Addr] = true
+		if (l.Cert == "") != (l.Key == "") {
+			errs = append(errs, fmt.Errorf("listeners[%d]: cert and key must be given together", i))
+		}
+		if l.ClientCA != "" && l.Cert == "" {
+			errs = append(errs, fmt.Errorf("listeners[%d]: client ca requires a cert", i))
+		}
+	}
+	if c.GRPC && !c.UseTLS() && !c.H2C {
+		errs = append(errs, errors.New("grpc on the API listeners requires tls or h2c"))
+	}
+	if c.GRPCAddr != "" && addrs[c.GRPCAddr] {
+		errs = append(errs, fmt.Errorf("grpc addr %s is already serving HTTP", c.GRPCAddr))
+	}
+	if strings.ContainsAny(c.NATS.SubjectPrefix, " \t*>") {
+		errs = append(errs, fmt.Errorf("nats subject prefix %q must not contain spaces or wildcards", c.NATS.SubjectPrefix))
+	}
+	if len(c.Kafka.Brokers) > 0 && c.Kafka.Topic == "" {
+		errs = append(errs, errors.New("kafka brokers require a topic"))
+	}
+	for _, b := range c.Kafka.Brokers {
+		if _, _, err := net.SplitHostPort(b); err != nil {
+			errs = append(errs, fmt.Errorf("kafka broker %q must be host:port", b))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LiveSettings returns the settings a running server can reload
+func (c Config) LiveSettings() (LiveSettings, error) {
+	level, err := ParseLogLevel(c.Log.Level)
+	if err != nil {
+		return LiveSettings{}, err
+	}
+	ls := LiveSettings{LogLevel: level}
+	if c.RateLimit.Rate > 0 {
+		limit := c.RateLimit
+		if limit.Burst == 0 {
+			limit.Burst = int(math.Ceil(limit.Rate))
+		}
+		ls.RateLimit = &limit
+	}
+	for group, rule := range c.IPAccess {
+		allow, err := ParseCIDRs(strings.Join(rule.Allow, ","))
+		if err != nil {
+			return LiveSettings{}, fmt.Errorf("ip access for %s: %w", group, err)
+		}
+		deny, err := ParseCIDRs(strings.Join(rule.Deny, ","))
+		if err != nil {
+			return LiveSettings{}, fmt.Errorf("ip access for %s: %w", group, err)
+		}
+		if ls.IPAccess == nil {
+			ls.IPAccess = make(map[string]IPAccess)
+		}
+		ls.IPAccess[group] = IPAccess{Allow: allow, Deny: deny}
+	}
+	return ls, nil
+}
+
+// socketMode parses SocketMode
+func (c Config) socketMode() (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(c.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q, want octal permissions such as 0660", c.SocketMode)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// UseTLS reports whether the API is served over HTTPS
+func (c Config) UseTLS() bool {
+	return c.TLS.Cert != "" || len(c.TLS.ACMEDomains) > 0
+}
+
+// setConfigField parses v into the field p points to. Lists are
+// comma-separated, and booleans also accept on and off.
+func setConfigField(p any, v string) error {
+	switch p := p.(type) {
+	case *string:
+		*p = v
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *float64:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		*p = f
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = n
+	case *bool:
+		switch strings.ToLower(v) {
+		case "on":
+			*p = true
+		case "off":
+			*p = false
+		default:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			*p = b
+		}
+	case *[]string:
+		*p = nil
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				*p = append(*p, s)
+			}
+		}
+	default:
+		panic(fmt.Sprintf("config field of unsupported type %T", p))
+	}
+	return nil
+}
+
+// configValue adapts a Config field to flag.Value
+type configValue struct {
+	p any
+}
+
+func (v configValue) String() string {
+	switch p := v.p.(type) {
+	case *string:
+		return *p
+	case *time.Duration:
+		return p.String()
+	case *float64:
+		return strconv.FormatFloat(*p, 'g', -1, 64)
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *[]string:
+		return strings.Join(*p, ",")
+	}
+	return ""
+}
+
+func (v configValue) Set(s string) error {
+	return setConfigField(v.p, s)
+}
+
+func (v configValue) IsBoolFlag() bool {
+	_, ok := v.p.(*bool)
+	return ok
+}
@@ -0,0 +1,188 @@
+package quickserve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func environ(env map[string]string) []string {
+	var kvs []string
+	for k, v := range env {
+		kvs = append(kvs, k+"="+v)
+	}
+	return kvs
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "quickserve.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	cfg, err := LoadConfig(nil, environ(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("expected the defaults, got %+v", cfg)
+	}
+	if cfg.Addr != ":8080" || cfg.UseTLS() || !cfg.Log.Access {
+		t.Errorf("expected plain HTTP on :8080 with an access log, got %+v", cfg)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := writeConfigFile(t, `
+addr: ":9000"
+pprof_addr: 127.0.0.1:6060
+timeouts:
+  handler: 5s
+  drain: 1m
+  read_header: 2s
+log:
+  level: debug
+  format: json
+  access_skip: [/metrics]
+`)
+	env := map[string]string{
+		"QUICKSERVE_CONFIG":          path,
+		"QUICKSERVE_ADDR":            ":9100",
+		"QUICKSERVE_DRAIN_TIMEOUT":   "45s",
+		"QUICKSERVE_ACCESS_LOG":      "off",
+		"QUICKSERVE_LOG_LEVEL":       "",
+		"QUICKSERVE_HANDLER_TIMEOUT": "",
+	}
+	cfg, err := LoadConfig([]string{"-addr", ":9200", "-log-level", "warn"}, environ(env))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Addr != ":9200" {
+		t.Errorf("expected the flag to win over env and file, got %q", cfg.Addr)
+	}
+	if cfg.Timeouts.Drain != 45*time.Second {
+		t.Errorf("expected env to win over the file, got %s", cfg.Timeouts.Drain)
+	}
+	if cfg.Timeouts.Handler != 5*time.Second || cfg.Timeouts.ReadHeader != 2*time.Second || cfg.PprofAddr != "127.0.0.1:6060" {
+		t.Errorf("expected file values where nothing overrides them, got %+v", cfg)
+	}
+	if cfg.Log.Level != "warn" || cfg.Log.Format != "json" || cfg.Log.Access {
+		t.Errorf("expected log settings from every layer, got %+v", cfg.Log)
+	}
+	if !reflect.DeepEqual(cfg.Log.AccessSkip, []string{"/metrics"}) {
+		t.Errorf("expected the file's skip list, got %v", cfg.Log.AccessSkip)
+	}
+	if cfg.Store.Backend != "memory" {
+		t.Errorf("expected defaults for unset fields, got %q", cfg.Store.Backend)
+	}
+}
+
+func TestLoadConfigFileFlag(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := writeConfigFile(t, "tls:\n  acme_domains: [api.example.com]\n  client_ca: ca.pem\n")
+	cfg, err := LoadConfig([]string{"-config", path}, environ(map[string]string{"QUICKSERVE_ACCESS_LOG_SKIP": ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.UseTLS() || cfg.TLS.ClientCA != "ca.pem" || cfg.TLS.ACMECache != defaultACMECache {
+		t.Errorf("expected ACME with mutual TLS, got %+v", cfg.TLS)
+	}
+	if len(cfg.Log.AccessSkip) != 0 {
+		t.Errorf("expected an empty variable to clear the skip list, got %v", cfg.Log.AccessSkip)
+	}
+}
+
+func TestLoadConfigListeners(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := writeConfigFile(t, `
+listeners:
+  - addr: 127.0.0.1:9090
+  - addr: unix:///run/quickserve.sock
+  - addr: :8443
+    cert: cert.pem
+    key: key.pem
+    client_ca: ca.pem
+`)
+	cfg, err := LoadConfig([]string{"-config", path}, environ(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ListenerConfig{
+		{Addr: "127.0.0.1:9090"},
+		{Addr: "unix:///run/quickserve.sock"},
+		{Addr: ":8443", Cert: "cert.pem", Key: "key.pem", ClientCA: "ca.pem"},
+	}
+	if !reflect.DeepEqual(cfg.Listeners, want) {
+		t.Errorf("expected the file's listeners, got %+v", cfg.Listeners)
+	}
+	if cfg.UseTLS() {
+		t.Error("expected a listener's certificate to leave the main listener on plain HTTP")
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown key", "adr: :80\n", nil, nil, "field adr not found"},
+		{"bad duration", "", map[string]string{"QUICKSERVE_DRAIN_TIMEOUT": "soon"}, nil, "QUICKSERVE_DRAIN_TIMEOUT"},
+		{"bad flag", "", nil, []string{"-access-log=maybe"}, "invalid boolean"},
+		{"store backend", "store:\n  backend: postgres\n", nil, nil, `unknown store backend "postgres"`},
+		{"search backend", "", nil, []string{"-search", "solr"}, `unknown search backend "solr"`},
+		{"search dir", "", nil, []string{"-search-dir", "/tmp/search"}, "requires the bleve search backend"},
+		{"posts on user delete", "store:\n  posts_on_user_delete: orphan\n", nil, nil, `unknown posts on user delete "orphan"`},
+		{"tenant source", "", map[string]string{"QUICKSERVE_TENANT_SOURCES": "header,cookie"}, nil, `unknown tenant source "cookie"`},
+		{"tenant domain", "tenancy:\n  sources: [subdomain]\n", nil, nil, "requires a tenant domain"},
+		{"log level", "", nil, []string{"-log-level", "loud"}, "unknown log level"},
+		{"tls pair", "", nil, []string{"-tls-cert", "cert.pem"}, "given together"},
+		{"client ca", "", nil, []string{"-tls-client-ca", "ca.pem"}, "requires a tls cert"},
+		{"socket mode", "", nil, []string{"-listen", "unix:///run/quickserve.sock", "-socket-mode", "rw"}, "invalid socket mode"},
+		{"listener addr", "listeners:\n  - cert: cert.pem\n    key: key.pem\n", nil, nil, "listeners[0]: addr must be set"},
+		{"listener twice", "listeners:\n  - addr: :8080\n", nil, nil, "listened on twice"},
+		{"listener client ca", "listeners:\n  - addr: :9090\n    client_ca: ca.pem\n", nil, nil, "client ca requires a cert"},
+		{"http3", "", nil, []string{"-http3"}, "http3 requires https"},
+		{"grpc without http/2", "", nil, []string{"-grpc"}, "grpc on the API listeners requires tls or h2c"},
+		{"grpc addr", "", nil, []string{"-grpc-addr", ":8080"}, "grpc addr :8080 is already serving HTTP"},
+		{"max in flight", "", nil, []string{"-max-in-flight", "-1"}, "max in flight and queue timeout must not be negative"},
+		{"route priority", "load_shedding:\n  priorities:\n    GET /users: urgent\n", nil, nil, `priority "urgent" of GET /users must be low, normal or critical`},
+		{"cache control", "cache_control:\n  GET /users: public, max-age=soon\n", nil, nil, `cache control of GET /users: invalid max-age "soon"`},
+		{"kafka topic", "kafka:\n  brokers: [kafka:9092]\n", nil, nil, "kafka brokers require a topic"},
+		{"kafka broker", "", nil, []string{"-kafka-brokers", "kafka", "-kafka-topic", "users"}, `kafka broker "kafka" must be host:port`},
+		{"nats subject prefix", "nats:\n  url: nats://nats:4222\n  subject_prefix: users.*\n", nil, nil, "must not contain spaces or wildcards"},
+		{"arguments", "", nil, []string{"serve"}, "unexpected arguments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"QUICKSERVE_CONFIG": writeConfigFile(t, tt.file)}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			_, err := LoadConfig(tt.args, environ(env))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,587 @@
+// Package contract verifies a provider against consumer-driven contracts
+// in the Pact JSON format, so teams calling the API can publish the
+// requests they make and the parts of the responses they rely on, and a
+// plain go test run checks that the API still honors them:
+//
+//	v := &contract.Verifier{Name: "quickserve", Provider: newProvider}
+//	v.VerifyDir(t, "testdata/contracts")
+//
+// Pact specification versions 2 and 3 are read. Of the matching rules,
+// equality, type (with min and max for arrays), regex, integer, decimal
+// and include are supported; bodies must be JSON.
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Contract is a consumer's expectations of a provider
+type Contract struct {
+	Consumer     Party         `json:"consumer"`
+	Provider     Party         `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Party names a consumer or provider
+type Party struct {
+	Name string `json:"name"`
+}
+
+// State is a provider state an interaction needs, e.g. "user 1 exists"
+type State struct {
+	Name   string         `json:"name"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// Interaction is one request and the response the consumer expects
+type Interaction struct {
+	Description string `json:"description"`
+	// ProviderState is the version 2 form of ProviderStates
+	ProviderState  string   `json:"providerState,omitempty"`
+	ProviderStates []State  `json:"providerStates,omitempty"`
+	Request        Request  `json:"request"`
+	Response       Response `json:"response"`
+}
+
+// States returns the provider states of the interaction in either form
+func (i Interaction) States() []State {
+	if i.ProviderState != "" {
+		return append([]State{{Name: i.ProviderState}}, i.ProviderStates...)
+	}
+	return i.ProviderStates
+}
+
+// Request is the request a consumer sends
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query is a query string in version 2 and a map of values in
+	// version 3
+	Query   json.RawMessage   `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Response is what the consumer relies on in the answer. Headers and body
+// fields not listed are not checked.
+type Response struct {
+	Status        int               `json:"status"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          json.RawMessage   `json:"body,omitempty"`
+	MatchingRules json.RawMessage   `json:"matchingRules,omitempty"`
+}
+
+// Load reads a contract file
+func Load(path string) (*Contract, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes a contract
+func Parse(r io.Reader) (*Contract, error) {
+	var c Contract
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, err
+	}
+	for _, i := range c.Interactions {
+		if i.Description == "" || i.Request.Method == "" || i.Request.Path == "" || i.Response.Status == 0 {
+			return nil, fmt.Errorf("interaction %q needs a description, a request method and path, and a response status", i.Description)
+		}
+		if _, err := parseRules(i.Response.MatchingRules); err != nil {
+			return nil, fmt.Errorf("interaction %q: %w", i.Description, err)
+		}
+	}
+	return &c, nil
+}
+
+// Verifier checks a provider against contracts
+type Verifier struct {
+	// Name is the provider's name; contracts with another provider are
+	// skipped. Empty verifies every contract.
+	Name string
+	// Provider returns the handler to send an interaction's request to,
+	// set up in the interaction's provider states. It is called for each
+	// interaction, so they don't see each other's changes, and should
+	// fail t on a state it doesn't know.
+	Provider func(t *testing.T, states []State) http.Handler
+}
+
+// VerifyDir verifies every contract in dir, the *.json files
+func (v *Verifier) VerifyDir(t *testing.T, dir string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Logf("no contracts in %s", dir)
+	}
+	for _, path := range paths {
+		v.VerifyFile(t, path)
+	}
+}
+
+// VerifyFile verifies the contract at path, each interaction as a subtest
+// named after its consumer and description
+func (v *Verifier) VerifyFile(t *testing.T, path string) {
+	t.Helper()
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "" && c.Provider.Name != v.Name {
+		t.Logf("skipping %s: it is for provider %q", path, c.Provider.Name)
+		return
+	}
+	for _, i := range c.Interactions {
+		t.Run(c.Consumer.Name+"/"+i.Description, func(t *testing.T) {
+			req, err := i.Request.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			v.Provider(t, i.States()).ServeHTTP(w, req)
+			for _, m := range i.Check(w.Code, w.Header(), w.Body.Bytes()) {
+				t.Errorf("%s: %s", path, m)
+			}
+		})
+	}
+}
+
+// build makes the request the consumer sends
+func (r Request) build() (*http.Request, error) {
+	target := r.Path
+	if q, err := r.query(); err != nil {
+		return nil, err
+	} else if q != "" {
+		target += "?" + q
+	}
+	var body io.Reader = http.NoBody
+	if len(r.Body) > 0 && string(r.Body) != "null" {
+		body = bytes.NewReader(r.Body)
+	}
+	req := httptest.NewRequest(r.Method, target, body)
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if body != http.NoBody && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// query returns the encoded query string of either version
+func (r Request) query() (string, error) {
+	if len(r.Query) == 0 {
+		return "", nil
+	}
+	var s string
+	if json.Unmarshal(r.Query, &s) == nil {
+		return s, nil
+	}
+	var values url.Values
+	if err := json.Unmarshal(r.Query, &values); err != nil {
+		return "", fmt.Errorf("query must be a string or a map of values: %w", err)
+	}
+	return values.Encode(), nil
+}
+
+// Check compares a response with the interaction's expectations and
+// returns what doesn't match, each as the path of the value and the
+// problem, e.g. "$.body.id: expected number, got string"
+func (i Interaction) Check(status int, header http.Header, body []byte) []string {
+	var problems []string
+	if status != i.Response.Status {
+		problems = append(problems, fmt.Sprintf("status: expected %d, got %d", i.Response.Status, status))
+	}
+	// Validated by Parse
+	rules, _ := parseRules(i.Response.MatchingRules)
+
+	names := make([]string, 0, len(i.Response.Headers))
+	for k := range i.Response.Headers {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		want, got := i.Response.Headers[k], header.Get(k)
+		path := "$.headers." + k
+		if m, ok := rules.headers[strings.ToLower(k)]; ok && m.kind != "equality" {
+			switch {
+			case m.regex != nil && !m.regex.MatchString(got):
+				problems = append(problems, fmt.Sprintf("%s: expected to match %q, got %q", path, m.regex, got))
+			case got == "":
+				problems = append(problems, path+": missing")
+			}
+			continue
+		}
+		if normalizeHeader(want) != normalizeHeader(got) {
+			problems = append(problems, fmt.Sprintf("%s: expected %q, got %q", path, want, got))
+		}
+	}
+
+	if len(i.Response.Body) == 0 {
+		return problems
+	}
+	var want, got any
+	if err := json.Unmarshal(i.Response.Body, &want); err != nil {
+		return append(problems, fmt.Sprintf("$.body: contract body is not JSON: %v", err))
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		return append(problems, fmt.Sprintf("$.body: expected JSON, got %q", truncate(body)))
+	}
+	c := checker{rules: rules.body}
+	c.compare([]string{"$"}, want, got, nil)
+	for _, p := range c.problems {
+		problems = append(problems, strings.Replace(p, "$", "$.body", 1))
+	}
+	return problems
+}
+
+// normalizeHeader ignores the spacing around commas and semicolons,
+// which Pact treats as insignificant
+func normalizeHeader(v string) string {
+	return strings.NewReplacer(", ", ",", "; ", ";").Replace(strings.TrimSpace(v))
+}
+
+func truncate(b []byte) string {
+	if len(b) > 80 {
+		return string(b[:80]) + "..."
+	}
+	return string(b)
+}
+
+// matcher is a matching rule
+type matcher struct {
+	kind     string // equality, type, regex, integer, decimal or include
+	regex    *regexp.Regexp
+	value    string
+	min, max int
+}
+
+// rules are a response's matching rules, bodies' by path and headers' by
+// lower-case name
+type rules struct {
+	body    map[string]matcher
+	headers map[string]matcher
+}
+
+// parseRules reads matching rules in either version: version 2 keys them
+// by paths starting $.body or $.headers, version 3 by category
+func parseRules(raw json.RawMessage) (rules, error) {
+	r := rules{body: map[string]matcher{}, headers: map[string]matcher{}}
+	if len(raw) == 0 {
+		return r, nil
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &top); err != nil {
+		return r, fmt.Errorf("matching rules: %w", err)
+	}
+	for key, v := range top {
+		var err error
+		switch {
+		case key == "$.body" || strings.HasPrefix(key, "$.body.") || strings.HasPrefix(key, "$.body["):
+			r.body["$"+strings.TrimPrefix(key, "$.body")], err = parseMatcher(v)
+		case strings.HasPrefix(key, "$.headers."):
+			r.headers[strings.ToLower(strings.TrimPrefix(key, "$.headers."))], err = parseMatcher(v)
+		case key == "body" || key == "header":
+			var byPath map[string]json.RawMessage
+			if err := json.Unmarshal(v, &byPath); err != nil {
+				return r, fmt.Errorf("matching rules %s: %w", key, err)
+			}
+			for path, m := range byPath {
+				if key == "body" {
+					r.body[path], err = parseMatcher(m)
+				} else {
+					r.headers[strings.ToLower(path)], err = parseMatcher(m)
+				}
+				if err != nil {
+					return r, fmt.Errorf("matching rule %s %s: %w", key, path, err)
+				}
+			}
+		default:
+			// Rules for the request, such as path or query, concern the
+			// consumer's mock only
+		}
+		if err != nil {
+			return r, fmt.Errorf("matching rule %s: %w", key, err)
+		}
+	}
+	return r, nil
+}
+
+// parseMatcher reads a rule: {"match": ...} in version 2, or the first of
+// {"matchers": [...]} in version 3
+func parseMatcher(raw json.RawMessage) (matcher, error) {
+	var rule struct {
+		Match    string            `json:"match"`
+		Regex    string            `json:"regex"`
+		Value    string            `json:"value"`
+		Min      *int              `json:"min"`
+		Max      *int              `json:"max"`
+		Matchers []json.RawMessage `json:"matchers"`
+	}
+	if err := json.Unmarshal(raw, &rule); err != nil {
+		return matcher{}, err
+	}
+	if rule.Matchers != nil {
+		if len(rule.Matchers) != 1 {
+			return matcher{}, fmt.Errorf("exactly one matcher is supported, got %d", len(rule.Matchers))
+		}
+		return parseMatcher(rule.Matchers[0])
+	}
+
+	m := matcher{kind: rule.Match, value: rule.Value, min: -1, max: -1}
+	if rule.Min != nil {
+		m.min = *rule.Min
+	}
+	if rule.Max != nil {
+		m.max = *rule.Max
+	}
+	switch m.kind {
+	case "":
+		// A bare min or max is a type match in version 2
+		if m.min < 0 && m.max < 0 {
+			return matcher{}, fmt.Errorf("missing match")
+		}
+		m.kind = "type"
+	case "regex":
+		re, err := regexp.Compile("^(?:" + rule.Regex + ")$")
+		if err != nil {
+			return matcher{}, err
+		}
+		m.regex = re
+	case "equality", "type", "integer", "decimal", "include":
+	default:
+		return matcher{}, fmt.Errorf("unsupported match %q", m.kind)
+	}
+	return m, nil
+}
+
+// checker compares a response body with the expected one under matching
+// rules, collecting problems
+type checker struct {
+	rules    map[string]matcher
+	problems []string
+}
+
+func (c *checker) fail(path []string, format string, args ...any) {
+	c.problems = append(c.problems, strings.Join(path, "")+": "+fmt.Sprintf(format, args...))
+}
+
+// rule returns the matcher of the value at path, the most specific if
+// several patterns match it. Type matching applies to everything under a
+// value it is set on, unless a rule there says otherwise.
+func (c *checker) rule(path []string, inherited *matcher) *matcher {
+	best, bestScore := inherited, -1
+	for pattern, m := range c.rules {
+		if score, ok := matchPath(pattern, path); ok && score > bestScore {
+			best, bestScore = &m, score
+		}
+	}
+	return best
+}
+
+// matchPath reports whether a rule path such as $.users[*].id matches
+// the path of a value, scoring it by how many segments it names exactly
+func matchPath(pattern string, path []string) (int, bool) {
+	segs := splitPath(pattern)
+	if len(segs) != len(path) {
+		return 0, false
+	}
+	score := 0
+	for i, s := range segs {
+		switch {
+		case s == path[i]:
+			score++
+		case s == ".*" && !strings.HasPrefix(path[i], "["):
+		case s == "[*]" && strings.HasPrefix(path[i], "["):
+		default:
+			return 0, false
+		}
+	}
+	return score, true
+}
+
+// splitPath splits $.a.b[0]['c d'] into $, .a, .b, [0] and .c d
+func splitPath(p string) []string {
+	var segs []string
+	for len(p) > 0 {
+		switch {
+		case p[0] == '$':
+			segs, p = append(segs, "$"), p[1:]
+		case strings.HasPrefix(p, "['"):
+			end := strings.Index(p, "']")
+			if end < 0 {
+				return append(segs, p)
+			}
+			segs, p = append(segs, "."+p[2:end]), p[end+2:]
+		case p[0] == '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return append(segs, p)
+			}
+			segs, p = append(segs, p[:end+1]), p[end+1:]
+		case p[0] == '.':
+			end := strings.IndexAny(p[1:], ".[")
+			if end < 0 {
+				end = len(p) - 1
+			}
+			segs, p = append(segs, p[:end+1]), p[end+1:]
+		default:
+			return append(segs, p)
+		}
+	}
+	return segs
+}
+
+// compare checks the value got at path against want
+func (c *checker) compare(path []string, want, got any, inherited *matcher) {
+	m := c.rule(path, inherited)
+	if m != nil && m.kind == "equality" {
+		m = nil
+	}
+	// Only type matching carries down to children
+	var carry *matcher
+	if m != nil && m.kind == "type" {
+		carry = m
+	}
+
+	if m != nil {
+		switch m.kind {
+		case "regex":
+			s, ok := scalarString(got)
+			if !ok || !m.regex.MatchString(s) {
+				c.fail(path, "expected to match %q, got %s", m.regex, describe(got))
+			}
+			return
+		case "integer":
+			if f, ok := got.(float64); !ok || f != float64(int64(f)) {
+				c.fail(path, "expected an integer, got %s", describe(got))
+			}
+			return
+		case "decimal":
+			if _, ok := got.(float64); !ok {
+				c.fail(path, "expected a number, got %s", describe(got))
+			}
+			return
+		case "include":
+			if s, ok := got.(string); !ok || !strings.Contains(s, m.value) {
+				c.fail(path, "expected to include %q, got %s", m.value, describe(got))
+			}
+			return
+		}
+	}
+
+	if kindOf(want) != kindOf(got) {
+		c.fail(path, "expected %s, got %s", kindOf(want), describe(got))
+		return
+	}
+	switch want := want.(type) {
+	case map[string]any:
+		got := got.(map[string]any)
+		keys := make([]string, 0, len(want))
+		for k := range want {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			child := append(slices.Clip(path), "."+k)
+			v, ok := got[k]
+			if !ok {
+				c.fail(child, "missing")
+				continue
+			}
+			c.compare(child, want[k], v, carry)
+		}
+	case []any:
+		got := got.([]any)
+		if m != nil && m.kind == "type" && (m.min >= 0 || m.max >= 0) {
+			if m.min >= 0 && len(got) < m.min {
+				c.fail(path, "expected at least %d items, got %d", m.min, len(got))
+			}
+			if m.max >= 0 && len(got) > m.max {
+				c.fail(path, "expected at most %d items, got %d", m.max, len(got))
+			}
+			// Every item is like the first one expected
+			if len(want) > 0 {
+				for i, v := range got {
+					c.compare(append(slices.Clip(path), "["+strconv.Itoa(i)+"]"), want[0], v, carry)
+				}
+			}
+			return
+		}
+		if len(got) != len(want) {
+			c.fail(path, "expected %d items, got %d", len(want), len(got))
+			return
+		}
+		for i := range want {
+			c.compare(append(slices.Clip(path), "["+strconv.Itoa(i)+"]"), want[i], got[i], carry)
+		}
+	default:
+		if carry == nil && want != got {
+			c.fail(path, "expected %s, got %s", describe(want), describe(got))
+		}
+	}
+}
+
+// kindOf names the JSON type of a decoded value
+func kindOf(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// describe renders a decoded value for a problem report
+func describe(v any) string {
+	switch v.(type) {
+	case map[string]any, []any:
+		return kindOf(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// scalarString returns a string or number as text, for regex matching
+func scalarString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,142 @@
+package contract
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestCheckMatchingRules(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, tt := range []struct {
+		name  string
+		rules string
+	}{
+		{"v2", `{
+			"$.headers.Content-Type": {"match": "regex", "regex": "application/json.*"},
+			"$.body.users": {"min": 1},
+			"$.body.users[*].created_at": {"match": "regex", "regex": "\\d{4}-\\d{2}-\\d{2}T.*"},
+			"$.body.count": {"match": "integer"}
+		}`},
+		{"v3", `{
+			"header": {"content-type": {"matchers": [{"match": "regex", "regex": "application/json.*"}]}},
+			"body": {
+				"$.users": {"matchers": [{"match": "type", "min": 1}]},
+				"$.users[*].created_at": {"matchers": [{"match": "regex", "regex": "\\d{4}-\\d{2}-\\d{2}T.*"}]},
+				"$.count": {"matchers": [{"match": "integer"}]}
+			}
+		}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(strings.NewReader(`{"consumer": {"name": "web"}, "provider": {"name": "api"}, "interactions": [{
+				"description": "list",
+				"request": {"method": "GET", "path": "/users"},
+				"response": {
+					"status": 200,
+					"headers": {"Content-Type": "application/json"},
+					"body": {"users": [{"id": 1, "name": "Alice", "created_at": "2024-01-01T00:00:00Z"}], "count": 1, "kind": "list"},
+					"matchingRules": ` + tt.rules + `
+				}
+			}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			i := c.Interactions[0]
+			header := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+
+			ok := `{"users": [{"id": 7, "name": "Bob", "created_at": "2025-06-01T12:00:00Z", "extra": true},
+				{"id": 8, "name": "Carol", "created_at": "2025-06-02T12:00:00Z"}], "count": 2, "kind": "list", "more": 1}`
+			if problems := i.Check(http.StatusOK, header, []byte(ok)); len(problems) != 0 {
+				t.Errorf("expected a match, got %v", problems)
+			}
+
+			bad := `{"users": [{"id": "7", "created_at": "yesterday"}], "count": 2.5, "kind": "page"}`
+			want := []string{
+				"status: expected 200, got 201",
+				`$.headers.Content-Type: expected to match "^(?:application/json.*)$", got "text/plain"`,
+				`$.body.count: expected an integer, got 2.5`,
+				`$.body.kind: expected "list", got "page"`,
+				`$.body.users[0].created_at: expected to match "^(?:\\d{4}-\\d{2}-\\d{2}T.*)$", got "yesterday"`,
+				`$.body.users[0].id: expected number, got "7"`,
+				`$.body.users[0].name: missing`,
+			}
+			got := i.Check(http.StatusCreated, http.Header{"Content-Type": {"text/plain"}}, []byte(bad))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			if got := i.Check(http.StatusOK, header, []byte(`{"users": [], "count": 0, "kind": "list"}`)); len(got) != 1 || !strings.Contains(got[0], "at least 1 items") {
+				t.Errorf("expected the minimum to be enforced, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCheckEquality(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	i := Interaction{Response: Response{Status: 404, Body: []byte(`{"error": "user not found", "tags": ["a", "b"]}`)}}
+	if problems := i.Check(404, nil, []byte(`{"error": "user not found", "tags": ["a", "b"], "request_id": "x"}`)); len(problems) != 0 {
+		t.Errorf("expected extra fields to be ignored, got %v", problems)
+	}
+	if problems := i.Check(404, nil, []byte(`{"error": "not found", "tags": ["a"]}`)); len(problems) != 2 {
+		t.Errorf("expected the message and the array length to differ, got %v", problems)
+	}
+	if problems := i.Check(404, nil, []byte(`not json`)); len(problems) != 1 || !strings.Contains(problems[0], "expected JSON") {
+		t.Errorf("expected a non-JSON body to be reported, got %v", problems)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, in := range []string{
+		`{"interactions": [{"description": "x", "request": {"method": "GET"}, "response": {"status": 200}}]}`,
+		`{"interactions": [{"description": "x", "request": {"method": "GET", "path": "/"}, "response": {"status": 200,
+			"matchingRules": {"$.body.id": {"match": "soundex"}}}}]}`,
+		`{"interactions": [{"description": "x", "request": {"method": "GET", "path": "/"}, "response": {"status": 200,
+			"matchingRules": {"$.body.id": {"match": "regex", "regex": "("}}}}]}`,
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("expected %s to be refused", in)
+		}
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "web.json"), []byte(`{
+		"consumer": {"name": "web"}, "provider": {"name": "api"},
+		"interactions": [{
+			"description": "a greeting",
+			"providerStates": [{"name": "greeting is", "params": {"text": "hello"}}],
+			"request": {"method": "POST", "path": "/greet", "query": {"name": ["Ann"]}, "body": {"polite": true}},
+			"response": {"status": 200, "body": {"greeting": "hello Ann"}}
+		}]
+	}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "other.json"), []byte(`{"consumer": {"name": "x"}, "provider": {"name": "elsewhere"},
+		"interactions": [{"description": "never", "request": {"method": "GET", "path": "/"}, "response": {"status": 418}}]}`), 0o644)
+
+	var states []State
+	v := &Verifier{Name: "api", Provider: func(t *testing.T, s []State) http.Handler {
+		states = s
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			w.Write([]byte(`{"greeting":"` + s[0].Params["text"].(string) + " " + r.URL.Query().Get("name") + `"}`))
+		})
+	}}
+	v.VerifyDir(t, dir)
+	if len(states) != 1 || states[0].Name != "greeting is" {
+		t.Errorf("expected the interaction's provider state, got %v", states)
+	}
+}
@@ -0,0 +1,50 @@
+package quickserve_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/contract"
+	"github.com/harshakonda/quickserve/fixtures"
+)
+
+// contractStates set up the provider states consumers' contracts name
+var contractStates = map[string]func(ctx context.Context, e *quickserve.Embedded, params map[string]any) error{
+	"no users exist": func(context.Context, *quickserve.Embedded, map[string]any) error { return nil },
+	// Users 1 and 2, Alice the admin and Bob
+	"users exist": func(ctx context.Context, e *quickserve.Embedded, _ map[string]any) error {
+		_, err := (&fixtures.Scenario{Users: []fixtures.ScenarioUser{
+			{Key: "alice", Name: "Alice", Email: "alice@example.com", Role: quickserve.RoleAdmin},
+			{Key: "bob", Name: "Bob", Email: "bob@example.com"},
+		}}).Apply(ctx, e)
+		return err
+	},
+}
+
+// TestContracts verifies the API against the contracts consumers publish
+// in testdata/contracts
+func TestContracts(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	v := &contract.Verifier{Name: "quickserve", Provider: func(t *testing.T, states []contract.State) http.Handler {
+		e, err := quickserve.NewEmbedded()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { e.Close() })
+		for _, s := range states {
+			setup, ok := contractStates[s.Name]
+			if !ok {
+				t.Fatalf("unknown provider state %q", s.Name)
+			}
+			if err := setup(context.Background(), e, s.Params); err != nil {
+				t.Fatalf("provider state %q: %v", s.Name, err)
+			}
+		}
+		return e.Handler()
+	}}
+	v.VerifyDir(t, "testdata/contracts")
+}
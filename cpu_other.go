@@ -0,0 +1,11 @@
+//go:build !unix
+
+package quickserve
+
+import "time"
+
+// processCPUTime is not available on this platform, so CPU never
+// contributes to the load weight
+func processCPUTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,80 @@
+package quickserve
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+const (
+	// CSRFCookieName holds the double-submit token. It is deliberately
+	// readable from JavaScript so the page can echo it in CSRFHeader.
+	CSRFCookieName = "qs_csrf"
+	// CSRFHeader must carry the cookie's value on mutating requests
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// WithCSRFProtection enables double-submit CSRF checks on the named route
+// groups (modules), e.g. "users" or "admin"
+func WithCSRFProtection(groups ...string) Option {
+	return func(s *Server) {
+		if s.csrfGroups == nil {
+			s.csrfGroups = make(map[string]bool)
+		}
+		for _, g := range groups {
+			s.csrfGroups[g] = true
+		}
+	}
+}
+
+// hasHeaderCredentials reports whether the request authenticates with
+// something a browser won't attach on its own, an API key or a bearer
+// token; such requests can't be forged cross-site and don't need a CSRF
+// token. Basic credentials don't count: browsers resend cached ones.
+func hasHeaderCredentials(r *http.Request) bool {
+	if r.Header.Get(APIKeyHeader) != "" {
+		return true
+	}
+	_, ok := bearerToken(r)
+	return ok
+}
+
+// csrfProtect issues a token cookie on safe requests and requires it to
+// be echoed in X-CSRF-Token on mutating ones. The cookie is SameSite=Strict
+// as a second line of defence.
+func (s *Server) csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(CSRFCookieName)
+		hasCookie := err == nil && cookie.Value != ""
+
+		if methodIdempotency(r.Method) == Safe {
+			if !hasCookie {
+				token, err := randomToken()
+				if err != nil {
+					httpError(w, r, "could not issue csrf token", http.StatusInternalServerError)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name:     CSRFCookieName,
+					Value:    token,
+					Path:     "/",
+					Secure:   r.TLS != nil,
+					SameSite: http.SameSiteStrictMode,
+				})
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if hasHeaderCredentials(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := r.Header.Get(CSRFHeader)
+		if !hasCookie || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+			httpError(w, r, "invalid csrf token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,98 @@
+package quickserve
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithCSRFProtection("users"))
+	routes := server.Routes()
+
+	// A safe request issues the token cookie
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	var token *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == CSRFCookieName {
+			token = c
+		}
+	}
+	if token == nil || token.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("expected SameSite=Strict csrf cookie, got %v", token)
+	}
+
+	post := func(header string) int {
+		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"A","email":"a@test.com"}`))
+		req.AddCookie(token)
+		if header != "" {
+			req.Header.Set(CSRFHeader, header)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(""); code != http.StatusForbidden {
+		t.Errorf("expected 403 without header, got %d", code)
+	}
+	if code := post("forged"); code != http.StatusForbidden {
+		t.Errorf("expected 403 with wrong token, got %d", code)
+	}
+	if code := post(token.Value); code != http.StatusCreated {
+		t.Errorf("expected 201 with matching token, got %d", code)
+	}
+}
+
+func TestCSRFOnlyOnEnabledGroups(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithCSRFProtection("admin"))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"A","email":"a@test.com"}`))
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected users group to be unprotected, got %d", w.Code)
+	}
+}
+
+func TestCSRFSkipsHeaderCredentials(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithCSRFProtection("users"))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"name":"A","email":"a@test.com"}`))
+	req.Header.Set(APIKeyHeader, "admin-secret")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected API key requests to skip csrf, got %d", w.Code)
+	}
+}
+
+func TestCSRFRequiresTokenWithBasicAuth(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAdminCredentials("admin", "secret"), WithCSRFProtection("admin"))
+
+	// Browsers resend cached Basic credentials on a cross-site form post
+	req := httptest.NewRequest(http.MethodPost, "/admin/tenants", bytes.NewBufferString(`{"id":"acme"}`))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected Basic-authenticated requests to need a csrf token, got %d", w.Code)
+	}
+}
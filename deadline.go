@@ -0,0 +1,193 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// RequestDeadlineHeader carries a client's end-to-end deadline, either as
+// a remaining budget ("250ms") or an absolute timestamp
+const RequestDeadlineHeader = "X-Request-Deadline"
+
+// defaultMaxDeadline caps client-supplied deadlines
+const defaultMaxDeadline = 30 * time.Second
+
+// WithMaxRequestDeadline caps the deadline a client may request
+func WithMaxRequestDeadline(d time.Duration) Option {
+	return func(s *Server) {
+		s.maxDeadline = d
+	}
+}
+
+// WithHandlerTimeout gives requests that carry no X-Request-Deadline a
+// deadline of d, so a slow store or upstream cannot hold a handler
+// indefinitely. Zero, the default, leaves such requests unbounded.
+func WithHandlerTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.handlerTimeout = d
+	}
+}
+
+// parseDeadline interprets an X-Request-Deadline value relative to now
+func parseDeadline(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(d), nil
+	}
+	return ParseTimestamp(v)
+}
+
+// requestDeadline derives the request context deadline from the
+// X-Request-Deadline header, or the handler timeout if there is none.
+// Store calls and outbound requests made with r.Context() inherit it; if
+// it passes before the handler finishes, the client gets a 504 saying how
+// far over budget the request went.
+func (s *Server) requestDeadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get(RequestDeadlineHeader)
+		// Event streams outlive any deadline, and can't be served through
+		// a buffered response
+		if (v == "" && s.handlerTimeout <= 0) || isWebSocketUpgrade(r) || isEventStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := s.clock.Now()
+		deadline := now.Add(s.handlerTimeout)
+		if v != "" {
+			var err error
+			if deadline, err = parseDeadline(v, now); err != nil {
+				httpError(w, r, "invalid "+RequestDeadlineHeader, http.StatusBadRequest)
+				return
+			}
+			if limit := now.Add(s.maxDeadline); deadline.After(limit) {
+				deadline = limit
+			}
+		}
+		if !deadline.After(now) {
+			s.writeDeadlineExceeded(w, r, deadline)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), deadline.Sub(now))
+		defer cancel()
+
+		s.serveWithin(ctx, w, r, next, func() {
+			s.writeDeadlineExceeded(w, r, deadline)
+		})
+	})
+}
+
+// serveWithin runs next with ctx, buffering its response. If ctx ends
+// first, onTimeout writes the response instead and anything the handler
+// writes afterwards is discarded.
+func (s *Server) serveWithin(ctx context.Context, w http.ResponseWriter, r *http.Request, next http.Handler, onTimeout func()) {
+	bw := &bufferedWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan any, 1)
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				if _, ok := p.(handlerPanic); !ok && p != http.ErrAbortHandler {
+					p = handlerPanic{value: p, stack: debug.Stack()}
+				}
+				panicked <- p
+			}
+		}()
+		next.ServeHTTP(bw, r.WithContext(ctx))
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+	case <-done:
+		bw.flushTo(w)
+	case <-ctx.Done():
+		bw.abandon()
+		onTimeout()
+	}
+}
+
+// writeDeadlineExceeded sends a 504 reporting the overrun
+func (s *Server) writeDeadlineExceeded(w http.ResponseWriter, r *http.Request, deadline time.Time) {
+	exceeded := s.clock.Now().Sub(deadline)
+	if exceeded < 0 {
+		exceeded = 0
+	}
+
+	s.writeJSON(w, r, http.StatusGatewayTimeout, struct {
+		Error        string    `json:"error"`
+		DeadlineAt   time.Time `json:"deadline_at"`
+		ExceededBy   string    `json:"exceeded_by"`
+		ExceededByMs int64     `json:"exceeded_by_ms"`
+		RequestID    string    `json:"request_id,omitempty"`
+	}{"request deadline exceeded", deadline, exceeded.String(), exceeded.Milliseconds(), RequestIDFromContext(r.Context())})
+}
+
+// bufferedWriter collects a response so it can be dropped if the handler
+// runs out of time
+type bufferedWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	code      int
+	abandoned bool
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) abandon() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.abandoned = true
+}
+
+func (bw *bufferedWriter) flushTo(w http.ResponseWriter) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	dst := w.Header()
+	for k, v := range bw.header {
+		dst[k] = v
+	}
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	w.WriteHeader(bw.code)
+	w.Write(bw.body.Bytes())
+}
+
+// propagateDeadline forwards the context deadline on an outbound request
+// so downstream services can honor the same budget
+func propagateDeadline(ctx context.Context, req *http.Request) {
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(RequestDeadlineHeader, deadline.UTC().Format(time.RFC3339Nano))
+	}
+}
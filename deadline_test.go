@@ -0,0 +1,139 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRequestDeadlineAlreadyPassed(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(RequestDeadlineHeader, time.Now().Add(-2*time.Second).Format(time.RFC3339Nano))
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d", w.Code)
+	}
+
+	var body struct {
+		ExceededByMs int64 `json:"exceeded_by_ms"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if body.ExceededByMs < 2000 {
+		t.Errorf("expected at least 2000ms over budget, got %d", body.ExceededByMs)
+	}
+}
+
+func TestRequestDeadlineSlowHandler(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte("too late"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set(RequestDeadlineHeader, "20ms")
+	w := httptest.NewRecorder()
+	server.requestDeadline(slow).ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+}
+
+func TestRequestDeadlineCapped(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithMaxRequestDeadline(time.Second))
+
+	var remaining time.Duration
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ := r.Context().Deadline()
+		remaining = time.Until(deadline)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestDeadlineHeader, "1h")
+	server.requestDeadline(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	if remaining > time.Second {
+		t.Errorf("expected deadline capped at 1s, got %v", remaining)
+	}
+}
+
+func TestRequestDeadlineInvalid(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(RequestDeadlineHeader, "soon")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+// slowUserStore blocks List until the request context ends, like a
+// backend that has stopped answering
+type slowUserStore struct {
+	*MemoryUserStore
+	cancelled chan error
+}
+
+func (s slowUserStore) List(ctx context.Context) ([]User, error) {
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestHandlerTimeoutCancelsStore(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := slowUserStore{NewMemoryUserStore(), make(chan error, 1)}
+	server := NewServer(WithUserStore(store), WithHandlerTimeout(20*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", w.Code)
+	}
+	if err := <-store.cancelled; err != context.DeadlineExceeded {
+		t.Errorf("expected the store call to be cancelled by the deadline, got %v", err)
+	}
+}
+
+func TestHandlerTimeoutClientDeadlineWins(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithHandlerTimeout(20 * time.Millisecond))
+
+	var remaining time.Duration
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ := r.Context().Deadline()
+		remaining = time.Until(deadline)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestDeadlineHeader, "5s")
+	server.requestDeadline(h).ServeHTTP(httptest.NewRecorder(), req)
+
+	if remaining < time.Second {
+		t.Errorf("expected the client's 5s deadline, got %v", remaining)
+	}
+}
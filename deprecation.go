@@ -0,0 +1,324 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deprecation is a deprecated route, or a query parameter of one, as
+// recorded in the changelog or the route registry
+type Deprecation struct {
+	Route string `json:"route"`
+	Field string `json:"field,omitempty"`
+	// Since is the API version of the deprecation, for the changelog's
+	Since       string `json:"since,omitempty"`
+	Description string `json:"description"`
+	// Sunset is when the route goes away, if decided
+	Sunset *time.Time `json:"sunset,omitempty"`
+}
+
+// RouteDeprecation marks a route deprecated in the route registry
+type RouteDeprecation struct {
+	Description string     `json:"description"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+}
+
+// WithDeprecation marks a route deprecated, so modules can phase out
+// routes the changelog doesn't know about. Its responses carry the
+// Deprecation header, and the Sunset header with the date it goes away
+// unless sunset is zero; calls are counted like those to routes the
+// changelog deprecates.
+func WithDeprecation(description string, sunset time.Time) RouteOption {
+	return func(rt *Route) {
+		rt.Deprecation = &RouteDeprecation{Description: description}
+		if !sunset.IsZero() {
+			sunset = sunset.UTC()
+			rt.Deprecation.Sunset = &sunset
+		}
+	}
+}
+
+// deprecationsOf returns the deprecations of rt, from the changelog and
+// the registry
+func (s *Server) deprecationsOf(rt *Route) []Deprecation {
+	// Versions and tenant namespaces serve copies of the route, deprecated
+	// with it
+	ds := s.deprecations[unscopedPattern(rt.Pattern())]
+	if rt.Deprecation != nil {
+		ds = append(slices.Clip(ds), Deprecation{
+			Route: rt.Pattern(), Description: rt.Deprecation.Description, Sunset: rt.Deprecation.Sunset,
+		})
+	}
+	return ds
+}
+
+// surface names what was used, e.g. "GET /users" or "GET /users?sort"
+func (d Deprecation) surface() string {
+	if d.Field == "" {
+		return d.Route
+	}
+	return d.Route + "?" + d.Field
+}
+
+// deprecationsFrom indexes the surfaces whose deprecation is still in
+// effect, by route: deprecated in some release and not removed since
+func deprecationsFrom(releases []Release) map[string][]Deprecation {
+	byRoute := make(map[string][]Deprecation)
+	for i := len(releases) - 1; i >= 0; i-- {
+		for _, c := range releases[i].Changes {
+			route, field, _ := strings.Cut(c.Route, "?")
+			switch {
+			case c.Kind == ChangeDeprecated && route != "":
+				byRoute[route] = append(byRoute[route], Deprecation{
+					Route: route, Field: field, Since: releases[i].Version, Description: c.Description,
+				})
+			case c.Kind == ChangeRemoved:
+				kept := byRoute[route][:0]
+				for _, d := range byRoute[route] {
+					if field != "" && d.Field != field {
+						kept = append(kept, d)
+					}
+				}
+				byRoute[route] = kept
+			}
+		}
+	}
+	return byRoute
+}
+
+// DeprecationUsage counts one caller's calls to one deprecated surface
+type DeprecationUsage struct {
+	Deprecation
+	Caller      string    `json:"caller"`
+	KeyID       ID        `json:"key_id,omitempty"`
+	Calls       int64     `json:"calls"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// DeprecationTracker records who still calls deprecated surfaces
+type DeprecationTracker struct {
+	mu    sync.Mutex
+	usage map[[2]string]*DeprecationUsage
+	clock Clock
+}
+
+// NewDeprecationTracker creates an empty tracker
+func NewDeprecationTracker() *DeprecationTracker {
+	return &DeprecationTracker{usage: make(map[[2]string]*DeprecationUsage), clock: SystemClock{}}
+}
+
+// Record counts a call and reports whether it was the caller's first
+// use of the surface
+func (t *DeprecationTracker) Record(d Deprecation, caller string, keyID ID) (DeprecationUsage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	k := [2]string{d.surface(), caller}
+	u, seen := t.usage[k]
+	if !seen {
+		u = &DeprecationUsage{Deprecation: d, Caller: caller, KeyID: keyID, FirstSeenAt: now}
+		t.usage[k] = u
+	}
+	u.Calls++
+	u.LastSeenAt = now
+	return *u, !seen
+}
+
+// Report returns all usage sorted by surface, then caller
+func (t *DeprecationTracker) Report() []DeprecationUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := make([]DeprecationUsage, 0, len(t.usage))
+	for _, u := range t.usage {
+		report = append(report, *u)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if si, sj := report[i].surface(), report[j].surface(); si != sj {
+			return si < sj
+		}
+		return report[i].Caller < report[j].Caller
+	})
+	return report
+}
+
+// deprecationMetricsPath serves the calls to deprecated surfaces for
+// Prometheus
+const deprecationMetricsPath = "/debug/deprecations/metrics"
+
+// DeprecationMetricsHandler serves the calls to deprecated routes and
+// query parameters in the Prometheus text format at
+// /debug/deprecations/metrics. Like LeakHandler it has no authentication:
+// serve it on a private listener, as quickserve does next to pprof.
+func (s *Server) DeprecationMetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+deprecationMetricsPath, s.handleDeprecationMetrics)
+	return mux
+}
+
+// handleDeprecationMetrics writes the calls and callers of each
+// deprecated surface, so dashboards show when one is safe to remove
+func (s *Server) handleDeprecationMetrics(w http.ResponseWriter, r *http.Request) {
+	type figures struct {
+		Deprecation
+		calls, callers int64
+	}
+	var surfaces []*figures
+	bySurface := make(map[string]*figures)
+	for _, u := range s.deprecationUsage.Report() {
+		f, ok := bySurface[u.surface()]
+		if !ok {
+			f = &figures{Deprecation: u.Deprecation}
+			bySurface[u.surface()] = f
+			surfaces = append(surfaces, f)
+		}
+		f.calls += u.Calls
+		f.callers++
+	}
+
+	var b strings.Builder
+	metric := func(name, kind, help string, v func(*figures) int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, f := range surfaces {
+			fmt.Fprintf(&b, "%s{route=%s,field=%s} %d\n", name, promLabel(f.Route), promLabel(f.Field), v(f))
+		}
+	}
+	metric("quickserve_deprecated_calls_total", "counter", "Calls to deprecated routes and query parameters since start.",
+		func(f *figures) int64 { return f.calls })
+	metric("quickserve_deprecated_callers", "gauge", "Callers that called a deprecated route or query parameter since start.",
+		func(f *figures) int64 { return f.callers })
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// promLabel quotes a Prometheus label value
+func promLabel(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+// trackDeprecated marks responses from deprecated routes with the
+// Deprecation header, and Sunset once the date is decided, and records
+// the caller. It runs after authentication so calls are attributed to
+// the API key.
+func (s *Server) trackDeprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, ok := RouteFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		deprecations := s.deprecationsOf(rt)
+		if len(deprecations) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller, keyID := "anonymous", ID(0)
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			caller, keyID = p.Subject, p.KeyID
+		}
+		query := r.URL.Query()
+		for _, d := range deprecations {
+			if d.Field != "" && !query.Has(d.Field) {
+				continue
+			}
+			w.Header().Set("Deprecation", "true")
+			if d.Since != "" {
+				w.Header().Add("Link", `</changelog>; rel="deprecation"`)
+			}
+			if d.Sunset != nil {
+				w.Header().Set("Sunset", d.Sunset.Format(http.TimeFormat))
+			}
+			if u, first := s.deprecationUsage.Record(d, caller, keyID); first && keyID != 0 {
+				s.notifyDeprecatedUsage(r.Context(), u)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// notifyDeprecatedUsage tells the owner of an API key, through the key's
+// notify_url, that it has started calling a deprecated surface. Delivery
+// happens in the background so the request is not held up.
+func (s *Server) notifyDeprecatedUsage(ctx context.Context, u DeprecationUsage) {
+	key, ok := s.apiKeys.Get(u.KeyID)
+	if !ok || key.NotifyURL == "" {
+		return
+	}
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		DeprecationUsage
+	}{"deprecated_usage", u})
+	if err != nil {
+		return
+	}
+
+	logger := s.componentLogger("deprecations")
+	started := s.goBackground(ctx, "webhook", webhookTimeout, func(ctx context.Context) {
+		if err := postWebhook(ctx, s.webhookClient, key.NotifyURL, body, nil); err != nil {
+			logger.WarnContext(ctx, "could not notify key owner", "key_id", key.ID, "err", err)
+		}
+	})
+	if !started {
+		logger.WarnContext(ctx, "shutting down; key owner not notified", "key_id", key.ID)
+	}
+}
+
+// postWebhook delivers a JSON payload with any extra header, treating any
+// non-2xx reply as failure
+func postWebhook(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	propagateTrace(ctx, req)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// HandleListDeprecations handles GET /admin/deprecations, reporting
+// who still calls deprecated routes. ?key_id= narrows it to one key.
+func (s *Server) HandleListDeprecations(w http.ResponseWriter, r *http.Request) {
+	report := s.deprecationUsage.Report()
+	if v := r.URL.Query().Get("key_id"); v != "" {
+		id, err := ParseID(v)
+		if err != nil {
+			httpError(w, r, "invalid key_id", http.StatusBadRequest)
+			return
+		}
+		filtered := []DeprecationUsage{}
+		for _, u := range report {
+			if u.KeyID == id {
+				filtered = append(filtered, u)
+			}
+		}
+		report = filtered
+	}
+
+	s.writeJSON(w, r, http.StatusOK, report)
+}
@@ -0,0 +1,193 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestDeprecationsFrom(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	got := deprecationsFrom([]Release{
+		{"3.0.0", []Change{{ChangeRemoved, "GET /old", "gone"}, {ChangeRemoved, "GET /users?sort", "gone"}}},
+		{"2.0.0", []Change{
+			{ChangeDeprecated, "GET /old", "use GET /new"},
+			{ChangeDeprecated, "GET /users?sort", "use ?order"},
+			{ChangeDeprecated, "GET /users?page", "use ?cursor"},
+		}},
+	})
+
+	if _, ok := got["GET /old"]; ok && len(got["GET /old"]) > 0 {
+		t.Errorf("expected the removed route to be dropped, got %v", got["GET /old"])
+	}
+	users := got["GET /users"]
+	if len(users) != 1 || users[0].Field != "page" || users[0].Since != "2.0.0" {
+		t.Errorf("expected only ?page to remain deprecated, got %+v", users)
+	}
+}
+
+func TestDeprecationTelemetry(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	notices := make(chan []byte, 4)
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		notices <- b
+	}))
+	defer owner.Close()
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"))
+	defer server.Close()
+	server.webhookClient = owner.Client()
+	server.deprecations = deprecationsFrom([]Release{{"9.0.0", []Change{
+		{ChangeDeprecated, "GET /users", "use GET /orgs/{org}/users"},
+		{ChangeDeprecated, "GET /users/{id}?id_format", "IDs are always numbers"},
+	}}})
+	handler := server.Routes()
+
+	do := func(key, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("admin-secret", http.MethodPost, "/admin/keys", `{"name":"legacy","notify_url":"`+owner.URL+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID  ID     `json:"id"`
+		Key string `json:"key"`
+	}
+	json.NewDecoder(w.Body).Decode(&created)
+
+	for i := 0; i < 2; i++ {
+		if w := do(created.Key, http.MethodGet, "/users", ""); w.Header().Get("Deprecation") != "true" {
+			t.Errorf("expected a Deprecation header, got %v", w.Header())
+		}
+	}
+	if w := do(created.Key, http.MethodGet, "/users/1", ""); w.Header().Get("Deprecation") != "" {
+		t.Error("expected no Deprecation header without the deprecated parameter")
+	}
+	do(created.Key, http.MethodGet, "/users/1?id_format=string", "")
+
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case b := <-notices:
+			var notice DeprecationUsage
+			json.Unmarshal(b, &notice)
+			if !strings.Contains(string(b), `"event":"deprecated_usage"`) || notice.KeyID != created.ID {
+				t.Errorf("unexpected notice: %s", b)
+			}
+			got[notice.surface()] = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the key owner to be notified of each surface")
+		}
+	}
+	if !got["GET /users"] || !got["GET /users/{id}?id_format"] {
+		t.Errorf("expected notices for both surfaces, got %v", got)
+	}
+	select {
+	case b := <-notices:
+		t.Errorf("expected one notice per surface, got another: %s", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	w = do("admin-secret", http.MethodGet, "/admin/deprecations?key_id="+created.ID.String(), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var report []DeprecationUsage
+	json.NewDecoder(w.Body).Decode(&report)
+	if len(report) != 2 {
+		t.Fatalf("expected two surfaces, got %+v", report)
+	}
+	if report[0].Route != "GET /users" || report[0].Calls != 2 || report[0].Caller != "apikey:"+created.ID.String() {
+		t.Errorf("unexpected usage: %+v", report[0])
+	}
+	if report[1].Field != "id_format" || report[1].Calls != 1 {
+		t.Errorf("unexpected usage: %+v", report[1])
+	}
+
+	if w := do(created.Key, http.MethodGet, "/admin/deprecations", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected the report to be admin only, got %d", w.Code)
+	}
+}
+
+func TestDeprecationOfRouteCopies(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	server.tenants.Create("acme")
+	server.deprecations = deprecationsFrom([]Release{{"9.0.0", []Change{
+		{ChangeDeprecated, "GET /users", "use GET /orgs/{org}/users"},
+	}}})
+	handler := server.Routes()
+
+	for _, path := range []string{"/users", "/v1/users", "/v2/users", "/tenants/acme/users"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Header().Get("Deprecation") != "true" {
+			t.Errorf("%s: expected a Deprecation header, got %v", path, w.Header())
+		}
+	}
+	if report := server.deprecationUsage.Report(); len(report) != 1 || report[0].Route != "GET /users" || report[0].Calls != 4 {
+		t.Errorf("expected every copy counted as the route, got %+v", report)
+	}
+}
+
+func TestRouteDeprecation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	sunset := time.Date(2031, 6, 30, 0, 0, 0, 0, time.UTC)
+	server := NewServer(
+		WithModule(testModule{name: "widgets", patterns: []string{"GET /widgets"}, opts: []RouteOption{WithDeprecation("use GET /gadgets", sunset)}}),
+		WithModule(testModule{name: "gizmos", patterns: []string{"GET /gizmos"}, opts: []RouteOption{WithDeprecation("going away", time.Time{})}}),
+	)
+	routes := server.Routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	for range 2 {
+		w := get("/widgets")
+		if w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") != "Mon, 30 Jun 2031 00:00:00 GMT" {
+			t.Errorf("expected Deprecation and Sunset headers, got %v", w.Header())
+		}
+		if w.Header().Get("Link") != "" {
+			t.Errorf("expected no link to the changelog, which doesn't list the route, got %q", w.Header().Get("Link"))
+		}
+	}
+	if w := get("/gizmos"); w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") != "" {
+		t.Errorf("expected no Sunset without a date, got %v", w.Header())
+	}
+
+	op := server.OpenAPI().Paths["/widgets"]["get"]
+	if !op.Deprecated || !strings.Contains(op.Description, "sunset on 2031-06-30") {
+		t.Errorf("expected the operation deprecated with its sunset, got %+v", op)
+	}
+
+	w := httptest.NewRecorder()
+	server.DeprecationMetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, deprecationMetricsPath, nil))
+	for _, want := range []string{
+		`quickserve_deprecated_calls_total{route="GET /widgets",field=""} 2`,
+		`quickserve_deprecated_calls_total{route="GET /gizmos",field=""} 1`,
+		`quickserve_deprecated_callers{route="GET /widgets",field=""} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %s in the metrics, got:\n%s", want, w.Body)
+		}
+	}
+}
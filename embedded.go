@@ -0,0 +1,139 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+)
+
+// embeddedRemoteAddr is the client address of requests made through
+// Embedded.Client
+const embeddedRemoteAddr = "127.0.0.1:0"
+
+// Embedded runs the whole service in-process, for tests and small tools
+// that want quickserve without starting a server. Requests go to Handler,
+// or through Client, and the stores can be used directly.
+type Embedded struct {
+	server  *Server
+	handler http.Handler
+}
+
+// NewEmbedded builds an in-process instance configured like NewServer.
+// It fails if modules register conflicting routes.
+func NewEmbedded(opts ...Option) (*Embedded, error) {
+	s := NewServer(opts...)
+	h, err := s.Handler()
+	if err != nil {
+		return nil, err
+	}
+	return &Embedded{server: s, handler: h}, nil
+}
+
+// Handler returns the HTTP handler serving every route, with the same
+// middleware a real server has
+func (e *Embedded) Handler() http.Handler {
+	return e.handler
+}
+
+// Client returns an HTTP client whose requests are served by Handler
+// without touching the network. Only the path and query of the URL matter.
+func (e *Embedded) Client() *http.Client {
+	return &http.Client{Transport: handlerTransport{e.handler}}
+}
+
+// Close stops the instance's background work, such as webhook deliveries
+// started by requests, and waits for it to exit
+func (e *Embedded) Close() error {
+	return e.server.Close()
+}
+
+// Users returns the user store. Users added or deleted through it are
+// audited and published as user events, as those of requests are.
+func (e *Embedded) Users() UserStore {
+	return auditedUserStore{UserStore: e.server.store, server: e.server}
+}
+
+// APIKeys returns the API key store
+func (e *Embedded) APIKeys() *APIKeyStore {
+	return e.server.apiKeys
+}
+
+// Orgs returns the org, team and membership store
+func (e *Embedded) Orgs() *OrgStore {
+	return e.server.orgs
+}
+
+// Invitations returns the invitation store
+func (e *Embedded) Invitations() *InvitationStore {
+	return e.server.invitations
+}
+
+// Audit returns the audit log
+func (e *Embedded) Audit() *AuditLog {
+	return e.server.audit
+}
+
+// CreateUser adds a user within the configured user limits, as POST /users
+// would. A non-empty password lets the user log in.
+func (e *Embedded) CreateUser(ctx context.Context, u User, password string) (User, error) {
+	if password != "" {
+		if err := u.SetPassword(password); err != nil {
+			return User{}, err
+		}
+	}
+	user, err := e.server.createUser(ctx, u)
+	if err != nil {
+		return User{}, err
+	}
+	e.server.recordChange(ctx, AuditCreate, "user", user.ID.String(), nil, user)
+	return user, nil
+}
+
+// auditedUserStore records the users inserted into and deleted from a
+// store outside the handlers, and removes what belonged to deleted ones
+type auditedUserStore struct {
+	UserStore
+	server *Server
+}
+
+func (st auditedUserStore) Insert(ctx context.Context, user User, limit, tenantLimit int) (User, error) {
+	user, err := st.UserStore.Insert(ctx, user, limit, tenantLimit)
+	if err == nil {
+		st.server.recordChange(ctx, AuditCreate, "user", user.ID.String(), nil, user)
+	}
+	return user, err
+}
+
+func (st auditedUserStore) Delete(ctx context.Context, id ID) (bool, error) {
+	user, found, err := st.UserStore.Get(ctx, id)
+	if err != nil || !found {
+		return false, err
+	}
+	if found, err = st.UserStore.Delete(ctx, id); found && err == nil {
+		st.server.deleteUserData(ctx, id)
+		st.server.recordChange(ctx, AuditDelete, "user", id.String(), user, nil)
+	}
+	return found, err
+}
+
+// handlerTransport serves client requests with a handler
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request, and handlers expect the
+	// fields a server sets
+	r := req.Clone(req.Context())
+	r.RequestURI = req.URL.RequestURI()
+	r.RemoteAddr = embeddedRemoteAddr
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+
+	w := httptest.NewRecorder()
+	t.handler.ServeHTTP(w, r)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
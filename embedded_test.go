@@ -0,0 +1,60 @@
+package quickserve_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/fixtures"
+)
+
+func TestEmbedded(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	e, err := quickserve.NewEmbedded(quickserve.WithAPIKeyAuth("admin-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	ctx := context.Background()
+	alice, err := fixtures.NewUser().WithName("Alice").WithEmail("alice@example.com").WithPassword("correct horse battery").Create(ctx, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := e.Client()
+	req, _ := http.NewRequest(http.MethodPost, "http://quickserve/users", strings.NewReader(`{"name":"Bob","email":"bob@example.com"}`))
+	req.Header.Set(quickserve.APIKeyHeader, "admin-secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if _, ok, _ := e.Users().FindByEmail(ctx, "bob@example.com"); !ok {
+		t.Error("expected the user created over HTTP in the store")
+	}
+
+	resp, err = client.Post("http://quickserve/login", "application/json",
+		strings.NewReader(`{"email":"alice@example.com","password":"correct horse battery"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the embedded user to log in, got %d", resp.StatusCode)
+	}
+	var login struct {
+		User quickserve.User `json:"user"`
+	}
+	json.NewDecoder(resp.Body).Decode(&login)
+	if login.User.ID != alice.ID {
+		t.Errorf("expected a session for user %d, got %d", alice.ID, login.User.ID)
+	}
+}
@@ -0,0 +1,172 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/harshakonda/quickserve/events"
+)
+
+const (
+	// defaultKeepalive is how often idle streams are pinged
+	defaultKeepalive = 30 * time.Second
+	// eventBuffer is how many events may queue for a subscriber before it
+	// is dropped as too slow
+	eventBuffer = 64
+)
+
+// WithStreamKeepalive sets how often idle event streams are kept alive:
+// WebSocket clients are pinged, and a client that sends nothing, not even
+// a pong, for two intervals is disconnected; Server-Sent Event streams
+// get a comment so proxies don't time them out.
+func WithStreamKeepalive(d time.Duration) Option {
+	return func(s *Server) {
+		s.keepalive = d
+	}
+}
+
+func (s *Server) keepaliveInterval() time.Duration {
+	if s.keepalive <= 0 {
+		return defaultKeepalive
+	}
+	return s.keepalive
+}
+
+// WithEventSink adds h to the sinks every user event is published to,
+// alongside webhooks and live clients. h runs on the request's goroutine
+// and must not block.
+func WithEventSink(h events.Handler[UserEvent]) Option {
+	return func(s *Server) {
+		s.events.Handle(h)
+	}
+}
+
+// endStreams ends every event stream. The server calls it when it
+// starts draining, so clients reconnect to another instance instead of
+// holding up the shutdown, and when it closes.
+func (s *Server) endStreams() {
+	s.streamsOnce.Do(func() { close(s.streamsDone) })
+}
+
+// User event types
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	// EventUserCapacity is a user count reaching a threshold of its limit;
+	// the event carries no user but its Capacity
+	EventUserCapacity = "user.capacity"
+)
+
+// userEventTypes are the event types clients may subscribe to
+var userEventTypes = []string{EventUserCreated, EventUserUpdated, EventUserDeleted, EventUserCapacity}
+
+// UserEvent is a change to a user as published on the server's event
+// bus. ID is that of the audit entry recording the change, so events are
+// ordered and can be looked up in /admin/audit.
+type UserEvent struct {
+	ID         ID        `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	UserID     ID        `json:"user_id"`
+	// User is the user after the change, or before it for deletions
+	User      json.RawMessage `json:"user,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	// Capacity is the threshold reached, for user.capacity events
+	Capacity json.RawMessage `json:"capacity,omitempty"`
+
+	// tenant is the user's, for routing events to tenant webhooks
+	tenant string
+	// remote is set on events relayed from another instance, whose IDs
+	// are from its own audit log
+	remote bool
+}
+
+// encodeEvent encodes ev with the server's ID format and timestamp
+// precision, as every event leaving the server is
+func (s *Server) encodeEvent(ev UserEvent) ([]byte, error) {
+	return encodeEventWith(ev, s.renderOptionsFor(nil))
+}
+
+// encodeEventWith encodes ev with opts, without encodeJSON's trailing
+// newline, which would end up in webhook bodies and messages
+func encodeEventWith(ev UserEvent, opts renderOptions) ([]byte, error) {
+	data, err := encodeJSON(ev, opts)
+	return bytes.TrimSuffix(data, []byte("\n")), err
+}
+
+// userEventFor maps an audit entry to the user event it records, if any
+func userEventFor(e AuditEntry) (UserEvent, bool) {
+	if e.Resource != "user" {
+		return UserEvent{}, false
+	}
+	id, err := ParseID(e.ResourceID)
+	if err != nil {
+		return UserEvent{}, false
+	}
+	ev := UserEvent{ID: e.ID, OccurredAt: e.OccurredAt, UserID: id, User: e.After, RequestID: e.RequestID}
+	switch e.Action {
+	case AuditCreate:
+		ev.Type = EventUserCreated
+	case AuditUpdate:
+		ev.Type = EventUserUpdated
+	case AuditDelete:
+		ev.Type = EventUserDeleted
+		ev.User = e.Before
+	default:
+		return UserEvent{}, false
+	}
+	ev.tenant = eventTenant(ev.User)
+	return ev, true
+}
+
+// eventFor maps an audit entry to the event it publishes, if any: a user
+// change, or a user count reaching a threshold
+func eventFor(e AuditEntry) (UserEvent, bool) {
+	if e.Resource != "capacity" {
+		return userEventFor(e)
+	}
+	return UserEvent{
+		ID:         e.ID,
+		Type:       EventUserCapacity,
+		OccurredAt: e.OccurredAt,
+		RequestID:  e.RequestID,
+		Capacity:   e.After,
+		tenant:     eventTenant(e.After),
+	}, true
+}
+
+// eventTenant returns the tenant of the user in an event
+func eventTenant(user json.RawMessage) string {
+	var u struct {
+		Tenant string `json:"tenant"`
+	}
+	json.Unmarshal(user, &u)
+	return u.Tenant
+}
+
+// EventType implements events.Event
+func (e UserEvent) EventType() string {
+	return e.Type
+}
+
+// eventTypesParam parses ?events=, a comma-separated list of event types;
+// none means every type
+func eventTypesParam(r *http.Request) ([]string, error) {
+	v := r.URL.Query().Get("events")
+	if v == "" {
+		return nil, nil
+	}
+	types := strings.Split(v, ",")
+	for _, t := range types {
+		if !slices.Contains(userEventTypes, t) {
+			return nil, fmt.Errorf("unknown event %s", t)
+		}
+	}
+	return types, nil
+}
@@ -0,0 +1,156 @@
+// Package events is an in-process publish/subscribe bus. A producer
+// publishes each event once; sinks either handle it as it is published,
+// or subscribe to a queue of their own, so adding a sink never touches
+// the code that produces events:
+//
+//	bus := events.NewBus[UserEvent](64)
+//	bus.Handle(func(ctx context.Context, ev UserEvent) { ... })
+//	sub := bus.Subscribe([]string{"user.created"})
+//	defer bus.Unsubscribe(sub)
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// Event is anything published on a Bus
+type Event interface {
+	// EventType names the kind of event subscribers filter on
+	EventType() string
+}
+
+// Handler is a sink called for every event as it is published. It runs
+// on the publisher's goroutine, so it must not block; a sink with slow
+// work queues it.
+type Handler[E Event] func(ctx context.Context, ev E)
+
+// Message is a queued event with its JSON encoding, made once for every
+// subscriber
+type Message[E Event] struct {
+	Event E
+	Data  []byte
+}
+
+// Subscription is one subscriber's queue
+type Subscription[E Event] struct {
+	types []string
+	send  chan Message[E]
+	// dropped is closed when the bus gives up on a slow subscriber
+	dropped chan struct{}
+}
+
+// Events returns the subscriber's queue
+func (s *Subscription[E]) Events() <-chan Message[E] {
+	return s.send
+}
+
+// Dropped is closed once the subscriber fell too far behind and was
+// unsubscribed. Events published before then are still queued.
+func (s *Subscription[E]) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Wants reports whether the subscriber asked for events of typ
+func (s *Subscription[E]) Wants(typ string) bool {
+	return len(s.types) == 0 || slices.Contains(s.types, typ)
+}
+
+// Bus fans events out to handlers and subscribers. Publishing never
+// waits for a subscriber: one whose queue is full is dropped instead of
+// holding up the producer.
+type Bus[E Event] struct {
+	buffer int
+
+	mu       sync.Mutex
+	handlers []Handler[E]
+	subs     map[*Subscription[E]]struct{}
+	encode   func(E) ([]byte, error)
+}
+
+// NewBus creates a bus queueing up to buffer events per subscriber
+func NewBus[E Event](buffer int) *Bus[E] {
+	return &Bus[E]{buffer: buffer, subs: make(map[*Subscription[E]]struct{})}
+}
+
+// Encode sets how events are encoded for subscribers; the default is
+// json.Marshal
+func (b *Bus[E]) Encode(f func(E) ([]byte, error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.encode = f
+}
+
+// Handle adds a sink called for every event, in the order added
+func (b *Bus[E]) Handle(h Handler[E]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Subscribe queues events of types, or of every type if none
+func (b *Bus[E]) Subscribe(types []string) *Subscription[E] {
+	s := &Subscription[E]{types: types, send: make(chan Message[E], b.buffer), dropped: make(chan struct{})}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Unsubscribe stops queueing events for s
+func (b *Bus[E]) Unsubscribe(s *Subscription[E]) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// Publish passes ev to every handler, then queues it for every
+// subscriber that wants its type
+func (b *Bus[E]) Publish(ctx context.Context, ev E) {
+	b.mu.Lock()
+	handlers := slices.Clone(b.handlers)
+	b.mu.Unlock()
+	for _, h := range handlers {
+		h(ctx, ev)
+	}
+	b.Relay(ev)
+}
+
+// Relay queues ev for subscribers only. It is for events published on
+// another bus, such as another process's, whose handlers have already
+// run there.
+func (b *Bus[E]) Relay(ev E) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var data []byte
+	for s := range b.subs {
+		if !s.Wants(ev.EventType()) {
+			continue
+		}
+		if data == nil {
+			encode := b.encode
+			if encode == nil {
+				encode = func(ev E) ([]byte, error) { return json.Marshal(ev) }
+			}
+			var err error
+			if data, err = encode(ev); err != nil {
+				return
+			}
+		}
+		select {
+		case s.send <- Message[E]{Event: ev, Data: data}:
+		default:
+			delete(b.subs, s)
+			close(s.dropped)
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers
+func (b *Bus[E]) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
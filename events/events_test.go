@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+type testEvent struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+}
+
+func (e testEvent) EventType() string { return e.Type }
+
+func TestBusDropsSlowSubscribers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	b := NewBus[testEvent](4)
+	slow := b.Subscribe(nil)
+	other := b.Subscribe([]string{"deleted"})
+	for i := 0; i <= 4; i++ {
+		b.Publish(context.Background(), testEvent{ID: i + 1, Type: "created"})
+	}
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatal("expected the subscriber with a full queue to be dropped")
+	}
+	if m := <-slow.Events(); m.Event.ID != 1 || string(m.Data) != `{"id":1,"type":"created"}` {
+		t.Errorf("expected the first event and its JSON, got %+v %s", m.Event, m.Data)
+	}
+	if len(other.Events()) != 0 {
+		t.Error("expected events of other types to be filtered out")
+	}
+	if n := b.Subscribers(); n != 1 {
+		t.Errorf("expected one subscriber left, got %d", n)
+	}
+}
+
+func TestBusHandlers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	type key struct{}
+	b := NewBus[testEvent](1)
+	var got []string
+	b.Handle(func(ctx context.Context, ev testEvent) {
+		got = append(got, "first:"+ev.Type+":"+ctx.Value(key{}).(string))
+	})
+	b.Handle(func(_ context.Context, ev testEvent) {
+		got = append(got, "second:"+ev.Type)
+	})
+
+	// Handlers see every event, with the publisher's context, in order
+	b.Publish(context.WithValue(context.Background(), key{}, "req-1"), testEvent{Type: "created"})
+	if want := []string{"first:created:req-1", "second:created"}; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Relayed events only reach subscribers
+	sub := b.Subscribe(nil)
+	b.Relay(testEvent{Type: "deleted"})
+	if len(got) != 2 || len(sub.Events()) != 1 {
+		t.Errorf("expected the relayed event to skip handlers, got %v and %d queued", got, len(sub.Events()))
+	}
+}
+
+func TestBusEncode(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	b := NewBus[testEvent](1)
+	b.Encode(func(ev testEvent) ([]byte, error) { return []byte(ev.Type), nil })
+	sub := b.Subscribe(nil)
+	b.Publish(context.Background(), testEvent{Type: "created"})
+	if m := <-sub.Events(); string(m.Data) != "created" {
+		t.Errorf("expected the event encoded by the encoder, got %s", m.Data)
+	}
+}
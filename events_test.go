@@ -0,0 +1,73 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUserEventFor(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	bob, _ := json.Marshal(User{ID: 2, Name: "Bob", Tenant: "acme"})
+	tests := []struct {
+		entry AuditEntry
+		ok    bool
+		want  UserEvent
+	}{
+		{AuditEntry{ID: 1, Action: AuditCreate, Resource: "user", ResourceID: "2", After: bob},
+			true, UserEvent{ID: 1, Type: EventUserCreated, UserID: 2, User: bob, tenant: "acme"}},
+		{AuditEntry{ID: 2, Action: AuditDelete, Resource: "user", ResourceID: "2", Before: bob},
+			true, UserEvent{ID: 2, Type: EventUserDeleted, UserID: 2, User: bob, tenant: "acme"}},
+		{AuditEntry{ID: 3, Action: AuditCreate, Resource: "api_key", ResourceID: "2"}, false, UserEvent{}},
+		{AuditEntry{ID: 4, Action: AuditLogin, Resource: "user", ResourceID: "2"}, false, UserEvent{}},
+	}
+	for _, tt := range tests {
+		ev, ok := userEventFor(tt.entry)
+		if ok != tt.ok || ev.ID != tt.want.ID || ev.Type != tt.want.Type || ev.UserID != tt.want.UserID ||
+			string(ev.User) != string(tt.want.User) || ev.tenant != tt.want.tenant {
+			t.Errorf("entry %d: expected %+v, %t; got %+v, %t", tt.entry.ID, tt.want, tt.ok, ev, ok)
+		}
+	}
+}
+
+func TestEventSink(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var got []UserEvent
+	h := NewServer(WithEventSink(func(_ context.Context, ev UserEvent) {
+		got = append(got, ev)
+	})).Routes()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+	if len(got) != 2 || got[0].Type != EventUserCreated || got[1].Type != EventUserDeleted || got[1].UserID != 1 {
+		t.Errorf("expected Alice's creation and deletion, got %+v", got)
+	}
+}
+
+func TestEventRenderOptions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	p := newKafkaPublisher(&fakeKafka{})
+	s := NewServer(WithIDFormat(IDFormatString), WithTimestampPrecision(time.Second), WithKafka(p),
+		WithClock(NewSimulatedClock(time.Date(2030, 1, 1, 0, 0, 0, 123456789, time.UTC))))
+	sub := s.events.Subscribe(nil)
+	defer s.events.Unsubscribe(sub)
+	s.Routes().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`)))
+
+	// Subscribers, webhooks, Kafka and NATS all get what responses get
+	m := <-sub.Events()
+	webhook, _ := s.encodeEvent(m.Event)
+	for name, data := range map[string][]byte{"subscriber": m.Data, "webhook": webhook, "kafka": (<-p.queue).Value} {
+		got := string(data)
+		if !strings.Contains(got, `"user_id":"1"`) || !strings.Contains(got, `"occurred_at":"2030-01-01T00:00:00Z"`) || strings.HasSuffix(got, "\n") {
+			t.Errorf("%s: expected string IDs and whole seconds, got %s", name, got)
+		}
+	}
+}
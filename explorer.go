@@ -0,0 +1,29 @@
+package quickserve
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// explorerPath is where the API explorer is served
+const explorerPath = "/docs/"
+
+//go:embed explorer
+var explorerFiles embed.FS
+
+// WithDocs serves an interactive API explorer at /docs/, driven by
+// /openapi.json. It sends requests with the visitor's credentials, so it
+// grants nothing by itself, but it advertises the API; leave it off in
+// production unless that is wanted.
+func WithDocs() Option {
+	return func(s *Server) {
+		s.docs = true
+	}
+}
+
+// handleExplorer serves the explorer's embedded page and assets
+func handleExplorer() http.Handler {
+	files, _ := fs.Sub(explorerFiles, "explorer")
+	return http.StripPrefix(explorerPath, http.FileServerFS(files))
+}
@@ -0,0 +1,127 @@
+// The explorer lists the operations in openapi.json and sends requests
+// to them with the credentials entered at the top of the page.
+"use strict";
+
+const specURL = new URL("../openapi.json", document.baseURI);
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  Object.assign(e, attrs);
+  e.append(...children);
+  return e;
+}
+
+// resolve follows a local $ref such as #/components/schemas/User
+function resolve(spec, schema) {
+  while (schema && schema.$ref) {
+    schema = schema.$ref.slice(2).split("/").reduce((o, k) => o[k], spec);
+  }
+  return schema || {};
+}
+
+// example builds a placeholder value for a schema, to edit before sending
+function example(spec, schema, depth = 0) {
+  schema = resolve(spec, schema);
+  if (schema.enum) return schema.enum[0];
+  if (schema.oneOf) return example(spec, schema.oneOf[0], depth);
+  switch (schema.type) {
+    case "object": {
+      const obj = {};
+      if (depth < 3) {
+        for (const [name, prop] of Object.entries(schema.properties || {})) {
+          obj[name] = example(spec, prop, depth + 1);
+        }
+      }
+      return obj;
+    }
+    case "array": return depth < 3 ? [example(spec, schema.items, depth + 1)] : [];
+    case "integer": case "number": return 0;
+    case "boolean": return false;
+    case "string": return schema.format === "date-time" ? new Date().toISOString() : "";
+  }
+  return null;
+}
+
+function credentials() {
+  const headers = {};
+  const key = document.getElementById("api-key").value;
+  const token = document.getElementById("bearer").value;
+  if (key) headers["X-API-Key"] = key;
+  if (token) headers["Authorization"] = "Bearer " + token;
+  return headers;
+}
+
+function operation(spec, path, method, op) {
+  const params = (op.parameters || []).map((p) =>
+    el("label", {}, p.name + " ", el("input", { name: p.name, required: p.required })));
+  const query = el("label", {}, "query ", el("input", { name: "?", placeholder: "id_format=string" }));
+  let body = null;
+  const content = op.requestBody && op.requestBody.content["application/json"];
+  if (content) {
+    body = el("textarea", { value: JSON.stringify(example(spec, content.schema), null, 2) });
+  }
+  const output = el("pre", { hidden: true });
+
+  const send = async (event) => {
+    event.preventDefault();
+    let url = path.replace(/\{(\w+)\}/g, (_, name) =>
+      encodeURIComponent(form.elements[name].value));
+    if (form.elements["?"].value) url += "?" + form.elements["?"].value;
+    const init = { method: method.toUpperCase(), headers: credentials() };
+    if (body) {
+      init.headers["Content-Type"] = "application/json";
+      init.body = body.value;
+    }
+    output.hidden = false;
+    output.textContent = init.method + " " + url + "\n…";
+    try {
+      const resp = await fetch(new URL(url, specURL), init);
+      const lines = [resp.status + " " + resp.statusText];
+      resp.headers.forEach((v, k) => lines.push(k + ": " + v));
+      let text = await resp.text();
+      try { text = JSON.stringify(JSON.parse(text), null, 2); } catch { /* not JSON */ }
+      output.textContent = lines.join("\n") + "\n\n" + text;
+    } catch (err) {
+      output.textContent = String(err);
+    }
+  };
+
+  const form = el("form", { onsubmit: send }, ...params, query, body || "",
+    el("button", { type: "submit" }, "Send"));
+  const summary = el("summary", {},
+    el("span", { className: "method" }, method.toUpperCase()),
+    el("span", { className: "path" }, path),
+    el("span", { className: "summary" }, op.summary || ""));
+  const details = el("details", { className: method + (op.deprecated ? " deprecated" : "") }, summary,
+    el("div", { className: "operation" }, el("p", {}, op.description || ""), form, output));
+  return details;
+}
+
+async function main() {
+  const container = document.getElementById("operations");
+  let spec;
+  try {
+    const resp = await fetch(specURL);
+    spec = await resp.json();
+  } catch (err) {
+    container.textContent = "Could not load openapi.json: " + err;
+    return;
+  }
+  document.getElementById("version").textContent = spec.info.version;
+  document.getElementById("description").textContent = spec.info.description || "";
+
+  const byTag = new Map();
+  for (const path of Object.keys(spec.paths).sort()) {
+    for (const [method, op] of Object.entries(spec.paths[path])) {
+      const tag = (op.tags && op.tags[0]) || "other";
+      if (!byTag.has(tag)) byTag.set(tag, []);
+      byTag.get(tag).push(operation(spec, path, method, op));
+    }
+  }
+  container.replaceChildren();
+  for (const tag of [...byTag.keys()].sort()) {
+    container.append(el("h2", {}, tag), ...byTag.get(tag));
+  }
+}
+
+main();
@@ -0,0 +1,35 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestExplorer(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	get := func(server *Server, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithDocs())
+	if w := get(server, "/docs"); w.Header().Get("Location") != "/docs/" {
+		t.Errorf("expected /docs to redirect to /docs/, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := get(server, "/docs/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `src="explorer.js"`) {
+		t.Errorf("expected the explorer page without credentials, got %d", w.Code)
+	}
+	if w := get(server, "/docs/explorer.js"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "openapi.json") {
+		t.Errorf("expected the script to load the OpenAPI document, got %d", w.Code)
+	}
+
+	if w := get(NewServer(), "/docs/"); w.Code != http.StatusNotFound {
+		t.Errorf("expected no explorer unless enabled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,121 @@
+// Package fixtures builds test data for quickserve: users from a fluent
+// builder, and whole scenarios of users, orgs, teams and API keys loaded
+// from JSON. It is meant for integration tests against
+// quickserve.Embedded, e.g.
+//
+//	alice := fixtures.NewUser().WithName("Alice").WithRole(quickserve.RoleAdmin).MustPersist(t, e.Users())
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/harshakonda/quickserve"
+)
+
+// seq numbers the defaults of built users so they never collide
+var seq atomic.Int64
+
+// UserBuilder describes a user to create. Anything not set gets a unique
+// default, so tests only spell out what they check.
+type UserBuilder struct {
+	user     quickserve.User
+	password string
+}
+
+// NewUser starts a user named "User N" with email userN@example.com and
+// the user role
+func NewUser() *UserBuilder {
+	n := seq.Add(1)
+	return &UserBuilder{user: quickserve.User{
+		Name:  fmt.Sprintf("User %d", n),
+		Email: fmt.Sprintf("user%d@example.com", n),
+		Role:  quickserve.RoleUser,
+	}}
+}
+
+// WithName sets the display name
+func (b *UserBuilder) WithName(name string) *UserBuilder {
+	b.user.Name = name
+	return b
+}
+
+// WithEmail sets the email address
+func (b *UserBuilder) WithEmail(email string) *UserBuilder {
+	b.user.Email = email
+	return b
+}
+
+// WithRole sets the role
+func (b *UserBuilder) WithRole(role quickserve.Role) *UserBuilder {
+	b.user.Role = role
+	return b
+}
+
+// WithTenant places the user in a tenant
+func (b *UserBuilder) WithTenant(tenant string) *UserBuilder {
+	b.user.Tenant = tenant
+	return b
+}
+
+// WithLocale sets the preferred locale, e.g. "de"
+func (b *UserBuilder) WithLocale(locale string) *UserBuilder {
+	b.user.Locale = locale
+	return b
+}
+
+// WithPassword lets the user log in with password
+func (b *UserBuilder) WithPassword(password string) *UserBuilder {
+	b.password = password
+	return b
+}
+
+// Build returns the user without storing it
+func (b *UserBuilder) Build() (quickserve.User, error) {
+	u := b.user
+	if !u.Role.Valid() {
+		return quickserve.User{}, fmt.Errorf("fixtures: invalid role %q", u.Role)
+	}
+	if b.password != "" {
+		if err := u.SetPassword(b.password); err != nil {
+			return quickserve.User{}, fmt.Errorf("fixtures: %w", err)
+		}
+	}
+	return u, nil
+}
+
+// Persist inserts the user into store, ignoring user limits, and returns
+// it with its ID
+func (b *UserBuilder) Persist(store quickserve.UserStore) (quickserve.User, error) {
+	return b.PersistContext(context.Background(), store)
+}
+
+// PersistContext is Persist with a context for the store call
+func (b *UserBuilder) PersistContext(ctx context.Context, store quickserve.UserStore) (quickserve.User, error) {
+	u, err := b.Build()
+	if err != nil {
+		return quickserve.User{}, err
+	}
+	return store.Insert(ctx, u, 0, 0)
+}
+
+// Create adds the user to e within its user limits, as POST /users would
+func (b *UserBuilder) Create(ctx context.Context, e *quickserve.Embedded) (quickserve.User, error) {
+	u := b.user
+	if !u.Role.Valid() {
+		return quickserve.User{}, fmt.Errorf("fixtures: invalid role %q", u.Role)
+	}
+	return e.CreateUser(ctx, u, b.password)
+}
+
+// MustPersist is Persist for tests, failing tb on error
+func (b *UserBuilder) MustPersist(tb testing.TB, store quickserve.UserStore) quickserve.User {
+	tb.Helper()
+	u, err := b.Persist(store)
+	if err != nil {
+		tb.Fatalf("persist user: %v", err)
+	}
+	return u
+}
@@ -0,0 +1,52 @@
+package fixtures_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/fixtures"
+)
+
+func TestUserBuilder(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := quickserve.NewMemoryUserStore()
+	alice := fixtures.NewUser().WithName("Alice").WithRole(quickserve.RoleAdmin).WithTenant("acme").MustPersist(t, store)
+	if alice.ID == 0 || alice.Name != "Alice" || alice.Role != quickserve.RoleAdmin || alice.Tenant != "acme" {
+		t.Errorf("unexpected user: %+v", alice)
+	}
+
+	a := fixtures.NewUser().MustPersist(t, store)
+	b := fixtures.NewUser().MustPersist(t, store)
+	if a.Email == b.Email || a.Name == "" || a.Role != quickserve.RoleUser {
+		t.Errorf("expected distinct defaults, got %+v and %+v", a, b)
+	}
+
+	if _, err := fixtures.NewUser().WithRole("owner").Persist(store); err == nil {
+		t.Error("expected an invalid role to be refused")
+	}
+	if _, err := fixtures.NewUser().WithPassword("short").Persist(store); err == nil {
+		t.Error("expected a short password to be refused")
+	}
+}
+
+func TestUserBuilderCreate(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	e, err := quickserve.NewEmbedded(quickserve.WithMaxUsers(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := fixtures.NewUser().Create(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	_, err = fixtures.NewUser().Create(ctx, e)
+	var quota *quickserve.QuotaError
+	if !errors.As(err, &quota) {
+		t.Errorf("expected Create to respect the user limit, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/harshakonda/quickserve"
+)
+
+// Scenario is a named set of users, orgs and API keys to load into an
+// embedded instance. Users and teams are referred to by key, e.g.
+//
+//	{
+//	  "users": [{"key": "alice", "name": "Alice", "role": "admin", "password": "correct horse"}],
+//	  "orgs": [{"key": "acme", "name": "Acme",
+//	    "teams": [{"key": "eng", "name": "Engineering"}],
+//	    "members": [{"user": "alice", "team": "eng", "role": "admin"}]}],
+//	  "api_keys": [{"name": "ci", "secret": "ci-secret", "role": "admin"}]
+//	}
+type Scenario struct {
+	Users   []ScenarioUser   `json:"users"`
+	Orgs    []ScenarioOrg    `json:"orgs"`
+	APIKeys []ScenarioAPIKey `json:"api_keys"`
+}
+
+// ScenarioUser is a user in a scenario. Empty fields get the defaults of
+// NewUser.
+type ScenarioUser struct {
+	Key      string          `json:"key"`
+	Name     string          `json:"name"`
+	Email    string          `json:"email"`
+	Role     quickserve.Role `json:"role"`
+	Tenant   string          `json:"tenant"`
+	Locale   string          `json:"locale"`
+	Password string          `json:"password"`
+}
+
+// ScenarioOrg is an org with its teams and members
+type ScenarioOrg struct {
+	Key     string           `json:"key"`
+	Name    string           `json:"name"`
+	Teams   []ScenarioTeam   `json:"teams"`
+	Members []ScenarioMember `json:"members"`
+}
+
+// ScenarioTeam is a team, nested under the team keyed Parent if set.
+// Parents must come before their children.
+type ScenarioTeam struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+}
+
+// ScenarioMember gives a user a role in the org, or in one of its teams
+type ScenarioMember struct {
+	User string          `json:"user"`
+	Team string          `json:"team"`
+	Role quickserve.Role `json:"role"`
+}
+
+// ScenarioAPIKey is an API key with a known secret
+type ScenarioAPIKey struct {
+	Name   string          `json:"name"`
+	Secret string          `json:"secret"`
+	Role   quickserve.Role `json:"role"`
+}
+
+// Loaded is what applying a scenario created, by key
+type Loaded struct {
+	Users   map[string]quickserve.User
+	Orgs    map[string]quickserve.Org
+	Teams   map[string]quickserve.Team
+	APIKeys map[string]quickserve.APIKey
+}
+
+// LoadScenario reads a scenario from a JSON file
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc, err := ParseScenario(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// ParseScenario decodes a scenario, rejecting unknown fields so typos do
+// not silently drop data
+func ParseScenario(r io.Reader) (*Scenario, error) {
+	var sc Scenario
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sc); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Apply creates the scenario in e. Users go through e.CreateUser, so the
+// instance's user limits apply.
+func (sc *Scenario) Apply(ctx context.Context, e *quickserve.Embedded) (*Loaded, error) {
+	l := &Loaded{
+		Users:   make(map[string]quickserve.User),
+		Orgs:    make(map[string]quickserve.Org),
+		Teams:   make(map[string]quickserve.Team),
+		APIKeys: make(map[string]quickserve.APIKey),
+	}
+
+	for _, su := range sc.Users {
+		if su.Key == "" {
+			return nil, fmt.Errorf("fixtures: user without a key")
+		}
+		if _, dup := l.Users[su.Key]; dup {
+			return nil, fmt.Errorf("fixtures: duplicate user %q", su.Key)
+		}
+		b := NewUser().WithTenant(su.Tenant).WithLocale(su.Locale).WithPassword(su.Password)
+		if su.Name != "" {
+			b.WithName(su.Name)
+		}
+		if su.Email != "" {
+			b.WithEmail(su.Email)
+		}
+		if su.Role != "" {
+			b.WithRole(su.Role)
+		}
+		u, err := b.Create(ctx, e)
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: user %q: %w", su.Key, err)
+		}
+		l.Users[su.Key] = u
+	}
+
+	for _, so := range sc.Orgs {
+		if _, dup := l.Orgs[so.Key]; dup || so.Key == "" {
+			return nil, fmt.Errorf("fixtures: org key %q is empty or taken", so.Key)
+		}
+		org := e.Orgs().CreateOrg(so.Name)
+		l.Orgs[so.Key] = org
+
+		for _, st := range so.Teams {
+			if _, dup := l.Teams[st.Key]; dup || st.Key == "" {
+				return nil, fmt.Errorf("fixtures: team key %q is empty or taken", st.Key)
+			}
+			var parent quickserve.ID
+			if st.Parent != "" {
+				p, ok := l.Teams[st.Parent]
+				if !ok || p.OrgID != org.ID {
+					return nil, fmt.Errorf("fixtures: team %q: no parent %q in org %q", st.Key, st.Parent, so.Key)
+				}
+				parent = p.ID
+			}
+			team, err := e.Orgs().CreateTeam(org.ID, parent, st.Name)
+			if err != nil {
+				return nil, fmt.Errorf("fixtures: team %q: %w", st.Key, err)
+			}
+			l.Teams[st.Key] = team
+		}
+
+		for _, m := range so.Members {
+			u, ok := l.Users[m.User]
+			if !ok {
+				return nil, fmt.Errorf("fixtures: org %q: no user %q", so.Key, m.User)
+			}
+			var team quickserve.ID
+			if m.Team != "" {
+				t, ok := l.Teams[m.Team]
+				if !ok || t.OrgID != org.ID {
+					return nil, fmt.Errorf("fixtures: org %q: no team %q", so.Key, m.Team)
+				}
+				team = t.ID
+			}
+			if _, err := e.Orgs().SetMember(org.ID, team, u.ID, m.Role); err != nil {
+				return nil, fmt.Errorf("fixtures: org %q: member %q: %w", so.Key, m.User, err)
+			}
+		}
+	}
+
+	for _, k := range sc.APIKeys {
+		if _, dup := l.APIKeys[k.Name]; dup || k.Name == "" || k.Secret == "" {
+			return nil, fmt.Errorf("fixtures: API key %q needs a unique name and a secret", k.Name)
+		}
+		role := k.Role
+		if role == "" {
+			role = quickserve.RoleUser
+		}
+		l.APIKeys[k.Name] = e.APIKeys().Import(k.Name, k.Secret, role)
+	}
+	return l, nil
+}
+
+// MustLoad loads the scenario file at path into e, failing tb on error
+func MustLoad(tb testing.TB, e *quickserve.Embedded, path string) *Loaded {
+	tb.Helper()
+	sc, err := LoadScenario(path)
+	if err != nil {
+		tb.Fatalf("load scenario: %v", err)
+	}
+	l, err := sc.Apply(context.Background(), e)
+	if err != nil {
+		tb.Fatalf("apply scenario %s: %v", path, err)
+	}
+	return l
+}
@@ -0,0 +1,82 @@
+package fixtures_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/fixtures"
+)
+
+func TestScenario(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	e, err := quickserve.NewEmbedded(quickserve.WithAPIKeyAuth(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := fixtures.MustLoad(t, e, "testdata/acme.json")
+
+	alice, bob := l.Users["alice"], l.Users["bob"]
+	if alice.Role != quickserve.RoleAdmin || bob.Role != quickserve.RoleUser || bob.Email == "" {
+		t.Errorf("unexpected users: %+v, %+v", alice, bob)
+	}
+	acme, platform := l.Orgs["acme"], l.Teams["platform"]
+	if platform.ParentID != l.Teams["eng"].ID {
+		t.Errorf("expected platform under engineering, got %+v", platform)
+	}
+	if role, ok := e.Orgs().RoleIn(acme.ID, 0, alice.ID); !ok || role != quickserve.RoleAdmin {
+		t.Errorf("expected Alice to administer Acme, got %q", role)
+	}
+	if _, ok := e.Orgs().Member(acme.ID, platform.ID, bob.ID); !ok {
+		t.Error("expected Bob in the platform team")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://quickserve/users/"+bob.ID.String(), nil)
+	req.Header.Set(quickserve.APIKeyHeader, "ci-secret")
+	resp, err := e.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the scenario's API key to authenticate, got %d", resp.StatusCode)
+	}
+
+	resp, err = e.Client().Post("http://quickserve/login", "application/json",
+		strings.NewReader(`{"email":"alice@acme.test","password":"correct horse battery"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected Alice to log in with her scenario password, got %d", resp.StatusCode)
+	}
+}
+
+func TestScenarioRejected(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tests := map[string]string{
+		"unknown field":  `{"users":[{"key":"a","nmae":"A"}]}`,
+		"duplicate user": `{"users":[{"key":"a"},{"key":"a"}]}`,
+		"unknown member": `{"orgs":[{"key":"o","name":"O","members":[{"user":"ghost","role":"user"}]}]}`,
+		"unknown parent": `{"orgs":[{"key":"o","name":"O","teams":[{"key":"t","name":"T","parent":"x"}]}]}`,
+	}
+	for name, doc := range tests {
+		e, err := quickserve.NewEmbedded()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, err := fixtures.ParseScenario(strings.NewReader(doc))
+		if err == nil {
+			_, err = sc.Apply(context.Background(), e)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
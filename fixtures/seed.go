@@ -0,0 +1,47 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/harshakonda/quickserve"
+)
+
+// Populate inserts the users of the seed file at path into store, ignoring
+// user limits, as a server started with -seed would create them. Users
+// are keyed by their key, or by email if they have none.
+func Populate(ctx context.Context, store quickserve.UserStore, path string) (map[string]quickserve.User, error) {
+	seed, err := quickserve.LoadSeed(path)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]quickserve.User, len(seed.Users))
+	for _, su := range seed.Users {
+		key := su.Key
+		if key == "" {
+			key = su.Email
+		}
+		if _, dup := users[key]; dup {
+			return nil, fmt.Errorf("fixtures: duplicate user %q", key)
+		}
+		u, err := su.User()
+		if err != nil {
+			return nil, fmt.Errorf("fixtures: user %q: %w", key, err)
+		}
+		if users[key], err = store.Insert(ctx, u, 0, 0); err != nil {
+			return nil, fmt.Errorf("fixtures: user %q: %w", key, err)
+		}
+	}
+	return users, nil
+}
+
+// MustPopulate is Populate for tests, failing tb on error
+func MustPopulate(tb testing.TB, store quickserve.UserStore, path string) map[string]quickserve.User {
+	tb.Helper()
+	users, err := Populate(context.Background(), store, path)
+	if err != nil {
+		tb.Fatalf("populate store: %v", err)
+	}
+	return users
+}
@@ -0,0 +1,31 @@
+package fixtures_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/fixtures"
+)
+
+func TestPopulate(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := quickserve.NewMemoryUserStore()
+	users := fixtures.MustPopulate(t, store, "testdata/users.json")
+	if len(users) != 7 {
+		t.Fatalf("expected the 7 users of the file, got %d", len(users))
+	}
+	emma := users["emma"]
+	if emma.ID == 0 || emma.Role != quickserve.RoleAdmin || emma.Tenant != "acme" || emma.Locale != "de-CH" {
+		t.Errorf("unexpected user: %+v", emma)
+	}
+	if stored, ok, _ := store.Get(context.Background(), emma.ID); !ok || stored.Email != emma.Email {
+		t.Errorf("expected emma in the store, got %+v", stored)
+	}
+
+	if _, err := fixtures.Populate(context.Background(), store, "testdata/acme.json"); err == nil {
+		t.Error("expected a scenario file to be refused as a seed")
+	}
+}
@@ -0,0 +1,265 @@
+// Package golden compares HTTP responses against golden files, so changes
+// to the shape of a response show up as a failing test and a reviewable
+// diff. Run the tests with -update to rewrite the files:
+//
+//	go test -run TestGolden . -update
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Update rewrites golden files instead of comparing against them
+var Update = flag.Bool("update", false, "rewrite golden files with the current responses")
+
+// maskedValue replaces the values of scrubbed fields
+const maskedValue = "<scrubbed>"
+
+// Case is one canonical request
+type Case struct {
+	// Name is the golden file name, without the .golden extension
+	Name string
+	// Route is the pattern the request exercises, e.g. "GET /users/{id}",
+	// for checking that every route is covered
+	Route  string
+	Method string
+	Path   string
+	Body   string
+	Header http.Header
+	// Scrub adds fields to mask in this response only
+	Scrub []string
+}
+
+// Harness runs cases against a handler. Cases run in order on the
+// same handler, so later ones see what earlier ones created.
+type Harness struct {
+	// Handler serves the requests
+	Handler http.Handler
+	// Dir holds the golden files; testdata/golden if empty
+	Dir string
+	// Header is sent with every request, under the case's own headers
+	Header http.Header
+	// Headers lists the response headers recorded; Content-Type and
+	// Location if empty
+	Headers []string
+	// Scrub names JSON fields whose values vary between runs, such as
+	// generated secrets; they are replaced wherever they appear
+	Scrub []string
+}
+
+// Run serves each case as a subtest and compares the canonical response
+// with its golden file
+func (h *Harness) Run(t *testing.T, cases []Case) {
+	t.Helper()
+	dir := h.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "golden")
+	}
+	seen := make(map[string]bool)
+	for _, c := range cases {
+		if seen[c.Name] {
+			t.Fatalf("duplicate golden case %q", c.Name)
+		}
+		seen[c.Name] = true
+
+		t.Run(c.Name, func(t *testing.T) {
+			got := h.serve(c)
+			path := filepath.Join(dir, c.Name+".golden")
+			if *Update {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v; run with -update to create it", err)
+			}
+			if d := Diff(string(want), string(got)); d != "" {
+				t.Errorf("response differs from %s (-want +got); run with -update if the change is intended:\n%s", path, d)
+			}
+		})
+	}
+}
+
+// serve makes the request for c and renders the canonical response
+func (h *Harness) serve(c Case) []byte {
+	req := httptest.NewRequest(c.Method, c.Path, strings.NewReader(c.Body))
+	for _, hdr := range []http.Header{h.Header, c.Header} {
+		for k, vs := range hdr {
+			req.Header.Del(k)
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+	}
+	if c.Body != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.Handler.ServeHTTP(w, req)
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	headers := h.Headers
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Location"}
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s %s\n", c.Method, c.Path)
+	fmt.Fprintf(&out, "HTTP %d\n", resp.StatusCode)
+	for _, k := range headers {
+		if v := resp.Header.Values(k); len(v) > 0 {
+			fmt.Fprintf(&out, "%s: %s\n", http.CanonicalHeaderKey(k), strings.Join(v, ", "))
+		}
+	}
+	out.WriteString("\n")
+	out.Write(canonicalBody(body, append(slices.Clip(h.Scrub), c.Scrub...)))
+	if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
+
+// canonicalBody indents JSON with its secrets masked, and summarizes
+// binary bodies, which diff poorly
+func canonicalBody(body []byte, fields []string) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+	var v any
+	if json.Unmarshal(body, &v) == nil {
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(scrub(v, fields))
+		return out.Bytes()
+	}
+	if !utf8.Valid(body) {
+		return []byte(fmt.Sprintf("<%d bytes of binary data>", len(body)))
+	}
+	for _, field := range fields {
+		re := regexp.MustCompile(`(?m)(` + regexp.QuoteMeta(field) + `[=:] ?)\S+`)
+		body = re.ReplaceAll(body, []byte("${1}"+maskedValue))
+	}
+	return body
+}
+
+// scrub masks the values of fields anywhere in a decoded JSON document
+func scrub(v any, fields []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			if slices.Contains(fields, k) && x != nil {
+				v[k] = maskedValue
+				continue
+			}
+			v[k] = scrub(x, fields)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = scrub(x, fields)
+		}
+	}
+	return v
+}
+
+// Diff returns a line diff of want and got, or "" if they are equal.
+// Unchanged lines are shown around each change for context.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	const context = 2
+	var out strings.Builder
+	last := -1
+	for k, l := range lines {
+		near := false
+		for d := max(0, k-context); d <= min(len(lines)-1, k+context); d++ {
+			if lines[d].op != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			continue
+		}
+		if last >= 0 && k > last+1 {
+			out.WriteString("...\n")
+		}
+		fmt.Fprintf(&out, "%c %s\n", l.op, l.text)
+		last = k
+	}
+	return out.String()
+}
+
+// Missing returns the routes, as patterns, that no case exercises
+func Missing(cases []Case, routes []string) []string {
+	covered := make(map[string]bool)
+	for _, c := range cases {
+		covered[c.Route] = true
+	}
+	var missing []string
+	for _, r := range routes {
+		if !covered[r] {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
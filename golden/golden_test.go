@@ -0,0 +1,71 @@
+package golden
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestDiff(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	if d := Diff("a\nb\n", "a\nb\n"); d != "" {
+		t.Errorf("expected no diff for equal input, got %q", d)
+	}
+	want := "a\nb\nc\nd\ne\nf\ng"
+	got := "a\nb\nc\nD\ne\nf\ng"
+	expected := "  b\n  c\n- d\n+ D\n  e\n  f\n"
+	if d := Diff(want, got); d != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", d, expected)
+	}
+}
+
+func TestHarness(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /thing", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"` + r.Header.Get("X-Name") + `","secret":"random","nested":[{"secret":"x"}]}`))
+	})
+	mux.HandleFunc("GET /binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0xfe, 0x00})
+	})
+
+	dir := t.TempDir()
+	h := &Harness{Handler: mux, Dir: dir, Header: http.Header{"x-name": {"widget"}}, Scrub: []string{"secret"}}
+	cases := []Case{
+		{Name: "thing", Route: "GET /thing", Method: "GET", Path: "/thing"},
+		{Name: "binary", Route: "GET /binary", Method: "GET", Path: "/binary"},
+	}
+
+	*Update = true
+	h.Run(t, cases)
+	*Update = false
+	h.Run(t, cases)
+
+	b, err := os.ReadFile(filepath.Join(dir, "thing.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"GET /thing\nHTTP 200\nContent-Type: application/json\n", `"name": "widget"`, `"secret": "<scrubbed>"`} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("expected %q in the golden file, got:\n%s", s, b)
+		}
+	}
+	if strings.Contains(string(b), "random") {
+		t.Errorf("expected the secret to be scrubbed, got:\n%s", b)
+	}
+	b, _ = os.ReadFile(filepath.Join(dir, "binary.golden"))
+	if !strings.Contains(string(b), "<3 bytes of binary data>") {
+		t.Errorf("expected binary bodies to be summarized, got:\n%s", b)
+	}
+
+	if missing := Missing(cases, []string{"GET /thing", "GET /binary", "DELETE /thing"}); len(missing) != 1 || missing[0] != "DELETE /thing" {
+		t.Errorf("expected DELETE /thing to be reported missing, got %v", missing)
+	}
+}
@@ -0,0 +1,252 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/harshakonda/quickserve/userpb"
+)
+
+// NewGRPCServer returns a gRPC server offering userpb.UserService. Each
+// call is served by the matching route of h, the handler from
+// Server.Handler, so the REST and gRPC APIs share one store and behave
+// alike: metadata such as authorization and x-api-key is passed on as
+// headers, failures map to the closest status code, and the request ID
+// comes back in the x-request-id header. Validation failures carry a
+// BadRequest detail listing each problem.
+func NewGRPCServer(h http.Handler, opts ...grpc.ServerOption) *grpc.Server {
+	gs := grpc.NewServer(opts...)
+	userpb.RegisterUserServiceServer(gs, &userService{handler: h})
+	return gs
+}
+
+// userService implements UserService on top of the REST routes
+type userService struct {
+	userpb.UnimplementedUserServiceServer
+	handler http.Handler
+}
+
+func (us *userService) ListUsers(ctx context.Context, _ *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	var users []User
+	if err := us.call(ctx, http.MethodGet, "/users", nil, &users); err != nil {
+		return nil, err
+	}
+	resp := &userpb.ListUsersResponse{Users: make([]*userpb.User, len(users))}
+	for i, u := range users {
+		resp.Users[i] = userToProto(u)
+	}
+	return resp, nil
+}
+
+func (us *userService) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	var user User
+	if err := us.call(ctx, http.MethodGet, "/users/"+ID(req.GetId()).String(), nil, &user); err != nil {
+		return nil, err
+	}
+	return userToProto(user), nil
+}
+
+func (us *userService) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.User, error) {
+	body := createUserRequest{
+		Name:     req.GetName(),
+		Email:    req.GetEmail(),
+		Role:     Role(req.GetRole()),
+		Locale:   req.GetLocale(),
+		Password: req.GetPassword(),
+	}
+	var user User
+	if err := us.call(ctx, http.MethodPost, "/users", body, &user); err != nil {
+		return nil, err
+	}
+	return userToProto(user), nil
+}
+
+func (us *userService) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*userpb.DeleteUserResponse, error) {
+	if err := us.call(ctx, http.MethodDelete, "/users/"+ID(req.GetId()).String(), nil, nil); err != nil {
+		return nil, err
+	}
+	return &userpb.DeleteUserResponse{}, nil
+}
+
+// call serves one REST request built from a gRPC call and decodes its
+// JSON answer into out
+func (us *userService) call(ctx context.Context, method, path string, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+	r, err := http.NewRequestWithContext(ctx, method, path, &body)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		// Pseudo-headers and the gRPC protocol's own are not the caller's
+		if strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") || k == "content-type" || k == "te" {
+			continue
+		}
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	if in != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+
+	w := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	us.handler.ServeHTTP(w, r)
+	if id := w.header.Get(RequestIDHeader); id != "" {
+		grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RequestIDHeader), id))
+	}
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if w.status >= 300 {
+		var e errorBody
+		if json.Unmarshal(w.body.Bytes(), &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(w.status)
+		}
+		st := status.New(grpcCode(w.status), e.Error)
+		if len(e.Invalid) > 0 {
+			// Schema violations become a BadRequest detail, as gRPC
+			// clients expect
+			br := &errdetails.BadRequest{}
+			for _, f := range e.Invalid {
+				br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Reason})
+			}
+			if withDetails, err := st.WithDetails(br); err == nil {
+				st = withDetails
+			}
+		}
+		return st.Err()
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(w.body.Bytes(), out); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// grpcStatusCodes pairs the HTTP statuses the routes answer with the
+// gRPC codes of the same meaning. Other 4xx statuses are Unknown and
+// other 5xx ones Internal.
+var grpcStatusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusMethodNotAllowed:      codes.Unimplemented,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.ResourceExhausted,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcCode maps an HTTP status to the gRPC code clients expect for it
+func grpcCode(httpStatus int) codes.Code {
+	if code, ok := grpcStatusCodes[httpStatus]; ok {
+		return code
+	}
+	if httpStatus >= 500 {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+func userToProto(u User) *userpb.User {
+	return &userpb.User{
+		Id:        int64(u.ID),
+		Name:      u.Name,
+		Email:     u.Email,
+		Role:      string(u.Role),
+		Locale:    u.Locale,
+		Tenant:    u.Tenant,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+}
+
+// bufferedResponse collects a response served in process
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func (w *bufferedResponse) Header() http.Header { return w.header }
+
+func (w *bufferedResponse) WriteHeader(status int) {
+	if !w.wrote {
+		w.status, w.wrote = status, true
+	}
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.body.Write(b)
+}
+
+// MultiplexGRPC serves gRPC calls, HTTP/2 requests with an
+// application/grpc content type, with gs and every other request with
+// next, so REST and gRPC share a listener. Plain HTTP listeners need h2c
+// for gRPC; see EnableH2C.
+func MultiplexGRPC(gs *grpc.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			gs.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// grpcDrainer lets serveAndDrain stop a gRPC server: GracefulStop waits
+// for calls in flight, and Stop cancels those left at the timeout
+type grpcDrainer struct {
+	*grpc.Server
+}
+
+func (d grpcDrainer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d grpcDrainer) Close() error {
+	d.Stop()
+	return nil
+}
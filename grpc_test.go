@@ -0,0 +1,155 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/harshakonda/quickserve/userpb"
+)
+
+func grpcClient(t *testing.T, server *Server) userpb.UserServiceClient {
+	t.Helper()
+	h, err := server.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := NewGRPCServer(h)
+	ln := bufconn.Listen(1 << 20)
+	go gs.Serve(ln)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		gs.Stop()
+	})
+	return userpb.NewUserServiceClient(conn)
+}
+
+func TestGRPCUserService(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	c := grpcClient(t, NewServer(WithAPIKeyAuth("admin-secret")))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "admin-secret")
+
+	var header metadata.MD
+	alice, err := c.CreateUser(ctx, &userpb.CreateUserRequest{Name: "Alice", Email: "alice@test.com", Role: "admin"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Id == 0 || alice.Name != "Alice" || alice.Role != "admin" || alice.CreatedAt.AsTime().IsZero() {
+		t.Fatalf("unexpected user: %v", alice)
+	}
+	if len(header.Get("x-request-id")) != 1 {
+		t.Errorf("expected the request ID in the header, got %v", header)
+	}
+
+	if got, err := c.GetUser(ctx, &userpb.GetUserRequest{Id: alice.Id}); err != nil || got.Email != "alice@test.com" {
+		t.Errorf("expected Alice back, got %v, %v", got, err)
+	}
+	if list, err := c.ListUsers(ctx, &userpb.ListUsersRequest{}); err != nil || len(list.Users) != 1 {
+		t.Errorf("expected one user, got %v, %v", list, err)
+	}
+	if _, err := c.DeleteUser(ctx, &userpb.DeleteUserRequest{Id: alice.Id}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, &userpb.GetUserRequest{Id: alice.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound after deletion, got %v", err)
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	c := grpcClient(t, NewServer(WithAPIKeyAuth("admin-secret")))
+
+	if _, err := c.ListUsers(context.Background(), &userpb.ListUsersRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected Unauthenticated without a key, got %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "admin-secret")
+	_, err := c.CreateUser(ctx, &userpb.CreateUserRequest{Name: "Bob", Email: "bob@test.com", Role: "owner"})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument || len(st.Details()) != 1 {
+		t.Fatalf("expected InvalidArgument with details for an unknown role, got %v", err)
+	}
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	if !ok || len(br.FieldViolations) != 1 || br.FieldViolations[0].Field != "role" {
+		t.Errorf("expected a violation of role, got %v", st.Details())
+	}
+}
+
+func TestMultiplexGRPC(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	h, err := NewServer(WithAPIKeyAuth("admin-secret")).Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := NewGRPCServer(h)
+	defer gs.Stop()
+	ts := httptest.NewUnstartedServer(MultiplexGRPC(gs, h))
+	if err := EnableH2C(ts.Config); err != nil {
+		t.Fatal(err)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	conn, err := grpc.NewClient(strings.TrimPrefix(ts.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "admin-secret")
+	alice, err := userpb.NewUserServiceClient(conn).CreateUser(ctx, &userpb.CreateUserRequest{Name: "Alice", Email: "alice@test.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user created over gRPC is served over REST on the same port
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/users/"+ID(alice.Id).String(), nil)
+	req.Header.Set(APIKeyHeader, "admin-secret")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil || user.Name != "Alice" {
+		t.Errorf("expected Alice over REST, got %d %+v, %v", resp.StatusCode, user, err)
+	}
+}
+
+func TestGRPCCode(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tests := map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusNotAcceptable:       codes.Unknown,
+		http.StatusInternalServerError: codes.Internal,
+		http.StatusBadGateway:          codes.Internal,
+	}
+	for httpStatus, want := range tests {
+		if got := grpcCode(httpStatus); got != want {
+			t.Errorf("%d: expected %v, got %v", httpStatus, want, got)
+		}
+	}
+}
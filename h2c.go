@@ -0,0 +1,23 @@
+package quickserve
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// EnableH2C makes srv, a plain HTTP server, also serve HTTP/2 without
+// TLS, to clients that know it is spoken (prior knowledge, as gRPC-aware
+// proxies and meshes do) or that upgrade from HTTP/1.1. Call it after
+// setting srv.Handler and srv.IdleTimeout. srv.Shutdown sends GOAWAY on
+// HTTP/2 connections but, as they are hijacked from srv, does not wait
+// for them to finish.
+func EnableH2C(srv *http.Server) error {
+	h2s := &http2.Server{IdleTimeout: srv.IdleTimeout}
+	if err := http2.ConfigureServer(srv, h2s); err != nil {
+		return err
+	}
+	srv.Handler = h2c.NewHandler(srv.Handler, h2s)
+	return nil
+}
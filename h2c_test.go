@@ -0,0 +1,53 @@
+package quickserve
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"golang.org/x/net/http2"
+)
+
+func TestEnableH2C(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := httptest.NewUnstartedServer(NewServer().Routes())
+	if err := EnableH2C(ts.Config); err != nil {
+		t.Fatal(err)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	// Prior knowledge: HTTP/2 from the first byte, without TLS
+	h2 := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+	defer h2.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: h2}).Get(ts.URL + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("expected 200 over HTTP/2, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+
+	client := ts.Client()
+	defer client.CloseIdleConnections()
+	resp, err = client.Get(ts.URL + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("expected HTTP/1.1 clients to be served as before, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+}
@@ -0,0 +1,124 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds each readiness check, so a hung dependency
+// fails the probe instead of stalling it
+const readinessTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency is usable; a nil error means
+// ready
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// WithReadinessCheck adds a check to GET /readyz, e.g. for a database a
+// custom UserStore depends on. Checks run concurrently on every probe.
+func WithReadinessCheck(name string, check HealthCheck) Option {
+	return func(s *Server) {
+		s.readiness = append(s.readiness, namedCheck{name, check})
+	}
+}
+
+// isHealthPath reports whether path is a probe, which rate limits, drift
+// refusals and the default access log leave alone
+func isHealthPath(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/") || path == "/healthz" || path == "/readyz"
+}
+
+// builtinChecks are run by every readiness probe ahead of those added
+// with WithReadinessCheck
+func (s *Server) builtinChecks() []namedCheck {
+	return []namedCheck{
+		{"store", func(ctx context.Context) error {
+			_, _, err := s.store.Count(ctx, "")
+			return err
+		}},
+		{"schema", func(context.Context) error {
+			if drift := s.schemaDrift(); len(drift) > 0 {
+				return fmt.Errorf("%d schema drift problems; see GET /admin/schema", len(drift))
+			}
+			return nil
+		}},
+		{"shutdown", func(context.Context) error {
+			if s.Draining() {
+				return errDraining
+			}
+			return nil
+		}},
+	}
+}
+
+// CheckResult is the outcome of one readiness check
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Readiness is the body of GET /readyz
+type Readiness struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// checkReadiness runs every check and reports each one
+func (s *Server) checkReadiness(ctx context.Context) Readiness {
+	checks := append(s.builtinChecks(), s.readiness...)
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+			defer cancel()
+			results[i] = CheckResult{Name: c.name, Status: "ok"}
+			if err := c.check(ctx); err != nil {
+				if errors.Is(err, context.DeadlineExceeded) {
+					err = fmt.Errorf("no answer within %s", readinessTimeout)
+				}
+				results[i].Status, results[i].Error = "failing", err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	ready := Readiness{Status: "ok", Checks: results}
+	for _, r := range results {
+		if r.Status != "ok" {
+			ready.Status = "unavailable"
+		}
+	}
+	return ready
+}
+
+// HandleLiveness handles GET /healthz. It only shows the process is
+// serving, so orchestrators restart it when it is not; dependencies are
+// left to GET /readyz.
+func (s *Server) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// HandleReadiness handles GET /readyz, answering 503 while any check
+// fails so load balancers stop sending traffic
+func (s *Server) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	ready := s.checkReadiness(r.Context())
+	status := http.StatusOK
+	if ready.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, r, status, ready)
+}
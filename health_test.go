@@ -0,0 +1,88 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestReadiness(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var cacheDown bool
+	s := NewServer(WithReadinessCheck("cache", func(ctx context.Context) error {
+		if cacheDown {
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+	handler := s.Routes()
+
+	probe := func() (int, Readiness) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var ready Readiness
+		json.NewDecoder(w.Body).Decode(&ready)
+		return w.Code, ready
+	}
+
+	code, ready := probe()
+	if code != http.StatusOK || ready.Status != "ok" || len(ready.Checks) != 4 {
+		t.Fatalf("expected 200 with four passing checks, got %d: %+v", code, ready)
+	}
+	if ready.Checks[3].Name != "cache" {
+		t.Errorf("expected custom checks after the built-in ones, got %+v", ready.Checks)
+	}
+
+	cacheDown = true
+	code, ready = probe()
+	if code != http.StatusServiceUnavailable || ready.Status != "unavailable" {
+		t.Fatalf("expected 503 once a check fails, got %d: %+v", code, ready)
+	}
+	for _, c := range ready.Checks {
+		want := "ok"
+		if c.Name == "cache" {
+			want = "failing"
+		}
+		if c.Status != want {
+			t.Errorf("expected %s to be %s, got %+v", c.Name, want, c)
+		}
+	}
+	if ready.Checks[3].Error != "connection refused" {
+		t.Errorf("expected the check's error, got %q", ready.Checks[3].Error)
+	}
+
+	// Liveness does not depend on the checks
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "OK" {
+		t.Errorf("expected /healthz to stay up, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestReadinessStoreUnreachable(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer(WithUserStore(unreachableUserStore{NewMemoryUserStore()}))
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var ready Readiness
+	json.NewDecoder(w.Body).Decode(&ready)
+	if w.Code != http.StatusServiceUnavailable || ready.Checks[0].Name != "store" || ready.Checks[0].Status != "failing" {
+		t.Errorf("expected the store check to fail, got %d: %+v", w.Code, ready)
+	}
+}
+
+// unreachableUserStore fails Count like a backend that is down
+type unreachableUserStore struct {
+	*MemoryUserStore
+}
+
+func (unreachableUserStore) Count(context.Context, string) (int, int, error) {
+	return 0, 0, errors.New("dial tcp: connection refused")
+}
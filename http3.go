@@ -0,0 +1,37 @@
+package quickserve
+
+import (
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server returns an HTTP/3 server for the HTTPS listener srv,
+// serving its handler over QUIC on the same port number. certFile and
+// keyFile are loaded unless srv's TLS settings provide certificates, as
+// with ACME. 0-RTT is off, so requests cannot be replayed.
+func newHTTP3Server(srv *http.Server, certFile, keyFile string) (*http3.Server, error) {
+	tlsConfig, err := withCertificates(srv.TLSConfig, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &http3.Server{
+		Addr:        srv.Addr,
+		Handler:     srv.Handler,
+		TLSConfig:   tlsConfig,
+		QUICConfig:  &quic.Config{},
+		IdleTimeout: srv.IdleTimeout,
+	}, nil
+}
+
+// advertiseHTTP3 adds Alt-Svc to responses sent over TCP, telling
+// clients they can switch to HTTP/3 on h3
+func advertiseHTTP3(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
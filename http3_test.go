@@ -0,0 +1,65 @@
+package quickserve
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHTTP3(t *testing.T) {
+	defer guard.VerifyNone(t)
+	t.Setenv("QUIC_GO_DISABLE_RECEIVE_BUFFER_WARNING", "true")
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cert := newTestCA(t).issue(t, "quickserve", "ops@example.com")
+	tlsConfig := defaultTLSConfig()
+	tlsConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &cert, nil }
+	srv := &http.Server{Addr: conn.LocalAddr().String(), Handler: NewServer().Routes(), TLSConfig: tlsConfig}
+	h3, err := newHTTP3Server(srv, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveAndDrain(ctx, h3, time.Second, func() error { return h3.Serve(conn) })
+	}()
+
+	client := &http3.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	resp, err := (&http.Client{Transport: client}).Get("https://" + srv.Addr + "/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 3 {
+		t.Errorf("expected 200 over HTTP/3, got %d over %s", resp.StatusCode, resp.Proto)
+	}
+
+	// Responses over TCP point clients at the QUIC port
+	w := httptest.NewRecorder()
+	advertiseHTTP3(h3, srv.Handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	_, port, _ := net.SplitHostPort(srv.Addr)
+	if got := w.Header().Get("Alt-Svc"); !strings.Contains(got, `h3=":`+port+`"`) {
+		t.Errorf("expected Alt-Svc to advertise h3 on port %s, got %q", port, got)
+	}
+
+	client.Close()
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,186 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"golang.org/x/text/language"
+)
+
+// Catalog maps message keys to text/template strings in one language
+type Catalog map[string]string
+
+// defaultCatalog is the built-in English catalog. Every key must be here,
+// since English ends every fallback chain.
+var defaultCatalog = Catalog{
+	"invite.subject": "You're invited to quickserve",
+	"invite.body": "You have been invited to join as {{.Role}}. " +
+		"{{if .URL}}Accept it here:\n\n{{.URL}}{{else}}Accept it by sending this token to POST /invitations/accept:\n\n{{.Token}}{{end}}" +
+		"\n\nThe invitation expires at {{.ExpiresAt}}.\n",
+
+	"login.subject": "New sign-in to your account",
+	"login.body": "Hello {{.Name}},\n\nYour account was signed in to via {{.Method}} at {{.Time}}.\n" +
+		"If this wasn't you, contact your administrator.\n",
+
+	"digest.subject": "Your weekly digest",
+	"digest.body": "Hello {{.Name}},\n\nHere is your activity for the week ending {{.WeekEnding}}:\n\n" +
+		"{{range .Activity}}  {{.Label}}: {{.Count}}\n{{end}}",
+
+	"activity.login":              "logins",
+	"activity.account_created":    "account created",
+	"activity.profile_updated":    "profile updates",
+	"activity.account_deleted":    "account deleted",
+	"activity.group_joined":       "groups joined",
+	"activity.group_left":         "groups left",
+	"activity.group_role_changed": "group role changes",
+}
+
+// Translator renders messages in a requested locale, falling back from
+// the locale to its parents (pt-BR, then pt), then to the server default
+// and finally English
+type Translator struct {
+	mu       sync.RWMutex
+	catalogs map[string]map[string]*template.Template
+	fallback language.Tag
+}
+
+// NewTranslator creates a translator with the built-in English catalog.
+// fallback is the locale used for users without one.
+func NewTranslator(fallback language.Tag) *Translator {
+	t := &Translator{catalogs: make(map[string]map[string]*template.Template), fallback: fallback}
+	if err := t.Add(language.English, defaultCatalog); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Add merges c into the catalog for tag, replacing existing keys
+func (t *Translator) Add(tag language.Tag, c Catalog) error {
+	compiled := make(map[string]*template.Template, len(c))
+	for key, text := range c {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %w", tag, key, err)
+		}
+		compiled[key] = tmpl
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	name := tag.String()
+	if t.catalogs[name] == nil {
+		t.catalogs[name] = make(map[string]*template.Template)
+	}
+	for key, tmpl := range compiled {
+		t.catalogs[name][key] = tmpl
+	}
+	return nil
+}
+
+// LoadDir adds every <locale>.json catalog in dir, e.g. de.json or
+// pt-BR.json, so translations can be added without rebuilding
+func (t *Translator) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		tag, err := language.Parse(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			return fmt.Errorf("%s: file name is not a locale: %w", path, err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var c Catalog
+		if err := json.Unmarshal(b, &c); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := t.Add(tag, c); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// chain lists the catalogs to try for locale, most specific first
+func (t *Translator) chain(locale string) []string {
+	var tags []language.Tag
+	if tag, err := language.Parse(locale); err == nil && locale != "" {
+		tags = append(tags, tag)
+	}
+	tags = append(tags, t.fallback, language.English)
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		for ; tag != language.Und; tag = tag.Parent() {
+			if name := tag.String(); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Render executes the message key in the best catalog for locale
+func (t *Translator) Render(locale, key string, data any) (string, error) {
+	t.mu.RLock()
+	var tmpl *template.Template
+	for _, name := range t.chain(locale) {
+		if tmpl = t.catalogs[name][key]; tmpl != nil {
+			break
+		}
+	}
+	t.mu.RUnlock()
+
+	if tmpl == nil {
+		return "", fmt.Errorf("no message %q", key)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// WithTranslator sets the catalogs user-facing text is rendered from
+func WithTranslator(t *Translator) Option {
+	return func(s *Server) {
+		s.translator = t
+	}
+}
+
+// parseLocale validates a locale from a request and returns it in
+// canonical form, e.g. "pt-br" becomes "pt-BR"
+func parseLocale(locale string) (string, error) {
+	if locale == "" {
+		return "", nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", fmt.Errorf("invalid locale %q", locale)
+	}
+	return tag.String(), nil
+}
+
+// localizedMessage renders the subject and body of the named email for a
+// recipient in locale
+func (s *Server) localizedMessage(to, locale, name string, data any) (Message, error) {
+	subject, err := s.translator.Render(locale, name+".subject", data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := s.translator.Render(locale, name+".body", data)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: subject, Body: body}, nil
+}
@@ -0,0 +1,99 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"golang.org/x/text/language"
+)
+
+func TestTranslatorFallbackChain(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tr := NewTranslator(language.German)
+	tr.Add(language.German, Catalog{"login.subject": "Neue Anmeldung", "digest.subject": "Ihre Wochenübersicht"})
+	tr.Add(language.Portuguese, Catalog{"login.subject": "Novo acesso"})
+	tr.Add(language.BrazilianPortuguese, Catalog{"digest.subject": "Seu resumo semanal"})
+
+	tests := []struct{ locale, key, want string }{
+		{"pt-BR", "digest.subject", "Seu resumo semanal"},
+		{"pt-BR", "login.subject", "Novo acesso"},
+		{"pt-BR", "invite.subject", "You're invited to quickserve"},
+		{"fr", "login.subject", "Neue Anmeldung"},
+		{"", "digest.subject", "Ihre Wochenübersicht"},
+		{"not a locale", "login.subject", "Neue Anmeldung"},
+	}
+	for _, tt := range tests {
+		got, err := tr.Render(tt.locale, tt.key, nil)
+		if err != nil || got != tt.want {
+			t.Errorf("Render(%q, %q) = %q, %v; want %q", tt.locale, tt.key, got, err, tt.want)
+		}
+	}
+	if _, err := tr.Render("de", "no.such.key", nil); err == nil {
+		t.Error("expected an unknown key to fail")
+	}
+}
+
+func TestTranslatorLoadDir(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{"invite.subject":"Einladung als {{.Role}}"}`), 0o600)
+	tr := NewTranslator(language.English)
+	if err := tr.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tr.Render("de-AT", "invite.subject", map[string]string{"Role": "admin"}); got != "Einladung als admin" {
+		t.Errorf("expected the loaded catalog to apply, got %q", got)
+	}
+
+	for name, content := range map[string]string{
+		"xx-invalid-tag-name.json": `{}`,
+		"fr.json":                  `{"invite.subject":"{{.Role"}`,
+	} {
+		bad := t.TempDir()
+		os.WriteFile(filepath.Join(bad, name), []byte(content), 0o600)
+		if err := NewTranslator(language.English).LoadDir(bad); err == nil {
+			t.Errorf("%s: expected loading to fail", name)
+		}
+	}
+}
+
+func TestLocalizedInvitation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tr := NewTranslator(language.English)
+	tr.Add(language.German, Catalog{"invite.subject": "Sie sind eingeladen"})
+	mailer := &recordingMailer{}
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithMailer(mailer), WithTranslator(tr), WithInviteURL("https://app.test/accept"))
+	routes := server.Routes()
+
+	do := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/invitations", `{"email":"max@test.com","locale":"de-de"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	msg := mailer.sent[0]
+	if msg.Subject != "Sie sind eingeladen" || !strings.Contains(msg.Body, "invited to join as user") {
+		t.Errorf("expected a German subject with the English body as fallback, got %+v", msg)
+	}
+
+	w := do("/invitations/accept", `{"token":"`+mailer.token(t)+`","name":"Max"}`)
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"locale":"de-DE"`) {
+		t.Errorf("expected the invitation's locale to carry over, got %d %s", w.Code, w.Body)
+	}
+	if w := do("/users", `{"name":"Bad","locale":"!!"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid locale, got %d", w.Code)
+	}
+}
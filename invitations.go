@@ -0,0 +1,351 @@
+package quickserve
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"errors"
+	"maps"
+	"net/http"
+	"net/mail"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultInviteExpiry is how long an invitation stays valid by default
+	defaultInviteExpiry = 7 * 24 * time.Hour
+	// maxInviteExpiry bounds the lifetime a caller may ask for
+	maxInviteExpiry = 30 * 24 * time.Hour
+)
+
+// Invitation is a pending offer for someone to register with a role.
+// Like API keys, only a hash of the token is kept.
+type Invitation struct {
+	ID         ID         `json:"id"`
+	Email      string     `json:"email"`
+	Role       Role       `json:"role"`
+	Locale     string     `json:"locale,omitempty"`
+	Tenant     string     `json:"tenant,omitempty"`
+	InvitedBy  string     `json:"invited_by,omitempty"`
+	UserID     ID         `json:"user_id,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+
+	hash [sha256.Size]byte
+}
+
+// Status summarizes where the invitation is in its lifecycle at now
+func (inv Invitation) Status(now time.Time) string {
+	switch {
+	case inv.AcceptedAt != nil:
+		return "accepted"
+	case inv.RevokedAt != nil:
+		return "revoked"
+	case !now.Before(inv.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+var (
+	errInviteNotFound = errors.New("invitation not found")
+	errInviteUsed     = errors.New("invitation is no longer valid")
+)
+
+// InvitationStore is an in-memory invitation store
+type InvitationStore struct {
+	mu      sync.RWMutex
+	invites map[ID]Invitation
+	next    ID
+	clock   Clock
+}
+
+// NewInvitationStore creates an empty invitation store
+func NewInvitationStore() *InvitationStore {
+	return &InvitationStore{
+		invites: make(map[ID]Invitation),
+		next:    1,
+		clock:   SystemClock{},
+	}
+}
+
+// Create records an invitation valid for ttl and returns it with its token
+func (s *InvitationStore) Create(email string, role Role, locale, tenant, invitedBy string, ttl time.Duration) (Invitation, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return Invitation{}, "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	inv := Invitation{
+		ID:        s.next,
+		Email:     email,
+		Role:      role,
+		Locale:    locale,
+		Tenant:    tenant,
+		InvitedBy: invitedBy,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+		hash:      sha256.Sum256([]byte(token)),
+	}
+	s.invites[inv.ID] = inv
+	s.next++
+	return inv, token, nil
+}
+
+// List returns every invitation ordered by ID
+func (s *InvitationStore) List() []Invitation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invites := make([]Invitation, 0, len(s.invites))
+	for _, inv := range s.invites {
+		invites = append(invites, inv)
+	}
+	slices.SortFunc(invites, func(a, b Invitation) int { return cmp.Compare(a.ID, b.ID) })
+	return invites
+}
+
+// Revoke invalidates a pending invitation
+func (s *InvitationStore) Revoke(id ID) (Invitation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invites[id]
+	if !ok {
+		return Invitation{}, errInviteNotFound
+	}
+	now := s.clock.Now()
+	if inv.Status(now) != "pending" {
+		return Invitation{}, errInviteUsed
+	}
+	inv.RevokedAt = &now
+	inv.UpdatedAt = now
+	s.invites[id] = inv
+	return inv, nil
+}
+
+// DeleteTenant removes the invitations to tenant, returning how many
+func (s *InvitationStore) DeleteTenant(tenant string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.invites)
+	maps.DeleteFunc(s.invites, func(_ ID, inv Invitation) bool { return inv.Tenant == tenant })
+	return n - len(s.invites)
+}
+
+// Accept redeems token, calling register to create the user while the
+// invitation is locked so it cannot be redeemed twice
+func (s *InvitationStore) Accept(token string, register func(Invitation) (User, error)) (User, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Hashes are compared directly: they are derived from 256 random bits,
+	// so a timing difference reveals nothing about any stored token
+	for id, inv := range s.invites {
+		if inv.hash != hash {
+			continue
+		}
+		now := s.clock.Now()
+		if inv.Status(now) != "pending" {
+			return User{}, errInviteUsed
+		}
+		user, err := register(inv)
+		if err != nil {
+			return User{}, err
+		}
+		inv.AcceptedAt = &now
+		inv.UpdatedAt = now
+		inv.UserID = user.ID
+		s.invites[id] = inv
+		return user, nil
+	}
+	return User{}, errInviteNotFound
+}
+
+// WithInviteURL sets the registration page that invitation emails link
+// to; the token is appended as ?token=
+func WithInviteURL(url string) Option {
+	return func(s *Server) {
+		s.inviteURL = url
+	}
+}
+
+// inviteMessage builds the email delivering an invitation, in the locale
+// the invitation was made for
+func (s *Server) inviteMessage(inv Invitation, token string) (Message, error) {
+	data := struct {
+		Role      Role
+		URL       string
+		Token     string
+		ExpiresAt string
+	}{inv.Role, "", token, inv.ExpiresAt.UTC().Format(time.RFC1123)}
+	if s.inviteURL != "" {
+		data.URL = s.inviteURL + "?token=" + token
+	}
+	return s.localizedMessage(inv.Email, inv.Locale, "invite", data)
+}
+
+// HandleCreateInvitation handles POST /invitations
+func (s *Server) HandleCreateInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email     string    `json:"email"`
+		Role      Role      `json:"role"`
+		ExpiresIn *Duration `json:"expires_in"`
+		Locale    string    `json:"locale"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		httpError(w, r, "invalid email", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleUser
+	}
+	if !req.Role.Valid() {
+		httpError(w, r, "invalid role", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultInviteExpiry
+	if req.ExpiresIn != nil {
+		ttl = time.Duration(*req.ExpiresIn)
+	}
+	if ttl <= 0 || ttl > maxInviteExpiry {
+		httpError(w, r, "expires_in must be positive and at most 720h", http.StatusBadRequest)
+		return
+	}
+
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var invitedBy string
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		invitedBy = p.Subject
+	}
+	inv, token, err := s.invitations.Create(req.Email, req.Role, locale, s.tenantFromRequest(r), invitedBy, ttl)
+	if err != nil {
+		httpError(w, r, "could not create invitation", http.StatusInternalServerError)
+		return
+	}
+
+	msg, err := s.inviteMessage(inv, token)
+	if err != nil {
+		s.invitations.Revoke(inv.ID)
+		httpError(w, r, "could not render invitation", http.StatusInternalServerError)
+		return
+	}
+	if err := s.mailer.Send(r.Context(), msg); err != nil {
+		s.invitations.Revoke(inv.ID)
+		httpError(w, r, "could not deliver invitation", http.StatusBadGateway)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "invitation", inv.ID.String(), nil, inv)
+	s.writeJSON(w, r, http.StatusCreated, inv)
+}
+
+// HandleAcceptInvitation handles POST /invitations/accept. It is public:
+// the token is the credential.
+func (s *Server) HandleAcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Name     string `json:"name"`
+		Locale   string `json:"locale"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Token == "" || req.Name == "" {
+		httpError(w, r, "token and name are required", http.StatusBadRequest)
+		return
+	}
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, ok := optionalPassword(w, r, req.Password)
+	if !ok {
+		return
+	}
+
+	user, err := s.invitations.Accept(req.Token, func(inv Invitation) (User, error) {
+		if locale == "" {
+			locale = inv.Locale
+		}
+		return s.createUser(r.Context(), User{
+			Name: req.Name, Email: inv.Email, Role: inv.Role, Locale: locale, Tenant: inv.Tenant, passwordHash: hash,
+		})
+	})
+	var quotaErr *QuotaError
+	switch {
+	case errors.Is(err, errInviteNotFound):
+		httpError(w, r, "invitation not found", http.StatusNotFound)
+	case errors.Is(err, errInviteUsed):
+		httpError(w, r, "invitation is no longer valid", http.StatusGone)
+	case errors.Is(err, ErrEmailTaken):
+		httpError(w, r, "a user with this email already exists", http.StatusConflict)
+	case errors.As(err, &quotaErr):
+		writeCreateError(w, r, err)
+	case err != nil:
+		writeStoreError(w, r, err)
+	default:
+		s.recordAudit(r, AuditCreate, "user", user.ID.String(), nil, user)
+		s.writeJSON(w, r, http.StatusCreated, user)
+	}
+}
+
+// invitationView adds the computed status to an invitation
+type invitationView struct {
+	Invitation
+	Status string `json:"status"`
+}
+
+// HandleListInvitations handles GET /admin/invitations, optionally
+// filtered with ?status=pending|accepted|revoked|expired
+func (s *Server) HandleListInvitations(w http.ResponseWriter, r *http.Request) {
+	now := s.clock.Now()
+	status := r.URL.Query().Get("status")
+
+	views := make([]invitationView, 0)
+	for _, inv := range s.invitations.List() {
+		v := invitationView{inv, inv.Status(now)}
+		if status == "" || v.Status == status {
+			views = append(views, v)
+		}
+	}
+	s.writeJSON(w, r, http.StatusOK, views)
+}
+
+// HandleRevokeInvitation handles DELETE /admin/invitations/{id}
+func (s *Server) HandleRevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	switch inv, err := s.invitations.Revoke(id); {
+	case errors.Is(err, errInviteNotFound):
+		httpError(w, r, "invitation not found", http.StatusNotFound)
+	case errors.Is(err, errInviteUsed):
+		httpError(w, r, "invitation is no longer pending", http.StatusConflict)
+	default:
+		s.recordAudit(r, AuditUpdate, "invitation", id.String(), nil, inv)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
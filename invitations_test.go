@@ -0,0 +1,146 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// recordingMailer keeps sent messages for inspection
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []Message
+	err  error
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// token extracts the invitation token from the last message
+func (m *recordingMailer) token(t *testing.T) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.sent) == 0 {
+		t.Fatal("no message sent")
+	}
+	_, token, ok := strings.Cut(m.sent[len(m.sent)-1].Body, "?token=")
+	if !ok {
+		t.Fatalf("no token in %q", m.sent[len(m.sent)-1].Body)
+	}
+	return strings.Fields(token)[0]
+}
+
+func TestInvitationWorkflow(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mailer := &recordingMailer{}
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithMailer(mailer), WithInviteURL("https://app.example/join"), WithAdminCredentials("admin", "secret"))
+	routes := server.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/invitations", `{"email":"bob@example.com","role":"admin","expires_in":"1h"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "token") {
+		t.Error("the token must only be delivered by email")
+	}
+	token := mailer.token(t)
+
+	w = do(http.MethodPost, "/invitations/accept", `{"token":"`+token+`","name":"Bob"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var user User
+	json.NewDecoder(w.Body).Decode(&user)
+	if user.Email != "bob@example.com" || user.Role != RoleAdmin {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	if w := do(http.MethodPost, "/invitations/accept", `{"token":"`+token+`","name":"Bob"}`); w.Code != http.StatusGone {
+		t.Errorf("expected 410 on reuse, got %d", w.Code)
+	}
+
+	do(http.MethodPost, "/invitations", `{"email":"carol@example.com","expires_in":"1h"}`)
+	expiring := mailer.token(t)
+	clock.Advance(2 * time.Hour)
+	if w := do(http.MethodPost, "/invitations/accept", `{"token":"`+expiring+`","name":"Carol"}`); w.Code != http.StatusGone {
+		t.Errorf("expected 410 once expired, got %d", w.Code)
+	}
+
+	do(http.MethodPost, "/invitations", `{"email":"dave@example.com","expires_in":"1h"}`)
+	taken := mailer.token(t)
+	addUser(t, server, User{Name: "Dave", Email: "dave@example.com"})
+	if w := do(http.MethodPost, "/invitations/accept", `{"token":"`+taken+`","name":"Dave"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 once the email is taken, got %d", w.Code)
+	}
+
+	w = do(http.MethodGet, "/admin/invitations?status=accepted", "")
+	var views []invitationView
+	json.NewDecoder(w.Body).Decode(&views)
+	if len(views) != 1 || views[0].UserID != user.ID {
+		t.Errorf("expected one accepted invitation linked to the user, got %+v", views)
+	}
+}
+
+func TestInvitationRevoke(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mailer := &recordingMailer{}
+	server := NewServer(WithMailer(mailer), WithInviteURL("https://app.example/join"), WithAdminCredentials("admin", "secret"))
+	routes := server.Routes()
+
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	do(http.MethodPost, "/invitations", `{"email":"dave@example.com"}`)
+	if code := do(http.MethodDelete, "/admin/invitations/1", ""); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if code := do(http.MethodDelete, "/admin/invitations/1", ""); code != http.StatusConflict {
+		t.Errorf("expected 409 revoking twice, got %d", code)
+	}
+	if code := do(http.MethodPost, "/invitations/accept", `{"token":"`+mailer.token(t)+`","name":"Dave"}`); code != http.StatusGone {
+		t.Errorf("expected revoked invitation to be refused, got %d", code)
+	}
+
+	mailer.err = errors.New("relay down")
+	if code := do(http.MethodPost, "/invitations", `{"email":"erin@example.com"}`); code != http.StatusBadGateway {
+		t.Errorf("expected 502 when delivery fails, got %d", code)
+	}
+	if inv := server.invitations.List()[1]; inv.RevokedAt == nil {
+		t.Error("expected undelivered invitation to be revoked")
+	}
+}
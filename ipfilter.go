@@ -0,0 +1,146 @@
+package quickserve
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// IPAccess restricts a route group by client address. Denied ranges win
+// over allowed ones; an empty allowlist admits every address not denied.
+type IPAccess struct {
+	Allow []netip.Prefix `json:"allow,omitempty"`
+	Deny  []netip.Prefix `json:"deny,omitempty"`
+}
+
+// permits reports whether addr may call the group
+func (a IPAccess) permits(addr netip.Addr) bool {
+	if containsAddr(a.Deny, addr) {
+		return false
+	}
+	return len(a.Allow) == 0 || containsAddr(a.Allow, addr)
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses comma-separated CIDR ranges. A bare address stands for
+// a range holding just that address.
+func ParseCIDRs(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", part)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", part)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked())
+	}
+	return prefixes, nil
+}
+
+// WithIPAccess restricts the named route group (module), e.g. "admin", by
+// client address
+func WithIPAccess(group string, access IPAccess) Option {
+	return func(s *Server) {
+		if s.ipAccess == nil {
+			s.ipAccess = make(map[string]IPAccess)
+		}
+		s.ipAccess[group] = access
+	}
+}
+
+// WithTrustedProxies makes the server believe X-Forwarded-For when a
+// request arrives from one of prefixes
+func WithTrustedProxies(prefixes ...netip.Prefix) Option {
+	return func(s *Server) {
+		s.trustedProxies = append(s.trustedProxies, prefixes...)
+	}
+}
+
+// remoteAddr parses the address of the connecting peer
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// clientAddr determines the client's address. X-Forwarded-For is read
+// right to left, skipping trusted proxies, so the result is the nearest
+// address no trusted proxy could have made up; entries further left can
+// be forged by the client. Peers on a unix socket are local proxies and
+// always trusted; without the header their clients' addresses are unknown.
+func (s *Server) clientAddr(r *http.Request) (netip.Addr, bool) {
+	addr, ok := remoteAddr(r)
+	if !viaUnixSocket(r) && (!ok || !containsAddr(s.trustedProxies, addr)) {
+		return addr, ok
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr, ok = hop.Unmap(), true
+		if !containsAddr(s.trustedProxies, addr) {
+			break
+		}
+	}
+	return addr, ok
+}
+
+// clientIP returns the client's address as text, for keying limits
+func (s *Server) clientIP(r *http.Request) string {
+	if addr, ok := s.clientAddr(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+// ipFilter enforces the access rules of group on its routes. The rules
+// are looked up per request, so a reload applies at once.
+func (s *Server) ipFilter(group string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.liveMu.RLock()
+			access, restricted := s.ipAccess[group]
+			s.liveMu.RUnlock()
+			if !restricted {
+				next.ServeHTTP(w, r)
+				return
+			}
+			addr, ok := s.clientAddr(r)
+			if !ok || !access.permits(addr) {
+				httpError(w, r, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
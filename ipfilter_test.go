@@ -0,0 +1,116 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func allowCIDRs(t *testing.T, s string) IPAccess {
+	t.Helper()
+	prefixes, err := ParseCIDRs(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return IPAccess{Allow: prefixes}
+}
+
+func TestClientAddrBehindProxies(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	proxies, err := ParseCIDRs("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(WithTrustedProxies(proxies...))
+
+	tests := []struct {
+		name, remote, xff, want string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"untrusted peer is not believed", "203.0.113.5:1234", "198.51.100.7", "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:80", "198.51.100.7", "198.51.100.7"},
+		{"forged entries left of the client are ignored", "10.1.2.3:80", "1.2.3.4, 198.51.100.7, 192.0.2.1", "198.51.100.7"},
+		{"only proxies", "10.1.2.3:80", "10.9.9.9", "10.9.9.9"},
+		{"garbage stops the walk", "10.1.2.3:80", "198.51.100.7, nonsense", "10.1.2.3"},
+		{"ipv4-mapped ipv6", "[::ffff:10.1.2.3]:80", "198.51.100.7", "198.51.100.7"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := server.clientIP(req); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIPAccessPerGroup(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	proxies, _ := ParseCIDRs("10.0.0.1")
+	deny, _ := ParseCIDRs("10.8.0.66")
+	admin := allowCIDRs(t, "10.8.0.0/16")
+	admin.Deny = deny
+	server := NewServer(
+		WithAPIKeyAuth("admin-secret"),
+		WithTrustedProxies(proxies...),
+		WithIPAccess("admin", admin),
+	)
+	routes := server.Routes()
+
+	do := func(path, remote, xff string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("/admin/keys", "10.8.1.1:5000", ""); code != http.StatusOK {
+		t.Errorf("expected the VPN range to reach /admin, got %d", code)
+	}
+	if code := do("/admin/keys", "203.0.113.5:5000", ""); code != http.StatusForbidden {
+		t.Errorf("expected other addresses to be refused, got %d", code)
+	}
+	if code := do("/admin/keys", "10.0.0.1:443", "10.8.1.1"); code != http.StatusOK {
+		t.Errorf("expected the forwarded address to be used behind a trusted proxy, got %d", code)
+	}
+	if code := do("/admin/keys", "203.0.113.5:5000", "10.8.1.1"); code != http.StatusForbidden {
+		t.Errorf("expected X-Forwarded-For from untrusted peers to be ignored, got %d", code)
+	}
+	if code := do("/admin/keys", "10.8.0.66:5000", ""); code != http.StatusForbidden {
+		t.Errorf("expected the denylist to win over the allowlist, got %d", code)
+	}
+	if code := do("/users", "203.0.113.5:5000", ""); code != http.StatusOK {
+		t.Errorf("expected other groups to be unrestricted, got %d", code)
+	}
+}
+
+func TestIPAccessFromEnv(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	cfg, err := LoadConfig(nil, []string{
+		"QUICKSERVE_IP_ALLOW_ADMIN=10.8.0.0/16,10.9.0.0/16",
+		"QUICKSERVE_IP_DENY_USERS=203.0.113.0/24",
+		"PATH=/usr/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _ := cfg.LiveSettings()
+	if len(rules.IPAccess) != 2 || len(rules.IPAccess["admin"].Allow) != 2 || len(rules.IPAccess["users"].Deny) != 1 {
+		t.Errorf("unexpected rules: %+v", rules.IPAccess)
+	}
+	if _, err := LoadConfig(nil, []string{"QUICKSERVE_IP_ALLOW_ADMIN=10.8.0.0/33"}); err == nil {
+		t.Error("expected an invalid CIDR to be rejected")
+	}
+}
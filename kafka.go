@@ -0,0 +1,183 @@
+package quickserve
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// kafkaQueueSize is how many events may wait for the broker before new
+	// ones are dropped
+	kafkaQueueSize = 10000
+	// kafkaBatchSize is the most events written in one request
+	kafkaBatchSize = 100
+	// kafkaBatchTimeout bounds how long the writer waits to fill a batch
+	kafkaBatchTimeout = 10 * time.Millisecond
+	// kafkaBackoff is the wait before retrying a failed batch; it doubles
+	// up to kafkaMaxBackoff
+	kafkaBackoff    = 100 * time.Millisecond
+	kafkaMaxBackoff = 10 * time.Second
+	// kafkaFlushTimeout is how long queued events get to reach the broker
+	// on shutdown
+	kafkaFlushTimeout = 10 * time.Second
+)
+
+// KafkaConfig names where user events are produced
+type KafkaConfig struct {
+	// Brokers are host:port addresses of the cluster's bootstrap brokers
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+}
+
+// kafkaWriter is the part of kafka.Writer the publisher uses
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaPublisher produces user events to a Kafka topic for downstream
+// pipelines. Each is keyed by user ID, so one user's events land on one
+// partition in order. Delivery is at least once: a batch the brokers did
+// not acknowledge is retried until they do, so consumers may see an
+// event twice and should deduplicate on its ID.
+type KafkaPublisher struct {
+	writer  kafkaWriter
+	queue   chan kafka.Message
+	backoff time.Duration
+	logger  *slog.Logger
+	// render and clock are the server's, for encoding events and waiting
+	// out the backoff
+	render renderOptions
+	clock  Clock
+}
+
+// NewKafkaPublisher creates a publisher for cfg. It connects lazily, when
+// the first batch is written.
+func NewKafkaPublisher(cfg KafkaConfig) *KafkaPublisher {
+	return newKafkaPublisher(&kafka.Writer{
+		Addr:     kafka.TCP(cfg.Brokers...),
+		Topic:    cfg.Topic,
+		Balancer: &kafka.Hash{},
+		// Every in-sync replica has the event before it counts as sent
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    kafkaBatchSize,
+		BatchTimeout: kafkaBatchTimeout,
+		// Retries are left to the publisher, which never gives up
+		MaxAttempts: 1,
+	})
+}
+
+func newKafkaPublisher(w kafkaWriter) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer:  w,
+		queue:   make(chan kafka.Message, kafkaQueueSize),
+		backoff: kafkaBackoff,
+		logger:  slog.Default(),
+		clock:   SystemClock{},
+	}
+}
+
+// WithKafka produces every user event with p. Events are sent by Run.
+func WithKafka(p *KafkaPublisher) Option {
+	return func(s *Server) {
+		s.kafka = p
+	}
+}
+
+// publish is the event bus sink queueing ev for the broker. A full queue,
+// which means the cluster has been unreachable for a while, drops the
+// event rather than hold up the request that made the change.
+func (p *KafkaPublisher) publish(ctx context.Context, ev UserEvent) {
+	value, err := encodeEventWith(ev, p.render)
+	if err != nil {
+		return
+	}
+	msg := kafka.Message{
+		Key:   []byte(ev.UserID.String()),
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte(ev.Type)},
+			{Key: "request_id", Value: []byte(ev.RequestID)},
+		},
+		Time: ev.OccurredAt,
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.logger.WarnContext(ctx, "kafka queue full; event dropped", "event_id", ev.ID)
+	}
+}
+
+// Run writes queued events until ctx is done, then gives what is still
+// queued kafkaFlushTimeout to reach the broker and closes the writer
+func (p *KafkaPublisher) Run(ctx context.Context) {
+	defer p.writer.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			p.flush(ctx, nil)
+			return
+		case msg := <-p.queue:
+			if batch := p.batch(msg); !p.write(ctx, batch) {
+				p.flush(ctx, batch)
+				return
+			}
+		}
+	}
+}
+
+// flush writes pending and everything still queued, giving up after
+// kafkaFlushTimeout
+func (p *KafkaPublisher) flush(ctx context.Context, pending []kafka.Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), kafkaFlushTimeout)
+	defer cancel()
+	for len(pending) > 0 || len(p.queue) > 0 {
+		if len(pending) == 0 {
+			pending = p.batch(<-p.queue)
+		}
+		if !p.write(ctx, pending) {
+			p.logger.Warn("kafka events not sent before shutdown", "events", len(pending)+len(p.queue))
+			return
+		}
+		pending = nil
+	}
+}
+
+// batch returns first and whatever else is queued, up to kafkaBatchSize
+func (p *KafkaPublisher) batch(first kafka.Message) []kafka.Message {
+	batch := []kafka.Message{first}
+	for len(batch) < kafkaBatchSize {
+		select {
+		case msg := <-p.queue:
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// write sends batch, retrying with backoff until it is acknowledged. It
+// reports false if ctx ended first.
+func (p *KafkaPublisher) write(ctx context.Context, batch []kafka.Message) bool {
+	backoff := p.backoff
+	for {
+		err := p.writer.WriteMessages(ctx, batch...)
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		p.logger.Warn("producing to kafka failed; retrying", "events", len(batch), "retry_in", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-p.clock.After(backoff):
+		}
+		backoff = min(backoff*2, kafkaMaxBackoff)
+	}
+}
@@ -0,0 +1,117 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka records what is written, failing the first failures calls
+type fakeKafka struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	written  []kafka.Message
+	closed   bool
+}
+
+func (f *fakeKafka) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.failures {
+		return errors.New("leader not available")
+	}
+	f.written = append(f.written, msgs...)
+	return nil
+}
+
+func (f *fakeKafka) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeKafka) messages() []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.written
+}
+
+func TestKafkaPublishing(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	writer := &fakeKafka{failures: 2}
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := newKafkaPublisher(writer)
+	s := NewServer(WithClock(clock), WithKafka(p))
+	h := s.Routes()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`)))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/users/1", nil))
+
+	// Failed writes are retried until the broker takes them, waiting for
+	// the clock with the backoff doubling each time
+	for _, backoff := range []time.Duration{kafkaBackoff, 2 * kafkaBackoff} {
+		clock.awaitWaiter(t, backoff)
+		clock.Advance(backoff)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(writer.messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := writer.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected both events after retries, got %d", len(msgs))
+	}
+	for i, typ := range []string{EventUserCreated, EventUserDeleted} {
+		m := msgs[i]
+		if string(m.Key) != "1" {
+			t.Errorf("event %d: expected to be keyed by user ID, got %q", i, m.Key)
+		}
+		if len(m.Headers) == 0 || m.Headers[0].Key != "event_type" || string(m.Headers[0].Value) != typ {
+			t.Errorf("event %d: expected type %s in headers, got %v", i, typ, m.Headers)
+		}
+		if !strings.Contains(string(m.Value), `"type":"`+typ+`"`) {
+			t.Errorf("event %d: expected the event as JSON, got %s", i, m.Value)
+		}
+	}
+	if !writer.closed {
+		t.Error("expected the writer to be closed")
+	}
+}
+
+func TestKafkaFlushOnShutdown(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	writer := &fakeKafka{}
+	p := newKafkaPublisher(writer)
+	for i := 1; i <= 3; i++ {
+		p.publish(context.Background(), UserEvent{ID: ID(i), Type: EventUserCreated, UserID: ID(i)})
+	}
+
+	// Events queued before shutdown still reach the broker
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Run(ctx)
+	if n := len(writer.messages()); n != 3 {
+		t.Errorf("expected the queue to be flushed, got %d events", n)
+	}
+}
@@ -0,0 +1,62 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// changeTracker remembers when users last changed, as the modification
+// time of collections of them. Until the first change it is the time the
+// server started, since nothing before then is known.
+type changeTracker struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+// handleEvent is the event bus sink recording a user change
+func (t *changeTracker) handleEvent(_ context.Context, ev UserEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ev.OccurredAt.After(t.at) {
+		t.at = ev.OccurredAt
+	}
+}
+
+// modified returns when users last changed
+func (t *changeTracker) modified() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.at
+}
+
+// notModified reports whether r's If-Modified-Since shows the client has
+// the version last modified at modified. HTTP dates have whole seconds,
+// so anything within the second the client saw counts as unchanged.
+// If-None-Match takes precedence, so it disables the check.
+func notModified(r *http.Request, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// checkModified sets Last-Modified and answers 304 if the client's copy
+// is current, reporting whether it did. A zero time is unknown and
+// checks nothing.
+func checkModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	if !notModified(r, modified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,82 @@
+package quickserve
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestLastModified(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"uncached", nil},
+		{"cached", []Option{WithResponseCache(10)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer guard.VerifyNone(t)
+
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clock := NewSimulatedClock(start)
+			store := NewMemoryUserStore()
+			store.clock = clock
+			routes := NewServer(append(tt.opts, WithClock(clock), WithUserStore(store))...).Routes()
+			posted := 0
+			do := func(method, path, since string) *httptest.ResponseRecorder {
+				body := ""
+				if method == http.MethodPost {
+					posted++
+					body = fmt.Sprintf(`{"name":"Bob","email":"bob%d@test.com"}`, posted)
+				}
+				req := httptest.NewRequest(method, path, strings.NewReader(body))
+				if since != "" {
+					req.Header.Set("If-Modified-Since", since)
+				}
+				w := httptest.NewRecorder()
+				routes.ServeHTTP(w, req)
+				return w
+			}
+
+			clock.Advance(time.Minute)
+			do(http.MethodPost, "/users", "")
+			created := start.Add(time.Minute).Format(http.TimeFormat)
+
+			w := do(http.MethodGet, "/users/1", "")
+			if got := w.Header().Get("Last-Modified"); got != created {
+				t.Fatalf("expected Last-Modified %s, got %q", created, got)
+			}
+			if w := do(http.MethodGet, "/users/1", created); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Errorf("expected 304 without a body, got %d: %s", w.Code, w.Body)
+			}
+			if w := do(http.MethodGet, "/users/1", start.Format(http.TimeFormat)); w.Code != http.StatusOK {
+				t.Errorf("expected 200 for an older copy, got %d", w.Code)
+			}
+
+			// The list changes with any user, the user only with itself
+			if w := do(http.MethodGet, "/users", created); w.Code != http.StatusNotModified {
+				t.Errorf("expected the list unchanged since the creation, got %d", w.Code)
+			}
+			clock.Advance(time.Minute)
+			do(http.MethodPost, "/users", "")
+			w = do(http.MethodGet, "/users", created)
+			if w.Code != http.StatusOK || w.Header().Get("Last-Modified") != start.Add(2*time.Minute).Format(http.TimeFormat) {
+				t.Errorf("expected the list modified by the second user, got %d: %v", w.Code, w.Header())
+			}
+			if w := do(http.MethodGet, "/users/1", created); w.Code != http.StatusNotModified {
+				t.Errorf("expected the first user unchanged, got %d", w.Code)
+			}
+
+			clock.Advance(time.Minute)
+			do(http.MethodDelete, "/users/2", "")
+			if w := do(http.MethodGet, "/users", start.Add(2*time.Minute).Format(http.TimeFormat)); w.Code != http.StatusOK {
+				t.Errorf("expected a deletion to modify the list, got %d", w.Code)
+			}
+		})
+	}
+}
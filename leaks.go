@@ -0,0 +1,297 @@
+package quickserve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	heapcheck "github.com/harshakonda/heapcheck/runtime"
+)
+
+const (
+	// leakPath serves the leak monitor's report, and leakPath+"/metrics"
+	// its figures for Prometheus
+	leakPath = "/debug/leaks"
+	// leakConfirmChecks is how many checks in a row must be over a
+	// threshold before a leak is reported, so a burst of traffic is not
+	leakConfirmChecks = 3
+	// leakTopGroups is how many of the fastest growing goroutine stacks
+	// a report lists
+	leakTopGroups = 10
+)
+
+// LeakCheckConfig enables the leak monitor
+type LeakCheckConfig struct {
+	// Interval is the time between checks; zero disables them
+	Interval time.Duration `yaml:"interval"`
+	// MaxGoroutineGrowth is how many goroutines may be added since the
+	// first check before a leak is reported; zero ignores them
+	MaxGoroutineGrowth int `yaml:"max_goroutine_growth"`
+	// MaxHeapGrowthMB is how many MiB of live heap may be added since the
+	// first check before a leak is reported; zero ignores it
+	MaxHeapGrowthMB int `yaml:"max_heap_growth_mb"`
+}
+
+// WithLeakMonitor checks the process for goroutine and heap leaks every
+// interval while Server.Run runs, comparing it with its state at the
+// first check with heapcheck. Growth of more than maxGoroutines
+// goroutines or maxHeap bytes of live heap over three checks in a row is
+// reported as a leak, in the log and at /debug/leaks of LeakHandler.
+// Either limit may be zero to only report that figure. Each check runs a
+// garbage collection, so keep the interval at a minute or more.
+func WithLeakMonitor(interval time.Duration, maxGoroutines int, maxHeap int64) Option {
+	return func(s *Server) {
+		s.leaks = &leakMonitor{interval: interval, maxGoroutines: maxGoroutines, maxHeap: maxHeap}
+	}
+}
+
+// LeakSample is the state of the process at one leak check
+type LeakSample struct {
+	At          time.Time `json:"at"`
+	Goroutines  int       `json:"goroutines"`
+	HeapBytes   uint64    `json:"heap_bytes"`
+	HeapObjects uint64    `json:"heap_objects"`
+}
+
+// GoroutineGroup counts the goroutines sharing a stack, named by the
+// innermost function outside the runtime and the one they were started
+// with
+type GoroutineGroup struct {
+	Function string `json:"function"`
+	Entry    string `json:"entry"`
+	Count    int    `json:"count"`
+	Baseline int    `json:"baseline"`
+}
+
+// LeakReport is what the leak monitor found. Growing lists the goroutine
+// stacks that grew most since the baseline, which usually points at the
+// code leaking.
+type LeakReport struct {
+	Enabled            bool             `json:"enabled"`
+	Checks             int64            `json:"checks"`
+	Leaking            bool             `json:"leaking"`
+	LeakingSince       *time.Time       `json:"leaking_since,omitempty"`
+	MaxGoroutineGrowth int              `json:"max_goroutine_growth,omitempty"`
+	MaxHeapGrowthBytes int64            `json:"max_heap_growth_bytes,omitempty"`
+	Baseline           *LeakSample      `json:"baseline,omitempty"`
+	Current            *LeakSample      `json:"current,omitempty"`
+	GoroutineGrowth    int              `json:"goroutine_growth"`
+	HeapGrowthBytes    int64            `json:"heap_growth_bytes"`
+	Growing            []GoroutineGroup `json:"growing,omitempty"`
+}
+
+// leakMonitor keeps the baseline and the latest check
+type leakMonitor struct {
+	interval      time.Duration
+	maxGoroutines int
+	maxHeap       int64
+
+	mu       sync.Mutex
+	baseline *heapcheck.Snapshot
+	groups   map[string]GoroutineGroup // at the baseline, by stack
+	over     int                       // checks in a row over a limit
+	report   LeakReport
+}
+
+// RunLeakMonitor checks for leaks every interval until ctx is done
+func (s *Server) RunLeakMonitor(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.leaks.interval):
+			s.checkLeaks(ctx)
+		}
+	}
+}
+
+// checkLeaks compares the process with the baseline, taking it on the
+// first call, and logs when a leak starts or stops being reported
+func (s *Server) checkLeaks(ctx context.Context) {
+	m := s.leaks
+	now := s.clock.Now()
+	groups := goroutineGroups()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.baseline == nil {
+		m.baseline = heapcheck.TakeSnapshot()
+		m.groups = groups
+		base := LeakSample{At: now, Goroutines: m.baseline.Goroutines, HeapBytes: m.baseline.HeapAllocated, HeapObjects: m.baseline.HeapObjects}
+		m.report = LeakReport{Checks: 1, Baseline: &base, Current: &base}
+		return
+	}
+
+	diff := m.baseline.Compare()
+	cur := LeakSample{
+		At:          now,
+		Goroutines:  m.baseline.Goroutines + diff.GoroutineGrowth,
+		HeapBytes:   uint64(int64(m.baseline.HeapAllocated) + diff.HeapGrowthBytes),
+		HeapObjects: uint64(int64(m.baseline.HeapObjects) + diff.HeapGrowthObjects),
+	}
+	over := (m.maxGoroutines > 0 && diff.GoroutineGrowth > m.maxGoroutines) ||
+		(m.maxHeap > 0 && diff.HeapGrowthBytes > m.maxHeap)
+	if over {
+		m.over++
+	} else {
+		m.over = 0
+	}
+
+	r := &m.report
+	r.Checks++
+	r.Current = &cur
+	r.GoroutineGrowth = diff.GoroutineGrowth
+	r.HeapGrowthBytes = diff.HeapGrowthBytes
+	r.Growing = growingGroups(m.groups, groups)
+	logger := s.componentLogger("leaks")
+	switch {
+	case m.over >= leakConfirmChecks && !r.Leaking:
+		r.Leaking = true
+		r.LeakingSince = &now
+		attrs := []any{"goroutine_growth", diff.GoroutineGrowth, "heap_growth_bytes", diff.HeapGrowthBytes}
+		if len(r.Growing) > 0 {
+			attrs = append(attrs, "top_function", r.Growing[0].Function, "top_growth", r.Growing[0].Count-r.Growing[0].Baseline)
+		}
+		logger.WarnContext(ctx, "leak suspected", attrs...)
+	case m.over == 0 && r.Leaking:
+		r.Leaking = false
+		r.LeakingSince = nil
+		logger.InfoContext(ctx, "leak no longer suspected", "goroutine_growth", diff.GoroutineGrowth, "heap_growth_bytes", diff.HeapGrowthBytes)
+	}
+}
+
+// LeakReport returns what the leak monitor found so far
+func (s *Server) LeakReport() LeakReport {
+	if s.leaks == nil {
+		return LeakReport{}
+	}
+	s.leaks.mu.Lock()
+	defer s.leaks.mu.Unlock()
+	r := s.leaks.report
+	r.Enabled = true
+	r.MaxGoroutineGrowth = s.leaks.maxGoroutines
+	r.MaxHeapGrowthBytes = s.leaks.maxHeap
+	r.Growing = slices.Clone(r.Growing)
+	return r
+}
+
+// goroutineGroups counts the running goroutines by stack, from the
+// aggregated goroutine profile
+func goroutineGroups() map[string]GoroutineGroup {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	return parseGoroutineGroups(buf.Bytes())
+}
+
+// parseGoroutineGroups reads a goroutine profile in its debug=1 text
+// form: a "N @ addresses" line per stack, followed by a "#" line per
+// frame, innermost first
+func parseGoroutineGroups(profile []byte) map[string]GoroutineGroup {
+	groups := make(map[string]GoroutineGroup)
+	var count int
+	var frames []string
+	flush := func() {
+		if count > 0 && len(frames) > 0 {
+			key := strings.Join(frames, "\n")
+			g := groups[key]
+			g.Function, g.Entry = frames[0], frames[len(frames)-1]
+			// Blocked goroutines sit in the scheduler; name them by the
+			// code that blocked
+			if i := slices.IndexFunc(frames, func(f string) bool { return !strings.HasPrefix(f, "runtime.") }); i >= 0 {
+				g.Function = frames[i]
+			}
+			g.Count += count
+			groups[key] = g
+		}
+		count, frames = 0, frames[:0]
+	}
+	sc := bufio.NewScanner(bytes.NewReader(profile))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "#"):
+			// "#	0x4a1b2c	pkg.fn+0x2c	/path/file.go:12"
+			fields := strings.Fields(line)
+			if len(fields) >= 3 {
+				fn, _, _ := strings.Cut(fields[2], "+0x")
+				frames = append(frames, fn)
+			}
+		case strings.Contains(line, " @ "):
+			flush()
+			n, _, _ := strings.Cut(line, " @ ")
+			count, _ = strconv.Atoi(n)
+		}
+	}
+	flush()
+	return groups
+}
+
+// growingGroups returns the groups that grew since the baseline, most
+// grown first
+func growingGroups(baseline, current map[string]GoroutineGroup) []GoroutineGroup {
+	var growing []GoroutineGroup
+	for key, g := range current {
+		g.Baseline = baseline[key].Count
+		if g.Count > g.Baseline {
+			growing = append(growing, g)
+		}
+	}
+	slices.SortFunc(growing, func(a, b GoroutineGroup) int {
+		if d := (b.Count - b.Baseline) - (a.Count - a.Baseline); d != 0 {
+			return d
+		}
+		return strings.Compare(a.Function, b.Function)
+	})
+	if len(growing) > leakTopGroups {
+		growing = growing[:leakTopGroups]
+	}
+	return growing
+}
+
+// LeakHandler serves the leak monitor's report at /debug/leaks and its
+// figures in the Prometheus text format at /debug/leaks/metrics. Like
+// DebugHandler it has no authentication: serve it on a private listener,
+// as quickserve does next to pprof.
+func (s *Server) LeakHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+leakPath, func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, r, http.StatusOK, s.LeakReport())
+	})
+	mux.HandleFunc("GET "+leakPath+"/metrics", s.handleLeakMetrics)
+	return mux
+}
+
+// handleLeakMetrics writes the leak figures for Prometheus to scrape and
+// alert on
+func (s *Server) handleLeakMetrics(w http.ResponseWriter, r *http.Request) {
+	rep := s.LeakReport()
+	var b strings.Builder
+	metric := func(name, kind, help string, v any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, v)
+	}
+	var cur LeakSample
+	if rep.Current != nil {
+		cur = *rep.Current
+	}
+	leaking := 0
+	if rep.Leaking {
+		leaking = 1
+	}
+	metric("quickserve_leak_checks_total", "counter", "Leak checks run since start.", rep.Checks)
+	metric("quickserve_leak_suspected", "gauge", "Whether the leak monitor reports a leak.", leaking)
+	metric("quickserve_goroutines", "gauge", "Goroutines at the last leak check.", cur.Goroutines)
+	metric("quickserve_goroutine_growth", "gauge", "Goroutines added since the leak baseline.", rep.GoroutineGrowth)
+	metric("quickserve_heap_bytes", "gauge", "Live heap bytes at the last leak check.", cur.HeapBytes)
+	metric("quickserve_heap_growth_bytes", "gauge", "Live heap bytes added since the leak baseline.", rep.HeapGrowthBytes)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,125 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// leakyWorker blocks until release is closed, standing in for a goroutine
+// nothing ever stops
+func leakyWorker(release chan struct{}) {
+	<-release
+}
+
+func TestLeakMonitor(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	var logs bytes.Buffer
+	s := NewServer(
+		WithClock(NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithLeakMonitor(time.Minute, 10, 0),
+	)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.LeakHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	report := func() LeakReport {
+		var r LeakReport
+		if err := json.NewDecoder(get("/debug/leaks").Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	s.checkLeaks(ctx)
+	if r := report(); !r.Enabled || r.Checks != 1 || r.Baseline == nil || r.Leaking {
+		t.Fatalf("expected a baseline after the first check, got %+v", r)
+	}
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			leakyWorker(release)
+		}()
+	}
+	for i := range leakConfirmChecks {
+		s.checkLeaks(ctx)
+		if r := report(); r.Leaking != (i == leakConfirmChecks-1) {
+			t.Fatalf("check %d: expected a leak only after %d checks over the limit, got %+v", i+1, leakConfirmChecks, r)
+		}
+	}
+	r := report()
+	if r.GoroutineGrowth < 50 || r.LeakingSince == nil || len(r.Growing) == 0 {
+		t.Fatalf("expected the growth reported, got %+v", r)
+	}
+	if g := r.Growing[0]; !strings.HasSuffix(g.Function, ".leakyWorker") || g.Count-g.Baseline != 50 {
+		t.Errorf("expected the leaking function first, got %+v", r.Growing)
+	}
+	if !strings.Contains(logs.String(), "leak suspected") || !strings.Contains(logs.String(), "top_function=github.com/harshakonda/quickserve.leakyWorker") {
+		t.Errorf("expected the leak logged with its function, got %q", logs.String())
+	}
+	if body := get("/debug/leaks/metrics").Body.String(); !strings.Contains(body, "\nquickserve_leak_suspected 1\n") ||
+		!strings.Contains(body, "# TYPE quickserve_goroutine_growth gauge") {
+		t.Errorf("expected the leak in the metrics, got:\n%s", body)
+	}
+
+	close(release)
+	wg.Wait()
+	s.checkLeaks(ctx)
+	if r := report(); r.Leaking || r.LeakingSince != nil {
+		t.Errorf("expected the leak cleared once the goroutines exited, got %+v", r)
+	}
+}
+
+func TestLeakMonitorDisabled(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	w := httptest.NewRecorder()
+	NewServer().LeakHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/leaks", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"enabled":false`) {
+		t.Errorf("expected a disabled report, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestParseGoroutineGroups(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	profile := `goroutine profile: total 5
+3 @ 0x43e1ce 0x44f8c5 0x4a2b1c 0x471e01
+#	0x43e1cd	runtime.gopark+0xcd		/go/src/runtime/proc.go:424
+#	0x44f8c4	runtime.chanrecv1+0x24		/go/src/runtime/chan.go:489
+#	0x4a2b1b	example.com/app.worker+0x3b	/app/worker.go:12
+
+2 @ 0x43e1ce 0x4a2c00
+#	0x43e1cd	runtime.gopark+0xcd		/go/src/runtime/proc.go:424
+#	0x4a2bff	example.com/app.serve+0x1f	/app/serve.go:40
+`
+	groups := parseGoroutineGroups([]byte(profile))
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %+v", groups)
+	}
+	growing := growingGroups(map[string]GoroutineGroup{}, groups)
+	if len(growing) != 2 || growing[0].Function != "example.com/app.worker" || growing[0].Count != 3 ||
+		growing[0].Entry != "example.com/app.worker" || growing[1].Function != "example.com/app.serve" {
+		t.Errorf("expected the groups named by their code, most grown first, got %+v", growing)
+	}
+	if g := growingGroups(groups, groups); len(g) != 0 {
+		t.Errorf("expected nothing growing against itself, got %+v", g)
+	}
+}
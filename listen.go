@@ -0,0 +1,78 @@
+package quickserve
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const (
+	// unixScheme prefixes listen addresses that are unix socket paths
+	unixScheme = "unix://"
+	// defaultSocketMode lets the owner and group, e.g. a local reverse
+	// proxy, connect to a unix socket
+	defaultSocketMode = "0660"
+)
+
+// listen opens addr, which is a TCP host:port, unix:// followed by a
+// socket path, e.g. unix:///var/run/quickserve.sock, or systemd: followed
+// by the name of a socket systemd passed in. The socket file is given mode
+// and removed when the listener closes. A socket file left by a process
+// that has exited is replaced; one still in use is an error.
+func listen(addr string, mode fs.FileMode) (net.Listener, error) {
+	if name, ok := strings.CutPrefix(addr, systemdScheme); ok {
+		return systemdListener(name)
+	}
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// removeStaleSocket removes the socket at path if nothing answers on it
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// viaUnixSocket reports whether r arrived on a unix socket listener. The
+// peer is then a local process, typically a reverse proxy, with no IP
+// address of its own.
+func viaUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
@@ -0,0 +1,115 @@
+package quickserve
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// socketPath returns a path short enough for a unix socket, which
+// t.TempDir's long names can exceed
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "qs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "api.sock")
+}
+
+func unixClient(path string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := socketPath(t)
+	ln, err := listen(unixScheme+path, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o660 {
+		t.Errorf("expected mode 0660, got %v", info.Mode().Perm())
+	}
+
+	deny, _ := ParseCIDRs("203.0.113.0/24")
+	server := NewServer(WithIPAccess("users", IPAccess{Deny: deny}))
+	srv := &http.Server{Handler: server.Routes()}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	defer func() {
+		srv.Close()
+		<-done
+	}()
+
+	client := unixClient(path)
+	defer client.CloseIdleConnections()
+	get := func(xff string) int {
+		req, _ := http.NewRequest(http.MethodGet, "http://quickserve/users", nil)
+		if xff != "" {
+			req.Header.Set("X-Forwarded-For", xff)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("198.51.100.7"); code != http.StatusOK {
+		t.Errorf("expected a forwarded client to be served, got %d", code)
+	}
+	if code := get("203.0.113.5"); code != http.StatusForbidden {
+		t.Errorf("expected X-Forwarded-For to be trusted over a unix socket, got %d", code)
+	}
+	if code := get(""); code != http.StatusForbidden {
+		t.Errorf("expected an unknown client address to be refused by the rules, got %d", code)
+	}
+}
+
+func TestListenReplacesStaleSocket(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := socketPath(t)
+	addr := unixScheme + path
+	ln, err := listen(addr, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(addr, 0o600); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("expected a live socket to be refused, got %v", err)
+	}
+
+	// A crashed process leaves its socket file behind
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	ln, err = listen(addr, 0o600)
+	if err != nil {
+		t.Fatalf("expected the stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listen(addr, 0o600); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("expected a regular file to be left alone, got %v", err)
+	}
+}
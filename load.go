@@ -0,0 +1,225 @@
+package quickserve
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultLoadCapacity is the in-flight request count treated as full load
+	defaultLoadCapacity = 100
+	// errorWindow is the period over which the error rate is measured
+	errorWindow = time.Minute
+	// cpuSampleInterval is the minimum time between CPU samples
+	cpuSampleInterval = time.Second
+)
+
+// WithLoadCapacity sets how many concurrent requests count as full load
+// when computing the load-balancer weight
+func WithLoadCapacity(n int) Option {
+	return func(s *Server) {
+		s.loadCapacity = n
+	}
+}
+
+// LoadReport describes the instance's current load
+type LoadReport struct {
+	Weight    int     `json:"weight"`
+	CPU       float64 `json:"cpu"`
+	InFlight  int64   `json:"in_flight"`
+	Capacity  int     `json:"capacity"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// loadTracker measures in-flight requests, recent error rate and process
+// CPU usage
+type loadTracker struct {
+	clock    Clock
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	buckets [60]loadBucket
+
+	cpuMu      sync.Mutex
+	cpuLast    time.Duration
+	cpuWall    time.Time
+	cpuPercent float64
+}
+
+// loadBucket holds one second of request outcomes
+type loadBucket struct {
+	second   int64
+	requests int
+	errors   int
+}
+
+func newLoadTracker(clock Clock) *loadTracker {
+	return &loadTracker{clock: clock, cpuWall: time.Now(), cpuLast: processCPUTime()}
+}
+
+func (t *loadTracker) record(status int) {
+	sec := t.clock.Now().Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := &t.buckets[sec%int64(len(t.buckets))]
+	if b.second != sec {
+		*b = loadBucket{second: sec}
+	}
+	b.requests++
+	if status >= 500 {
+		b.errors++
+	}
+}
+
+// errorRate returns the fraction of 5xx responses within errorWindow
+func (t *loadTracker) errorRate() float64 {
+	cutoff := t.clock.Now().Add(-errorWindow).Unix()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var requests, errors int
+	for _, b := range t.buckets {
+		if b.second > cutoff {
+			requests += b.requests
+			errors += b.errors
+		}
+	}
+	if requests == 0 {
+		return 0
+	}
+	return float64(errors) / float64(requests)
+}
+
+// cpu returns process CPU utilization across all cores in [0, 1]. It is
+// resampled at most once per cpuSampleInterval; CPU time is measured in
+// real time regardless of the server clock.
+func (t *loadTracker) cpu() float64 {
+	t.cpuMu.Lock()
+	defer t.cpuMu.Unlock()
+
+	now := time.Now()
+	wall := now.Sub(t.cpuWall)
+	if wall < cpuSampleInterval {
+		return t.cpuPercent
+	}
+
+	used := processCPUTime()
+	busy := float64(used-t.cpuLast) / float64(wall) / float64(runtime.NumCPU())
+	t.cpuPercent = math.Max(0, math.Min(1, busy))
+	t.cpuLast, t.cpuWall = used, now
+	return t.cpuPercent
+}
+
+// report combines the signals into a weight. The most saturated signal
+// dominates: an instance at 90% CPU is hot no matter how few requests it
+// is serving.
+func (t *loadTracker) report(capacity int) LoadReport {
+	r := LoadReport{
+		CPU:       t.cpu(),
+		InFlight:  t.inFlight.Load(),
+		Capacity:  capacity,
+		ErrorRate: t.errorRate(),
+	}
+
+	load := math.Max(r.CPU, r.ErrorRate)
+	if capacity > 0 {
+		load = math.Max(load, float64(r.InFlight)/float64(capacity))
+	}
+	r.Weight = int(math.Round(100 * (1 - math.Min(load, 1))))
+	return r
+}
+
+// statusWriter records the status code and body size written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// trackLoad counts in-flight requests and records response statuses
+func (s *Server) trackLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.load.inFlight.Add(1)
+		defer s.load.inFlight.Add(-1)
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		s.load.record(sw.status)
+	})
+}
+
+// HandleWeight handles GET /health/weight. It returns the load report as
+// JSON, or the HAProxy agent format ("up 75%") when plain text is asked
+// for with ?format=agent or an Accept: text/plain header.
+func (s *Server) HandleWeight(w http.ResponseWriter, r *http.Request) {
+	report := s.load.report(s.loadCapacity)
+
+	if r.URL.Query().Get("format") == "agent" || strings.HasPrefix(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%s\n", agentResponse(report))
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, report)
+}
+
+// agentResponse formats a report for HAProxy's agent-check
+func agentResponse(r LoadReport) string {
+	if r.Weight == 0 {
+		return "drain"
+	}
+	return fmt.Sprintf("up %d%%", r.Weight)
+}
+
+// ServeAgentCheck answers HAProxy agent-check connections on ln until it
+// is closed. Each connection receives one line and is closed; a draining
+// server always answers "drain".
+func (s *Server) ServeAgentCheck(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		line := agentResponse(s.load.report(s.loadCapacity)) + "\n"
+		if s.Draining() {
+			line = "drain\n"
+		}
+		conn.SetWriteDeadline(time.Now().Add(time.Second))
+		conn.Write([]byte(line))
+		conn.Close()
+	}
+}
@@ -0,0 +1,107 @@
+package quickserve
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestLoadReportErrorRate(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := newLoadTracker(clock)
+
+	for i := 0; i < 3; i++ {
+		tracker.record(http.StatusOK)
+	}
+	tracker.record(http.StatusInternalServerError)
+
+	if rate := tracker.errorRate(); rate != 0.25 {
+		t.Errorf("expected error rate 0.25, got %v", rate)
+	}
+	if w := tracker.report(0).Weight; w > 75 {
+		t.Errorf("expected weight at most 75, got %d", w)
+	}
+
+	clock.Advance(2 * errorWindow)
+	if rate := tracker.errorRate(); rate != 0 {
+		t.Errorf("expected old errors to expire, got %v", rate)
+	}
+}
+
+func TestLoadReportInFlight(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tracker := newLoadTracker(SystemClock{})
+	tracker.inFlight.Add(5)
+
+	if w := tracker.report(10).Weight; w > 50 {
+		t.Errorf("expected weight at most 50 at half capacity, got %d", w)
+	}
+}
+
+func TestHandleWeight(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	routes := server.Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/health/weight", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	var report LoadReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if report.Weight < 0 || report.Weight > 100 || report.Capacity != defaultLoadCapacity {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/health/weight?format=agent", nil)
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if body := w.Body.String(); !strings.HasPrefix(body, "up ") || !strings.HasSuffix(body, "%\n") {
+		t.Errorf("expected agent-check response, got %q", body)
+	}
+}
+
+func TestServeAgentCheck(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		server.ServeAgentCheck(ln)
+		close(done)
+	}()
+	defer func() {
+		ln.Close()
+		<-done
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "up ") {
+		t.Errorf("expected agent response, got %q", line)
+	}
+}
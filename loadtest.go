@@ -0,0 +1,275 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// defaultLoadTestWorkers bounds the requests a load test has in
+	// flight; ticks that find every worker busy are counted as dropped
+	defaultLoadTestWorkers = 64
+	// loadTestTimeout is how long a single request may take by default
+	loadTestTimeout = 10 * time.Second
+)
+
+// loadTestOps are the operations of a load test, in report order
+var loadTestOps = []string{"create", "get", "list", "delete"}
+
+// LoadTestConfig configures a load test
+type LoadTestConfig struct {
+	// Target is the base URL of the server under test, e.g.
+	// http://localhost:8080
+	Target   string
+	Duration time.Duration
+	// RPS is the request rate to sustain
+	RPS int
+	// Workers bounds the requests in flight; zero means 64
+	Workers int
+	// APIKey and Token authenticate the requests, for targets that
+	// require it
+	APIKey string
+	Token  string
+	// Client sends the requests; nil means a client with a 10s timeout
+	Client *http.Client
+}
+
+// LatencyStats summarizes the latencies of a set of requests
+type LatencyStats struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// LoadTestOperation is how one kind of request fared in a load test
+type LoadTestOperation struct {
+	Requests  int64        `json:"requests"`
+	Errors    int64        `json:"errors"`
+	ErrorRate float64      `json:"error_rate"`
+	Latency   LatencyStats `json:"latency"`
+}
+
+// LoadTestReport is the outcome of a load test. Errors are requests that
+// could not be sent or were answered with a 4xx or 5xx; Dropped are ticks
+// skipped because every worker was still waiting on the target.
+type LoadTestReport struct {
+	Target      string                       `json:"target"`
+	Duration    time.Duration                `json:"duration"`
+	RPS         int                          `json:"rps"`
+	AchievedRPS float64                      `json:"achieved_rps"`
+	Requests    int64                        `json:"requests"`
+	Errors      int64                        `json:"errors"`
+	ErrorRate   float64                      `json:"error_rate"`
+	Dropped     int64                        `json:"dropped"`
+	Statuses    map[int]int64                `json:"statuses"`
+	Latency     LatencyStats                 `json:"latency"`
+	Operations  map[string]LoadTestOperation `json:"operations"`
+}
+
+// LoadTest drives the user API of cfg.Target at cfg.RPS for cfg.Duration
+// with the same mix of creates, reads, lists and deletes as Soak, and
+// reports latency percentiles and error rates per operation. Users it
+// created and did not delete are deleted afterwards, outside the figures.
+// Stopping ctx ends the test early with a report of what ran so far.
+func LoadTest(ctx context.Context, cfg LoadTestConfig) (LoadTestReport, error) {
+	if cfg.Duration <= 0 || cfg.RPS <= 0 {
+		return LoadTestReport{}, fmt.Errorf("loadtest: duration and rps must be positive")
+	}
+	if cfg.Target == "" {
+		return LoadTestReport{}, fmt.Errorf("loadtest: target is required")
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = defaultLoadTestWorkers
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: loadTestTimeout}
+	}
+
+	t := newSoakTraffic(client)
+	t.base = strings.TrimSuffix(cfg.Target, "/")
+	t.header = make(http.Header)
+	if cfg.APIKey != "" {
+		t.header.Set(APIKeyHeader, cfg.APIKey)
+	}
+	if cfg.Token != "" {
+		t.header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	var mu sync.Mutex
+	latencies := make(map[string][]time.Duration)
+	failed := make(map[string]int64)
+	t.observe = func(op string, status int, elapsed time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		latencies[op] = append(latencies[op], elapsed)
+		if status == 0 || status >= http.StatusBadRequest {
+			failed[op]++
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				t.request()
+			}
+		}()
+	}
+
+	start := time.Now()
+	report := LoadTestReport{Target: cfg.Target, RPS: cfg.RPS, Operations: make(map[string]LoadTestOperation)}
+	tick := time.NewTicker(time.Second / time.Duration(cfg.RPS))
+	defer tick.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-tick.C:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				report.Dropped++
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	report.Duration = time.Since(start).Round(time.Millisecond)
+
+	// Leave the target as it was found
+	t.observe = nil
+	for _, id := range t.ids {
+		t.do(http.MethodDelete, "/users/"+id.String(), "", nil)
+	}
+
+	var all []time.Duration
+	for _, op := range loadTestOps {
+		if len(latencies[op]) == 0 {
+			continue
+		}
+		n := int64(len(latencies[op]))
+		report.Operations[op] = LoadTestOperation{
+			Requests:  n,
+			Errors:    failed[op],
+			ErrorRate: float64(failed[op]) / float64(n),
+			Latency:   latencyStats(latencies[op]),
+		}
+		all = append(all, latencies[op]...)
+		report.Requests += n
+		report.Errors += failed[op]
+	}
+	if report.Requests > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(report.Requests)
+		report.Latency = latencyStats(all)
+	}
+	if report.Duration > 0 {
+		report.AchievedRPS = float64(report.Requests) / report.Duration.Seconds()
+	}
+	report.Statuses = t.statusCounts()
+	return report, nil
+}
+
+// latencyStats summarizes latencies, sorting them in place. Percentiles
+// use the nearest rank, so they are always latencies actually observed.
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, d := range latencies {
+		sum += d
+	}
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return latencies[max(i, 0)]
+	}
+	return LatencyStats{
+		Min:  latencies[0],
+		Mean: sum / time.Duration(len(latencies)),
+		P50:  rank(0.50),
+		P90:  rank(0.90),
+		P95:  rank(0.95),
+		P99:  rank(0.99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+// WriteText writes the report for a terminal: a summary, a latency table
+// per operation and the status counts
+func (r LoadTestReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "loadtest: %s for %s at %d req/s: %d requests (%.1f req/s), %d errors (%.2f%%), %d dropped\n\n",
+		r.Target, r.Duration, r.RPS, r.Requests, r.AchievedRPS, r.Errors, r.ErrorRate*100, r.Dropped)
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OPERATION\tREQUESTS\tERRORS\tMIN\tMEAN\tP50\tP90\tP95\tP99\tMAX\t")
+	row := func(name string, requests, errors int64, l LatencyStats) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", name, requests, errors,
+			roundLatency(l.Min), roundLatency(l.Mean), roundLatency(l.P50), roundLatency(l.P90),
+			roundLatency(l.P95), roundLatency(l.P99), roundLatency(l.Max))
+	}
+	for _, op := range loadTestOps {
+		if o, ok := r.Operations[op]; ok {
+			row(op, o.Requests, o.Errors, o.Latency)
+		}
+	}
+	row("total", r.Requests, r.Errors, r.Latency)
+	tw.Flush()
+
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+	b.WriteString("\nstatuses:")
+	for _, status := range statuses {
+		label := fmt.Sprint(status)
+		if status == 0 {
+			label = "failed"
+		}
+		fmt.Fprintf(&b, " %s=%d", label, r.Statuses[status])
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r LoadTestReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// roundLatency rounds d for display, to a precision that suits its size
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
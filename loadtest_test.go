@@ -0,0 +1,113 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestLoadTest(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	target := httptest.NewServer(server.Routes())
+	defer target.Close()
+
+	report, err := LoadTest(context.Background(), LoadTestConfig{
+		Target:   target.URL + "/",
+		Duration: 500 * time.Millisecond,
+		RPS:      200,
+		Client:   target.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Operations["create"].Requests == 0 {
+		t.Fatalf("expected traffic including creates, got %+v", report)
+	}
+	if report.Errors != 0 || report.ErrorRate != 0 {
+		t.Errorf("expected no errors, got %d: %v", report.Errors, report.Statuses)
+	}
+	if l := report.Latency; l.Min <= 0 || l.Min > l.P50 || l.P50 > l.P99 || l.P99 > l.Max {
+		t.Errorf("expected ordered latencies, got %+v", l)
+	}
+	if users, _ := server.store.List(context.Background()); len(users) != 0 {
+		t.Errorf("expected the users created to be deleted, %d are left", len(users))
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	if !strings.Contains(text.String(), "OPERATION") || !strings.Contains(text.String(), "statuses: 201=") {
+		t.Errorf("expected a latency table and status counts, got:\n%s", text.String())
+	}
+}
+
+func TestLoadTestErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	// Every request needs credentials the test doesn't send
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(APIKeyHeader) != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer target.Close()
+
+	report, err := LoadTest(context.Background(), LoadTestConfig{
+		Target: target.URL, Duration: 200 * time.Millisecond, RPS: 100, Client: target.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.ErrorRate != 1 || report.Statuses[http.StatusUnauthorized] != report.Requests {
+		t.Errorf("expected every request refused, got %d errors of %d: %v", report.Errors, report.Requests, report.Statuses)
+	}
+
+	report, err = LoadTest(context.Background(), LoadTestConfig{
+		Target: target.URL, Duration: 200 * time.Millisecond, RPS: 100, Client: target.Client(), APIKey: "key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests == 0 || report.Errors != 0 {
+		t.Errorf("expected the API key to be sent, got %d errors of %d", report.Errors, report.Requests)
+	}
+}
+
+func TestLoadTestInvalidConfig(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	if _, err := LoadTest(context.Background(), LoadTestConfig{Duration: time.Second, RPS: 1}); err == nil {
+		t.Error("expected an error without a target")
+	}
+	if _, err := LoadTest(context.Background(), LoadTestConfig{Target: "http://localhost", Duration: time.Second}); err == nil {
+		t.Error("expected an error without a request rate")
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	latencies := make([]time.Duration, 100)
+	for i := range latencies {
+		latencies[i] = time.Duration(100-i) * time.Millisecond
+	}
+	got := latencyStats(latencies)
+	want := LatencyStats{
+		Min: time.Millisecond, Mean: 50500 * time.Microsecond,
+		P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P95: 95 * time.Millisecond,
+		P99: 99 * time.Millisecond, Max: 100 * time.Millisecond,
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if (latencyStats(nil) != LatencyStats{}) {
+		t.Error("expected zero stats without latencies")
+	}
+}
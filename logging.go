@@ -0,0 +1,82 @@
+package quickserve
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// NewLogger creates a logger writing to w in format "text" or "json" at
+// level and above. A *slog.LevelVar lets the level change later.
+func NewLogger(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, want text or json", format)
+}
+
+// ParseLogLevel parses debug, info, warn or error
+func ParseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, want debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// WithLogger sets the logger the server and its components write to.
+// Each component logs with a "component" attribute, e.g. audit or mailer.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// componentLogger returns the logger for one part of the server. Lines
+// logged with a request's context carry its request_id, and its trace_id
+// when the request is traced.
+func (s *Server) componentLogger(name string) *slog.Logger {
+	return slog.New(requestIDHandler{s.logger.Handler()}).With("component", name)
+}
+
+// requestIDHandler adds the request and trace IDs from the context to
+// every record
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		rec.AddAttrs(slog.String("request_id", id))
+	}
+	if sp := SpanFromContext(ctx); sp != nil {
+		rec.AddAttrs(slog.String("trace_id", sp.TraceID()))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// fatalf logs a startup failure through the default logger and exits
+func fatalf(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// fatal is fatalf for a single value, typically an error
+func fatal(v any) {
+	fatalf("%v", v)
+}
@@ -0,0 +1,74 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestNewLogger(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "n", 1)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single JSON line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "shown" || line["level"] != "WARN" {
+		t.Errorf("unexpected entry: %v", line)
+	}
+
+	if _, err := NewLogger(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+	if level, err := ParseLogLevel("debug"); err != nil || level != slog.LevelDebug {
+		t.Errorf("expected debug, got %v %v", level, err)
+	}
+	if _, err := ParseLogLevel("loud"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+}
+
+// failingMailer rejects every message
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, Message) error { return errors.New("relay down") }
+
+func TestComponentLoggers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var buf bytes.Buffer
+	logger, _ := NewLogger(&buf, "text", slog.LevelDebug)
+	server := NewServer(WithLogger(logger))
+
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+	server.mailer.Send(context.Background(), Message{To: "alice@test.com", Subject: "hi"})
+	server.mailer = failingMailer{}
+	server.notifications.Set(1, NotificationPrefs{EmailOnLogin: true})
+	server.recordLogin(httptest.NewRequest("POST", "/login", nil), Principal{Email: "alice@test.com", Method: "password"})
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=DEBUG msg="user created" component=store user_id=1`,
+		`level=INFO msg=mail component=mailer to=alice@test.com subject=hi`,
+		`level=ERROR msg="could not send login notice" component=notifications user_id=1 err="relay down"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
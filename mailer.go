@@ -0,0 +1,69 @@
+package quickserve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// Message is an email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// WithMailer sets how outgoing email is delivered
+func WithMailer(m Mailer) Option {
+	return func(s *Server) {
+		s.mailer = m
+	}
+}
+
+// logMailer writes messages to the log instead of sending them; it is
+// the default so development setups need no mail server
+type logMailer struct {
+	logger *slog.Logger
+}
+
+func (l logMailer) Send(ctx context.Context, m Message) error {
+	l.logger.InfoContext(ctx, "mail", "to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
+
+// SMTPMailer sends email through an SMTP relay, using STARTTLS when the
+// server offers it
+type SMTPMailer struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// Send delivers m. net/smtp has no context support, so ctx is only
+// checked before connecting.
+func (s SMTPMailer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("invalid header value")
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, _ := net.SplitHostPort(s.Addr)
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		s.From, m.To, m.Subject, m.Body)
+	return smtp.SendMail(s.Addr, auth, s.From, []string{m.To}, []byte(msg))
+}
@@ -0,0 +1,73 @@
+package quickserve
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// WithClientCAs enables mutual TLS: clients must present a certificate
+// that chains to pool, and its subject authenticates the request
+func WithClientCAs(pool *x509.CertPool) Option {
+	return func(s *Server) {
+		s.clientCAs = pool
+	}
+}
+
+// LoadClientCAs reads a PEM bundle of CA certificates
+func LoadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return pool, nil
+}
+
+// TLSConfig returns the server's TLS settings, requiring verified client
+// certificates when mutual TLS is enabled
+func (s *Server) TLSConfig() *tls.Config {
+	return mutualTLSConfig(s.clientCAs)
+}
+
+// mutualTLSConfig returns the default TLS settings, requiring client
+// certificates that chain to pool unless it is nil
+func mutualTLSConfig(pool *x509.CertPool) *tls.Config {
+	cfg := defaultTLSConfig()
+	if pool != nil {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = pool
+	}
+	return cfg
+}
+
+// ClientCertificate returns the verified client certificate of r. Only
+// certificates the TLS handshake verified are returned, so an unverified
+// one presented to a listener without client CAs is ignored.
+func ClientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return r.TLS.VerifiedChains[0][0], true
+}
+
+// certificatePrincipal identifies the caller by certificate subject. The
+// first email SAN, if any, maps the caller to a user's role.
+func (s *Server) certificatePrincipal(ctx context.Context, cert *x509.Certificate) Principal {
+	var email string
+	if len(cert.EmailAddresses) > 0 {
+		email = cert.EmailAddresses[0]
+	}
+	return Principal{
+		Subject: cert.Subject.String(),
+		Method:  "mtls",
+		Email:   email,
+		Role:    s.roleForEmail(ctx, "", email),
+	}
+}
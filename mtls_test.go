@@ -0,0 +1,163 @@
+package quickserve
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// testCA issues client certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, cn, email string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: cn},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ca := newTestCA(t)
+	server := NewServer(WithClientCAs(ca.pool))
+	addUser(t, server, User{Name: "Alice", Email: "alice@example.com", Role: RoleAdmin})
+
+	ts := httptest.NewUnstartedServer(server.Routes())
+	ts.TLS = server.TLSConfig()
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport)
+	defer transport.CloseIdleConnections()
+
+	if _, err := client.Get(ts.URL + "/users"); err == nil {
+		t.Fatal("expected the handshake to fail without a client certificate")
+	}
+
+	transport.TLSClientConfig.Certificates = []tls.Certificate{ca.issue(t, "alice", "alice@example.com")}
+	resp, err := client.Post(ts.URL+"/users", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the admin certificate to reach the handler, got %d", resp.StatusCode)
+	}
+}
+
+func TestCertificatePrincipal(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ca := newTestCA(t)
+	server := NewServer(WithClientCAs(ca.pool))
+	cert, _ := x509.ParseCertificate(ca.issue(t, "bob", "bob@example.com").Certificate[0])
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert, ca.cert}}}
+
+	var got Principal
+	handler := server.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.Method != "mtls" || got.Subject != "CN=bob" || got.Email != "bob@example.com" || got.Role != RoleUser {
+		t.Errorf("unexpected principal: %+v", got)
+	}
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected unverified certificates to be rejected, got %d", w.Code)
+	}
+}
+
+func TestListenerClientCAs(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	// The CA belongs to one listener only; API keys authenticate elsewhere
+	ca := newTestCA(t)
+	server := NewServer(WithAPIKeyAuth("admin-secret"))
+	addUser(t, server, User{Name: "Alice", Email: "alice@example.com", Role: RoleAdmin})
+
+	ts := httptest.NewUnstartedServer(server.Routes())
+	ts.TLS = mutualTLSConfig(ca.pool)
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport)
+	defer transport.CloseIdleConnections()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{ca.issue(t, "alice", "alice@example.com")}
+
+	resp, err := client.Post(ts.URL+"/users", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the listener's certificate to authenticate, got %d", resp.StatusCode)
+	}
+}
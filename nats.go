@@ -0,0 +1,162 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// defaultNATSSubjectPrefix starts every subject unless configured
+	defaultNATSSubjectPrefix = "quickserve"
+	// natsInstanceHeader names the instance that published an event, so
+	// it can ignore its own
+	natsInstanceHeader = "Quickserve-Instance"
+	// natsReconnectWait is the pause between attempts to reach a server
+	natsReconnectWait = 2 * time.Second
+	// natsReconnectBuffer is how many bytes of events are held while
+	// reconnecting; publishing fails once it is full
+	natsReconnectBuffer = 8 << 20
+	// natsDrainTimeout is how long pending events get to go out on
+	// shutdown
+	natsDrainTimeout = 10 * time.Second
+)
+
+// NATSConfig names where user events are exchanged with other instances
+type NATSConfig struct {
+	// URL is a nats:// server URL, or several separated by commas
+	URL string `yaml:"url"`
+	// SubjectPrefix starts every subject: events go to <prefix>.<type>,
+	// e.g. quickserve.user.created
+	SubjectPrefix string `yaml:"subject_prefix"`
+}
+
+// NATSTransport publishes user events to NATS and relays those other
+// instances publish to this instance's WebSocket and SSE clients, so a
+// client sees every change whichever instance made it. Webhooks and Kafka
+// only get the events of the instance that made them, so each is sent
+// once.
+type NATSTransport struct {
+	cfg           NATSConfig
+	instance      string
+	reconnectWait time.Duration
+	logger        *slog.Logger
+	// render is the server's, for encoding events
+	render renderOptions
+
+	mu   sync.Mutex
+	conn *nats.Conn
+	// relay hands events from other instances to local subscribers
+	relay func(UserEvent)
+}
+
+// NewNATSTransport creates a transport for cfg. It connects when Run.
+func NewNATSTransport(cfg NATSConfig) *NATSTransport {
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = defaultNATSSubjectPrefix
+	}
+	instance, _ := randomToken()
+	return &NATSTransport{cfg: cfg, instance: instance, reconnectWait: natsReconnectWait, logger: slog.Default()}
+}
+
+// WithNATS exchanges user events with other instances over t. Events are
+// exchanged while the server Runs.
+func WithNATS(t *NATSTransport) Option {
+	return func(s *Server) {
+		s.nats = t
+	}
+}
+
+// subject returns the subject events of typ are published to
+func (t *NATSTransport) subject(typ string) string {
+	return t.cfg.SubjectPrefix + "." + typ
+}
+
+// Run connects, then relays events until ctx is done. It keeps trying
+// to reach a server, from startup on, and resubscribes after every
+// reconnect; events published while disconnected are buffered.
+func (t *NATSTransport) Run(ctx context.Context) error {
+	closed := make(chan struct{})
+	conn, err := nats.Connect(t.cfg.URL,
+		nats.Name("quickserve"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(t.reconnectWait),
+		nats.ReconnectBufSize(natsReconnectBuffer),
+		nats.DrainTimeout(natsDrainTimeout),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				t.logger.Warn("nats disconnected; reconnecting", "err", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			t.logger.Info("nats reconnected", "url", c.ConnectedUrlRedacted())
+		}),
+		nats.ClosedHandler(func(*nats.Conn) { close(closed) }),
+	)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	if _, err := conn.Subscribe(t.subject("user.*"), t.receive); err != nil {
+		conn.Close()
+		<-closed
+		return fmt.Errorf("nats: %w", err)
+	}
+	t.mu.Lock()
+	t.conn = conn
+	t.mu.Unlock()
+
+	<-ctx.Done()
+	t.mu.Lock()
+	t.conn = nil
+	t.mu.Unlock()
+	// Draining sends what is buffered; a connection that is down has
+	// nothing to drain
+	if conn.Drain() != nil {
+		conn.Close()
+	}
+	<-closed
+	return nil
+}
+
+// publish is the event bus sink sending ev to other instances
+func (t *NATSTransport) publish(ctx context.Context, ev UserEvent) {
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	data, err := encodeEventWith(ev, t.render)
+	if err != nil {
+		return
+	}
+	msg := &nats.Msg{Subject: t.subject(ev.Type), Data: data, Header: nats.Header{}}
+	msg.Header.Set(natsInstanceHeader, t.instance)
+	if ev.RequestID != "" {
+		msg.Header.Set(RequestIDHeader, ev.RequestID)
+	}
+	if err := conn.PublishMsg(msg); err != nil {
+		t.logger.WarnContext(ctx, "publishing to nats failed; event not broadcast", "event_id", ev.ID, "err", err)
+	}
+}
+
+// receive relays an event published by another instance
+func (t *NATSTransport) receive(msg *nats.Msg) {
+	if msg.Header.Get(natsInstanceHeader) == t.instance || t.relay == nil {
+		return
+	}
+	var ev UserEvent
+	if err := json.Unmarshal(msg.Data, &ev); err != nil {
+		t.logger.Warn("undecodable nats event", "subject", msg.Subject, "err", err)
+		return
+	}
+	ev.remote = true
+	ev.tenant = eventTenant(ev.User)
+	t.relay(ev)
+}
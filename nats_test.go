@@ -0,0 +1,279 @@
+package quickserve
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve/events"
+)
+
+// fakeNATS is a single NATS server speaking just enough of the protocol
+// for the client: pings, subscriptions and publishes with headers
+type fakeNATS struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]map[string]string // sid -> subject
+	subjects []string
+}
+
+func startFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeNATS{ln: ln, conns: make(map[net.Conn]map[string]string)}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns[conn] = make(map[string]string)
+			f.mu.Unlock()
+			f.wg.Add(1)
+			go func() {
+				defer f.wg.Done()
+				f.serve(conn)
+			}()
+		}
+	}()
+	return f
+}
+
+func (f *fakeNATS) url() string {
+	return "nats://" + f.ln.Addr().String()
+}
+
+// dropConnections disconnects every client, as a server restart would
+func (f *fakeNATS) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for c := range f.conns {
+		c.Close()
+		delete(f.conns, c)
+	}
+}
+
+func (f *fakeNATS) close() {
+	f.ln.Close()
+	f.dropConnections()
+	f.wg.Wait()
+}
+
+// subscriptions returns the number of subscriptions across clients
+func (f *fakeNATS) subscriptions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, subs := range f.conns {
+		n += len(subs)
+	}
+	return n
+}
+
+func (f *fakeNATS) published() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.subjects...)
+}
+
+func (f *fakeNATS) serve(conn net.Conn) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+	fmt.Fprint(conn, `INFO {"server_id":"fake","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}`+"\r\n")
+	br := bufio.NewReader(conn)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "PING":
+			f.write(conn, "PONG\r\n")
+		case "SUB":
+			f.mu.Lock()
+			if subs, ok := f.conns[conn]; ok {
+				subs[fields[len(fields)-1]] = fields[1]
+			}
+			f.mu.Unlock()
+		case "UNSUB":
+			f.mu.Lock()
+			delete(f.conns[conn], fields[1])
+			f.mu.Unlock()
+		case "HPUB":
+			// HPUB <subject> [reply] <header bytes> <total bytes>
+			hdr, _ := strconv.Atoi(fields[len(fields)-2])
+			total, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, total+2)
+			if _, err := io.ReadFull(br, payload); err != nil {
+				return
+			}
+			f.deliver(fields[1], hdr, payload[:total])
+		}
+	}
+}
+
+// deliver sends a published message to every matching subscription
+func (f *fakeNATS) deliver(subject string, hdr int, payload []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subjects = append(f.subjects, subject)
+	for c, subs := range f.conns {
+		for sid, pattern := range subs {
+			if natsSubjectMatches(pattern, subject) {
+				fmt.Fprintf(c, "HMSG %s %s %d %d\r\n%s\r\n", subject, sid, hdr, len(payload), payload)
+			}
+		}
+	}
+}
+
+func (f *fakeNATS) write(c net.Conn, s string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	io.WriteString(c, s)
+}
+
+func natsSubjectMatches(pattern, subject string) bool {
+	p, s := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, tok := range p {
+		switch {
+		case tok == ">":
+			return len(s) > i
+		case i >= len(s), tok != "*" && tok != s[i]:
+			return false
+		}
+	}
+	return len(p) == len(s)
+}
+
+// natsInstance is a server connected to the fake NATS server
+type natsInstance struct {
+	handler http.Handler
+	sub     *events.Subscription[UserEvent]
+	stop    func()
+}
+
+func startNATSInstance(t *testing.T, url string) *natsInstance {
+	t.Helper()
+	transport := NewNATSTransport(NATSConfig{URL: url})
+	transport.reconnectWait = 10 * time.Millisecond
+	s := NewServer(WithNATS(transport))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+	return &natsInstance{
+		handler: s.Routes(),
+		sub:     s.events.Subscribe(nil),
+		stop: func() {
+			cancel()
+			<-done
+		},
+	}
+}
+
+func (in *natsInstance) createUser(name string) {
+	body := fmt.Sprintf(`{"name":%q,"email":"%s@test.com"}`, name, strings.ToLower(name))
+	in.handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+}
+
+func (in *natsInstance) next(t *testing.T) UserEvent {
+	t.Helper()
+	select {
+	case m := <-in.sub.Events():
+		return m.Event
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected an event")
+		return UserEvent{}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNATSBroadcast(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := startFakeNATS(t)
+	defer srv.close()
+	a := startNATSInstance(t, srv.url())
+	defer a.stop()
+	b := startNATSInstance(t, srv.url())
+	defer b.stop()
+	waitFor(t, "both instances to subscribe", func() bool { return srv.subscriptions() == 2 })
+
+	a.createUser("Alice")
+	if ev := a.next(t); ev.Type != EventUserCreated || ev.remote {
+		t.Errorf("expected A's own event, got %+v", ev)
+	}
+	if ev := b.next(t); ev.Type != EventUserCreated || ev.UserID != 1 || !ev.remote {
+		t.Errorf("expected A's event relayed to B, got %+v", ev)
+	}
+	if got := srv.published(); len(got) != 1 || got[0] != "quickserve.user.created" {
+		t.Errorf("expected one publish to quickserve.user.created, got %v", got)
+	}
+
+	// After the server goes away both reconnect and resubscribe
+	srv.dropConnections()
+	waitFor(t, "both instances to resubscribe", func() bool { return srv.subscriptions() == 2 })
+	b.createUser("Bob")
+	if ev := a.next(t); ev.Type != EventUserCreated || !ev.remote {
+		t.Errorf("expected B's event relayed to A, got %+v", ev)
+	}
+	b.next(t)
+
+	// No event arrives twice, e.g. echoed back by NATS
+	select {
+	case m := <-a.sub.Events():
+		t.Errorf("expected no more events on A, got %+v", m.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSSERelayedEventsHaveNoID(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var sb strings.Builder
+	ev := UserEvent{ID: 7, Type: EventUserDeleted, remote: true}
+	if err := writeSSE(&sb, events.Message[UserEvent]{Event: ev, Data: []byte(`{"id":7}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != "event: user.deleted\ndata: {\"id\":7}\n\n" {
+		t.Errorf("expected a relayed event without an id, got %q", got)
+	}
+}
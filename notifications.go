@@ -0,0 +1,248 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// digestInterval is how often weekly digests go out
+const digestInterval = 7 * 24 * time.Hour
+
+// webhookEventTypes are the activity types a user may subscribe webhooks to
+var webhookEventTypes = []string{
+	ActivityLogin,
+	ActivityAccountCreated,
+	ActivityProfileUpdated,
+	ActivityAccountDeleted,
+	ActivityGroupJoined,
+	ActivityGroupLeft,
+	ActivityGroupRole,
+}
+
+// NotificationPrefs are a user's notification settings. Everything is off
+// until the user opts in.
+type NotificationPrefs struct {
+	EmailOnLogin  bool     `json:"email_on_login"`
+	WeeklyDigest  bool     `json:"weekly_digest"`
+	WebhookEvents []string `json:"webhook_events"`
+}
+
+// WantsWebhook reports whether the user subscribed webhooks to event
+func (p NotificationPrefs) WantsWebhook(event string) bool {
+	return slices.Contains(p.WebhookEvents, event)
+}
+
+// NotificationStore is an in-memory store of notification preferences
+type NotificationStore struct {
+	mu    sync.RWMutex
+	prefs map[ID]NotificationPrefs
+}
+
+// NewNotificationStore creates an empty notification store
+func NewNotificationStore() *NotificationStore {
+	return &NotificationStore{prefs: make(map[ID]NotificationPrefs)}
+}
+
+// Get returns the preferences of user
+func (s *NotificationStore) Get(user ID) NotificationPrefs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p := s.prefs[user]
+	if p.WebhookEvents == nil {
+		p.WebhookEvents = []string{}
+	}
+	return p
+}
+
+// Set replaces the preferences of user
+func (s *NotificationStore) Set(user ID, p NotificationPrefs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs[user] = p
+}
+
+// Delete forgets the preferences of user
+func (s *NotificationStore) Delete(user ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.prefs, user)
+}
+
+// notifyLogin emails the user about a login if they asked for it. A
+// failure to send is logged; it must not fail the login.
+func (s *Server) notifyLogin(ctx context.Context, p Principal) {
+	if p.Email == "" {
+		return
+	}
+	user, ok, err := s.users(ctx).FindByEmail(ctx, p.Email)
+	if err != nil || !ok || !s.notifications.Get(user.ID).EmailOnLogin {
+		return
+	}
+	msg, err := s.localizedMessage(user.Email, user.Locale, "login", map[string]string{
+		"Name":   user.Name,
+		"Method": p.Method,
+		"Time":   s.clock.Now().Format(time.RFC1123),
+	})
+	if err == nil {
+		err = s.mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		s.componentLogger("notifications").ErrorContext(ctx, "could not send login notice", "user_id", user.ID, "err", err)
+	}
+}
+
+// SendWeeklyDigests emails every user who opted in a summary of their
+// activity over the past week. Users with no activity get no email, and a
+// standby sends none since its primary already does.
+func (s *Server) SendWeeklyDigests(ctx context.Context) {
+	if s.isStandby() {
+		return
+	}
+	now := s.clock.Now()
+	counts := make(map[ID]map[string]int)
+	for _, e := range s.audit.Query(AuditFilter{Since: now.Add(-digestInterval), Until: now}) {
+		item, subject, ok := activityFor(e)
+		if !ok {
+			continue
+		}
+		if counts[subject] == nil {
+			counts[subject] = make(map[string]int)
+		}
+		counts[subject][item.Type]++
+	}
+
+	users, err := s.store.List(ctx)
+	if err != nil {
+		s.componentLogger("notifications").ErrorContext(ctx, "could not list users for digests", "err", err)
+		return
+	}
+	for _, user := range users {
+		activity := counts[user.ID]
+		if len(activity) == 0 || !s.notifications.Get(user.ID).WeeklyDigest {
+			continue
+		}
+		types := make([]string, 0, len(activity))
+		for t := range activity {
+			types = append(types, t)
+		}
+		slices.Sort(types)
+
+		type line struct {
+			Label string
+			Count int
+		}
+		lines := make([]line, 0, len(types))
+		for _, t := range types {
+			label, err := s.translator.Render(user.Locale, "activity."+t, nil)
+			if err != nil {
+				label = t
+			}
+			lines = append(lines, line{label, activity[t]})
+		}
+		msg, err := s.localizedMessage(user.Email, user.Locale, "digest", map[string]any{
+			"Name":       user.Name,
+			"WeekEnding": now.Format("2006-01-02"),
+			"Activity":   lines,
+		})
+		if err == nil {
+			err = s.mailer.Send(ctx, msg)
+		}
+		if err != nil {
+			s.componentLogger("notifications").ErrorContext(ctx, "could not send digest", "user_id", user.ID, "err", err)
+		}
+	}
+}
+
+// RunWeeklyDigests sends digests every week until ctx is done
+func (s *Server) RunWeeklyDigests(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(digestInterval):
+			s.SendWeeklyDigests(ctx)
+		}
+	}
+}
+
+// HandleGetNotifications handles GET /users/{id}/notifications
+func (s *Server) HandleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.notificationsUser(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, s.notifications.Get(user.ID))
+}
+
+// notificationPrefsUpdate is the body of PUT /users/{id}/notifications
+type notificationPrefsUpdate struct {
+	EmailOnLogin  *bool     `json:"email_on_login"`
+	WeeklyDigest  *bool     `json:"weekly_digest"`
+	WebhookEvents *[]string `json:"webhook_events"`
+}
+
+// HandlePutNotifications handles PUT /users/{id}/notifications. Omitted
+// fields are left unchanged.
+func (s *Server) HandlePutNotifications(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.notificationsUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req notificationPrefsUpdate
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	before := s.notifications.Get(user.ID)
+	after := before
+	if req.EmailOnLogin != nil {
+		after.EmailOnLogin = *req.EmailOnLogin
+	}
+	if req.WeeklyDigest != nil {
+		after.WeeklyDigest = *req.WeeklyDigest
+	}
+	if req.WebhookEvents != nil {
+		for _, e := range *req.WebhookEvents {
+			if !slices.Contains(webhookEventTypes, e) {
+				httpError(w, r, "unknown webhook event "+e, http.StatusBadRequest)
+				return
+			}
+		}
+		events := slices.Clone(*req.WebhookEvents)
+		slices.Sort(events)
+		after.WebhookEvents = slices.Compact(events)
+	}
+
+	s.notifications.Set(user.ID, after)
+	s.recordAudit(r, AuditUpdate, "notification_prefs", user.ID.String(), before, after)
+	s.writeJSON(w, r, http.StatusOK, after)
+}
+
+// notificationsUser resolves the user in the path. Preferences are
+// private: only the user and admins may see or change them.
+func (s *Server) notificationsUser(w http.ResponseWriter, r *http.Request) (User, bool) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return User{}, false
+	}
+	user, ok, err := s.users(r.Context()).Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return User{}, false
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return User{}, false
+	}
+	if !s.selfOrAdmin(r, user) {
+		httpError(w, r, "forbidden", http.StatusForbidden)
+		return User{}, false
+	}
+	return user, true
+}
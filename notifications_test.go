@@ -0,0 +1,99 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mailer := &recordingMailer{}
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithAPIKeyAuth("admin-secret"), WithMailer(mailer))
+	routes := server.Routes()
+
+	do := func(auth, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if strings.HasPrefix(auth, "Bearer ") {
+			req.Header.Set("Authorization", auth)
+		} else if auth != "" {
+			req.Header.Set(APIKeyHeader, auth)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+	login := func(email string) string {
+		t.Helper()
+		w := do("", http.MethodPost, "/login", `{"email":"`+email+`","password":"correct horse"}`)
+		var resp struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+			t.Fatalf("login failed: %d %s", w.Code, w.Body)
+		}
+		return "Bearer " + resp.Token
+	}
+
+	do("admin-secret", http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com","password":"correct horse"}`)
+	do("admin-secret", http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com","password":"correct horse"}`)
+
+	alice := login("alice@test.com")
+	if len(mailer.sent) != 0 {
+		t.Fatalf("expected no login email before opting in, got %+v", mailer.sent)
+	}
+
+	w := do(alice, http.MethodGet, "/users/1/notifications", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"webhook_events":[]`) {
+		t.Fatalf("expected default preferences, got %d %s", w.Code, w.Body)
+	}
+	w = do(alice, http.MethodPut, "/users/1/notifications", `{"email_on_login":true,"webhook_events":["login","group_joined","login"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	w = do(alice, http.MethodPut, "/users/1/notifications", `{"weekly_digest":true}`)
+	var prefs NotificationPrefs
+	json.NewDecoder(w.Body).Decode(&prefs)
+	if !prefs.EmailOnLogin || !prefs.WeeklyDigest || strings.Join(prefs.WebhookEvents, ",") != "group_joined,login" {
+		t.Errorf("expected updates to merge, got %+v", prefs)
+	}
+	if !prefs.WantsWebhook(ActivityLogin) || prefs.WantsWebhook(ActivityAccountDeleted) {
+		t.Errorf("unexpected webhook subscriptions: %+v", prefs)
+	}
+	if w := do(alice, http.MethodPut, "/users/1/notifications", `{"webhook_events":["everything"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown event, got %d", w.Code)
+	}
+
+	bob := login("bob@test.com")
+	if w := do(bob, http.MethodGet, "/users/1/notifications", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected other users to be refused, got %d", w.Code)
+	}
+	if w := do("admin-secret", http.MethodGet, "/users/1/notifications", ""); w.Code != http.StatusOK {
+		t.Errorf("expected admins to see preferences, got %d", w.Code)
+	}
+
+	login("alice@test.com")
+	if len(mailer.sent) != 1 || mailer.sent[0].To != "alice@test.com" || !strings.Contains(mailer.sent[0].Body, "password") {
+		t.Fatalf("expected a login notice for alice only, got %+v", mailer.sent)
+	}
+
+	clock.Advance(24 * time.Hour)
+	server.SendWeeklyDigests(context.Background())
+	if len(mailer.sent) != 2 || mailer.sent[1].Subject != "Your weekly digest" || !strings.Contains(mailer.sent[1].Body, "logins: 2") {
+		t.Fatalf("expected a digest counting alice's logins, got %+v", mailer.sent)
+	}
+
+	clock.Advance(digestInterval)
+	server.SendWeeklyDigests(context.Background())
+	if len(mailer.sent) != 2 {
+		t.Errorf("expected no digest after a quiet week, got %+v", mailer.sent[2:])
+	}
+}
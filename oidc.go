@@ -0,0 +1,511 @@
+package quickserve
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures OpenID Connect login against an external
+// identity provider such as Keycloak, Auth0 or Google
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// IDTokenClaims are the verified claims of an ID token
+type IDTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	Expiry    int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	NotBefore int64    `json:"nbf"`
+	Nonce     string   `json:"nonce"`
+	Email     string   `json:"email"`
+	// EmailVerified is whether the provider checked the caller owns Email
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+	// Tenant is a non-standard claim naming the caller's tenant
+	Tenant string `json:"tenant"`
+}
+
+// audience accepts both the string and array forms of the aud claim
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcDiscovery is the subset of the provider metadata we use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type pendingLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+const (
+	// oidcLoginTTL bounds how long a user may take at the provider
+	oidcLoginTTL = 10 * time.Minute
+	// oidcLeeway tolerates clock skew between us and the provider
+	oidcLeeway = time.Minute
+	// jwksMinRefresh limits refetching keys for unknown key IDs
+	jwksMinRefresh = time.Minute
+)
+
+// OIDCProvider performs the authorization code flow with PKCE and
+// verifies the resulting ID tokens
+type OIDCProvider struct {
+	cfg       OIDCConfig
+	discovery oidcDiscovery
+	client    *http.Client
+	clock     Clock
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	pending     map[string]pendingLogin
+}
+
+// NewOIDCProvider fetches the provider's discovery document and keys
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig, client *http.Client) (*OIDCProvider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := &OIDCProvider{
+		cfg:     cfg,
+		client:  client,
+		clock:   SystemClock{},
+		keys:    make(map[string]crypto.PublicKey),
+		pending: make(map[string]pendingLogin),
+	}
+
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if p.discovery.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch: got %q, want %q", p.discovery.Issuer, cfg.Issuer)
+	}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WithOIDC accepts bearer ID tokens from p on protected routes and mounts
+// the /auth/login and /auth/callback endpoints
+func WithOIDC(p *OIDCProvider) Option {
+	return func(s *Server) {
+		s.oidc = p
+	}
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	propagateDeadline(ctx, req)
+	propagateTrace(ctx, req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwk is a single JSON Web Key. Only RSA and P-256 EC signing keys are used.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	dec := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err := dec.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := dec.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = p.clock.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// key returns the verification key for kid, refetching the key set once
+// if it is unknown, which handles provider key rotation
+func (p *OIDCProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	stale := p.clock.Now().Sub(p.keysFetched) >= jwksMinRefresh
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok = p.keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// Verify checks an ID token's signature and standard claims
+func (p *OIDCProvider) Verify(ctx context.Context, raw string) (*IDTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("token header: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch header.Alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+			return nil, errors.New("invalid signature")
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return nil, errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return nil, errors.New("invalid signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	var claims IDTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("token claims: %w", err)
+	}
+
+	now := p.clock.Now()
+	switch {
+	case claims.Issuer != p.cfg.Issuer:
+		return nil, errors.New("wrong issuer")
+	case !claims.Audience.contains(p.cfg.ClientID):
+		return nil, errors.New("wrong audience")
+	case now.After(time.Unix(claims.Expiry, 0).Add(oidcLeeway)):
+		return nil, errors.New("token expired")
+	case claims.NotBefore != 0 && now.Add(oidcLeeway).Before(time.Unix(claims.NotBefore, 0)):
+		return nil, errors.New("token not yet valid")
+	}
+	return &claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// startLogin records a pending login and returns the provider URL
+func (p *OIDCProvider) startLogin() (string, error) {
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	now := p.clock.Now()
+	p.mu.Lock()
+	for k, v := range p.pending {
+		if now.After(v.expiresAt) {
+			delete(p.pending, k)
+		}
+	}
+	p.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, expiresAt: now.Add(oidcLoginTTL)}
+	p.mu.Unlock()
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// takeLogin consumes the pending login for state
+func (p *OIDCProvider) takeLogin(state string) (pendingLogin, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.pending[state]
+	delete(p.pending, state)
+	if !ok || p.clock.Now().After(login.expiresAt) {
+		return pendingLogin{}, false
+	}
+	return login, true
+}
+
+// oidcTokens is the token endpoint response
+type oidcTokens struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// exchange redeems an authorization code at the token endpoint
+func (p *OIDCProvider) exchange(ctx context.Context, code, verifier string) (oidcTokens, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oidcTokens{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	propagateDeadline(ctx, req)
+	propagateTrace(ctx, req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return oidcTokens{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return oidcTokens{}, fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+
+	var tokens oidcTokens
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return oidcTokens{}, err
+	}
+	if tokens.IDToken == "" {
+		return oidcTokens{}, errors.New("token endpoint returned no id_token")
+	}
+	return tokens, nil
+}
+
+// HandleOIDCLogin handles GET /auth/login
+func (s *Server) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	target, err := s.oidc.startLogin()
+	if err != nil {
+		httpError(w, r, "could not start login", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// HandleOIDCCallback handles GET /auth/callback
+func (s *Server) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		httpError(w, r, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+
+	login, ok := s.oidc.takeLogin(q.Get("state"))
+	if !ok {
+		httpError(w, r, "invalid or expired state", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := s.oidc.exchange(r.Context(), q.Get("code"), login.verifier)
+	if err != nil {
+		httpError(w, r, "code exchange failed", http.StatusBadGateway)
+		return
+	}
+
+	claims, err := s.oidc.Verify(r.Context(), tokens.IDToken)
+	if err != nil || claims.Nonce != login.nonce {
+		httpError(w, r, "invalid id token", http.StatusUnauthorized)
+		return
+	}
+	s.recordLogin(r, Principal{
+		Subject: claims.Subject,
+		Method:  "oidc",
+		Email:   claims.Email,
+		Role:    s.oidcRole(r.Context(), claims),
+		Tenant:  claims.Tenant,
+	})
+
+	s.writeJSON(w, r, http.StatusOK, struct {
+		IDToken     string `json:"id_token"`
+		AccessToken string `json:"access_token,omitempty"`
+		ExpiresIn   int    `json:"expires_in,omitempty"`
+		Subject     string `json:"subject"`
+		Email       string `json:"email,omitempty"`
+	}{tokens.IDToken, tokens.AccessToken, tokens.ExpiresIn, claims.Subject, claims.Email})
+}
+
+// oidcRole resolves the role of an OIDC caller from the user record
+// matching their email. Emails the provider hasn't verified could be
+// anyone's, so they get RoleUser.
+func (s *Server) oidcRole(ctx context.Context, claims *IDTokenClaims) Role {
+	if !claims.EmailVerified {
+		return RoleUser
+	}
+	return s.roleForEmail(ctx, claims.Tenant, claims.Email)
+}
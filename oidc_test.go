@@ -0,0 +1,242 @@
+package quickserve
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// testIdP is a minimal OpenID provider for exercising the login flow
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testAuthCode
+}
+
+type testAuthCode struct {
+	nonce     string
+	challenge string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, codes: make(map[string]testAuthCode)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		enc := base64.RawURLEncoding
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   enc.EncodeToString(key.N.Bytes()),
+				"e":   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		code, ok := idp.codes[r.Form.Get("code")]
+		idp.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id_token":   idp.sign(t, map[string]any{"nonce": code.nonce}),
+			"token_type": "Bearer",
+			"expires_in": 3600,
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// sign issues an RS256 ID token with default claims overlaid by extra
+func (idp *testIdP) sign(t *testing.T, extra map[string]any) string {
+	t.Helper()
+
+	claims := map[string]any{
+		"iss":   idp.URL,
+		"sub":   "user-123",
+		"aud":   "quickserve",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"email": "alice@example.com",
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + enc.EncodeToString(sig)
+}
+
+func newTestOIDCServer(t *testing.T, idp *testIdP) *Server {
+	t.Helper()
+
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Issuer:      idp.URL,
+		ClientID:    "quickserve",
+		RedirectURL: "http://localhost/auth/callback",
+	}, idp.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(WithOIDC(provider))
+}
+
+func TestOIDCBearerToken(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	idp := newTestIdP(t)
+	defer idp.Close()
+	routes := newTestOIDCServer(t, idp).Routes()
+
+	tests := []struct {
+		name     string
+		token    string
+		expected int
+	}{
+		{"valid", idp.sign(t, nil), http.StatusOK},
+		{"expired", idp.sign(t, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized},
+		{"wrong audience", idp.sign(t, map[string]any{"aud": "other"}), http.StatusUnauthorized},
+		{"garbage", "not.a.token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestOIDCRoleRequiresVerifiedEmail(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	idp := newTestIdP(t)
+	defer idp.Close()
+	server := newTestOIDCServer(t, idp)
+	addUser(t, server, User{Name: "Alice", Email: "alice@example.com", Role: RoleAdmin})
+
+	for _, tt := range []struct {
+		name     string
+		claims   map[string]any
+		expected Role
+	}{
+		{"verified", map[string]any{"email_verified": true}, RoleAdmin},
+		{"unverified", map[string]any{"email_verified": false}, RoleUser},
+		{"unstated", nil, RoleUser},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Authorization", "Bearer "+idp.sign(t, tt.claims))
+			p, ok := server.authenticate(req)
+			if !ok || p.Role != tt.expected {
+				t.Errorf("expected %s, got %+v", tt.expected, p)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	idp := newTestIdP(t)
+	defer idp.Close()
+	routes := newTestOIDCServer(t, idp).Routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/login", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected 302, got %d", w.Code)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), idp.URL+"/authorize") {
+		t.Fatalf("unexpected redirect %q", w.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Errorf("expected PKCE S256, got %q", q.Get("code_challenge_method"))
+	}
+
+	// Simulate the provider authenticating the user
+	idp.mu.Lock()
+	idp.codes["abc"] = testAuthCode{nonce: q.Get("nonce"), challenge: q.Get("code_challenge")}
+	idp.mu.Unlock()
+
+	req = httptest.NewRequest(http.MethodGet, "/auth/callback?code=abc&state="+url.QueryEscape(q.Get("state")), nil)
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Subject string `json:"subject"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Subject != "user-123" {
+		t.Errorf("expected subject user-123, got %q", resp.Subject)
+	}
+
+	// State is single use
+	w = httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 on replayed state, got %d", w.Code)
+	}
+}
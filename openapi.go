@@ -0,0 +1,403 @@
+package quickserve
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// openAPIPath is where the OpenAPI document is served
+const openAPIPath = "/openapi.json"
+
+// OpenAPI is an OpenAPI 3.0 document describing the served routes
+type OpenAPI struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       OpenAPIInfo         `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components OpenAPIComponents   `json:"components"`
+}
+
+// OpenAPIInfo describes the API as a whole
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on one path, keyed by lower-case method
+type PathItem map[string]*Operation
+
+// Operation describes one route
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security lists the schemes that authenticate the route; nil means
+	// none are needed
+	Security []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody describes the body a route accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one response of a route, or refers to a shared one
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema that OpenAPI 3.0 uses and Go types
+// map to
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// OpenAPIComponents holds the definitions operations refer to
+type OpenAPIComponents struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]*Response      `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way of authenticating requests
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// WithRequestSchema documents the JSON body a route accepts, given as a
+// value of the Go type it is decoded into
+func WithRequestSchema(body any) RouteOption {
+	return func(rt *Route) {
+		rt.requestType = reflect.TypeOf(body)
+	}
+}
+
+// WithResponseSchema documents the status of a successful response and
+// its JSON body, given as a value of the Go type that is encoded; nil
+// means the body is not JSON or there is none
+func WithResponseSchema(status int, body any) RouteOption {
+	return func(rt *Route) {
+		rt.responseStatus = status
+		rt.responseType = reflect.TypeOf(body)
+	}
+}
+
+// OpenAPI describes the routes the server serves. Schemas come from the
+// Go types routes document with WithRequestSchema and WithResponseSchema,
+// summaries from the changelog and deprecations from its entries.
+func (s *Server) OpenAPI() OpenAPI {
+	rr := s.routes
+	if rr == nil {
+		rr = NewRouteRegistry()
+		s.registerRoutes(rr)
+	}
+
+	summaries := make(map[string]string)
+	for _, rel := range changelog {
+		for _, c := range rel.Changes {
+			if c.Kind == ChangeAdded {
+				summaries[c.Route] = c.Description
+			}
+		}
+	}
+
+	gen := schemaGenerator{schemas: make(map[string]*Schema)}
+	doc := OpenAPI{
+		OpenAPI: "3.0.3",
+		Info: OpenAPIInfo{
+			Title:   "quickserve",
+			Version: APIVersion,
+			Description: "IDs are rendered as strings with ?id_format=string. " +
+				"Errors are JSON objects with error and request_id; unexpected failures are RFC 9457 problem details.",
+		},
+		Paths: make(map[string]PathItem),
+		Components: OpenAPIComponents{
+			Schemas:         gen.schemas,
+			SecuritySchemes: s.securitySchemes(),
+			Responses: map[string]*Response{
+				"Error":   {Description: "The request failed", Content: jsonContent(gen.schema(reflect.TypeOf(errorBody{})))},
+				"Problem": {Description: "Unexpected server error", Content: map[string]MediaType{"application/problem+json": {Schema: gen.schema(reflect.TypeOf(problem{}))}}},
+			},
+		},
+	}
+
+	var security []map[string][]string
+	for _, name := range sortedKeys(doc.Components.SecuritySchemes) {
+		security = append(security, map[string][]string{name: {}})
+	}
+
+	for _, rt := range rr.Routes() {
+		if rt.Method == "" {
+			continue
+		}
+		pattern := rt.Pattern()
+		path, params := openAPITemplate(rt.Path)
+		op := &Operation{
+			OperationID: operationID(rt.Method, rt.Path),
+			Summary:     summaries[pattern],
+			Tags:        []string{rt.Module},
+			Parameters:  append(params, rt.queryParams...),
+			Responses: map[string]*Response{
+				"default": {Ref: "#/components/responses/Error"},
+				"500":     {Ref: "#/components/responses/Problem"},
+			},
+		}
+		for _, d := range s.deprecationsOf(&rt) {
+			if d.Field != "" {
+				continue
+			}
+			op.Deprecated = true
+			if d.Since != "" {
+				op.Description = "Deprecated since " + d.Since + ": " + d.Description
+			} else {
+				op.Description = "Deprecated: " + d.Description
+			}
+			if d.Sunset != nil {
+				op.Description += "; sunset on " + d.Sunset.Format(time.DateOnly)
+			}
+		}
+		if perm, ok := routePermissions[pattern]; ok {
+			op.Description = strings.TrimSpace(op.Description + " Requires the " + string(perm) + " permission.")
+			op.Security = security
+		}
+		if rt.requestType != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: jsonContent(gen.schema(rt.requestType))}
+		}
+		status := rt.responseStatus
+		if status == 0 {
+			status = http.StatusOK
+		}
+		ok := &Response{Description: http.StatusText(status)}
+		if rt.responseType != nil {
+			ok.Content = jsonContent(gen.schema(rt.responseType))
+		}
+		op.Responses[strconv.Itoa(status)] = ok
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(rt.Method)] = op
+	}
+	return doc
+}
+
+// HandleOpenAPI handles GET /openapi.json
+func (s *Server) HandleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, s.OpenAPI())
+}
+
+// securitySchemes lists the authentication methods that are enabled
+func (s *Server) securitySchemes() map[string]SecurityScheme {
+	schemes := make(map[string]SecurityScheme)
+	if s.apiKeyAuth {
+		schemes["apiKey"] = SecurityScheme{Type: "apiKey", In: "header", Name: APIKeyHeader}
+	}
+	if s.adminAuth {
+		schemes["basic"] = SecurityScheme{Type: "http", Scheme: "basic"}
+	}
+	// Session tokens from POST /login are accepted wherever authentication
+	// is, and OpenID Connect tokens when it is configured
+	if s.authRequired() {
+		schemes["bearer"] = SecurityScheme{Type: "http", Scheme: "bearer"}
+	}
+	return schemes
+}
+
+// openAPITemplate turns a ServeMux path into an OpenAPI path template
+// and its path parameters. Parameters named id are IDs; others are
+// strings.
+func openAPITemplate(path string) (string, []Parameter) {
+	var params []Parameter
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if seg == "{$}" {
+			segs[i] = ""
+			continue
+		}
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			continue
+		}
+		name := strings.TrimSuffix(strings.Trim(seg, "{}"), "...")
+		segs[i] = "{" + name + "}"
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema = &Schema{Type: "integer", Format: "int64"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// operationID derives an identifier such as getUsersByIdNotifications
+// from a route
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || seg == "{$}" {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By")
+			seg = strings.TrimSuffix(strings.Trim(seg, "{}"), "...")
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(upperFirst(word))
+		}
+	}
+	return b.String()
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var roleType = reflect.TypeOf(Role(""))
+
+// schemaGenerator derives schemas from Go types the way encoding/json
+// encodes them, like schemaOf. Named structs become shared components.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func (g schemaGenerator) schema(t reflect.Type) *Schema {
+	switch t {
+	case idType:
+		if _, ok := g.schemas["ID"]; !ok {
+			g.schemas["ID"] = &Schema{
+				Description: "A 64-bit ID; a string with ?id_format=string, and accepted as either",
+				OneOf:       []*Schema{{Type: "integer", Format: "int64"}, {Type: "string", Format: "int64"}},
+			}
+		}
+		return &Schema{Ref: "#/components/schemas/ID"}
+	case roleType:
+		var roles []string
+		for r := range rolePermissions {
+			roles = append(roles, string(r))
+		}
+		slices.Sort(roles)
+		return &Schema{Type: "string", Enum: roles}
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := upperFirst(t.Name())
+		if _, ok := g.schemas[name]; !ok {
+			// Reserved first, so recursive types refer to themselves
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// Interfaces hold any value
+	return &Schema{}
+}
+
+// object describes a struct's exported fields. Fields without omitempty
+// that are not pointers are required.
+func (g schemaGenerator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
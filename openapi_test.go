@@ -0,0 +1,87 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+type widget struct {
+	ID       ID        `json:"id"`
+	Name     string    `json:"name"`
+	Tags     []string  `json:"tags,omitempty"`
+	Parent   *widget   `json:"parent,omitempty"`
+	Children []*widget `json:"children"`
+}
+
+type widgetModule struct{}
+
+func (widgetModule) Name() string { return "widgets" }
+
+func (widgetModule) RegisterRoutes(g *RouteGroup) {
+	g.HandleFunc("POST /widgets/{name...}", func(http.ResponseWriter, *http.Request) {},
+		WithRequestSchema(widget{}), WithResponseSchema(http.StatusCreated, widget{}))
+}
+
+func TestOpenAPI(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithModule(widgetModule{}))
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 without credentials, got %d", w.Code)
+	}
+	var doc OpenAPI
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rt := range server.routes.Routes() {
+		path, _ := openAPITemplate(rt.Path)
+		if doc.Paths[path][strings.ToLower(rt.Method)] == nil {
+			t.Errorf("%s is served but not described", rt.Pattern())
+		}
+	}
+
+	create := doc.Paths["/users"]["post"]
+	if create == nil || create.Summary != "Create a user" || len(create.Security) != 2 {
+		t.Fatalf("expected POST /users with its changelog summary and both schemes, got %+v", create)
+	}
+	if ref := create.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/CreateUserRequest" {
+		t.Errorf("expected the request body schema, got %q", ref)
+	}
+	if ref := create.Responses["201"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/User" {
+		t.Errorf("expected a 201 with a user, got %+v", create.Responses)
+	}
+	if got := doc.Components.Schemas["CreateUserRequest"].Required; !slices.Equal(got, []string{"name", "email"}) {
+		t.Errorf("expected only name and email to be required, got %v", got)
+	}
+	if role := doc.Components.Schemas["User"].Properties["role"]; !slices.Equal(role.Enum, []string{"admin", "user"}) {
+		t.Errorf("expected roles to be enumerated, got %+v", role)
+	}
+	if health := doc.Paths["/health"]["get"]; !health.Deprecated || health.Security != nil {
+		t.Errorf("expected /health to be public and deprecated, got %+v", health)
+	}
+	if doc.Components.Responses["Error"] == nil || doc.Components.Schemas["Problem"] == nil {
+		t.Error("expected the error shapes to be described")
+	}
+
+	op := doc.Paths["/widgets/{name}"]["post"]
+	if op == nil || op.OperationID != "postWidgetsByName" || op.Tags[0] != "widgets" || op.Parameters[0].Name != "name" {
+		t.Fatalf("expected the module's route, got %+v", op)
+	}
+	w2 := doc.Components.Schemas["Widget"]
+	if w2.Properties["parent"].Ref != "#/components/schemas/Widget" || w2.Properties["children"].Items.Ref != "#/components/schemas/Widget" {
+		t.Errorf("expected recursive references, got %+v", w2.Properties)
+	}
+	if !slices.Equal(w2.Required, []string{"id", "name", "children"}) {
+		t.Errorf("unexpected required fields: %v", w2.Required)
+	}
+}
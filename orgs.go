@@ -0,0 +1,665 @@
+package quickserve
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Org is an organization that groups users into teams
+type Org struct {
+	ID        ID        `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Team belongs to an org and may be nested under a parent team
+type Team struct {
+	ID        ID        `json:"id"`
+	OrgID     ID        `json:"org_id"`
+	ParentID  ID        `json:"parent_id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Membership gives a user a role in an org, or in one of its teams when
+// TeamID is set. Roles are inherited downwards: an org admin is an admin
+// of every team, and a team member is a member of its sub-teams.
+type Membership struct {
+	UserID ID   `json:"user_id"`
+	OrgID  ID   `json:"org_id"`
+	TeamID ID   `json:"team_id,omitempty"`
+	Role   Role `json:"role"`
+	// Inherited is set in listings for members who have no direct
+	// membership and get their role from the org or a parent team
+	Inherited bool      `json:"inherited,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+var (
+	errOrgNotFound    = errors.New("org not found")
+	errTeamNotFound   = errors.New("team not found")
+	errMemberNotFound = errors.New("member not found")
+)
+
+type memberKey struct {
+	org, team, user ID
+}
+
+// String identifies the membership in audit entries
+func (k memberKey) String() string {
+	return fmt.Sprintf("org:%d/team:%d/user:%d", k.org, k.team, k.user)
+}
+
+// OrgStore is an in-memory store of orgs, teams and memberships
+type OrgStore struct {
+	mu       sync.RWMutex
+	orgs     map[ID]Org
+	teams    map[ID]Team
+	members  map[memberKey]Membership
+	nextOrg  ID
+	nextTeam ID
+	clock    Clock
+}
+
+// NewOrgStore creates an empty org store
+func NewOrgStore() *OrgStore {
+	return &OrgStore{
+		orgs:     make(map[ID]Org),
+		teams:    make(map[ID]Team),
+		members:  make(map[memberKey]Membership),
+		nextOrg:  1,
+		nextTeam: 1,
+		clock:    SystemClock{},
+	}
+}
+
+// CreateOrg adds an org
+func (s *OrgStore) CreateOrg(name string) Org {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	org := Org{ID: s.nextOrg, Name: name, CreatedAt: now, UpdatedAt: now}
+	s.orgs[org.ID] = org
+	s.nextOrg++
+	return org
+}
+
+// GetOrg retrieves an org by ID
+func (s *OrgStore) GetOrg(id ID) (Org, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	org, ok := s.orgs[id]
+	return org, ok
+}
+
+// ListOrgs returns all orgs ordered by ID
+func (s *OrgStore) ListOrgs() []Org {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := make([]Org, 0, len(s.orgs))
+	for _, o := range s.orgs {
+		orgs = append(orgs, o)
+	}
+	slices.SortFunc(orgs, func(a, b Org) int { return cmp.Compare(a.ID, b.ID) })
+	return orgs
+}
+
+// DeleteOrg removes an org with its teams and memberships
+func (s *OrgStore) DeleteOrg(id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[id]; !ok {
+		return false
+	}
+	delete(s.orgs, id)
+	for tid, t := range s.teams {
+		if t.OrgID == id {
+			delete(s.teams, tid)
+		}
+	}
+	for k := range s.members {
+		if k.org == id {
+			delete(s.members, k)
+		}
+	}
+	return true
+}
+
+// CreateTeam adds a team to an org, nested under parent if it is non-zero
+func (s *OrgStore) CreateTeam(org, parent ID, name string) (Team, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[org]; !ok {
+		return Team{}, errOrgNotFound
+	}
+	if parent != 0 {
+		if p, ok := s.teams[parent]; !ok || p.OrgID != org {
+			return Team{}, errTeamNotFound
+		}
+	}
+
+	now := s.clock.Now()
+	team := Team{ID: s.nextTeam, OrgID: org, ParentID: parent, Name: name, CreatedAt: now, UpdatedAt: now}
+	s.teams[team.ID] = team
+	s.nextTeam++
+	return team, nil
+}
+
+// Teams returns an org's teams ordered by ID
+func (s *OrgStore) Teams(org ID) []Team {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teams := make([]Team, 0)
+	for _, t := range s.teams {
+		if t.OrgID == org {
+			teams = append(teams, t)
+		}
+	}
+	slices.SortFunc(teams, func(a, b Team) int { return cmp.Compare(a.ID, b.ID) })
+	return teams
+}
+
+// GetTeam retrieves a team by ID, whatever its org
+func (s *OrgStore) GetTeam(id ID) (Team, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.teams[id]
+	return t, ok
+}
+
+// ListTeams returns the teams of every org ordered by ID
+func (s *OrgStore) ListTeams() []Team {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	teams := make([]Team, 0, len(s.teams))
+	for _, t := range s.teams {
+		teams = append(teams, t)
+	}
+	slices.SortFunc(teams, func(a, b Team) int { return cmp.Compare(a.ID, b.ID) })
+	return teams
+}
+
+// DeleteTeam removes a team, its sub-teams and their memberships
+func (s *OrgStore) DeleteTeam(org, team ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.teams[team]; !ok || t.OrgID != org {
+		return false
+	}
+	doomed := map[ID]bool{team: true}
+	// Collect descendants level by level until a pass finds no more
+	for changed := true; changed; {
+		changed = false
+		for id, t := range s.teams {
+			if !doomed[id] && doomed[t.ParentID] {
+				doomed[id] = true
+				changed = true
+			}
+		}
+	}
+	for id := range doomed {
+		delete(s.teams, id)
+	}
+	for k := range s.members {
+		if doomed[k.team] {
+			delete(s.members, k)
+		}
+	}
+	return true
+}
+
+// SetMember adds a user to an org, or to a team when team is non-zero,
+// or changes their role there
+func (s *OrgStore) SetMember(org, team, user ID, role Role) (Membership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[org]; !ok {
+		return Membership{}, errOrgNotFound
+	}
+	if team != 0 {
+		if t, ok := s.teams[team]; !ok || t.OrgID != org {
+			return Membership{}, errTeamNotFound
+		}
+	}
+
+	now := s.clock.Now()
+	k := memberKey{org, team, user}
+	m, ok := s.members[k]
+	if !ok {
+		m = Membership{UserID: user, OrgID: org, TeamID: team, CreatedAt: now}
+	}
+	m.Role = role
+	m.UpdatedAt = now
+	s.members[k] = m
+	return m, nil
+}
+
+// Member returns a user's direct membership of an org or team
+func (s *OrgStore) Member(org, team, user ID) (Membership, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, ok := s.members[memberKey{org, team, user}]
+	return m, ok
+}
+
+// RemoveMember removes and returns a user's direct membership
+func (s *OrgStore) RemoveMember(org, team, user ID) (Membership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := memberKey{org, team, user}
+	m, ok := s.members[k]
+	if !ok {
+		return Membership{}, errMemberNotFound
+	}
+	delete(s.members, k)
+	return m, nil
+}
+
+// RemoveUser drops every membership of a deleted user
+func (s *OrgStore) RemoveUser(user ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.members {
+		if k.user == user {
+			delete(s.members, k)
+		}
+	}
+}
+
+// scopesLocked returns the team followed by its ancestors and finally
+// the org itself (team 0): every place a role can be inherited from
+func (s *OrgStore) scopesLocked(team ID) []ID {
+	var scopes []ID
+	for team != 0 {
+		scopes = append(scopes, team)
+		team = s.teams[team].ParentID
+	}
+	return append(scopes, 0)
+}
+
+// Members lists the effective members of an org, or of a team when team
+// is non-zero, including those who inherit membership. Each user appears
+// once with the strongest role they hold.
+func (s *OrgStore) Members(org, team ID) ([]Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.orgs[org]; !ok {
+		return nil, errOrgNotFound
+	}
+	if team != 0 {
+		if t, ok := s.teams[team]; !ok || t.OrgID != org {
+			return nil, errTeamNotFound
+		}
+	}
+
+	byUser := make(map[ID]Membership)
+	for _, scope := range s.scopesLocked(team) {
+		for k, m := range s.members {
+			if k.org != org || k.team != scope {
+				continue
+			}
+			m.Inherited = scope != team
+			prev, seen := byUser[k.user]
+			switch {
+			case !seen:
+				byUser[k.user] = m
+			case strongerRole(m.Role, prev.Role):
+				m.Inherited = prev.Inherited && m.Inherited
+				byUser[k.user] = m
+			}
+		}
+	}
+
+	members := make([]Membership, 0, len(byUser))
+	for _, m := range byUser {
+		members = append(members, m)
+	}
+	slices.SortFunc(members, func(a, b Membership) int { return cmp.Compare(a.UserID, b.UserID) })
+	return members, nil
+}
+
+// UserIDs returns every user with a membership anywhere in the org
+func (s *OrgStore) UserIDs(org ID) []ID {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[ID]bool)
+	for k := range s.members {
+		if k.org == org {
+			seen[k.user] = true
+		}
+	}
+	ids := make([]ID, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// RoleIn returns the strongest role a user holds in an org, or in a team
+// when team is non-zero, counting inherited memberships
+func (s *OrgStore) RoleIn(org, team, user ID) (Role, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var role Role
+	for _, scope := range s.scopesLocked(team) {
+		if m, ok := s.members[memberKey{org, scope, user}]; ok && (role == "" || strongerRole(m.Role, role)) {
+			role = m.Role
+		}
+	}
+	return role, role != ""
+}
+
+// strongerRole reports whether a grants more than b
+func strongerRole(a, b Role) bool {
+	return a == RoleAdmin && b != RoleAdmin
+}
+
+// orgRole resolves the caller's role in the org and team named by the
+// request path. Callers are matched to users by email.
+func (s *Server) orgRole(r *http.Request, p Principal) (Role, bool) {
+	org, team, err := s.scopePathIDs(r)
+	if err != nil || p.Email == "" {
+		return "", false
+	}
+	u, ok, err := s.users(r.Context()).FindByEmail(r.Context(), p.Email)
+	if err != nil || !ok {
+		return "", false
+	}
+	return s.orgs.RoleIn(org, team, u.ID)
+}
+
+// orgPathIDs parses the {org} and optional {team} path values
+func orgPathIDs(r *http.Request) (org, team ID, err error) {
+	if org, err = ParseID(r.PathValue("org")); err != nil {
+		return 0, 0, err
+	}
+	if v := r.PathValue("team"); v != "" {
+		if team, err = ParseID(v); err != nil {
+			return 0, 0, err
+		}
+	}
+	return org, team, nil
+}
+
+// scopePathIDs is orgPathIDs for routes that may name only a team, such
+// as those under /teams, taking the org from the team
+func (s *Server) scopePathIDs(r *http.Request) (org, team ID, err error) {
+	if r.PathValue("org") != "" {
+		return orgPathIDs(r)
+	}
+	if team, err = ParseID(r.PathValue("team")); err != nil {
+		return 0, 0, err
+	}
+	t, ok := s.orgs.GetTeam(team)
+	if !ok {
+		return 0, 0, errTeamNotFound
+	}
+	return t.OrgID, team, nil
+}
+
+// writeOrgError maps store errors to responses
+func writeOrgError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errOrgNotFound), errors.Is(err, errTeamNotFound), errors.Is(err, errMemberNotFound):
+		httpError(w, r, err.Error(), http.StatusNotFound)
+	default:
+		httpError(w, r, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// HandleListOrgs handles GET /orgs
+func (s *Server) HandleListOrgs(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, s.orgs.ListOrgs())
+}
+
+// HandleCreateOrg handles POST /orgs
+func (s *Server) HandleCreateOrg(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Name == "" {
+		httpError(w, r, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	org := s.orgs.CreateOrg(req.Name)
+	s.recordAudit(r, AuditCreate, "org", org.ID.String(), nil, org)
+
+	s.writeJSON(w, r, http.StatusCreated, org)
+}
+
+// HandleGetOrg handles GET /orgs/{org}
+func (s *Server) HandleGetOrg(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("org"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	org, ok := s.orgs.GetOrg(id)
+	if !ok {
+		httpError(w, r, "org not found", http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, org)
+}
+
+// HandleDeleteOrg handles DELETE /orgs/{org}
+func (s *Server) HandleDeleteOrg(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("org"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	org, ok := s.orgs.GetOrg(id)
+	if !ok || !s.orgs.DeleteOrg(id) {
+		httpError(w, r, "org not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "org", id.String(), org, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListTeams handles GET /orgs/{org}/teams
+func (s *Server) HandleListTeams(w http.ResponseWriter, r *http.Request) {
+	org, _, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.orgs.GetOrg(org); !ok {
+		httpError(w, r, "org not found", http.StatusNotFound)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, s.orgs.Teams(org))
+}
+
+// HandleCreateTeam handles POST /orgs/{org}/teams
+func (s *Server) HandleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	org, _, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Name     string `json:"name"`
+		ParentID ID     `json:"parent_id"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Name == "" {
+		httpError(w, r, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	team, err := s.orgs.CreateTeam(org, req.ParentID, req.Name)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "team", team.ID.String(), nil, team)
+	s.writeJSON(w, r, http.StatusCreated, team)
+}
+
+// HandleDeleteTeam handles DELETE /orgs/{org}/teams/{team}
+func (s *Server) HandleDeleteTeam(w http.ResponseWriter, r *http.Request) {
+	org, team, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var before any
+	for _, t := range s.orgs.Teams(org) {
+		if t.ID == team {
+			before = t
+		}
+	}
+	if !s.orgs.DeleteTeam(org, team) {
+		httpError(w, r, "team not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "team", team.String(), before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListMembers handles GET /orgs/{org}/members and
+// GET /orgs/{org}/teams/{team}/members
+func (s *Server) HandleListMembers(w http.ResponseWriter, r *http.Request) {
+	org, team, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	members, err := s.orgs.Members(org, team)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, members)
+}
+
+// HandleSetMember handles PUT /orgs/{org}/members/{user} and
+// PUT /orgs/{org}/teams/{team}/members/{user}
+func (s *Server) HandleSetMember(w http.ResponseWriter, r *http.Request) {
+	org, team, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user, err := ParseID(r.PathValue("user"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Role Role `json:"role"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleUser
+	}
+	if !req.Role.Valid() {
+		httpError(w, r, "invalid role", http.StatusBadRequest)
+		return
+	}
+	if _, ok, err := s.users(r.Context()).Get(r.Context(), user); err != nil {
+		writeStoreError(w, r, err)
+		return
+	} else if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return
+	}
+
+	prev, existed := s.orgs.Member(org, team, user)
+	m, err := s.orgs.SetMember(org, team, user, req.Role)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	if existed {
+		s.recordAudit(r, AuditUpdate, "membership", memberKey{org, team, user}.String(), prev, m)
+	} else {
+		s.recordAudit(r, AuditCreate, "membership", memberKey{org, team, user}.String(), nil, m)
+	}
+	s.writeJSON(w, r, http.StatusOK, m)
+}
+
+// HandleRemoveMember handles DELETE /orgs/{org}/members/{user} and
+// DELETE /orgs/{org}/teams/{team}/members/{user}
+func (s *Server) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	org, team, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	user, err := ParseID(r.PathValue("user"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	m, err := s.orgs.RemoveMember(org, team, user)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "membership", memberKey{org, team, user}.String(), m, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListOrgUsers handles GET /orgs/{org}/users, listing every user
+// with a membership in the org or any of its teams
+func (s *Server) HandleListOrgUsers(w http.ResponseWriter, r *http.Request) {
+	org, _, err := orgPathIDs(r)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.orgs.GetOrg(org); !ok {
+		httpError(w, r, "org not found", http.StatusNotFound)
+		return
+	}
+
+	users := make([]User, 0)
+	for _, id := range s.orgs.UserIDs(org) {
+		u, ok, err := s.users(r.Context()).Get(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		if ok {
+			users = append(users, u)
+		}
+	}
+	s.writeJSON(w, r, http.StatusOK, users)
+}
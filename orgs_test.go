@@ -0,0 +1,134 @@
+package quickserve
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestOrgMembershipInheritance(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewOrgStore()
+	org := store.CreateOrg("Acme")
+	eng, _ := store.CreateTeam(org.ID, 0, "Engineering")
+	backend, _ := store.CreateTeam(org.ID, eng.ID, "Backend")
+
+	store.SetMember(org.ID, 0, 1, RoleAdmin)
+	store.SetMember(org.ID, eng.ID, 2, RoleUser)
+	store.SetMember(org.ID, backend.ID, 3, RoleUser)
+
+	if role, ok := store.RoleIn(org.ID, backend.ID, 1); !ok || role != RoleAdmin {
+		t.Errorf("expected org admin to be admin of nested teams, got %q", role)
+	}
+	if role, ok := store.RoleIn(org.ID, backend.ID, 2); !ok || role != RoleUser {
+		t.Errorf("expected parent team member to inherit, got %q", role)
+	}
+	if _, ok := store.RoleIn(org.ID, eng.ID, 3); ok {
+		t.Error("expected membership not to propagate upwards")
+	}
+
+	members, err := store.Members(org.ID, backend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 3 {
+		t.Fatalf("expected 3 effective members, got %d", len(members))
+	}
+	if !members[0].Inherited || !members[1].Inherited || members[2].Inherited {
+		t.Errorf("unexpected inherited flags: %+v", members)
+	}
+
+	if _, err := store.CreateTeam(store.CreateOrg("Other").ID, eng.ID, "x"); err != errTeamNotFound {
+		t.Errorf("expected parent from another org to be rejected, got %v", err)
+	}
+
+	store.DeleteTeam(org.ID, eng.ID)
+	if len(store.Teams(org.ID)) != 0 {
+		t.Error("expected sub-teams to be deleted with their parent")
+	}
+	if ids := store.UserIDs(org.ID); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("expected only the org member to remain, got %v", ids)
+	}
+}
+
+func TestOrgEndpoints(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	addUser(t, server, User{Name: "Alice", Email: "alice@example.com"})
+	routes := server.Routes()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodPost, "/orgs", `{"name":"Acme"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/orgs/1/teams", `{"name":"Eng"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/orgs/1/teams/1/members/1", `{"role":"admin"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPut, "/orgs/1/members/99", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown user, got %d", w.Code)
+	}
+
+	w := do(http.MethodGet, "/orgs/1/users", "")
+	var users []User
+	if err := json.NewDecoder(w.Body).Decode(&users); err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Email != "alice@example.com" {
+		t.Errorf("expected Alice in the org, got %+v", users)
+	}
+
+	if w := do(http.MethodDelete, "/users/1", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/orgs/1/teams/1/members", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected deleted users to lose memberships, got %s", w.Body)
+	}
+}
+
+func TestOrgRoleGrantsPermissions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ca := newTestCA(t)
+	server := NewServer(WithClientCAs(ca.pool))
+	alice := addUser(t, server, User{Name: "Alice", Email: "alice@example.com"})
+	acme := server.orgs.CreateOrg("Acme")
+	other := server.orgs.CreateOrg("Other")
+	server.orgs.SetMember(acme.ID, 0, alice.ID, RoleAdmin)
+	routes := server.Routes()
+
+	cert, _ := x509.ParseCertificate(ca.issue(t, "alice", "alice@example.com").Certificate[0])
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do(http.MethodPost, "/orgs/"+acme.ID.String()+"/teams", `{"name":"Eng"}`); code != http.StatusCreated {
+		t.Errorf("expected org admin to manage their org, got %d", code)
+	}
+	if code := do(http.MethodPost, "/orgs/"+other.ID.String()+"/teams", `{"name":"Eng"}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 in another org, got %d", code)
+	}
+	if code := do(http.MethodPost, "/orgs", `{"name":"Mine"}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 creating orgs without a global role, got %d", code)
+	}
+}
@@ -0,0 +1,207 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Page sizes of GET /users once paginated
+const (
+	defaultUsersLimit = 100
+	maxUsersLimit     = 1000
+)
+
+// UserSort is an order users are listed in. Ties are broken by ID, so
+// every user has a position of its own.
+type UserSort string
+
+const (
+	// SortByID lists users in ID order, the default
+	SortByID UserSort = "id"
+	// SortByName lists users by name
+	SortByName UserSort = "name"
+	// SortByCreatedAt lists users oldest first
+	SortByCreatedAt UserSort = "created_at"
+)
+
+// Valid reports whether o is a known order
+func (o UserSort) Valid() bool {
+	return o == SortByID || o == SortByName || o == SortByCreatedAt
+}
+
+// Compare orders a and b by o, then by ID
+func (o UserSort) Compare(a, b User) int {
+	var c int
+	switch o {
+	case SortByName:
+		c = strings.Compare(a.Name, b.Name)
+	case SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	return cmp.Or(c, cmp.Compare(a.ID, b.ID))
+}
+
+// UserPager is implemented by user stores that iterate their users in
+// order, so a page is read without listing every user. The users of
+// other stores are listed and sorted in full for each page.
+type UserPager interface {
+	// ListAfter returns up to limit users in order, starting after the
+	// position of after, or with the first user if after is nil. Only the
+	// fields order compares are set on after.
+	ListAfter(ctx context.Context, order UserSort, after *User, limit int) ([]User, error)
+}
+
+// userPager returns the pager of st, looking through the tracing
+// decorator
+func userPager(st UserStore) (UserPager, bool) {
+	if t, ok := st.(tracedUserStore); ok {
+		if _, ok := t.UserStore.(UserPager); !ok {
+			return nil, false
+		}
+		return t, true
+	}
+	p, ok := st.(UserPager)
+	return p, ok
+}
+
+// listAfter reads a page of the users of st
+func listAfter(ctx context.Context, st UserStore, order UserSort, after *User, limit int) ([]User, error) {
+	if p, ok := userPager(st); ok {
+		return p.ListAfter(ctx, order, after, limit)
+	}
+	users, err := st.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pageOf(users, order, after, limit), nil
+}
+
+// pageOf returns up to limit of users in order, starting after the
+// position of after
+func pageOf(users []User, order UserSort, after *User, limit int) []User {
+	if after != nil {
+		users = slices.DeleteFunc(users, func(u User) bool { return order.Compare(u, *after) <= 0 })
+	}
+	slices.SortFunc(users, order.Compare)
+	return users[:min(len(users), limit)]
+}
+
+// ListAfter walks IDs in order for SortByID, so a page costs about as
+// much as its users wherever it starts. Other orders compare every user,
+// and only sort those after the cursor. A position past the last ID
+// assigned is errInvalidCursor.
+func (s *MemoryUserStore) ListAfter(ctx context.Context, order UserSort, after *User, limit int) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	last := ID(s.next.Load())
+	if after != nil && after.ID > last {
+		return nil, errInvalidCursor
+	}
+	if order != SortByID {
+		var users []User
+		s.each(func(u User) bool {
+			if after == nil || order.Compare(u, *after) > 0 {
+				users = append(users, u)
+			}
+			return true
+		})
+		return pageOf(users, order, nil, limit), nil
+	}
+
+	var from ID = 1
+	if after != nil {
+		from = after.ID + 1
+	}
+	users := make([]User, 0, min(limit, defaultUsersLimit))
+	// IDs are assigned in order, and none is above the last one assigned
+	for id := from; id <= last && len(users) < limit; id++ {
+		u, ok, err := s.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+// ListAfter pages through the users of the tenant
+func (st tenantScope) ListAfter(ctx context.Context, order UserSort, after *User, limit int) ([]User, error) {
+	users, err := st.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pageOf(users, order, after, limit), nil
+}
+
+// userCursor is the position of a user in an order. Clients get it as
+// next_cursor and pass it back in ?cursor=; it is opaque to them.
+type userCursor struct {
+	Sort UserSort `json:"s"`
+	ID   ID       `json:"id"`
+	// Key is the value of the sort field, for orders other than ID
+	Key string `json:"k,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns the cursor of u's position in order
+func encodeCursor(order UserSort, u User) string {
+	c := userCursor{Sort: order, ID: u.ID}
+	switch order {
+	case SortByName:
+		c.Key = u.Name
+	case SortByCreatedAt:
+		c.Key = u.CreatedAt.Format(time.RFC3339Nano)
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses a cursor encodeCursor returned
+func decodeCursor(s string) (userCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return userCursor{}, errInvalidCursor
+	}
+	var c userCursor
+	if err := json.Unmarshal(b, &c); err != nil || !c.Sort.Valid() {
+		return userCursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+// position returns a user at the cursor's position, with the fields its
+// order compares set
+func (c userCursor) position() (User, error) {
+	if c.ID < 0 {
+		return User{}, errInvalidCursor
+	}
+	u := User{ID: c.ID}
+	switch c.Sort {
+	case SortByName:
+		u.Name = c.Key
+	case SortByCreatedAt:
+		t, err := time.Parse(time.RFC3339Nano, c.Key)
+		if err != nil {
+			return User{}, errInvalidCursor
+		}
+		u.CreatedAt = t
+	}
+	return u, nil
+}
+
+// usersPage is the response of GET /users when paginated
+type usersPage struct {
+	Users any `json:"users"`
+	// NextCursor continues after this page; it is left out on the last
+	NextCursor string `json:"next_cursor,omitempty"`
+}
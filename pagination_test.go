@@ -0,0 +1,150 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestMemoryUserStoreListAfter(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	clock := NewSimulatedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := NewMemoryUserStore()
+	store.clock = clock
+	for _, name := range []string{"Carol", "Alice", "Bob", "Alice", "Dave"} {
+		clock.Advance(-time.Minute)
+		store.Insert(ctx, User{Name: name, Email: strings.ToLower(name) + "@example.com"}, 0, 0)
+	}
+	store.Delete(ctx, 3)
+
+	for _, order := range []UserSort{SortByID, SortByName, SortByCreatedAt} {
+		all, _ := store.List(ctx)
+		want := pageOf(all, order, nil, len(all))
+
+		// Two at a time must give what sorting everyone does
+		var got []User
+		var after *User
+		for {
+			page, err := store.ListAfter(ctx, order, after, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			got = append(got, page...)
+			after = &page[len(page)-1]
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d users, got %d", order, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Errorf("%s: expected user %d at %d, got %d", order, want[i].ID, i, got[i].ID)
+			}
+		}
+	}
+}
+
+func TestUserCursor(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	u := User{ID: 7, Name: "Alice", CreatedAt: time.Date(2030, 1, 1, 0, 0, 0, 5, time.UTC)}
+	for _, order := range []UserSort{SortByID, SortByName, SortByCreatedAt} {
+		c, err := decodeCursor(encodeCursor(order, u))
+		if err != nil {
+			t.Fatalf("%s: %v", order, err)
+		}
+		pos, err := c.position()
+		if err != nil || c.Sort != order || order.Compare(pos, u) != 0 {
+			t.Errorf("%s: expected the cursor at the user, got %+v", order, pos)
+		}
+	}
+	for _, s := range []string{"", "!", "bm9wZQ", encodeCursor("color", u)} {
+		if _, err := decodeCursor(s); err == nil {
+			t.Errorf("%q: expected an invalid cursor", s)
+		}
+	}
+	if _, err := (userCursor{Sort: SortByID, ID: -1}).position(); err == nil {
+		t.Error("expected a negative ID refused")
+	}
+}
+
+func TestMemoryUserStoreListAfterBounds(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewMemoryUserStore()
+	store.Insert(context.Background(), User{Name: "Alice", Email: "alice@example.com"}, 0, 0)
+
+	// A cursor past the last ID would otherwise overflow or walk for ever
+	for _, id := range []ID{2, math.MaxInt64} {
+		if _, err := store.ListAfter(context.Background(), SortByID, &User{ID: id}, 10); err != errInvalidCursor {
+			t.Errorf("%d: expected an invalid cursor, got %v", id, err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.ListAfter(ctx, SortByID, nil, 10); err != context.Canceled {
+		t.Errorf("expected the walk to stop with the request, got %v", err)
+	}
+}
+
+func TestListUsersPages(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	for _, name := range []string{"Carol", "Alice", "Bob"} {
+		addUser(t, server, User{Name: name, Email: strings.ToLower(name) + "@example.com"})
+	}
+	routes := server.Routes()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	type page struct {
+		Users      []User `json:"users"`
+		NextCursor string `json:"next_cursor"`
+	}
+
+	var first page
+	json.NewDecoder(get("/users?limit=2&sort=name").Body).Decode(&first)
+	if len(first.Users) != 2 || first.Users[0].Name != "Alice" || first.NextCursor == "" {
+		t.Fatalf("expected the first page by name, got %+v", first)
+	}
+	// Users added before the cursor don't shift the next page, as they
+	// would with offsets
+	addUser(t, server, User{Name: "Aaron", Email: "aaron@example.com"})
+	var second page
+	json.NewDecoder(get("/users?limit=2&cursor=" + first.NextCursor).Body).Decode(&second)
+	if len(second.Users) != 1 || second.Users[0].Name != "Carol" || second.NextCursor != "" {
+		t.Errorf("expected the last page to hold only carol, got %+v", second)
+	}
+
+	for path, want := range map[string]string{
+		"/users?cursor=" + first.NextCursor + "&sort=id": "cursor is for another sort",
+		"/users?cursor=x": "invalid cursor",
+		"/users?cursor=" + encodeCursor(SortByID, User{ID: math.MaxInt64}): "invalid cursor",
+		"/users?limit=1001": "limit",
+		"/users?sort=email": "sort",
+	} {
+		if w := get(path); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("%s: expected 400 with %q, got %d: %s", path, want, w.Code, w.Body)
+		}
+	}
+	// Without a page every user is listed, as before
+	var users []User
+	json.NewDecoder(get("/users?sort=created_at").Body).Decode(&users)
+	if len(users) != 4 {
+		t.Errorf("expected every user, got %+v", users)
+	}
+}
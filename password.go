@@ -0,0 +1,140 @@
+package quickserve
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength is the shortest accepted password. bcrypt itself
+// caps passwords at 72 bytes.
+const minPasswordLength = 8
+
+// loginThrottle limits login attempts per IP to slow down password
+// guessing
+var loginThrottle = RateLimit{Rate: 10.0 / 60, Burst: 10}
+
+var errPasswordLength = errors.New("password must be between 8 and 72 bytes")
+
+// passwordCost is the bcrypt work factor; tests lower it
+var passwordCost = bcrypt.DefaultCost
+
+// hashPassword validates and hashes a new password
+func hashPassword(password string) ([]byte, error) {
+	if len(password) < minPasswordLength {
+		return nil, errPasswordLength
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return nil, errPasswordLength
+	}
+	return hash, err
+}
+
+// SetPassword validates password and stores its hash, so the user can log
+// in once inserted into a store
+func (u *User) SetPassword(password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	u.passwordHash = hash
+	return nil
+}
+
+// optionalPassword hashes password if one was given. Users created
+// without one cannot log in with a password.
+func optionalPassword(w http.ResponseWriter, r *http.Request, password string) ([]byte, bool) {
+	if password == "" {
+		return nil, true
+	}
+	hash, err := hashPassword(password)
+	if errors.Is(err, errPasswordLength) {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		httpError(w, r, "could not hash password", http.StatusInternalServerError)
+		return nil, false
+	}
+	return hash, true
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// checkPassword reports whether password matches user's. Users without
+// a password are compared against a dummy hash so that response times do
+// not reveal which emails have accounts.
+func checkPassword(user User, password string) bool {
+	hash := user.passwordHash
+	if hash == nil {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), passwordCost)
+		})
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
+
+// HandleLogin handles POST /login, exchanging an email and password for a
+// session. The token is returned for API clients and set as a cookie for
+// browsers.
+func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	if d := s.loginLimiter.Allow(s.clientIP(r)); !d.Allowed {
+		setRetryAfter(w.Header(), d.RetryAfter)
+		httpError(w, r, "too many login attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Email == "" || req.Password == "" {
+		httpError(w, r, "email and password are required", http.StatusBadRequest)
+		return
+	}
+
+	user, _, err := s.users(r.Context()).FindByEmail(r.Context(), req.Email)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if !checkPassword(user, req.Password) {
+		httpError(w, r, "invalid email or password", http.StatusUnauthorized)
+		return
+	}
+
+	sess, token, err := s.startSession(r.Context(), user.ID)
+	if err != nil {
+		httpError(w, r, "could not create session", http.StatusInternalServerError)
+		return
+	}
+	s.recordLogin(r, userPrincipal(user))
+	setSessionCookie(w, r, token, sess.ExpiresAt)
+
+	s.writeJSON(w, r, http.StatusOK, struct {
+		Token     string    `json:"token"`
+		TokenType string    `json:"token_type"`
+		ExpiresAt time.Time `json:"expires_at"`
+		User      User      `json:"user"`
+	}{token, "Bearer", sess.ExpiresAt, user})
+}
+
+// userPrincipal identifies a user who logged in with a password
+func userPrincipal(u User) Principal {
+	return Principal{
+		Subject: "user:" + u.ID.String(),
+		Method:  "password",
+		Email:   u.Email,
+		Role:    u.Role,
+		Tenant:  u.Tenant,
+	}
+}
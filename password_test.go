@@ -0,0 +1,109 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// Keep the suite fast; the cost does not change behavior
+	passwordCost = bcrypt.MinCost
+}
+
+func TestPasswordLogin(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithAPIKeyAuth("admin-secret"), WithSessionTTL(time.Hour))
+	routes := server.Routes()
+
+	do := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+	admin := http.Header{APIKeyHeader: {"admin-secret"}}
+
+	w := do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com","password":"correct horse"}`, admin)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "password") || strings.Contains(w.Body.String(), "$2a$") {
+		t.Fatalf("password leaked into response: %s", w.Body)
+	}
+	if w := do(http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com","password":"short"}`, admin); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a short password, got %d", w.Code)
+	}
+	do(http.MethodPost, "/users", `{"name":"Carol","email":"carol@test.com"}`, admin)
+
+	for _, body := range []string{
+		`{"email":"alice@test.com","password":"wrong password"}`,
+		`{"email":"nobody@test.com","password":"correct horse"}`,
+		`{"email":"carol@test.com","password":"anything at all"}`,
+	} {
+		if w := do(http.MethodPost, "/login", body, nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", body, w.Code)
+		}
+	}
+
+	w = do(http.MethodPost, "/login", `{"email":"alice@test.com","password":"correct horse"}`, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var login struct {
+		Token     string    `json:"token"`
+		TokenType string    `json:"token_type"`
+		ExpiresAt time.Time `json:"expires_at"`
+		User      User      `json:"user"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&login); err != nil {
+		t.Fatal(err)
+	}
+	if login.Token == "" || login.TokenType != "Bearer" || login.User.ID != 1 || !login.ExpiresAt.Equal(clock.Now().Add(time.Hour)) {
+		t.Fatalf("unexpected login response: %+v", login)
+	}
+
+	bearer := http.Header{"Authorization": {"Bearer " + login.Token}}
+	if w := do(http.MethodGet, "/users/1", "", bearer); w.Code != http.StatusOK {
+		t.Errorf("expected the token to authenticate, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/users", `{"name":"Dan"}`, bearer); w.Code != http.StatusForbidden {
+		t.Errorf("expected the user's role to apply, got %d", w.Code)
+	}
+	if entries := server.audit.Query(AuditFilter{UserID: 1}); entries[len(entries)-1].Action != AuditLogin {
+		t.Errorf("expected the login to be recorded, got %+v", entries[len(entries)-1])
+	}
+
+	clock.Advance(time.Hour)
+	if w := do(http.MethodGet, "/users/1", "", bearer); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the token to expire, got %d", w.Code)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithClock(NewSimulatedClock(time.Now())))
+	routes := server.Routes()
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i <= loginThrottle.Burst; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"a@test.com","password":"guess guess"}`))
+		w = httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+	}
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected 429 with Retry-After once the burst is spent, got %d", w.Code)
+	}
+}
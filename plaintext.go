@@ -0,0 +1,199 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// wantsPlainText reports whether the client prefers text/plain to JSON.
+// A bare */* keeps JSON, so only clients that ask get text.
+func wantsPlainText(r *http.Request) bool {
+	if r.Header.Get("Accept") == "" {
+		return false
+	}
+	quality := acceptQuality(r)
+	text := quality("text/plain")
+	return text > 0 && text > quality("application/json")
+}
+
+// textObject is a JSON object that remembers its key order, so columns
+// appear in the order the API defines fields
+type textObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o textObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		val, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// decodeOrdered decodes the next JSON value, keeping object key order
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := textObject{values: make(map[string]any)}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := keyTok.(string)
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := obj.values[key]; !dup {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = v
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := make([]any, 0)
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// renderPlainText turns a JSON response into plain text for terminals and
+// screen readers: lists of objects become tables with a header row, single
+// objects become "key: value" lines, and lists of objects inside an object
+// (such as a page of items) follow as their own tables. There are no
+// borders or box-drawing characters, only aligned columns.
+func renderPlainText(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	switch v := v.(type) {
+	case []any:
+		writeTextList(&b, v)
+	case textObject:
+		var tables []string
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, k := range v.keys {
+			if list, ok := v.values[k].([]any); ok && isObjectList(list) {
+				tables = append(tables, k)
+				continue
+			}
+			fmt.Fprintf(tw, "%s:\t%s\n", k, textCell(v.values[k]))
+		}
+		tw.Flush()
+		for _, k := range tables {
+			fmt.Fprintf(&b, "\n%s:\n", k)
+			writeTextList(&b, v.values[k].([]any))
+		}
+	default:
+		fmt.Fprintln(&b, textCell(v))
+	}
+	return b.Bytes(), nil
+}
+
+// isObjectList reports whether list is non-empty and holds only objects
+func isObjectList(list []any) bool {
+	for _, item := range list {
+		if _, ok := item.(textObject); !ok {
+			return false
+		}
+	}
+	return len(list) > 0
+}
+
+// writeTextList writes a table for a list of objects, or one value per
+// line for anything else
+func writeTextList(b *bytes.Buffer, list []any) {
+	if len(list) == 0 {
+		fmt.Fprintln(b, "(none)")
+		return
+	}
+	if !isObjectList(list) {
+		for _, item := range list {
+			fmt.Fprintln(b, textCell(item))
+		}
+		return
+	}
+
+	var columns []string
+	seen := make(map[string]bool)
+	for _, item := range list {
+		for _, k := range item.(textObject).keys {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+	for _, item := range list {
+		obj := item.(textObject)
+		cells := make([]string, len(columns))
+		for i, k := range columns {
+			cells[i] = textCell(obj.values[k])
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+}
+
+// textCell renders a single value on one line. Missing and null values
+// are shown as "-" so columns never look shifted.
+func textCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return strings.Join(strings.Fields(v), " ")
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "-"
+	}
+	return string(b)
+}
@@ -0,0 +1,85 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestPlainTextRendering(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock))
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+	addUser(t, server, User{Name: "Bob Smith", Email: ""})
+	routes := server.Routes()
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/users/1", "text/plain")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("expected text/plain, got %q", ct)
+	}
+	want := "id:          1\n" +
+		"name:        Alice\n" +
+		"email:       alice@test.com\n" +
+		"role:        user\n" +
+		"created_at:  2024-01-01T00:00:00Z\n" +
+		"updated_at:  2024-01-01T00:00:00Z\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("unexpected object rendering:\n%s\nwant:\n%s", got, want)
+	}
+
+	w = get("/users?id_format=string", "text/plain, application/json;q=0.5")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id  name       email") {
+		t.Fatalf("expected a header row and two rows, got:\n%s", w.Body)
+	}
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "Bob Smith") && !strings.Contains(line, "Bob Smith  -") {
+			t.Errorf("expected empty values to render as -, got %q", line)
+		}
+	}
+
+	for _, accept := range []string{"", "*/*", "application/json", "text/plain;q=0.5, application/json"} {
+		if ct := get("/users", accept).Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Accept %q: expected JSON, got %q", accept, ct)
+		}
+	}
+}
+
+func TestRenderPlainTextNested(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	got, err := renderPlainText([]byte(`{"items":[{"id":2,"details":{"b":1,"a":true}}],"next_cursor":2,"tags":["x","y"],"empty":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "next_cursor:  2\n" +
+		"tags:         [\"x\",\"y\"]\n" +
+		"empty:        []\n" +
+		"\nitems:\n" +
+		"id  details\n" +
+		"2   {\"b\":1,\"a\":true}\n"
+	if string(got) != want {
+		t.Errorf("unexpected rendering:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, _ = renderPlainText([]byte(`[]`))
+	if string(got) != "(none)\n" {
+		t.Errorf("expected an empty list to say so, got %q", got)
+	}
+}
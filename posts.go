@@ -0,0 +1,331 @@
+package quickserve
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on the text of a post, in characters
+const (
+	maxPostTitle = 200
+	maxPostBody  = 10000
+)
+
+// Post is a piece of text written by a user. Posts live under their
+// author, at /users/{id}/posts.
+type Post struct {
+	ID        ID        `json:"id"`
+	UserID    ID        `json:"user_id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PostsOnUserDelete is what deleting a user who has posts does
+type PostsOnUserDelete string
+
+const (
+	// PostsCascade deletes the user's posts along with them
+	PostsCascade PostsOnUserDelete = "cascade"
+	// PostsBlock refuses to delete the user with 409 until their posts
+	// are deleted
+	PostsBlock PostsOnUserDelete = "block"
+)
+
+// Valid reports whether p is a known policy
+func (p PostsOnUserDelete) Valid() bool {
+	return p == PostsCascade || p == PostsBlock
+}
+
+// WithPostsOnUserDelete sets what deleting a user who has posts does;
+// the default is PostsCascade
+func WithPostsOnUserDelete(p PostsOnUserDelete) Option {
+	return func(s *Server) {
+		s.postsOnUserDelete = p
+	}
+}
+
+// PostStore is an in-memory store of posts, indexed by author
+type PostStore struct {
+	mu     sync.RWMutex
+	posts  map[ID]Post
+	byUser map[ID]map[ID]struct{}
+	nextID ID
+	clock  Clock
+}
+
+// NewPostStore creates an empty post store
+func NewPostStore() *PostStore {
+	return &PostStore{
+		posts:  make(map[ID]Post),
+		byUser: make(map[ID]map[ID]struct{}),
+		nextID: 1,
+		clock:  SystemClock{},
+	}
+}
+
+// Create adds a post by user
+func (s *PostStore) Create(user ID, title, body string) Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	p := Post{ID: s.nextID, UserID: user, Title: title, Body: body, CreatedAt: now, UpdatedAt: now}
+	s.nextID++
+	s.posts[p.ID] = p
+	if s.byUser[user] == nil {
+		s.byUser[user] = make(map[ID]struct{})
+	}
+	s.byUser[user][p.ID] = struct{}{}
+	return p
+}
+
+// Get returns a post of user; posts of other users are not found
+func (s *PostStore) Get(user, id ID) (Post, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	p, ok := s.posts[id]
+	if !ok || p.UserID != user {
+		return Post{}, false
+	}
+	return p, true
+}
+
+// List returns the posts of user ordered by ID
+func (s *PostStore) List(user ID) []Post {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := make([]Post, 0, len(s.byUser[user]))
+	for id := range s.byUser[user] {
+		posts = append(posts, s.posts[id])
+	}
+	slices.SortFunc(posts, func(a, b Post) int { return cmp.Compare(a.ID, b.ID) })
+	return posts
+}
+
+// Count returns how many posts user has
+func (s *PostStore) Count(user ID) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.byUser[user])
+}
+
+// Update replaces the title and body of a post of user
+func (s *PostStore) Update(user, id ID, title, body string) (Post, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok || p.UserID != user {
+		return Post{}, false
+	}
+	p.Title, p.Body = title, body
+	p.UpdatedAt = s.clock.Now()
+	s.posts[id] = p
+	return p, true
+}
+
+// Delete removes a post of user
+func (s *PostStore) Delete(user, id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.posts[id]
+	if !ok || p.UserID != user {
+		return false
+	}
+	delete(s.posts, id)
+	delete(s.byUser[user], id)
+	if len(s.byUser[user]) == 0 {
+		delete(s.byUser, user)
+	}
+	return true
+}
+
+// DeleteUser removes every post of a deleted user, returning how many
+func (s *PostStore) DeleteUser(user ID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.byUser[user])
+	for id := range s.byUser[user] {
+		delete(s.posts, id)
+	}
+	delete(s.byUser, user)
+	return n
+}
+
+// postRequest is the body of POST /users/{id}/posts and
+// PUT /users/{id}/posts/{post}
+type postRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+// validate reports what is wrong with the post, if anything
+func (req postRequest) validate() string {
+	switch {
+	case req.Title == "":
+		return "title is required"
+	case utf8.RuneCountInString(req.Title) > maxPostTitle:
+		return fmt.Sprintf("title must be at most %d characters", maxPostTitle)
+	case utf8.RuneCountInString(req.Body) > maxPostBody:
+		return fmt.Sprintf("body must be at most %d characters", maxPostBody)
+	}
+	return ""
+}
+
+// postsUser returns the user named by the {id} path value. Writes are
+// left to the user and admins.
+func (s *Server) postsUser(w http.ResponseWriter, r *http.Request, write bool) (User, bool) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return User{}, false
+	}
+	user, ok, err := s.users(r.Context()).Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return User{}, false
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return User{}, false
+	}
+	if write && !s.selfOrAdmin(r, user) {
+		httpError(w, r, "forbidden", http.StatusForbidden)
+		return User{}, false
+	}
+	return user, true
+}
+
+// postID parses the {post} path value
+func postID(w http.ResponseWriter, r *http.Request) (ID, bool) {
+	id, err := ParseID(r.PathValue("post"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// decodePost reads and validates a post body
+func decodePost(w http.ResponseWriter, r *http.Request) (postRequest, bool) {
+	var req postRequest
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if msg := req.validate(); msg != "" {
+		httpError(w, r, msg, http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// HandleListPosts handles GET /users/{id}/posts
+func (s *Server) HandleListPosts(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.postsUser(w, r, false)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, s.posts.List(user.ID))
+}
+
+// HandleCreatePost handles POST /users/{id}/posts
+func (s *Server) HandleCreatePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.postsUser(w, r, true)
+	if !ok {
+		return
+	}
+	req, ok := decodePost(w, r)
+	if !ok {
+		return
+	}
+
+	post := s.posts.Create(user.ID, req.Title, req.Body)
+	// Deleting a user drops their posts once the user is gone, so one
+	// deleted meanwhile may have missed this post
+	if _, ok, err := s.users(r.Context()).Get(r.Context(), user.ID); err == nil && !ok {
+		s.posts.Delete(user.ID, post.ID)
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "post", post.ID.String(), nil, post)
+	s.writeJSON(w, r, http.StatusCreated, post)
+}
+
+// HandleGetPost handles GET /users/{id}/posts/{post}
+func (s *Server) HandleGetPost(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.postsUser(w, r, false)
+	if !ok {
+		return
+	}
+	id, ok := postID(w, r)
+	if !ok {
+		return
+	}
+	post, ok := s.posts.Get(user.ID, id)
+	if !ok {
+		httpError(w, r, "post not found", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, post)
+}
+
+// HandleUpdatePost handles PUT /users/{id}/posts/{post}, replacing its
+// title and body
+func (s *Server) HandleUpdatePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.postsUser(w, r, true)
+	if !ok {
+		return
+	}
+	id, ok := postID(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodePost(w, r)
+	if !ok {
+		return
+	}
+
+	before, ok := s.posts.Get(user.ID, id)
+	if ok {
+		var after Post
+		if after, ok = s.posts.Update(user.ID, id, req.Title, req.Body); ok {
+			s.recordAudit(r, AuditUpdate, "post", id.String(), before, after)
+			s.writeJSON(w, r, http.StatusOK, after)
+			return
+		}
+	}
+	httpError(w, r, "post not found", http.StatusNotFound)
+}
+
+// HandleDeletePost handles DELETE /users/{id}/posts/{post}
+func (s *Server) HandleDeletePost(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.postsUser(w, r, true)
+	if !ok {
+		return
+	}
+	id, ok := postID(w, r)
+	if !ok {
+		return
+	}
+
+	post, ok := s.posts.Get(user.ID, id)
+	if !ok || !s.posts.Delete(user.ID, id) {
+		httpError(w, r, "post not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "post", id.String(), post, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
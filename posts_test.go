@@ -0,0 +1,156 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestPostStore(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewPostStore()
+	first := store.Create(1, "First", "")
+	store.Create(2, "Other", "")
+	store.Create(1, "Second", "")
+
+	if posts := store.List(1); len(posts) != 2 || posts[0].ID != first.ID || posts[1].Title != "Second" {
+		t.Errorf("expected the user's posts by ID, got %+v", posts)
+	}
+	if _, ok := store.Get(2, first.ID); ok {
+		t.Error("expected another user's post not found")
+	}
+	if _, ok := store.Update(2, first.ID, "Mine", ""); ok || store.Delete(2, first.ID) {
+		t.Error("expected another user's post left alone")
+	}
+	if n := store.DeleteUser(1); n != 2 || store.Count(1) != 0 || store.Count(2) != 1 {
+		t.Errorf("expected only the user's posts deleted, got %d", n)
+	}
+}
+
+func TestPostEndpoints(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	alice := addUser(t, server, User{Name: "Alice", Email: "alice@example.com"})
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com"})
+	routes := server.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	alicePosts := "/users/" + alice.ID.String() + "/posts"
+	bobPosts := "/users/" + bob.ID.String() + "/posts"
+
+	w := do(http.MethodPost, alicePosts, `{"title":"Hello","body":"First post"}`)
+	var post Post
+	json.NewDecoder(w.Body).Decode(&post)
+	if w.Code != http.StatusCreated || post.UserID != alice.ID || post.Title != "Hello" {
+		t.Fatalf("expected the post created, got %d: %+v", w.Code, post)
+	}
+	do(http.MethodPost, bobPosts, `{"title":"Bob's"}`)
+	for body, want := range map[string]string{
+		`{"body":"no title"}`:                                       `"field":"title","reason":"is required"`,
+		`{"title":"` + strings.Repeat("x", 201) + `"}`:              "title must be at most 200 characters",
+		`{"title":"t","body":"` + strings.Repeat("é", 10001) + `"}`: "body must be at most 10000 characters",
+	} {
+		if w := do(http.MethodPost, alicePosts, body); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %q, got %d: %s", want, w.Code, w.Body)
+		}
+	}
+
+	// Listings and lookups are scoped to the author
+	var posts []Post
+	json.NewDecoder(do(http.MethodGet, alicePosts, "").Body).Decode(&posts)
+	if len(posts) != 1 || posts[0].ID != post.ID {
+		t.Errorf("expected only alice's post, got %+v", posts)
+	}
+	postPath := alicePosts + "/" + post.ID.String()
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, postPath, "", http.StatusOK},
+		{http.MethodGet, bobPosts + "/" + post.ID.String(), "", http.StatusNotFound},
+		{http.MethodPut, bobPosts + "/" + post.ID.String(), `{"title":"Mine"}`, http.StatusNotFound},
+		{http.MethodDelete, bobPosts + "/" + post.ID.String(), "", http.StatusNotFound},
+		{http.MethodGet, "/users/9/posts", "", http.StatusNotFound},
+		{http.MethodPost, "/users/9/posts", `{"title":"Ghost"}`, http.StatusNotFound},
+		{http.MethodGet, alicePosts + "/x", "", http.StatusBadRequest},
+		{http.MethodPut, postPath, `{"title":"Hello again"}`, http.StatusOK},
+	} {
+		if w := do(tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, w.Code, w.Body)
+		}
+	}
+	if got, _ := server.posts.Get(alice.ID, post.ID); got.Title != "Hello again" || got.Body != "" {
+		t.Errorf("expected the title and body replaced, got %+v", got)
+	}
+
+	// By default deleting a user deletes their posts
+	if w := do(http.MethodDelete, "/users/"+alice.ID.String(), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if server.posts.Count(alice.ID) != 0 || server.posts.Count(bob.ID) != 1 {
+		t.Error("expected only the deleted user's posts gone")
+	}
+}
+
+func TestPostsBlockUserDelete(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithPostsOnUserDelete(PostsBlock))
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com"})
+	post := server.posts.Create(bob.ID, "Hello", "")
+	routes := server.Routes()
+	deleteBob := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users/"+bob.ID.String(), nil))
+		return w
+	}
+
+	if w := deleteBob(); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "user has posts") {
+		t.Errorf("expected the delete refused, got %d: %s", w.Code, w.Body)
+	}
+	if _, ok, _ := server.store.Get(context.Background(), bob.ID); !ok {
+		t.Fatal("expected bob kept")
+	}
+	server.posts.Delete(bob.ID, post.ID)
+	if w := deleteBob(); w.Code != http.StatusNoContent {
+		t.Errorf("expected the delete allowed once the posts are gone, got %d", w.Code)
+	}
+}
+
+func TestEmbeddedUserDeleteData(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	e, err := NewEmbedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	bob, _ := e.Users().Insert(ctx, User{Name: "Bob", Email: "bob@example.com"}, 0, 0)
+	e.server.posts.Create(bob.ID, "Hello", "")
+	_, token, err := e.server.startSession(ctx, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting through the store cleans up as the handler does
+	if ok, err := e.Users().Delete(ctx, bob.ID); !ok || err != nil {
+		t.Fatalf("expected bob deleted, got %v, %v", ok, err)
+	}
+	if n := e.server.posts.Count(bob.ID); n != 0 {
+		t.Errorf("expected bob's posts gone, got %d", n)
+	}
+	if _, ok, _ := e.server.sessions.Load(ctx, sessionID(token)); ok {
+		t.Error("expected bob's sessions ended")
+	}
+}
@@ -0,0 +1,137 @@
+package quickserve
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"time"
+)
+
+const (
+	// pprofPrefix is where the profiling endpoints are mounted
+	pprofPrefix = "/debug/pprof/"
+	// defaultProfileSeconds is how long CPU profiles and traces run
+	defaultProfileSeconds = 30
+	// maxProfileSeconds bounds ?seconds on CPU profiles and traces
+	maxProfileSeconds = 300
+)
+
+// DebugHandler serves runtime profiles under /debug/pprof/ in the format
+// of net/http/pprof, so `go tool pprof` works against it. Serve it on a
+// separate, private listener: profiles reveal internals and a CPU profile
+// costs throughput while it runs.
+//
+// net/http/pprof itself is not imported because it registers its handlers
+// on http.DefaultServeMux, which would expose them in any program that
+// embeds quickserve and serves the default mux.
+func DebugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+pprofPrefix, pprofIndex)
+	mux.HandleFunc("GET "+pprofPrefix+"profile", pprofCPU)
+	mux.HandleFunc("GET "+pprofPrefix+"trace", pprofTrace)
+	mux.HandleFunc("GET "+pprofPrefix+"{profile}", pprofNamed)
+	return mux
+}
+
+// pprofIndex lists the available profiles
+func pprofIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != pprofPrefix {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<html><head><title>/debug/pprof/</title></head><body>\n<p>Profiles:</p>\n<table>\n")
+	for _, p := range pprof.Profiles() {
+		name := html.EscapeString(p.Name())
+		fmt.Fprintf(w, "<tr><td>%d</td><td><a href=\"%s?debug=1\">%s</a></td></tr>\n", p.Count(), name, name)
+	}
+	fmt.Fprint(w, "<tr><td></td><td><a href=\"profile\">profile</a> (CPU, ?seconds=30)</td></tr>\n")
+	fmt.Fprint(w, "<tr><td></td><td><a href=\"trace\">trace</a> (execution trace, ?seconds=30)</td></tr>\n")
+	fmt.Fprint(w, "</table>\n</body></html>\n")
+}
+
+// pprofNamed writes a profile such as heap, goroutine or allocs. ?debug=1
+// gives a readable text form, and ?gc=1 runs a collection before a heap
+// profile.
+func pprofNamed(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("profile")
+	p := pprof.Lookup(name)
+	if p == nil {
+		http.Error(w, "unknown profile "+strconv.Quote(name), http.StatusNotFound)
+		return
+	}
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+	if name == "heap" && r.URL.Query().Get("gc") == "1" {
+		runtime.GC()
+	}
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	}
+	p.WriteTo(w, debug)
+}
+
+// profileDuration reads ?seconds for CPU profiles and traces
+func profileDuration(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	sec := defaultProfileSeconds
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxProfileSeconds {
+			http.Error(w, fmt.Sprintf("seconds must be between 1 and %d", maxProfileSeconds), http.StatusBadRequest)
+			return 0, false
+		}
+		sec = n
+	}
+	return time.Duration(sec) * time.Second, true
+}
+
+// pprofCPU records a CPU profile for ?seconds
+func pprofCPU(w http.ResponseWriter, r *http.Request) {
+	d, ok := profileDuration(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+	if err := pprof.StartCPUProfile(w); err != nil {
+		// Only one CPU profile can run per process
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "could not start CPU profile: "+err.Error(), http.StatusConflict)
+		return
+	}
+	sleepCtx(r, d)
+	pprof.StopCPUProfile()
+}
+
+// pprofTrace records an execution trace for ?seconds
+func pprofTrace(w http.ResponseWriter, r *http.Request) {
+	d, ok := profileDuration(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(w); err != nil {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "could not start trace: "+err.Error(), http.StatusConflict)
+		return
+	}
+	sleepCtx(r, d)
+	trace.Stop()
+}
+
+// sleepCtx waits for d, or until the client goes away
+func sleepCtx(r *http.Request, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-r.Context().Done():
+	}
+}
@@ -0,0 +1,56 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestDebugHandler(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	h := DebugHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/debug/pprof/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "heap") {
+		t.Errorf("expected an index listing heap, got %d: %s", w.Code, w.Body)
+	}
+	if w := get("/debug/pprof/goroutine?debug=1"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine profile") {
+		t.Errorf("expected a text goroutine profile, got %d", w.Code)
+	}
+	w := get("/debug/pprof/heap?gc=1")
+	if w.Code != http.StatusOK || w.Body.Len() == 0 || w.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("expected a binary heap profile, got %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if w := get("/debug/pprof/profile?seconds=1"); w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("expected a CPU profile, got %d", w.Code)
+	}
+	if w := get("/debug/pprof/profile?seconds=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for seconds=0, got %d", w.Code)
+	}
+	if w := get("/debug/pprof/nonsense"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown profile, got %d", w.Code)
+	}
+}
+
+func TestDebugHandlerNotOnMainRoutes(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	w := httptest.NewRecorder()
+	NewServer().Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected profiles to stay off the API listener, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected nothing registered on http.DefaultServeMux, got %d", w.Code)
+	}
+}
@@ -0,0 +1,299 @@
+// Package quicktest cuts the boilerplate of tests against quickserve: it
+// starts an embedded instance with a fresh store for a test, sends typed
+// requests to the user API and compares JSON bodies, failing the test on
+// anything unexpected, e.g.
+//
+//	srv := quicktest.New(t).WithAPIKey("admin-secret")
+//	alice := srv.CreateUser(t, quicktest.NewUser{Name: "Alice", Email: "alice@example.com"})
+//	srv.Do(t, http.MethodGet, "/users/"+alice.ID.String(), nil).Expect(t, http.StatusOK).JSON(t, `{"name": "Alice"}`)
+package quicktest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/golden"
+)
+
+// Server is an embedded instance serving one test. The embedded methods
+// give direct access to its stores.
+type Server struct {
+	*quickserve.Embedded
+	// Header is sent with every request, e.g. an API key
+	Header http.Header
+
+	ts *httptest.Server
+}
+
+// New starts an instance configured like quickserve.NewServer, with a
+// fresh memory store unless opts give one, and stops it when the test
+// ends. Checks deferred with guard.VerifyNone run after it stopped.
+func New(tb testing.TB, opts ...quickserve.Option) *Server {
+	tb.Helper()
+	e, err := quickserve.NewEmbedded(opts...)
+	if err != nil {
+		tb.Fatalf("quicktest: %v", err)
+	}
+	s := &Server{Embedded: e, Header: make(http.Header)}
+	tb.Cleanup(func() {
+		if s.ts != nil {
+			s.ts.Close()
+		}
+		e.Close()
+	})
+	return s
+}
+
+// WithAPIKey authenticates every request with key
+func (s *Server) WithAPIKey(key string) *Server {
+	s.Header.Set(quickserve.APIKeyHeader, key)
+	return s
+}
+
+// WithToken authenticates every request with a bearer token
+func (s *Server) WithToken(token string) *Server {
+	s.Header.Set("Authorization", "Bearer "+token)
+	return s
+}
+
+// URL starts listening on a loopback address, once, and returns the base
+// URL, for code under test that needs a real server such as the client or
+// the command line
+func (s *Server) URL() string {
+	if s.ts == nil {
+		s.ts = httptest.NewServer(s.Handler())
+	}
+	return s.ts.URL
+}
+
+// Response is a response read in full
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Do sends a request with s.Header to path, e.g. "/users?limit=10". body
+// is sent as is if it is a string or []byte and as JSON otherwise; nil
+// sends none.
+func (s *Server) Do(tb testing.TB, method, path string, body any) *Response {
+	tb.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = bytes.NewBufferString(b)
+	case []byte:
+		r = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			tb.Fatalf("quicktest: encode %s %s body: %v", method, path, err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), method, "http://quickserve"+path, r)
+	if err != nil {
+		tb.Fatalf("quicktest: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return s.DoRequest(tb, req)
+}
+
+// DoRequest sends req with s.Header added to its own headers
+func (s *Server) DoRequest(tb testing.TB, req *http.Request) *Response {
+	tb.Helper()
+	for k, v := range s.Header {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = v
+		}
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		tb.Fatalf("quicktest: %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		tb.Fatalf("quicktest: %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	return &Response{Status: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// Expect fails the test now unless the response has status
+func (r *Response) Expect(tb testing.TB, status int) *Response {
+	tb.Helper()
+	if r.Status != status {
+		tb.Fatalf("expected %d, got %d: %s", status, r.Status, bytes.TrimSpace(r.Body))
+	}
+	return r
+}
+
+// Decode decodes the JSON body into v
+func (r *Response) Decode(tb testing.TB, v any) {
+	tb.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		tb.Fatalf("quicktest: decode %s: %v", bytes.TrimSpace(r.Body), err)
+	}
+}
+
+// JSON checks the body with AssertJSON
+func (r *Response) JSON(tb testing.TB, want string) *Response {
+	tb.Helper()
+	AssertJSON(tb, r.Body, want)
+	return r
+}
+
+// AssertJSON checks that got holds the JSON in want, ignoring formatting
+// and key order. Objects in want may leave out members, such as
+// generated IDs and timestamps; arrays must match in length and order.
+func AssertJSON(tb testing.TB, got []byte, want string) {
+	tb.Helper()
+	var g, w any
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		tb.Fatalf("quicktest: invalid expected JSON: %v", err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		tb.Fatalf("quicktest: expected JSON, got %s", bytes.TrimSpace(got))
+	}
+	if !contains(g, w) {
+		tb.Errorf("JSON mismatch (-want +got):\n%s", golden.Diff(indent(w), indent(prune(g, w))))
+	}
+}
+
+// contains reports whether got holds want, with objects of got allowed
+// extra members
+func contains(got, want any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, wv := range w {
+			gv, ok := g[k]
+			if !ok || !contains(gv, wv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !contains(g[i], w[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(got, want)
+	}
+}
+
+// prune drops the members of got's objects that want leaves out, so a
+// diff shows only what was checked
+func prune(got, want any) any {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return got
+		}
+		out := make(map[string]any, len(w))
+		for k, wv := range w {
+			if gv, ok := g[k]; ok {
+				out[k] = prune(gv, wv)
+			}
+		}
+		return out
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return got
+		}
+		out := make([]any, len(g))
+		for i := range g {
+			out[i] = g[i]
+			if i < len(w) {
+				out[i] = prune(g[i], w[i])
+			}
+		}
+		return out
+	default:
+		return got
+	}
+}
+
+func indent(v any) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data) + "\n"
+}
+
+// NewUser is the body of POST /users. Tenant is sent in the tenant header.
+type NewUser struct {
+	Name     string          `json:"name"`
+	Email    string          `json:"email"`
+	Role     quickserve.Role `json:"role,omitempty"`
+	Locale   string          `json:"locale,omitempty"`
+	Password string          `json:"password,omitempty"`
+	Tenant   string          `json:"-"`
+}
+
+// CreateUser creates u through POST /users and returns it as created
+func (s *Server) CreateUser(tb testing.TB, u NewUser) quickserve.User {
+	tb.Helper()
+	data, err := json.Marshal(u)
+	if err != nil {
+		tb.Fatalf("quicktest: %v", err)
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://quickserve/users", bytes.NewReader(data))
+	if err != nil {
+		tb.Fatalf("quicktest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.Tenant != "" {
+		req.Header.Set(quickserve.TenantHeader, u.Tenant)
+	}
+	var user quickserve.User
+	s.DoRequest(tb, req).Expect(tb, http.StatusCreated).Decode(tb, &user)
+	return user
+}
+
+// GetUser fetches the user with id through GET /users/{id}
+func (s *Server) GetUser(tb testing.TB, id quickserve.ID) quickserve.User {
+	tb.Helper()
+	var user quickserve.User
+	s.Do(tb, http.MethodGet, userPath(id), nil).Expect(tb, http.StatusOK).Decode(tb, &user)
+	return user
+}
+
+// ListUsers lists the users through GET /users
+func (s *Server) ListUsers(tb testing.TB) []quickserve.User {
+	tb.Helper()
+	var users []quickserve.User
+	s.Do(tb, http.MethodGet, "/users", nil).Expect(tb, http.StatusOK).Decode(tb, &users)
+	return users
+}
+
+// DeleteUser deletes the user with id through DELETE /users/{id}
+func (s *Server) DeleteUser(tb testing.TB, id quickserve.ID) {
+	tb.Helper()
+	s.Do(tb, http.MethodDelete, userPath(id), nil).Expect(tb, http.StatusNoContent)
+}
+
+func userPath(id quickserve.ID) string {
+	return fmt.Sprintf("/users/%d", id)
+}
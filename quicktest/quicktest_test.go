@@ -0,0 +1,90 @@
+package quicktest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+)
+
+func TestServer(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := New(t, quickserve.WithAPIKeyAuth("admin-secret"))
+	srv.Do(t, http.MethodGet, "/users", nil).Expect(t, http.StatusUnauthorized)
+	srv.WithAPIKey("admin-secret")
+
+	alice := srv.CreateUser(t, NewUser{Name: "Alice", Email: "alice@example.com", Role: quickserve.RoleAdmin})
+	bob := srv.CreateUser(t, NewUser{Name: "Bob", Email: "bob@example.com", Tenant: "acme"})
+	if got := srv.GetUser(t, bob.ID); got.Name != "Bob" || got.Tenant != "acme" {
+		t.Errorf("expected Bob in acme, got %+v", got)
+	}
+	srv.Do(t, http.MethodGet, "/users", nil).Expect(t, http.StatusOK).JSON(t, `[
+		{"name": "Alice", "role": "admin"},
+		{"name": "Bob", "tenant": "acme"}
+	]`)
+	srv.Do(t, http.MethodPost, "/users", map[string]string{"name": "Carol", "email": "carol@example.com", "role": "root"}).
+		Expect(t, http.StatusBadRequest).JSON(t, `{"error": "invalid request body"}`)
+
+	srv.DeleteUser(t, alice.ID)
+	if users := srv.ListUsers(t); len(users) != 1 || users[0].ID != bob.ID {
+		t.Errorf("expected only Bob left, got %+v", users)
+	}
+	if _, ok, _ := srv.Users().Get(context.Background(), alice.ID); ok {
+		t.Error("expected Alice gone from the store")
+	}
+
+	resp, err := http.Get(srv.URL() + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the listener to serve, got %d", resp.StatusCode)
+	}
+}
+
+// recorder is a TB that records failures instead of failing
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertJSON(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	got := []byte(`{"id": 1, "name": "Alice", "tags": ["a", "b"], "org": {"id": 2, "name": "Acme"}}`)
+	for _, tt := range []struct {
+		want string
+		ok   bool
+	}{
+		{`{"tags": ["a", "b"], "name": "Alice", "id": 1, "org": {"name": "Acme", "id": 2}}`, true},
+		{`{"name": "Alice", "org": {"name": "Acme"}}`, true},
+		{`{"name": "Bob"}`, false},
+		{`{"name": "Alice", "email": "alice@example.com"}`, false},
+		{`{"tags": ["a"]}`, false},
+		{`{"tags": ["b", "a"]}`, false},
+		{`{"id": "1"}`, false},
+		{`{"org": {"name": "Globex"}}`, false},
+	} {
+		r := &recorder{TB: t}
+		AssertJSON(r, got, tt.want)
+		if ok := len(r.errors) == 0; ok != tt.ok {
+			t.Errorf("%s: expected ok %v, got %v", tt.want, tt.ok, r.errors)
+		}
+	}
+
+	r := &recorder{TB: t}
+	AssertJSON(r, got, `{"name": "Bob", "org": {"name": "Acme"}}`)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], `-   "name": "Bob"`) || strings.Contains(r.errors[0], `"id"`) {
+		t.Errorf("expected a diff of the checked members only, got %v", r.errors)
+	}
+}
@@ -0,0 +1,195 @@
+package quickserve
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quota caps the number of requests an API key may make per UTC day and
+// per UTC month. Zero means unlimited.
+type Quota struct {
+	Daily   int `json:"daily"`
+	Monthly int `json:"monthly"`
+}
+
+// WithQuota sets the default quota for API keys without their own
+func WithQuota(q Quota) Option {
+	return func(s *Server) {
+		s.quota = q
+	}
+}
+
+// Usage is the request count of one API key in the current periods
+type Usage struct {
+	KeyID        ID     `json:"key_id"`
+	Name         string `json:"name"`
+	Day          string `json:"day"`
+	DailyUsed    int    `json:"daily_used"`
+	DailyLimit   int    `json:"daily_limit"`
+	Month        string `json:"month"`
+	MonthlyUsed  int    `json:"monthly_used"`
+	MonthlyLimit int    `json:"monthly_limit"`
+	Total        int    `json:"total"`
+}
+
+type usageCounter struct {
+	day, month           string
+	dayCount, monthCount int
+	total                int
+}
+
+// UsageTracker counts requests per API key for quota enforcement
+type UsageTracker struct {
+	mu       sync.Mutex
+	clock    Clock
+	counters map[ID]*usageCounter
+}
+
+// NewUsageTracker creates an empty tracker
+func NewUsageTracker(clock Clock) *UsageTracker {
+	return &UsageTracker{clock: clock, counters: make(map[ID]*usageCounter)}
+}
+
+// counter returns key's counter rolled over to the current periods
+func (t *UsageTracker) counter(key ID) *usageCounter {
+	now := t.clock.Now()
+	day, month := now.Format("2006-01-02"), now.Format("2006-01")
+
+	c, ok := t.counters[key]
+	if !ok {
+		c = &usageCounter{day: day, month: month}
+		t.counters[key] = c
+	}
+	if c.day != day {
+		c.day, c.dayCount = day, 0
+	}
+	if c.month != month {
+		c.month, c.monthCount = month, 0
+	}
+	return c
+}
+
+// Consume records a request against q, refusing it if either period's
+// limit has been reached. The reason names the exhausted period.
+func (t *UsageTracker) Consume(key ID, q Quota) (ok bool, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.counter(key)
+	switch {
+	case q.Daily > 0 && c.dayCount >= q.Daily:
+		return false, "daily"
+	case q.Monthly > 0 && c.monthCount >= q.Monthly:
+		return false, "monthly"
+	}
+	c.dayCount++
+	c.monthCount++
+	c.total++
+	return true, ""
+}
+
+// Usage returns key's counts in the current periods
+func (t *UsageTracker) Usage(key ID) (day string, daily int, month string, monthly int, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.counter(key)
+	return c.day, c.dayCount, c.month, c.monthCount, c.total
+}
+
+// quotaFor returns the key's own quota, falling back to the default
+func (s *Server) quotaFor(key APIKey) Quota {
+	if key.Quota != nil {
+		return *key.Quota
+	}
+	return s.quota
+}
+
+// quotaReset returns when the UTC period, "daily" or "monthly", that
+// now falls in rolls over
+func quotaReset(now time.Time, period string) time.Time {
+	y, m, d := now.UTC().Date()
+	if period == "monthly" {
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+// enforceQuota counts requests made with API keys and rejects them once
+// the key's quota is used up. Other callers are not metered.
+func (s *Server) enforceQuota(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok || p.Method != "apikey" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := s.apiKeys.Get(p.KeyID)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		q := s.quotaFor(key)
+		if ok, period := s.usage.Consume(key.ID, q); !ok {
+			now := s.clock.Now()
+			setRetryAfter(w.Header(), quotaReset(now, period).Sub(now))
+			httpError(w, r, period+" quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		_, daily, _, monthly, _ := s.usage.Usage(key.ID)
+		if q.Daily > 0 {
+			w.Header().Set("X-Quota-Daily-Remaining", strconv.Itoa(q.Daily-daily))
+		}
+		if q.Monthly > 0 {
+			w.Header().Set("X-Quota-Monthly-Remaining", strconv.Itoa(q.Monthly-monthly))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleGetUsage handles GET /admin/usage. It reports every key, or only
+// the one given by ?key_id=.
+func (s *Server) HandleGetUsage(w http.ResponseWriter, r *http.Request) {
+	keys := s.apiKeys.List()
+
+	if v := r.URL.Query().Get("key_id"); v != "" {
+		id, err := ParseID(v)
+		if err != nil {
+			httpError(w, r, "invalid key_id", http.StatusBadRequest)
+			return
+		}
+		key, ok := s.apiKeys.Get(id)
+		if !ok {
+			httpError(w, r, "api key not found", http.StatusNotFound)
+			return
+		}
+		keys = []APIKey{key}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	usage := make([]Usage, 0, len(keys))
+	for _, k := range keys {
+		q := s.quotaFor(k)
+		day, daily, month, monthly, total := s.usage.Usage(k.ID)
+		usage = append(usage, Usage{
+			KeyID:        k.ID,
+			Name:         k.Name,
+			Day:          day,
+			DailyUsed:    daily,
+			DailyLimit:   q.Daily,
+			Month:        month,
+			MonthlyUsed:  monthly,
+			MonthlyLimit: q.Monthly,
+			Total:        total,
+		})
+	}
+
+	s.writeJSON(w, r, http.StatusOK, usage)
+}
@@ -0,0 +1,85 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUsageTrackerRollsOver(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC))
+	tracker := NewUsageTracker(clock)
+	q := Quota{Daily: 2, Monthly: 3}
+
+	tracker.Consume(1, q)
+	tracker.Consume(1, q)
+	if ok, period := tracker.Consume(1, q); ok || period != "daily" {
+		t.Fatalf("expected daily quota to be exhausted, got ok=%v period=%q", ok, period)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if ok, _ := tracker.Consume(1, q); !ok {
+		t.Error("expected new day and month to reset counters")
+	}
+	_, daily, month, monthly, total := tracker.Usage(1)
+	if daily != 1 || monthly != 1 || month != "2024-02" || total != 3 {
+		t.Errorf("unexpected usage: daily=%d monthly=%d month=%s total=%d", daily, monthly, month, total)
+	}
+}
+
+func TestQuotaEnforcedPerKey(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithQuota(Quota{Daily: 5}))
+	key := server.apiKeys.Import("limited", "limited-secret", RoleUser)
+	server.apiKeys.SetQuota(key.ID, &Quota{Daily: 1})
+	routes := server.Routes()
+
+	do := func(secret, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(APIKeyHeader, secret)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("limited-secret", "/users"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	w := do("limited-secret", "/users")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once quota is used, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After until the quota resets")
+	}
+
+	w = do("admin-secret", "/admin/usage?key_id=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var usage []Usage
+	json.NewDecoder(w.Body).Decode(&usage)
+	if len(usage) != 1 || usage[0].DailyUsed != 1 || usage[0].DailyLimit != 1 {
+		t.Errorf("unexpected usage report: %+v", usage)
+	}
+}
+
+func TestQuotaReset(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	now := time.Date(2024, 1, 31, 22, 30, 0, 0, time.UTC)
+	if got := quotaReset(now, "daily").Sub(now); got != 90*time.Minute {
+		t.Errorf("expected the daily quota to reset at midnight, got %v", got)
+	}
+	if got := quotaReset(now, "monthly"); !got.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the monthly quota to reset on the 1st, got %v", got)
+	}
+}
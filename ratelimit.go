@@ -0,0 +1,203 @@
+package quickserve
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket: Rate tokens are added per second
+// up to Burst, and each request takes one
+type RateLimit struct {
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+// rateDecision is the outcome of a single Allow call
+type rateDecision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token is available
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+type bucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// bucketIdleSweep is how often buckets that have refilled are dropped
+const bucketIdleSweep = time.Minute
+
+// RateLimiter keeps one token bucket per client
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     RateLimit
+	clock     Clock
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter applying limit to every client
+func NewRateLimiter(limit RateLimit, clock Clock) *RateLimiter {
+	return &RateLimiter{
+		limit:     limit,
+		clock:     clock,
+		buckets:   make(map[string]*bucket),
+		lastSweep: clock.Now(),
+	}
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *RateLimiter) Allow(key string) rateDecision {
+	return l.AllowLimit(key, l.limit)
+}
+
+// AllowLimit is Allow with a per-call limit, such as a tenant override. A
+// bucket whose limit changes keeps its tokens, capped at the new burst.
+func (l *RateLimiter) AllowLimit(key string, limit RateLimit) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.limit != limit {
+		b.limit = limit
+		b.tokens = math.Min(b.tokens, float64(limit.Burst))
+	}
+
+	d := rateDecision{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = durationFor(limit, 1-b.tokens)
+	}
+	d.Remaining = int(math.Floor(b.tokens))
+	d.Reset = durationFor(limit, float64(limit.Burst)-b.tokens)
+	return d
+}
+
+func (l *RateLimiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	}
+	b.last = now
+}
+
+// durationFor returns how long it takes to accumulate n tokens at limit
+func durationFor(limit RateLimit, n float64) time.Duration {
+	if n <= 0 || limit.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(n / limit.Rate * float64(time.Second)))
+}
+
+// sweep drops buckets that would be full by now; they are
+// indistinguishable from new ones, so this bounds memory for free
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleSweep {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// WithRateLimit limits each client to rate requests per second with the
+// given burst
+func WithRateLimit(rate float64, burst int) Option {
+	return func(s *Server) {
+		s.rateLimit = &RateLimit{Rate: rate, Burst: burst}
+	}
+}
+
+// rateLimitKey identifies the client: by API key when one the key store
+// accepts is presented, otherwise by IP address, so made-up keys don't
+// each get a bucket of their own
+func (s *Server) rateLimitKey(r *http.Request) string {
+	if k, ok := s.apiKeys.Authenticate(r.Header.Get(APIKeyHeader)); ok {
+		return "key:" + k.ID.String()
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// rateLimitTenant returns the tenant whose limit applies to r: the
+// tenant of the caller it authenticates as. A tenant merely named by the
+// request, in a header or subdomain, could be anyone's choice, so
+// anonymous callers and those of no tenant get the default limit.
+func (s *Server) rateLimitTenant(r *http.Request) string {
+	if p, ok := s.authenticate(r); ok {
+		return p.Tenant
+	}
+	return ""
+}
+
+// rateLimited applies the limiter to every request except health checks.
+// The limit is resolved per request so the caller's tenant's override
+// applies, but the bucket is the client's whatever tenant it names;
+// requests with no limit in effect pass straight through.
+func (s *Server) rateLimited(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isHealthPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := s.settingsFor(s.rateLimitTenant(r)).RateLimit
+		if limit == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d := s.limiter.AllowLimit(s.rateLimitKey(r), *limit)
+
+		s.setRateLimitHeaders(w.Header(), d)
+		if !d.Allowed {
+			setRetryAfter(w.Header(), d.RetryAfter)
+			httpError(w, r, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setRateLimitHeaders reports the state of the client's bucket, both in
+// the RateLimit-* fields of the IETF draft, where Reset is in seconds
+// from now, and in the older X-RateLimit-* form, where it is Unix time
+func (s *Server) setRateLimitHeaders(h http.Header, d rateDecision) {
+	limit, remaining := strconv.Itoa(d.Limit), strconv.Itoa(d.Remaining)
+	h.Set("RateLimit-Limit", limit)
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	h.Set("X-RateLimit-Limit", limit)
+	h.Set("X-RateLimit-Remaining", remaining)
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(s.clock.Now().Add(d.Reset).Unix(), 10))
+}
+
+// setRetryAfter tells a throttled client to wait d, in whole seconds and
+// never less than one, so a client honoring it does not retry too soon
+func setRetryAfter(h http.Header, d time.Duration) {
+	h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d), 1)))
+}
+
+// ceilSeconds rounds d up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
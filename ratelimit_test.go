@@ -0,0 +1,121 @@
+package quickserve
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRateLimiterTokenBucket(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 2}, clock)
+
+	if !limiter.Allow("a").Allowed || !limiter.Allow("a").Allowed {
+		t.Fatal("expected burst of 2 to be allowed")
+	}
+	d := limiter.Allow("a")
+	if d.Allowed {
+		t.Fatal("expected third request to be limited")
+	}
+	if d.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", d.RetryAfter)
+	}
+
+	if !limiter.Allow("b").Allowed {
+		t.Error("expected other clients to have their own bucket")
+	}
+
+	clock.Advance(time.Second)
+	if !limiter.Allow("a").Allowed {
+		t.Error("expected a token after refill")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithRateLimit(1, 1))
+	routes := server.Routes()
+
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/users"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	w := do("/users")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected rate limit headers: %v", w.Header())
+	}
+	if w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Error("expected X-RateLimit-Reset header")
+	}
+	h := w.Header()
+	if h.Get("RateLimit-Limit") != "1" || h.Get("RateLimit-Remaining") != "0" || h.Get("RateLimit-Reset") != "1" {
+		t.Errorf("expected draft RateLimit headers with reset in seconds, got %v", h)
+	}
+	if h.Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After: 1, got %q", h.Get("Retry-After"))
+	}
+
+	// Allowed requests report the bucket but carry no Retry-After
+	clock.Advance(time.Second)
+	w = do("/users")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("Retry-After") != "" {
+		t.Errorf("expected 200 with headers and no Retry-After, got %d: %v", w.Code, w.Header())
+	}
+
+	if w := do("/health"); w.Code != http.StatusOK {
+		t.Errorf("expected health checks to bypass the limiter, got %d", w.Code)
+	}
+}
+
+func TestRateLimitKeyedByClient(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithRateLimit(1, 1))
+	_, secret, _ := server.apiKeys.Create("ci", RoleUser)
+	routes := server.Routes()
+	do := func(header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Neither made-up tenants nor made-up keys get a bucket of their own
+	if code := do(TenantHeader, "t0"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	for i := range 5 {
+		if code := do(TenantHeader, fmt.Sprintf("t%d", i+1)); code != http.StatusTooManyRequests {
+			t.Errorf("expected another tenant to share the client's bucket, got %d", code)
+		}
+		if code := do(APIKeyHeader, fmt.Sprintf("bogus-%d", i)); code != http.StatusTooManyRequests {
+			t.Errorf("expected an unknown key to share the client's bucket, got %d", code)
+		}
+	}
+	if code := do(APIKeyHeader, secret); code != http.StatusOK {
+		t.Errorf("expected a valid key to get its own bucket, got %d", code)
+	}
+	if n := len(server.limiter.buckets); n != 2 {
+		t.Errorf("expected a bucket for the address and the key, got %d", n)
+	}
+}
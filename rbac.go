@@ -0,0 +1,163 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+)
+
+// Role determines what an authenticated caller may do
+type Role string
+
+const (
+	// RoleAdmin may perform every operation
+	RoleAdmin Role = "admin"
+	// RoleUser may only read users
+	RoleUser Role = "user"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether the role grants p
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission is a single grantable capability
+type Permission string
+
+const (
+	PermUsersRead   Permission = "users:read"
+	PermUsersWrite  Permission = "users:write"
+	PermUsersDelete Permission = "users:delete"
+	PermOrgsRead    Permission = "orgs:read"
+	PermOrgsWrite   Permission = "orgs:write"
+	PermAdmin       Permission = "admin"
+)
+
+// rolePermissions lists what each role is granted
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {PermUsersRead, PermUsersWrite, PermUsersDelete, PermOrgsRead, PermOrgsWrite, PermAdmin},
+	RoleUser:  {PermUsersRead, PermOrgsRead},
+}
+
+// routePermissions maps route patterns, exactly as registered, to the
+// permission they require; routes in a tenant's namespace require what
+// the user route they serve does. Routes not listed only need
+// authentication.
+var routePermissions = map[string]Permission{
+	"GET /users":                                     PermUsersRead,
+	"GET /users/{id}/notifications":                  PermUsersRead,
+	"GET /users/changes":                             PermUsersRead,
+	"GET /users/{id}/activity":                       PermUsersRead,
+	"GET /users/{id}/avatar":                         PermUsersRead,
+	"GET /users/{id}":                                PermUsersRead,
+	"GET /users/search":                              PermUsersRead,
+	"GET /users/{id}/posts":                          PermUsersRead,
+	"GET /users/{id}/posts/{post}":                   PermUsersRead,
+	"POST /users":                                    PermUsersWrite,
+	"DELETE /users/{id}":                             PermUsersDelete,
+	"GET /ws":                                        PermUsersRead,
+	"GET /users/events":                              PermUsersRead,
+	"POST /invitations":                              PermUsersWrite,
+	"GET /orgs":                                      PermOrgsRead,
+	"POST /orgs":                                     PermOrgsWrite,
+	"GET /orgs/{org}":                                PermOrgsRead,
+	"DELETE /orgs/{org}":                             PermOrgsWrite,
+	"GET /orgs/{org}/users":                          PermOrgsRead,
+	"GET /orgs/{org}/teams":                          PermOrgsRead,
+	"POST /orgs/{org}/teams":                         PermOrgsWrite,
+	"DELETE /orgs/{org}/teams/{team}":                PermOrgsWrite,
+	"GET /orgs/{org}/members":                        PermOrgsRead,
+	"PUT /orgs/{org}/members/{user}":                 PermOrgsWrite,
+	"DELETE /orgs/{org}/members/{user}":              PermOrgsWrite,
+	"GET /orgs/{org}/teams/{team}/members":           PermOrgsRead,
+	"PUT /orgs/{org}/teams/{team}/members/{user}":    PermOrgsWrite,
+	"DELETE /orgs/{org}/teams/{team}/members/{user}": PermOrgsWrite,
+	"GET /teams":                                     PermOrgsRead,
+	"POST /teams":                                    PermOrgsWrite,
+	"GET /teams/{team}":                              PermOrgsRead,
+	"DELETE /teams/{team}":                           PermOrgsWrite,
+	"GET /teams/{team}/members":                      PermOrgsRead,
+	"POST /teams/{team}/members":                     PermOrgsWrite,
+	"GET /admin/keys":                                PermAdmin,
+	"POST /admin/keys":                               PermAdmin,
+	"DELETE /admin/keys/{id}":                        PermAdmin,
+	"GET /admin/audit":                               PermAdmin,
+	"GET /admin/usage":                               PermAdmin,
+	"GET /admin/invitations":                         PermAdmin,
+	"DELETE /admin/invitations/{id}":                 PermAdmin,
+	"GET /admin/routes":                              PermAdmin,
+	"GET /admin/deprecations":                        PermAdmin,
+	"GET /admin/schema":                              PermAdmin,
+	"GET /admin/cache":                               PermAdmin,
+	"GET /admin/replication":                         PermAdmin,
+	"POST /admin/promote":                            PermAdmin,
+	"POST /admin/reload":                             PermAdmin,
+	"GET /admin/clock":                               PermAdmin,
+	"POST /admin/clock":                              PermAdmin,
+	"GET /admin/tenants":                             PermAdmin,
+	"POST /admin/tenants":                            PermAdmin,
+	"GET /admin/tenants/{tenant}":                    PermAdmin,
+	"POST /admin/tenants/{tenant}/suspend":           PermAdmin,
+	"POST /admin/tenants/{tenant}/resume":            PermAdmin,
+	"DELETE /admin/tenants/{tenant}":                 PermAdmin,
+	"GET /admin/tenants/{tenant}/settings":           PermAdmin,
+	"PUT /admin/tenants/{tenant}/settings":           PermAdmin,
+	"DELETE /admin/tenants/{tenant}/settings":        PermAdmin,
+	"GET /admin/webhooks":                            PermAdmin,
+	"POST /admin/webhooks":                           PermAdmin,
+	"DELETE /admin/webhooks/{id}":                    PermAdmin,
+}
+
+// authorize enforces routePermissions against the caller's role. On org
+// routes a role held in that org (or inherited within it) counts too. It
+// must run after authentication; unauthenticated requests pass through so
+// it is a no-op when authentication is disabled.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, authenticated := PrincipalFromContext(r.Context())
+		rt, matched := RouteFromContext(r.Context())
+		if !authenticated || !matched {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		perm, ok := routePermissions[unscopedPattern(rt.Pattern())]
+		if ok && !p.Role.Can(perm) && !s.orgGrants(r, p, perm) {
+			httpError(w, r, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// orgGrants reports whether the caller's role in the org named by the
+// request path, or owning the team it names, grants perm
+func (s *Server) orgGrants(r *http.Request, p Principal, perm Permission) bool {
+	if r.PathValue("org") == "" && r.PathValue("team") == "" {
+		return false
+	}
+	role, ok := s.orgRole(r, p)
+	return ok && role.Can(perm)
+}
+
+// roleForEmail resolves the role of a person identified by email in
+// tenant, falling back to RoleUser for people without a user record or
+// when the lookup fails
+func (s *Server) roleForEmail(ctx context.Context, tenant, email string) Role {
+	if email != "" {
+		if u, ok, err := s.usersOf(tenant).FindByEmail(ctx, email); ok && err == nil && u.Role.Valid() {
+			return u.Role
+		}
+	}
+	return RoleUser
+}
@@ -0,0 +1,83 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRBACEnforcesRoutePermissions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"))
+	server.apiKeys.Import("reader", "reader-secret", RoleUser)
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+	routes := server.Routes()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		key      string
+		expected int
+	}{
+		{"user can list", http.MethodGet, "/users", "reader-secret", http.StatusOK},
+		{"user can get", http.MethodGet, "/users/1", "reader-secret", http.StatusOK},
+		{"user cannot delete", http.MethodDelete, "/users/1", "reader-secret", http.StatusForbidden},
+		{"user cannot manage keys", http.MethodGet, "/admin/keys", "reader-secret", http.StatusForbidden},
+		{"admin can delete", http.MethodDelete, "/users/1", "admin-secret", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set(APIKeyHeader, tt.key)
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestCreateUserWithRole(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+
+	body := bytes.NewBufferString(`{"name":"Root","email":"root@test.com","role":"admin"}`)
+	req := httptest.NewRequest(http.MethodPost, "/users", body)
+	w := httptest.NewRecorder()
+	server.HandleCreateUser(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+	if u, _, _ := server.store.Get(context.Background(), 1); u.Role != RoleAdmin {
+		t.Errorf("expected admin role, got %q", u.Role)
+	}
+
+	body = bytes.NewBufferString(`{"name":"X","email":"x@test.com","role":"superuser"}`)
+	req = httptest.NewRequest(http.MethodPost, "/users", body)
+	w = httptest.NewRecorder()
+	server.HandleCreateUser(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown role, got %d", w.Code)
+	}
+}
+
+func TestDefaultRole(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewMemoryUserStore()
+	if u, _ := store.Insert(context.Background(), User{Name: "Bob", Email: "bob@test.com"}, 0, 0); u.Role != RoleUser {
+		t.Errorf("expected default role %q, got %q", RoleUser, u.Role)
+	}
+}
@@ -0,0 +1,77 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// handlerPanic carries a panic recovered on another goroutine, such as
+// the one serveWithin runs handlers on, together with its original stack
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+func (p handlerPanic) String() string {
+	return fmt.Sprint(p.value)
+}
+
+// problem is an RFC 9457 problem details body
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// recoverPanics turns a panic in a handler into a logged stack trace and
+// a 500 problem response, instead of a dropped connection. If the handler
+// had already started its response, the connection is aborted since the
+// status can no longer change.
+func (s *Server) recoverPanics(next http.Handler) http.Handler {
+	logger := s.componentLogger("http")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			value, stack := p, debug.Stack()
+			if hp, ok := p.(handlerPanic); ok {
+				value, stack = hp.value, hp.stack
+			}
+			logger.ErrorContext(r.Context(), "panic serving request",
+				"method", r.Method, "path", r.URL.Path, "panic", fmt.Sprint(value), "stack", string(stack))
+
+			if sw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			b, _ := json.Marshal(problem{
+				Type:      "about:blank",
+				Title:     http.StatusText(http.StatusInternalServerError),
+				Status:    http.StatusInternalServerError,
+				Detail:    "the server hit an unexpected error",
+				RequestID: RequestIDFromContext(r.Context()),
+			})
+			h := w.Header()
+			for k := range h {
+				if k != RequestIDHeader && k != APIVersionHeader {
+					h.Del(k)
+				}
+			}
+			h.Set("Content-Type", "application/problem+json")
+			h.Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(append(b, '\n'))
+		}()
+		next.ServeHTTP(sw, r)
+	})
+}
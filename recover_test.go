@@ -0,0 +1,104 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// panicModule serves a route that always panics
+type panicModule struct{}
+
+func (panicModule) Name() string { return "panics" }
+
+func (panicModule) RegisterRoutes(g *RouteGroup) {
+	g.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		explode()
+	})
+	g.HandleFunc("GET /boom-late", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		explode()
+	})
+}
+
+func explode() { panic("kaboom") }
+
+func TestRecoverPanics(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, tt := range []struct {
+		name     string
+		deadline string
+	}{
+		{"inline", ""},
+		{"within a deadline", "5s"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger, _ := NewLogger(&logs, "json", slog.LevelError)
+			handler := NewServer(WithLogger(logger), WithModule(panicModule{})).Routes()
+
+			req := httptest.NewRequest(http.MethodGet, "/boom", nil)
+			req.Header.Set(RequestIDHeader, "trace-9")
+			if tt.deadline != "" {
+				req.Header.Set(RequestDeadlineHeader, tt.deadline)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("expected 500, got %d", w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("expected a problem response, got %q", ct)
+			}
+			var p problem
+			json.NewDecoder(w.Body).Decode(&p)
+			if p.Status != http.StatusInternalServerError || p.Title != "Internal Server Error" || p.RequestID != "trace-9" {
+				t.Errorf("unexpected problem: %+v", p)
+			}
+
+			var entry struct {
+				Msg       string
+				Panic     string
+				Stack     string
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("expected one log entry, got %q", logs.String())
+			}
+			if entry.Panic != "kaboom" || entry.RequestID != "trace-9" {
+				t.Errorf("unexpected log entry: %+v", entry)
+			}
+			// The stack must point at the handler, not at where the
+			// panic was re-raised
+			if !strings.Contains(entry.Stack, ".explode(") {
+				t.Errorf("expected the original stack, got:\n%s", entry.Stack)
+			}
+		})
+	}
+}
+
+func TestRecoverPanicAfterResponseStarted(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var logs bytes.Buffer
+	logger, _ := NewLogger(&logs, "text", slog.LevelError)
+	handler := NewServer(WithLogger(logger), WithModule(panicModule{})).Routes()
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("expected the connection to be aborted, got %v", p)
+		}
+		if !strings.Contains(logs.String(), "panic serving request") {
+			t.Errorf("expected the panic to be logged, got %q", logs.String())
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom-late", nil))
+}
@@ -0,0 +1,85 @@
+package quickserve
+
+import (
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+)
+
+var errNoReloadSource = errors.New("no reload source configured")
+
+// LiveSettings are the settings that can change while the server runs,
+// without a restart or dropping connections
+type LiveSettings struct {
+	LogLevel slog.Level `json:"log_level"`
+	// RateLimit is the server-wide limit per client; nil means none
+	RateLimit *RateLimit `json:"rate_limit,omitempty"`
+	// IPAccess restricts route groups by client address
+	IPAccess map[string]IPAccess `json:"ip_access,omitempty"`
+}
+
+// WithLogLevel lets reloads change the level of the server's logger;
+// level must be the Leveler the logger was built with
+func WithLogLevel(level *slog.LevelVar) Option {
+	return func(s *Server) {
+		s.logLevel = level
+	}
+}
+
+// WithReloadSource makes Reload, and POST /admin/reload, apply the
+// settings load returns, e.g. from a config file read again
+func WithReloadSource(load func() (LiveSettings, error)) Option {
+	return func(s *Server) {
+		s.reloadSource = load
+	}
+}
+
+// LiveSettings returns the settings currently in effect
+func (s *Server) LiveSettings() LiveSettings {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	ls := LiveSettings{RateLimit: s.rateLimit, IPAccess: maps.Clone(s.ipAccess)}
+	if s.logLevel != nil {
+		ls.LogLevel = s.logLevel.Level()
+	}
+	return ls
+}
+
+// Reload reads settings from the reload source and applies them to
+// requests from then on. If the source fails, the current settings stay.
+func (s *Server) Reload() (LiveSettings, error) {
+	if s.reloadSource == nil {
+		return LiveSettings{}, errNoReloadSource
+	}
+	ls, err := s.reloadSource()
+	if err != nil {
+		return LiveSettings{}, err
+	}
+
+	s.liveMu.Lock()
+	if s.logLevel != nil {
+		s.logLevel.Set(ls.LogLevel)
+	}
+	s.rateLimit = ls.RateLimit
+	s.ipAccess = maps.Clone(ls.IPAccess)
+	s.liveMu.Unlock()
+
+	s.componentLogger("config").Info("settings reloaded", "log_level", ls.LogLevel,
+		"rate_limit", ls.RateLimit != nil, "ip_access_groups", len(ls.IPAccess))
+	return s.LiveSettings(), nil
+}
+
+// HandleReload handles POST /admin/reload, applying the live settings of
+// the reload source and returning them
+func (s *Server) HandleReload(w http.ResponseWriter, r *http.Request) {
+	before := s.LiveSettings()
+	ls, err := s.Reload()
+	if err != nil {
+		s.componentLogger("config").ErrorContext(r.Context(), "reload failed; keeping the current settings", "err", err)
+		httpError(w, r, "reload failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(r, AuditUpdate, "settings", "", before, ls)
+	s.writeJSON(w, r, http.StatusOK, ls)
+}
@@ -0,0 +1,126 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestReload(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var logs bytes.Buffer
+	var level slog.LevelVar
+	level.Set(slog.LevelWarn)
+	logger, _ := NewLogger(&logs, "text", &level)
+
+	next := LiveSettings{LogLevel: slog.LevelDebug}
+	var sourceErr error
+	server := NewServer(
+		WithAPIKeyAuth("admin-secret"),
+		WithLogger(logger),
+		WithLogLevel(&level),
+		WithReloadSource(func() (LiveSettings, error) { return next, sourceErr }),
+	)
+	routes := server.Routes()
+	do := func(method, path, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remote
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodGet, "/users", "203.0.113.5:5000"); w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Fatalf("expected no rate limit before the reload, got %q", w.Header().Get("X-RateLimit-Limit"))
+	}
+
+	next.RateLimit = &RateLimit{Rate: 1, Burst: 5}
+	next.IPAccess = map[string]IPAccess{"users": {Deny: []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}}}
+	w := do(http.MethodPost, "/admin/reload", "10.0.0.1:5000")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got LiveSettings
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.LogLevel != slog.LevelDebug || got.RateLimit == nil || got.RateLimit.Burst != 5 || len(got.IPAccess["users"].Deny) != 1 {
+		t.Errorf("expected the new settings in the response, got %+v", got)
+	}
+
+	if level.Level() != slog.LevelDebug || !strings.Contains(logs.String(), "settings reloaded") {
+		t.Errorf("expected the log level to drop to debug, got %s", level.Level())
+	}
+	if w := do(http.MethodGet, "/users", "203.0.113.5:5000"); w.Code != http.StatusForbidden {
+		t.Errorf("expected the new denylist to apply at once, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users", "198.51.100.7:5000"); w.Header().Get("X-RateLimit-Limit") != "5" {
+		t.Errorf("expected the new rate limit to apply, got %q", w.Header().Get("X-RateLimit-Limit"))
+	}
+	if entries := server.audit.Query(AuditFilter{}); len(entries) != 1 || entries[0].Resource != "settings" {
+		t.Errorf("expected the reload to be audited, got %+v", entries)
+	}
+
+	// A failing source leaves everything in place
+	sourceErr = errors.New("config.yaml: line 3: did not find expected key")
+	if w := do(http.MethodPost, "/admin/reload", "10.0.0.1:5000"); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "line 3") {
+		t.Errorf("expected the source's error, got %d: %s", w.Code, w.Body)
+	}
+	if ls := server.LiveSettings(); ls.RateLimit == nil || level.Level() != slog.LevelDebug {
+		t.Errorf("expected the previous settings to stay, got %+v", ls)
+	}
+}
+
+func TestReloadWithoutSource(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("admin-secret"))
+	if _, err := server.Reload(); !errors.Is(err, errNoReloadSource) {
+		t.Errorf("expected errNoReloadSource, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.Header.Set(APIKeyHeader, "admin-secret")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected no reload route without a source, got %d", w.Code)
+	}
+}
+
+func TestConfigLiveSettings(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := writeConfigFile(t, `
+rate_limit:
+  rate: 2.5
+ip_access:
+  admin:
+    allow: [10.8.0.0/16]
+    deny: [10.8.0.66]
+`)
+	cfg, err := LoadConfig(nil, []string{"QUICKSERVE_CONFIG=" + path, "QUICKSERVE_IP_DENY_ADMIN=", "QUICKSERVE_LOG_LEVEL=error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ls, err := cfg.LiveSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ls.LogLevel != slog.LevelError {
+		t.Errorf("expected the level from the environment, got %s", ls.LogLevel)
+	}
+	if ls.RateLimit == nil || *ls.RateLimit != (RateLimit{Rate: 2.5, Burst: 3}) {
+		t.Errorf("expected a burst of one second's worth, got %+v", ls.RateLimit)
+	}
+	if admin := ls.IPAccess["admin"]; len(admin.Allow) != 1 || len(admin.Deny) != 0 {
+		t.Errorf("expected the variable to clear the file's denylist, got %+v", admin)
+	}
+}
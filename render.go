@@ -0,0 +1,349 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ID is a 64-bit resource identifier. It always decodes precisely, from
+// either a JSON number or a decimal string, so clients that must quote
+// large IDs (JavaScript loses precision above 2^53) can round-trip them.
+type ID int64
+
+// ParseID parses a decimal ID, e.g. from a path segment
+func ParseID(s string) (ID, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return ID(n), err
+}
+
+// String returns the decimal form of the ID
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// UnmarshalJSON accepts 123 as well as "123"
+func (id *ID) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return errors.New("id must be a 64-bit integer")
+	}
+	*id = ID(n)
+	return nil
+}
+
+// IDFormat controls how identifiers are rendered in JSON output
+type IDFormat string
+
+const (
+	// IDFormatNumber renders IDs as JSON numbers (the default)
+	IDFormatNumber IDFormat = "number"
+	// IDFormatString renders IDs as JSON strings
+	IDFormatString IDFormat = "string"
+)
+
+// WithIDFormat sets the default ID rendering for all JSON output.
+// Clients can still override it per request with ?id_format=.
+func WithIDFormat(f IDFormat) Option {
+	return func(s *Server) {
+		s.idFormat = f
+	}
+}
+
+// renderOptions controls how values are encoded in JSON output
+type renderOptions struct {
+	IDs       IDFormat
+	Precision time.Duration
+}
+
+// renderOptionsFor resolves the output options for a request
+func (s *Server) renderOptionsFor(r *http.Request) renderOptions {
+	return renderOptions{
+		IDs:       s.idFormatFor(r),
+		Precision: s.timePrecision,
+	}
+}
+
+// idFormatFor resolves the ID format for a request. Versions that fix
+// the format win, and unknown values fall back to the server default.
+func (s *Server) idFormatFor(r *http.Request) IDFormat {
+	if r != nil {
+		if f := codecFor(r.Context()).idFormat(); f != "" {
+			return f
+		}
+		switch f := IDFormat(r.URL.Query().Get("id_format")); f {
+		case IDFormatNumber, IDFormatString:
+			return f
+		}
+	}
+	if s.idFormat == "" {
+		return IDFormatNumber
+	}
+	return s.idFormat
+}
+
+// writeJSON encodes v as the response body using the request's render options
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	b := getJSONBuffer()
+	defer putJSONBuffer(b)
+	data, err := b.encode(v, s.renderOptionsFor(r))
+	if err != nil {
+		httpError(w, r, "could not encode response", http.StatusInternalServerError)
+		return
+	}
+	s.writeEncoded(w, r, status, data)
+}
+
+// writeEncoded writes JSON that encodeJSON produced, as plain text if the
+// request asks for it
+func (s *Server) writeEncoded(w http.ResponseWriter, r *http.Request, status int, data []byte) {
+	if r != nil && r.Method == http.MethodGet {
+		w.Header().Add("Vary", "Accept")
+		if wantsPlainText(r) {
+			text, err := renderPlainText(data)
+			if err != nil {
+				httpError(w, r, "could not encode response", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write(text)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// acceptQuality parses the request's Accept header and returns how much
+// the client wants a media type, from 0 (not acceptable) to 1, honoring
+// type/* and */* ranges. A missing header accepts everything.
+func acceptQuality(r *http.Request) func(mediaType string) float64 {
+	accept := r.Header.Get("Accept")
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					weight = f
+				}
+			}
+		}
+		if mediaType = strings.ToLower(strings.TrimSpace(mediaType)); mediaType != "" {
+			q[mediaType] = weight
+		}
+	}
+	return func(mediaType string) float64 {
+		if accept == "" {
+			return 1
+		}
+		major, _, _ := strings.Cut(mediaType, "/")
+		for _, k := range []string{mediaType, major + "/*", "*/*"} {
+			if w, ok := q[k]; ok {
+				return w
+			}
+		}
+		return 0
+	}
+}
+
+// decodeJSON decodes a request body, preserving number precision for
+// fields decoded into interface values
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// encodeJSON marshals v and applies the render options. Every JSON
+// payload leaving the server should go through here so ID and timestamp
+// formatting are applied consistently. The result is the caller's to
+// keep; writeJSON encodes into a pooled buffer instead.
+func encodeJSON(v any, opts renderOptions) ([]byte, error) {
+	b := getJSONBuffer()
+	defer putJSONBuffer(b)
+	data, err := b.encode(v, opts)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(data), nil
+}
+
+// jsonBufferSize is what pooled buffers start with, enough for most
+// responses, and maxPooledJSONBuffer the largest kept for reuse, so one
+// huge response doesn't pin its memory in the pool
+const (
+	jsonBufferSize      = 4 << 10
+	maxPooledJSONBuffer = 256 << 10
+)
+
+// jsonBuffer is a reusable encoder with the buffers it writes to: raw for
+// the encoding and out for the render options' rewrite of it
+type jsonBuffer struct {
+	raw, out bytes.Buffer
+	enc      *json.Encoder
+}
+
+var jsonBuffers = sync.Pool{
+	New: func() any {
+		b := &jsonBuffer{}
+		b.raw.Grow(jsonBufferSize)
+		b.enc = json.NewEncoder(&b.raw)
+		return b
+	},
+}
+
+func getJSONBuffer() *jsonBuffer {
+	return jsonBuffers.Get().(*jsonBuffer)
+}
+
+func putJSONBuffer(b *jsonBuffer) {
+	if b.raw.Cap() > maxPooledJSONBuffer || b.out.Cap() > maxPooledJSONBuffer {
+		return
+	}
+	jsonBuffers.Put(b)
+}
+
+// encode marshals v with the render options applied. The result is only
+// valid until b goes back to the pool.
+func (b *jsonBuffer) encode(v any, opts renderOptions) ([]byte, error) {
+	b.raw.Reset()
+	if err := b.enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if opts.IDs != IDFormatString && opts.Precision <= 0 {
+		return b.raw.Bytes(), nil
+	}
+	b.out.Reset()
+	if err := rewriteJSONTo(&b.out, b.raw.Bytes(), opts); err != nil {
+		return nil, err
+	}
+	b.out.WriteByte('\n')
+	return b.out.Bytes(), nil
+}
+
+// isIDKey reports whether a JSON object key holds an identifier
+func isIDKey(k string) bool {
+	return k == "id" || strings.HasSuffix(k, "_id") || strings.HasSuffix(k, "_ids")
+}
+
+// rewriteJSON re-emits a JSON document with numeric values under ID keys
+// quoted (for IDFormatString) and timestamps under "_at" keys reformatted
+// to the configured precision. It walks the token stream so field order
+// is preserved.
+func rewriteJSON(data []byte, opts renderOptions) ([]byte, error) {
+	var out bytes.Buffer
+	if err := rewriteJSONTo(&out, data, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// rewriteJSONTo is rewriteJSON appending to out
+func rewriteJSONTo(out *bytes.Buffer, data []byte, opts renderOptions) error {
+	type frame struct {
+		object    bool
+		expectKey bool
+		key       string
+		n         int
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var stack []*frame
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		closing := tok == json.Delim('}') || tok == json.Delim(']')
+		if top != nil && !closing {
+			switch {
+			case top.object && !top.expectKey:
+				out.WriteByte(':')
+			case top.n > 0:
+				out.WriteByte(',')
+			}
+		}
+
+		switch v := tok.(type) {
+		case json.Delim:
+			out.WriteByte(byte(v))
+			if !closing {
+				f := &frame{object: v == '{', expectKey: v == '{'}
+				if v == '[' && top != nil {
+					f.key = top.key
+				}
+				stack = append(stack, f)
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case string:
+			if top != nil && top.object && top.expectKey {
+				b, _ := json.Marshal(v)
+				out.Write(b)
+				top.key = v
+				top.expectKey = false
+				continue
+			}
+			if top != nil && opts.Precision > 0 && isTimeKey(top.key) {
+				if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+					v = formatTimestamp(t, opts.Precision)
+				}
+			}
+			b, _ := json.Marshal(v)
+			out.Write(b)
+		case json.Number:
+			if top != nil && opts.IDs == IDFormatString && isIDKey(top.key) {
+				out.WriteByte('"')
+				out.WriteString(v.String())
+				out.WriteByte('"')
+			} else {
+				out.WriteString(v.String())
+			}
+		case bool:
+			out.WriteString(strconv.FormatBool(v))
+		case nil:
+			out.WriteString("null")
+		}
+
+		// A complete value was written into the enclosing container
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parent.n++
+			if parent.object {
+				parent.expectKey = true
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRewriteJSONStringIDs(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	in := `{"id":9007199254740993,"name":"a","count":5,"owner_id":2,"member_ids":[3,4],"nested":{"id":7}}`
+	out, err := rewriteJSON([]byte(in), renderOptions{IDs: IDFormatString})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"id":"9007199254740993","name":"a","count":5,"owner_id":"2","member_ids":["3","4"],"nested":{"id":"7"}}`
+	if string(out) != want {
+		t.Errorf("expected %s, got %s", want, out)
+	}
+}
+
+func TestIDFormatQueryParam(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1?id_format=string", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	server.HandleGetUser(w, req)
+
+	if !strings.Contains(w.Body.String(), `"id":"1"`) {
+		t.Errorf("expected quoted id, got %s", w.Body.String())
+	}
+}
+
+func TestIDFormatServerDefault(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithIDFormat(IDFormatString))
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/users?id_format=number", nil)
+	w := httptest.NewRecorder()
+
+	server.HandleListUsers(w, req)
+
+	if !strings.Contains(w.Body.String(), `"id":1`) {
+		t.Errorf("expected numeric id override, got %s", w.Body.String())
+	}
+}
+
+func TestIDUnmarshalPrecision(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var v struct {
+		A ID `json:"a"`
+		B ID `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":9007199254740993,"b":"9223372036854775807"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.A != 9007199254740993 {
+		t.Errorf("expected 9007199254740993, got %d", v.A)
+	}
+	if v.B != 9223372036854775807 {
+		t.Errorf("expected max int64, got %d", v.B)
+	}
+}
+
+func TestEncodeJSONOwnsResult(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	// The pooled buffer is reused once encodeJSON returns, which must not
+	// change what it returned, e.g. a cached response
+	first, err := encodeJSON(map[string]int{"id": 1}, renderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodeJSON(map[string]int{"id": 2}, renderOptions{})
+	encodeJSON(map[string]int{"id": 3}, renderOptions{IDs: IDFormatString})
+	if string(first) != "{\"id\":1}\n" {
+		t.Errorf("expected the first encoding kept, got %q", first)
+	}
+}
+
+// BenchmarkWriteJSONUsers renders a page of users as GET /users does,
+// comparing the pooled buffers with a fresh encoder per response
+func BenchmarkWriteJSONUsers(b *testing.B) {
+	server := NewServer()
+	for i := 0; i < 100; i++ {
+		server.store.Insert(context.Background(), User{Name: "User", Email: "user@test.com"}, 0, 0)
+	}
+	users, _ := server.store.List(context.Background())
+
+	for _, format := range []IDFormat{IDFormatNumber, IDFormatString} {
+		req := httptest.NewRequest(http.MethodGet, "/users?id_format="+string(format), nil)
+		b.Run(string(format)+"/fresh", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				opts := server.renderOptionsFor(req)
+				var buf bytes.Buffer
+				json.NewEncoder(&buf).Encode(users)
+				data := buf.Bytes()
+				if opts.IDs == IDFormatString {
+					data, _ = rewriteJSON(data, opts)
+				}
+				server.writeEncoded(discardWriter{}, req, http.StatusOK, data)
+			}
+		})
+		b.Run(string(format)+"/pooled", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				server.writeJSON(discardWriter{}, req, http.StatusOK, users)
+			}
+		})
+	}
+}
+
+// discardWriter is a ResponseWriter dropping what it is sent, so
+// benchmarks measure the encoding alone
+type discardWriter struct{}
+
+func (discardWriter) Header() http.Header         { return http.Header{} }
+func (discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (discardWriter) WriteHeader(int)             {}
+
+// FuzzParseID checks that any ID that parses, from a path or from JSON,
+// round-trips through its decimal form, and that nothing panics
+func FuzzParseID(f *testing.F) {
+	defer guard.VerifyNone(f)
+
+	for _, seed := range []string{"1", "0", "-1", "+7", "007", "9223372036854775807", "9223372036854775808",
+		"-9223372036854775808", "1e3", "0x10", " 1", `"1"`, `""`, "null", "١٢", "\xff"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if id, err := ParseID(s); err == nil {
+			if back, err := ParseID(id.String()); err != nil || back != id {
+				t.Fatalf("ParseID(%q) = %d does not round-trip: %d, %v", s, id, back, err)
+			}
+		}
+
+		var id ID
+		if err := id.UnmarshalJSON([]byte(s)); err != nil {
+			return
+		}
+		for _, format := range []IDFormat{IDFormatNumber, IDFormatString} {
+			data, err := encodeJSON(struct {
+				ID ID `json:"id"`
+			}{id}, renderOptions{IDs: format})
+			if err != nil {
+				t.Fatalf("encode %d: %v", id, err)
+			}
+			var back struct {
+				ID ID `json:"id"`
+			}
+			if err := json.Unmarshal(data, &back); err != nil || back.ID != id {
+				t.Fatalf("ID %d from %q rendered as %s does not decode back: %d, %v", id, s, data, back.ID, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,475 @@
+package quickserve
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// snapshotPath is where a standby receives snapshots from its primary
+	snapshotPath = "/replication/snapshot"
+	// defaultSnapshotInterval is how often a primary ships by default
+	defaultSnapshotInterval = time.Minute
+	// maxSnapshotBody bounds the snapshot a standby will buffer
+	maxSnapshotBody = 64 << 20
+)
+
+// Snapshot is the state a primary ships to its standby. Users are only
+// included while they are kept in memory; other user stores replicate
+// themselves. Sessions and the audit log stay with each instance.
+type Snapshot struct {
+	TakenAt        time.Time                  `json:"taken_at"`
+	Users          []snapshotUser             `json:"users,omitempty"`
+	APIKeys        []snapshotAPIKey           `json:"api_keys"`
+	Orgs           []Org                      `json:"orgs"`
+	Teams          []Team                     `json:"teams"`
+	Memberships    []Membership               `json:"memberships"`
+	Notifications  map[ID]NotificationPrefs   `json:"notifications"`
+	TenantSettings map[string]TenantOverrides `json:"tenant_settings"`
+}
+
+// snapshotUser carries the password hash that User keeps out of JSON
+type snapshotUser struct {
+	User
+	PasswordHash []byte `json:"password_hash,omitempty"`
+}
+
+// snapshotAPIKey carries the secret hash that APIKey keeps out of JSON
+type snapshotAPIKey struct {
+	APIKey
+	Hash []byte `json:"hash"`
+}
+
+// replication is an instance's part in snapshot shipping: a primary ships
+// to standbyURL, a standby applies what it is sent until it is promoted
+type replication struct {
+	standbyURL string
+	secret     string
+	interval   time.Duration
+	verifier   *requestSigner
+
+	mu         sync.Mutex
+	standby    bool
+	lastAt     time.Time
+	lastErr    string
+	promotedAt *time.Time
+}
+
+// WithSnapshotShipping makes the server a primary that ships a snapshot
+// to the standby at standbyURL every interval, signed with secret
+func WithSnapshotShipping(standbyURL, secret string, interval time.Duration) Option {
+	return func(s *Server) {
+		if interval <= 0 {
+			interval = defaultSnapshotInterval
+		}
+		rep := s.replicationState()
+		rep.standbyURL = strings.TrimSuffix(standbyURL, "/")
+		rep.secret = secret
+		rep.interval = interval
+	}
+}
+
+// WithStandby makes the server a cold standby. It accepts snapshots signed
+// with one of secrets and refuses client writes until promoted through
+// POST /admin/promote.
+func WithStandby(secrets ...string) Option {
+	return func(s *Server) {
+		rep := s.replicationState()
+		rep.standby = true
+		rep.verifier = newRequestSigner(secrets, maxSnapshotBody)
+	}
+}
+
+func (s *Server) replicationState() *replication {
+	if s.replication == nil {
+		s.replication = &replication{}
+	}
+	return s.replication
+}
+
+// isStandby reports whether the server waits to take over from a primary
+func (s *Server) isStandby() bool {
+	if s.replication == nil {
+		return false
+	}
+	s.replication.mu.Lock()
+	defer s.replication.mu.Unlock()
+	return s.replication.standby
+}
+
+// TakeSnapshot captures the replicated state of the server
+func (s *Server) TakeSnapshot() Snapshot {
+	snap := Snapshot{
+		TakenAt:        s.clock.Now(),
+		APIKeys:        s.apiKeys.snapshot(),
+		Notifications:  s.notifications.snapshot(),
+		TenantSettings: s.tenantSettings.snapshot(),
+	}
+	if st, ok := s.memoryUserStore(); ok {
+		snap.Users = st.snapshot()
+	}
+	snap.Orgs, snap.Teams, snap.Memberships = s.orgs.snapshot()
+	return snap
+}
+
+// restoreSnapshot replaces the replicated state with snap
+func (s *Server) restoreSnapshot(snap Snapshot) error {
+	// Tenant settings go first: they are the only part that can fail
+	if err := s.tenantSettings.restore(snap.TenantSettings); err != nil {
+		return err
+	}
+	if st, ok := s.memoryUserStore(); ok {
+		st.restore(snap.Users)
+		// No events are published for a snapshot
+		if s.cache != nil {
+			s.cache.purge()
+		}
+	}
+	s.apiKeys.restore(snap.APIKeys)
+	s.orgs.restore(snap.Orgs, snap.Teams, snap.Memberships)
+	s.notifications.restore(snap.Notifications)
+	return nil
+}
+
+// ShipSnapshot sends a snapshot to the standby
+func (s *Server) ShipSnapshot(ctx context.Context) error {
+	rep := s.replication
+	snap := s.TakeSnapshot()
+	body, err := json.Marshal(snap)
+	if err == nil {
+		err = s.postSnapshot(ctx, rep, body)
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if err != nil {
+		rep.lastErr = err.Error()
+		return err
+	}
+	rep.lastAt, rep.lastErr = snap.TakenAt, ""
+	return nil
+}
+
+func (s *Server) postSnapshot(ctx context.Context, rep *replication, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, rep.interval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rep.standbyURL+snapshotPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, SignRequest(rep.secret, s.clock.Now(), http.MethodPost, snapshotPath, body))
+	propagateTrace(ctx, req)
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e errorBody
+		json.NewDecoder(resp.Body).Decode(&e)
+		if e.Error != "" {
+			return fmt.Errorf("standby: %s: %s", resp.Status, e.Error)
+		}
+		return fmt.Errorf("standby: %s", resp.Status)
+	}
+	return nil
+}
+
+// RunSnapshotShipping ships a snapshot every interval until ctx is done.
+// A standby does not ship until it is promoted.
+func (s *Server) RunSnapshotShipping(ctx context.Context) {
+	logger := s.componentLogger("replication")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.replication.interval):
+			if s.isStandby() {
+				continue
+			}
+			if err := s.ShipSnapshot(ctx); err != nil {
+				logger.ErrorContext(ctx, "could not ship snapshot", "standby", s.replication.standbyURL, "err", err)
+			}
+		}
+	}
+}
+
+// refuseOnStandby answers 503 to client writes while the server is a
+// standby, since the next snapshot would silently undo them
+func (s *Server) refuseOnStandby(next http.Handler) http.Handler {
+	if s.replication == nil || !s.replication.standby {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		exempt := path == snapshotPath || path == "/admin/promote"
+		if !exempt && methodIdempotency(r.Method) != Safe && s.isStandby() {
+			httpError(w, r, "this instance is a standby; promote it with POST /admin/promote before writing", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleApplySnapshot handles POST /replication/snapshot on a standby.
+// Snapshots older than the one last applied are rejected, so a delayed
+// delivery cannot roll the standby back.
+func (s *Server) HandleApplySnapshot(w http.ResponseWriter, r *http.Request) {
+	var snap Snapshot
+	if err := decodeJSON(r, &snap); err != nil || snap.TakenAt.IsZero() {
+		httpError(w, r, "invalid snapshot", http.StatusBadRequest)
+		return
+	}
+
+	rep := s.replication
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	if !rep.standby {
+		httpError(w, r, "this instance has been promoted and no longer accepts snapshots", http.StatusConflict)
+		return
+	}
+	if snap.TakenAt.Before(rep.lastAt) {
+		httpError(w, r, "snapshot is older than the one already applied", http.StatusConflict)
+		return
+	}
+	if err := s.restoreSnapshot(snap); err != nil {
+		rep.lastErr = err.Error()
+		httpError(w, r, "could not apply snapshot", http.StatusInternalServerError)
+		return
+	}
+	rep.lastAt, rep.lastErr = snap.TakenAt, ""
+	s.componentLogger("replication").DebugContext(r.Context(), "snapshot applied", "taken_at", snap.TakenAt)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// replicationStatus is the GET /admin/replication view
+type replicationStatus struct {
+	Role           string     `json:"role"`
+	StandbyURL     string     `json:"standby_url,omitempty"`
+	Interval       Duration   `json:"interval,omitempty"`
+	LastSnapshotAt *time.Time `json:"last_snapshot_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	PromotedAt     *time.Time `json:"promoted_at,omitempty"`
+}
+
+func (rep *replication) status() replicationStatus {
+	st := replicationStatus{
+		Role:       "primary",
+		StandbyURL: rep.standbyURL,
+		Interval:   Duration(rep.interval),
+		LastError:  rep.lastErr,
+		PromotedAt: rep.promotedAt,
+	}
+	if rep.standby {
+		st.Role = "standby"
+	}
+	if !rep.lastAt.IsZero() {
+		at := rep.lastAt
+		st.LastSnapshotAt = &at
+	}
+	return st
+}
+
+// HandleGetReplication handles GET /admin/replication
+func (s *Server) HandleGetReplication(w http.ResponseWriter, r *http.Request) {
+	s.replication.mu.Lock()
+	st := s.replication.status()
+	s.replication.mu.Unlock()
+
+	s.writeJSON(w, r, http.StatusOK, st)
+}
+
+// HandlePromote handles POST /admin/promote, turning a standby into a
+// primary that accepts writes and refuses further snapshots
+func (s *Server) HandlePromote(w http.ResponseWriter, r *http.Request) {
+	rep := s.replication
+	rep.mu.Lock()
+	if !rep.standby {
+		rep.mu.Unlock()
+		httpError(w, r, "this instance is already a primary", http.StatusConflict)
+		return
+	}
+	now := s.clock.Now()
+	rep.standby = false
+	rep.promotedAt = &now
+	st := rep.status()
+	rep.mu.Unlock()
+
+	s.componentLogger("replication").WarnContext(r.Context(), "standby promoted to primary", "last_snapshot_at", st.LastSnapshotAt)
+	s.recordAudit(r, AuditUpdate, "replication", "", nil, st)
+	s.writeJSON(w, r, http.StatusOK, st)
+}
+
+func (s *MemoryUserStore) snapshot() []snapshotUser {
+	users := make([]snapshotUser, 0, s.total.Load())
+	s.each(func(u User) bool {
+		users = append(users, snapshotUser{u, u.passwordHash})
+		return true
+	})
+	slices.SortFunc(users, func(a, b snapshotUser) int { return cmp.Compare(a.ID, b.ID) })
+	return users
+}
+
+func (s *MemoryUserStore) restore(users []snapshotUser) {
+	for i := range s.shards {
+		s.shards[i].mu.Lock()
+		defer s.shards[i].mu.Unlock()
+		s.shards[i].users = make(map[ID]User)
+	}
+	s.tenantMu.Lock()
+	defer s.tenantMu.Unlock()
+
+	clear(s.tenants)
+	clear(s.byTenant)
+	clear(s.emails)
+	var next ID
+	restored := make([]User, 0, len(users))
+	for _, su := range users {
+		u := su.User
+		u.passwordHash = su.PasswordHash
+		s.shard(u.ID).users[u.ID] = u
+		restored = append(restored, u)
+		s.addToTenant(u)
+		if u.Tenant != "" {
+			s.tenants[u.Tenant]++
+		}
+		next = max(next, u.ID)
+	}
+	s.next.Store(int64(next))
+	s.total.Store(int64(len(users)))
+	s.index.reset(restored)
+}
+
+func (s *APIKeyStore) snapshot() []snapshotAPIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]snapshotAPIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, snapshotAPIKey{k, k.hash[:]})
+	}
+	slices.SortFunc(keys, func(a, b snapshotAPIKey) int { return cmp.Compare(a.ID, b.ID) })
+	return keys
+}
+
+func (s *APIKeyStore) restore(keys []snapshotAPIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = make(map[ID]APIKey, len(keys))
+	s.next = 1
+	for _, sk := range keys {
+		k := sk.APIKey
+		copy(k.hash[:], sk.Hash)
+		s.keys[k.ID] = k
+		s.next = max(s.next, k.ID+1)
+	}
+}
+
+func (s *OrgStore) snapshot() ([]Org, []Team, []Membership) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := make([]Org, 0, len(s.orgs))
+	for _, o := range s.orgs {
+		orgs = append(orgs, o)
+	}
+	slices.SortFunc(orgs, func(a, b Org) int { return cmp.Compare(a.ID, b.ID) })
+	teams := make([]Team, 0, len(s.teams))
+	for _, t := range s.teams {
+		teams = append(teams, t)
+	}
+	slices.SortFunc(teams, func(a, b Team) int { return cmp.Compare(a.ID, b.ID) })
+	members := make([]Membership, 0, len(s.members))
+	for _, m := range s.members {
+		members = append(members, m)
+	}
+	slices.SortFunc(members, func(a, b Membership) int {
+		return cmp.Or(cmp.Compare(a.OrgID, b.OrgID), cmp.Compare(a.TeamID, b.TeamID), cmp.Compare(a.UserID, b.UserID))
+	})
+	return orgs, teams, members
+}
+
+func (s *OrgStore) restore(orgs []Org, teams []Team, members []Membership) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgs = make(map[ID]Org, len(orgs))
+	s.nextOrg = 1
+	for _, o := range orgs {
+		s.orgs[o.ID] = o
+		s.nextOrg = max(s.nextOrg, o.ID+1)
+	}
+	s.teams = make(map[ID]Team, len(teams))
+	s.nextTeam = 1
+	for _, t := range teams {
+		s.teams[t.ID] = t
+		s.nextTeam = max(s.nextTeam, t.ID+1)
+	}
+	s.members = make(map[memberKey]Membership, len(members))
+	for _, m := range members {
+		s.members[memberKey{m.OrgID, m.TeamID, m.UserID}] = m
+	}
+}
+
+func (s *NotificationStore) snapshot() map[ID]NotificationPrefs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefs := make(map[ID]NotificationPrefs, len(s.prefs))
+	for id, p := range s.prefs {
+		prefs[id] = p
+	}
+	return prefs
+}
+
+func (s *NotificationStore) restore(prefs map[ID]NotificationPrefs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prefs = make(map[ID]NotificationPrefs, len(prefs))
+	for id, p := range prefs {
+		s.prefs[id] = p
+	}
+}
+
+func (s *TenantSettingsStore) snapshot() map[string]TenantOverrides {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	overrides := make(map[string]TenantOverrides, len(s.overrides))
+	for t, o := range s.overrides {
+		overrides[t] = o
+	}
+	return overrides
+}
+
+// restore replaces the overrides, keeping the previous ones if they
+// cannot be persisted
+func (s *TenantSettingsStore) restore(overrides map[string]TenantOverrides) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := s.overrides
+	s.overrides = make(map[string]TenantOverrides, len(overrides))
+	for t, o := range overrides {
+		s.overrides[t] = o
+	}
+	if err := s.saveLocked(); err != nil {
+		s.overrides = prev
+		return err
+	}
+	return nil
+}
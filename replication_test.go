@@ -0,0 +1,114 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSnapshotShipping(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	standby := NewServer(WithAPIKeyAuth("standby-admin"), WithStandby("s3cret"))
+	ts := httptest.NewServer(standby.Routes())
+	defer ts.Close()
+
+	primary := NewServer(WithAPIKeyAuth("primary-admin"), WithSnapshotShipping(ts.URL, "s3cret", time.Minute))
+	primary.webhookClient = ts.Client()
+	alice := addUser(t, primary, User{Name: "Alice", Email: "alice@example.com", passwordHash: []byte("hash")})
+	org := primary.orgs.CreateOrg("Acme")
+	primary.orgs.SetMember(org.ID, 0, alice.ID, RoleAdmin)
+	primary.notifications.Set(alice.ID, NotificationPrefs{WeeklyDigest: true})
+
+	ctx := context.Background()
+	if err := primary.ShipSnapshot(ctx); err != nil {
+		t.Fatalf("ship: %v", err)
+	}
+
+	u, ok, _ := standby.store.Get(ctx, alice.ID)
+	if !ok || u.Email != alice.Email || string(u.passwordHash) != "hash" {
+		t.Errorf("expected Alice with her password hash on the standby, got %+v", u)
+	}
+	if role, ok := standby.orgs.RoleIn(org.ID, 0, alice.ID); !ok || role != RoleAdmin {
+		t.Errorf("expected Alice's org membership on the standby, got %q", role)
+	}
+	if !standby.notifications.Get(alice.ID).WeeklyDigest {
+		t.Error("expected notification preferences on the standby")
+	}
+	// The primary's keys replace the standby's own
+	if _, ok := standby.apiKeys.Authenticate("primary-admin"); !ok {
+		t.Error("expected the primary's API keys on the standby")
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, "primary-admin")
+		w := httptest.NewRecorder()
+		ts.Config.Handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := do(http.MethodPost, "/users", `{"name":"Bob","email":"bob@example.com"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for writes on a standby, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/users", ""); w.Code != http.StatusOK {
+		t.Errorf("expected reads on a standby, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "/admin/promote", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from promote, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/users", `{"name":"Bob","email":"bob@example.com"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected writes after promotion, got %d: %s", w.Code, w.Body)
+	}
+	if err := primary.ShipSnapshot(ctx); err == nil || !strings.Contains(err.Error(), "409") {
+		t.Errorf("expected a promoted standby to refuse snapshots, got %v", err)
+	}
+
+	w := do(http.MethodGet, "/admin/replication", "")
+	var st replicationStatus
+	json.NewDecoder(w.Body).Decode(&st)
+	if st.Role != "primary" || st.PromotedAt == nil || st.LastSnapshotAt == nil {
+		t.Errorf("unexpected status after promotion: %+v", st)
+	}
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/replication", nil)
+	req.Header.Set(APIKeyHeader, "primary-admin")
+	primary.Routes().ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&st)
+	if !strings.Contains(st.LastError, "promoted") {
+		t.Errorf("expected the primary to report the refused snapshot, got %+v", st)
+	}
+}
+
+func TestApplySnapshotRejected(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	standby := NewServer(WithStandby("s3cret"))
+	handler := standby.Routes()
+	now := time.Now()
+
+	send := func(secret string, snap Snapshot) int {
+		body, _ := json.Marshal(snap)
+		req := httptest.NewRequest(http.MethodPost, snapshotPath, strings.NewReader(string(body)))
+		req.Header.Set(SignatureHeader, SignRequest(secret, now, http.MethodPost, snapshotPath, body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send("wrong", Snapshot{TakenAt: now}); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a bad signature, got %d", code)
+	}
+	if code := send("s3cret", Snapshot{TakenAt: now}); code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", code)
+	}
+	if code := send("s3cret", Snapshot{TakenAt: now.Add(-time.Second)}); code != http.StatusConflict {
+		t.Errorf("expected 409 for an older snapshot, got %d", code)
+	}
+}
@@ -0,0 +1,81 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// RequestIDHeader carries the request ID. A caller-supplied value is kept
+// so IDs can be correlated across services; otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds caller-supplied IDs
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request being served
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts printable ASCII without spaces
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestID assigns every request an ID, echoes it in the response
+// and makes it available through RequestIDFromContext
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			token, err := randomToken()
+			if err != nil {
+				httpError(w, r, "could not assign request id", http.StatusInternalServerError)
+				return
+			}
+			id = token[:22]
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// errorBody is the payload of every error response. The request ID lets
+// a client quote the failed request when reporting it.
+type errorBody struct {
+	Error     string        `json:"error"`
+	RequestID string        `json:"request_id,omitempty"`
+	Quota     *QuotaError   `json:"quota,omitempty"`
+	Drift     []SchemaDrift `json:"drift,omitempty"`
+	Invalid   []FieldError  `json:"invalid,omitempty"`
+}
+
+// httpError replies with status and a JSON error carrying the request ID.
+// It replaces http.Error so every failure can be correlated with the logs.
+func httpError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	writeError(w, r, status, errorBody{Error: msg})
+}
+
+// writeError sends body, filling in the request ID
+func writeError(w http.ResponseWriter, r *http.Request, status int, body errorBody) {
+	body.RequestID = RequestIDFromContext(r.Context())
+	b, _ := json.Marshal(body)
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
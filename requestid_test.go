@@ -0,0 +1,97 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRequestID(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var seen string
+	handler := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"generated", "", false},
+		{"caller supplied", "abc-123", true},
+		{"invalid replaced", "has space", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("expected response header to match context, got %q and %q", got, seen)
+			}
+			if (got == tt.header) != tt.keep {
+				t.Errorf("unexpected request id %q", got)
+			}
+		})
+	}
+}
+
+func TestErrorCarriesRequestID(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/999", nil)
+	req.Header.Set(RequestIDHeader, "trace-42")
+	w := httptest.NewRecorder()
+	NewServer().Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON error, got %q", ct)
+	}
+	var body errorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error == "" || body.RequestID != "trace-42" {
+		t.Errorf("expected the error to carry the request id, got %+v", body)
+	}
+}
+
+func TestLogsCarryRequestID(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var buf bytes.Buffer
+	logger, _ := NewLogger(&buf, "text", slog.LevelInfo)
+	server := NewServer(WithLogger(logger))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "trace-42")
+	server.componentLogger("test").InfoContext(ctx, "handled")
+	server.componentLogger("test").Info("background")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two lines, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "component=test request_id=trace-42") {
+		t.Errorf("expected the request id on the request's line, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "request_id") {
+		t.Errorf("expected no request id without a request, got %q", lines[1])
+	}
+}
@@ -0,0 +1,81 @@
+package resource
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// MemoryStore keeps entities in memory; they are lost on restart
+type MemoryStore[T any] struct {
+	setID func(v *T, id int64)
+
+	mu     sync.RWMutex
+	items  map[int64]T
+	lastID int64
+}
+
+// NewMemoryStore creates an empty store. setID stores an ID in an entity,
+// so the store can assign IDs without knowing the type.
+func NewMemoryStore[T any](setID func(v *T, id int64)) *MemoryStore[T] {
+	return &MemoryStore[T]{setID: setID, items: make(map[int64]T)}
+}
+
+// List returns every entity, ordered by ID
+func (s *MemoryStore[T]) List(ctx context.Context) ([]T, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]int64, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, cmp.Compare[int64])
+	items := make([]T, len(ids))
+	for i, id := range ids {
+		items[i] = s.items[id]
+	}
+	return items, nil
+}
+
+// Get returns the entity with id
+func (s *MemoryStore[T]) Get(ctx context.Context, id int64) (T, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.items[id]
+	return v, ok, nil
+}
+
+// Create adds v under the next ID
+func (s *MemoryStore[T]) Create(ctx context.Context, v T) (T, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	s.setID(&v, s.lastID)
+	s.items[s.lastID] = v
+	return v, nil
+}
+
+// Update replaces the entity with id, keeping its ID
+func (s *MemoryStore[T]) Update(ctx context.Context, id int64, v T) (T, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		var zero T
+		return zero, false, nil
+	}
+	s.setID(&v, id)
+	s.items[id] = v
+	return v, true, nil
+}
+
+// Delete removes the entity with id
+func (s *MemoryStore[T]) Delete(ctx context.Context, id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.items[id]; !ok {
+		return false, nil
+	}
+	delete(s.items, id)
+	return true, nil
+}
@@ -0,0 +1,297 @@
+// Package resource serves create, list, get, update and delete routes for
+// any entity type kept in a Store, so a new resource doesn't need its own
+// five handlers:
+//
+//	store := resource.NewMemoryStore(func(w *Widget, id int64) { w.ID = id })
+//	resource.Register(mux, "widgets", store,
+//		resource.WithValidate(func(ctx context.Context, w *Widget) error {
+//			if w.Name == "" {
+//				return errors.New("name is required")
+//			}
+//			return nil
+//		}))
+//
+// registers GET and POST /widgets, and GET, PUT and DELETE /widgets/{id}.
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// Store keeps the entities of one resource by ID
+type Store[T any] interface {
+	// List returns every entity, ordered by ID
+	List(ctx context.Context) ([]T, error)
+	Get(ctx context.Context, id int64) (T, bool, error)
+	// Create adds v, assigning its ID
+	Create(ctx context.Context, v T) (T, error)
+	// Update replaces the entity with id, reporting whether it existed
+	Update(ctx context.Context, id int64, v T) (T, bool, error)
+	// Delete removes the entity with id, reporting whether it existed
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
+// Action is a route Register can add
+type Action string
+
+const (
+	List   Action = "list"
+	Get    Action = "get"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Mux is where Register adds routes, such as an http.ServeMux. Muxes that
+// also implement ErrorWriter answer errors their own way.
+type Mux interface {
+	Handle(pattern string, h http.Handler)
+}
+
+// ErrorWriter writes error responses, so a resource's errors look like
+// those of the rest of the API
+type ErrorWriter interface {
+	WriteError(w http.ResponseWriter, r *http.Request, status int, msg string)
+}
+
+// Error is a failure with the status it is answered with. Hooks and stores
+// return one to choose the status; other errors from validation are 400,
+// from WithBeforeDelete 409 and from the store 500.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errorf returns an *Error answered with status
+func Errorf(status int, format string, args ...any) *Error {
+	return &Error{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// Option configures a resource
+type Option[T any] func(*handlers[T])
+
+// WithValidate checks, and may normalize, the body of every create and
+// update before it reaches the store
+func WithValidate[T any](fn func(ctx context.Context, v *T) error) Option[T] {
+	return func(h *handlers[T]) {
+		h.validate = append(h.validate, fn)
+	}
+}
+
+// WithBeforeDelete runs fn before an entity is deleted; an error keeps it,
+// e.g. while other entities still reference it
+func WithBeforeDelete[T any](fn func(ctx context.Context, v T) error) Option[T] {
+	return func(h *handlers[T]) {
+		h.beforeDelete = append(h.beforeDelete, fn)
+	}
+}
+
+// WithActions registers only the routes of actions instead of all five
+func WithActions[T any](actions ...Action) Option[T] {
+	return func(h *handlers[T]) {
+		h.actions = actions
+	}
+}
+
+// handlers serves the routes of one resource
+type handlers[T any] struct {
+	store        Store[T]
+	errors       ErrorWriter
+	validate     []func(ctx context.Context, v *T) error
+	beforeDelete []func(ctx context.Context, v T) error
+	actions      []Action
+}
+
+// Register adds the routes of the resource name to mux: GET /name lists,
+// POST /name creates, and GET, PUT and DELETE /name/{id} get, replace and
+// delete one entity
+func Register[T any](mux Mux, name string, store Store[T], opts ...Option[T]) {
+	h := &handlers[T]{
+		store:   store,
+		errors:  jsonErrors{},
+		actions: []Action{List, Get, Create, Update, Delete},
+	}
+	if ew, ok := mux.(ErrorWriter); ok {
+		h.errors = ew
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	routes := []struct {
+		action  Action
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{List, "GET /" + name, h.list},
+		{Create, "POST /" + name, h.create},
+		{Get, "GET /" + name + "/{id}", h.get},
+		{Update, "PUT /" + name + "/{id}", h.update},
+		{Delete, "DELETE /" + name + "/{id}", h.delete},
+	}
+	for _, rt := range routes {
+		if slices.Contains(h.actions, rt.action) {
+			mux.Handle(rt.pattern, rt.handler)
+		}
+	}
+}
+
+func (h *handlers[T]) list(w http.ResponseWriter, r *http.Request) {
+	items, err := h.store.List(r.Context())
+	if err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []T{}
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *handlers[T]) get(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.id(w, r)
+	if !ok {
+		return
+	}
+	v, ok, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.errors.WriteError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (h *handlers[T]) create(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	created, err := h.store.Create(r.Context(), v)
+	if err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *handlers[T]) update(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.id(w, r)
+	if !ok {
+		return
+	}
+	v, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	updated, ok, err := h.store.Update(r.Context(), id, v)
+	if err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.errors.WriteError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *handlers[T]) delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.id(w, r)
+	if !ok {
+		return
+	}
+	v, ok, err := h.store.Get(r.Context(), id)
+	if err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		h.errors.WriteError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	for _, fn := range h.beforeDelete {
+		if err := fn(r.Context(), v); err != nil {
+			h.fail(w, r, err, http.StatusConflict)
+			return
+		}
+	}
+	// Gone in between: the client got what it asked for either way
+	if _, err := h.store.Delete(r.Context(), id); err != nil {
+		h.fail(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// id parses the {id} path segment, answering 400 if it is not one
+func (h *handlers[T]) id(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.errors.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	return id, true
+}
+
+// decode reads and validates the request body, answering the error if it
+// does not pass
+func (h *handlers[T]) decode(w http.ResponseWriter, r *http.Request) (T, bool) {
+	var v T
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		h.errors.WriteError(w, r, http.StatusBadRequest, "invalid request body")
+		return v, false
+	}
+	for _, fn := range h.validate {
+		if err := fn(r.Context(), &v); err != nil {
+			h.fail(w, r, err, http.StatusBadRequest)
+			return v, false
+		}
+	}
+	// Don't start a write the client has already given up on
+	return v, r.Context().Err() == nil
+}
+
+// fail answers err, with its own status if it is an *Error and status
+// otherwise. Internal errors are not shown to clients, and nothing is
+// written once the client is gone.
+func (h *handlers[T]) fail(w http.ResponseWriter, r *http.Request, err error, status int) {
+	if r.Context().Err() != nil {
+		return
+	}
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		h.errors.WriteError(w, r, e.Status, e.Message)
+	case status == http.StatusInternalServerError:
+		h.errors.WriteError(w, r, status, "internal error")
+	default:
+		h.errors.WriteError(w, r, status, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// jsonErrors writes {"error": msg}
+type jsonErrors struct{}
+
+func (jsonErrors) WriteError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
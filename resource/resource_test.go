@@ -0,0 +1,122 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+type widget struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+func newWidgets(opts ...Option[widget]) (*http.ServeMux, *MemoryStore[widget]) {
+	store := NewMemoryStore(func(w *widget, id int64) { w.ID = id })
+	mux := http.NewServeMux()
+	opts = append([]Option[widget]{WithValidate(func(ctx context.Context, w *widget) error {
+		w.Name = strings.TrimSpace(w.Name)
+		if w.Name == "" {
+			return errors.New("name is required")
+		}
+		return nil
+	})}, opts...)
+	Register(mux, "widgets", store, opts...)
+	return mux, store
+}
+
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+func TestRegister(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mux, _ := newWidgets()
+	for _, tt := range []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"GET", "/widgets", "", 200, `[]`},
+		{"POST", "/widgets", `{"name":" sprocket ","color":"red"}`, 201, `{"id":1,"name":"sprocket","color":"red"}`},
+		{"POST", "/widgets", `{"name":"cog"}`, 201, `{"id":2,"name":"cog"}`},
+		{"POST", "/widgets", `{"name":"  "}`, 400, `{"error":"name is required"}`},
+		{"POST", "/widgets", `{"name":`, 400, `{"error":"invalid request body"}`},
+		{"GET", "/widgets", "", 200, `[{"id":1,"name":"sprocket","color":"red"},{"id":2,"name":"cog"}]`},
+		{"GET", "/widgets/2", "", 200, `{"id":2,"name":"cog"}`},
+		{"GET", "/widgets/9", "", 404, `{"error":"not found"}`},
+		{"GET", "/widgets/x", "", 400, `{"error":"invalid id"}`},
+		// The ID comes from the path, not the body
+		{"PUT", "/widgets/2", `{"id":7,"name":"gear"}`, 200, `{"id":2,"name":"gear"}`},
+		{"PUT", "/widgets/9", `{"name":"gear"}`, 404, `{"error":"not found"}`},
+		{"PUT", "/widgets/2", `{}`, 400, `{"error":"name is required"}`},
+		{"DELETE", "/widgets/1", "", 204, ``},
+		{"DELETE", "/widgets/1", "", 404, `{"error":"not found"}`},
+		{"GET", "/widgets", "", 200, `[{"id":2,"name":"gear"}]`},
+	} {
+		w := do(mux, tt.method, tt.path, tt.body)
+		if w.Code != tt.status || strings.TrimSpace(w.Body.String()) != tt.want {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tt.method, tt.path, tt.status, tt.want, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRegisterHooks(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mux, store := newWidgets(
+		WithActions[widget](List, Create, Delete),
+		WithBeforeDelete(func(ctx context.Context, w widget) error {
+			if w.Color == "red" {
+				return errors.New("red widgets are in use")
+			}
+			return nil
+		}),
+		WithValidate(func(ctx context.Context, w *widget) error {
+			if w.Color == "blue" {
+				return Errorf(http.StatusUnprocessableEntity, "no %s widgets", w.Color)
+			}
+			return nil
+		}),
+	)
+	store.Create(context.Background(), widget{Name: "sprocket", Color: "red"})
+
+	if w := do(mux, "DELETE", "/widgets/1", ""); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "red widgets are in use") {
+		t.Errorf("expected the delete refused, got %d %s", w.Code, w.Body)
+	}
+	// Every validator runs, and an *Error picks the status
+	if w := do(mux, "POST", "/widgets", `{"name":"cog","color":"blue"}`); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "no blue widgets") {
+		t.Errorf("expected 422, got %d %s", w.Code, w.Body)
+	}
+	// Actions left out are not routed
+	if w := do(mux, "GET", "/widgets/1", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET /widgets/1 not routed, got %d", w.Code)
+	}
+}
+
+// failingStore fails to list
+type failingStore struct{ *MemoryStore[widget] }
+
+func (failingStore) List(context.Context) ([]widget, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRegisterStoreErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	mux := http.NewServeMux()
+	Register[widget](mux, "widgets", failingStore{})
+	// Store failures are not shown to clients
+	if w := do(mux, "GET", "/widgets", ""); w.Code != http.StatusInternalServerError || strings.TrimSpace(w.Body.String()) != `{"error":"internal error"}` {
+		t.Errorf("expected a 500 without details, got %d %s", w.Code, w.Body)
+	}
+}
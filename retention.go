@@ -0,0 +1,57 @@
+package quickserve
+
+import (
+	"context"
+	"time"
+)
+
+// retentionSweep is how often entries past their retention are pruned
+const retentionSweep = time.Hour
+
+// WithRetention keeps audit entries, and the user events and feeds read
+// from them, for d before they are pruned. Tenants may override it; zero
+// keeps them for ever.
+func WithRetention(d time.Duration) Option {
+	return func(s *Server) {
+		s.retention = d
+	}
+}
+
+// PruneExpired removes the audit entries older than the retention of the
+// tenant of the resource they record, or the server's for resources of
+// no tenant, and returns how many it removed
+func (s *Server) PruneExpired() (int, error) {
+	now := s.clock.Now()
+	retention := make(map[string]time.Duration)
+	return s.audit.Prune(func(e AuditEntry) bool {
+		tenant := auditTenant(e)
+		d, ok := retention[tenant]
+		if !ok {
+			d = time.Duration(s.settingsFor(tenant).Retention)
+			retention[tenant] = d
+		}
+		return d > 0 && now.Sub(e.OccurredAt) > d
+	})
+}
+
+// auditTenant returns the tenant of the resource an entry records
+func auditTenant(e AuditEntry) string {
+	if e.After != nil {
+		return eventTenant(e.After)
+	}
+	return eventTenant(e.Before)
+}
+
+// RunRetention prunes expired entries every hour until ctx is done
+func (s *Server) RunRetention(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(retentionSweep):
+			if _, err := s.PruneExpired(); err != nil {
+				s.componentLogger("audit").ErrorContext(ctx, "could not prune expired entries", "err", err)
+			}
+		}
+	}
+}
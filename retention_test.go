@@ -0,0 +1,58 @@
+package quickserve
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestPruneExpired(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	clock := NewSimulatedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	settings := NewTenantSettingsStore()
+	day := Duration(24 * time.Hour)
+	settings.Set("acme", TenantOverrides{Retention: &day})
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audit.Close()
+	server := NewServer(WithClock(clock), WithTenantSettings(settings), WithAuditLog(audit), WithRetention(7*24*time.Hour))
+
+	for _, tenant := range []string{"acme", "globex", ""} {
+		u, _ := server.createUser(ctx, User{Name: "Old", Email: "old@" + tenant + ".example.com", Tenant: tenant})
+		server.recordChange(ctx, AuditCreate, "user", u.ID.String(), nil, u)
+	}
+	clock.Advance(2 * 24 * time.Hour)
+	u, _ := server.createUser(ctx, User{Name: "New", Email: "new@globex.example.com", Tenant: "globex"})
+	server.recordChange(ctx, AuditCreate, "user", u.ID.String(), nil, u)
+
+	// acme keeps a day, everyone else the server's week
+	if n, err := server.PruneExpired(); n != 1 || err != nil {
+		t.Fatalf("expected acme's old entry pruned, got %d: %v", n, err)
+	}
+	clock.Advance(6 * 24 * time.Hour)
+	if n, _ := server.PruneExpired(); n != 2 {
+		t.Errorf("expected the other old entries pruned after a week, got %d", n)
+	}
+
+	// Pruning is persisted and IDs keep counting from the last
+	audit.Close()
+	reopened, err := OpenAuditLog(audit.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	entries := reopened.Query(AuditFilter{})
+	if len(entries) != 1 || entries[0].ID != 4 {
+		t.Fatalf("expected only the new entry kept, got %+v", entries)
+	}
+	if e, _ := reopened.Append(AuditEntry{Action: AuditCreate}); e.ID != 5 {
+		t.Errorf("expected the next ID after the last, got %d", e.ID)
+	}
+}
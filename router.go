@@ -0,0 +1,335 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/harshakonda/quickserve/resource"
+)
+
+// Module is a self-contained set of routes, such as a plugin or a
+// resource type, registered with the server at startup
+type Module interface {
+	Name() string
+	RegisterRoutes(g *RouteGroup)
+}
+
+// WithModule adds a module's routes to the server
+func WithModule(m Module) Option {
+	return func(s *Server) {
+		s.modules = append(s.modules, m)
+	}
+}
+
+// Route is a registered route and the module that owns it
+type Route struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Module   string `json:"module"`
+	Override bool   `json:"override,omitempty"`
+
+	// Replaced lists modules whose registration this route overrode
+	Replaced []string `json:"replaced,omitempty"`
+
+	// Idempotency tells gateways whether the route may be retried
+	Idempotency Idempotency `json:"idempotency"`
+
+	// MaxBody is the body limit the module asked for, if any
+	MaxBody int64 `json:"max_body,omitempty"`
+
+	// Priority decides how soon the route is shed under load
+	Priority Priority `json:"priority"`
+
+	// Deprecation is set on routes deprecated in the registry
+	Deprecation *RouteDeprecation `json:"deprecation,omitempty"`
+
+	handler http.Handler
+
+	// requestType, responseStatus, responseType and queryParams
+	// document the route in the OpenAPI document
+	requestType    reflect.Type
+	responseStatus int
+	responseType   reflect.Type
+	queryParams    []Parameter
+}
+
+// Idempotency classifies a route's retry semantics
+type Idempotency string
+
+const (
+	// Safe routes have no side effects and can always be retried
+	Safe Idempotency = "safe"
+	// Idempotent routes may be retried; repeating them has no further effect
+	Idempotent Idempotency = "idempotent"
+	// NonIdempotent routes must not be retried blindly
+	NonIdempotent Idempotency = "non-idempotent"
+)
+
+// Retryable reports whether a gateway may retry the route automatically
+func (i Idempotency) Retryable() bool {
+	return i == Safe || i == Idempotent
+}
+
+// methodIdempotency returns the RFC 9110 classification of a method.
+// Routes without a method match everything and are treated as unsafe.
+func methodIdempotency(method string) Idempotency {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return Safe
+	case http.MethodPut, http.MethodDelete:
+		return Idempotent
+	}
+	return NonIdempotent
+}
+
+// WithIdempotency overrides the classification derived from the method,
+// e.g. for a POST that is made idempotent by a client-supplied key
+func WithIdempotency(i Idempotency) RouteOption {
+	return func(rt *Route) {
+		rt.Idempotency = i
+	}
+}
+
+// Pattern returns the ServeMux pattern for the route
+func (rt *Route) Pattern() string {
+	if rt.Method == "" {
+		return rt.Path
+	}
+	return rt.Method + " " + rt.Path
+}
+
+// RouteOption configures a single route registration
+type RouteOption func(*Route)
+
+// Override marks a registration as intentionally replacing a route with
+// the same method and path registered by another module. Without it,
+// duplicates are reported as conflicts.
+func Override() RouteOption {
+	return func(rt *Route) {
+		rt.Override = true
+	}
+}
+
+// RouteRegistry collects routes from all modules so conflicts can be
+// detected before anything is served
+type RouteRegistry struct {
+	routes []*Route
+}
+
+// NewRouteRegistry creates an empty registry
+func NewRouteRegistry() *RouteRegistry {
+	return &RouteRegistry{}
+}
+
+// Group returns a registration handle for module. Middleware is applied,
+// outermost first, to every route registered through the group.
+func (rr *RouteRegistry) Group(module string, middleware ...func(http.Handler) http.Handler) *RouteGroup {
+	return &RouteGroup{registry: rr, module: module, middleware: middleware}
+}
+
+// RouteGroup registers routes on behalf of one module
+type RouteGroup struct {
+	registry   *RouteRegistry
+	module     string
+	middleware []func(http.Handler) http.Handler
+}
+
+// Module returns the name of the module owning the group
+func (g *RouteGroup) Module() string {
+	return g.module
+}
+
+// With returns a group for the same module with extra middleware
+func (g *RouteGroup) With(middleware ...func(http.Handler) http.Handler) *RouteGroup {
+	mw := append(append([]func(http.Handler) http.Handler{}, g.middleware...), middleware...)
+	return &RouteGroup{registry: g.registry, module: g.module, middleware: mw}
+}
+
+// Handle registers h for a ServeMux pattern such as "GET /users/{id}"
+func (g *RouteGroup) Handle(pattern string, h http.Handler, opts ...RouteOption) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		h = g.middleware[i](h)
+	}
+
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	rt := &Route{
+		Method:      method,
+		Path:        strings.TrimSpace(path),
+		Module:      g.module,
+		Idempotency: methodIdempotency(method),
+		Priority:    PriorityNormal,
+		handler:     h,
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	g.registry.routes = append(g.registry.routes, rt)
+}
+
+// HandleFunc registers a handler function for pattern
+func (g *RouteGroup) HandleFunc(pattern string, h http.HandlerFunc, opts ...RouteOption) {
+	g.Handle(pattern, h, opts...)
+}
+
+// Resources returns a resource.Mux registering on the group with opts,
+// so resource.Register routes are served and answer errors like the
+// built-in ones
+func (g *RouteGroup) Resources(opts ...RouteOption) resource.Mux {
+	return resourceMux{g, opts}
+}
+
+// resourceMux adapts a RouteGroup to resource.Mux
+type resourceMux struct {
+	group *RouteGroup
+	opts  []RouteOption
+}
+
+func (m resourceMux) Handle(pattern string, h http.Handler) {
+	m.group.Handle(pattern, h, m.opts...)
+}
+
+func (resourceMux) WriteError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	httpError(w, r, msg, status)
+}
+
+// routeKey normalizes a route so that patterns differing only in
+// wildcard names compare equal
+func routeKey(rt *Route) string {
+	segs := strings.Split(rt.Path, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") && seg != "{$}" {
+			if strings.HasSuffix(seg, "...}") {
+				segs[i] = "{...}"
+			} else {
+				segs[i] = "{}"
+			}
+		}
+	}
+	return rt.Method + " " + strings.Join(segs, "/")
+}
+
+// resolve applies override precedence and returns the winning routes in
+// registration order, along with every conflict found
+func (rr *RouteRegistry) resolve() ([]*Route, []error) {
+	byKey := make(map[string][]*Route)
+	var order []string
+	for _, rt := range rr.routes {
+		k := routeKey(rt)
+		if _, seen := byKey[k]; !seen {
+			order = append(order, k)
+		}
+		byKey[k] = append(byKey[k], rt)
+	}
+
+	var winners []*Route
+	var errs []error
+	for _, k := range order {
+		candidates := byKey[k]
+		if len(candidates) == 1 {
+			winners = append(winners, candidates[0])
+			continue
+		}
+
+		var overrides []*Route
+		var modules []string
+		for _, rt := range candidates {
+			modules = append(modules, fmt.Sprintf("%s (%s)", rt.Module, rt.Pattern()))
+			if rt.Override {
+				overrides = append(overrides, rt)
+			}
+		}
+
+		if len(overrides) != 1 {
+			errs = append(errs, fmt.Errorf("route %s registered more than once: %s", candidates[0].Pattern(), strings.Join(modules, ", ")))
+			continue
+		}
+
+		winner := overrides[0]
+		winner.Replaced = nil
+		for _, rt := range candidates {
+			if rt != winner {
+				winner.Replaced = append(winner.Replaced, rt.Module)
+			}
+		}
+		winners = append(winners, winner)
+	}
+	return winners, errs
+}
+
+// Mount registers the resolved routes on mux. It reports duplicates that
+// are not resolved by Override, as well as overlapping patterns that
+// ServeMux itself would reject, naming the modules involved.
+func (rr *RouteRegistry) Mount(mux *http.ServeMux) error {
+	winners, errs := rr.resolve()
+
+	for _, rt := range winners {
+		if err := register(mux, rt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// register adds a single route, turning ServeMux's panic on overlapping
+// patterns into an error attributed to the module
+func register(mux *http.ServeMux, rt *Route) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("route %s from module %s: %v", rt.Pattern(), rt.Module, r)
+		}
+	}()
+	mux.Handle(rt.Pattern(), withRoute(rt, rt.handler))
+	return nil
+}
+
+type routeKeyCtx struct{}
+
+// withRoute makes the matched route available to middleware, which is
+// how per-route policy such as permissions is looked up
+func withRoute(rt *Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKeyCtx{}, rt)))
+	})
+}
+
+// RouteFromContext returns the route that matched the request
+func RouteFromContext(ctx context.Context) (*Route, bool) {
+	rt, ok := ctx.Value(routeKeyCtx{}).(*Route)
+	return rt, ok
+}
+
+// Routes returns the resolved routes sorted by path and method
+func (rr *RouteRegistry) Routes() []Route {
+	winners, _ := rr.resolve()
+
+	routes := make([]Route, 0, len(winners))
+	for _, rt := range winners {
+		routes = append(routes, *rt)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// HandleListRoutes handles GET /admin/routes, describing every route
+// served, the module that owns it and its retry semantics
+func (s *Server) HandleListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := []Route{}
+	if s.routes != nil {
+		routes = s.routes.Routes()
+	}
+
+	s.writeJSON(w, r, http.StatusOK, routes)
+}
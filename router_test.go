@@ -0,0 +1,180 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve/resource"
+)
+
+// testModule registers fixed routes for exercising the registry
+type testModule struct {
+	name     string
+	patterns []string
+	opts     []RouteOption
+}
+
+func (m testModule) Name() string { return m.name }
+
+func (m testModule) RegisterRoutes(g *RouteGroup) {
+	for _, p := range m.patterns {
+		g.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(m.name))
+		}, m.opts...)
+	}
+}
+
+func TestRouteConflictReported(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithModule(testModule{name: "widgets", patterns: []string{"GET /users/{userID}"}}))
+
+	_, err := server.Handler()
+	if err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if !strings.Contains(err.Error(), "users (GET /users/{id})") || !strings.Contains(err.Error(), "widgets (GET /users/{userID})") {
+		t.Errorf("expected both modules in error, got %v", err)
+	}
+}
+
+func TestRouteOverlapReported(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	rr := NewRouteRegistry()
+	testModule{name: "a", patterns: []string{"GET /x/{id}/detail"}}.RegisterRoutes(rr.Group("a"))
+	testModule{name: "b", patterns: []string{"GET /x/all/{field}"}}.RegisterRoutes(rr.Group("b"))
+
+	err := rr.Mount(http.NewServeMux())
+	if err == nil || !strings.Contains(err.Error(), "module b") {
+		t.Errorf("expected overlap attributed to module b, got %v", err)
+	}
+}
+
+func TestRouteOverride(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithModule(testModule{
+		name:     "custom-health",
+		patterns: []string{"GET /health"},
+		opts:     []RouteOption{Override()},
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Body.String() != "custom-health" {
+		t.Errorf("expected override to win, got %q", w.Body.String())
+	}
+
+	rr := NewRouteRegistry()
+	server.registerRoutes(rr)
+	for _, rt := range rr.Routes() {
+		if rt.Path == "/health" && (len(rt.Replaced) != 1 || rt.Replaced[0] != "health") {
+			t.Errorf("expected /health to record replaced module, got %v", rt.Replaced)
+		}
+	}
+}
+
+func TestRouteIdempotency(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAdminCredentials("admin", "pw"))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	req.SetBasicAuth("admin", "pw")
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var routes []Route
+	json.NewDecoder(w.Body).Decode(&routes)
+
+	want := map[string]Idempotency{
+		"GET /users":         Safe,
+		"POST /users":        NonIdempotent,
+		"DELETE /users/{id}": Idempotent,
+	}
+	for _, rt := range routes {
+		if expected, ok := want[rt.Pattern()]; ok {
+			if rt.Idempotency != expected {
+				t.Errorf("%s: expected %s, got %s", rt.Pattern(), expected, rt.Idempotency)
+			}
+			delete(want, rt.Pattern())
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("routes missing from introspection: %v", want)
+	}
+}
+
+func TestWithIdempotencyOverride(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	rr := NewRouteRegistry()
+	rr.Group("orders").HandleFunc("POST /orders", func(w http.ResponseWriter, r *http.Request) {}, WithIdempotency(Idempotent))
+
+	if got := rr.Routes()[0].Idempotency; got != Idempotent {
+		t.Errorf("expected override to idempotent, got %s", got)
+	}
+}
+
+// gadgetModule serves a generic resource from a module
+type gadgetModule struct{}
+
+type gadget struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func (gadgetModule) Name() string { return "gadgets" }
+
+func (gadgetModule) RegisterRoutes(g *RouteGroup) {
+	store := resource.NewMemoryStore(func(v *gadget, id int64) { v.ID = id })
+	resource.Register(g.Resources(WithPriority(PriorityLow)), "gadgets", store)
+}
+
+func TestRouteGroupResources(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithModule(gadgetModule{}))
+	handler, err := server.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/gadgets", strings.NewReader(`{"name":"lever"}`)))
+	if w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"name":"lever"`) {
+		t.Fatalf("expected the gadget created, got %d: %s", w.Code, w.Body)
+	}
+
+	// Errors look like those of the built-in routes
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gadgets/9", nil))
+	var body errorBody
+	json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusNotFound || body.Error != "not found" || body.RequestID == "" {
+		t.Errorf("expected a 404 with the request ID, got %d: %+v", w.Code, body)
+	}
+
+	var registered int
+	for _, rt := range server.routes.routes {
+		if rt.Module == "gadgets" {
+			registered++
+			if rt.Priority != PriorityLow {
+				t.Errorf("%s: expected the route options applied, got %v", rt.Pattern(), rt.Priority)
+			}
+		}
+	}
+	if registered != 5 {
+		t.Errorf("expected 5 gadget routes, got %d", registered)
+	}
+}
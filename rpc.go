@@ -0,0 +1,200 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// rpcPath is where JSON-RPC 2.0 requests are posted
+const rpcPath = "/rpc"
+
+// maxRPCBatch caps the calls in one batch, so a single request cannot keep
+// the server busy for long
+const maxRPCBatch = 100
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	// rpcServerError reports a failure of the route a method maps to;
+	// data carries its status and error body
+	rpcServerError = -32000
+)
+
+// rpcRequest is one JSON-RPC call. A call without an ID is a
+// notification and gets no response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *rpcErrorData `json:"data,omitempty"`
+}
+
+// rpcErrorData is the failed route's status and error body
+type rpcErrorData struct {
+	Status int `json:"status"`
+	errorBody
+}
+
+// rpcIDParams are the params of the methods acting on one user
+type rpcIDParams struct {
+	ID *ID `json:"id"`
+}
+
+// rpcRoute maps a JSON-RPC method and its params to the request of the
+// user route serving it
+func rpcRoute(method string, params json.RawMessage) (httpMethod, path string, body []byte, rerr *rpcError) {
+	switch method {
+	case "user.list":
+		return http.MethodGet, "/users", nil, nil
+	case "user.get", "user.delete":
+		var p rpcIDParams
+		if err := json.Unmarshal(params, &p); err != nil || p.ID == nil {
+			return "", "", nil, &rpcError{Code: rpcInvalidParams, Message: `params must be an object with an "id"`}
+		}
+		if method == "user.get" {
+			return http.MethodGet, "/users/" + p.ID.String(), nil, nil
+		}
+		return http.MethodDelete, "/users/" + p.ID.String(), nil, nil
+	case "user.create":
+		// The params are the body of POST /users, which validates them
+		if p := bytes.TrimSpace(params); len(p) == 0 || p[0] != '{' {
+			return "", "", nil, &rpcError{Code: rpcInvalidParams, Message: "params must be an object"}
+		}
+		return http.MethodPost, "/users", params, nil
+	}
+	return "", "", nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %q not found", method)}
+}
+
+// HandleRPC handles POST /rpc, a JSON-RPC 2.0 endpoint offering
+// user.list, user.get, user.create and user.delete, singly or in batches.
+// Each call is served by the user route it maps to with the caller's
+// headers, so it is authenticated, authorized, validated and audited
+// exactly like the REST request.
+func (s *Server) HandleRPC(w http.ResponseWriter, r *http.Request) {
+	var body json.RawMessage
+	if err := decodeJSON(r, &body); err != nil {
+		s.writeRPC(w, r, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcParseError, Message: "parse error"}})
+		return
+	}
+
+	if b := bytes.TrimSpace(body); len(b) == 0 || b[0] != '[' {
+		if resp, ok := s.serveRPC(r, body); ok {
+			s.writeRPC(w, r, resp)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+		s.writeRPC(w, r, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: "a batch must be a non-empty array"}})
+		return
+	}
+	if len(batch) > maxRPCBatch {
+		s.writeRPC(w, r, rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: fmt.Sprintf("a batch may hold at most %d calls", maxRPCBatch)}})
+		return
+	}
+	resps := make([]rpcResponse, 0, len(batch))
+	for _, call := range batch {
+		if r.Context().Err() != nil {
+			return
+		}
+		if resp, ok := s.serveRPC(r, call); ok {
+			resps = append(resps, resp)
+		}
+	}
+	// A batch of notifications only is answered with nothing at all
+	if len(resps) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeRPC(w, r, resps)
+}
+
+// serveRPC runs one call through the routes. It reports false for
+// notifications, which get no response.
+func (s *Server) serveRPC(r *http.Request, raw json.RawMessage) (rpcResponse, bool) {
+	var call rpcRequest
+	if err := json.Unmarshal(raw, &call); err != nil || call.JSONRPC != "2.0" || call.Method == "" {
+		// The ID can't be trusted, so the error has none
+		return rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: rpcInvalidRequest, Message: "invalid request"}}, true
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: call.ID}
+	resp.Result, resp.Error = s.callRPC(r, call)
+	return resp, call.ID != nil
+}
+
+// callRPC serves call with the user route it maps to and returns the
+// route's JSON answer as the result
+func (s *Server) callRPC(r *http.Request, call rpcRequest) (json.RawMessage, *rpcError) {
+	method, path, body, rerr := rpcRoute(call.Method, call.Params)
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	ctx := r.Context()
+	if s.tracer != nil {
+		var sp *Span
+		ctx, sp = s.tracer.Start(ctx, "rpc "+call.Method, SpanKindInternal)
+		sp.SetAttribute("rpc.method", call.Method)
+		defer sp.End()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: "internal error"}
+	}
+	// The caller's headers carry its credentials; the body is the call's
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Length")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Host, req.RemoteAddr, req.TLS = r.Host, r.RemoteAddr, r.TLS
+
+	w := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+	s.mux.ServeHTTP(w, req)
+	if w.status >= 300 {
+		var e errorBody
+		if json.Unmarshal(w.body.Bytes(), &e) != nil || e.Error == "" {
+			e.Error = http.StatusText(w.status)
+		}
+		code := rpcServerError
+		if w.status == http.StatusBadRequest {
+			code = rpcInvalidParams
+		}
+		return nil, &rpcError{Code: code, Message: e.Error, Data: &rpcErrorData{Status: w.status, errorBody: e}}
+	}
+	if w.body.Len() == 0 {
+		return json.RawMessage("null"), nil
+	}
+	return json.RawMessage(bytes.TrimSpace(w.body.Bytes())), nil
+}
+
+func (s *Server) writeRPC(w http.ResponseWriter, r *http.Request, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		httpError(w, r, "could not encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
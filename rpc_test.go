@@ -0,0 +1,134 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// postRPC posts body to /rpc with key and decodes the answer into out
+func postRPC(t *testing.T, handler http.Handler, key, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, rpcPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(APIKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if out != nil && w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(out); err != nil {
+			t.Fatalf("decode %q: %v", body, err)
+		}
+	}
+	return w.Code
+}
+
+func TestRPC(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer(WithAPIKeyAuth("admin-secret"))
+	handler := s.Routes()
+
+	var created rpcResponse
+	postRPC(t, handler, "admin-secret", `{"jsonrpc":"2.0","method":"user.create","params":{"name":"Alice","email":"alice@test.com"},"id":"c"}`, &created)
+	var alice User
+	if created.Error != nil || string(created.ID) != `"c"` || json.Unmarshal(created.Result, &alice) != nil || alice.Name != "Alice" {
+		t.Fatalf("expected Alice to be created, got %+v", created)
+	}
+
+	var got rpcResponse
+	postRPC(t, handler, "admin-secret", `{"jsonrpc":"2.0","method":"user.get","params":{"id":"`+alice.ID.String()+`"},"id":1}`, &got)
+	if got.Error != nil || !strings.Contains(string(got.Result), `"alice@test.com"`) {
+		t.Errorf("expected Alice, got %+v", got)
+	}
+
+	var deleted rpcResponse
+	postRPC(t, handler, "admin-secret", `{"jsonrpc":"2.0","method":"user.delete","params":{"id":`+alice.ID.String()+`},"id":2}`, &deleted)
+	if deleted.Error != nil || string(deleted.Result) != "null" {
+		t.Errorf("expected a null result, got %+v", deleted)
+	}
+	if _, ok, _ := s.store.Get(context.Background(), alice.ID); ok {
+		t.Error("expected Alice to be deleted")
+	}
+
+	// Calls are authorized by the route they map to
+	var denied rpcResponse
+	postRPC(t, handler, "", `{"jsonrpc":"2.0","method":"user.list","id":3}`, &denied)
+	if denied.Error == nil || denied.Error.Code != rpcServerError || denied.Error.Data.Status != http.StatusUnauthorized {
+		t.Errorf("expected the route's 401, got %+v", denied)
+	}
+}
+
+func TestRPCErrors(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	handler := NewServer().Routes()
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":`, rpcParseError},
+		{"not 2.0", `{"jsonrpc":"1.0","method":"user.list","id":1}`, rpcInvalidRequest},
+		{"no method", `{"jsonrpc":"2.0","id":1}`, rpcInvalidRequest},
+		{"empty batch", `[]`, rpcInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","method":"user.rename","id":1}`, rpcMethodNotFound},
+		{"missing id", `{"jsonrpc":"2.0","method":"user.get","params":{},"id":1}`, rpcInvalidParams},
+		{"create params not an object", `{"jsonrpc":"2.0","method":"user.create","params":["Alice"],"id":1}`, rpcInvalidParams},
+		{"invalid user", `{"jsonrpc":"2.0","method":"user.create","params":{"name":"Alice","email":"a@test.com","role":"root"},"id":1}`, rpcInvalidParams},
+		{"not found", `{"jsonrpc":"2.0","method":"user.get","params":{"id":999},"id":1}`, rpcServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp rpcResponse
+			if status := postRPC(t, handler, "", tt.body, &resp); status != http.StatusOK {
+				t.Fatalf("expected 200, got %d", status)
+			}
+			if resp.Error == nil || resp.Error.Code != tt.code {
+				t.Errorf("expected error %d, got %+v", tt.code, resp)
+			}
+		})
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer()
+	handler := s.Routes()
+
+	var resps []rpcResponse
+	postRPC(t, handler, "", `[
+		{"jsonrpc":"2.0","method":"user.create","params":{"name":"Alice","email":"alice@test.com"},"id":1},
+		{"jsonrpc":"2.0","method":"user.create","params":{"name":"Bob","email":"bob@test.com"}},
+		{"jsonrpc":"2.0","method":"user.list","id":2},
+		42
+	]`, &resps)
+	if len(resps) != 3 {
+		t.Fatalf("expected three responses, the notification getting none, got %+v", resps)
+	}
+	var users []User
+	if err := json.Unmarshal(resps[1].Result, &users); err != nil || len(users) != 2 {
+		t.Errorf("expected the notification to have run before the list, got %s", resps[1].Result)
+	}
+	if resps[2].Error == nil || resps[2].Error.Code != rpcInvalidRequest || string(resps[2].ID) != "null" {
+		t.Errorf("expected an invalid request without an ID, got %+v", resps[2])
+	}
+
+	if status := postRPC(t, handler, "", `[{"jsonrpc":"2.0","method":"user.list"}]`, nil); status != http.StatusNoContent {
+		t.Errorf("expected notifications only to get no content, got %d", status)
+	}
+
+	batch := strings.Repeat(`{"jsonrpc":"2.0","method":"user.list","id":1},`, maxRPCBatch+1)
+	var tooMany rpcResponse
+	postRPC(t, handler, "", "["+strings.TrimSuffix(batch, ",")+"]", &tooMany)
+	if tooMany.Error == nil || tooMany.Error.Code != rpcInvalidRequest {
+		t.Errorf("expected an oversized batch to be refused, got %+v", tooMany)
+	}
+}
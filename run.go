@@ -0,0 +1,163 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// webhookTimeout bounds one background webhook delivery
+const webhookTimeout = 10 * time.Second
+
+var errServerRunning = errors.New("quickserve: Run called twice")
+
+// taskGroup runs goroutines that share a lifetime. The first task to fail
+// or panic cancels the others, and wait returns once every task has
+// returned, so nothing outlives its owner.
+type taskGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+func newTaskGroup(parent context.Context) *taskGroup {
+	ctx, cancel := context.WithCancel(parent)
+	return &taskGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go starts fn under the group's context. It reports false, and does not
+// run fn, once the group is stopping.
+func (g *taskGroup) Go(name string, fn func(ctx context.Context) error) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return false
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			if p := recover(); p != nil {
+				g.fail(fmt.Errorf("%s: panic: %v\n%s", name, p, debug.Stack()))
+			}
+		}()
+		if err := fn(g.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			g.fail(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+	return true
+}
+
+// fail records the first error and cancels every task
+func (g *taskGroup) fail(err error) {
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// stop refuses new tasks and cancels the running ones
+func (g *taskGroup) stop() {
+	g.mu.Lock()
+	g.closed = true
+	g.mu.Unlock()
+	g.cancel()
+}
+
+// wait blocks until every task has returned and reports the first failure
+func (g *taskGroup) wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// Run runs the server's background work until ctx is done or a job fails:
+// weekly digests, retention pruning, snapshot shipping, span export, Kafka
+// publishing, NATS relaying, leak checks and the search index rebuild when
+// configured, and webhook deliveries started by requests. On return every one of these goroutines has exited, so a
+// leak check after Run covers the whole server lifecycle. Serving HTTP is
+// left to the caller.
+func (s *Server) Run(ctx context.Context) error {
+	if !s.running.CompareAndSwap(false, true) {
+		return errServerRunning
+	}
+
+	s.tasks.Go("digests", func(ctx context.Context) error {
+		s.RunWeeklyDigests(ctx)
+		return nil
+	})
+	s.tasks.Go("retention", func(ctx context.Context) error {
+		s.RunRetention(ctx)
+		return nil
+	})
+	if s.replication != nil && s.replication.standbyURL != "" {
+		s.tasks.Go("snapshots", func(ctx context.Context) error {
+			s.RunSnapshotShipping(ctx)
+			return nil
+		})
+	}
+	if s.tracer != nil {
+		s.tasks.Go("tracing", func(ctx context.Context) error {
+			s.tracer.Run(ctx)
+			return nil
+		})
+	}
+	if s.kafka != nil {
+		s.tasks.Go("kafka", func(ctx context.Context) error {
+			s.kafka.Run(ctx)
+			return nil
+		})
+	}
+	if s.nats != nil {
+		s.tasks.Go("nats", s.nats.Run)
+	}
+	if s.leaks != nil {
+		s.tasks.Go("leaks", func(ctx context.Context) error {
+			s.RunLeakMonitor(ctx)
+			return nil
+		})
+	}
+	if s.bleve != nil {
+		s.tasks.Go("search-index", s.RebuildSearchIndex)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-s.tasks.ctx.Done():
+	}
+	return s.Close()
+}
+
+// Close cancels the server's background work and waits for it to exit.
+// Run calls it on the way out; servers that are never Run, such as an
+// Embedded instance, call it to stop webhook deliveries in flight.
+func (s *Server) Close() error {
+	s.endStreams()
+	s.tasks.stop()
+	return s.tasks.wait()
+}
+
+// goBackground runs fn outside the request that started it, with the
+// request's values but the server's lifetime: shutdown cancels it, and
+// Close waits for it. Requests arriving during shutdown skip the work.
+func (s *Server) goBackground(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context)) bool {
+	values := context.WithoutCancel(ctx)
+	return s.tasks.Go(name, func(group context.Context) error {
+		ctx, cancel := context.WithTimeout(values, timeout)
+		defer cancel()
+		stop := context.AfterFunc(group, cancel)
+		defer stop()
+		fn(ctx)
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRunStopsBackgroundWork(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	standby := httptest.NewServer(http.NotFoundHandler())
+	defer standby.Close()
+
+	server := NewServer(
+		WithClock(NewSimulatedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))),
+		WithSnapshotShipping(standby.URL, "secret", time.Minute),
+		WithTracing(NewTracer(TracingConfig{Endpoint: "http://collector.invalid"})),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Run(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected a clean stop, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was canceled")
+	}
+
+	if err := server.Run(context.Background()); !errors.Is(err, errServerRunning) {
+		t.Errorf("expected a second Run to fail, got %v", err)
+	}
+	if server.goBackground(context.Background(), "late", time.Second, func(context.Context) {}) {
+		t.Error("expected background work to be refused after Run returned")
+	}
+}
+
+func TestTaskGroupFailureStopsOthers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	g := newTaskGroup(context.Background())
+	stopped := make(chan struct{})
+	g.Go("waiter", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	g.Go("crasher", func(context.Context) error {
+		panic("boom")
+	})
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a panicking task to cancel the others")
+	}
+	g.stop()
+	err := g.wait()
+	if err == nil || !strings.Contains(err.Error(), "crasher: panic: boom") {
+		t.Errorf("expected the panic to be reported with the task name, got %v", err)
+	}
+	if g.Go("late", func(context.Context) error { return nil }) {
+		t.Error("expected a stopped group to refuse tasks")
+	}
+}
+
+func TestCloseCancelsWebhooks(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	arrived := make(chan struct{})
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.ReadAll(r.Body)
+		close(arrived)
+		<-r.Context().Done()
+	}))
+	defer owner.Close()
+
+	server := NewServer()
+	server.webhookClient = owner.Client()
+	key := server.apiKeys.Import("legacy", "legacy-secret", RoleUser)
+	server.apiKeys.SetNotifyURL(key.ID, owner.URL)
+
+	server.notifyDeprecatedUsage(context.Background(), DeprecationUsage{KeyID: key.ID})
+	select {
+	case <-arrived:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the webhook to be delivered")
+	}
+
+	start := time.Now()
+	if err := server.Close(); err != nil {
+		t.Errorf("expected a clean close, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("expected Close to cancel the hung delivery, took %s", d)
+	}
+}
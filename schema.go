@@ -0,0 +1,239 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaDrift describes records in a persisted store that do not match
+// what the code expects, e.g. a missing field or one of the wrong type.
+// Identical problems are reported once with a count and an example.
+type SchemaDrift struct {
+	Store    string `json:"store"`
+	Field    string `json:"field"`
+	Problem  string `json:"problem"`
+	Expected string `json:"expected,omitempty"`
+	Found    string `json:"found,omitempty"`
+	Records  int    `json:"records"`
+	Example  string `json:"example"`
+}
+
+// fieldSpec is the JSON shape a field must have
+type fieldSpec struct {
+	kind     string
+	required bool
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	idType          = reflect.TypeOf(ID(0))
+	textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// schemaOf derives the expected fields of a record from the struct it is
+// decoded into, so the schema can never fall out of step with the code
+func schemaOf(t reflect.Type) map[string]fieldSpec {
+	fields := make(map[string]fieldSpec)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = fieldSpec{
+			kind:     jsonKindOf(f.Type),
+			required: !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer,
+		}
+	}
+	return fields
+}
+
+// jsonKindOf names the JSON type a Go type decodes from
+func jsonKindOf(t reflect.Type) string {
+	switch {
+	case t == rawMessageType:
+		return "any"
+	case t == idType:
+		return "id"
+	case t == timeType, reflect.PointerTo(t).Implements(textUnmarshaler):
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonKindOf(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "any"
+}
+
+// jsonKind names the JSON type of a raw value
+func jsonKind(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "missing"
+	}
+	switch raw[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// kindMatches reports whether a value of kind found fits spec
+func kindMatches(spec fieldSpec, found string) bool {
+	switch {
+	case spec.kind == "any", spec.kind == found:
+		return true
+	case found == "null":
+		return !spec.required
+	case spec.kind == "id":
+		return found == "number" || found == "string"
+	}
+	return false
+}
+
+// schemaCheck accumulates the drift found while loading one store
+type schemaCheck struct {
+	store  string
+	schema map[string]fieldSpec
+	drift  map[[4]string]*SchemaDrift
+}
+
+func newSchemaCheck(store string, v any) *schemaCheck {
+	return &schemaCheck{store: store, schema: schemaOf(reflect.TypeOf(v)), drift: make(map[[4]string]*SchemaDrift)}
+}
+
+func (c *schemaCheck) add(field, problem, expected, found, record string) {
+	k := [4]string{field, problem, expected, found}
+	d, ok := c.drift[k]
+	if !ok {
+		d = &SchemaDrift{Store: c.store, Field: field, Problem: problem, Expected: expected, Found: found, Example: record}
+		c.drift[k] = d
+	}
+	d.Records++
+}
+
+// record compares one stored record against the schema and reports
+// whether it matched
+func (c *schemaCheck) record(name string, raw []byte) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		c.add("", "not an object", "object", jsonKind(raw), name)
+		return false
+	}
+
+	ok := true
+	for field, spec := range c.schema {
+		v, present := fields[field]
+		switch {
+		case !present && spec.required:
+			c.add(field, "missing", spec.kind, "", name)
+			ok = false
+		case present && !kindMatches(spec, jsonKind(v)):
+			c.add(field, "wrong type", spec.kind, jsonKind(v), name)
+			ok = false
+		}
+	}
+	for field := range fields {
+		if _, known := c.schema[field]; !known {
+			c.add(field, "unexpected", "", "", name)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// report lists the drift found, ordered by field and problem
+func (c *schemaCheck) report() []SchemaDrift {
+	out := make([]SchemaDrift, 0, len(c.drift))
+	for _, d := range c.drift {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Field != out[j].Field {
+			return out[i].Field < out[j].Field
+		}
+		return out[i].Problem < out[j].Problem
+	})
+	return out
+}
+
+// WithSchemaDriftReads keeps serving reads while persisted stores have
+// drifted from the expected schema. Writes are refused either way, since
+// they would overwrite records the code cannot read.
+func WithSchemaDriftReads(allow bool) Option {
+	return func(s *Server) {
+		s.driftReads = allow
+	}
+}
+
+// schemaDrift collects the drift reported by every persisted store
+func (s *Server) schemaDrift() []SchemaDrift {
+	return append(s.audit.Drift(), s.tenantSettings.Drift()...)
+}
+
+// refuseOnDrift answers 503 to writes, and to reads unless they are
+// allowed, while a store has drifted. Health checks and the schema report
+// stay available so operators can see what needs migrating.
+func (s *Server) refuseOnDrift(next http.Handler) http.Handler {
+	drift := s.schemaDrift()
+	if len(drift) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		exempt := isHealthPath(path) || path == "/admin/schema"
+		if !exempt && (methodIdempotency(r.Method) != Safe || !s.driftReads) {
+			writeError(w, r, http.StatusServiceUnavailable, errorBody{
+				Error: "stored data does not match the expected schema; apply migrations and restart",
+				Drift: drift,
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// HandleGetSchema handles GET /admin/schema, reporting schema drift in
+// the persisted stores
+func (s *Server) HandleGetSchema(w http.ResponseWriter, r *http.Request) {
+	drift := s.schemaDrift()
+	s.writeJSON(w, r, http.StatusOK, struct {
+		OK     bool          `json:"ok"`
+		Writes bool          `json:"writes"`
+		Reads  bool          `json:"reads"`
+		Drift  []SchemaDrift `json:"drift"`
+	}{len(drift) == 0, len(drift) == 0, len(drift) == 0 || s.driftReads, drift})
+}
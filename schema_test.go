@@ -0,0 +1,126 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestAuditLogSchemaDrift(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	lines := []string{
+		`{"id":1,"occurred_at":"2030-01-01T00:00:00Z","action":"create","resource":"user","method":"POST","path":"/users"}`,
+		`{"id":2,"occurred_at":"2030-01-01T00:00:00Z","resource":"user","method":"POST","path":"/users"}`,
+		`{"id":3,"occurred_at":"2030-01-01T00:00:00Z","action":"create","method":"POST","path":"/users"}`,
+		`{"id":true,"occurred_at":"2030-01-01T00:00:00Z","action":"create","resource":"user","method":"POST","path":"/users","tenant":"acme"}`,
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if got := l.Query(AuditFilter{}); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("expected only the matching entry to load, got %+v", got)
+	}
+	want := []SchemaDrift{
+		{Store: "audit log", Field: "action", Problem: "missing", Expected: "string", Records: 1, Example: path + ":2"},
+		{Store: "audit log", Field: "id", Problem: "wrong type", Expected: "id", Found: "boolean", Records: 1, Example: path + ":4"},
+		{Store: "audit log", Field: "resource", Problem: "missing", Expected: "string", Records: 1, Example: path + ":3"},
+		{Store: "audit log", Field: "tenant", Problem: "unexpected", Records: 1, Example: path + ":4"},
+	}
+	got := l.Drift()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d problems, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("problem %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestTenantSettingsSchemaDrift(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	os.WriteFile(path, []byte(`{"ok":{"webhook_limit":5},"bad":{"webhook_limit":"ten"},"worse":{"retention":"a week"}}`), 0o600)
+
+	store, err := LoadTenantSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("ok"); !ok {
+		t.Error("expected the valid tenant to load")
+	}
+	if _, ok := store.Get("bad"); ok {
+		t.Error("expected the drifted tenant to be left out")
+	}
+	drift := store.Drift()
+	if len(drift) != 2 {
+		t.Fatalf("expected two problems, got %+v", drift)
+	}
+	if drift[0].Problem != "undecodable" || drift[1].Field != "webhook_limit" || drift[1].Found != "string" {
+		t.Errorf("unexpected drift: %+v", drift)
+	}
+}
+
+func TestSchemaDriftRefusesWrites(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	os.WriteFile(path, []byte(`{"acme":{"features":["export"]}}`), 0o600)
+	store, err := LoadTenantSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reads := range []bool{true, false} {
+		server := NewServer(WithTenantSettings(store), WithAdminCredentials("admin", "pw"), WithSchemaDriftReads(reads))
+		handler := server.Routes()
+		do := func(method, path, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.SetBasicAuth("admin", "pw")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w
+		}
+
+		w := do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected writes to be refused, got %d", w.Code)
+		}
+		var body errorBody
+		json.NewDecoder(w.Body).Decode(&body)
+		if len(body.Drift) != 1 || body.Drift[0].Field != "features" {
+			t.Errorf("expected the drift in the error, got %+v", body)
+		}
+
+		if w := do(http.MethodGet, "/users", ""); (w.Code == http.StatusOK) != reads {
+			t.Errorf("reads allowed %v: GET /users answered %d", reads, w.Code)
+		}
+		if w := do(http.MethodGet, "/health", ""); w.Code != http.StatusOK {
+			t.Errorf("expected health checks to keep working, got %d", w.Code)
+		}
+
+		w = do(http.MethodGet, "/admin/schema", "")
+		var report struct {
+			OK, Writes, Reads bool
+			Drift             []SchemaDrift
+		}
+		json.NewDecoder(w.Body).Decode(&report)
+		if w.Code != http.StatusOK || report.OK || report.Writes || report.Reads != reads || len(report.Drift) != 1 {
+			t.Errorf("unexpected schema report: %d %+v", w.Code, report)
+		}
+	}
+}
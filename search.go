@@ -0,0 +1,255 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// defaultSearchLimit is how many results a search returns by default
+	defaultSearchLimit = 20
+	// maxSearchLimit bounds ?limit= on searches
+	maxSearchLimit = 100
+	// maxSearchTokenRunes bounds the words indexed; longer ones are cut,
+	// so the prefixes stored per word stay few
+	maxSearchTokenRunes = 32
+)
+
+// Weights of a match in each field. A prefix of a word scores the share
+// of the word it covers, so "ali" ranks Ali above Alice.
+const (
+	searchNameWeight  = 2
+	searchEmailWeight = 1
+)
+
+// UserSearcher is implemented by user stores that can search users by
+// text. A MemoryUserStore keeps an inverted index for it.
+type UserSearcher interface {
+	// SearchUsers returns up to limit users matching every word of query,
+	// most relevant first
+	SearchUsers(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchResult is a user matching a search, with its relevance
+type SearchResult struct {
+	User  User    `json:"user"`
+	Score float64 `json:"score"`
+}
+
+// searchPage is the response of GET /users/search
+type searchPage struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// searchTokens splits s into lowercase words of letters and digits, so
+// "Alice.Smith@example.com" is alice, smith, example and com
+func searchTokens(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, w := range words {
+		if r := []rune(w); len(r) > maxSearchTokenRunes {
+			words[i] = string(r[:maxSearchTokenRunes])
+		}
+	}
+	return words
+}
+
+// searchIndex is an inverted index from every prefix of the words in
+// users' names and emails to the users having them, weighted by field
+// and by how much of the word the prefix covers
+type searchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[ID]float64
+	// keys are the postings of each user, to remove it
+	keys map[ID][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{postings: make(map[string]map[ID]float64), keys: make(map[ID][]string)}
+}
+
+// add indexes u, replacing what was indexed for it before
+func (x *searchIndex) add(u User) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(u.ID)
+
+	best := make(map[string]float64)
+	for _, f := range []struct {
+		text   string
+		weight float64
+	}{{u.Name, searchNameWeight}, {u.Email, searchEmailWeight}} {
+		for _, word := range searchTokens(f.text) {
+			runes := []rune(word)
+			for n := 1; n <= len(runes); n++ {
+				key := string(runes[:n])
+				best[key] = max(best[key], f.weight*float64(n)/float64(len(runes)))
+			}
+		}
+	}
+	keys := make([]string, 0, len(best))
+	for key, w := range best {
+		if x.postings[key] == nil {
+			x.postings[key] = make(map[ID]float64)
+		}
+		x.postings[key][u.ID] = w
+		keys = append(keys, key)
+	}
+	x.keys[u.ID] = keys
+}
+
+// remove drops the user with id from the index
+func (x *searchIndex) remove(id ID) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.removeLocked(id)
+}
+
+func (x *searchIndex) removeLocked(id ID) {
+	for _, key := range x.keys[id] {
+		delete(x.postings[key], id)
+		if len(x.postings[key]) == 0 {
+			delete(x.postings, key)
+		}
+	}
+	delete(x.keys, id)
+}
+
+// reset replaces the whole index with users
+func (x *searchIndex) reset(users []User) {
+	x.mu.Lock()
+	x.postings = make(map[string]map[ID]float64)
+	x.keys = make(map[ID][]string)
+	x.mu.Unlock()
+	for _, u := range users {
+		x.add(u)
+	}
+}
+
+// searchMatch is a user ID matching a search, with its score
+type searchMatch struct {
+	id    ID
+	score float64
+}
+
+// search returns the users having a word starting with every word of
+// query, best first and then by ID. A user's score is the sum of the best
+// weight of each query word.
+func (x *searchIndex) search(query string) []searchMatch {
+	words := searchTokens(query)
+	if len(words) == 0 {
+		return nil
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	// Start from the rarest word, so the intersection stays small
+	slices.SortFunc(words, func(a, b string) int { return cmp.Compare(len(x.postings[a]), len(x.postings[b])) })
+	scores := make(map[ID]float64, len(x.postings[words[0]]))
+	for id, w := range x.postings[words[0]] {
+		scores[id] = w
+	}
+	for _, word := range words[1:] {
+		postings := x.postings[word]
+		for id := range scores {
+			w, ok := postings[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += w
+		}
+	}
+
+	matches := make([]searchMatch, 0, len(scores))
+	for id, score := range scores {
+		matches = append(matches, searchMatch{id, math.Round(score*1000) / 1000})
+	}
+	slices.SortFunc(matches, func(a, b searchMatch) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.id, b.id)
+	})
+	return matches
+}
+
+// SearchUsers searches the users' names and emails with the store's
+// index. Every word of query must start a word of the name or email.
+func (s *MemoryUserStore) SearchUsers(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, min(limit, defaultSearchLimit))
+	for _, m := range s.index.search(query) {
+		if len(results) == limit {
+			break
+		}
+		// Skip users deleted since the search
+		if u, ok, _ := s.Get(ctx, m.id); ok {
+			results = append(results, SearchResult{User: u, Score: m.score})
+		}
+	}
+	return results, nil
+}
+
+// userSearcher returns the Bleve index if configured, or else the user
+// store if it can search, looking through the tracing decorator
+func (s *Server) userSearcher() (UserSearcher, bool) {
+	if s.bleve != nil {
+		return bleveSearcher{s.bleve, s.store}, true
+	}
+	st := s.store
+	if t, ok := st.(tracedUserStore); ok {
+		if _, ok := t.UserStore.(UserSearcher); !ok {
+			return nil, false
+		}
+		return t, true
+	}
+	us, ok := st.(UserSearcher)
+	return us, ok
+}
+
+// HandleSearchUsers handles GET /users/search, matching ?q= against the
+// words of users' names and emails, prefixes included, most relevant
+// first
+func (s *Server) HandleSearchUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if len(searchTokens(query)) == 0 {
+		httpError(w, r, "q must contain a letter or digit", http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxSearchLimit {
+			httpError(w, r, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	searcher, ok := s.userSearcher()
+	if !ok {
+		httpError(w, r, "the user store does not support search", http.StatusNotImplemented)
+		return
+	}
+	if tenant, ok := tenantFromContext(r.Context()); ok {
+		searcher = tenantSearcher{searcher, tenant}
+	}
+
+	results, err := searcher.SearchUsers(r.Context(), query, limit)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, codecFor(r.Context()).searchPage(query, results))
+}
@@ -0,0 +1,127 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSearchTokens(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	got := searchTokens("Alice.Smith@Example.com, Zoë O'Brien 42")
+	want := []string{"alice", "smith", "example", "com", "zoë", "o", "brien", "42"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMemoryUserStoreSearch(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	for _, u := range []User{
+		{Name: "Alice Smith", Email: "alice@example.com"},
+		{Name: "Ali Khan", Email: "ak@example.com"},
+		{Name: "Bob Jones", Email: "bob.alison@example.com"},
+		{Name: "Carol Smith", Email: "carol@example.org"},
+	} {
+		store.Insert(ctx, u, 0, 0)
+	}
+	search := func(q string) []string {
+		results, err := store.SearchUsers(ctx, q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, r := range results {
+			names = append(names, r.User.Name)
+		}
+		return names
+	}
+
+	for _, tt := range []struct {
+		q    string
+		want []string
+	}{
+		// A whole word beats a prefix, and a name beats an email
+		{"ali", []string{"Ali Khan", "Alice Smith", "Bob Jones"}},
+		{"ALICE", []string{"Alice Smith"}},
+		{"smith", []string{"Alice Smith", "Carol Smith"}},
+		// Every word must match
+		{"smith car", []string{"Carol Smith"}},
+		{"example org", []string{"Carol Smith"}},
+		{"smith bob", nil},
+		{"zed", nil},
+		{"...", nil},
+	} {
+		if got := search(tt.q); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.q, tt.want, got)
+		}
+	}
+	if results, _ := store.SearchUsers(ctx, "example", 2); len(results) != 2 || results[0].User.ID != 1 || results[0].Score != results[1].Score {
+		t.Errorf("expected the limit applied and ties ordered by ID, got %+v", results)
+	}
+
+	// The index follows deletions and restores
+	store.Delete(ctx, 2)
+	if got := search("ali"); !slices.Equal(got, []string{"Alice Smith", "Bob Jones"}) {
+		t.Errorf("expected the deleted user gone, got %q", got)
+	}
+	store.restore([]snapshotUser{{User: User{ID: 7, Name: "Dave Ali", Email: "dave@example.com"}}})
+	if got := search("ali"); !slices.Equal(got, []string{"Dave Ali"}) {
+		t.Errorf("expected the index rebuilt from the snapshot, got %q", got)
+	}
+	if len(store.index.keys) != 1 {
+		t.Errorf("expected only the restored user indexed, got %d", len(store.index.keys))
+	}
+	store.Delete(ctx, 7)
+	if len(store.index.postings) != 0 || len(store.index.keys) != 0 {
+		t.Errorf("expected an empty index once every user is gone, got %d postings", len(store.index.postings))
+	}
+}
+
+func TestHandleSearchUsers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	routes := NewServer().Routes()
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	for _, body := range []string{`{"name":"Alice","email":"alice@test.com"}`, `{"name":"Alicia","email":"alicia@test.com"}`} {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+	}
+
+	w := do("/users/search?q=alic&limit=1")
+	var page searchPage
+	json.NewDecoder(w.Body).Decode(&page)
+	if w.Code != http.StatusOK || page.Query != "alic" || len(page.Results) != 1 || page.Results[0].User.Name != "Alice" {
+		t.Errorf("expected Alice, the closer match, first, got %d: %+v", w.Code, page)
+	}
+	if w := do("/users/search?q=nobody"); w.Code != http.StatusOK || w.Body.String() != `{"query":"nobody","results":[]}`+"\n" {
+		t.Errorf("expected an empty list, got %d: %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/users/search", "/users/search?q=+-+", "/users/search?q=a&limit=0", "/users/search?q=a&limit=101"} {
+		if w := do(path); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+
+	// Stores without an index can't search
+	plain := struct{ UserStore }{NewMemoryUserStore()}
+	w = httptest.NewRecorder()
+	NewServer(WithUserStore(plain)).Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/search?q=a", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", w.Code)
+	}
+}
@@ -0,0 +1,125 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Seed is a set of users to load into a store, e.g.
+//
+//	{"users": [
+//	  {"name": "Alice", "email": "alice@example.com", "role": "admin", "password": "correct horse"},
+//	  {"name": "Bob", "email": "bob@example.com", "tenant": "acme", "locale": "de-CH"}
+//	]}
+//
+// The same file seeds a server at startup with -seed, a running server
+// with `quickserve seed`, and test stores with fixtures.Populate.
+type Seed struct {
+	Users []SeedUser `json:"users"`
+}
+
+// SeedUser is a user in a seed file. Key is optional and only names the
+// user for tests.
+type SeedUser struct {
+	Key      string `json:"key,omitempty"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Role     Role   `json:"role,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// User returns the user to insert, with its password hashed
+func (su SeedUser) User() (User, error) {
+	u := User{Name: su.Name, Email: su.Email, Role: su.Role, Tenant: su.Tenant, Locale: su.Locale}
+	if su.Password != "" {
+		if err := u.SetPassword(su.Password); err != nil {
+			return User{}, err
+		}
+	}
+	return u, nil
+}
+
+// LoadSeed reads a seed file
+func LoadSeed(path string) (*Seed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seed, err := ParseSeed(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return seed, nil
+}
+
+// ParseSeed decodes and checks a seed. Unknown fields are refused so typos
+// don't silently drop data, and so is anything POST /users would refuse,
+// so a seed fails as a whole before any user is created. Locales are
+// normalized.
+func ParseSeed(r io.Reader) (*Seed, error) {
+	var seed Seed
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&seed); err != nil {
+		return nil, err
+	}
+
+	emails := make(map[string]bool, len(seed.Users))
+	for i := range seed.Users {
+		su := &seed.Users[i]
+		var err error
+		switch {
+		case su.Name == "" || su.Email == "":
+			err = errors.New("name and email are required")
+		case emails[su.Email]:
+			err = errors.New("duplicate email")
+		case su.Role != "" && !su.Role.Valid():
+			err = fmt.Errorf("invalid role %q", su.Role)
+		case su.Password != "" && (len(su.Password) < minPasswordLength || len(su.Password) > 72):
+			err = errPasswordLength
+		default:
+			su.Locale, err = parseLocale(su.Locale)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("user %d (%s): %w", i+1, su.Email, err)
+		}
+		emails[su.Email] = true
+	}
+	return &seed, nil
+}
+
+// seedUsers creates the seed's users within the user limits, skipping
+// those whose email is already taken so seeding again at every start is
+// harmless. It returns how many it created.
+func (s *Server) seedUsers(ctx context.Context, seed *Seed) (int, error) {
+	created := 0
+	for _, su := range seed.Users {
+		u, err := su.User()
+		if err != nil {
+			return created, fmt.Errorf("seed user %s: %w", su.Email, err)
+		}
+		user, err := s.createUser(ctx, u)
+		if errors.Is(err, ErrEmailTaken) {
+			continue
+		}
+		if err != nil {
+			return created, fmt.Errorf("seed user %s: %w", su.Email, err)
+		}
+		s.recordChange(ctx, AuditCreate, "user", user.ID.String(), nil, user)
+		created++
+	}
+	return created, nil
+}
+
+// Seed creates the seed's users that don't exist yet, by email, within
+// the user limits, and returns how many it created
+func (e *Embedded) Seed(ctx context.Context, seed *Seed) (int, error) {
+	return e.server.seedUsers(ctx, seed)
+}
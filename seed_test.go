@@ -0,0 +1,88 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestParseSeed(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	seed, err := ParseSeed(strings.NewReader(`{"users":[{"name":"Alice","email":"alice@test.com","locale":"de-ch"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seed.Users) != 1 || seed.Users[0].Locale != "de-CH" {
+		t.Errorf("expected one user with a normalized locale, got %+v", seed.Users)
+	}
+
+	for _, tt := range []struct{ name, in, want string }{
+		{"unknown field", `{"users":[{"name":"A","email":"a@test.com","nickname":"a"}]}`, "unknown field"},
+		{"missing email", `{"users":[{"name":"A"}]}`, "name and email are required"},
+		{"duplicate email", `{"users":[{"name":"A","email":"a@test.com"},{"name":"B","email":"a@test.com"}]}`, "user 2 (a@test.com): duplicate email"},
+		{"invalid role", `{"users":[{"name":"A","email":"a@test.com","role":"owner"}]}`, "invalid role"},
+		{"short password", `{"users":[{"name":"A","email":"a@test.com","password":"short"}]}`, "password must be"},
+		{"invalid locale", `{"users":[{"name":"A","email":"a@test.com","locale":"!!"}]}`, "invalid locale"},
+	} {
+		if _, err := ParseSeed(strings.NewReader(tt.in)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestSeedUsers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	e, err := NewEmbedded(WithMaxUsers(0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	ctx := context.Background()
+	addUser(t, e.server, User{Name: "Existing", Email: "bob@test.com"})
+
+	seed := &Seed{Users: []SeedUser{
+		{Name: "Alice", Email: "alice@test.com", Role: RoleAdmin, Tenant: "acme"},
+		{Name: "Bob", Email: "bob@test.com"},
+	}}
+	if n, err := e.Seed(ctx, seed); err != nil || n != 1 {
+		t.Fatalf("expected Alice created and Bob skipped, got %d, %v", n, err)
+	}
+	if n, err := e.Seed(ctx, seed); err != nil || n != 0 {
+		t.Errorf("expected seeding again to create nothing, got %d, %v", n, err)
+	}
+	alice, ok, _ := e.Users().FindByEmail(ctx, "alice@test.com")
+	if !ok || alice.Role != RoleAdmin || alice.Tenant != "acme" {
+		t.Errorf("expected Alice as seeded, got %+v", alice)
+	}
+
+	// Seeds respect the user limits
+	_, err = e.Seed(ctx, &Seed{Users: []SeedUser{{Name: "Carol", Email: "carol@test.com", Tenant: "acme"}}})
+	var quota *QuotaError
+	if !errors.As(err, &quota) {
+		t.Errorf("expected the tenant limit to apply, got %v", err)
+	}
+}
+
+func TestConfigSeed(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.json"), filepath.Join(dir, "bad.json")
+	os.WriteFile(good, []byte(`{"users":[{"name":"Alice","email":"alice@test.com"}]}`), 0o600)
+	os.WriteFile(bad, []byte(`{"users":[{"name":"Alice"}]}`), 0o600)
+
+	cfg, err := LoadConfig([]string{"-seed", good}, nil)
+	if err != nil || cfg.Store.Seed != good {
+		t.Errorf("expected the seed file, got %q, %v", cfg.Store.Seed, err)
+	}
+	if _, err := LoadConfig(nil, []string{"QUICKSERVE_SEED=" + bad}); err == nil || !strings.Contains(err.Error(), "seed:") {
+		t.Errorf("expected an invalid seed to fail validation, got %v", err)
+	}
+}
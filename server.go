@@ -0,0 +1,1051 @@
+// Package quickserve is a simple REST API with heapcheck integration. It
+// runs as a server (cmd/quickserve) or in-process through Embedded.
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/harshakonda/quickserve/events"
+)
+
+// User represents a user in the system
+type User struct {
+	ID        ID        `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	Locale    string    `json:"locale,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// passwordHash is the bcrypt hash of the user's password, if any
+	passwordHash []byte
+}
+
+// UserStore persists users. Every call takes the request context so a
+// slow backend can give up once the client or the deadline has.
+type UserStore interface {
+	// Insert adds user, assigning its ID and timestamps. If the store
+	// already holds limit users, or tenantLimit users in the user's tenant,
+	// it returns a *QuotaError instead; zero limits are ignored. If the
+	// email is taken it returns ErrEmailTaken.
+	Insert(ctx context.Context, user User, limit, tenantLimit int) (User, error)
+	Get(ctx context.Context, id ID) (User, bool, error)
+	List(ctx context.Context) ([]User, error)
+	FindByEmail(ctx context.Context, email string) (User, bool, error)
+	// Delete removes a user, reporting whether it existed
+	Delete(ctx context.Context, id ID) (bool, error)
+	// Count returns the number of users, in total and in tenant
+	Count(ctx context.Context, tenant string) (total, inTenant int, err error)
+}
+
+// ErrEmailTaken is returned by UserStore.Insert for an email another
+// user already has. Login finds users by email, so it must be unique.
+var ErrEmailTaken = errors.New("email already taken")
+
+// WithUserStore keeps users in st instead of in memory
+func WithUserStore(st UserStore) Option {
+	return func(s *Server) {
+		s.store = st
+	}
+}
+
+// memoryShards is how many independently locked parts a MemoryUserStore
+// spreads its users over, so writes to different users don't wait for
+// each other
+const memoryShards = 32
+
+// MemoryUserStore keeps users in memory; they are lost on restart. Users
+// are sharded by ID, which is assigned in sequence, so consecutive users
+// land on different shards.
+type MemoryUserStore struct {
+	shards []userShard
+	// next is the last ID assigned and total the number of users, with
+	// users being inserted counted as soon as they pass the limit
+	next  atomic.Int64
+	total atomic.Int64
+
+	// tenants counts users per tenant, for tenant limits, and byTenant
+	// holds the IDs of each tenant's users, the default tenant's under ""
+	tenantMu sync.Mutex
+	tenants  map[string]int
+	byTenant map[string]map[ID]struct{}
+	// emails holds the users of each email in each scope, see emailScope;
+	// an ID of 0 holds an email for a user still being inserted
+	emails map[string]map[string]ID
+	// tenantEmails makes emails unique per tenant rather than store-wide
+	tenantEmails bool
+
+	// index finds users by the words of their names and emails
+	index *searchIndex
+
+	clock  Clock
+	logger *slog.Logger
+}
+
+// userShard is one lock's share of the users. It is padded to a cache
+// line so neighboring shards' locks don't contend.
+type userShard struct {
+	mu    sync.RWMutex
+	users map[ID]User
+	_     [32]byte
+}
+
+// NewMemoryUserStore creates an empty in-memory user store
+func NewMemoryUserStore() *MemoryUserStore {
+	return newMemoryUserStore(memoryShards)
+}
+
+// newMemoryUserStore creates a store with n shards, a power of two
+func newMemoryUserStore(n int) *MemoryUserStore {
+	s := &MemoryUserStore{
+		shards:   make([]userShard, n),
+		tenants:  make(map[string]int),
+		byTenant: make(map[string]map[ID]struct{}),
+		emails:   make(map[string]map[string]ID),
+		index:    newSearchIndex(),
+		clock:    SystemClock{},
+		logger:   slog.Default(),
+	}
+	for i := range s.shards {
+		s.shards[i].users = make(map[ID]User)
+	}
+	return s
+}
+
+// shard returns the shard holding the user with id
+func (s *MemoryUserStore) shard(id ID) *userShard {
+	return &s.shards[uint64(id)&uint64(len(s.shards)-1)]
+}
+
+func (s *MemoryUserStore) Insert(ctx context.Context, user User, limit, tenantLimit int) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	// The user is counted before it is stored, so concurrent inserts
+	// can't all pass the limit
+	if n := s.total.Add(1); limit > 0 && n > int64(limit) {
+		s.total.Add(-1)
+		return User{}, &QuotaError{Resource: "users", Scope: "server", Limit: limit, Used: int(n - 1)}
+	}
+	// The email is held under the same lock it is checked, so concurrent
+	// inserts can't both take it
+	s.tenantMu.Lock()
+	if _, taken := s.emails[s.emailScope(user)][user.Email]; taken && user.Email != "" {
+		s.tenantMu.Unlock()
+		s.total.Add(-1)
+		return User{}, ErrEmailTaken
+	}
+	if user.Tenant != "" {
+		n := s.tenants[user.Tenant]
+		if tenantLimit > 0 && n >= tenantLimit {
+			s.tenantMu.Unlock()
+			s.total.Add(-1)
+			return User{}, &QuotaError{Resource: "users", Scope: "tenant", Tenant: user.Tenant, Limit: tenantLimit, Used: n}
+		}
+		s.tenants[user.Tenant] = n + 1
+	}
+	s.setEmail(User{Email: user.Email, Tenant: user.Tenant})
+	s.tenantMu.Unlock()
+
+	now := s.clock.Now()
+	user.ID = ID(s.next.Add(1))
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	sh := s.shard(user.ID)
+	sh.mu.Lock()
+	sh.users[user.ID] = user
+	sh.mu.Unlock()
+	s.tenantMu.Lock()
+	s.addToTenant(user)
+	s.tenantMu.Unlock()
+	s.index.add(user)
+	s.logger.DebugContext(ctx, "user created", "user_id", user.ID)
+	return user, nil
+}
+
+func (s *MemoryUserStore) Count(ctx context.Context, tenant string) (total, inTenant int, err error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	if tenant != "" {
+		s.tenantMu.Lock()
+		inTenant = s.tenants[tenant]
+		s.tenantMu.Unlock()
+	}
+	return int(s.total.Load()), inTenant, nil
+}
+
+func (s *MemoryUserStore) Get(ctx context.Context, id ID) (User, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, false, err
+	}
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	user, ok := sh.users[id]
+	return user, ok, nil
+}
+
+func (s *MemoryUserStore) List(ctx context.Context) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	users := make([]User, 0, s.total.Load())
+	s.each(func(u User) bool {
+		users = append(users, u)
+		return true
+	})
+	slices.SortFunc(users, func(a, b User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+func (s *MemoryUserStore) FindByEmail(ctx context.Context, email string) (User, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, false, err
+	}
+
+	var found User
+	var ok bool
+	s.each(func(u User) bool {
+		found, ok = u, u.Email == email
+		return !ok
+	})
+	if !ok {
+		return User{}, false, nil
+	}
+	return found, true, nil
+}
+
+// addToTenant adds u to its tenant's partition and its email to the
+// index; tenantMu must be held
+func (s *MemoryUserStore) addToTenant(u User) {
+	if s.byTenant[u.Tenant] == nil {
+		s.byTenant[u.Tenant] = make(map[ID]struct{})
+	}
+	s.byTenant[u.Tenant][u.ID] = struct{}{}
+	s.setEmail(u)
+}
+
+// emailScope returns the scope u's email must be unique in: its tenant's
+// if the store keeps emails per tenant, otherwise the whole store's
+func (s *MemoryUserStore) emailScope(u User) string {
+	if s.tenantEmails {
+		return u.Tenant
+	}
+	return ""
+}
+
+// setEmail records u as the user of its email; tenantMu must be held
+func (s *MemoryUserStore) setEmail(u User) {
+	if u.Email == "" {
+		return
+	}
+	scope := s.emailScope(u)
+	if s.emails[scope] == nil {
+		s.emails[scope] = make(map[string]ID)
+	}
+	s.emails[scope][u.Email] = u.ID
+}
+
+// removeEmail frees u's email unless another user has it; tenantMu must
+// be held
+func (s *MemoryUserStore) removeEmail(u User) {
+	scope := s.emailScope(u)
+	if id, ok := s.emails[scope][u.Email]; ok && id == u.ID {
+		delete(s.emails[scope], u.Email)
+		if len(s.emails[scope]) == 0 {
+			delete(s.emails, scope)
+		}
+	}
+}
+
+// each calls f with every user, a shard at a time, until f returns false
+func (s *MemoryUserStore) each(f func(User) bool) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.RLock()
+		for _, u := range sh.users {
+			if !f(u) {
+				sh.mu.RUnlock()
+				return
+			}
+		}
+		sh.mu.RUnlock()
+	}
+}
+
+func (s *MemoryUserStore) Delete(ctx context.Context, id ID) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	sh := s.shard(id)
+	sh.mu.Lock()
+	user, ok := sh.users[id]
+	delete(sh.users, id)
+	sh.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	s.index.remove(id)
+
+	s.total.Add(-1)
+	s.tenantMu.Lock()
+	if user.Tenant != "" {
+		if s.tenants[user.Tenant]--; s.tenants[user.Tenant] <= 0 {
+			delete(s.tenants, user.Tenant)
+		}
+	}
+	delete(s.byTenant[user.Tenant], id)
+	if len(s.byTenant[user.Tenant]) == 0 {
+		delete(s.byTenant, user.Tenant)
+	}
+	s.removeEmail(user)
+	s.tenantMu.Unlock()
+	s.logger.DebugContext(ctx, "user deleted", "user_id", id)
+	return true, nil
+}
+
+// writeStoreError answers a failed store call. A call cut short by the
+// request context gets no answer of its own: the deadline middleware has
+// already replied, or the client has gone.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	httpError(w, r, "internal error", http.StatusInternalServerError)
+}
+
+// Server holds the HTTP server dependencies
+type Server struct {
+	store   UserStore
+	apiKeys *APIKeyStore
+	orgs    *OrgStore
+
+	sessions     SessionStore
+	sessionTTL   time.Duration
+	sessionIdle  time.Duration
+	loginLimiter *RateLimiter
+
+	invitations *InvitationStore
+	mailer      Mailer
+	inviteURL   string
+
+	notifications *NotificationStore
+	translator    *Translator
+
+	posts *PostStore
+	// postsOnUserDelete decides whether deleting a user deletes their
+	// posts or is refused while they have any
+	postsOnUserDelete PostsOnUserDelete
+
+	// tenancy isolates tenants from each other when set
+	tenancy *Tenancy
+
+	signup        *SignupConfig
+	signupLimiter *RateLimiter
+
+	audit  *AuditLog
+	logger *slog.Logger
+
+	accessLog     bool
+	accessLogSkip []string
+
+	avatars avatarCache
+	clock   Clock
+
+	apiKeyAuth   bool
+	bootstrapKey string
+	idFormat     IDFormat
+
+	timePrecision time.Duration
+
+	adminAuth bool
+	adminUser [32]byte
+	adminPass [32]byte
+
+	oidc *OIDCProvider
+
+	clientCAs *x509.CertPool
+
+	modules []Module
+
+	maxDeadline    time.Duration
+	handlerTimeout time.Duration
+
+	maxBody      int64
+	routeMaxBody map[string]int64
+
+	// docs mounts the API explorer
+	docs bool
+
+	// liveMu guards the settings Reload changes: rateLimit, ipAccess and
+	// the level of logLevel
+	liveMu       sync.RWMutex
+	logLevel     *slog.LevelVar
+	reloadSource func() (LiveSettings, error)
+
+	rateLimit *RateLimit
+	limiter   *RateLimiter
+
+	// inFlight holds a slot per request handled when concurrency is
+	// limited
+	inFlight     chan struct{}
+	queueTimeout time.Duration
+	// shedder rejects requests by priority when the server is saturated
+	shedder       *loadShedder
+	routePriority map[string]Priority
+	// leaks watches goroutine and heap growth while Run runs
+	leaks *leakMonitor
+	// bleve answers searches instead of the user store when set
+	bleve *BleveIndex
+
+	// cache holds responses of hot reads when enabled
+	cache *responseCache
+	// userChanges is the modification time of user lists
+	userChanges changeTracker
+	// cacheControl maps route patterns and modules to their Cache-Control
+	cacheControl map[string]string
+
+	quota Quota
+	usage *UsageTracker
+
+	routes *RouteRegistry
+	// spec describes routes so requests can be validated against it
+	spec *OpenAPI
+	// mux serves the routes alone, for JSON-RPC calls to dispatch to
+	mux http.Handler
+
+	csrfGroups map[string]bool
+	signer     *requestSigner
+
+	ipAccess       map[string]IPAccess
+	trustedProxies []netip.Prefix
+
+	load         *loadTracker
+	loadCapacity int
+
+	tenantSettings *TenantSettingsStore
+	tenants        *TenantStore
+	features       map[string]bool
+	webhookLimit   int
+	retention      time.Duration
+	maxUsers       int
+	tenantMaxUsers int
+	capacity       *capacityAlerts
+	driftReads     bool
+
+	deprecations     map[string][]Deprecation
+	deprecationUsage *DeprecationTracker
+	webhookClient    *http.Client
+
+	// webhooks receive user events from a pool of webhookWorkers
+	webhooks       *WebhookStore
+	webhookPool    *webhookPool
+	webhookWorkers int
+	webhookBackoff time.Duration
+
+	replication *replication
+
+	kafka *KafkaPublisher
+	nats  *NATSTransport
+
+	tracer *Tracer
+
+	// events carries user changes to webhooks and to WebSocket and SSE
+	// clients
+	events    *events.Bus[UserEvent]
+	keepalive time.Duration
+	// streamsDone is closed to end event streams; see endStreams
+	streamsDone chan struct{}
+	streamsOnce sync.Once
+
+	readiness []namedCheck
+
+	// tasks owns every background goroutine; see Run
+	tasks   *taskGroup
+	running atomic.Bool
+
+	draining atomic.Bool
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithAPIKeyAuth requires a valid X-API-Key on user routes and mounts the
+// key management endpoints under /admin/keys. A non-empty bootstrap secret
+// is registered with the admin role so the first real keys can be issued.
+func WithAPIKeyAuth(bootstrap string) Option {
+	return func(s *Server) {
+		s.apiKeyAuth = true
+		s.bootstrapKey = bootstrap
+	}
+}
+
+// NewServer creates a new server
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		store:   NewMemoryUserStore(),
+		apiKeys: NewAPIKeyStore(),
+		orgs:    NewOrgStore(),
+
+		sessions:    NewMemorySessionStore(),
+		sessionTTL:  defaultSessionTTL,
+		sessionIdle: defaultSessionIdleTimeout,
+
+		invitations:   NewInvitationStore(),
+		mailer:        logMailer{slog.Default()},
+		notifications: NewNotificationStore(),
+		translator:    NewTranslator(language.English),
+		audit:         NewAuditLog(),
+		clock:         SystemClock{},
+		logger:        slog.Default(),
+
+		posts:             NewPostStore(),
+		postsOnUserDelete: PostsCascade,
+
+		maxDeadline:  defaultMaxDeadline,
+		maxBody:      defaultMaxBody,
+		loadCapacity: defaultLoadCapacity,
+
+		tenantSettings: NewTenantSettingsStore(),
+		tenants:        NewTenantStore(),
+		webhookLimit:   defaultWebhookLimit,
+
+		driftReads: true,
+
+		deprecations:     deprecationsFrom(changelog),
+		deprecationUsage: NewDeprecationTracker(),
+		webhookClient:    http.DefaultClient,
+
+		webhooks:       NewWebhookStore(),
+		webhookPool:    newWebhookPool(),
+		webhookWorkers: defaultWebhookWorkers,
+		webhookBackoff: defaultWebhookBackoff,
+
+		events:      events.NewBus[UserEvent](eventBuffer),
+		streamsDone: make(chan struct{}),
+
+		tasks: newTaskGroup(context.Background()),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if st, ok := s.store.(*MemoryUserStore); ok {
+		st.clock = s.clock
+		st.logger = s.componentLogger("store")
+		// Without tenancy login looks users up across tenants
+		st.tenantEmails = s.tenancy != nil
+	}
+	if s.tracer != nil {
+		s.tracer.logger = s.componentLogger("tracing")
+		s.store = tracedUserStore{s.store, s.tracer}
+	}
+	if m, ok := s.mailer.(logMailer); ok {
+		m.logger = s.componentLogger("mailer")
+		s.mailer = m
+	}
+	s.apiKeys.clock = s.clock
+	// Imported once the clock is final so the key's timestamps use it
+	if s.bootstrapKey != "" {
+		s.apiKeys.Import("bootstrap", s.bootstrapKey, RoleAdmin)
+	}
+	s.orgs.clock = s.clock
+	s.posts.clock = s.clock
+	s.tenants.clock = s.clock
+	s.invitations.clock = s.clock
+	s.capacity = newCapacityAlerts(s.componentLogger("capacity"))
+	s.deprecationUsage.clock = s.clock
+	s.webhooks.clock = s.clock
+	s.userChanges.at = s.clock.Now()
+	s.events.Encode(s.encodeEvent)
+	s.events.Handle(s.userChanges.handleEvent)
+	if s.cache != nil {
+		s.events.Handle(s.cache.handleEvent)
+	}
+	s.events.Handle(s.dispatchWebhooks)
+	if s.kafka != nil {
+		s.kafka.render = s.renderOptionsFor(nil)
+		s.kafka.clock = s.clock
+		s.events.Handle(s.kafka.publish)
+	}
+	if s.nats != nil {
+		s.nats.render = s.renderOptionsFor(nil)
+		s.events.Handle(s.nats.publish)
+		s.nats.relay = s.events.Relay
+	}
+	if s.bleve != nil {
+		s.bleve.logger = s.componentLogger("search")
+		s.events.Handle(s.bleve.handleEvent)
+	}
+	if s.oidc != nil {
+		s.oidc.clock = s.clock
+	}
+	// The limiter always exists since tenants may override an unset limit
+	var limit RateLimit
+	if s.rateLimit != nil {
+		limit = *s.rateLimit
+	}
+	s.limiter = NewRateLimiter(limit, s.clock)
+	s.signupLimiter = NewRateLimiter(RateLimit{}, s.clock)
+	s.loginLimiter = NewRateLimiter(loginThrottle, s.clock)
+	s.usage = NewUsageTracker(s.clock)
+	s.load = newLoadTracker(s.clock)
+	return s
+}
+
+// HandleListUsers handles GET /users. Every user is listed unless
+// ?limit= or ?cursor= asks for a page, continued with ?cursor= set to the
+// previous page's next_cursor; ?sort= orders either.
+func (s *Server) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	order := SortByID
+	if v := q.Get("sort"); v != "" {
+		if order = UserSort(v); !order.Valid() {
+			httpError(w, r, "sort must be id, name or created_at", http.StatusBadRequest)
+			return
+		}
+	}
+	var after *User
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		var pos User
+		if err == nil {
+			pos, err = c.position()
+		}
+		if err != nil {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		// The cursor keeps its order, which ?sort= may only repeat
+		if q.Has("sort") && c.Sort != order {
+			httpError(w, r, "cursor is for another sort", http.StatusBadRequest)
+			return
+		}
+		order, after = c.Sort, &pos
+	}
+	var limit int
+	if v := q.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxUsersLimit {
+			httpError(w, r, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+	} else if after != nil {
+		limit = defaultUsersLimit
+	}
+
+	codec := codecFor(r.Context())
+	s.writeCachedJSON(w, r, usersCacheTag, func() (any, time.Time) {
+		// Taken first, so a change made while listing makes the list look
+		// older rather than newer than it is
+		modified := s.userChanges.modified()
+		if limit == 0 {
+			users, err := s.users(r.Context()).List(r.Context())
+			if err != nil {
+				writeStoreError(w, r, err)
+				return nil, time.Time{}
+			}
+			if order != SortByID {
+				slices.SortFunc(users, order.Compare)
+			}
+			return codec.users(users), modified
+		}
+
+		// One more than the page, to tell whether there is another
+		users, err := listAfter(r.Context(), s.users(r.Context()), order, after, limit+1)
+		if errors.Is(err, errInvalidCursor) {
+			httpError(w, r, "invalid cursor", http.StatusBadRequest)
+			return nil, time.Time{}
+		}
+		if err != nil {
+			writeStoreError(w, r, err)
+			return nil, time.Time{}
+		}
+		var next string
+		if len(users) > limit {
+			users = users[:limit]
+			next = encodeCursor(order, users[limit-1])
+		}
+		return usersPage{Users: codec.users(users), NextCursor: next}, modified
+	})
+}
+
+// HandleGetUser handles GET /users/{id}
+func (s *Server) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := ParseID(idStr)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	s.writeCachedJSON(w, r, userCacheTag(id), func() (any, time.Time) {
+		user, ok, err := s.users(r.Context()).Get(r.Context(), id)
+		if err != nil {
+			writeStoreError(w, r, err)
+			return nil, time.Time{}
+		}
+		if !ok {
+			httpError(w, r, "user not found", http.StatusNotFound)
+			return nil, time.Time{}
+		}
+		return codecFor(r.Context()).user(user), user.UpdatedAt
+	})
+}
+
+// createUserRequest is the body of POST /users
+type createUserRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Role     Role   `json:"role,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// HandleCreateUser handles POST /users
+func (s *Server) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
+	codec := codecFor(r.Context())
+	req, err := codec.createRequest(r)
+	if err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != "" && !req.Role.Valid() {
+		httpError(w, r, "invalid role", http.StatusBadRequest)
+		return
+	}
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, ok := optionalPassword(w, r, req.Password)
+	if !ok {
+		return
+	}
+
+	// Don't start a write the client has already given up on
+	if r.Context().Err() != nil {
+		return
+	}
+
+	user, err := s.createUser(r.Context(), User{
+		Name: req.Name, Email: req.Email, Role: req.Role, Locale: locale, Tenant: s.tenantFromRequest(r), passwordHash: hash,
+	})
+	if err != nil {
+		writeCreateError(w, r, err)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "user", user.ID.String(), nil, user)
+
+	s.writeJSON(w, r, http.StatusCreated, codec.user(user))
+}
+
+// HandleDeleteUser handles DELETE /users/{id}
+func (s *Server) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := ParseID(idStr)
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if r.Context().Err() != nil {
+		return
+	}
+
+	users := s.users(r.Context())
+	user, ok, err := users.Get(r.Context(), id)
+	if err == nil && ok {
+		if s.postsOnUserDelete == PostsBlock && s.posts.Count(id) > 0 {
+			httpError(w, r, "user has posts; delete them first", http.StatusConflict)
+			return
+		}
+		ok, err = users.Delete(r.Context(), id)
+	}
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return
+	}
+	s.deleteUserData(r.Context(), id)
+	s.recordAudit(r, AuditDelete, "user", id.String(), user, nil)
+	s.checkUserCapacity(r.Context(), user.Tenant)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// group creates a route group for module, adding the middleware that is
+// configured per group on top of mw
+func (s *Server) group(rr *RouteRegistry, module string, mw ...func(http.Handler) http.Handler) *RouteGroup {
+	// Admin routes work across tenants
+	if module != "admin" {
+		// After authentication, which may scope the request to the
+		// caller's tenant
+		mw = append(mw, s.checkTenant)
+		// Before authentication, which checks the tenant against the
+		// caller's
+		if s.tenancy != nil {
+			mw = append([]func(http.Handler) http.Handler{s.scopeTenant}, mw...)
+		}
+	}
+	// Address checks come first so blocked clients learn nothing more,
+	// then shedding so nothing is done for requests that won't be served,
+	// then the body limit so nothing reads more than it allows
+	mw = append([]func(http.Handler) http.Handler{s.ipFilter(module), s.shedLoad, s.applyCacheControl, s.limitBody}, mw...)
+	// Before anything that may answer, so every request span has its route
+	if s.tracer != nil {
+		mw = append([]func(http.Handler) http.Handler{s.nameSpan}, mw...)
+	}
+	if s.csrfGroups[module] {
+		mw = append(mw, s.csrfProtect)
+	}
+	if s.signer != nil && s.signer.groups[module] {
+		mw = append(mw, s.verifySignature)
+	}
+	// After authentication, so clients without access learn nothing
+	// about the schema
+	mw = append(mw, s.validateRequest)
+	// Last, so usage is only counted for requests that were let through
+	mw = append(mw, s.trackDeprecated)
+	return rr.Group(module, mw...)
+}
+
+// registerUserRoutes adds the user routes to g, their paths starting
+// with prefix, documented with the users of c
+func (s *Server) registerUserRoutes(g *RouteGroup, prefix string, c userCodec) {
+	usersLimit := integerBetween(1, maxUsersLimit)
+	usersLimit.Description = "Users per page; with it or cursor the response is a page of users and its next_cursor"
+	// Listing everyone is the most expensive read, and bulk consumers can
+	// retry it
+	g.HandleFunc("GET "+prefix+"/users", s.HandleListUsers, WithResponseSchema(http.StatusOK, c.users(nil)), WithPriority(PriorityLow),
+		WithQueryParam("limit", usersLimit),
+		WithQueryParam("cursor", &Schema{Type: "string", Description: "The next_cursor of the previous page"}),
+		WithQueryParam("sort", &Schema{Type: "string", Enum: []string{string(SortByID), string(SortByName), string(SortByCreatedAt)}}))
+	g.HandleFunc("GET "+prefix+"/users/{id}", s.HandleGetUser, WithResponseSchema(http.StatusOK, c.user(User{})))
+	g.HandleFunc("GET "+prefix+"/users/search", s.HandleSearchUsers, WithResponseSchema(http.StatusOK, c.searchPage("", nil)),
+		WithQueryParam("q", &Schema{Type: "string", Description: "Words matched against the start of the words of names and emails"}),
+		WithQueryParam("limit", integerBetween(1, maxSearchLimit)))
+	g.HandleFunc("GET "+prefix+"/users/{id}/avatar", s.HandleGetAvatar,
+		WithQueryParam("size", integerBetween(minAvatarSize, maxAvatarSize)),
+		WithQueryParam("style", &Schema{Type: "string", Enum: []string{"identicon", "initials"}}))
+	g.HandleFunc("GET "+prefix+"/users/changes", s.HandleUserChanges, WithResponseSchema(http.StatusOK, changesPage{}), WithPriority(PriorityLow),
+		WithQueryParam("since", &Schema{Type: "integer", Format: "int64", Description: "Sequence number of the last change already seen; 0 for all"}),
+		WithQueryParam("limit", integerBetween(1, maxChangesLimit)))
+	g.HandleFunc("GET "+prefix+"/users/{id}/activity", s.HandleUserActivity, WithResponseSchema(http.StatusOK, activityPage{}),
+		WithQueryParam("limit", integerBetween(1, maxActivityLimit)),
+		WithQueryParam("before", &Schema{Type: "integer", Format: "int64"}))
+	g.HandleFunc("GET "+prefix+"/users/{id}/notifications", s.HandleGetNotifications, WithResponseSchema(http.StatusOK, NotificationPrefs{}))
+	g.HandleFunc("PUT "+prefix+"/users/{id}/notifications", s.HandlePutNotifications,
+		WithRequestSchema(notificationPrefsUpdate{}), WithResponseSchema(http.StatusOK, NotificationPrefs{}))
+	g.HandleFunc("GET "+prefix+"/users/{id}/posts", s.HandleListPosts, WithResponseSchema(http.StatusOK, []Post{}))
+	g.HandleFunc("POST "+prefix+"/users/{id}/posts", s.HandleCreatePost,
+		WithRequestSchema(postRequest{}), WithResponseSchema(http.StatusCreated, Post{}))
+	g.HandleFunc("GET "+prefix+"/users/{id}/posts/{post}", s.HandleGetPost, WithResponseSchema(http.StatusOK, Post{}))
+	g.HandleFunc("PUT "+prefix+"/users/{id}/posts/{post}", s.HandleUpdatePost,
+		WithRequestSchema(postRequest{}), WithResponseSchema(http.StatusOK, Post{}))
+	g.HandleFunc("DELETE "+prefix+"/users/{id}/posts/{post}", s.HandleDeletePost, WithResponseSchema(http.StatusNoContent, nil))
+	g.HandleFunc("POST "+prefix+"/users", s.HandleCreateUser,
+		WithRequestSchema(c.createBody()), WithResponseSchema(http.StatusCreated, c.user(User{})))
+	g.HandleFunc("DELETE "+prefix+"/users/{id}", s.HandleDeleteUser, WithResponseSchema(http.StatusNoContent, nil))
+}
+
+// registerRoutes adds the built-in routes and those of every module
+func (s *Server) registerRoutes(rr *RouteRegistry) {
+	var auth []func(http.Handler) http.Handler
+	if s.authRequired() {
+		auth = append(auth, s.requireAuth, s.authorize, s.enforceQuota)
+	}
+
+	s.registerUserRoutes(s.group(rr, "users", auth...), "", v1Codec{})
+	// The same routes under each version's prefix, with its users
+	for _, v := range apiVersions {
+		s.registerUserRoutes(s.group(rr, "users", append([]func(http.Handler) http.Handler{useCodec(v.codec)}, auth...)...), v.prefix, v.codec)
+	}
+	// The same v1 routes in each tenant's namespace, scoped to the tenant
+	// in the path whatever else names one
+	s.registerUserRoutes(s.group(rr, "users", append([]func(http.Handler) http.Handler{s.pathTenant}, auth...)...), tenantPrefix, v1Codec{})
+
+	invites := s.group(rr, "invitations", auth...)
+	invites.HandleFunc("POST /invitations", s.HandleCreateInvitation)
+
+	// Accepting an invitation is how people without credentials register
+	registration := s.group(rr, "registration")
+	registration.HandleFunc("POST /invitations/accept", s.HandleAcceptInvitation)
+	if s.signup != nil {
+		registration.HandleFunc("POST /signup", s.HandleSignup)
+	}
+
+	login := s.group(rr, "login")
+	login.HandleFunc("POST /login", s.HandleLogin)
+	login.HandleFunc("POST /logout", s.HandleLogout)
+
+	orgs := s.group(rr, "orgs", auth...)
+	orgs.HandleFunc("GET /orgs", s.HandleListOrgs)
+	orgs.HandleFunc("POST /orgs", s.HandleCreateOrg)
+	orgs.HandleFunc("GET /orgs/{org}", s.HandleGetOrg)
+	orgs.HandleFunc("DELETE /orgs/{org}", s.HandleDeleteOrg)
+	orgs.HandleFunc("GET /orgs/{org}/users", s.HandleListOrgUsers)
+	orgs.HandleFunc("GET /orgs/{org}/teams", s.HandleListTeams)
+	orgs.HandleFunc("POST /orgs/{org}/teams", s.HandleCreateTeam)
+	orgs.HandleFunc("DELETE /orgs/{org}/teams/{team}", s.HandleDeleteTeam)
+	orgs.HandleFunc("GET /orgs/{org}/members", s.HandleListMembers)
+	orgs.HandleFunc("PUT /orgs/{org}/members/{user}", s.HandleSetMember)
+	orgs.HandleFunc("DELETE /orgs/{org}/members/{user}", s.HandleRemoveMember)
+	orgs.HandleFunc("GET /orgs/{org}/teams/{team}/members", s.HandleListMembers)
+	orgs.HandleFunc("PUT /orgs/{org}/teams/{team}/members/{user}", s.HandleSetMember)
+	orgs.HandleFunc("DELETE /orgs/{org}/teams/{team}/members/{user}", s.HandleRemoveMember)
+
+	teams := s.group(rr, "teams", auth...)
+	teams.HandleFunc("GET /teams", s.HandleListAllTeams, WithResponseSchema(http.StatusOK, []Team{}))
+	teams.HandleFunc("POST /teams", s.HandleCreateOrgTeam,
+		WithRequestSchema(createTeamRequest{}), WithResponseSchema(http.StatusCreated, Team{}))
+	teams.HandleFunc("GET /teams/{team}", s.HandleGetTeam, WithResponseSchema(http.StatusOK, Team{}))
+	teams.HandleFunc("DELETE /teams/{team}", s.HandleDeleteOrgTeam, WithResponseSchema(http.StatusNoContent, nil))
+	teams.HandleFunc("GET /teams/{team}/members", s.HandleListTeamMembers, WithResponseSchema(http.StatusOK, []Membership{}))
+	teams.HandleFunc("POST /teams/{team}/members", s.HandleAddTeamMember,
+		WithRequestSchema(addTeamMemberRequest{}), WithResponseSchema(http.StatusCreated, Membership{}))
+
+	// The /admin group uses basic auth when configured and otherwise
+	// falls back to API keys. It is not mounted if neither is enabled.
+	if s.adminAuth || s.apiKeyAuth {
+		adminAuth := auth
+		if s.adminAuth {
+			adminAuth = []func(http.Handler) http.Handler{s.requireBasicAuth, s.authorize}
+		}
+
+		admin := s.group(rr, "admin", adminAuth...)
+		admin.HandleFunc("GET /admin/keys", s.HandleListAPIKeys)
+		admin.HandleFunc("POST /admin/keys", s.HandleCreateAPIKey)
+		admin.HandleFunc("DELETE /admin/keys/{id}", s.HandleRevokeAPIKey)
+		admin.HandleFunc("GET /admin/usage", s.HandleGetUsage)
+		admin.HandleFunc("GET /admin/audit", s.HandleListAudit)
+		admin.HandleFunc("GET /admin/routes", s.HandleListRoutes)
+		admin.HandleFunc("GET /admin/deprecations", s.HandleListDeprecations)
+		admin.HandleFunc("GET /admin/schema", s.HandleGetSchema)
+		admin.HandleFunc("GET /admin/cache", s.HandleGetCacheStats, WithResponseSchema(http.StatusOK, CacheStats{}))
+		if s.reloadSource != nil {
+			admin.HandleFunc("POST /admin/reload", s.HandleReload)
+		}
+		if s.replication != nil {
+			admin.HandleFunc("GET /admin/replication", s.HandleGetReplication)
+			admin.HandleFunc("POST /admin/promote", s.HandlePromote)
+		}
+		admin.HandleFunc("GET /admin/invitations", s.HandleListInvitations)
+		admin.HandleFunc("DELETE /admin/invitations/{id}", s.HandleRevokeInvitation)
+		admin.HandleFunc("GET /admin/clock", s.HandleGetClock)
+		admin.HandleFunc("GET /admin/tenants", s.HandleListTenants, WithResponseSchema(http.StatusOK, []Tenant{}))
+		admin.HandleFunc("POST /admin/tenants", s.HandleCreateTenant,
+			WithRequestSchema(createTenantRequest{}), WithResponseSchema(http.StatusCreated, Tenant{}))
+		admin.HandleFunc("GET /admin/tenants/{tenant}", s.HandleGetTenant, WithResponseSchema(http.StatusOK, Tenant{}))
+		admin.HandleFunc("POST /admin/tenants/{tenant}/suspend", s.HandleSuspendTenant, WithResponseSchema(http.StatusOK, Tenant{}))
+		admin.HandleFunc("POST /admin/tenants/{tenant}/resume", s.HandleResumeTenant, WithResponseSchema(http.StatusOK, Tenant{}))
+		admin.HandleFunc("DELETE /admin/tenants/{tenant}", s.HandleDeleteTenant, WithResponseSchema(http.StatusNoContent, nil))
+		admin.HandleFunc("GET /admin/tenants/{tenant}/settings", s.HandleGetTenantSettings)
+		admin.HandleFunc("PUT /admin/tenants/{tenant}/settings", s.HandlePutTenantSettings)
+		admin.HandleFunc("DELETE /admin/tenants/{tenant}/settings", s.HandleDeleteTenantSettings)
+		admin.HandleFunc("GET /admin/webhooks", s.HandleListWebhooks)
+		admin.HandleFunc("POST /admin/webhooks", s.HandleCreateWebhook)
+		admin.HandleFunc("DELETE /admin/webhooks/{id}", s.HandleDeleteWebhook)
+		if _, ok := s.clock.(*SimulatedClock); ok {
+			admin.HandleFunc("POST /admin/clock", s.HandleSetClock)
+		}
+	}
+
+	if s.replication != nil && s.replication.verifier != nil {
+		replica := s.group(rr, "replication", func(next http.Handler) http.Handler {
+			return s.verifySignedBy(s.replication.verifier, next)
+		})
+		replica.HandleFunc("POST "+snapshotPath, s.HandleApplySnapshot, WithMaxBody(maxSnapshotBody))
+	}
+
+	if s.oidc != nil {
+		login := s.group(rr, "oidc")
+		login.HandleFunc("GET /auth/login", s.HandleOIDCLogin)
+		login.HandleFunc("GET /auth/callback", s.HandleOIDCCallback)
+	}
+
+	// Probes are never shed, or an overloaded instance would be restarted
+	health := s.group(rr, "health")
+	critical := WithPriority(PriorityCritical)
+	health.HandleFunc("GET /health", s.HandleLiveness, critical)
+	health.HandleFunc("GET /healthz", s.HandleLiveness, critical)
+	health.HandleFunc("GET /readyz", s.HandleReadiness, WithResponseSchema(http.StatusOK, Readiness{}), critical)
+	health.HandleFunc("GET /health/weight", s.HandleWeight, critical)
+
+	live := s.group(rr, "events", auth...)
+	eventsParam := WithQueryParam("events", &Schema{Type: "string", Description: "Comma-separated event types to receive; all if omitted"})
+	live.HandleFunc("GET "+wsPath, s.HandleWebSocket, eventsParam)
+	live.HandleFunc("GET "+userEventsPath, s.HandleUserEvents, eventsParam)
+
+	// Each call is authenticated and authorized by the user route it
+	// maps to, so the endpoint itself needs neither
+	rpc := s.group(rr, "rpc")
+	rpc.HandleFunc("POST "+rpcPath, s.HandleRPC)
+
+	changes := s.group(rr, "changelog")
+	changes.HandleFunc("GET /changelog", s.HandleChangelog, WithResponseSchema(http.StatusOK, changelogBody{}),
+		WithQueryParam("since", &Schema{Type: "string", Description: "Only list releases newer than this version"}))
+
+	docs := s.group(rr, "openapi")
+	docs.HandleFunc("GET "+openAPIPath, s.HandleOpenAPI)
+	if s.docs {
+		docs.Handle("GET "+explorerPath, handleExplorer())
+	}
+
+	// Module routes are authenticated like user routes
+	for _, m := range s.modules {
+		m.RegisterRoutes(s.group(rr, m.Name(), auth...))
+	}
+}
+
+// Handler builds the HTTP handler for all routes. It fails if modules
+// register conflicting routes, describing each conflict.
+func (s *Server) Handler() (http.Handler, error) {
+	rr := NewRouteRegistry()
+	s.registerRoutes(rr)
+	s.routes = rr
+	spec := s.OpenAPI()
+	s.spec = &spec
+
+	mux := http.NewServeMux()
+	if err := rr.Mount(mux); err != nil {
+		return nil, err
+	}
+	s.mux = mux
+	return withRequestID(s.traceRequests(s.logAccess(withAPIVersion(s.trackLoad(s.limitConcurrency(s.recoverPanics(s.refuseOnDrift(s.refuseOnStandby(s.rateLimited(s.requestDeadline(mux))))))))))), nil
+}
+
+// Routes returns the HTTP handler with all routes. Like http.ServeMux it
+// panics on conflicting registrations; use Handler to get an error.
+func (s *Server) Routes() http.Handler {
+	h, err := s.Handler()
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
@@ -0,0 +1,413 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// addUser inserts user straight into the server's store
+func addUser(t *testing.T, s *Server, user User) User {
+	t.Helper()
+	user, err := s.store.Insert(context.Background(), user, 0, 0)
+	if err != nil {
+		t.Errorf("insert user: %v", err)
+	}
+	return user
+}
+
+func TestUserStoreConcurrent(t *testing.T) {
+	defer guard.VerifyNone(t,
+		guard.MaxGoroutines(10),
+	)
+
+	server := NewServer()
+
+	// Concurrent writes
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func(n int) {
+			addUser(t, server, User{Name: "User", Email: fmt.Sprintf("user%d@test.com", n)})
+			done <- true
+		}(i)
+	}
+
+	// Wait for all
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	users, _ := server.store.List(context.Background())
+	if len(users) != 10 {
+		t.Errorf("expected 10 users, got %d", len(users))
+	}
+}
+
+func TestMemoryUserStoreLimitsConcurrent(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewMemoryUserStore()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			tenant := []string{"acme", "globex"}[n%2]
+			store.Insert(context.Background(), User{Name: "User", Tenant: tenant}, 20, 8)
+		}(i)
+	}
+	wg.Wait()
+
+	total, acme, _ := store.Count(context.Background(), "acme")
+	if _, globex, _ := store.Count(context.Background(), "globex"); total != 16 || acme != 8 || globex != 8 {
+		t.Errorf("expected 8 users in each tenant, got %d of %d and %d", acme, total, globex)
+	}
+	users, _ := store.List(context.Background())
+	for i, u := range users {
+		if u.ID != ID(i+1) {
+			t.Fatalf("expected IDs in sequence, got %d at %d", u.ID, i)
+		}
+	}
+
+	store.Delete(context.Background(), users[0].ID)
+	if _, err := store.Insert(context.Background(), User{Name: "User", Tenant: users[0].Tenant}, 20, 8); err != nil {
+		t.Errorf("expected room in the tenant after a deletion, got %v", err)
+	}
+}
+
+func TestMemoryUserStoreEmailsConcurrent(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	var wg sync.WaitGroup
+	var created, taken atomic.Int32
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Insert(ctx, User{Name: "Alice", Email: "alice@test.com"}, 0, 0)
+			switch {
+			case err == nil:
+				created.Add(1)
+			case errors.Is(err, ErrEmailTaken):
+				taken.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if created.Load() != 1 || taken.Load() != 49 {
+		t.Fatalf("expected one user created and the rest refused, got %d and %d", created.Load(), taken.Load())
+	}
+	if total, _, _ := store.Count(ctx, ""); total != 1 {
+		t.Errorf("expected refused users uncounted, got %d", total)
+	}
+	alice, _, _ := store.FindByEmail(ctx, "alice@test.com")
+	store.Delete(ctx, alice.ID)
+	if _, err := store.Insert(ctx, User{Name: "Alice", Email: "alice@test.com"}, 0, 0); err != nil {
+		t.Errorf("expected the email free after a deletion, got %v", err)
+	}
+}
+
+// BenchmarkMemoryUserStoreConcurrent creates and deletes users from every
+// P at once, comparing one lock with the sharded store
+func BenchmarkMemoryUserStoreConcurrent(b *testing.B) {
+	for _, shards := range []int{1, memoryShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store := newMemoryUserStore(shards)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				ctx := context.Background()
+				for pb.Next() {
+					u, err := store.Insert(ctx, User{Name: "User", Email: "user@test.com"}, 0, 0)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					store.Get(ctx, u.ID)
+					store.Delete(ctx, u.ID)
+				}
+			})
+		})
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+
+	server.Routes().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", w.Code)
+	}
+	if w.Body.String() != "OK" {
+		t.Errorf("expected 'OK', got '%s'", w.Body.String())
+	}
+}
+
+// userStoreDesigns are the stores the BenchmarkUserStore benchmarks
+// compare; add an alternative design here to measure it against the rest
+var userStoreDesigns = []struct {
+	name string
+	new  func() UserStore
+}{
+	{"memory", func() UserStore { return NewMemoryUserStore() }},
+	{"memory-unsharded", func() UserStore { return newMemoryUserStore(1) }},
+}
+
+// benchStoreSizes are the populations benchmarks run against
+var benchStoreSizes = []int{100, 10_000, 100_000}
+
+// fillUserStore inserts n users, with distinct emails
+func fillUserStore(b *testing.B, st UserStore, n int) {
+	b.Helper()
+	for i := 0; i < n; i++ {
+		user := User{Name: "User", Email: fmt.Sprintf("user%d@test.com", i)}
+		if _, err := st.Insert(context.Background(), user, 0, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchOps calls op b.N times with a sequence number from 0, from one
+// goroutine or from every P at once
+func benchOps(b *testing.B, parallel bool, op func(i int)) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	if !parallel {
+		for i := 0; i < b.N; i++ {
+			op(i)
+		}
+		return
+	}
+	var next atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			op(int(next.Add(1) - 1))
+		}
+	})
+}
+
+// benchUserStore runs a sub-benchmark for every store design, population
+// and parallelism. setup fills the store, given the population and b.N,
+// before op is timed.
+func benchUserStore(b *testing.B, setup func(b *testing.B, st UserStore, size int), op func(st UserStore, size, i int)) {
+	for _, design := range userStoreDesigns {
+		for _, size := range benchStoreSizes {
+			for _, parallel := range []bool{false, true} {
+				name := fmt.Sprintf("%s/size=%d/serial", design.name, size)
+				if parallel {
+					name = fmt.Sprintf("%s/size=%d/parallel", design.name, size)
+				}
+				b.Run(name, func(b *testing.B) {
+					st := design.new()
+					setup(b, st, size)
+					benchOps(b, parallel, func(i int) { op(st, size, i) })
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkUserStoreCreate inserts users into a store that starts at
+// each population
+func BenchmarkUserStoreCreate(b *testing.B) {
+	benchUserStore(b, fillUserStore, func(st UserStore, _, _ int) {
+		st.Insert(context.Background(), User{Name: "User", Email: "new@test.com"}, 0, 0)
+	})
+}
+
+// BenchmarkUserStoreGet reads existing users
+func BenchmarkUserStoreGet(b *testing.B) {
+	benchUserStore(b, fillUserStore, func(st UserStore, size, i int) {
+		st.Get(context.Background(), ID(i%size+1))
+	})
+}
+
+// BenchmarkUserStoreList lists the whole population
+func BenchmarkUserStoreList(b *testing.B) {
+	benchUserStore(b, fillUserStore, func(st UserStore, _, _ int) {
+		st.List(context.Background())
+	})
+}
+
+// BenchmarkUserStoreDelete deletes existing users, down to the population
+func BenchmarkUserStoreDelete(b *testing.B) {
+	setup := func(b *testing.B, st UserStore, size int) {
+		fillUserStore(b, st, size+b.N)
+	}
+	benchUserStore(b, setup, func(st UserStore, _, i int) {
+		st.Delete(context.Background(), ID(i+1))
+	})
+}
+
+// benchHandlerSizes are the populations handler benchmarks run against;
+// smaller than the store's, since responses are encoded as well
+var benchHandlerSizes = []int{100, 1_000}
+
+// benchHandler runs a sub-benchmark sending the request req returns, with
+// the sequence number, through the full middleware stack of a server
+// holding each population, plus b.N extra users when extra is set
+func benchHandler(b *testing.B, extra bool, req func(size, i int) *http.Request) {
+	for _, size := range benchHandlerSizes {
+		for _, parallel := range []bool{false, true} {
+			name := fmt.Sprintf("size=%d/serial", size)
+			if parallel {
+				name = fmt.Sprintf("size=%d/parallel", size)
+			}
+			b.Run(name, func(b *testing.B) {
+				server := NewServer()
+				n := size
+				if extra {
+					n += b.N
+				}
+				fillUserStore(b, server.store, n)
+				routes := server.Routes()
+				benchOps(b, parallel, func(i int) {
+					w := httptest.NewRecorder()
+					routes.ServeHTTP(w, req(size, i))
+					if w.Code >= http.StatusBadRequest {
+						b.Errorf("unexpected status %d: %s", w.Code, w.Body)
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkHandleCreateUser sends POST /users
+func BenchmarkHandleCreateUser(b *testing.B) {
+	benchHandler(b, false, func(_, i int) *http.Request {
+		body := fmt.Sprintf(`{"name":"New","email":"new%d@test.com"}`, i)
+		return httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	})
+}
+
+// BenchmarkHandleGetUser sends GET /users/{id} for existing users
+func BenchmarkHandleGetUser(b *testing.B) {
+	benchHandler(b, false, func(size, i int) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/users/"+strconv.Itoa(i%size+1), nil)
+	})
+}
+
+// BenchmarkHandleListUsers sends GET /users
+func BenchmarkHandleListUsers(b *testing.B) {
+	benchHandler(b, false, func(_, _ int) *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/users", nil)
+	})
+}
+
+// BenchmarkHandleDeleteUser sends DELETE /users/{id}, down to the
+// population
+func BenchmarkHandleDeleteUser(b *testing.B) {
+	benchHandler(b, true, func(_, i int) *http.Request {
+		return httptest.NewRequest(http.MethodDelete, "/users/"+strconv.Itoa(i+1), nil)
+	})
+}
+
+// FuzzHandleCreateUser sends arbitrary bodies to POST /users. Whatever the
+// body, the server must answer without a 5xx, and the store must hold
+// exactly the users it reported creating, as it reported them.
+func FuzzHandleCreateUser(f *testing.F) {
+	defer guard.VerifyNone(f)
+
+	for _, seed := range []string{
+		`{"name":"Alice","email":"alice@test.com"}`,
+		`{"name":"Bob","email":"bob@test.com","role":"admin","locale":"fr-FR"}`,
+		`{"name":"Eve","email":"eve@test.com","password":"correct horse battery"}`,
+		`{"name":`,
+		`[]`,
+		`null`,
+		`{"name":1e999,"email":-0}`,
+		`{"id":99999999999999999999999,"name":"Big","email":"big@test.com"}`,
+		`{"name":"\u0000\ud800￿","email":"‮@test.com"}`,
+		"{\"name\":\"\xff\xfe\",\"email\":\"ünïcødé@tëst.com\"}",
+		`{"name":"Dup","name":"Dup","email":"dup@test.com","extra":{"a":[1,2,{"b":null}]}}`,
+	} {
+		f.Add(seed)
+	}
+
+	server := NewServer()
+	routes := server.Routes()
+	f.Fuzz(func(t *testing.T, body string) {
+		ctx := context.Background()
+		before, _, _ := server.store.Count(ctx, "")
+
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body)))
+		if w.Code >= http.StatusInternalServerError {
+			t.Fatalf("expected no server error, got %d: %s", w.Code, w.Body)
+		}
+
+		after, _, _ := server.store.Count(ctx, "")
+		if w.Code != http.StatusCreated {
+			if after != before {
+				t.Fatalf("expected a refused body to store nothing, got %d users from %d", after, before)
+			}
+			return
+		}
+		if after != before+1 {
+			t.Fatalf("expected one user stored, got %d users from %d", after, before)
+		}
+		var created User
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("expected the created user, got %s: %v", w.Body, err)
+		}
+		stored, ok, _ := server.store.Get(ctx, created.ID)
+		if !ok || stored.Name != created.Name || stored.Email != created.Email || stored.Role != created.Role {
+			t.Fatalf("expected the store to hold %+v, got %+v", created, stored)
+		}
+	})
+}
+
+// FuzzUserPath sends GET and DELETE /users/{id} with arbitrary segments.
+// Neither may fail with a 5xx, and a delete may only remove the user its
+// segment parses to.
+func FuzzUserPath(f *testing.F) {
+	defer guard.VerifyNone(f)
+
+	for _, seed := range []string{"1", "2", "0", "-1", "+1", "01", "1.0", "1e3", " 1", "abc",
+		"9223372036854775807", "9223372036854775808", "-9223372036854775809", "١", "１", "%00", "", "..", "1/avatar"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, segment string) {
+		server := NewServer()
+		addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+		routes := server.Routes()
+		path := "/users/" + url.PathEscape(segment)
+
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			if w.Code >= http.StatusInternalServerError {
+				t.Fatalf("%s %s: expected no server error, got %d: %s", method, path, w.Code, w.Body)
+			}
+		}
+
+		if _, ok, _ := server.store.Get(context.Background(), 1); !ok {
+			if id, err := ParseID(segment); err != nil || id != 1 {
+				t.Fatalf("DELETE %s removed user 1", path)
+			}
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package quickserve
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionCookieName carries the session token for browser clients
+	SessionCookieName = "qs_session"
+	// defaultSessionTTL is how long a session lives no matter how active
+	defaultSessionTTL = 24 * time.Hour
+	// defaultSessionIdleTimeout ends sessions left unused this long
+	defaultSessionIdleTimeout = 2 * time.Hour
+	// sessionTouchInterval limits how often activity is written back to
+	// the store
+	sessionTouchInterval = time.Minute
+)
+
+// Session is a login issued to a user
+type Session struct {
+	UserID     ID        `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionStore persists sessions. Sessions are keyed by a hash of their
+// token, so a store never sees a usable credential. Timeouts are enforced
+// by the server; a store only needs to keep what it is given.
+type SessionStore interface {
+	Save(ctx context.Context, id string, sess Session) error
+	Load(ctx context.Context, id string) (Session, bool, error)
+	Delete(ctx context.Context, id string) error
+	// DeleteUser removes every session of user
+	DeleteUser(ctx context.Context, user ID) error
+}
+
+// WithSessionStore keeps sessions in st instead of in memory
+func WithSessionStore(st SessionStore) Option {
+	return func(s *Server) {
+		s.sessions = st
+	}
+}
+
+// WithSessionTTL sets how long a session lives no matter how active
+func WithSessionTTL(d time.Duration) Option {
+	return func(s *Server) {
+		s.sessionTTL = d
+	}
+}
+
+// WithSessionIdleTimeout ends sessions left unused for d; zero disables it
+func WithSessionIdleTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.sessionIdle = d
+	}
+}
+
+// MemorySessionStore keeps sessions in memory; they are lost on restart
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore creates an empty in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]Session)}
+}
+
+func (m *MemorySessionStore) Save(_ context.Context, id string, sess Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = sess
+	return nil
+}
+
+func (m *MemorySessionStore) Load(_ context.Context, id string) (Session, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[id]
+	return sess, ok, nil
+}
+
+func (m *MemorySessionStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *MemorySessionStore) DeleteUser(_ context.Context, user ID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, sess := range m.sessions {
+		if sess.UserID == user {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// sessionID derives the store key for token
+func sessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// startSession issues a new session for user and returns its token
+func (s *Server) startSession(ctx context.Context, user ID) (Session, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return Session{}, "", err
+	}
+	now := s.clock.Now()
+	sess := Session{UserID: user, CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(s.sessionTTL)}
+	if err := s.sessions.Save(ctx, sessionID(token), sess); err != nil {
+		return Session{}, "", err
+	}
+	return sess, token, nil
+}
+
+// resumeSession looks up the live session for token, enforcing both
+// timeouts and recording the activity
+func (s *Server) resumeSession(ctx context.Context, token string) (Session, bool) {
+	if token == "" {
+		return Session{}, false
+	}
+	id := sessionID(token)
+	sess, ok, err := s.sessions.Load(ctx, id)
+	if err != nil {
+		s.componentLogger("session").ErrorContext(ctx, "load failed", "err", err)
+		return Session{}, false
+	}
+	if !ok {
+		return Session{}, false
+	}
+
+	now := s.clock.Now()
+	if !now.Before(sess.ExpiresAt) || (s.sessionIdle > 0 && now.Sub(sess.LastSeenAt) >= s.sessionIdle) {
+		s.sessions.Delete(ctx, id)
+		return Session{}, false
+	}
+	if now.Sub(sess.LastSeenAt) >= sessionTouchInterval {
+		sess.LastSeenAt = now
+		if err := s.sessions.Save(ctx, id, sess); err != nil {
+			s.componentLogger("session").ErrorContext(ctx, "touch failed", "user_id", sess.UserID, "err", err)
+		}
+	}
+	return sess, true
+}
+
+// sessionToken returns the session token a request presents, from the
+// Authorization header or the session cookie
+func sessionToken(r *http.Request) (string, bool) {
+	if token, ok := bearerToken(r); ok {
+		return token, true
+	}
+	if c, err := r.Cookie(SessionCookieName); err == nil && c.Value != "" {
+		return c.Value, true
+	}
+	return "", false
+}
+
+// setSessionCookie hands token to a browser. It is HttpOnly so scripts
+// can't read it, and Secure whenever the request came over TLS.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// HandleLogout handles POST /logout, ending the session the request
+// presents. It succeeds even without one so clients can always clear
+// their state.
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if token, ok := sessionToken(r); ok {
+		if sess, ok := s.resumeSession(r.Context(), token); ok {
+			if err := s.sessions.Delete(r.Context(), sessionID(token)); err != nil {
+				httpError(w, r, "could not end session", http.StatusInternalServerError)
+				return
+			}
+			if u, ok, err := s.store.Get(r.Context(), sess.UserID); ok && err == nil {
+				s.recordAudit(r.WithContext(WithPrincipal(r.Context(), userPrincipal(u))), AuditDelete, "session", "", nil, nil)
+			}
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
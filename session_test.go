@@ -0,0 +1,116 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	store := NewMemorySessionStore()
+	store.Save(ctx, "a", Session{UserID: 1})
+	store.Save(ctx, "b", Session{UserID: 1})
+	store.Save(ctx, "c", Session{UserID: 2})
+
+	if sess, ok, err := store.Load(ctx, "a"); err != nil || !ok || sess.UserID != 1 {
+		t.Fatalf("expected session a, got %+v %v %v", sess, ok, err)
+	}
+	store.Delete(ctx, "a")
+	if _, ok, _ := store.Load(ctx, "a"); ok {
+		t.Error("expected session a to be deleted")
+	}
+	store.DeleteUser(ctx, 1)
+	if _, ok, _ := store.Load(ctx, "b"); ok {
+		t.Error("expected the user's other sessions to be deleted")
+	}
+	if _, ok, _ := store.Load(ctx, "c"); !ok {
+		t.Error("expected other users' sessions to remain")
+	}
+}
+
+func TestCookieSessions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(
+		WithClock(clock),
+		WithAPIKeyAuth("admin-secret"),
+		WithSessionTTL(4*time.Hour),
+		WithSessionIdleTimeout(30*time.Minute),
+	)
+	routes := server.Routes()
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com","password":"correct horse"}`))
+	req.Header.Set(APIKeyHeader, "admin-secret")
+	routes.ServeHTTP(httptest.NewRecorder(), req)
+
+	login := func() *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"alice@test.com","password":"correct horse"}`))
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == SessionCookieName {
+				if !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+					t.Errorf("expected an HttpOnly, SameSite=Lax cookie, got %+v", c)
+				}
+				return c
+			}
+		}
+		t.Fatalf("login set no session cookie: %d %s", w.Code, w.Body)
+		return nil
+	}
+	get := func(c *http.Cookie) int {
+		req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		req.AddCookie(c)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	cookie := login()
+	if code := get(cookie); code != http.StatusOK {
+		t.Fatalf("expected the cookie to authenticate, got %d", code)
+	}
+
+	// Activity keeps the session alive up to its absolute lifetime
+	for i := 0; i < 8; i++ {
+		clock.Advance(29 * time.Minute)
+		if code := get(cookie); code != http.StatusOK {
+			t.Fatalf("expected an active session to stay valid after %d checks, got %d", i+1, code)
+		}
+	}
+	clock.Advance(29 * time.Minute)
+	if code := get(cookie); code != http.StatusUnauthorized {
+		t.Errorf("expected the absolute timeout to end the session, got %d", code)
+	}
+
+	cookie = login()
+	clock.Advance(30 * time.Minute)
+	if code := get(cookie); code != http.StatusUnauthorized {
+		t.Errorf("expected the idle timeout to end the session, got %d", code)
+	}
+
+	cookie = login()
+	req = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	if c := w.Result().Cookies(); len(c) != 1 || c[0].MaxAge >= 0 {
+		t.Errorf("expected logout to clear the cookie, got %+v", c)
+	}
+	if code := get(cookie); code != http.StatusUnauthorized {
+		t.Errorf("expected logout to end the session, got %d", code)
+	}
+}
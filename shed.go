@@ -0,0 +1,195 @@
+package quickserve
+
+import (
+	"net/http"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// shedSamples is how many recent handler latencies are kept
+	shedSamples = 1024
+	// shedWindow is how far back latencies count towards the p99
+	shedWindow = 10 * time.Second
+	// shedMinSamples is how many latencies the p99 needs, so a handful
+	// of slow requests on an idle server shed nothing
+	shedMinSamples = 20
+	// shedCheckInterval is the minimum time between saturation checks
+	shedCheckInterval = 250 * time.Millisecond
+)
+
+// LoadSheddingConfig sheds low-priority requests when the server is
+// saturated
+type LoadSheddingConfig struct {
+	// MaxLatency is the p99 handler latency at which the server counts
+	// as saturated; zero ignores latency
+	MaxLatency time.Duration `yaml:"max_latency"`
+	// MaxGoroutines is the goroutine count at which the server counts as
+	// saturated; zero ignores it
+	MaxGoroutines int `yaml:"max_goroutines"`
+	// Priorities overrides the priority of routes by pattern, e.g.
+	// "GET /users": critical; they are set in the config file only
+	Priorities map[string]Priority `yaml:"priorities"`
+}
+
+// Priority decides which requests are shed first when the server is
+// saturated
+type Priority string
+
+const (
+	// PriorityLow routes are shed as soon as the server is saturated
+	PriorityLow Priority = "low"
+	// PriorityNormal routes are shed once it is saturated twice over
+	PriorityNormal Priority = "normal"
+	// PriorityCritical routes are never shed
+	PriorityCritical Priority = "critical"
+)
+
+// valid reports whether p is one of the defined priorities
+func (p Priority) valid() bool {
+	return p == PriorityLow || p == PriorityNormal || p == PriorityCritical
+}
+
+// WithPriority sets the priority a module wants for a route, e.g. low
+// for bulk exports that clients can retry later
+func WithPriority(p Priority) RouteOption {
+	return func(rt *Route) {
+		rt.Priority = p
+	}
+}
+
+// WithRoutePriority sets the priority of the routes matching patterns,
+// e.g. "GET /users", winning over what modules declare with WithPriority
+func WithRoutePriority(p Priority, patterns ...string) Option {
+	return func(s *Server) {
+		if s.routePriority == nil {
+			s.routePriority = make(map[string]Priority)
+		}
+		for _, pattern := range patterns {
+			s.routePriority[pattern] = p
+		}
+	}
+}
+
+// priority resolves the priority of rt
+func (s *Server) priority(rt *Route) Priority {
+	if p, ok := s.routePriority[rt.Pattern()]; ok {
+		return p
+	}
+	return rt.Priority
+}
+
+// WithLoadShedding sheds requests by priority while the p99 handler
+// latency is over maxLatency or more than maxGoroutines goroutines are
+// running. Either may be zero to ignore that signal.
+func WithLoadShedding(maxLatency time.Duration, maxGoroutines int) Option {
+	return func(s *Server) {
+		s.shedder = &loadShedder{maxLatency: maxLatency, maxGoroutines: maxGoroutines}
+	}
+}
+
+// latencySample is one handler's latency and when it finished
+type latencySample struct {
+	at time.Time
+	d  time.Duration
+}
+
+// loadShedder measures how saturated the server is: the ratio of the
+// recent p99 latency and of the goroutine count to their limits,
+// whichever is higher. Latency is measured in real time regardless of
+// the server clock.
+type loadShedder struct {
+	maxLatency    time.Duration
+	maxGoroutines int
+
+	mu         sync.Mutex
+	samples    [shedSamples]latencySample
+	next       int
+	checked    time.Time
+	saturation float64
+}
+
+// record adds the latency of a handled request
+func (l *loadShedder) record(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples[l.next] = latencySample{at: time.Now(), d: d}
+	l.next = (l.next + 1) % len(l.samples)
+}
+
+// saturated returns the saturation, 1 being at the limit. It is
+// recomputed at most once per shedCheckInterval. Only requests that
+// finished within shedWindow count, so once shedding has brought latency
+// down the p99 recovers even if slow requests are no longer let through.
+func (l *loadShedder) saturated() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.checked) < shedCheckInterval {
+		return l.saturation
+	}
+	l.checked = now
+
+	l.saturation = 0
+	if l.maxLatency > 0 {
+		var recent []time.Duration
+		for _, s := range l.samples {
+			if !s.at.IsZero() && now.Sub(s.at) < shedWindow {
+				recent = append(recent, s.d)
+			}
+		}
+		if len(recent) >= shedMinSamples {
+			slices.Sort(recent)
+			p99 := recent[(len(recent)*99-1)/100]
+			l.saturation = float64(p99) / float64(l.maxLatency)
+		}
+	}
+	if l.maxGoroutines > 0 {
+		l.saturation = max(l.saturation, float64(runtime.NumGoroutine())/float64(l.maxGoroutines))
+	}
+	return l.saturation
+}
+
+// admits reports whether a request of priority p is served at the
+// current saturation
+func (l *loadShedder) admits(p Priority) bool {
+	switch p {
+	case PriorityCritical:
+		return true
+	case PriorityLow:
+		return l.saturated() < 1
+	}
+	return l.saturated() < 2
+}
+
+// shedLoad answers 503 to requests whose route's priority is shed at the
+// current saturation, before any work is done for them, and measures how
+// long the others take. Event streams, which stay open, are left out of
+// the latency.
+func (s *Server) shedLoad(next http.Handler) http.Handler {
+	if s.shedder == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, ok := RouteFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.shedder.admits(s.priority(rt)) {
+			setRetryAfter(w.Header(), time.Second)
+			httpError(w, r, "server overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		if isWebSocketUpgrade(r) || isEventStream(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		s.shedder.record(time.Since(start))
+	})
+}
@@ -0,0 +1,89 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// fill records n latencies of d and forces the next check
+func (l *loadShedder) fill(n int, d time.Duration) {
+	for range n {
+		l.record(d)
+	}
+	l.checked = time.Time{}
+}
+
+func TestLoadShedderSaturation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	l := &loadShedder{maxLatency: 10 * time.Millisecond}
+	l.fill(shedMinSamples-1, time.Second)
+	if !l.admits(PriorityLow) {
+		t.Error("expected too few samples to shed nothing")
+	}
+
+	l.fill(len(l.samples), time.Millisecond)
+	if !l.admits(PriorityLow) {
+		t.Errorf("expected no shedding with a fast p99, got saturation %v", l.saturation)
+	}
+
+	// Over the limit only low priority is shed
+	l.fill(len(l.samples), 15*time.Millisecond)
+	if l.admits(PriorityLow) || !l.admits(PriorityNormal) {
+		t.Errorf("expected only low priority shed, got saturation %v", l.saturation)
+	}
+
+	// Twice over it normal priority is shed too, but never critical
+	l.fill(len(l.samples), 30*time.Millisecond)
+	if l.admits(PriorityNormal) || !l.admits(PriorityCritical) {
+		t.Errorf("expected normal priority shed, got saturation %v", l.saturation)
+	}
+
+	// Latencies age out, so the server recovers when nothing slow is let
+	// through
+	for i := range l.samples {
+		l.samples[i].at = time.Now().Add(-shedWindow)
+	}
+	l.checked = time.Time{}
+	if !l.admits(PriorityLow) {
+		t.Errorf("expected old latencies to be ignored, got saturation %v", l.saturation)
+	}
+}
+
+func TestLoadSheddingByRoute(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithLoadShedding(time.Millisecond, 0), WithRoutePriority(PriorityCritical, "GET /users/{id}"))
+	routes := server.Routes()
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 before saturation, got %d", w.Code)
+	}
+
+	server.shedder.fill(len(server.shedder.samples), 5*time.Millisecond)
+	w = do("/users")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected a low-priority route shed with 503 and Retry-After, got %d: %v", w.Code, w.Header())
+	}
+	if w := do("/orgs"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected a normal-priority route shed at 5x saturation, got %d", w.Code)
+	}
+	if w := do("/users/1"); w.Code != http.StatusOK {
+		t.Errorf("expected a route raised to critical to be served, got %d", w.Code)
+	}
+	if w := do("/healthz"); w.Code != http.StatusOK {
+		t.Errorf("expected health checks never to be shed, got %d", w.Code)
+	}
+}
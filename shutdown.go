@@ -0,0 +1,63 @@
+package quickserve
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultDrainTimeout is how long in-flight requests get to finish
+	// after a shutdown signal
+	defaultDrainTimeout = 30 * time.Second
+)
+
+var errDraining = errors.New("shutting down")
+
+// Drain marks the server as shutting down: GET /readyz fails and the
+// HAProxy agent-check answers "drain", so load balancers stop sending new
+// requests while those in flight finish. Requests are still served, but
+// event streams end so their clients reconnect elsewhere.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+	s.endStreams()
+}
+
+// Draining reports whether Drain has been called
+func (s *Server) Draining() bool {
+	return s.draining.Load()
+}
+
+// drainer is a server that can shut down gracefully, such as an
+// *http.Server or an *http3.Server
+type drainer interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// serveAndDrain runs serve until ctx is done, then shuts srv down
+// gracefully: listeners close at once and in-flight requests get up to
+// timeout to finish before their connections are closed
+func serveAndDrain(ctx context.Context, srv drainer, timeout time.Duration, serve func() error) error {
+	served := make(chan error, 1)
+	go func() { served <- serve() }()
+	select {
+	case err := <-served:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(drainCtx); err != nil {
+		slog.Warn("requests still in flight after the drain timeout; closing their connections", "timeout", timeout)
+		srv.Close()
+	}
+	<-served
+	return nil
+}
@@ -0,0 +1,183 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestDrainFailsReadiness(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer()
+	handler := s.Routes()
+	probe := func() (int, Readiness) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var ready Readiness
+		json.NewDecoder(w.Body).Decode(&ready)
+		return w.Code, ready
+	}
+
+	if code, _ := probe(); code != http.StatusOK {
+		t.Fatalf("expected 200 before draining, got %d", code)
+	}
+	s.Drain()
+	code, ready := probe()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", code)
+	}
+	for _, c := range ready.Checks {
+		if c.Name == "shutdown" && (c.Status != "failing" || c.Error != "shutting down") {
+			t.Errorf("expected the shutdown check to fail, got %+v", c)
+		}
+	}
+
+	// Requests are still served while draining
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected requests to be served while draining, got %d", w.Code)
+	}
+}
+
+func TestServeAndDrainFinishesInFlight(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAndDrain(ctx, srv, time.Minute, func() error { return srv.Serve(ln) })
+	}()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	body := make(chan string, 1)
+	go func() {
+		resp, err := client.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		t.Fatalf("expected shutdown to wait for the request, returned %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if got := <-body; got != "done" {
+		t.Errorf("expected the in-flight request to finish, got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestServeAndDrainTimeout(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serveAndDrain(ctx, srv, 50*time.Millisecond, func() error { return srv.Serve(ln) })
+	}()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	failed := make(chan error, 1)
+	go func() {
+		resp, err := client.Get("http://" + ln.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		failed <- err
+	}()
+
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the drain timeout to cut the stuck request")
+	}
+	if err := <-failed; err == nil {
+		t.Error("expected the stuck request's connection to be closed")
+	}
+}
+
+func TestServeAgentCheckDraining(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	server.Drain()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		server.ServeAgentCheck(ln)
+		close(done)
+	}()
+	defer func() {
+		ln.Close()
+		<-done
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(line) != "drain\n" {
+		t.Errorf("expected drain while draining, got %q", line)
+	}
+}
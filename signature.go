@@ -0,0 +1,201 @@
+package quickserve
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>",
+	// computed over "<t>.<METHOD>.<path>.<body>" for requests the server
+	// verifies and over "<t>.<body>" for webhooks it sends. Several v1
+	// values may be sent while a secret is being rotated.
+	SignatureHeader = "X-Signature"
+	// defaultSignatureWindow is how far a signature's timestamp may be
+	// from the server clock
+	defaultSignatureWindow = 5 * time.Minute
+	// maxSignedBody bounds how much of a request is buffered for hashing
+	maxSignedBody = 1 << 20
+)
+
+// requestSigner verifies signed requests and remembers the signatures it
+// has accepted until they fall out of the window, so none can be replayed
+type requestSigner struct {
+	secrets [][]byte
+	window  time.Duration
+	maxBody int64
+	groups  map[string]bool
+
+	mu   sync.Mutex
+	seen map[string]bool
+	// expiries orders the seen signatures by when they may be forgotten
+	expiries signatureExpiries
+}
+
+// newRequestSigner creates a signer accepting signatures made with any of
+// secrets
+func newRequestSigner(secrets []string, maxBody int64) *requestSigner {
+	v := &requestSigner{
+		window:  defaultSignatureWindow,
+		maxBody: maxBody,
+		groups:  make(map[string]bool),
+		seen:    make(map[string]bool),
+	}
+	for _, secret := range secrets {
+		v.secrets = append(v.secrets, []byte(secret))
+	}
+	return v
+}
+
+// signatureExpiry is when a seen signature may be forgotten
+type signatureExpiry struct {
+	key string
+	at  time.Time
+}
+
+// signatureExpiries is a min-heap of expiries, soonest first
+type signatureExpiries []signatureExpiry
+
+func (h signatureExpiries) Len() int           { return len(h) }
+func (h signatureExpiries) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h signatureExpiries) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *signatureExpiries) Push(x any)        { *h = append(*h, x.(signatureExpiry)) }
+func (h *signatureExpiries) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// WithRequestSigning requires requests to the named route groups to carry
+// an X-Signature made with one of secrets. Listing more than one secret
+// allows rotating them without downtime.
+func WithRequestSigning(secrets []string, groups ...string) Option {
+	return func(s *Server) {
+		signer := newRequestSigner(secrets, maxSignedBody)
+		for _, g := range groups {
+			signer.groups[g] = true
+		}
+		s.signer = signer
+	}
+}
+
+// Sign returns the X-Signature value for a webhook body at time t
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(signatureMAC([]byte(secret), ts, body))
+}
+
+// SignRequest returns the X-Signature value for a request to path with
+// method and body at time t. The method and path are signed too, so a
+// signature can't be replayed against another route.
+func SignRequest(secret string, t time.Time, method, path string, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(signatureMAC([]byte(secret), ts, []byte(method), []byte(path), body))
+}
+
+// signatureMAC is the MAC of ts and parts, each after a dot
+func signatureMAC(secret []byte, ts string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts))
+	for _, p := range parts {
+		mac.Write([]byte("."))
+		mac.Write(p)
+	}
+	return mac.Sum(nil)
+}
+
+// parseSignature splits a header into its timestamp and candidate MACs
+func parseSignature(h string) (ts string, macs [][]byte) {
+	for _, part := range strings.Split(h, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if mac, err := hex.DecodeString(v); err == nil {
+				macs = append(macs, mac)
+			}
+		}
+	}
+	return ts, macs
+}
+
+// verify checks a signature for a request with method, path and body,
+// returning the error message to send when it is not acceptable
+func (v *requestSigner) verify(header, method, path string, body []byte, now time.Time) (string, bool) {
+	ts, macs := parseSignature(header)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(macs) == 0 {
+		return "missing or malformed signature", false
+	}
+	signedAt := time.Unix(unix, 0)
+	if d := now.Sub(signedAt); d > v.window || d < -v.window {
+		return "signature timestamp outside allowed window", false
+	}
+
+	var matched []byte
+	for _, secret := range v.secrets {
+		expected := signatureMAC(secret, ts, []byte(method), []byte(path), body)
+		for _, mac := range macs {
+			if hmac.Equal(mac, expected) {
+				matched = mac
+			}
+		}
+	}
+	if matched == nil {
+		return "invalid signature", false
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for len(v.expiries) > 0 && now.After(v.expiries[0].at) {
+		delete(v.seen, heap.Pop(&v.expiries).(signatureExpiry).key)
+	}
+	key := string(matched)
+	if v.seen[key] {
+		return "signature already used", false
+	}
+	v.seen[key] = true
+	heap.Push(&v.expiries, signatureExpiry{key: key, at: signedAt.Add(v.window)})
+	return "", true
+}
+
+// verifySignature rejects requests whose method, path and body aren't
+// signed with a shared secret, are signed too long ago, or were seen
+// before
+func (s *Server) verifySignature(next http.Handler) http.Handler {
+	return s.verifySignedBy(s.signer, next)
+}
+
+// verifySignedBy is verifySignature with the secrets of v
+func (s *Server) verifySignedBy(v *requestSigner, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, v.maxBody+1))
+		if err != nil {
+			httpError(w, r, "could not read request body", http.StatusBadRequest)
+			return
+		}
+		if int64(len(body)) > v.maxBody {
+			httpError(w, r, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if msg, ok := v.verify(r.Header.Get(SignatureHeader), r.Method, r.URL.Path, body, s.clock.Now()); !ok {
+			httpError(w, r, msg, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
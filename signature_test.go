@@ -0,0 +1,104 @@
+package quickserve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestRequestSigning(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithRequestSigning([]string{"new-secret", "old-secret"}, "users"))
+	routes := server.Routes()
+
+	body := `{"name":"Alice","email":"alice@test.com"}`
+	sign := func(secret string, at time.Time, body string) string {
+		return SignRequest(secret, at, http.MethodPost, "/users", []byte(body))
+	}
+	do := func(signature, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(SignatureHeader, signature)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	sig := sign("old-secret", clock.Now(), body)
+	if w := do(sig, body); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 with a rotated-out secret, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(sig, body); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected replay to be rejected, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+		body      string
+	}{
+		{"unsigned", "", body},
+		{"tampered body", sign("new-secret", clock.Now(), body), body + " "},
+		{"wrong secret", sign("guess", clock.Now(), body), body},
+		{"stale", sign("new-secret", clock.Now().Add(-10*time.Minute), body), body},
+		{"body only", Sign("new-secret", clock.Now(), []byte(body)), body},
+		{"other route", SignRequest("new-secret", clock.Now(), http.MethodPost, "/orgs", []byte(body)), body},
+		{"other method", SignRequest("new-secret", clock.Now(), http.MethodPut, "/users", []byte(body)), body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := do(tt.signature, tt.body); w.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", w.Code)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected other groups to be unaffected, got %d", w.Code)
+	}
+}
+
+func TestParseSignatureRotation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	now := time.Unix(1700000000, 0)
+	a := Sign("a", now, nil)
+	b := Sign("b", now, nil)
+	_, macB, _ := strings.Cut(b, ",")
+
+	ts, macs := parseSignature(a + "," + macB)
+	if ts != "1700000000" || len(macs) != 2 {
+		t.Errorf("expected timestamp and two signatures, got %q %d", ts, len(macs))
+	}
+}
+
+func TestRequestSignerForgetsExpired(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	v := newRequestSigner([]string{"secret"}, maxSignedBody)
+	start := time.Unix(1700000000, 0)
+	for i := range 3 {
+		at := start.Add(time.Duration(i) * time.Minute)
+		if msg, ok := v.verify(SignRequest("secret", at, http.MethodPost, "/users", nil), http.MethodPost, "/users", nil, at); !ok {
+			t.Fatalf("expected signature %d accepted, got %s", i, msg)
+		}
+	}
+
+	// Only the signatures out of the window are forgotten
+	now := start.Add(v.window + time.Minute + time.Second)
+	sig := SignRequest("secret", now, http.MethodPost, "/users", nil)
+	v.verify(sig, http.MethodPost, "/users", nil, now)
+	if len(v.seen) != 2 || len(v.expiries) != 2 {
+		t.Errorf("expected two signatures remembered, got %d and %d", len(v.seen), len(v.expiries))
+	}
+}
@@ -0,0 +1,186 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SignupConfig controls public self-service registration
+type SignupConfig struct {
+	// AllowedDomains restricts sign-ups to these email domains; empty
+	// allows any
+	AllowedDomains []string
+	// DefaultRole is given to every new user; RoleUser if empty
+	DefaultRole Role
+	// Captcha, if set, must accept the request's captcha_token
+	Captcha CaptchaVerifier
+	// Throttle limits sign-up attempts per IP; five a minute if zero
+	Throttle RateLimit
+}
+
+// WithSignup mounts POST /signup
+func WithSignup(cfg SignupConfig) Option {
+	return func(s *Server) {
+		if cfg.DefaultRole == "" {
+			cfg.DefaultRole = RoleUser
+		}
+		if cfg.Throttle == (RateLimit{}) {
+			cfg.Throttle = RateLimit{Rate: 5.0 / 60, Burst: 5}
+		}
+		for i, d := range cfg.AllowedDomains {
+			cfg.AllowedDomains[i] = strings.ToLower(strings.TrimSpace(d))
+		}
+		s.signup = &cfg
+	}
+}
+
+// domainAllowed reports whether email's domain may sign up
+func (c *SignupConfig) domainAllowed(email string) bool {
+	if len(c.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndexByte(email, '@')
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range c.AllowedDomains {
+		if domain == d {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptchaVerifier checks a CAPTCHA response token
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// siteVerifier implements the siteverify protocol shared by hCaptcha and
+// Cloudflare Turnstile
+type siteVerifier struct {
+	endpoint string
+	secret   string
+	client   *http.Client
+}
+
+// NewHCaptcha verifies tokens with hCaptcha
+func NewHCaptcha(secret string) CaptchaVerifier {
+	return &siteVerifier{endpoint: "https://api.hcaptcha.com/siteverify", secret: secret, client: http.DefaultClient}
+}
+
+// NewTurnstile verifies tokens with Cloudflare Turnstile
+func NewTurnstile(secret string) CaptchaVerifier {
+	return &siteVerifier{endpoint: "https://challenges.cloudflare.com/turnstile/v0/siteverify", secret: secret, client: http.DefaultClient}
+}
+
+var errCaptchaFailed = errors.New("captcha verification failed")
+
+func (v *siteVerifier) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return errCaptchaFailed
+	}
+	form := url.Values{"secret": {v.secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha provider: %s", resp.Status)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("%w: %s", errCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+	return nil
+}
+
+// HandleSignup handles POST /signup. Anyone may call it, within the
+// configured domain rules, CAPTCHA and per-IP throttle.
+func (s *Server) HandleSignup(w http.ResponseWriter, r *http.Request) {
+	ip := s.clientIP(r)
+	if d := s.signupLimiter.AllowLimit("signup:"+ip, s.signup.Throttle); !d.Allowed {
+		setRetryAfter(w.Header(), d.RetryAfter)
+		httpError(w, r, "too many sign-up attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	var req struct {
+		Name         string `json:"name"`
+		Email        string `json:"email"`
+		Locale       string `json:"locale"`
+		Password     string `json:"password"`
+		CaptchaToken string `json:"captcha_token"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.Name == "" {
+		httpError(w, r, "name and email are required", http.StatusBadRequest)
+		return
+	}
+	addr, err := mail.ParseAddress(req.Email)
+	if err != nil || addr.Address != req.Email {
+		httpError(w, r, "invalid email", http.StatusBadRequest)
+		return
+	}
+	if !s.signup.domainAllowed(req.Email) {
+		httpError(w, r, "email domain not allowed", http.StatusForbidden)
+		return
+	}
+	locale, err := parseLocale(req.Locale)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if s.signup.Captcha != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		err := s.signup.Captcha.Verify(ctx, req.CaptchaToken, ip)
+		cancel()
+		if errors.Is(err, errCaptchaFailed) {
+			httpError(w, r, "captcha verification failed", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			httpError(w, r, "could not verify captcha", http.StatusBadGateway)
+			return
+		}
+	}
+
+	// Hashing is deliberately slow, so it waits until the CAPTCHA passed
+	hash, ok := optionalPassword(w, r, req.Password)
+	if !ok {
+		return
+	}
+
+	user, err := s.createUser(r.Context(), User{
+		Name: req.Name, Email: req.Email, Role: s.signup.DefaultRole, Locale: locale, Tenant: s.tenantFromRequest(r), passwordHash: hash,
+	})
+	if err != nil {
+		writeCreateError(w, r, err)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "user", user.ID.String(), nil, user)
+
+	s.writeJSON(w, r, http.StatusCreated, user)
+}
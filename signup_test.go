@@ -0,0 +1,99 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSignup(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	server := NewServer(WithClock(clock), WithSignup(SignupConfig{
+		AllowedDomains: []string{"Example.com"},
+		Throttle:       RateLimit{Rate: 1, Burst: 3},
+	}))
+	routes := server.Routes()
+
+	do := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(`{"name":"Alice","email":"alice@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if u, ok, _ := server.store.FindByEmail(context.Background(), "alice@example.com"); !ok || u.Role != RoleUser {
+		t.Errorf("expected user with default role, got %+v", u)
+	}
+	if w := do(`{"name":"Alice","email":"alice@example.com"}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for existing email, got %d", w.Code)
+	}
+	if w := do(`{"name":"Mallory","email":"mallory@evil.example"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for other domains, got %d", w.Code)
+	}
+
+	w := do(`{"name":"Bob","email":"bob@example.com"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected throttled attempt with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	clock.Advance(time.Second)
+	if w := do(`{"name":"Bob","email":"bob@example.com"}`); w.Code != http.StatusCreated {
+		t.Errorf("expected 201 after the throttle refills, got %d", w.Code)
+	}
+}
+
+func TestSignupDisabledByDefault(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	NewServer().Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected /signup to be unmounted, got %d", w.Code)
+	}
+}
+
+func TestSignupCaptcha(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("secret") == "s3cret" && r.Form.Get("response") == "good" {
+			w.Write([]byte(`{"success":true}`))
+			return
+		}
+		w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer provider.Close()
+	defer provider.Client().CloseIdleConnections()
+
+	captcha := &siteVerifier{endpoint: provider.URL, secret: "s3cret", client: provider.Client()}
+	if err := captcha.Verify(context.Background(), "good", "192.0.2.1"); err != nil {
+		t.Errorf("expected valid token to verify: %v", err)
+	}
+
+	server := NewServer(WithSignup(SignupConfig{Captcha: captcha}))
+	routes := server.Routes()
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(`{"name":"Carol","email":"carol@example.com","captcha_token":"`+token+`"}`))
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("bad"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a failed captcha, got %d", code)
+	}
+	if code := do("good"); code != http.StatusCreated {
+		t.Errorf("expected 201, got %d", code)
+	}
+}
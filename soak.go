@@ -0,0 +1,437 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	heapruntime "github.com/harshakonda/heapcheck/runtime"
+)
+
+const (
+	// soakWorkers bounds the requests a soak run has in flight; ticks that
+	// find every worker busy are counted as dropped
+	soakWorkers = 32
+	// soakPopulation is how many users a soak run keeps alive, so the
+	// store stays the same size and any growth is a leak
+	soakPopulation = 200
+	// soakWarmup is the share of a run whose samples are ignored when
+	// measuring growth, while caches and pools fill up
+	soakWarmup = 0.1
+	// soakSettle is how long goroutines get to exit after the instance
+	// closes before they count as leaked
+	soakSettle = 2 * time.Second
+
+	defaultSoakMaxHeapGrowth = 64 << 20
+)
+
+// SoakConfig configures a soak run
+type SoakConfig struct {
+	Duration time.Duration
+	// RPS is the request rate to sustain
+	RPS int
+	// SampleInterval is how often goroutine and heap stats are recorded;
+	// zero takes 60 samples over the run, at most one a second
+	SampleInterval time.Duration
+	// MaxGoroutineGrowth is how many goroutines may outlive the instance
+	MaxGoroutineGrowth int
+	// MaxHeapGrowth bounds, in bytes, how far the live heap may grow
+	// between the end of warm-up and the end of the run; zero means 64 MiB
+	MaxHeapGrowth int64
+	// Options configure the embedded instance under test
+	Options []Option
+}
+
+// SoakSample is the runtime state at one point of a soak run
+type SoakSample struct {
+	Elapsed     time.Duration `json:"elapsed"`
+	Goroutines  int           `json:"goroutines"`
+	HeapAlloc   uint64        `json:"heap_alloc"`
+	HeapObjects uint64        `json:"heap_objects"`
+	Requests    int64         `json:"requests"`
+}
+
+// LeakedGoroutine is a goroutine still running after a soak run closed
+// its instance
+type LeakedGoroutine struct {
+	ID    int    `json:"id"`
+	State string `json:"state"`
+	Stack string `json:"stack"`
+}
+
+// SoakReport is the outcome of a soak run. Failures is empty when the run
+// passed.
+type SoakReport struct {
+	Duration   time.Duration `json:"duration"`
+	RPS        int           `json:"rps"`
+	Requests   int64         `json:"requests"`
+	Errors     int64         `json:"errors"`
+	Dropped    int64         `json:"dropped"`
+	Statuses   map[int]int64 `json:"statuses"`
+	Samples    []SoakSample  `json:"samples"`
+	StartStats SoakSample    `json:"start"`
+	EndStats   SoakSample    `json:"end"`
+	// HeapGrowth is the growth of the live heap from the end of warm-up to
+	// the end of the run, after the instance closed
+	HeapGrowth int64 `json:"heap_growth"`
+	// HeapSlope and GoroutineSlope are the least-squares trends of the
+	// samples taken after warm-up, per minute
+	HeapSlope        float64           `json:"heap_slope_per_minute"`
+	GoroutineSlope   float64           `json:"goroutine_slope_per_minute"`
+	GoroutineGrowth  int               `json:"goroutine_growth"`
+	LeakedGoroutines []LeakedGoroutine `json:"leaked_goroutines,omitempty"`
+	Failures         []string          `json:"failures,omitempty"`
+}
+
+// Passed reports whether the run found no leak or regression
+func (r SoakReport) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// Soak runs traffic against an embedded instance for cfg.Duration at
+// cfg.RPS, creating, reading, listing and deleting users, while sampling
+// goroutine and heap stats. It then closes the instance and, like
+// guard.VerifyNone does for a test, checks that every goroutine it started
+// has exited and that the heap has not grown past cfg.MaxHeapGrowth.
+// Stopping ctx ends the run early with a report of what ran so far.
+//
+// The run uses the wall clock and this process's runtime stats, so nothing
+// else should run in the process meanwhile.
+func Soak(ctx context.Context, cfg SoakConfig) (SoakReport, error) {
+	if cfg.Duration <= 0 || cfg.RPS <= 0 {
+		return SoakReport{}, fmt.Errorf("soak: duration and rps must be positive")
+	}
+	if cfg.MaxHeapGrowth == 0 {
+		cfg.MaxHeapGrowth = defaultSoakMaxHeapGrowth
+	}
+	interval := cfg.SampleInterval
+	if interval <= 0 {
+		interval = max(cfg.Duration/60, time.Second)
+	}
+
+	baseline := heapruntime.TakeSnapshot()
+	e, err := NewEmbedded(cfg.Options...)
+	if err != nil {
+		return SoakReport{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Duration)
+	defer cancel()
+
+	// Run the background jobs too, so their goroutines are covered. They
+	// stop once the last request is done, not when the clock runs out.
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+	ran := make(chan error, 1)
+	go func() { ran <- e.server.Run(runCtx) }()
+
+	t := newSoakTraffic(e.Client())
+	jobs := make(chan struct{})
+	var workers sync.WaitGroup
+	for range soakWorkers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for range jobs {
+				t.request()
+			}
+		}()
+	}
+
+	start := time.Now()
+	report := SoakReport{Duration: cfg.Duration, RPS: cfg.RPS}
+	report.StartStats = sampleRuntime(0, 0)
+	report.Samples = append(report.Samples, report.StartStats)
+	sample := time.NewTicker(interval)
+	defer sample.Stop()
+	tick := time.NewTicker(time.Second / time.Duration(cfg.RPS))
+	defer tick.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-sample.C:
+			report.Samples = append(report.Samples, sampleRuntime(time.Since(start), t.requests.Load()))
+		case <-tick.C:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				report.Dropped++
+			}
+		}
+	}
+	close(jobs)
+	workers.Wait()
+	stopRun()
+	if err := <-ran; err != nil {
+		return SoakReport{}, err
+	}
+	report.Duration = time.Since(start).Round(time.Millisecond)
+	report.Requests, report.Errors = t.requests.Load(), t.errors.Load()
+	report.Statuses = t.statusCounts()
+
+	diff := settle(baseline, cfg.MaxGoroutineGrowth)
+	report.EndStats = sampleRuntime(report.Duration, report.Requests)
+	report.GoroutineGrowth = diff.GoroutineGrowth
+	for _, g := range diff.LeakedGoroutines {
+		report.LeakedGoroutines = append(report.LeakedGoroutines, LeakedGoroutine{g.ID, g.State, g.Stack})
+	}
+	report.evaluate(cfg)
+	return report, nil
+}
+
+// settle waits up to soakSettle for goroutines to exit, like guard does
+// after a test, and compares the runtime against baseline
+func settle(baseline *heapruntime.Snapshot, maxGrowth int) *heapruntime.Diff {
+	deadline := time.Now().Add(soakSettle)
+	for {
+		diff := baseline.Compare()
+		if diff.GoroutineGrowth <= maxGrowth || time.Now().After(deadline) {
+			return diff
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// evaluate fills in the trends and records a failure for each threshold
+// the run exceeded
+func (r *SoakReport) evaluate(cfg SoakConfig) {
+	steady := r.Samples[int(float64(len(r.Samples))*soakWarmup):]
+	if len(steady) > 0 {
+		r.HeapGrowth = int64(r.EndStats.HeapAlloc) - int64(steady[0].HeapAlloc)
+	}
+	r.HeapSlope = slopePerMinute(steady, func(s SoakSample) float64 { return float64(s.HeapAlloc) })
+	r.GoroutineSlope = slopePerMinute(steady, func(s SoakSample) float64 { return float64(s.Goroutines) })
+
+	if r.GoroutineGrowth > cfg.MaxGoroutineGrowth {
+		r.Failures = append(r.Failures, fmt.Sprintf("goroutine leak: %d goroutines outlived the instance (max %d)",
+			r.GoroutineGrowth, cfg.MaxGoroutineGrowth))
+	}
+	if r.HeapGrowth > cfg.MaxHeapGrowth {
+		r.Failures = append(r.Failures, fmt.Sprintf("heap regression: live heap grew by %s after warm-up (max %s)",
+			formatBytes(r.HeapGrowth), formatBytes(cfg.MaxHeapGrowth)))
+	}
+	if r.Errors > 0 {
+		r.Failures = append(r.Failures, fmt.Sprintf("%d requests failed with a server error", r.Errors))
+	}
+}
+
+// slopePerMinute fits a least-squares line through the samples and
+// returns its slope per minute of elapsed time
+func slopePerMinute(samples []SoakSample, value func(SoakSample) float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x, y := s.Elapsed.Minutes(), value(s)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// sampleRuntime records the runtime state after a collection, so the heap
+// figure is the live heap rather than garbage waiting to be swept
+func sampleRuntime(elapsed time.Duration, requests int64) SoakSample {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return SoakSample{
+		Elapsed:     elapsed.Round(time.Millisecond),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   m.HeapAlloc,
+		HeapObjects: m.HeapObjects,
+		Requests:    requests,
+	}
+}
+
+// WriteText writes the report for a terminal: a summary, every sample and
+// the verdict
+func (r SoakReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "soak: %s at %d req/s: %d requests, %d server errors, %d dropped\n",
+		r.Duration, r.RPS, r.Requests, r.Errors, r.Dropped)
+	fmt.Fprintf(&b, "goroutines: %d at start, %+d after close, %+.1f/min after warm-up\n",
+		r.StartStats.Goroutines, r.GoroutineGrowth, r.GoroutineSlope)
+	fmt.Fprintf(&b, "heap: %s at start, %s at end, %s after warm-up, %s/min trend\n\n",
+		formatBytes(int64(r.StartStats.HeapAlloc)), formatBytes(int64(r.EndStats.HeapAlloc)),
+		signedBytes(r.HeapGrowth), signedBytes(int64(r.HeapSlope)))
+
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ELAPSED\tREQUESTS\tGOROUTINES\tHEAP\tOBJECTS\t")
+	for _, s := range r.Samples {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t\n", s.Elapsed, s.Requests, s.Goroutines, formatBytes(int64(s.HeapAlloc)), s.HeapObjects)
+	}
+	tw.Flush()
+
+	for _, g := range r.LeakedGoroutines {
+		fmt.Fprintf(&b, "\nleaked goroutine %d [%s]:\n%s\n", g.ID, g.State, g.Stack)
+	}
+	b.WriteString("\n")
+	for _, f := range r.Failures {
+		fmt.Fprintf(&b, "FAIL: %s\n", f)
+	}
+	if r.Passed() {
+		b.WriteString("PASS\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r SoakReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func formatBytes(n int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+}
+
+func signedBytes(n int64) string {
+	if n < 0 {
+		return "-" + formatBytes(-n)
+	}
+	return "+" + formatBytes(n)
+}
+
+// soakTraffic issues the requests of a soak run against a fixed-size
+// population of users. Load tests send the same mix to a remote target.
+type soakTraffic struct {
+	client *http.Client
+	// base is the URL paths are relative to, and header is added to
+	// every request, e.g. credentials
+	base   string
+	header http.Header
+	// observe, if set, is told the outcome of every request
+	observe func(op string, status int, elapsed time.Duration)
+
+	requests atomic.Int64
+	errors   atomic.Int64
+	next     atomic.Int64
+
+	mu       sync.Mutex
+	ids      []ID
+	statuses map[int]int64
+}
+
+func newSoakTraffic(client *http.Client) *soakTraffic {
+	return &soakTraffic{client: client, base: "http://quickserve", statuses: make(map[int]int64)}
+}
+
+// request sends one request: a create while the population is short, and
+// otherwise a read, a list or a delete
+func (t *soakTraffic) request() {
+	t.mu.Lock()
+	var id ID
+	if len(t.ids) > 0 {
+		id = t.ids[rand.IntN(len(t.ids))]
+	}
+	short := len(t.ids) < soakPopulation
+	t.mu.Unlock()
+
+	var op string
+	var status int
+	start := time.Now()
+	switch n := rand.IntN(10); {
+	case short || id == 0:
+		op = "create"
+		var created User
+		status = t.do(http.MethodPost, "/users", t.newUser(), &created)
+		if status == http.StatusCreated {
+			t.mu.Lock()
+			t.ids = append(t.ids, created.ID)
+			t.mu.Unlock()
+		}
+	case n < 6:
+		op = "get"
+		status = t.do(http.MethodGet, "/users/"+id.String(), "", nil)
+	case n < 8:
+		op = "list"
+		status = t.do(http.MethodGet, "/users", "", nil)
+	default:
+		op = "delete"
+		t.mu.Lock()
+		t.ids = removeID(t.ids, id)
+		t.mu.Unlock()
+		status = t.do(http.MethodDelete, "/users/"+id.String(), "", nil)
+	}
+	if t.observe != nil {
+		t.observe(op, status, time.Since(start))
+	}
+
+	t.requests.Add(1)
+	if status == 0 || status >= http.StatusInternalServerError {
+		t.errors.Add(1)
+	}
+	t.mu.Lock()
+	t.statuses[status]++
+	t.mu.Unlock()
+}
+
+func (t *soakTraffic) newUser() string {
+	n := t.next.Add(1)
+	return fmt.Sprintf(`{"name":"Soak User %d","email":"soak-%d@example.com"}`, n, n)
+}
+
+// do sends a request and returns its status, or 0 if it could not be sent
+func (t *soakTraffic) do(method, path, body string, into any) int {
+	req, err := http.NewRequest(method, t.base+path, strings.NewReader(body))
+	if err != nil {
+		return 0
+	}
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	if into != nil {
+		json.NewDecoder(resp.Body).Decode(into)
+	} else {
+		io.Copy(io.Discard, resp.Body)
+	}
+	return resp.StatusCode
+}
+
+func (t *soakTraffic) statusCounts() map[int]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := make(map[int]int64, len(t.statuses))
+	for status, n := range t.statuses {
+		counts[status] = n
+	}
+	return counts
+}
+
+func removeID(ids []ID, id ID) []ID {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,77 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSoak(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	report, err := Soak(context.Background(), SoakConfig{
+		Duration:       500 * time.Millisecond,
+		RPS:            200,
+		SampleInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed() {
+		t.Fatalf("expected a clean run, got %v", report.Failures)
+	}
+	if report.Requests == 0 || report.Statuses[http.StatusCreated] == 0 {
+		t.Errorf("expected traffic including creates, got %d requests: %v", report.Requests, report.Statuses)
+	}
+	if len(report.Samples) < 3 {
+		t.Errorf("expected a sample every 100ms, got %d", len(report.Samples))
+	}
+
+	var text bytes.Buffer
+	report.WriteText(&text)
+	if !strings.Contains(text.String(), "ELAPSED") || !strings.HasSuffix(text.String(), "PASS\n") {
+		t.Errorf("expected a sample table and a verdict, got:\n%s", text.String())
+	}
+}
+
+func TestSoakInvalidConfig(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	if _, err := Soak(context.Background(), SoakConfig{Duration: time.Second}); err == nil {
+		t.Error("expected an error without a request rate")
+	}
+}
+
+func TestSoakReportEvaluate(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	samples := make([]SoakSample, 10)
+	for i := range samples {
+		samples[i] = SoakSample{
+			Elapsed:    time.Duration(i) * time.Minute,
+			Goroutines: 40,
+			HeapAlloc:  uint64(10+i) << 20,
+		}
+	}
+	r := SoakReport{
+		Samples:         samples,
+		EndStats:        SoakSample{HeapAlloc: 100 << 20},
+		GoroutineGrowth: 2,
+	}
+	r.evaluate(SoakConfig{MaxHeapGrowth: 32 << 20})
+
+	if r.HeapGrowth != 89<<20 {
+		t.Errorf("expected growth from the first sample after warm-up, got %d", r.HeapGrowth)
+	}
+	if r.HeapSlope != 1<<20 || r.GoroutineSlope != 0 {
+		t.Errorf("expected 1 MiB/min heap and flat goroutine trends, got %f and %f", r.HeapSlope, r.GoroutineSlope)
+	}
+	if len(r.Failures) != 2 || !strings.HasPrefix(r.Failures[0], "goroutine leak") || !strings.HasPrefix(r.Failures[1], "heap regression") {
+		t.Errorf("expected a goroutine leak and a heap regression, got %v", r.Failures)
+	}
+}
@@ -0,0 +1,115 @@
+package quickserve
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/harshakonda/quickserve/events"
+)
+
+// userEventsPath streams user events as Server-Sent Events
+const userEventsPath = "/users/events"
+
+// isEventStream reports whether r asks for Server-Sent Events
+func isEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// HandleUserEvents handles GET /users/events, streaming user events as
+// Server-Sent Events named after their type. ?events= limits the stream
+// like it does for /ws. A reconnecting client sends the last ID it saw in
+// Last-Event-ID and first gets every event it missed, from the audit log.
+// A client that falls too far behind is disconnected; it resumes the same
+// way.
+func (s *Server) HandleUserEvents(w http.ResponseWriter, r *http.Request) {
+	types, err := eventTypesParam(r)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var last ID
+	resume := r.Header.Get("Last-Event-ID")
+	if resume != "" {
+		if last, err = ParseID(resume); err != nil {
+			httpError(w, r, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Subscribed before replaying, so nothing recorded in between is
+	// lost; what was replayed is skipped when it arrives live
+	sub := s.events.Subscribe(types)
+	defer s.events.Unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout is meant for requests, not streams
+	rc.SetWriteDeadline(time.Time{})
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Proxies such as nginx would otherwise hold events back
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if resume != "" {
+		for _, e := range s.audit.Query(AuditFilter{AfterID: last}) {
+			ev, ok := eventFor(e)
+			if !ok || !sub.Wants(ev.Type) || !eventVisible(r.Context(), ev) {
+				continue
+			}
+			data, err := s.encodeEvent(ev)
+			if err != nil || writeSSE(w, events.Message[UserEvent]{Event: ev, Data: data}) != nil {
+				return
+			}
+			last = ev.ID
+		}
+	}
+	// Without a flush the client would not know the stream has started
+	if rc.Flush() != nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.keepaliveInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-sub.Events():
+			if !m.Event.remote && m.Event.ID <= last || !eventVisible(r.Context(), m.Event) {
+				continue
+			}
+			if writeSSE(w, m) != nil || rc.Flush() != nil {
+				return
+			}
+			if !m.Event.remote {
+				last = m.Event.ID
+			}
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case <-sub.Dropped():
+			return
+		case <-r.Context().Done():
+			return
+		case <-s.streamsDone:
+			return
+		}
+	}
+}
+
+// writeSSE writes m as one event. Its JSON holds no newlines, so it
+// fits on a single data line. Events from other instances carry no id,
+// which would not resume from this instance's audit log; the client
+// keeps the last one it got.
+func writeSSE(w io.Writer, m events.Message[UserEvent]) error {
+	if !m.Event.remote {
+		if _, err := fmt.Fprintf(w, "id: %s\n", m.Event.ID); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", m.Event.Type, strings.TrimSpace(string(m.Data)))
+	return err
+}
@@ -0,0 +1,148 @@
+package quickserve
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// sseEvent is one event as read off the stream
+type sseEvent struct {
+	id, event string
+	data      UserEvent
+}
+
+// openEvents connects to path with the given Last-Event-ID, if any
+func openEvents(t *testing.T, ts *httptest.Server, path, lastID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		resp.Body.Close()
+		t.Fatalf("expected a stream, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+// readSSE reads the next event, skipping comments
+func readSSE(t *testing.T, br *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && ev.id != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+				t.Fatalf("decode %q: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestUserEventsResume(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer()
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+	post := func(method, path, body string) {
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	post(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	post(http.MethodPost, "/users", `{"name":"Bob","email":"bob@test.com"}`)
+
+	// The client saw Alice's creation, so it is sent Bob's, then live ones
+	resp, br := openEvents(t, ts, "/users/events", "1")
+	defer resp.Body.Close()
+	if ev := readSSE(t, br); ev.id != "2" || ev.event != EventUserCreated || ev.data.UserID != 2 {
+		t.Errorf("expected Bob's creation to be replayed, got %+v", ev)
+	}
+	post(http.MethodDelete, "/users/1", "")
+	if ev := readSSE(t, br); ev.id != "3" || ev.event != EventUserDeleted || ev.data.UserID != 1 {
+		t.Errorf("expected Alice's deletion, got %+v", ev)
+	}
+
+	// Without Last-Event-ID nothing is replayed
+	fresh, freshReader := openEvents(t, ts, "/users/events?events=user.created", "")
+	defer fresh.Body.Close()
+	post(http.MethodDelete, "/users/2", "")
+	post(http.MethodPost, "/users", `{"name":"Carol","email":"carol@test.com"}`)
+	if ev := readSSE(t, freshReader); ev.id != "5" || ev.event != EventUserCreated {
+		t.Errorf("expected only Carol's creation, got %+v", ev)
+	}
+}
+
+func TestUserEventsKeepaliveAndDrain(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer(WithStreamKeepalive(10 * time.Millisecond))
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+
+	resp, br := openEvents(t, ts, "/users/events", "")
+	defer resp.Body.Close()
+	if line, err := br.ReadString('\n'); err != nil || line != ": keepalive\n" {
+		t.Errorf("expected a keepalive comment, got %q, %v", line, err)
+	}
+
+	// Draining ends streams so clients reconnect elsewhere
+	s.Drain()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, br)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the stream to end cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected draining to end the stream")
+	}
+}
+
+func TestUserEventsInvalid(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	h := NewServer().Routes()
+	unknownType := httptest.NewRequest(http.MethodGet, "/users/events?events=user.renamed", nil)
+	badResume := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+	badResume.Header.Set("Last-Event-ID", "latest")
+	for _, req := range []*http.Request{unknownType, badResume} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %v: expected 400, got %d", req.URL, req.Header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package quickserve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// systemdScheme prefixes listen addresses naming a socket systemd
+	// passed in, by its FileDescriptorName=
+	systemdScheme = "systemd:"
+	// sdListenFDsStart is the first file descriptor systemd passes
+	sdListenFDsStart = 3
+)
+
+// systemdListeners are the sockets systemd passed to the process. They
+// are taken over once; LISTEN_* are then unset so child processes do not
+// claim them too.
+var systemdListeners = sync.OnceValues(func() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	return activationListeners(os.Getenv, os.Getpid(), func(i int) *os.File {
+		return os.NewFile(uintptr(sdListenFDsStart+i), "")
+	})
+})
+
+// activationListeners turns the files named by LISTEN_FDS and
+// LISTEN_FDNAMES into listeners keyed by name. Sockets without a name are
+// keyed by their position, counting from 0. None are returned unless
+// LISTEN_PID is pid.
+func activationListeners(getenv func(string) string, pid int, file func(i int) *os.File) (map[string]net.Listener, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(pid) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	lns := make(map[string]net.Listener, n)
+	for i := range n {
+		name := strconv.Itoa(i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := file(i)
+		ln, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, ln := range lns {
+				ln.Close()
+			}
+			return nil, fmt.Errorf("socket %s from systemd: %w", name, err)
+		}
+		lns[name] = ln
+	}
+	return lns, nil
+}
+
+// systemdListener returns the socket systemd passed in under name
+func systemdListener(name string) (net.Listener, error) {
+	lns, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+	ln, ok := lns[name]
+	if !ok {
+		return nil, fmt.Errorf("systemd passed no socket named %q", name)
+	}
+	return ln, nil
+}
+
+// sdNotify sends state, such as READY=1, to the service manager. Outside
+// a systemd service with Type=notify it does nothing.
+func sdNotify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if strings.HasPrefix(path, "@") {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdWatchdogInterval returns how often systemd expects WATCHDOG=1, or 0
+// if WatchdogSec= is not set for this process
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// sdWatchdog pings the systemd watchdog at half its interval until ctx is
+// canceled. A process that hangs stops pinging and is restarted.
+func sdWatchdog(ctx context.Context, interval time.Duration) error {
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		if err := sdNotify("WATCHDOG=1"); err != nil {
+			return fmt.Errorf("systemd watchdog: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package quickserve
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestActivationListeners(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var files []*os.File
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ln.(*net.TCPListener).File()
+		ln.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	env := map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "2", "LISTEN_FDNAMES": "api:"}
+	getenv := func(k string) string { return env[k] }
+	file := func(i int) *os.File { return files[i] }
+
+	lns, err := activationListeners(getenv, 42, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lns) != 2 || lns["api"] == nil || lns["1"] == nil {
+		t.Fatalf("expected a named and a numbered socket, got %v", lns)
+	}
+	for _, ln := range lns {
+		ln.Close()
+	}
+
+	if lns, err := activationListeners(getenv, 7, file); lns != nil || err != nil {
+		t.Errorf("expected sockets meant for another process to be ignored, got %v, %v", lns, err)
+	}
+	env["LISTEN_FDS"] = "many"
+	if _, err := activationListeners(getenv, 42, file); err == nil {
+		t.Error("expected an invalid LISTEN_FDS to be reported")
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("expected no-op outside systemd, got %v", err)
+	}
+
+	path := filepath.Join(filepath.Dir(socketPath(t)), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+	read := func() string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		buf := make([]byte, 64)
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	if err := sdNotify("STOPPING=1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "STOPPING=1" {
+		t.Errorf("expected STOPPING=1, got %q", got)
+	}
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("WATCHDOG_USEC", "20000")
+	interval := sdWatchdogInterval()
+	if interval != 20*time.Millisecond {
+		t.Fatalf("expected 20ms, got %s", interval)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sdWatchdog(ctx, interval) }()
+	for range 2 {
+		if got := read(); got != "WATCHDOG=1" {
+			t.Errorf("expected WATCHDOG=1, got %q", got)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
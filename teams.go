@@ -0,0 +1,166 @@
+package quickserve
+
+import (
+	"net/http"
+)
+
+// The /teams routes address teams by ID alone, whatever their org, for
+// clients that only deal with teams. They share the org store, so a team
+// created here is listed under /orgs/{org}/teams and the other way around.
+
+// createTeamRequest is the body of POST /teams
+type createTeamRequest struct {
+	OrgID    ID     `json:"org_id"`
+	ParentID ID     `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+}
+
+// addTeamMemberRequest is the body of POST /teams/{team}/members
+type addTeamMemberRequest struct {
+	UserID ID   `json:"user_id"`
+	Role   Role `json:"role,omitempty"`
+}
+
+// teamFromPath returns the team named by the {team} path value, answering
+// 400 or 404 if there is none
+func (s *Server) teamFromPath(w http.ResponseWriter, r *http.Request) (Team, bool) {
+	id, err := ParseID(r.PathValue("team"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return Team{}, false
+	}
+	team, ok := s.orgs.GetTeam(id)
+	if !ok {
+		httpError(w, r, "team not found", http.StatusNotFound)
+		return Team{}, false
+	}
+	return team, true
+}
+
+// HandleListAllTeams handles GET /teams, listing the teams of every org
+func (s *Server) HandleListAllTeams(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, s.orgs.ListTeams())
+}
+
+// HandleCreateOrgTeam handles POST /teams, adding a team to the org named
+// in the body
+func (s *Server) HandleCreateOrgTeam(w http.ResponseWriter, r *http.Request) {
+	var req createTeamRequest
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		httpError(w, r, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	team, err := s.orgs.CreateTeam(req.OrgID, req.ParentID, req.Name)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "team", team.ID.String(), nil, team)
+	s.writeJSON(w, r, http.StatusCreated, team)
+}
+
+// HandleGetTeam handles GET /teams/{team}
+func (s *Server) HandleGetTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamFromPath(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, team)
+}
+
+// HandleDeleteOrgTeam handles DELETE /teams/{team}, removing its
+// sub-teams and memberships too
+func (s *Server) HandleDeleteOrgTeam(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamFromPath(w, r)
+	if !ok {
+		return
+	}
+	if !s.orgs.DeleteTeam(team.OrgID, team.ID) {
+		httpError(w, r, "team not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "team", team.ID.String(), team, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListTeamMembers handles GET /teams/{team}/members, including the
+// members who inherit their role from the org or a parent team
+func (s *Server) HandleListTeamMembers(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamFromPath(w, r)
+	if !ok {
+		return
+	}
+	members, err := s.orgs.Members(team.OrgID, team.ID)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, members)
+}
+
+// HandleAddTeamMember handles POST /teams/{team}/members, adding a user
+// to the team or changing their role there. It answers 201 for a new
+// member and 200 for a role change.
+func (s *Server) HandleAddTeamMember(w http.ResponseWriter, r *http.Request) {
+	team, ok := s.teamFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req addTeamMemberRequest
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = RoleUser
+	}
+	if !req.Role.Valid() {
+		httpError(w, r, "invalid role", http.StatusBadRequest)
+		return
+	}
+	if !s.userExists(w, r, req.UserID) {
+		return
+	}
+
+	key := memberKey{team.OrgID, team.ID, req.UserID}
+	prev, existed := s.orgs.Member(key.org, key.team, key.user)
+	m, err := s.orgs.SetMember(key.org, key.team, key.user, req.Role)
+	if err != nil {
+		writeOrgError(w, r, err)
+		return
+	}
+	// Deleting a user drops their memberships once the user is gone, so
+	// one deleted meanwhile may have missed this one
+	if !s.userExists(w, r, req.UserID) {
+		s.orgs.RemoveMember(key.org, key.team, key.user)
+		return
+	}
+	if existed {
+		s.recordAudit(r, AuditUpdate, "membership", key.String(), prev, m)
+		s.writeJSON(w, r, http.StatusOK, m)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "membership", key.String(), nil, m)
+	s.writeJSON(w, r, http.StatusCreated, m)
+}
+
+// userExists reports whether the user with id exists, answering 404 or
+// the store error if not
+func (s *Server) userExists(w http.ResponseWriter, r *http.Request, id ID) bool {
+	_, ok, err := s.users(r.Context()).Get(r.Context(), id)
+	if err != nil {
+		writeStoreError(w, r, err)
+		return false
+	}
+	if !ok {
+		httpError(w, r, "user not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package quickserve
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestTeamEndpoints(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	alice := addUser(t, server, User{Name: "Alice", Email: "alice@example.com"})
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com"})
+	acme := server.orgs.CreateOrg("Acme")
+	server.orgs.SetMember(acme.ID, 0, alice.ID, RoleAdmin)
+	routes := server.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodPost, "/teams", `{"org_id":1,"name":"Engineering"}`)
+	var eng Team
+	json.NewDecoder(w.Body).Decode(&eng)
+	if w.Code != http.StatusCreated || eng.OrgID != acme.ID || eng.Name != "Engineering" {
+		t.Fatalf("expected the team created, got %d: %+v", w.Code, eng)
+	}
+	for body, want := range map[string]int{
+		`{"org_id":1}`:                            http.StatusBadRequest,
+		`{"org_id":9,"name":"Ops"}`:               http.StatusNotFound,
+		`{"org_id":1,"parent_id":9,"name":"Ops"}`: http.StatusNotFound,
+	} {
+		if w := do(http.MethodPost, "/teams", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, w.Code)
+		}
+	}
+	// Teams made under an org are listed here too
+	server.orgs.CreateTeam(acme.ID, eng.ID, "Backend")
+	var all []Team
+	json.NewDecoder(do(http.MethodGet, "/teams", "").Body).Decode(&all)
+	if len(all) != 2 || all[1].ParentID != eng.ID {
+		t.Errorf("expected both teams, got %+v", all)
+	}
+
+	if w := do(http.MethodPost, "/teams/1/members", `{"user_id":2}`); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"role":"user"`) {
+		t.Errorf("expected bob added as a user, got %d: %s", w.Code, w.Body)
+	}
+	if w := do(http.MethodPost, "/teams/1/members", `{"user_id":2,"role":"admin"}`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for a role change, got %d: %s", w.Code, w.Body)
+	}
+	for body, want := range map[string]int{
+		`{"user_id":9}`:               http.StatusNotFound,
+		`{"user_id":2,"role":"root"}`: http.StatusBadRequest,
+	} {
+		if w := do(http.MethodPost, "/teams/1/members", body); w.Code != want {
+			t.Errorf("%s: expected %d, got %d", body, want, w.Code)
+		}
+	}
+	var members []Membership
+	json.NewDecoder(do(http.MethodGet, "/teams/1/members", "").Body).Decode(&members)
+	if len(members) != 2 || members[0].UserID != alice.ID || !members[0].Inherited || members[1].Role != RoleAdmin {
+		t.Errorf("expected alice through the org and bob directly, got %+v", members)
+	}
+
+	// Deleting a user cascades to their memberships
+	do(http.MethodDelete, "/users/"+bob.ID.String(), "")
+	json.NewDecoder(do(http.MethodGet, "/teams/1/members", "").Body).Decode(&members)
+	if len(members) != 1 || members[0].UserID != alice.ID {
+		t.Errorf("expected the deleted user gone from the team, got %+v", members)
+	}
+
+	if w := do(http.MethodDelete, "/teams/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	for _, path := range []string{"/teams/1", "/teams/2", "/teams/2/members"} {
+		if w := do(http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected sub-teams deleted with their parent, got %d", path, w.Code)
+		}
+	}
+	if w := do(http.MethodGet, "/teams/x", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad id, got %d", w.Code)
+	}
+}
+
+func TestTeamRoleGrantsPermissions(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ca := newTestCA(t)
+	server := NewServer(WithClientCAs(ca.pool))
+	alice := addUser(t, server, User{Name: "Alice", Email: "alice@example.com"})
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com"})
+	acme := server.orgs.CreateOrg("Acme")
+	eng, _ := server.orgs.CreateTeam(acme.ID, 0, "Engineering")
+	ops, _ := server.orgs.CreateTeam(acme.ID, 0, "Ops")
+	server.orgs.SetMember(acme.ID, eng.ID, alice.ID, RoleAdmin)
+	routes := server.Routes()
+
+	cert, _ := x509.ParseCertificate(ca.issue(t, "alice", "alice@example.com").Certificate[0])
+	do := func(method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	body := `{"user_id":` + bob.ID.String() + `}`
+	if code := do(http.MethodPost, "/teams/"+eng.ID.String()+"/members", body); code != http.StatusCreated {
+		t.Errorf("expected a team admin to manage their team, got %d", code)
+	}
+	if code := do(http.MethodPost, "/teams/"+ops.ID.String()+"/members", body); code != http.StatusForbidden {
+		t.Errorf("expected 403 in another team, got %d", code)
+	}
+	if code := do(http.MethodPost, "/teams", `{"org_id":1,"name":"Mine"}`); code != http.StatusForbidden {
+		t.Errorf("expected 403 creating teams without a role in the org, got %d", code)
+	}
+}
@@ -0,0 +1,300 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// TenantSource is where a request's tenant is taken from
+type TenantSource string
+
+const (
+	// TenantFromHeader takes the tenant from the X-Tenant-ID header
+	TenantFromHeader TenantSource = "header"
+	// TenantFromSubdomain takes the tenant from the label in front of
+	// the base domain, such as acme in acme.example.com
+	TenantFromSubdomain TenantSource = "subdomain"
+	// TenantFromClaim takes the tenant from the caller's credentials: the
+	// tenant claim of an OIDC token, or the tenant of a logged-in user
+	TenantFromClaim TenantSource = "claim"
+)
+
+// Valid reports whether src is a known source
+func (src TenantSource) Valid() bool {
+	return src == TenantFromHeader || src == TenantFromSubdomain || src == TenantFromClaim
+}
+
+// Tenancy isolates tenants from each other. Every request belongs to the
+// first tenant its sources name, or to the default tenant "" if none
+// does, and only sees and changes that tenant's users; the users of other
+// tenants are not found. Callers whose credentials name a tenant are
+// refused with 403 in any other. Callers whose credentials name none,
+// such as API keys, may address any tenant.
+type Tenancy struct {
+	// Sources are tried in order
+	Sources []TenantSource
+	// Domain is the base domain of TenantFromSubdomain, such as
+	// example.com
+	Domain string
+}
+
+// WithTenancy isolates tenants as t describes. Without it X-Tenant-ID
+// only selects tenant settings, and every request sees every user.
+func WithTenancy(t Tenancy) Option {
+	return func(s *Server) {
+		s.tenancy = &t
+	}
+}
+
+type tenantKey struct{}
+
+// withTenant returns a copy of ctx scoped to tenant
+func withTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant a request was scoped to
+func tenantFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tenantKey{}).(string)
+	return t, ok
+}
+
+// tenantFromRequest returns the request's tenant, or "" for none
+func (s *Server) tenantFromRequest(r *http.Request) string {
+	if t, ok := tenantFromContext(r.Context()); ok {
+		return t
+	}
+	return s.requestTenant(r)
+}
+
+// requestTenant returns the first tenant named by the configured sources;
+// without tenancy that is X-Tenant-ID. The claim is only known once the
+// request is authenticated.
+func (s *Server) requestTenant(r *http.Request) string {
+	if s.tenancy == nil {
+		return r.Header.Get(TenantHeader)
+	}
+	for _, src := range s.tenancy.Sources {
+		var t string
+		switch src {
+		case TenantFromHeader:
+			t = r.Header.Get(TenantHeader)
+		case TenantFromSubdomain:
+			t = subdomainTenant(r.Host, s.tenancy.Domain)
+		case TenantFromClaim:
+			if p, ok := PrincipalFromContext(r.Context()); ok {
+				t = p.Tenant
+			}
+		}
+		if t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// subdomainTenant returns the single label host has in front of domain,
+// or "" if there is none
+func subdomainTenant(host, domain string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	label, ok := strings.CutSuffix(host, "."+strings.ToLower(domain))
+	if !ok || domain == "" || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// scopeTenant scopes requests to the tenant the request names. Requests
+// that authenticate are scoped again by bindTenant once the claim is
+// known.
+func (s *Server) scopeTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), s.requestTenant(r))))
+	})
+}
+
+// bindTenant checks the tenant of a scoped request, whose context
+// carries p, against p's. A request that named no tenant may be scoped to
+// p's now. It reports false when p is bound to another tenant.
+func (s *Server) bindTenant(r *http.Request, p Principal) (context.Context, bool) {
+	ctx := r.Context()
+	tenant, scoped := tenantFromContext(ctx)
+	if !scoped {
+		return ctx, true
+	}
+	if tenant == "" && s.tenancy != nil {
+		tenant = s.requestTenant(r)
+	}
+	if p.Tenant != "" && tenant != p.Tenant {
+		return ctx, false
+	}
+	return withTenant(ctx, tenant), true
+}
+
+// users returns the user store as the request with ctx sees it: the
+// users of its tenant if it is scoped to one
+func (s *Server) users(ctx context.Context) UserStore {
+	if tenant, ok := tenantFromContext(ctx); ok {
+		return tenantScope{s.store, tenant}
+	}
+	return s.store
+}
+
+// usersOf returns the users of tenant, or every user without tenancy
+func (s *Server) usersOf(tenant string) UserStore {
+	if s.tenancy == nil {
+		return s.store
+	}
+	return tenantScope{s.store, tenant}
+}
+
+// eventVisible reports whether the request with ctx may see ev
+func eventVisible(ctx context.Context, ev UserEvent) bool {
+	tenant, ok := tenantFromContext(ctx)
+	return !ok || ev.tenant == tenant
+}
+
+// TenantIndex is implemented by user stores that partition their users
+// by tenant, so one tenant's users are found without going through
+// everyone else's. The users of other stores are filtered instead.
+type TenantIndex interface {
+	// ListTenant returns the users of tenant ordered by ID
+	ListTenant(ctx context.Context, tenant string) ([]User, error)
+	FindByEmailInTenant(ctx context.Context, tenant, email string) (User, bool, error)
+}
+
+// tenantIndex returns the tenant index of st, looking through the tracing
+// decorator
+func tenantIndex(st UserStore) (TenantIndex, bool) {
+	if t, ok := st.(tracedUserStore); ok {
+		if _, ok := t.UserStore.(TenantIndex); !ok {
+			return nil, false
+		}
+		return t, true
+	}
+	x, ok := st.(TenantIndex)
+	return x, ok
+}
+
+// tenantScope is the part of a user store belonging to one tenant. The
+// users of other tenants are not found, and inserted users join the
+// tenant.
+type tenantScope struct {
+	UserStore
+	tenant string
+}
+
+func (st tenantScope) Insert(ctx context.Context, user User, limit, tenantLimit int) (User, error) {
+	user.Tenant = st.tenant
+	return st.UserStore.Insert(ctx, user, limit, tenantLimit)
+}
+
+func (st tenantScope) Get(ctx context.Context, id ID) (User, bool, error) {
+	u, ok, err := st.UserStore.Get(ctx, id)
+	if err != nil || !ok || u.Tenant != st.tenant {
+		return User{}, false, err
+	}
+	return u, true, nil
+}
+
+func (st tenantScope) List(ctx context.Context) ([]User, error) {
+	if x, ok := tenantIndex(st.UserStore); ok {
+		return x.ListTenant(ctx, st.tenant)
+	}
+	users, err := st.UserStore.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(users, func(u User) bool { return u.Tenant != st.tenant }), nil
+}
+
+func (st tenantScope) FindByEmail(ctx context.Context, email string) (User, bool, error) {
+	if x, ok := tenantIndex(st.UserStore); ok {
+		return x.FindByEmailInTenant(ctx, st.tenant, email)
+	}
+	// The email may be taken in other tenants too, so the store's own
+	// lookup could find one of those
+	users, err := st.List(ctx)
+	if err != nil {
+		return User{}, false, err
+	}
+	for _, u := range users {
+		if u.Email == email {
+			return u, true, nil
+		}
+	}
+	return User{}, false, nil
+}
+
+func (st tenantScope) Delete(ctx context.Context, id ID) (bool, error) {
+	if _, ok, err := st.Get(ctx, id); err != nil || !ok {
+		return false, err
+	}
+	return st.UserStore.Delete(ctx, id)
+}
+
+// tenantSearcher keeps the search results of one tenant
+type tenantSearcher struct {
+	UserSearcher
+	tenant string
+}
+
+func (ts tenantSearcher) SearchUsers(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	// Other tenants' users take up part of each page, so ask for more
+	// until there are enough or there is nothing left
+	for n := limit; ; n *= 2 {
+		results, err := ts.UserSearcher.SearchUsers(ctx, query, n)
+		if err != nil {
+			return nil, err
+		}
+		full := len(results) == n
+		results = slices.DeleteFunc(results, func(r SearchResult) bool { return r.User.Tenant != ts.tenant })
+		if len(results) >= limit || !full {
+			return results[:min(len(results), limit)], nil
+		}
+	}
+}
+
+// ListTenant returns the users of tenant ordered by ID
+func (s *MemoryUserStore) ListTenant(ctx context.Context, tenant string) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.tenantMu.Lock()
+	ids := make([]ID, 0, len(s.byTenant[tenant]))
+	for id := range s.byTenant[tenant] {
+		ids = append(ids, id)
+	}
+	s.tenantMu.Unlock()
+
+	users := make([]User, 0, len(ids))
+	for _, id := range ids {
+		// Skip users deleted since
+		if u, ok, _ := s.Get(ctx, id); ok {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, func(a, b User) int { return cmp.Compare(a.ID, b.ID) })
+	return users, nil
+}
+
+// FindByEmailInTenant finds a user of tenant by email
+func (s *MemoryUserStore) FindByEmailInTenant(ctx context.Context, tenant, email string) (User, bool, error) {
+	users, err := s.ListTenant(ctx, tenant)
+	if err != nil {
+		return User{}, false, err
+	}
+	for _, u := range users {
+		if u.Email == email {
+			return u, true, nil
+		}
+	}
+	return User{}, false, nil
+}
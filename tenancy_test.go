@@ -0,0 +1,174 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestSubdomainTenant(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for host, want := range map[string]string{
+		"acme.example.com":      "acme",
+		"ACME.example.com:8443": "acme",
+		"acme.example.com.":     "acme",
+		"example.com":           "",
+		"a.b.example.com":       "",
+		"acme.example.org":      "",
+		"acmeexample.com":       "",
+	} {
+		if got := subdomainTenant(host, "example.com"); got != want {
+			t.Errorf("%s: expected %q, got %q", host, want, got)
+		}
+	}
+}
+
+func TestMemoryUserStoreTenants(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	store := NewMemoryUserStore()
+	store.tenantEmails = true
+	alice, _ := store.Insert(ctx, User{Name: "Alice", Email: "same@example.com", Tenant: "acme"}, 0, 0)
+	store.Insert(ctx, User{Name: "Bob", Email: "same@example.com", Tenant: "globex"}, 0, 0)
+	if _, err := store.Insert(ctx, User{Name: "Bob Again", Email: "same@example.com", Tenant: "globex"}, 0, 0); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected the email taken in globex, got %v", err)
+	}
+	carol, _ := store.Insert(ctx, User{Name: "Carol", Email: "carol@example.com"}, 0, 0)
+
+	if users, _ := store.ListTenant(ctx, "acme"); len(users) != 1 || users[0].ID != alice.ID {
+		t.Errorf("expected only acme's users, got %+v", users)
+	}
+	if users, _ := store.ListTenant(ctx, ""); len(users) != 1 || users[0].ID != carol.ID {
+		t.Errorf("expected the default tenant's users, got %+v", users)
+	}
+	// An email may be taken in several tenants
+	if u, ok, _ := store.FindByEmailInTenant(ctx, "globex", "same@example.com"); !ok || u.Name != "Bob" {
+		t.Errorf("expected bob in globex, got %+v", u)
+	}
+	store.Delete(ctx, alice.ID)
+	if users, _ := store.ListTenant(ctx, "acme"); len(users) != 0 {
+		t.Errorf("expected the deleted user gone from the tenant, got %+v", users)
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithTenancy(Tenancy{Sources: []TenantSource{TenantFromHeader, TenantFromSubdomain}, Domain: "example.com"}))
+	routes := server.Routes()
+	do := func(method, path, tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if tenant != "" {
+			req.Header.Set(TenantHeader, tenant)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	var alice, bob User
+	json.NewDecoder(do(http.MethodPost, "/users", "acme", `{"name":"Alice","email":"alice@example.com"}`).Body).Decode(&alice)
+	json.NewDecoder(do(http.MethodPost, "/users", "globex", `{"name":"Bob","email":"bob@example.com"}`).Body).Decode(&bob)
+	if alice.Tenant != "acme" || bob.Tenant != "globex" {
+		t.Fatalf("expected users created in their tenants, got %+v and %+v", alice, bob)
+	}
+
+	var users []User
+	json.NewDecoder(do(http.MethodGet, "/users", "acme", "").Body).Decode(&users)
+	if len(users) != 1 || users[0].ID != alice.ID {
+		t.Errorf("expected only acme's users, got %+v", users)
+	}
+	bobPath := "/users/" + bob.ID.String()
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodGet, bobPath},
+		{http.MethodDelete, bobPath},
+		{http.MethodGet, bobPath + "/posts"},
+		{http.MethodGet, bobPath + "/notifications"},
+	} {
+		if w := do(tt.method, tt.path, "acme", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected another tenant's user not found, got %d", tt.method, tt.path, w.Code)
+		}
+	}
+	if w := do(http.MethodGet, bobPath, "globex", ""); w.Code != http.StatusOK {
+		t.Errorf("expected bob found in his tenant, got %d", w.Code)
+	}
+
+	// The subdomain names the tenant when the header doesn't
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Host = "globex.example.com"
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&users)
+	if len(users) != 1 || users[0].ID != bob.ID {
+		t.Errorf("expected globex's users, got %+v", users)
+	}
+	// Requests naming no tenant are in the default one
+	if w := do(http.MethodGet, "/users", "", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected no users in the default tenant, got %s", w.Body)
+	}
+
+	if w := do(http.MethodGet, "/users/search?q=bob", "acme", ""); strings.Contains(w.Body.String(), "Bob") {
+		t.Errorf("expected search scoped to the tenant, got %s", w.Body)
+	}
+	var changes changesPage
+	json.NewDecoder(do(http.MethodGet, "/users/changes", "acme", "").Body).Decode(&changes)
+	if len(changes.Changes) != 1 || changes.Changes[0].UserID != alice.ID {
+		t.Errorf("expected only acme's changes, got %+v", changes.Changes)
+	}
+}
+
+func TestTenantClaim(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(
+		WithAPIKeyAuth("admin-secret"),
+		WithTenancy(Tenancy{Sources: []TenantSource{TenantFromHeader, TenantFromClaim}}),
+	)
+	routes := server.Routes()
+	do := func(method, path string, header http.Header, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	// API keys are bound to no tenant, so they may address any
+	for _, tenant := range []string{"acme", "globex"} {
+		body := `{"name":"Admin","email":"admin@example.com","password":"correct horse"}`
+		if w := do(http.MethodPost, "/users", http.Header{APIKeyHeader: {"admin-secret"}, TenantHeader: {tenant}}, body); w.Code != http.StatusCreated {
+			t.Fatalf("expected the user created in %s, got %d: %s", tenant, w.Code, w.Body)
+		}
+	}
+
+	// The same email logs in to the tenant the login names
+	w := do(http.MethodPost, "/login", http.Header{TenantHeader: {"globex"}}, `{"email":"admin@example.com","password":"correct horse"}`)
+	var login struct {
+		Token string `json:"token"`
+		User  User   `json:"user"`
+	}
+	json.NewDecoder(w.Body).Decode(&login)
+	if w.Code != http.StatusOK || login.User.Tenant != "globex" {
+		t.Fatalf("expected a login in globex, got %d: %+v", w.Code, login.User)
+	}
+	bearer := "Bearer " + login.Token
+
+	var users []User
+	json.NewDecoder(do(http.MethodGet, "/users", http.Header{"Authorization": {bearer}}, "").Body).Decode(&users)
+	if len(users) != 1 || users[0].Tenant != "globex" {
+		t.Errorf("expected the claim to name the tenant, got %+v", users)
+	}
+	if w := do(http.MethodGet, "/users", http.Header{"Authorization": {bearer}, TenantHeader: {"acme"}}, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 addressing another tenant, got %d", w.Code)
+	}
+}
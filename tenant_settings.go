@@ -0,0 +1,315 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// TenantHeader identifies the tenant a request belongs to
+	TenantHeader = "X-Tenant-ID"
+	// defaultWebhookLimit is how many webhooks a tenant may register
+	defaultWebhookLimit = 10
+)
+
+// Duration is a time.Duration that encodes as a string such as "720h"
+type Duration time.Duration
+
+// MarshalText encodes the duration in time.Duration.String form
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a time.ParseDuration string
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// TenantSettings are the settings in effect for a tenant
+type TenantSettings struct {
+	RateLimit    *RateLimit      `json:"rate_limit,omitempty"`
+	Features     map[string]bool `json:"features"`
+	WebhookLimit int             `json:"webhook_limit"`
+	Retention    Duration        `json:"retention"`
+	MaxUsers     int             `json:"max_users"`
+}
+
+// TenantOverrides are the settings a tenant overrides; nil fields inherit
+// the server defaults. Features are merged key by key.
+type TenantOverrides struct {
+	RateLimit    *RateLimit      `json:"rate_limit,omitempty"`
+	Features     map[string]bool `json:"features,omitempty"`
+	WebhookLimit *int            `json:"webhook_limit,omitempty"`
+	Retention    *Duration       `json:"retention,omitempty"`
+	MaxUsers     *int            `json:"max_users,omitempty"`
+}
+
+// apply layers o over base
+func (o TenantOverrides) apply(base TenantSettings) TenantSettings {
+	out := base
+	out.Features = maps.Clone(base.Features)
+	if out.Features == nil {
+		out.Features = make(map[string]bool)
+	}
+
+	if o.RateLimit != nil {
+		out.RateLimit = o.RateLimit
+	}
+	for k, v := range o.Features {
+		out.Features[k] = v
+	}
+	if o.WebhookLimit != nil {
+		out.WebhookLimit = *o.WebhookLimit
+	}
+	if o.Retention != nil {
+		out.Retention = *o.Retention
+	}
+	if o.MaxUsers != nil {
+		out.MaxUsers = *o.MaxUsers
+	}
+	return out
+}
+
+// TenantSettingsStore holds per-tenant overrides. With a path it persists
+// every change to a JSON file, replaced atomically.
+type TenantSettingsStore struct {
+	mu        sync.RWMutex
+	path      string
+	overrides map[string]TenantOverrides
+	drift     []SchemaDrift
+}
+
+// NewTenantSettingsStore creates an in-memory store
+func NewTenantSettingsStore() *TenantSettingsStore {
+	return &TenantSettingsStore{overrides: make(map[string]TenantOverrides)}
+}
+
+// LoadTenantSettings opens the store persisted at path, which need not
+// exist yet. Tenants whose overrides do not match the expected schema are
+// left out and reported by Drift.
+func LoadTenantSettings(path string) (*TenantSettingsStore, error) {
+	s := NewTenantSettingsStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tenants map[string]json.RawMessage
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	check := newSchemaCheck("tenant settings", TenantOverrides{})
+	for tenant, raw := range tenants {
+		record := fmt.Sprintf("%s: tenant %s", path, tenant)
+		if !check.record(record, raw) {
+			continue
+		}
+		var o TenantOverrides
+		if err := json.Unmarshal(raw, &o); err != nil {
+			check.add("", "undecodable", "", err.Error(), record)
+			continue
+		}
+		s.overrides[tenant] = o
+	}
+	s.drift = check.report()
+	return s, nil
+}
+
+// Drift reports tenants in the backing file that could not be loaded
+func (s *TenantSettingsStore) Drift() []SchemaDrift {
+	return s.drift
+}
+
+// Get returns a tenant's overrides
+func (s *TenantSettingsStore) Get(tenant string) (TenantOverrides, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.overrides[tenant]
+	return o, ok
+}
+
+// Set replaces a tenant's overrides
+func (s *TenantSettingsStore) Set(tenant string, o TenantOverrides) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, had := s.overrides[tenant]
+	s.overrides[tenant] = o
+	if err := s.saveLocked(); err != nil {
+		if had {
+			s.overrides[tenant] = prev
+		} else {
+			delete(s.overrides, tenant)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a tenant's overrides
+func (s *TenantSettingsStore) Delete(tenant string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.overrides[tenant]
+	if !ok {
+		return false, nil
+	}
+	delete(s.overrides, tenant)
+	if err := s.saveLocked(); err != nil {
+		s.overrides[tenant] = prev
+		return false, err
+	}
+	return true, nil
+}
+
+// saveLocked writes the overrides to a temporary file and renames it into
+// place so a crash never leaves a truncated file
+func (s *TenantSettingsStore) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(s.overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tenants-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// WithTenantSettings uses store for per-tenant overrides
+func WithTenantSettings(store *TenantSettingsStore) Option {
+	return func(s *Server) {
+		s.tenantSettings = store
+	}
+}
+
+// WithFeatureFlags sets the default value of feature flags
+func WithFeatureFlags(flags map[string]bool) Option {
+	return func(s *Server) {
+		s.features = flags
+	}
+}
+
+// defaultSettings are the server-wide settings every tenant inherits
+func (s *Server) defaultSettings() TenantSettings {
+	s.liveMu.RLock()
+	defer s.liveMu.RUnlock()
+	return TenantSettings{
+		RateLimit:    s.rateLimit,
+		Features:     s.features,
+		WebhookLimit: s.webhookLimit,
+		Retention:    Duration(s.retention),
+		MaxUsers:     s.tenantMaxUsers,
+	}
+}
+
+// settingsFor resolves the effective settings of a tenant: server defaults
+// overlaid with the tenant's overrides
+func (s *Server) settingsFor(tenant string) TenantSettings {
+	base := s.defaultSettings()
+	if tenant == "" {
+		return TenantOverrides{}.apply(base)
+	}
+	o, _ := s.tenantSettings.Get(tenant)
+	return o.apply(base)
+}
+
+// featureEnabled reports whether a feature flag is on for the request's tenant
+func (s *Server) featureEnabled(r *http.Request, name string) bool {
+	return s.settingsFor(s.tenantFromRequest(r)).Features[name]
+}
+
+// HandleGetTenantSettings handles GET /admin/tenants/{tenant}/settings
+func (s *Server) HandleGetTenantSettings(w http.ResponseWriter, r *http.Request) {
+	tenant := r.PathValue("tenant")
+	overrides, _ := s.tenantSettings.Get(tenant)
+
+	s.writeJSON(w, r, http.StatusOK, struct {
+		Tenant    string          `json:"tenant"`
+		Overrides TenantOverrides `json:"overrides"`
+		Effective TenantSettings  `json:"effective"`
+	}{tenant, overrides, s.settingsFor(tenant)})
+}
+
+// HandlePutTenantSettings handles PUT /admin/tenants/{tenant}/settings,
+// replacing the tenant's overrides
+func (s *Server) HandlePutTenantSettings(w http.ResponseWriter, r *http.Request) {
+	var o TenantOverrides
+	if err := decodeJSON(r, &o); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if o.RateLimit != nil && (o.RateLimit.Rate <= 0 || o.RateLimit.Burst < 1) {
+		httpError(w, r, "rate_limit needs a positive rate and burst", http.StatusBadRequest)
+		return
+	}
+	if o.MaxUsers != nil && *o.MaxUsers < 0 {
+		httpError(w, r, "max_users must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	tenant := r.PathValue("tenant")
+	before, existed := s.tenantSettings.Get(tenant)
+	if err := s.tenantSettings.Set(tenant, o); err != nil {
+		httpError(w, r, "could not save settings", http.StatusInternalServerError)
+		return
+	}
+	if existed {
+		s.recordAudit(r, AuditUpdate, "tenant_settings", tenant, before, o)
+	} else {
+		s.recordAudit(r, AuditCreate, "tenant_settings", tenant, nil, o)
+	}
+	s.HandleGetTenantSettings(w, r)
+}
+
+// HandleDeleteTenantSettings handles DELETE /admin/tenants/{tenant}/settings
+func (s *Server) HandleDeleteTenantSettings(w http.ResponseWriter, r *http.Request) {
+	tenant := r.PathValue("tenant")
+	before, _ := s.tenantSettings.Get(tenant)
+	ok, err := s.tenantSettings.Delete(tenant)
+	if err != nil {
+		httpError(w, r, "could not save settings", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		httpError(w, r, "tenant has no overrides", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "tenant_settings", tenant, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestTenantOverridesApply(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	limit := 3
+	base := TenantSettings{
+		RateLimit:    &RateLimit{Rate: 10, Burst: 10},
+		Features:     map[string]bool{"search": true, "export": false},
+		WebhookLimit: 10,
+	}
+	got := TenantOverrides{
+		Features:     map[string]bool{"export": true},
+		WebhookLimit: &limit,
+	}.apply(base)
+
+	if got.RateLimit != base.RateLimit {
+		t.Error("expected rate limit to be inherited")
+	}
+	if !got.Features["search"] || !got.Features["export"] {
+		t.Errorf("expected features to merge, got %v", got.Features)
+	}
+	if got.WebhookLimit != 3 {
+		t.Errorf("expected webhook limit 3, got %d", got.WebhookLimit)
+	}
+	if base.Features["export"] {
+		t.Error("apply modified the base features")
+	}
+}
+
+func TestTenantSettingsPersist(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	store, err := LoadTenantSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retention := Duration(720 * time.Hour)
+	if err := store.Set("acme", TenantOverrides{Retention: &retention}); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := LoadTenantSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, ok := reloaded.Get("acme")
+	if !ok || o.Retention == nil || *o.Retention != retention {
+		t.Fatalf("expected overrides to survive reload, got %+v", o)
+	}
+
+	if ok, err := reloaded.Delete("acme"); !ok || err != nil {
+		t.Fatalf("delete: %v %v", ok, err)
+	}
+	reloaded, _ = LoadTenantSettings(path)
+	if _, ok := reloaded.Get("acme"); ok {
+		t.Error("expected delete to be persisted")
+	}
+}
+
+func TestTenantSettingsEndpoints(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithAPIKeyAuth("bootstrap"), WithFeatureFlags(map[string]bool{"search": true}))
+	routes := server.Routes()
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/tenants/acme/settings", strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, "bootstrap")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, `{"rate_limit":{"rate":1,"burst":1},"features":{"search":false}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Effective TenantSettings `json:"effective"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Effective.Features["search"] || resp.Effective.RateLimit == nil {
+		t.Errorf("unexpected effective settings: %+v", resp.Effective)
+	}
+
+	if w := do(http.MethodPut, `{"rate_limit":{"rate":0,"burst":1}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid rate limit, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestTenantRateLimitOverride(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	store := NewTenantSettingsStore()
+	store.Set("acme", TenantOverrides{RateLimit: &RateLimit{Rate: 1, Burst: 1}})
+	store.Set("globex", TenantOverrides{RateLimit: &RateLimit{Rate: 1, Burst: 100}})
+	server := NewServer(WithTenantSettings(store), WithRateLimit(1, 2))
+	routes := server.Routes()
+	tokenFor := func(tenant string) string {
+		u, _ := server.createUser(ctx, User{Name: "Alice", Email: "alice@" + tenant + ".example.com", Tenant: tenant})
+		_, token, err := server.startSession(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	acme, globex := tokenFor("acme"), tokenFor("globex")
+
+	// Each caller comes from an address, and so a bucket, of its own
+	do := func(addr, tenant, token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = addr + ":1234"
+		req.Header.Set(TenantHeader, tenant)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if do("192.0.2.1", "acme", acme) != http.StatusOK || do("192.0.2.1", "acme", acme) != http.StatusTooManyRequests {
+		t.Error("expected the tenant's rate limit to apply")
+	}
+
+	// Naming a tenant doesn't earn its limit; only its callers get it
+	codes := []int{do("192.0.2.2", "globex", ""), do("192.0.2.2", "globex", ""), do("192.0.2.2", "globex", "")}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("expected anonymous requests naming globex to get the default limit, got %v", codes)
+	}
+	for range 3 {
+		if code := do("192.0.2.3", "globex", globex); code != http.StatusOK {
+			t.Fatalf("expected globex's callers to get its limit, got %d", code)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tenantPrefix starts the routes of a tenant's namespace, which serve the
+// user routes scoped to the tenant in the path
+const tenantPrefix = "/tenants/{tenant}"
+
+// tenantIDPattern is what tenant IDs look like, so they also work as
+// subdomains
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// TenantStatus is whether a tenant may be used
+type TenantStatus string
+
+const (
+	// TenantActive tenants are served
+	TenantActive TenantStatus = "active"
+	// TenantSuspended tenants are refused with 403 until resumed; their
+	// data is kept
+	TenantSuspended TenantStatus = "suspended"
+)
+
+// Tenant is a tenant created through the admin API. Requests may also
+// name tenants that were never created, except in the tenant namespace.
+type Tenant struct {
+	ID        string       `json:"id"`
+	Status    TenantStatus `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TenantStore is an in-memory registry of tenants
+type TenantStore struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+	clock   Clock
+}
+
+// NewTenantStore creates an empty tenant registry
+func NewTenantStore() *TenantStore {
+	return &TenantStore{tenants: make(map[string]Tenant), clock: SystemClock{}}
+}
+
+// Create adds an active tenant, reporting false if id is taken
+func (s *TenantStore) Create(id string) (Tenant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tenants[id]; ok {
+		return Tenant{}, false
+	}
+	now := s.clock.Now()
+	t := Tenant{ID: id, Status: TenantActive, CreatedAt: now, UpdatedAt: now}
+	s.tenants[id] = t
+	return t, true
+}
+
+// Get retrieves a tenant by ID
+func (s *TenantStore) Get(id string) (Tenant, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tenants[id]
+	return t, ok
+}
+
+// List returns every tenant ordered by ID
+func (s *TenantStore) List() []Tenant {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tenants := make([]Tenant, 0, len(s.tenants))
+	for _, t := range s.tenants {
+		tenants = append(tenants, t)
+	}
+	slices.SortFunc(tenants, func(a, b Tenant) int { return cmp.Compare(a.ID, b.ID) })
+	return tenants
+}
+
+// SetStatus changes the status of a tenant
+func (s *TenantStore) SetStatus(id string, status TenantStatus) (Tenant, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tenants[id]
+	if !ok {
+		return Tenant{}, false
+	}
+	if t.Status != status {
+		t.Status = status
+		t.UpdatedAt = s.clock.Now()
+		s.tenants[id] = t
+	}
+	return t, true
+}
+
+// Delete removes a tenant, reporting whether it existed
+func (s *TenantStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.tenants[id]
+	delete(s.tenants, id)
+	return ok
+}
+
+// unscopedPattern returns the user route a versioned or tenant namespace
+// route serves, or pattern itself for other routes
+func unscopedPattern(pattern string) string {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return pattern
+	}
+	path = unversionedPath(path)
+	if rest, ok := strings.CutPrefix(path, tenantPrefix); ok {
+		path = rest
+	}
+	return method + " " + path
+}
+
+// pathTenant scopes requests to the {tenant} in their path
+func (s *Server) pathTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), r.PathValue("tenant"))))
+	})
+}
+
+// checkTenant refuses scoped requests to suspended tenants, and requests
+// in the tenant namespace naming a tenant that was never created. It runs
+// after authentication, so callers without access learn nothing about
+// tenants.
+func (s *Server) checkTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := tenantFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		t, found := s.tenants.Get(tenant)
+		switch {
+		case !found && r.PathValue("tenant") != "":
+			httpError(w, r, "tenant not found", http.StatusNotFound)
+		case found && t.Status == TenantSuspended:
+			httpError(w, r, "tenant suspended", http.StatusForbidden)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// createTenantRequest is the body of POST /admin/tenants
+type createTenantRequest struct {
+	ID string `json:"id"`
+}
+
+// HandleListTenants handles GET /admin/tenants
+func (s *Server) HandleListTenants(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, s.tenants.List())
+}
+
+// HandleCreateTenant handles POST /admin/tenants
+func (s *Server) HandleCreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req createTenantRequest
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !tenantIDPattern.MatchString(req.ID) {
+		httpError(w, r, "id must be lowercase letters, digits and inner hyphens, at most 63", http.StatusBadRequest)
+		return
+	}
+
+	t, ok := s.tenants.Create(req.ID)
+	if !ok {
+		httpError(w, r, "tenant exists", http.StatusConflict)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "tenant", t.ID, nil, t)
+	s.writeJSON(w, r, http.StatusCreated, t)
+}
+
+// HandleGetTenant handles GET /admin/tenants/{tenant}
+func (s *Server) HandleGetTenant(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.tenants.Get(r.PathValue("tenant"))
+	if !ok {
+		httpError(w, r, "tenant not found", http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, t)
+}
+
+// HandleSuspendTenant handles POST /admin/tenants/{tenant}/suspend
+func (s *Server) HandleSuspendTenant(w http.ResponseWriter, r *http.Request) {
+	s.setTenantStatus(w, r, TenantSuspended)
+}
+
+// HandleResumeTenant handles POST /admin/tenants/{tenant}/resume
+func (s *Server) HandleResumeTenant(w http.ResponseWriter, r *http.Request) {
+	s.setTenantStatus(w, r, TenantActive)
+}
+
+// setTenantStatus answers a suspend or resume
+func (s *Server) setTenantStatus(w http.ResponseWriter, r *http.Request, status TenantStatus) {
+	id := r.PathValue("tenant")
+	before, ok := s.tenants.Get(id)
+	if !ok {
+		httpError(w, r, "tenant not found", http.StatusNotFound)
+		return
+	}
+	after, ok := s.tenants.SetStatus(id, status)
+	if !ok {
+		httpError(w, r, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if before.Status != after.Status {
+		s.recordAudit(r, AuditUpdate, "tenant", id, before, after)
+	}
+	s.writeJSON(w, r, http.StatusOK, after)
+}
+
+// HandleDeleteTenant handles DELETE /admin/tenants/{tenant}, purging the
+// tenant's users with everything that belongs to them, and its settings,
+// webhooks and invitations. Posts are deleted even when deleting users
+// with posts is blocked.
+func (s *Server) HandleDeleteTenant(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("tenant")
+	t, ok := s.tenants.Get(id)
+	// Suspended first, so nothing is added to the tenant while it is
+	// purged but by requests already under way
+	if ok {
+		_, ok = s.tenants.SetStatus(id, TenantSuspended)
+	}
+	if !ok {
+		httpError(w, r, "tenant not found", http.StatusNotFound)
+		return
+	}
+	if err := s.purgeTenant(r, id); err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	if s.tenants.Delete(id) {
+		s.recordAudit(r, AuditDelete, "tenant", id, t, nil)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeTenant deletes the data of tenant. Each user deleted is audited,
+// so consumers of user events learn of it.
+func (s *Server) purgeTenant(r *http.Request, tenant string) error {
+	ctx := r.Context()
+	users, err := tenantScope{s.store, tenant}.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		ok, err := s.store.Delete(ctx, u.ID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.deleteUserData(ctx, u.ID)
+			s.recordAudit(r, AuditDelete, "user", u.ID.String(), u, nil)
+		}
+	}
+	if _, err := s.tenantSettings.Delete(tenant); err != nil {
+		return err
+	}
+	s.webhooks.DeleteTenant(tenant)
+	s.invitations.DeleteTenant(tenant)
+	s.checkUserCapacity(ctx, tenant)
+	return nil
+}
+
+// deleteUserData removes what belongs to a deleted user
+func (s *Server) deleteUserData(ctx context.Context, id ID) {
+	s.orgs.RemoveUser(id)
+	s.notifications.Delete(id)
+	s.posts.DeleteUser(id)
+	if err := s.sessions.DeleteUser(ctx, id); err != nil {
+		s.componentLogger("session").ErrorContext(ctx, "could not end sessions", "user_id", id, "err", err)
+	}
+}
@@ -0,0 +1,170 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestTenantStore(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	store := NewTenantStore()
+	store.Create("globex")
+	if _, ok := store.Create("acme"); !ok {
+		t.Fatal("expected acme created")
+	}
+	if _, ok := store.Create("acme"); ok {
+		t.Error("expected a taken ID refused")
+	}
+	if tenants := store.List(); len(tenants) != 2 || tenants[0].ID != "acme" {
+		t.Errorf("expected tenants by ID, got %+v", tenants)
+	}
+	if got, _ := store.SetStatus("acme", TenantSuspended); got.Status != TenantSuspended {
+		t.Errorf("expected acme suspended, got %+v", got)
+	}
+	if _, ok := store.SetStatus("initech", TenantSuspended); ok {
+		t.Error("expected an unknown tenant not found")
+	}
+	if !store.Delete("acme") || store.Delete("acme") {
+		t.Error("expected acme deleted once")
+	}
+}
+
+func TestUnscopedPattern(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for pattern, want := range map[string]string{
+		"GET /tenants/{tenant}/users/{id}": "GET /users/{id}",
+		"GET /users/{id}":                  "GET /users/{id}",
+		"GET /admin/tenants/{tenant}":      "GET /admin/tenants/{tenant}",
+	} {
+		if got := unscopedPattern(pattern); got != want {
+			t.Errorf("%s: expected %q, got %q", pattern, want, got)
+		}
+	}
+}
+
+func TestTenantNamespace(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	// The namespace scopes requests without WithTenancy too
+	server := NewServer()
+	server.tenants.Create("acme")
+	server.tenants.Create("globex")
+	routes := server.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	var alice User
+	json.NewDecoder(do(http.MethodPost, "/tenants/acme/users", `{"name":"Alice","email":"alice@example.com"}`).Body).Decode(&alice)
+	if alice.Tenant != "acme" {
+		t.Fatalf("expected alice created in acme, got %+v", alice)
+	}
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com", Tenant: "globex"})
+
+	var users []User
+	json.NewDecoder(do(http.MethodGet, "/tenants/acme/users", "").Body).Decode(&users)
+	if len(users) != 1 || users[0].ID != alice.ID {
+		t.Errorf("expected only acme's users, got %+v", users)
+	}
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/tenants/acme/users/" + alice.ID.String(), http.StatusOK},
+		{"/tenants/acme/users/" + bob.ID.String(), http.StatusNotFound},
+		{"/tenants/acme/users/" + bob.ID.String() + "/posts", http.StatusNotFound},
+		{"/tenants/initech/users", http.StatusNotFound},
+		// The plain routes still see every user
+		{"/users/" + bob.ID.String(), http.StatusOK},
+	} {
+		if w := do(http.MethodGet, tt.path, ""); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.path, tt.want, w.Code, w.Body)
+		}
+	}
+
+	server.tenants.SetStatus("acme", TenantSuspended)
+	if w := do(http.MethodGet, "/tenants/acme/users", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected a suspended tenant refused, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "/tenants/globex/users", ""); w.Code != http.StatusOK {
+		t.Errorf("expected other tenants served, got %d", w.Code)
+	}
+}
+
+func TestTenantSuspensionWithTenancy(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithTenancy(Tenancy{Sources: []TenantSource{TenantFromHeader}}))
+	server.tenants.Create("acme")
+	server.tenants.SetStatus("acme", TenantSuspended)
+	routes := server.Routes()
+
+	for tenant, want := range map[string]int{"acme": http.StatusForbidden, "globex": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(TenantHeader, tenant)
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", tenant, want, w.Code)
+		}
+	}
+}
+
+func TestDeleteTenant(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ctx := context.Background()
+	server := NewServer(WithAPIKeyAuth("admin-secret"), WithPostsOnUserDelete(PostsBlock))
+	server.tenants.Create("acme")
+	alice := addUser(t, server, User{Name: "Alice", Email: "alice@example.com", Tenant: "acme"})
+	bob := addUser(t, server, User{Name: "Bob", Email: "bob@example.com", Tenant: "globex"})
+	server.posts.Create(alice.ID, "Hello", "")
+	server.webhooks.Create("https://acme.example.com/hook", nil, "acme", 0)
+	server.webhooks.Create("https://globex.example.com/hook", nil, "globex", 0)
+	server.invitations.Create("carol@example.com", RoleUser, "", "acme", "", time.Hour)
+	routes := server.Routes()
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, req)
+		return w
+	}
+
+	// Posts are purged even though they block deleting a user
+	if w := do(http.MethodDelete, "/admin/tenants/acme"); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if _, ok, _ := server.store.Get(ctx, alice.ID); ok {
+		t.Error("expected acme's users deleted")
+	}
+	if _, ok, _ := server.store.Get(ctx, bob.ID); !ok {
+		t.Error("expected other tenants' users kept")
+	}
+	if server.posts.Count(alice.ID) != 0 {
+		t.Error("expected acme's posts deleted")
+	}
+	if hooks := server.webhooks.List(); len(hooks) != 1 || hooks[0].Tenant != "globex" {
+		t.Errorf("expected only globex's webhooks kept, got %+v", hooks)
+	}
+	if invites := server.invitations.List(); len(invites) != 0 {
+		t.Errorf("expected acme's invitations deleted, got %+v", invites)
+	}
+	if _, ok := server.tenants.Get("acme"); ok {
+		t.Error("expected the tenant deleted")
+	}
+	if w := do(http.MethodDelete, "/admin/tenants/acme"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting again, got %d", w.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package quickserve
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamps are always emitted as RFC 3339 in UTC. Fields holding them
+// use the "_at" suffix (created_at, updated_at, ...), which is how the
+// render pipeline recognises them when applying the configured precision.
+
+// inputLayouts are the layouts accepted from clients, tried in order.
+// Layouts without a zone are interpreted as UTC.
+var inputLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// ParseTimestamp leniently parses a client-supplied time. Besides the
+// layouts above it accepts Unix seconds and Unix milliseconds.
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, errors.New("empty timestamp")
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		// Anything past year 5138 in seconds is taken as milliseconds
+		if n > 1e11 || n < -1e11 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+
+	for _, layout := range inputLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// FlexibleTime is used in request bodies to accept any format understood
+// by ParseTimestamp, as a JSON string or number
+type FlexibleTime struct {
+	time.Time
+}
+
+// UnmarshalJSON parses the value with ParseTimestamp
+func (t *FlexibleTime) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unq, err := strconv.Unquote(s); err == nil {
+		s = unq
+	}
+	parsed, err := ParseTimestamp(s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// ParseTimestampPrecision parses a precision name: s, ms, us or ns
+func ParseTimestampPrecision(s string) (time.Duration, error) {
+	switch s {
+	case "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ns":
+		return time.Nanosecond, nil
+	}
+	return 0, fmt.Errorf("invalid timestamp precision %q (want s, ms, us or ns)", s)
+}
+
+// WithTimestampPrecision renders all timestamps with a fixed number of
+// fractional digits. Without it, trailing zeros are trimmed.
+func WithTimestampPrecision(p time.Duration) Option {
+	return func(s *Server) {
+		s.timePrecision = p
+	}
+}
+
+// timestampLayout returns the fixed-width output layout for a precision
+func timestampLayout(p time.Duration) string {
+	switch {
+	case p >= time.Second:
+		return "2006-01-02T15:04:05Z07:00"
+	case p >= time.Millisecond:
+		return "2006-01-02T15:04:05.000Z07:00"
+	case p >= time.Microsecond:
+		return "2006-01-02T15:04:05.000000Z07:00"
+	}
+	return "2006-01-02T15:04:05.000000000Z07:00"
+}
+
+// formatTimestamp renders t in UTC at the given precision
+func formatTimestamp(t time.Time, p time.Duration) string {
+	if p <= 0 {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return t.UTC().Truncate(p).Format(timestampLayout(p))
+}
+
+// isTimeKey reports whether a JSON object key holds a timestamp
+func isTimeKey(k string) bool {
+	return strings.HasSuffix(k, "_at")
+}
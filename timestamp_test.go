@@ -0,0 +1,86 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []string{
+		"2024-03-01T12:30:00Z",
+		"2024-03-01T14:30:00+02:00",
+		"2024-03-01T12:30:00",
+		"2024-03-01 12:30:00",
+		"Fri, 01 Mar 2024 12:30:00 +0000",
+		"1709296200",
+		"1709296200000",
+	}
+
+	for _, in := range tests {
+		got, err := ParseTimestamp(in)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", in, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q: expected %v, got %v", in, want, got)
+		}
+	}
+
+	if _, err := ParseTimestamp("yesterday"); err == nil {
+		t.Error("expected error for unrecognized timestamp")
+	}
+}
+
+func TestFlexibleTimeUnmarshal(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var v struct {
+		A FlexibleTime `json:"a"`
+		B FlexibleTime `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a":"2024-03-01","b":1709296200}`), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.A.Format(time.DateOnly) != "2024-03-01" {
+		t.Errorf("expected 2024-03-01, got %v", v.A)
+	}
+	if v.B.Unix() != 1709296200 {
+		t.Errorf("expected 1709296200, got %d", v.B.Unix())
+	}
+}
+
+func TestTimestampPrecision(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithTimestampPrecision(time.Millisecond))
+	addUser(t, server, User{Name: "Alice", Email: "alice@test.com"})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	server.HandleGetUser(w, req)
+
+	var raw map[string]any
+	json.NewDecoder(w.Body).Decode(&raw)
+
+	created, _ := raw["created_at"].(string)
+	if len(created) != len("2006-01-02T15:04:05.000Z") || !strings.HasSuffix(created, "Z") {
+		t.Errorf("expected millisecond UTC timestamp, got %q", created)
+	}
+	if _, ok := raw["updated_at"].(string); !ok {
+		t.Error("expected updated_at to be set")
+	}
+}
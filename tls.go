@@ -0,0 +1,63 @@
+package quickserve
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// defaultTLSConfig returns the server TLS settings: TLS 1.2 or newer,
+// forward-secret AEAD cipher suites only, and modern curves. TLS 1.3
+// suites are not configurable and are all acceptable.
+func defaultTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// withCertificates returns a copy of base serving the certificate in
+// certFile and keyFile, unless base provides certificates itself, as
+// with ACME. It is for servers that cannot load files themselves, unlike
+// http.Server.ServeTLS.
+func withCertificates(base *tls.Config, certFile, keyFile string) (*tls.Config, error) {
+	tlsConfig := base.Clone()
+	if tlsConfig.GetCertificate == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// redirectToHTTPS permanently redirects every request to the same URL
+// on the HTTPS listener at httpsAddr
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,82 @@
+package quickserve
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestDefaultTLSConfig(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	cfg := defaultTLSConfig()
+	if cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected minimum TLS 1.2, got %x", cfg.MinVersion)
+	}
+
+	insecure := make(map[uint16]bool)
+	for _, cs := range tls.InsecureCipherSuites() {
+		insecure[cs.ID] = true
+	}
+	for _, id := range cfg.CipherSuites {
+		if insecure[id] {
+			t.Errorf("insecure cipher suite enabled: %s", tls.CipherSuiteName(id))
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tests := []struct {
+		httpsAddr string
+		host      string
+		expected  string
+	}{
+		{":443", "example.com", "https://example.com/users?id_format=string"},
+		{":8443", "example.com:8080", "https://example.com:8443/users?id_format=string"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users?id_format=string", nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		redirectToHTTPS(tt.httpsAddr).ServeHTTP(w, req)
+
+		if w.Code != http.StatusPermanentRedirect {
+			t.Errorf("expected 308, got %d", w.Code)
+		}
+		if loc := w.Header().Get("Location"); loc != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, loc)
+		}
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	ts := httptest.NewUnstartedServer(server.Routes())
+	ts.TLS = defaultTLSConfig()
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := ts.Client()
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(ts.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.Version < tls.VersionTLS12 {
+		t.Errorf("expected TLS 1.2+, got %v", resp.TLS)
+	}
+}
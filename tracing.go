@@ -0,0 +1,599 @@
+package quickserve
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TraceParentHeader carries the W3C trace context of a request
+	TraceParentHeader = "traceparent"
+	// TraceStateHeader carries vendor trace state, passed on unchanged
+	TraceStateHeader = "tracestate"
+
+	// defaultTraceBatch is how many spans are exported at once
+	defaultTraceBatch = 512
+	// maxTraceQueue bounds the spans held while the collector is slow;
+	// further spans are dropped
+	maxTraceQueue = 4096
+	// traceExportInterval is how often queued spans are exported
+	traceExportInterval = 5 * time.Second
+	// tracerScope names the instrumentation in exported spans
+	tracerScope = "github.com/harshakonda/quickserve"
+)
+
+// SpanKind is the OTLP role of a span
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// spanContext identifies a span within a trace, as carried by traceparent
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+	state   string
+}
+
+// parseTraceParent parses a version 00 traceparent header
+func parseTraceParent(v string) (spanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return spanContext{}, false
+	}
+	var sc spanContext
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return spanContext{}, false
+	}
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == [16]byte{} {
+		return spanContext{}, false
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == [8]byte{} {
+		return spanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return spanContext{}, false
+	}
+	sc.sampled = flags&1 == 1
+	return sc, true
+}
+
+// traceParent formats sc as a traceparent header
+func (sc spanContext) traceParent() string {
+	flags := "00"
+	if sc.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.traceID[:]) + "-" + hex.EncodeToString(sc.spanID[:]) + "-" + flags
+}
+
+// Span is a timed operation within a trace. Spans of unsampled traces
+// still carry the trace context but are never exported. A nil *Span is
+// valid and does nothing.
+type Span struct {
+	tracer *Tracer
+	sc     spanContext
+	parent [8]byte
+	kind   SpanKind
+	start  time.Time
+
+	mu      sync.Mutex
+	name    string
+	attrs   []spanAttr
+	failed  bool
+	message string
+	end     time.Time
+}
+
+type spanAttr struct {
+	key   string
+	value any
+}
+
+// SetName renames the span, e.g. once the route of a request is known
+func (sp *Span) SetName(name string) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.name = name
+}
+
+// SetAttribute records a string, bool, integer or float attribute
+func (sp *Span) SetAttribute(key string, value any) {
+	if sp == nil || !sp.sc.sampled {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.attrs = append(sp.attrs, spanAttr{key, value})
+}
+
+// RecordError marks the span failed with err; a nil err is ignored
+func (sp *Span) RecordError(err error) {
+	if sp == nil || err == nil {
+		return
+	}
+	sp.SetError(err.Error())
+}
+
+// SetError marks the span failed
+func (sp *Span) SetError(message string) {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.failed, sp.message = true, message
+}
+
+// End finishes the span and queues it for export
+func (sp *Span) End() {
+	if sp == nil {
+		return
+	}
+	sp.mu.Lock()
+	if !sp.end.IsZero() {
+		sp.mu.Unlock()
+		return
+	}
+	sp.end = time.Now()
+	sp.mu.Unlock()
+	if sp.sc.sampled {
+		sp.tracer.enqueue(sp)
+	}
+}
+
+// TraceID returns the hex trace ID, for correlating logs with traces
+func (sp *Span) TraceID() string {
+	if sp == nil {
+		return ""
+	}
+	return hex.EncodeToString(sp.sc.traceID[:])
+}
+
+type spanKeyCtx struct{}
+
+// SpanFromContext returns the current span, or nil outside a trace
+func SpanFromContext(ctx context.Context) *Span {
+	sp, _ := ctx.Value(spanKeyCtx{}).(*Span)
+	return sp
+}
+
+// TracingConfig controls where spans are exported
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP base URL of a collector, Jaeger or Tempo,
+	// e.g. http://localhost:4318; spans are posted to /v1/traces
+	Endpoint string
+	// Headers are added to every export, e.g. for authentication
+	Headers map[string]string
+	// ServiceName identifies the process in traces; "quickserve" if empty
+	ServiceName string
+	// Client sends exports; http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Tracer creates spans and exports them in batches over OTLP/HTTP JSON
+type Tracer struct {
+	cfg    TracingConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+	ready   chan struct{}
+}
+
+// NewTracer creates a tracer exporting to cfg.Endpoint. Spans are only
+// sent while Run is running, or by Flush.
+func NewTracer(cfg TracingConfig) *Tracer {
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "quickserve"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	return &Tracer{cfg: cfg, logger: slog.Default(), ready: make(chan struct{}, 1)}
+}
+
+// WithTracing traces every request, and the store calls made for it,
+// with t
+func WithTracing(t *Tracer) Option {
+	return func(s *Server) {
+		s.tracer = t
+	}
+}
+
+// Start begins a span as a child of the span in ctx, or of remote when
+// ctx has none and remote is valid, or else a new trace
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return t.start(ctx, name, kind, spanContext{})
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, remote spanContext) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	sp := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	switch parent := SpanFromContext(ctx); {
+	case parent != nil:
+		sp.sc.traceID, sp.sc.sampled, sp.sc.state = parent.sc.traceID, parent.sc.sampled, parent.sc.state
+		sp.parent = parent.sc.spanID
+	case remote.traceID != [16]byte{}:
+		sp.sc.traceID, sp.sc.sampled, sp.sc.state = remote.traceID, remote.sampled, remote.state
+		sp.parent = remote.spanID
+	default:
+		rand.Read(sp.sc.traceID[:])
+		sp.sc.sampled = true
+	}
+	rand.Read(sp.sc.spanID[:])
+	return context.WithValue(ctx, spanKeyCtx{}, sp), sp
+}
+
+func (t *Tracer) enqueue(sp *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxTraceQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, sp)
+	if len(t.queue) >= defaultTraceBatch {
+		select {
+		case t.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run exports queued spans every few seconds, or as soon as a batch is
+// full, until ctx is done; then it exports what is left
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(traceExportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), traceExportInterval)
+			defer cancel()
+			if err := t.Flush(flushCtx); err != nil {
+				t.logger.Warn("exporting spans failed", "error", err)
+			}
+			return
+		case <-ticker.C:
+		case <-t.ready:
+		}
+		if err := t.Flush(ctx); err != nil {
+			t.logger.Warn("exporting spans failed", "error", err)
+		}
+	}
+}
+
+// Flush exports every queued span. Spans that could not be delivered are
+// dropped, so a collector outage cannot exhaust memory.
+func (t *Tracer) Flush(ctx context.Context) error {
+	var errs []error
+	for {
+		t.mu.Lock()
+		n := min(len(t.queue), defaultTraceBatch)
+		batch := t.queue[:n:n]
+		t.queue = t.queue[n:]
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+
+		if dropped > 0 {
+			errs = append(errs, fmt.Errorf("tracing: dropped %d spans while the export queue was full", dropped))
+		}
+		if n == 0 {
+			return errors.Join(errs...)
+		}
+		if err := t.export(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// export posts spans in the OTLP/HTTP JSON encoding
+func (t *Tracer) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(t.cfg.ServiceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.Endpoint+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("tracing: collector: %s", resp.Status)
+	}
+	return nil
+}
+
+type (
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		TraceState   string     `json:"traceState,omitempty"`
+		Name         string     `json:"name"`
+		Kind         SpanKind   `json:"kind"`
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []otlpAttr `json:"attributes,omitempty"`
+		Status       otlpStatus `json:"status"`
+	}
+)
+
+func otlpAttrValue(v any) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{StringValue: &v}
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case int:
+		s := strconv.Itoa(v)
+		return otlpValue{IntValue: &s}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpValue{IntValue: &s}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	}
+	s := fmt.Sprint(v)
+	return otlpValue{StringValue: &s}
+}
+
+// otlpRequest builds an ExportTraceServiceRequest
+func otlpRequest(service string, spans []*Span) any {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, sp := range spans {
+		sp.mu.Lock()
+		os := otlpSpan{
+			TraceID:    hex.EncodeToString(sp.sc.traceID[:]),
+			SpanID:     hex.EncodeToString(sp.sc.spanID[:]),
+			TraceState: sp.sc.state,
+			Name:       sp.name,
+			Kind:       sp.kind,
+			Start:      strconv.FormatInt(sp.start.UnixNano(), 10),
+			End:        strconv.FormatInt(sp.end.UnixNano(), 10),
+		}
+		if sp.parent != [8]byte{} {
+			os.ParentSpanID = hex.EncodeToString(sp.parent[:])
+		}
+		for _, a := range sp.attrs {
+			os.Attributes = append(os.Attributes, otlpAttr{a.key, otlpAttrValue(a.value)})
+		}
+		if sp.failed {
+			os.Status = otlpStatus{Code: 2, Message: sp.message}
+		}
+		sp.mu.Unlock()
+		out = append(out, os)
+	}
+
+	type attrs struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	type scope struct {
+		Name string `json:"name"`
+	}
+	type scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	type resourceSpans struct {
+		Resource   attrs        `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	return struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}{[]resourceSpans{{
+		Resource:   attrs{[]otlpAttr{{"service.name", otlpAttrValue(service)}}},
+		ScopeSpans: []scopeSpans{{Scope: scope{tracerScope}, Spans: out}},
+	}}}
+}
+
+// traceRequests starts a server span per request, continuing the
+// caller's trace when it sends a valid traceparent
+func (s *Server) traceRequests(next http.Handler) http.Handler {
+	if s.tracer == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote, _ := parseTraceParent(r.Header.Get(TraceParentHeader))
+		remote.state = r.Header.Get(TraceStateHeader)
+		ctx, sp := s.tracer.start(r.Context(), r.Method, SpanKindServer, remote)
+		defer sp.End()
+
+		sp.SetAttribute("http.request.method", r.Method)
+		sp.SetAttribute("url.path", r.URL.Path)
+		sp.SetAttribute("client.address", s.clientIP(r))
+		if ua := r.UserAgent(); ua != "" {
+			sp.SetAttribute("user_agent.original", ua)
+		}
+		if id := RequestIDFromContext(ctx); id != "" {
+			sp.SetAttribute("quickserve.request_id", id)
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		sp.SetAttribute("http.response.status_code", status)
+		if status >= 500 {
+			sp.SetError(http.StatusText(status))
+		}
+	})
+}
+
+// nameSpan names the request span after the matched route, which is only
+// known once the mux has run
+func (s *Server) nameSpan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sp := SpanFromContext(r.Context()); sp != nil {
+			if rt, ok := RouteFromContext(r.Context()); ok {
+				sp.SetName(rt.Pattern())
+				sp.SetAttribute("http.route", rt.Path)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// propagateTrace forwards the current trace context on an outbound request
+func propagateTrace(ctx context.Context, req *http.Request) {
+	if sp := SpanFromContext(ctx); sp != nil {
+		req.Header.Set(TraceParentHeader, sp.sc.traceParent())
+		if sp.sc.state != "" {
+			req.Header.Set(TraceStateHeader, sp.sc.state)
+		}
+	}
+}
+
+// tracedUserStore records a child span for every store call
+type tracedUserStore struct {
+	UserStore
+	tracer *Tracer
+}
+
+// memoryUserStore returns the in-memory user store, looking through the
+// tracing decorator
+func (s *Server) memoryUserStore() (*MemoryUserStore, bool) {
+	st := s.store
+	if t, ok := st.(tracedUserStore); ok {
+		st = t.UserStore
+	}
+	m, ok := st.(*MemoryUserStore)
+	return m, ok
+}
+
+func (st tracedUserStore) span(ctx context.Context, op string) (context.Context, *Span) {
+	ctx, sp := st.tracer.Start(ctx, "UserStore."+op, SpanKindInternal)
+	sp.SetAttribute("db.operation.name", op)
+	return ctx, sp
+}
+
+func (st tracedUserStore) Insert(ctx context.Context, user User, limit, tenantLimit int) (User, error) {
+	ctx, sp := st.span(ctx, "Insert")
+	defer sp.End()
+	u, err := st.UserStore.Insert(ctx, user, limit, tenantLimit)
+	sp.RecordError(err)
+	return u, err
+}
+
+func (st tracedUserStore) Get(ctx context.Context, id ID) (User, bool, error) {
+	ctx, sp := st.span(ctx, "Get")
+	defer sp.End()
+	u, ok, err := st.UserStore.Get(ctx, id)
+	sp.RecordError(err)
+	return u, ok, err
+}
+
+func (st tracedUserStore) List(ctx context.Context) ([]User, error) {
+	ctx, sp := st.span(ctx, "List")
+	defer sp.End()
+	users, err := st.UserStore.List(ctx)
+	sp.RecordError(err)
+	return users, err
+}
+
+func (st tracedUserStore) FindByEmail(ctx context.Context, email string) (User, bool, error) {
+	ctx, sp := st.span(ctx, "FindByEmail")
+	defer sp.End()
+	u, ok, err := st.UserStore.FindByEmail(ctx, email)
+	sp.RecordError(err)
+	return u, ok, err
+}
+
+func (st tracedUserStore) Delete(ctx context.Context, id ID) (bool, error) {
+	ctx, sp := st.span(ctx, "Delete")
+	defer sp.End()
+	ok, err := st.UserStore.Delete(ctx, id)
+	sp.RecordError(err)
+	return ok, err
+}
+
+func (st tracedUserStore) Count(ctx context.Context, tenant string) (int, int, error) {
+	ctx, sp := st.span(ctx, "Count")
+	defer sp.End()
+	total, inTenant, err := st.UserStore.Count(ctx, tenant)
+	sp.RecordError(err)
+	return total, inTenant, err
+}
+
+func (st tracedUserStore) ListTenant(ctx context.Context, tenant string) ([]User, error) {
+	ctx, sp := st.span(ctx, "ListTenant")
+	defer sp.End()
+	users, err := st.UserStore.(TenantIndex).ListTenant(ctx, tenant)
+	sp.RecordError(err)
+	return users, err
+}
+
+func (st tracedUserStore) FindByEmailInTenant(ctx context.Context, tenant, email string) (User, bool, error) {
+	ctx, sp := st.span(ctx, "FindByEmailInTenant")
+	defer sp.End()
+	u, ok, err := st.UserStore.(TenantIndex).FindByEmailInTenant(ctx, tenant, email)
+	sp.RecordError(err)
+	return u, ok, err
+}
+
+func (st tracedUserStore) SearchUsers(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	ctx, sp := st.span(ctx, "SearchUsers")
+	defer sp.End()
+	results, err := st.UserStore.(UserSearcher).SearchUsers(ctx, query, limit)
+	sp.RecordError(err)
+	return results, err
+}
+
+func (st tracedUserStore) ListAfter(ctx context.Context, order UserSort, after *User, limit int) ([]User, error) {
+	ctx, sp := st.span(ctx, "ListAfter")
+	defer sp.End()
+	users, err := st.UserStore.(UserPager).ListAfter(ctx, order, after, limit)
+	sp.RecordError(err)
+	return users, err
+}
@@ -0,0 +1,231 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// collector is a fake OTLP/HTTP endpoint recording exported spans
+type collector struct {
+	mu      sync.Mutex
+	spans   []otlpSpan
+	service string
+	auth    string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttr `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad export", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.auth = r.Header.Get("Authorization")
+	for _, rs := range req.ResourceSpans {
+		for _, a := range rs.Resource.Attributes {
+			if a.Key == "service.name" && a.Value.StringValue != nil {
+				c.service = *a.Value.StringValue
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+}
+
+func (c *collector) inTrace(traceID string) []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []otlpSpan
+	for _, sp := range c.spans {
+		if sp.TraceID == traceID {
+			out = append(out, sp)
+		}
+	}
+	return out
+}
+
+func attrValue(sp otlpSpan, key string) string {
+	for _, a := range sp.Attributes {
+		if a.Key != key {
+			continue
+		}
+		switch {
+		case a.Value.StringValue != nil:
+			return *a.Value.StringValue
+		case a.Value.IntValue != nil:
+			return *a.Value.IntValue
+		}
+	}
+	return ""
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		// Later versions may append fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sc, ok := parseTraceParent(tt.header)
+		if ok != tt.ok || sc.sampled != tt.sampled {
+			t.Errorf("parseTraceParent(%q) = sampled %v, ok %v; want %v, %v", tt.header, sc.sampled, ok, tt.sampled, tt.ok)
+		}
+		if ok && tt.header[:2] == "00" && sc.traceParent() != tt.header {
+			t.Errorf("expected %q to round-trip, got %q", tt.header, sc.traceParent())
+		}
+	}
+}
+
+func TestTracingRequestSpans(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	c := &collector{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	tracer := NewTracer(TracingConfig{
+		Endpoint:    ts.URL + "/",
+		Headers:     map[string]string{"Authorization": "Bearer otlp"},
+		ServiceName: "users-api",
+		Client:      ts.Client(),
+	})
+	s := NewServer(WithTracing(tracer))
+	alice := addUser(t, s, User{Name: "Alice", Email: "alice@example.com"})
+	handler := s.Routes()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/users/"+alice.ID.String(), nil)
+	req.Header.Set(TraceParentHeader, "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set(TraceStateHeader, "vendor=1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	// An unsampled caller is followed, so nothing of its trace is exported
+	const unsampled = "0af7651916cd43dd8448eb211c80319c"
+	req = httptest.NewRequest(http.MethodGet, "/users/"+alice.ID.String(), nil)
+	req.Header.Set(TraceParentHeader, "00-"+unsampled+"-b7ad6b7169203331-00")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if c.service != "users-api" || c.auth != "Bearer otlp" {
+		t.Errorf("expected the service name and headers on exports, got %q, %q", c.service, c.auth)
+	}
+	if spans := c.inTrace(unsampled); len(spans) != 0 {
+		t.Errorf("expected no spans for an unsampled trace, got %d", len(spans))
+	}
+
+	spans := c.inTrace(traceID)
+	var server, store *otlpSpan
+	for i := range spans {
+		switch spans[i].Kind {
+		case SpanKindServer:
+			server = &spans[i]
+		case SpanKindInternal:
+			store = &spans[i]
+		}
+	}
+	if server == nil || store == nil {
+		t.Fatalf("expected a server span and a store span, got %+v", spans)
+	}
+	if server.Name != "GET /users/{id}" || server.ParentSpanID != "00f067aa0ba902b7" || server.TraceState != "vendor=1" {
+		t.Errorf("unexpected server span: %+v", *server)
+	}
+	if got := attrValue(*server, "http.response.status_code"); got != "200" {
+		t.Errorf("expected status code 200 on the span, got %q", got)
+	}
+	if got := attrValue(*server, "http.route"); got != "/users/{id}" {
+		t.Errorf("expected the route on the span, got %q", got)
+	}
+	if store.Name != "UserStore.Get" || store.ParentSpanID != server.SpanID {
+		t.Errorf("expected the store call as a child of the request, got %+v", *store)
+	}
+}
+
+// failingUserStore fails List like a backend that is down
+type failingUserStore struct {
+	*MemoryUserStore
+}
+
+func (failingUserStore) List(context.Context) ([]User, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTracingErrorStatus(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	tracer := NewTracer(TracingConfig{Endpoint: "http://collector.invalid"})
+	s := NewServer(WithTracing(tracer), WithUserStore(failingUserStore{NewMemoryUserStore()}))
+
+	ctx, sp := tracer.Start(context.Background(), "test", SpanKindInternal)
+	req := httptest.NewRequest(http.MethodGet, "/users", nil).WithContext(ctx)
+	s.Routes().ServeHTTP(httptest.NewRecorder(), req)
+	sp.End()
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	var failed []string
+	for _, q := range tracer.queue {
+		if q.failed {
+			failed = append(failed, q.name)
+		}
+	}
+	if strings.Join(failed, ",") != "UserStore.List,GET /users" {
+		t.Errorf("expected the store call and the request to fail, got %v", failed)
+	}
+}
+
+func TestPropagateTrace(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(TraceParentHeader)
+	}))
+	defer ts.Close()
+
+	tracer := NewTracer(TracingConfig{Endpoint: "http://collector.invalid"})
+	ctx, sp := tracer.Start(context.Background(), "deliver", SpanKindInternal)
+	defer sp.End()
+	if err := postWebhook(ctx, ts.Client(), ts.URL, []byte("{}"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got != sp.sc.traceParent() || !strings.Contains(got, sp.TraceID()) {
+		t.Errorf("expected traceparent %q on the webhook, got %q", sp.sc.traceParent(), got)
+	}
+}
@@ -0,0 +1,6 @@
+// Package userpb holds the Go code generated from
+// proto/quickserve/v1/users.proto: the UserService messages, and its
+// gRPC client and server stubs
+package userpb
+
+//go:generate protoc -I ../proto --go_out=.. --go_opt=module=github.com/harshakonda/quickserve --go-grpc_out=.. --go-grpc_opt=module=github.com/harshakonda/quickserve quickserve/v1/users.proto
@@ -0,0 +1,96 @@
+package quickserve_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+	"github.com/harshakonda/quickserve"
+	"github.com/harshakonda/quickserve/quicktest"
+)
+
+func TestHandleListUsers(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t)
+	srv.CreateUser(t, quicktest.NewUser{Name: "Alice", Email: "alice@test.com"})
+	srv.CreateUser(t, quicktest.NewUser{Name: "Bob", Email: "bob@test.com"})
+
+	srv.Do(t, http.MethodGet, "/users", nil).Expect(t, http.StatusOK).JSON(t, `[
+		{"name": "Alice", "email": "alice@test.com"},
+		{"name": "Bob", "email": "bob@test.com"}
+	]`)
+}
+
+func TestHandleCreateUser(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t)
+	srv.Do(t, http.MethodPost, "/users", `{"name":"Test","email":"test@test.com"}`).
+		Expect(t, http.StatusCreated).JSON(t, `{"id": 1, "name": "Test"}`)
+}
+
+func TestHandleCreateUserDuplicateEmail(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for _, tt := range []struct {
+		name string
+		opts []quickserve.Option
+		// acme is the status of taking the email again in acme
+		acme int
+	}{
+		// Without tenancy login searches every user, so emails are
+		// unique across tenants
+		{"without tenancy", nil, http.StatusConflict},
+		{"with tenancy", []quickserve.Option{quickserve.WithTenancy(quickserve.Tenancy{Sources: []quickserve.TenantSource{quickserve.TenantFromHeader}})}, http.StatusCreated},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := quicktest.New(t, tt.opts...)
+			for _, c := range []struct {
+				path, tenant, body string
+				want               int
+			}{
+				{"/users", "", `{"name":"Alice","email":"alice@test.com"}`, http.StatusCreated},
+				{"/users", "", `{"name":"Alice Again","email":"alice@test.com"}`, http.StatusConflict},
+				{"/v2/users", "", `{"display_name":"Alice","email":"alice@test.com"}`, http.StatusConflict},
+				{"/users", "acme", `{"name":"Alice","email":"alice@test.com"}`, tt.acme},
+				{"/users", "acme", `{"name":"Alice Again","email":"alice@test.com"}`, http.StatusConflict},
+			} {
+				req, _ := http.NewRequest(http.MethodPost, "http://quickserve"+c.path, strings.NewReader(c.body))
+				req.Header.Set(quickserve.TenantHeader, c.tenant)
+				if got := srv.DoRequest(t, req).Status; got != c.want {
+					t.Errorf("%s in %q: expected %d, got %d", c.body, c.tenant, c.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleGetUser(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t)
+	alice := srv.CreateUser(t, quicktest.NewUser{Name: "Alice", Email: "alice@test.com"})
+
+	if got := srv.GetUser(t, alice.ID); got.Name != "Alice" {
+		t.Errorf("expected 'Alice', got '%s'", got.Name)
+	}
+}
+
+func TestHandleGetUserNotFound(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t)
+	srv.Do(t, http.MethodGet, "/users/999", nil).Expect(t, http.StatusNotFound)
+}
+
+func TestHandleDeleteUser(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	srv := quicktest.New(t)
+	alice := srv.CreateUser(t, quicktest.NewUser{Name: "Alice", Email: "alice@test.com"})
+
+	srv.DeleteUser(t, alice.ID)
+	srv.Do(t, http.MethodGet, "/users/1", nil).Expect(t, http.StatusNotFound)
+}
@@ -0,0 +1,316 @@
+package quickserve
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is one way a request does not match the OpenAPI document
+type FieldError struct {
+	// In is body or query
+	In string `json:"in"`
+	// Field locates the value, e.g. email, webhook_events[1] or limit;
+	// empty for the body as a whole
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// WithQueryParam documents an optional query parameter of a route.
+// Requests whose value does not match schema are refused before the
+// handler runs.
+func WithQueryParam(name string, schema *Schema) RouteOption {
+	return func(rt *Route) {
+		rt.queryParams = append(rt.queryParams, Parameter{Name: name, In: "query", Schema: schema})
+	}
+}
+
+// integerBetween is the schema of an integer in [min, max]
+func integerBetween(min, max float64) *Schema {
+	return &Schema{Type: "integer", Minimum: &min, Maximum: &max}
+}
+
+// validateRequest answers 400 to requests whose query parameters or JSON
+// body do not match the route's operation in the OpenAPI document, so
+// handlers only see well-formed input. It runs after the body limit, so
+// the body is read at most once in full.
+func (s *Server) validateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rt, ok := RouteFromContext(r.Context())
+		if !ok || s.spec == nil || rt.Method == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		path, _ := openAPITemplate(rt.Path)
+		op := s.spec.Paths[path][strings.ToLower(rt.Method)]
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator{schemas: s.spec.Components.Schemas}
+		var invalid []FieldError
+		query := r.URL.Query()
+		for _, p := range op.Parameters {
+			if p.In != "query" || !query.Has(p.Name) {
+				continue
+			}
+			if reason := v.param(p.Schema, query.Get(p.Name)); reason != "" {
+				invalid = append(invalid, FieldError{In: "query", Field: p.Name, Reason: reason})
+			}
+		}
+		if op.RequestBody != nil {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				httpError(w, r, "could not read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			invalid = append(invalid, v.body(op.RequestBody.Content["application/json"].Schema, body)...)
+		}
+		if len(invalid) > 0 {
+			msg := "invalid request body"
+			if invalid[0].In == "query" {
+				msg = "invalid query parameters"
+			}
+			writeError(w, r, http.StatusBadRequest, errorBody{Error: msg, Invalid: invalid})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validator checks values against schemas, resolving references to the
+// document's components
+type validator struct {
+	schemas map[string]*Schema
+}
+
+func (v validator) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	if s == nil {
+		return &Schema{}
+	}
+	return s
+}
+
+// body decodes a JSON body as decodeJSON does and checks it against s
+func (v validator) body(s *Schema, body []byte) []FieldError {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []FieldError{{In: "body", Reason: "is required"}}
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []FieldError{{In: "body", Reason: "is not valid JSON"}}
+	}
+	var invalid []FieldError
+	v.value(s, value, "", &invalid)
+	return invalid
+}
+
+// value appends the ways value does not match s to invalid. Null is only
+// accepted for optional properties, which encoding/json leaves unset.
+func (v validator) value(s *Schema, value any, field string, invalid *[]FieldError) {
+	s = v.resolve(s)
+	fail := func(reason string) {
+		*invalid = append(*invalid, FieldError{In: "body", Field: field, Reason: reason})
+	}
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			var errs []FieldError
+			if v.value(alt, value, field, &errs); len(errs) == 0 {
+				return
+			}
+		}
+		fail("must be " + v.describe(s))
+		return
+	}
+	if s.Type == "" {
+		return
+	}
+	if value == nil {
+		fail("must not be null")
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*invalid = append(*invalid, FieldError{In: "body", Field: joinField(field, name), Reason: "is required"})
+			}
+		}
+		for _, name := range sortedKeys(obj) {
+			prop, ok := s.Properties[name]
+			if !ok {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil || obj[name] == nil && !s.requires(name) {
+				continue
+			}
+			v.value(prop, obj[name], joinField(field, name), invalid)
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range arr {
+			v.value(s.Items, item, field+"["+strconv.Itoa(i)+"]", invalid)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			fail("must be " + v.describe(s))
+			return
+		}
+		if reason := checkNumber(s, n.String()); reason != "" {
+			fail(reason)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if reason := checkString(s, str); reason != "" {
+			fail(reason)
+		}
+	}
+}
+
+// param checks a query parameter value against s, returning why it does
+// not match
+func (v validator) param(s *Schema, value string) string {
+	s = v.resolve(s)
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			if v.param(alt, value) == "" {
+				return ""
+			}
+		}
+		return "must be " + v.describe(s)
+	}
+	switch s.Type {
+	case "integer", "number":
+		return checkNumber(s, value)
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	case "string":
+		return checkString(s, value)
+	}
+	return ""
+}
+
+// requires reports whether the object schema s requires property name
+func (s *Schema) requires(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// describe names the values s accepts, e.g. "an integer or a string"
+func (v validator) describe(s *Schema) string {
+	s = v.resolve(s)
+	if len(s.OneOf) > 0 {
+		alts := make([]string, len(s.OneOf))
+		for i, alt := range s.OneOf {
+			alts[i] = v.describe(alt)
+		}
+		return strings.Join(alts, " or ")
+	}
+	switch s.Type {
+	case "integer", "object", "array":
+		return "an " + s.Type
+	case "":
+		return "any value"
+	}
+	return "a " + s.Type
+}
+
+func checkNumber(s *Schema, value string) string {
+	var n float64
+	if s.Type == "integer" {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "must be an integer"
+		}
+		n = float64(i)
+	} else {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "must be a number"
+		}
+		n = f
+	}
+	switch {
+	case s.Minimum != nil && s.Maximum != nil && (n < *s.Minimum || n > *s.Maximum):
+		return "must be between " + formatBound(*s.Minimum) + " and " + formatBound(*s.Maximum)
+	case s.Minimum != nil && n < *s.Minimum:
+		return "must be at least " + formatBound(*s.Minimum)
+	case s.Maximum != nil && n > *s.Maximum:
+		return "must be at most " + formatBound(*s.Maximum)
+	}
+	return ""
+}
+
+func formatBound(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func checkString(s *Schema, value string) string {
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if value == e {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(s.Enum, ", ")
+	}
+	switch s.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 timestamp"
+		}
+	case "int64":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be a 64-bit integer"
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+			return "must be base64"
+		}
+	}
+	return ""
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -0,0 +1,107 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestValidateRequestBody(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	handler := NewServer(WithModule(widgetModule{})).Routes()
+	tests := []struct {
+		name    string
+		body    string
+		invalid []FieldError
+	}{
+		{"valid", `{"id":1,"name":"w","children":[]}`, nil},
+		{"string id", `{"id":"1","name":"w","children":[],"parent":null}`, nil},
+		{"unknown fields", `{"id":1,"name":"w","children":[],"colour":"red"}`, nil},
+		{"empty", ``, []FieldError{{In: "body", Reason: "is required"}}},
+		{"malformed", `{"id":`, []FieldError{{In: "body", Reason: "is not valid JSON"}}},
+		{"not an object", `[]`, []FieldError{{In: "body", Reason: "must be an object"}}},
+		{"missing", `{"id":1}`, []FieldError{
+			{In: "body", Field: "name", Reason: "is required"},
+			{In: "body", Field: "children", Reason: "is required"},
+		}},
+		{"wrong types", `{"id":true,"name":null,"children":[{"id":1,"name":"c","children":[],"tags":[3]}]}`, []FieldError{
+			{In: "body", Field: "children[0].tags[0]", Reason: "must be a string"},
+			{In: "body", Field: "id", Reason: "must be an integer or a string"},
+			{In: "body", Field: "name", Reason: "must not be null"},
+		}},
+		{"id not a number", `{"id":"one","name":"w","children":[]}`, []FieldError{
+			{In: "body", Field: "id", Reason: "must be an integer or a string"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/widgets/w", strings.NewReader(tt.body)))
+			if tt.invalid == nil {
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected the handler to run, got %d %s", w.Code, w.Body)
+				}
+				return
+			}
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", w.Code)
+			}
+			var body errorBody
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error != "invalid request body" || !reflect.DeepEqual(body.Invalid, tt.invalid) {
+				t.Errorf("expected %+v, got %q %+v", tt.invalid, body.Error, body.Invalid)
+			}
+		})
+	}
+}
+
+func TestValidateQueryParams(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer()
+	server.store.Insert(context.Background(), User{Name: "Alice", Email: "alice@test.com"}, 0, 0)
+	handler := server.Routes()
+	get := func(url string) (int, errorBody) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var body errorBody
+		json.NewDecoder(w.Body).Decode(&body)
+		return w.Code, body
+	}
+
+	if code, _ := get("/users/1/activity?limit=5&before=10&id_format=string"); code != http.StatusOK {
+		t.Fatalf("expected valid parameters to pass, got %d", code)
+	}
+	code, body := get("/users/1/activity?limit=500&before=x")
+	want := []FieldError{
+		{In: "query", Field: "limit", Reason: "must be between 1 and 100"},
+		{In: "query", Field: "before", Reason: "must be an integer"},
+	}
+	if code != http.StatusBadRequest || body.Error != "invalid query parameters" || !reflect.DeepEqual(body.Invalid, want) {
+		t.Errorf("expected 400 with %+v, got %d %+v", want, code, body)
+	}
+	code, body = get("/users/1/avatar?style=cartoon")
+	if code != http.StatusBadRequest || len(body.Invalid) != 1 || body.Invalid[0].Reason != "must be one of identicon, initials" {
+		t.Errorf("expected the style enum to be enforced, got %d %+v", code, body)
+	}
+}
+
+func TestValidateAfterAuthentication(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	w := httptest.NewRecorder()
+	NewServer(WithAPIKeyAuth("admin-secret")).Routes().ServeHTTP(w,
+		httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 before the body is validated, got %d", w.Code)
+	}
+}
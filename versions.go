@@ -0,0 +1,173 @@
+package quickserve
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiVersions are the versions of the user routes, each served under its
+// own prefix. The user routes without a prefix are v1, so clients written
+// before versioning keep working.
+var apiVersions = []struct {
+	prefix string
+	codec  userCodec
+}{
+	{"/v1", v1Codec{}},
+	{"/v2", v2Codec{}},
+}
+
+// userCodec is how one version of the user routes represents users. The
+// handlers are shared by every version and go through the codec of the
+// request's, so a breaking change to the user schema is a new codec.
+type userCodec interface {
+	// version names the version, such as v2
+	version() string
+	// idFormat is how the version renders IDs, or "" to leave it to the
+	// request
+	idFormat() IDFormat
+	// user returns the representation of u
+	user(u User) any
+	// users returns the representation of a list of users
+	users(users []User) any
+	// searchPage returns the representation of search results
+	searchPage(query string, results []SearchResult) any
+	// createRequest decodes the body of POST /users
+	createRequest(r *http.Request) (createUserRequest, error)
+	// createBody returns a value of the Go type of that body, for the
+	// OpenAPI document
+	createBody() any
+}
+
+type codecKey struct{}
+
+// useCodec serves requests with the users of c
+func useCodec(c userCodec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), codecKey{}, c)))
+		})
+	}
+}
+
+// codecFor returns the user codec of the request with ctx, v1 unless
+// its route is versioned
+func codecFor(ctx context.Context) userCodec {
+	if c, ok := ctx.Value(codecKey{}).(userCodec); ok {
+		return c
+	}
+	return v1Codec{}
+}
+
+// unversionedPath returns path without its version prefix
+func unversionedPath(path string) string {
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(path, v.prefix); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return path
+}
+
+// v1Codec represents users as they always were
+type v1Codec struct{}
+
+func (v1Codec) version() string { return "v1" }
+
+func (v1Codec) idFormat() IDFormat { return "" }
+
+func (v1Codec) user(u User) any { return u }
+
+func (v1Codec) users(users []User) any { return users }
+
+func (v1Codec) searchPage(query string, results []SearchResult) any {
+	return searchPage{Query: query, Results: results}
+}
+
+func (v1Codec) createRequest(r *http.Request) (createUserRequest, error) {
+	var req createUserRequest
+	err := decodeJSON(r, &req)
+	return req, err
+}
+
+func (v1Codec) createBody() any { return createUserRequest{} }
+
+// userV2 is a user in v2, where the name is display_name
+type userV2 struct {
+	ID          ID        `json:"id"`
+	DisplayName string    `json:"display_name"`
+	Email       string    `json:"email"`
+	Role        Role      `json:"role"`
+	Locale      string    `json:"locale,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// createUserRequestV2 is the body of POST /v2/users
+type createUserRequestV2 struct {
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Role        Role   `json:"role,omitempty"`
+	Locale      string `json:"locale,omitempty"`
+	Password    string `json:"password,omitempty"`
+}
+
+// searchResultV2 is a search result in v2
+type searchResultV2 struct {
+	User  userV2  `json:"user"`
+	Score float64 `json:"score"`
+}
+
+// searchPageV2 is the response of GET /v2/users/search
+type searchPageV2 struct {
+	Query   string           `json:"query"`
+	Results []searchResultV2 `json:"results"`
+}
+
+// v2Codec represents users as userV2, and renders every ID as a string
+// whatever ?id_format= says, so JavaScript clients never lose precision
+type v2Codec struct{}
+
+func (v2Codec) version() string { return "v2" }
+
+func (v2Codec) idFormat() IDFormat { return IDFormatString }
+
+func (v2Codec) user(u User) any { return toUserV2(u) }
+
+func (v2Codec) users(users []User) any {
+	out := make([]userV2, len(users))
+	for i, u := range users {
+		out[i] = toUserV2(u)
+	}
+	return out
+}
+
+func (v2Codec) searchPage(query string, results []SearchResult) any {
+	page := searchPageV2{Query: query, Results: make([]searchResultV2, len(results))}
+	for i, res := range results {
+		page.Results[i] = searchResultV2{User: toUserV2(res.User), Score: res.Score}
+	}
+	return page
+}
+
+func (v2Codec) createRequest(r *http.Request) (createUserRequest, error) {
+	var req createUserRequestV2
+	if err := decodeJSON(r, &req); err != nil {
+		return createUserRequest{}, err
+	}
+	return createUserRequest{
+		Name: req.DisplayName, Email: req.Email, Role: req.Role, Locale: req.Locale, Password: req.Password,
+	}, nil
+}
+
+func (v2Codec) createBody() any { return createUserRequestV2{} }
+
+// toUserV2 converts u to its v2 representation
+func toUserV2(u User) userV2 {
+	return userV2{
+		ID: u.ID, DisplayName: u.Name, Email: u.Email, Role: u.Role,
+		Locale: u.Locale, Tenant: u.Tenant, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt,
+	}
+}
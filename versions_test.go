@@ -0,0 +1,76 @@
+package quickserve
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+func TestUnversionedPath(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	for path, want := range map[string]string{
+		"/v1/users":      "/users",
+		"/v2/users/{id}": "/users/{id}",
+		"/users":         "/users",
+		"/v2users":       "/v2users",
+	} {
+		if got := unversionedPath(path); got != want {
+			t.Errorf("%s: expected %q, got %q", path, want, got)
+		}
+	}
+	if got := unscopedPattern("DELETE /v2/users/{id}"); got != "DELETE /users/{id}" {
+		t.Errorf("expected versioned routes to share the user routes' permissions, got %q", got)
+	}
+}
+
+func TestVersionedUserRoutes(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	server := NewServer(WithResponseCache(10))
+	routes := server.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodPost, "/v2/users", `{"display_name":"Alice","email":"alice@example.com"}`)
+	var created map[string]any
+	json.NewDecoder(w.Body).Decode(&created)
+	if w.Code != http.StatusCreated || created["id"] != "1" || created["display_name"] != "Alice" {
+		t.Fatalf("expected a v2 user created, got %d: %v", w.Code, created)
+	}
+	if w := do(http.MethodPost, "/v2/users", `{"name":"Bob","email":"bob@example.com"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a v1 body refused by v2, got %d", w.Code)
+	}
+
+	// v1 is what the unprefixed routes always served
+	unprefixed := do(http.MethodGet, "/users/1", "")
+	if v1 := do(http.MethodGet, "/v1/users/1", ""); v1.Body.String() != unprefixed.Body.String() {
+		t.Errorf("expected v1 to match the unprefixed route, got %s and %s", v1.Body, unprefixed.Body)
+	}
+	// Cached per version, so neither gets the other's shape
+	for range 2 {
+		var user map[string]any
+		json.NewDecoder(do(http.MethodGet, "/v2/users/1?id_format=number", "").Body).Decode(&user)
+		if user["id"] != "1" || user["display_name"] != "Alice" || user["name"] != nil {
+			t.Errorf("expected the v2 user with a string ID, got %v", user)
+		}
+	}
+
+	var users []map[string]any
+	json.NewDecoder(do(http.MethodGet, "/v2/users", "").Body).Decode(&users)
+	if len(users) != 1 || users[0]["display_name"] != "Alice" {
+		t.Errorf("expected v2 users listed, got %v", users)
+	}
+	var post map[string]any
+	json.NewDecoder(do(http.MethodPost, "/v2/users/1/posts", `{"title":"Hello"}`).Body).Decode(&post)
+	if post["user_id"] != "1" {
+		t.Errorf("expected every v2 ID rendered as a string, got %v", post)
+	}
+}
@@ -0,0 +1,331 @@
+package quickserve
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// WebhookEventHeader names the event type of a webhook delivery
+	WebhookEventHeader = "X-Webhook-Event"
+	// defaultWebhookWorkers is how many deliveries run at once
+	defaultWebhookWorkers = 4
+	// webhookQueueSize is how many deliveries may wait for a worker before
+	// new ones are dropped
+	webhookQueueSize = 1024
+	// webhookAttempts is how often a delivery is tried before giving up
+	webhookAttempts = 3
+	// defaultWebhookBackoff is the wait before the first retry; it doubles
+	// after each failure
+	defaultWebhookBackoff = time.Second
+)
+
+var errWebhookLimit = errors.New("webhook limit reached")
+
+// Webhook is a URL registered to receive user events. The secret signing
+// deliveries is only shown when the webhook is created.
+type Webhook struct {
+	ID     ID       `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Tenant limits deliveries to events about the tenant's users; empty
+	// means every user
+	Tenant        string     `json:"tenant,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	secret string
+}
+
+// wants reports whether the webhook subscribed to ev
+func (h Webhook) wants(ev UserEvent) bool {
+	return slices.Contains(h.Events, ev.Type) && (h.Tenant == "" || h.Tenant == ev.tenant)
+}
+
+// WebhookStore is an in-memory webhook store
+type WebhookStore struct {
+	mu    sync.RWMutex
+	hooks map[ID]Webhook
+	next  ID
+	clock Clock
+}
+
+// NewWebhookStore creates an empty webhook store
+func NewWebhookStore() *WebhookStore {
+	return &WebhookStore{
+		hooks: make(map[ID]Webhook),
+		next:  1,
+		clock: SystemClock{},
+	}
+}
+
+// Create registers a webhook for events and returns it with its signing
+// secret. It fails with errWebhookLimit if the tenant already has limit
+// webhooks; zero or less means no limit.
+func (s *WebhookStore) Create(u string, events []string, tenant string, limit int) (Webhook, string, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return Webhook{}, "", err
+	}
+	secret = "whsec_" + secret
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit > 0 {
+		n := 0
+		for _, h := range s.hooks {
+			if h.Tenant == tenant {
+				n++
+			}
+		}
+		if n >= limit {
+			return Webhook{}, "", errWebhookLimit
+		}
+	}
+	now := s.clock.Now()
+	hook := Webhook{ID: s.next, URL: u, Events: events, Tenant: tenant, CreatedAt: now, UpdatedAt: now, secret: secret}
+	s.hooks[s.next] = hook
+	s.next++
+	return hook, secret, nil
+}
+
+// Get retrieves a webhook by ID
+func (s *WebhookStore) Get(id ID) (Webhook, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.hooks[id]
+	return h, ok
+}
+
+// List returns every webhook in ID order
+func (s *WebhookStore) List() []Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hooks := make([]Webhook, 0, len(s.hooks))
+	for _, h := range s.hooks {
+		hooks = append(hooks, h)
+	}
+	slices.SortFunc(hooks, func(a, b Webhook) int { return cmp.Compare(a.ID, b.ID) })
+	return hooks
+}
+
+// Delete removes a webhook, reporting whether it existed
+func (s *WebhookStore) Delete(id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.hooks[id]
+	delete(s.hooks, id)
+	return ok
+}
+
+// DeleteTenant removes the webhooks of tenant, returning how many
+func (s *WebhookStore) DeleteTenant(tenant string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.hooks)
+	maps.DeleteFunc(s.hooks, func(_ ID, h Webhook) bool { return h.Tenant == tenant })
+	return n - len(s.hooks)
+}
+
+// matching returns the webhooks subscribed to ev
+func (s *WebhookStore) matching(ev UserEvent) []Webhook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var hooks []Webhook
+	for _, h := range s.hooks {
+		if h.wants(ev) {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// recordAttempt notes the outcome of the latest delivery to a webhook
+func (s *WebhookStore) recordAttempt(id ID, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hooks[id]
+	if !ok {
+		return
+	}
+	now := s.clock.Now()
+	h.LastAttemptAt, h.LastError = &now, ""
+	if err != nil {
+		h.LastError = err.Error()
+	}
+	s.hooks[id] = h
+}
+
+// WithWebhookWorkers sets how many webhook deliveries run at once
+func WithWebhookWorkers(n int) Option {
+	return func(s *Server) {
+		s.webhookWorkers = n
+	}
+}
+
+// webhookDelivery is one event on its way to one webhook
+type webhookDelivery struct {
+	hook  Webhook
+	event UserEvent
+	data  []byte
+}
+
+// webhookPool delivers events with a fixed number of workers, started
+// with the first delivery so servers without webhooks run none
+type webhookPool struct {
+	queue chan webhookDelivery
+	once  sync.Once
+}
+
+func newWebhookPool() *webhookPool {
+	return &webhookPool{queue: make(chan webhookDelivery, webhookQueueSize)}
+}
+
+// dispatchWebhooks is the event bus sink queueing ev for every webhook
+// subscribed to it. A full queue drops the delivery rather than hold up
+// the request that made the change.
+func (s *Server) dispatchWebhooks(ctx context.Context, ev UserEvent) {
+	hooks := s.webhooks.matching(ev)
+	if len(hooks) == 0 {
+		return
+	}
+	data, err := s.encodeEvent(ev)
+	if err != nil {
+		return
+	}
+	s.webhookPool.once.Do(func() {
+		for i := 0; i < max(s.webhookWorkers, 1); i++ {
+			s.tasks.Go("webhooks", s.runWebhookWorker)
+		}
+	})
+	logger := s.componentLogger("webhooks")
+	for _, h := range hooks {
+		select {
+		case s.webhookPool.queue <- webhookDelivery{hook: h, event: ev, data: data}:
+		default:
+			logger.WarnContext(ctx, "queue full; delivery dropped", "webhook_id", h.ID, "event_id", ev.ID)
+		}
+	}
+}
+
+// runWebhookWorker delivers queued events until the server closes
+func (s *Server) runWebhookWorker(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case d := <-s.webhookPool.queue:
+			s.deliverWebhook(ctx, d)
+		}
+	}
+}
+
+// deliverWebhook posts an event, signed with the webhook's secret,
+// retrying failures with exponential backoff
+func (s *Server) deliverWebhook(ctx context.Context, d webhookDelivery) {
+	header := http.Header{WebhookEventHeader: {d.event.Type}}
+	if d.event.RequestID != "" {
+		header.Set(RequestIDHeader, d.event.RequestID)
+	}
+	backoff := s.webhookBackoff
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		// Signed per attempt so retries don't fall outside the window
+		header.Set(SignatureHeader, Sign(d.hook.secret, s.clock.Now(), d.data))
+		attemptCtx, cancel := context.WithTimeout(ctx, webhookTimeout)
+		err = postWebhook(attemptCtx, s.webhookClient, d.hook.URL, d.data, header)
+		cancel()
+		if err == nil || attempt == webhookAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(backoff):
+		}
+		backoff *= 2
+	}
+	s.webhooks.recordAttempt(d.hook.ID, err)
+	if err != nil {
+		s.componentLogger("webhooks").WarnContext(ctx, "delivery failed",
+			"webhook_id", d.hook.ID, "event_id", d.event.ID, "attempts", webhookAttempts, "err", err)
+	}
+}
+
+// HandleListWebhooks handles GET /admin/webhooks
+func (s *Server) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, s.webhooks.List())
+}
+
+// createWebhookRequest is the body of POST /admin/webhooks
+type createWebhookRequest struct {
+	URL string `json:"url"`
+	// Events defaults to every user event type
+	Events []string `json:"events,omitempty"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+// HandleCreateWebhook handles POST /admin/webhooks. The response carries
+// the signing secret, which is not shown again.
+func (s *Server) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		httpError(w, r, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		httpError(w, r, "url must be an http or https URL", http.StatusBadRequest)
+		return
+	}
+	if len(req.Events) == 0 {
+		req.Events = slices.Clone(userEventTypes)
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(userEventTypes, e) {
+			httpError(w, r, "unknown event "+e, http.StatusBadRequest)
+			return
+		}
+	}
+
+	hook, secret, err := s.webhooks.Create(req.URL, req.Events, req.Tenant, s.settingsFor(req.Tenant).WebhookLimit)
+	if errors.Is(err, errWebhookLimit) {
+		httpError(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		httpError(w, r, "could not generate secret", http.StatusInternalServerError)
+		return
+	}
+	s.recordAudit(r, AuditCreate, "webhook", hook.ID.String(), nil, hook)
+
+	s.writeJSON(w, r, http.StatusCreated, struct {
+		Webhook
+		Secret string `json:"secret"`
+	}{hook, secret})
+}
+
+// HandleDeleteWebhook handles DELETE /admin/webhooks/{id}
+func (s *Server) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := ParseID(r.PathValue("id"))
+	if err != nil {
+		httpError(w, r, "invalid id", http.StatusBadRequest)
+		return
+	}
+	before, ok := s.webhooks.Get(id)
+	if !ok || !s.webhooks.Delete(id) {
+		httpError(w, r, "webhook not found", http.StatusNotFound)
+		return
+	}
+	s.recordAudit(r, AuditDelete, "webhook", id.String(), before, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,192 @@
+package quickserve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// webhookCall is one delivery as seen by the receiver
+type webhookCall struct {
+	header http.Header
+	body   []byte
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	calls := make(chan webhookCall, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		calls <- webhookCall{r.Header.Clone(), body}
+	}))
+	defer receiver.Close()
+
+	s := NewServer(WithAPIKeyAuth("admin-secret"))
+	s.webhookClient = receiver.Client()
+	defer s.Close()
+	h := s.Routes()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(APIKeyHeader, "admin-secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/admin/webhooks", `{"url":"`+receiver.URL+`","events":["user.deleted"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Webhook
+		Secret string `json:"secret"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !strings.HasPrefix(created.Secret, "whsec_") {
+		t.Fatalf("expected a signing secret, got %q", created.Secret)
+	}
+	if strings.Contains(do(http.MethodGet, "/admin/webhooks", "").Body.String(), created.Secret) {
+		t.Error("expected the secret not to be listed")
+	}
+
+	// Only the subscribed event is delivered
+	do(http.MethodPost, "/users", `{"name":"Alice","email":"alice@test.com"}`)
+	do(http.MethodDelete, "/users/1", "")
+	var call webhookCall
+	select {
+	case call = <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the deletion to be delivered")
+	}
+	var ev UserEvent
+	if err := json.Unmarshal(call.body, &ev); err != nil || ev.Type != EventUserDeleted || ev.UserID != 1 {
+		t.Errorf("expected Alice's deletion, got %s", call.body)
+	}
+	if got := call.header.Get(WebhookEventHeader); got != EventUserDeleted {
+		t.Errorf("expected the event header, got %q", got)
+	}
+	if call.header.Get(RequestIDHeader) != ev.RequestID || ev.RequestID == "" {
+		t.Errorf("expected the request ID of the change, got %q", call.header.Get(RequestIDHeader))
+	}
+	sig := call.header.Get(SignatureHeader)
+	ts, _ := parseSignature(sig)
+	unix, _ := strconv.ParseInt(ts, 10, 64)
+	if sig == "" || Sign(created.Secret, time.Unix(unix, 0), call.body) != sig {
+		t.Errorf("expected the body to be signed with the secret, got %q", sig)
+	}
+	select {
+	case extra := <-calls:
+		t.Errorf("expected only the deletion, got %s", extra.body)
+	default:
+	}
+
+	if w := do(http.MethodDelete, "/admin/webhooks/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/admin/webhooks/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 once deleted, got %d", w.Code)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	var attempts atomic.Int32
+	delivered := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < webhookAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(delivered)
+	}))
+	defer receiver.Close()
+
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewServer(WithClock(clock))
+	s.webhookClient = receiver.Client()
+	defer s.Close()
+	hook, _, err := s.webhooks.Create(receiver.URL, userEventTypes, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.dispatchWebhooks(context.Background(), UserEvent{ID: 1, Type: EventUserCreated, UserID: 1})
+	// Each retry waits for the clock, doubling the backoff
+	for backoff, i := s.webhookBackoff, 1; i < webhookAttempts; backoff, i = backoff*2, i+1 {
+		clock.awaitWaiter(t, backoff)
+		if n := attempts.Load(); n != int32(i) {
+			t.Fatalf("expected retry %d to wait for the clock, got %d attempts", i, n)
+		}
+		clock.Advance(backoff)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a delivery after retries, got %d attempts", attempts.Load())
+	}
+	// The attempt is recorded after the receiver answers
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := s.webhooks.Get(hook.ID)
+		if got.LastAttemptAt != nil {
+			if got.LastError != "" {
+				t.Errorf("expected the last attempt to succeed, got %q", got.LastError)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the attempt to be recorded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebhookStore(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewWebhookStore()
+	acme, _, err := s.Create("https://acme.example/hook", []string{EventUserCreated}, "acme", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Create("https://acme.example/other", userEventTypes, "acme", 1); !errors.Is(err, errWebhookLimit) {
+		t.Errorf("expected the tenant's limit to be enforced, got %v", err)
+	}
+	global, _, err := s.Create("https://ops.example/hook", userEventTypes, "", 1)
+	if err != nil {
+		t.Errorf("expected the limit to be per tenant, got %v", err)
+	}
+
+	tests := []struct {
+		ev   UserEvent
+		want []ID
+	}{
+		{UserEvent{Type: EventUserCreated, tenant: "acme"}, []ID{acme.ID, global.ID}},
+		{UserEvent{Type: EventUserCreated, tenant: "globex"}, []ID{global.ID}},
+		{UserEvent{Type: EventUserDeleted, tenant: "acme"}, []ID{global.ID}},
+	}
+	for _, tt := range tests {
+		var got []ID
+		for _, h := range s.List() {
+			if h.wants(tt.ev) {
+				got = append(got, h.ID)
+			}
+		}
+		if len(got) != len(s.matching(tt.ev)) || !slices.Equal(got, tt.want) {
+			t.Errorf("%s for %q: expected %v, got %v", tt.ev.Type, tt.ev.tenant, tt.want, got)
+		}
+	}
+}
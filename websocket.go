@@ -0,0 +1,339 @@
+package quickserve
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/harshakonda/quickserve/events"
+)
+
+// wsPath upgrades to a WebSocket pushing user events
+const wsPath = "/ws"
+
+const (
+	// wsWriteWait bounds one write to a client
+	wsWriteWait = 10 * time.Second
+	// wsMaxFrame is the largest frame a client may send; clients only
+	// need control frames
+	wsMaxFrame = 4096
+	// wsAcceptGUID is mixed into Sec-WebSocket-Accept (RFC 6455, 1.3)
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+	wsCloseProtocol  = 1002
+	wsCloseTooBig    = 1009
+	wsCloseTryAgain  = 1013
+)
+
+// isWebSocketUpgrade reports whether r asks to switch to WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && headerHasToken(r.Header, "Connection", "upgrade")
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsAccept computes Sec-WebSocket-Accept for a client key
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// sameOrigin reports whether a browser's Origin matches the host it
+// connected to. Browsers send cookies on cross-site WebSocket handshakes,
+// so without this check any page could read events with a visitor's
+// session.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || hasHeaderCredentials(r) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// HandleWebSocket handles GET /ws, upgrading to a WebSocket that pushes
+// each user event as a JSON text message. ?events= limits the stream to
+// a comma-separated list of types. The connection is pinged while idle;
+// clients that stop answering, or fall too far behind, are disconnected.
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !isWebSocketUpgrade(r) || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		httpError(w, r, "websocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.ProtoMajor != 1 {
+		httpError(w, r, "websocket needs HTTP/1.1", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		httpError(w, r, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	if !sameOrigin(r) {
+		httpError(w, r, "cross-origin websocket refused", http.StatusForbidden)
+		return
+	}
+	types, err := eventTypesParam(r)
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		httpError(w, r, "websocket not supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+	// Server read and write timeouts are meant for requests, not for a
+	// connection that stays open
+	conn.SetDeadline(time.Time{})
+
+	// Headers set so far, such as the request ID, go out with the 101
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	fmt.Fprintf(&resp, "Sec-WebSocket-Accept: %s\r\n", wsAccept(key))
+	w.Header().Write(&resp)
+	resp.WriteString("\r\n")
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if _, err := io.WriteString(conn, resp.String()); err != nil {
+		return
+	}
+
+	sub := s.events.Subscribe(types)
+	defer s.events.Unsubscribe(sub)
+	ws := &wsConn{conn: conn}
+	s.serveWebSocket(r.Context(), ws, brw.Reader, sub)
+}
+
+// wsConn serializes writes to a WebSocket
+type wsConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *wsConn) write(op byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return writeWSFrame(c.conn, op, payload, false)
+}
+
+// close sends a close frame with code and reason
+func (c *wsConn) close(code int, reason string) {
+	c.write(wsClose, wsClosePayload(code, reason))
+}
+
+// serveWebSocket pushes sub's events over ws until the client goes away,
+// stops answering, falls behind, or the server shuts down. Events of
+// other tenants than ctx's are left out.
+func (s *Server) serveWebSocket(ctx context.Context, ws *wsConn, br *bufio.Reader, sub *events.Subscription[UserEvent]) {
+	interval := s.keepaliveInterval()
+
+	// The reader answers pings and notices closes and dead clients: any
+	// frame, pongs included, keeps the connection alive for two intervals
+	readDone := make(chan int, 1)
+	go func() {
+		readDone <- ws.readLoop(br, 2*interval)
+	}()
+	defer func() {
+		// Unblocks the reader if it is still waiting for a frame
+		ws.conn.Close()
+		<-readDone
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case m := <-sub.Events():
+			if !eventVisible(ctx, m.Event) {
+				continue
+			}
+			if ws.write(wsText, m.Data) != nil {
+				return
+			}
+		case <-ticker.C:
+			if ws.write(wsPing, nil) != nil {
+				return
+			}
+		case <-sub.Dropped():
+			ws.close(wsCloseTryAgain, "too slow")
+			return
+		case code := <-readDone:
+			readDone <- code
+			if code != 0 {
+				ws.close(code, "")
+			}
+			return
+		case <-s.streamsDone:
+			ws.close(wsCloseGoingAway, "server shutting down")
+			return
+		}
+	}
+}
+
+// readLoop reads client frames until the connection ends, answering
+// pings. It returns the close code to send back, or 0 if the connection
+// is already gone.
+func (c *wsConn) readLoop(br *bufio.Reader, idle time.Duration) int {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idle))
+		f, err := readWSFrame(br, wsMaxFrame)
+		switch {
+		case errors.Is(err, errWSFrameTooBig):
+			return wsCloseTooBig
+		case err != nil:
+			return 0
+		case !f.masked:
+			// RFC 6455, 5.1: clients must mask every frame
+			return wsCloseProtocol
+		}
+		switch f.op {
+		case wsPing:
+			if c.write(wsPong, f.payload) != nil {
+				return 0
+			}
+		case wsClose:
+			// Echo the client's code, as the close handshake expects
+			if len(f.payload) >= 2 {
+				return int(binary.BigEndian.Uint16(f.payload))
+			}
+			return wsCloseNormal
+		case wsPong, wsText, wsBinary, wsContinuation:
+			// Messages from clients carry nothing; reading them is enough
+			// to keep the connection alive
+		default:
+			return wsCloseProtocol
+		}
+	}
+}
+
+var errWSFrameTooBig = errors.New("websocket frame too large")
+
+// wsFrame is one WebSocket frame, unmasked
+type wsFrame struct {
+	op      byte
+	fin     bool
+	masked  bool
+	payload []byte
+}
+
+// readWSFrame reads one frame of at most max payload bytes
+func readWSFrame(r io.Reader, max int64) (wsFrame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{op: head[0] & 0x0F, fin: head[0]&0x80 != 0, masked: head[1]&0x80 != 0}
+	n := int64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return wsFrame{}, err
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if n > max {
+		return wsFrame{}, errWSFrameTooBig
+	}
+	var key [4]byte
+	if f.masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return wsFrame{}, err
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return wsFrame{}, err
+	}
+	if f.masked {
+		for i := range f.payload {
+			f.payload[i] ^= key[i%4]
+		}
+	}
+	return f, nil
+}
+
+// writeWSFrame writes payload as a single final frame. Clients must mask
+// what they send; servers must not.
+func writeWSFrame(w io.Writer, op byte, payload []byte, mask bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|op)
+	var maskBit byte
+	if mask {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if !mask {
+		buf = append(buf, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		for i, b := range payload {
+			buf = append(buf, b^key[i%4])
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+func wsClosePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
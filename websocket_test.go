@@ -0,0 +1,255 @@
+package quickserve
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/harshakonda/heapcheck/guard"
+)
+
+// dialWS opens a WebSocket to path on ts and checks the handshake
+func dialWS(t *testing.T, ts *httptest.Server, path string, header http.Header) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("expected the upgrade to be accepted, got %s %v", resp.Status, resp.Header)
+	}
+	if resp.Header.Get(RequestIDHeader) == "" {
+		t.Error("expected the handshake to carry the request ID")
+	}
+	return conn, br
+}
+
+// readWSMessage returns the next frame that is not a ping
+func readWSMessage(t *testing.T, conn net.Conn, br *bufio.Reader) wsFrame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		f, err := readWSFrame(br, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.op != wsPing {
+			return f
+		}
+	}
+}
+
+func readUserEvent(t *testing.T, conn net.Conn, br *bufio.Reader) UserEvent {
+	t.Helper()
+	f := readWSMessage(t, conn, br)
+	var ev UserEvent
+	if f.op != wsText || json.Unmarshal(f.payload, &ev) != nil {
+		t.Fatalf("expected an event, got opcode %d %q", f.op, f.payload)
+	}
+	return ev
+}
+
+func TestWebSocketEvents(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer(WithAPIKeyAuth("admin-secret"))
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+	auth := http.Header{APIKeyHeader: {"admin-secret"}}
+
+	all, allReader := dialWS(t, ts, "/ws", auth)
+	defer all.Close()
+	deletes, deletesReader := dialWS(t, ts, "/ws?events=user.deleted", auth)
+	defer deletes.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/users", strings.NewReader(`{"name":"Alice","email":"alice@test.com"}`))
+	req.Header = auth.Clone()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	created := readUserEvent(t, all, allReader)
+	var alice User
+	json.Unmarshal(created.User, &alice)
+	if created.Type != EventUserCreated || created.UserID != 1 || alice.Name != "Alice" || created.RequestID == "" {
+		t.Errorf("expected Alice's creation, got %+v", created)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, ts.URL+"/users/1", nil)
+	req.Header = auth.Clone()
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	for _, c := range []struct {
+		conn net.Conn
+		br   *bufio.Reader
+	}{{all, allReader}, {deletes, deletesReader}} {
+		ev := readUserEvent(t, c.conn, c.br)
+		if ev.Type != EventUserDeleted || ev.UserID != 1 || ev.ID <= created.ID {
+			t.Errorf("expected Alice's deletion, got %+v", ev)
+		}
+	}
+
+	// The close handshake echoes the client's code
+	if err := writeWSFrame(all, wsClose, wsClosePayload(wsCloseNormal, "bye"), true); err != nil {
+		t.Fatal(err)
+	}
+	if f := readWSMessage(t, all, allReader); f.op != wsClose || binary.BigEndian.Uint16(f.payload) != wsCloseNormal {
+		t.Errorf("expected the close to be echoed, got opcode %d %q", f.op, f.payload)
+	}
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := httptest.NewServer(NewServer(WithAPIKeyAuth("admin-secret")).Routes())
+	defer ts.Close()
+
+	upgrade := make(http.Header)
+	upgrade.Set("Upgrade", "websocket")
+	upgrade.Set("Connection", "Upgrade")
+	upgrade.Set("Sec-WebSocket-Version", "13")
+	upgrade.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	upgrade.Set(APIKeyHeader, "admin-secret")
+	tests := []struct {
+		name   string
+		path   string
+		modify func(h http.Header)
+		want   int
+	}{
+		{"not an upgrade", "/ws", func(h http.Header) { h.Del("Upgrade") }, http.StatusUpgradeRequired},
+		{"unauthenticated", "/ws", func(h http.Header) { h.Del(APIKeyHeader) }, http.StatusUnauthorized},
+		{"old version", "/ws", func(h http.Header) { h.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"no key", "/ws", func(h http.Header) { h.Del("Sec-WebSocket-Key") }, http.StatusBadRequest},
+		{"unknown event", "/ws?events=user.renamed", func(http.Header) {}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			req.Header = upgrade.Clone()
+			tt.modify(req.Header)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+
+	// Browsers send session cookies on cross-site handshakes, but never an
+	// API key, so a foreign origin is refused unless one is present
+	open := httptest.NewServer(NewServer().Routes())
+	defer open.Close()
+	for key, want := range map[string]int{"": http.StatusForbidden, "admin-secret": http.StatusSwitchingProtocols} {
+		req, _ := http.NewRequest(http.MethodGet, open.URL+"/ws", nil)
+		req.Header = upgrade.Clone()
+		req.Header.Set("Origin", "https://evil.example")
+		req.Header.Set(APIKeyHeader, key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("cross origin with key %q: expected %d, got %d", key, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer(WithStreamKeepalive(20 * time.Millisecond))
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+
+	conn, br := dialWS(t, ts, "/ws", nil)
+	defer conn.Close()
+
+	// Answering pings keeps the connection open past two intervals
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		f, err := readWSFrame(br, 1<<20)
+		if err != nil || f.op != wsPing {
+			t.Fatalf("expected pings, got opcode %d, %v", f.op, err)
+		}
+		if err := writeWSFrame(conn, wsPong, f.payload, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A client that goes quiet is disconnected
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, err := readWSFrame(br, 1<<20); err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("expected a silent client to be disconnected")
+			}
+			break
+		}
+	}
+	if n := s.events.Subscribers(); n != 0 {
+		t.Errorf("expected the client to be unsubscribed, %d left", n)
+	}
+}
+
+func TestWebSocketUnmaskedFrame(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	ts := httptest.NewServer(NewServer().Routes())
+	defer ts.Close()
+
+	conn, br := dialWS(t, ts, "/ws", nil)
+	defer conn.Close()
+	if err := writeWSFrame(conn, wsText, []byte("hi"), false); err != nil {
+		t.Fatal(err)
+	}
+	if f := readWSMessage(t, conn, br); f.op != wsClose || binary.BigEndian.Uint16(f.payload) != wsCloseProtocol {
+		t.Errorf("expected a protocol error, got opcode %d %q", f.op, f.payload)
+	}
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	defer guard.VerifyNone(t)
+
+	s := NewServer()
+	ts := httptest.NewServer(s.Routes())
+	defer ts.Close()
+
+	conn, br := dialWS(t, ts, "/ws", nil)
+	defer conn.Close()
+	s.Close()
+	if f := readWSMessage(t, conn, br); f.op != wsClose || binary.BigEndian.Uint16(f.payload) != wsCloseGoingAway {
+		t.Errorf("expected going away, got opcode %d %q", f.op, f.payload)
+	}
+}